@@ -0,0 +1,101 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package annotator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+
+	pdfcore "github.com/gnaoh1379/unipdf/core"
+	pdf "github.com/gnaoh1379/unipdf/model"
+)
+
+// HighlightAnnotationDef defines a highlight annotation covering one or more rectangular regions of
+// a page, such as the parts of a phrase on consecutive lines of text.
+type HighlightAnnotationDef struct {
+	Rects    []pdf.PdfRectangle     // The highlighted regions in page coordinates.
+	Color    *pdf.PdfColorDeviceRGB // Highlight color. Yellow if nil.
+	Contents string                 // Optional text of the annotation, e.g. the highlighted text.
+}
+
+// CreateHighlightAnnotation creates a highlight annotation object that can be added to page PDF
+// annotations. The appearance stream paints the regions with the multiply blend mode so that the
+// text below the highlight remains legible.
+func CreateHighlightAnnotation(def HighlightAnnotationDef) (*pdf.PdfAnnotation, error) {
+	if len(def.Rects) == 0 {
+		return nil, errors.New("highlight annotation has no regions")
+	}
+	color := def.Color
+	if color == nil {
+		color = pdf.NewPdfColorDeviceRGB(1, 1, 0)
+	}
+	r, g, b := color.R(), color.G(), color.B()
+
+	annotation := pdf.NewPdfAnnotationHighlight()
+	annotation.C = pdfcore.MakeArrayFromFloats([]float64{r, g, b})
+	if def.Contents != "" {
+		annotation.Contents = pdfcore.MakeString(def.Contents)
+	}
+
+	// The quadrilaterals are given in the order used by most PDF viewers: upper left, upper right,
+	// lower left, lower right.
+	bbox := def.Rects[0]
+	var quads []float64
+	for _, rect := range def.Rects {
+		quads = append(quads,
+			rect.Llx, rect.Ury, rect.Urx, rect.Ury,
+			rect.Llx, rect.Lly, rect.Urx, rect.Lly)
+		bbox.Llx = math.Min(bbox.Llx, rect.Llx)
+		bbox.Lly = math.Min(bbox.Lly, rect.Lly)
+		bbox.Urx = math.Max(bbox.Urx, rect.Urx)
+		bbox.Ury = math.Max(bbox.Ury, rect.Ury)
+	}
+	annotation.QuadPoints = pdfcore.MakeArrayFromFloats(quads)
+	annotation.Rect = bbox.ToPdfObject()
+
+	apDict, err := makeHighlightAnnotationAppearanceStream(def.Rects, bbox, r, g, b)
+	if err != nil {
+		return nil, err
+	}
+	annotation.AP = apDict
+
+	return annotation.PdfAnnotation, nil
+}
+
+func makeHighlightAnnotationAppearanceStream(rects []pdf.PdfRectangle, bbox pdf.PdfRectangle,
+	r, g, b float64) (*pdfcore.PdfObjectDictionary, error) {
+	form := pdf.NewXObjectForm()
+	form.Resources = pdf.NewPdfPageResources()
+
+	gsState := pdfcore.MakeDict()
+	gsState.Set("BM", pdfcore.MakeName("Multiply"))
+	if err := form.Resources.AddExtGState("gs1", gsState); err != nil {
+		common.Log.Debug("Unable to add extgstate gs1")
+		return nil, err
+	}
+
+	// The form is drawn in page coordinates. Its bounding box is the annotation rectangle so it is
+	// not transformed when it is mapped to the rectangle.
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "q /gs1 gs %.4f %.4f %.4f rg\n", r, g, b)
+	for _, rect := range rects {
+		fmt.Fprintf(&content, "%.4f %.4f %.4f %.4f re f\n",
+			rect.Llx, rect.Lly, rect.Width(), rect.Height())
+	}
+	content.WriteString("Q\n")
+	if err := form.SetContentStream(content.Bytes(), nil); err != nil {
+		return nil, err
+	}
+	form.BBox = bbox.ToPdfObject()
+
+	apDict := pdfcore.MakeDict()
+	apDict.Set("N", form.ToPdfObject())
+	return apDict, nil
+}
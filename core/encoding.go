@@ -15,7 +15,7 @@ package core
 // - ASCII85
 // - CCITT Fax (dummy)
 // - JBIG2 (dummy)
-// - JPX (dummy)
+// - JPX (decoding only)
 
 import (
 	"bytes"
@@ -36,6 +36,7 @@ import (
 	"github.com/gnaoh1379/unipdf/common"
 
 	"github.com/gnaoh1379/unipdf/internal/ccittfax"
+	"github.com/gnaoh1379/unipdf/internal/jpx"
 )
 
 // Stream encoding filter names.
@@ -1986,15 +1987,65 @@ func (enc *CCITTFaxEncoder) EncodeBytes(data []byte) ([]byte, error) {
 	return encoder.Encode(pixels), nil
 }
 
-// JPXEncoder implements JPX encoder/decoder (dummy, for now)
-// FIXME: implement
-type JPXEncoder struct{}
+// JPXEncoder implements JPX (JPEG 2000) encoder/decoder. Only decoding is supported.
+type JPXEncoder struct {
+	ColorComponents  int // Number of color components, without the opacity channel.
+	BitsPerComponent int // 1, 2, 4, 8 or 16 bit
+	Width            int
+	Height           int
+	// SMaskInData specifies how the opacity channel embedded in the JPX data is used
+	// (the SMaskInData entry of the image dictionary). If zero, the opacity channel is
+	// ignored. If nonzero, it is used as the soft mask of the image.
+	SMaskInData int
+}
 
 // NewJPXEncoder returns a new instance of JPXEncoder.
 func NewJPXEncoder() *JPXEncoder {
 	return &JPXEncoder{}
 }
 
+// newJPXEncoderFromStream creates a new JPX encoder with the image parameters loaded
+// from the JPX data. If the JPX data cannot be parsed, the encoder with the default
+// parameters is returned and the error is reported when the data is decoded.
+func newJPXEncoderFromStream(streamObj *PdfObjectStream, multiEnc *MultiEncoder) (*JPXEncoder, error) {
+	encoder := NewJPXEncoder()
+
+	encDict := streamObj.PdfObjectDictionary
+	if encDict == nil {
+		// No encoding dictionary.
+		return encoder, nil
+	}
+	if smaskInData, err := GetNumberAsInt64(encDict.Get("SMaskInData")); err == nil {
+		encoder.SMaskInData = int(smaskInData)
+	}
+
+	// If using JPXDecode in combination with other filters, make sure to decode that first...
+	encoded := streamObj.Stream
+	if multiEnc != nil {
+		e, err := multiEnc.DecodeBytes(encoded)
+		if err != nil {
+			return nil, err
+		}
+		encoded = e
+	}
+
+	cfg, err := jpx.DecodeConfig(encoded)
+	if err != nil {
+		common.Log.Debug("Error decoding JPX header: %v", err)
+		return encoder, nil
+	}
+	encoder.setConfig(cfg)
+	common.Log.Trace("JPX Encoder: %+v", encoder)
+	return encoder, nil
+}
+
+func (enc *JPXEncoder) setConfig(cfg jpx.Config) {
+	enc.ColorComponents = cfg.ColorComponents
+	enc.BitsPerComponent = cfg.BitsPerComponent
+	enc.Width = cfg.Width
+	enc.Height = cfg.Height
+}
+
 // GetFilterName returns the name of the encoding filter.
 func (enc *JPXEncoder) GetFilterName() string {
 	return StreamEncodingFilterNameJPX
@@ -2008,24 +2059,41 @@ func (enc *JPXEncoder) MakeDecodeParams() PdfObject {
 
 // MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
 func (enc *JPXEncoder) MakeStreamDict() *PdfObjectDictionary {
-	return MakeDict()
+	dict := MakeDict()
+	dict.Set("Filter", MakeName(enc.GetFilterName()))
+	return dict
 }
 
 // UpdateParams updates the parameter values of the encoder.
 func (enc *JPXEncoder) UpdateParams(params *PdfObjectDictionary) {
 }
 
-// DecodeBytes decodes a slice of JPX encoded bytes and returns the result.
+// DecodeBytes decodes a slice of JPX encoded bytes and returns the color samples of
+// the image. The samples are stored the same way as the samples of the image XObjects,
+// with ColorComponents and BitsPerComponent of the encoder updated from the JPX data.
 func (enc *JPXEncoder) DecodeBytes(encoded []byte) ([]byte, error) {
-	common.Log.Debug("Error: Attempting to use unsupported encoding %s", enc.GetFilterName())
-	return encoded, ErrNoJPXDecode
+	data, _, err := enc.DecodeImage(encoded)
+	return data, err
+}
+
+// DecodeImage decodes a slice of JPX encoded bytes and returns the color samples and
+// the opacity samples of the image. The opacity samples are nil if the image has
+// no opacity channel, otherwise they are stored with the same BitsPerComponent as the
+// color samples.
+func (enc *JPXEncoder) DecodeImage(encoded []byte) (data []byte, alpha []byte, err error) {
+	img, err := jpx.Decode(encoded)
+	if err != nil {
+		common.Log.Debug("Error decoding JPX image: %v", err)
+		return nil, nil, err
+	}
+	enc.setConfig(img.Config)
+	return img.Data, img.Alpha, nil
 }
 
 // DecodeStream decodes a JPX encoded stream and returns the result as a
 // slice of bytes.
 func (enc *JPXEncoder) DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
-	common.Log.Debug("Error: Attempting to use unsupported encoding %s", enc.GetFilterName())
-	return streamObj.Stream, ErrNoJPXDecode
+	return enc.DecodeBytes(streamObj.Stream)
 }
 
 // EncodeBytes JPX encodes the passed in slice of bytes.
@@ -2148,6 +2216,12 @@ func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error
 			mencoder.AddEncoder(encoder)
 			common.Log.Trace("Added DCT encoder...")
 			common.Log.Trace("Multi encoder: %#v", mencoder)
+		} else if *name == StreamEncodingFilterNameJPX {
+			encoder, err := newJPXEncoderFromStream(streamObj, mencoder)
+			if err != nil {
+				return nil, err
+			}
+			mencoder.AddEncoder(encoder)
 		} else {
 			common.Log.Error("Unsupported filter %s", *name)
 			return nil, fmt.Errorf("invalid filter in multi filter array")
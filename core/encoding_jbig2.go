@@ -16,6 +16,7 @@ import (
 	"github.com/gnaoh1379/unipdf/internal/jbig2/bitmap"
 	"github.com/gnaoh1379/unipdf/internal/jbig2/decoder"
 	"github.com/gnaoh1379/unipdf/internal/jbig2/document"
+	"github.com/gnaoh1379/unipdf/internal/jbig2/encoder/classer"
 	"github.com/gnaoh1379/unipdf/internal/jbig2/errors"
 )
 
@@ -27,12 +28,10 @@ const (
 	JB2Generic JBIG2CompressionType = iota
 	// JB2SymbolCorrelation is the JBIG2 compression type that uses symbol dictionary and text region encoding procedure
 	// with the correlation classification.
-	// NOT IMPLEMENTED YET.
 	JB2SymbolCorrelation
 	// JB2SymbolRankHaus is the JBIG2 compression type that uses symbol dictionary and text region encoding procedure
 	// with the rank hausdorff classification. RankHausMode uses the rank Hausdorff method that classifies the input images.
 	// It is more robust, more susceptible to confusing components that should be in different classes.
-	// NOT IMPLEMENTED YET.
 	JB2SymbolRankHaus
 )
 
@@ -48,12 +47,14 @@ const JB2ImageAutoThreshold = -1.0
 // provided images (best used document scans) in multiple way. By default it uses single page generic
 // encoder. It allows to store lossless data as a single segment.
 // In order to store multiple image pages use the 'FileMode' which allows to store more pages within single jbig2 document.
-// WIP: In order to obtain better compression results the encoder would allow to encode the input in a
+// In order to obtain better compression results the encoder allows to encode the input in a
 // lossy or lossless way with a component (symbol) mode. It divides the image into components.
 // Then checks if any component is 'similar' to the others and maps them together. The symbol classes are stored
 // in the dictionary. Then the encoder creates text regions which uses the related symbol classes to fill it's space.
 // The similarity is defined by the 'Threshold' variable (default: 0.95). The less the value is, the more components
 // matches to single class, thus the compression is better, but the result might become lossy.
+// The symbols shared by multiple PDF image streams could be stored in a single JBIG2Globals stream
+// with the 'EncodeGlobals' setting and 'EncodePages' method.
 type JBIG2Encoder struct {
 	// These values are required to be set for the 'EncodeBytes' method.
 	// ColorComponents defines the number of color components for provided image.
@@ -76,6 +77,9 @@ type JBIG2Encoder struct {
 	IsChocolateData bool
 	// DefaultPageSettings are the settings parameters used by the jbig2 encoder.
 	DefaultPageSettings JBIG2EncoderSettings
+
+	// globalsStream is the JBIG2Globals stream produced by the 'EncodePages' method.
+	globalsStream *PdfObjectStream
 }
 
 // NewJBIG2Encoder creates a new JBIG2Encoder.
@@ -102,24 +106,17 @@ func (enc *JBIG2Encoder) AddPageImage(img *JBIG2Image, settings *JBIG2EncoderSet
 	if err = settings.Validate(); err != nil {
 		return errors.Wrap(err, processName, "")
 	}
+	if settings.EncodeGlobals {
+		enc.d.EncodeGlobals = true
+	}
 
 	// convert input 'img' to the bitmap.Bitmap
 	b, err := img.toBitmap()
 	if err != nil {
 		return errors.Wrap(err, processName, "")
 	}
-
-	switch settings.Compression {
-	case JB2Generic:
-		if err = enc.d.AddGenericPage(b, settings.DuplicatedLinesRemoval); err != nil {
-			return errors.Wrap(err, processName, "")
-		}
-	case JB2SymbolCorrelation:
-		return errors.Error(processName, "symbol correlation encoding not implemented yet")
-	case JB2SymbolRankHaus:
-		return errors.Error(processName, "symbol rank haus encoding not implemented yet")
-	default:
-		return errors.Error(processName, "provided invalid compression")
+	if err = enc.addPage(b, settings); err != nil {
+		return errors.Wrap(err, processName, "")
 	}
 	return nil
 }
@@ -189,18 +186,11 @@ func (enc *JBIG2Encoder) EncodeBytes(data []byte) ([]byte, error) {
 	if err = settings.Validate(); err != nil {
 		return nil, errors.Wrap(err, processName, "")
 	}
-
-	switch settings.Compression {
-	case JB2Generic:
-		if err = enc.d.AddGenericPage(b, settings.DuplicatedLinesRemoval); err != nil {
-			return nil, errors.Wrap(err, processName, "")
-		}
-	case JB2SymbolCorrelation:
-		return nil, errors.Error(processName, "symbol correlation encoding not implemented yet")
-	case JB2SymbolRankHaus:
-		return nil, errors.Error(processName, "symbol rank haus encoding not implemented yet")
-	default:
-		return nil, errors.Error(processName, "provided invalid compression")
+	if enc.d == nil {
+		enc.d = document.InitEncodeDocument(settings.FileMode)
+	}
+	if err = enc.addPage(b, &settings); err != nil {
+		return nil, errors.Wrap(err, processName, "")
 	}
 	return enc.Encode()
 }
@@ -234,6 +224,37 @@ func (enc *JBIG2Encoder) Encode() (data []byte, err error) {
 	return data, nil
 }
 
+// EncodePages encodes the pages added with the 'EncodeGlobals' setting into separate PDF embedded streams data.
+// The symbols shared by the pages are stored in the JBIG2Globals stream, which is returned by the GlobalsStream
+// method and set as the decode parameter of the encoder. The pages data are in the order they were added.
+func (enc *JBIG2Encoder) EncodePages() (pages [][]byte, err error) {
+	const processName = "JBIG2Encoder.EncodePages"
+	if enc.d == nil {
+		return nil, errors.Errorf(processName, "document input data not defined")
+	}
+	globals, pages, err := enc.d.EncodePages()
+	if err != nil {
+		return nil, errors.Wrap(err, processName, "")
+	}
+	if globals == nil {
+		return pages, nil
+	}
+	// set the globals in order to be able to decode the encoded pages.
+	if enc.Globals, err = jbig2.DecodeGlobals(globals); err != nil {
+		return nil, errors.Wrap(err, processName, "")
+	}
+	if enc.globalsStream, err = MakeStream(globals, nil); err != nil {
+		return nil, errors.Wrap(err, processName, "")
+	}
+	return pages, nil
+}
+
+// GlobalsStream returns the JBIG2Globals stream produced by the EncodePages method.
+// Returns nil if the encoded pages doesn't define any global segments.
+func (enc *JBIG2Encoder) GlobalsStream() *PdfObjectStream {
+	return enc.globalsStream
+}
+
 // GetFilterName returns the name of the encoding filter.
 func (enc *JBIG2Encoder) GetFilterName() string {
 	return StreamEncodingFilterNameJBIG2
@@ -241,13 +262,20 @@ func (enc *JBIG2Encoder) GetFilterName() string {
 
 // MakeDecodeParams makes a new instance of an encoding dictionary based on the current encoder settings.
 func (enc *JBIG2Encoder) MakeDecodeParams() PdfObject {
-	return MakeDict()
+	dict := MakeDict()
+	if enc.globalsStream != nil {
+		dict.Set("JBIG2Globals", enc.globalsStream)
+	}
+	return dict
 }
 
 // MakeStreamDict makes a new instance of an encoding dictionary for a stream object.
 func (enc *JBIG2Encoder) MakeStreamDict() *PdfObjectDictionary {
 	dict := MakeDict()
 	dict.Set("Filter", MakeName(enc.GetFilterName()))
+	if enc.globalsStream != nil {
+		dict.Set("DecodeParms", enc.MakeDecodeParams())
+	}
 	return dict
 }
 
@@ -272,6 +300,34 @@ func (enc *JBIG2Encoder) UpdateParams(params *PdfObjectDictionary) {
 	}
 }
 
+func (enc *JBIG2Encoder) addPage(b *bitmap.Bitmap, settings *JBIG2EncoderSettings) (err error) {
+	const processName = "addPage"
+	var method classer.Method
+	switch settings.Compression {
+	case JB2Generic:
+		if err = enc.d.AddGenericPage(b, settings.DuplicatedLinesRemoval); err != nil {
+			return errors.Wrap(err, processName, "")
+		}
+		return nil
+	case JB2SymbolCorrelation:
+		method = classer.Correlation
+	case JB2SymbolRankHaus:
+		method = classer.RankHaus
+	default:
+		return errors.Error(processName, "provided invalid compression")
+	}
+	// the classer is shared by all the pages of the document, its settings are taken from the first classified page.
+	if enc.d.Classer == nil {
+		if enc.d.Classer, err = classer.Init(settings.classerSettings()); err != nil {
+			return errors.Wrap(err, processName, "")
+		}
+	}
+	if err = enc.d.AddClassifiedPage(b, method); err != nil {
+		return errors.Wrap(err, processName, "")
+	}
+	return nil
+}
+
 func (enc *JBIG2Encoder) encodeImage(i image.Image) ([]byte, error) {
 	const processName = "encodeImage"
 	// convert the input into jbig2 image
@@ -446,7 +502,6 @@ func bwToJBIG2Image(i *image.Gray) *JBIG2Image {
 }
 
 // JBIG2EncoderSettings contains the parameters and settings used by the JBIG2Encoder.
-// Current version works only on JB2Generic compression.
 type JBIG2EncoderSettings struct {
 	// FileMode defines if the jbig2 encoder should return full jbig2 file instead of
 	// shortened pdf mode. This adds the file header to the jbig2 definition.
@@ -469,6 +524,10 @@ type JBIG2EncoderSettings struct {
 	// but the more lossy.
 	// Default value: 0.95
 	Threshold float64
+	// EncodeGlobals defines if the symbols shared by multiple pages should be stored in the JBIG2Globals stream
+	// in the PDF mode. It allows to add multiple pages to the encoder, which are encoded using the 'EncodePages' method.
+	// Used only for JB2SymbolCorrelation and JB2SymbolRankHaus methods.
+	EncodeGlobals bool
 }
 
 // Validate validates the page settings for the JBIG2 encoder.
@@ -486,8 +545,30 @@ func (s JBIG2EncoderSettings) Validate() error {
 	if s.DefaultPixelValue != 0 && s.DefaultPixelValue != 1 {
 		return errors.Errorf(processName, "default pixel value: '%d' must be a value for the bit: {0,1}", s.DefaultPixelValue)
 	}
-	if s.Compression != JB2Generic {
-		return errors.Errorf(processName, "provided compression is not implemented yet")
+	switch s.Compression {
+	case JB2Generic, JB2SymbolCorrelation, JB2SymbolRankHaus:
+	default:
+		return errors.Errorf(processName, "provided compression: '%d' is not valid", s.Compression)
 	}
 	return nil
 }
+
+// classerSettings gets the classer settings for the symbol compression.
+// The 'Threshold' is the correlation score threshold or the rank of the Hausdorff match.
+func (s JBIG2EncoderSettings) classerSettings() classer.Settings {
+	threshold := s.Threshold
+	if threshold == 0 {
+		threshold = 0.95
+	}
+	cs := classer.Settings{}
+	switch s.Compression {
+	case JB2SymbolRankHaus:
+		cs.RankHaus = threshold
+		cs.Method = classer.RankHaus
+	default:
+		cs.Thresh = threshold
+		cs.Method = classer.Correlation
+	}
+	cs.SetDefault()
+	return cs
+}
@@ -50,3 +50,79 @@ func TestImageToJBIG2Image(t *testing.T) {
 		assert.Equal(t, jb2.Data, bm.Data)
 	})
 }
+
+// TestJBIG2SymbolEncoding tests the symbol dictionary and text region encoding of the JBIG2Encoder.
+func TestJBIG2SymbolEncoding(t *testing.T) {
+	pages := []*bitmap.Bitmap{bitmap.TstWordBitmap(t, 2), bitmap.TstWordBitmapWithSpaces(t, 2)}
+	compressions := map[string]JBIG2CompressionType{
+		"Correlation": JB2SymbolCorrelation,
+		"RankHaus":    JB2SymbolRankHaus,
+	}
+	toImage := func(bm *bitmap.Bitmap) *JBIG2Image {
+		return &JBIG2Image{Width: bm.Width, Height: bm.Height, Data: bm.Data, HasPadding: true}
+	}
+	// the decoded data is unpadded and stores the black pixels as '0' bits.
+	expectedData := func(bm *bitmap.Bitmap) []byte {
+		inverted := bm.Copy()
+		inverted.InverseData()
+		data, err := inverted.GetUnpaddedData()
+		require.NoError(t, err)
+		return data
+	}
+
+	for name, compression := range compressions {
+		t.Run(name, func(t *testing.T) {
+			t.Run("SinglePage", func(t *testing.T) {
+				enc := NewJBIG2Encoder()
+				enc.DefaultPageSettings.Compression = compression
+				data, err := enc.EncodeJBIG2Image(toImage(pages[0]))
+				require.NoError(t, err)
+
+				// the single page stream doesn't require any globals.
+				assert.Nil(t, enc.GlobalsStream())
+				assert.Nil(t, enc.MakeStreamDict().Get("DecodeParms"))
+
+				decoded, err := NewJBIG2Encoder().DecodeBytes(data)
+				require.NoError(t, err)
+
+				assert.Equal(t, expectedData(pages[0]), decoded)
+			})
+
+			t.Run("SharedGlobals", func(t *testing.T) {
+				enc := NewJBIG2Encoder()
+				settings := &JBIG2EncoderSettings{Compression: compression, EncodeGlobals: true}
+				for _, page := range pages {
+					require.NoError(t, enc.AddPageImage(toImage(page), settings))
+				}
+
+				encoded, err := enc.EncodePages()
+				require.NoError(t, err)
+				require.Len(t, encoded, len(pages))
+
+				// the globals stream should be set as the decode parameter.
+				globals := enc.GlobalsStream()
+				require.NotNil(t, globals)
+				decodeParams, ok := GetDict(enc.MakeStreamDict().Get("DecodeParms"))
+				require.True(t, ok)
+				assert.Equal(t, globals, decodeParams.Get("JBIG2Globals"))
+
+				// decode the pages using the globals from the stream.
+				dec := NewJBIG2Encoder()
+				dec.Globals, err = dec.DecodeGlobals(globals.Stream)
+				require.NoError(t, err)
+				for i, page := range pages {
+					decoded, err := dec.DecodeBytes(encoded[i])
+					require.NoError(t, err)
+
+					assert.Equal(t, expectedData(page), decoded, "page: %d", i+1)
+				}
+			})
+		})
+	}
+
+	t.Run("InvalidCompression", func(t *testing.T) {
+		enc := NewJBIG2Encoder()
+		err := enc.AddPageImage(toImage(pages[0]), &JBIG2EncoderSettings{Compression: JBIG2CompressionType(5)})
+		require.Error(t, err)
+	})
+}
@@ -7,8 +7,11 @@ package core
 
 import (
 	"encoding/base64"
+	"encoding/hex"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/gnaoh1379/unipdf/common"
 )
 
@@ -367,3 +370,39 @@ func TestMultiEncoder(t *testing.T) {
 		return
 	}
 }
+
+// TestJPXDecoding tests decoding of a 4x3 8-bit grayscale JPEG 2000 codestream with
+// a single reversible decomposition level.
+func TestJPXDecoding(t *testing.T) {
+	encoded, err := hex.DecodeString("ff4fff510029000000000004000000030000000000000000000000040000000300" +
+		"000000000000000001070101ff52000c00000001000102020001ff5c00074048505058ff90000a00000000002700" +
+		"01ff93cfc01407a373c76fc1f501c0f9c1407ce0800fc0c60c400c47ffd9")
+	require.NoError(t, err)
+
+	dict := MakeDict()
+	dict.Set("Filter", MakeName(StreamEncodingFilterNameJPX))
+	dict.Set("SMaskInData", MakeInteger(1))
+	stream := &PdfObjectStream{PdfObjectDictionary: dict, Stream: encoded}
+
+	encoder, err := NewEncoderFromStream(stream)
+	require.NoError(t, err)
+	jpxEncoder, ok := encoder.(*JPXEncoder)
+	require.True(t, ok)
+	require.Equal(t, 4, jpxEncoder.Width)
+	require.Equal(t, 3, jpxEncoder.Height)
+	require.Equal(t, 1, jpxEncoder.ColorComponents)
+	require.Equal(t, 8, jpxEncoder.BitsPerComponent)
+	require.Equal(t, 1, jpxEncoder.SMaskInData)
+
+	decoded, err := DecodeStream(stream)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 16, 32, 48, 64, 80, 96, 112, 128, 160, 192, 255}, decoded)
+
+	data, alpha, err := jpxEncoder.DecodeImage(encoded)
+	require.NoError(t, err)
+	require.Equal(t, decoded, data)
+	require.Nil(t, alpha)
+
+	_, err = jpxEncoder.DecodeBytes(encoded[:len(encoded)/2])
+	require.Error(t, err)
+}
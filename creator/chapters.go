@@ -234,20 +234,33 @@ func (chap *Chapter) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		outlineDest.Y = posY
 	}
 
-	for _, d := range chap.contents {
-		newBlocks, c, err := d.GeneratePageBlocks(ctx)
+	// In endnote mode, the footnotes referenced in top-level chapters are
+	// drawn at their end.
+	endnotes := chap.parent == nil && ctx.footnotes != nil && ctx.footnotes.endnotes
+	var collected int
+	if endnotes {
+		collected = len(ctx.footnotes.collected)
+	}
+
+	contents := chap.contents
+	for i := 0; i < len(contents); i++ {
+		newBlocks, c, err := contents[i].GeneratePageBlocks(ctx)
 		if err != nil {
 			return blocks, ctx, err
 		}
-		if len(newBlocks) < 1 {
-			continue
-		}
 
-		// The first block is always appended to the last..
-		blocks[len(blocks)-1].mergeBlocks(newBlocks[0])
-		blocks = append(blocks, newBlocks[1:]...)
+		if len(newBlocks) > 0 {
+			// The first block is always appended to the last..
+			blocks[len(blocks)-1].mergeBlocks(newBlocks[0])
+			blocks = append(blocks, newBlocks[1:]...)
 
-		ctx = c
+			ctx = c
+		}
+
+		if endnotes && i == len(contents)-1 {
+			notes := ctx.footnotes.endnoteComponents(collected, ctx.Width)
+			contents = append(contents[:len(contents):len(contents)], notes...)
+		}
 	}
 
 	if chap.positioning.isRelative() {
@@ -0,0 +1,484 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
+)
+
+// Columns is a container component which lays out its components in columns, e.g. for newspaper
+// or journal style pages. The components flow down the first column, then down the next columns
+// and then onto the columns of the next pages. Components that wrap across pages, such as
+// paragraphs and tables, wrap across columns.
+//
+// Spanning components (see AddSpanning) are drawn across all the columns, below the columns of
+// the components added before them. The columns of the components after them start below them.
+// If balancing is enabled (default), the columns on the last page of the components before a
+// spanning component and at the end of the container are balanced, i.e. they are filled to
+// similar heights rather than one after the other.
+//
+// The footnotes referenced in the columns are drawn at the bottom of the page of their markers,
+// below all the columns of the page.
+type Columns struct {
+	components []columnsComponent
+
+	// The number of columns.
+	count int
+
+	// The space between the columns.
+	gap float64
+
+	// Controls whether the last columns are balanced.
+	balanced bool
+
+	// Margins to be applied around the columns when drawing on Page.
+	margins margins
+}
+
+// columnsComponent is a component of a Columns container.
+type columnsComponent struct {
+	drawable Drawable
+
+	// Spanning components are drawn across all the columns.
+	span bool
+}
+
+// newColumns returns a new Columns container component with `count` columns.
+func newColumns(count int) *Columns {
+	if count < 1 {
+		count = 1
+	}
+
+	return &Columns{
+		count:    count,
+		gap:      10,
+		balanced: true,
+	}
+}
+
+// Count returns the number of columns.
+func (cols *Columns) Count() int {
+	return cols.count
+}
+
+// SetGap sets the space between the columns (10 points default).
+func (cols *Columns) SetGap(gap float64) {
+	cols.gap = gap
+}
+
+// SetBalanced sets whether the columns on the last page of the components before spanning
+// components and at the end of the container are balanced (default true).
+func (cols *Columns) SetBalanced(balanced bool) {
+	cols.balanced = balanced
+}
+
+// SetMargins sets the margins around the columns.
+func (cols *Columns) SetMargins(left, right, top, bottom float64) {
+	cols.margins.left = left
+	cols.margins.right = right
+	cols.margins.top = top
+	cols.margins.bottom = bottom
+}
+
+// GetMargins returns the margins around the columns: left, right, top, bottom.
+func (cols *Columns) GetMargins() (float64, float64, float64, float64) {
+	return cols.margins.left, cols.margins.right, cols.margins.top, cols.margins.bottom
+}
+
+// Add adds a Drawable that flows in the columns to the container, e.g. a Paragraph,
+// StyledParagraph, Table or Image.
+// NOTE: The components may be laid out several times to balance the columns.
+func (cols *Columns) Add(d Drawable) {
+	cols.components = append(cols.components, columnsComponent{drawable: d})
+}
+
+// AddSpanning adds a Drawable that is drawn across all the columns to the container, e.g. a
+// heading.
+func (cols *Columns) AddSpanning(d Drawable) {
+	cols.components = append(cols.components, columnsComponent{drawable: d, span: true})
+}
+
+// GeneratePageBlocks generates the page blocks for the Columns component.
+// Multiple blocks are generated if the contents wrap over multiple pages.
+// Implements the Drawable interface.
+func (cols *Columns) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error) {
+	origCtx := ctx
+
+	// Account for the margins.
+	ctx.X += cols.margins.left
+	ctx.Y += cols.margins.top
+	ctx.Width -= cols.margins.left + cols.margins.right
+	ctx.Height -= cols.margins.top + cols.margins.bottom
+
+	// The footnotes referenced in the columns take room from the columns of their pages, which
+	// are laid out again to end above them.
+	colWidth := (ctx.Width - float64(cols.count-1)*cols.gap) / float64(cols.count)
+	reserved := map[int]float64{}
+	var l *columnsLayout
+	var pos columnsPos
+	for pass := 0; ; pass++ {
+		l = &columnsLayout{cols: cols, ctx: ctx, colWidth: colWidth, reserved: reserved}
+
+		var err error
+		if pos, err = l.layout(); err != nil {
+			return nil, origCtx, err
+		}
+		if !l.placeNotes() || pass == maxColumnsPasses {
+			break
+		}
+	}
+	l.block(pos.page)
+
+	// Move below the columns.
+	ctx = origCtx
+	ctx.Page += pos.page
+	ctx.Y = pos.bottom + cols.margins.bottom
+	ctx.Height = ctx.PageHeight - ctx.Margins.bottom - ctx.Y
+
+	return l.pages, ctx, nil
+}
+
+// maxColumnsPasses is the maximum number of times that the components of a Columns container are
+// laid out again to make room for their footnotes.
+const maxColumnsPasses = 4
+
+// columnsLayout lays out the components of a Columns container on pages.
+type columnsLayout struct {
+	cols *Columns
+
+	// The context that the columns are drawn in.
+	ctx DrawContext
+
+	// The width of the columns.
+	colWidth float64
+
+	// The height reserved for footnotes at the bottom of the pages, by page index.
+	reserved map[int]float64
+
+	// The blocks of the pages that the columns are drawn on.
+	pages []*Block
+
+	// The references to footnotes from the components, with the index of their page.
+	notes []footnoteRef
+}
+
+// columnsPos is a position in the columns of a Columns container.
+type columnsPos struct {
+	// The index of the page, from the page that the columns start on.
+	page int
+
+	// The column.
+	col int
+
+	// The top of the columns on the page.
+	top float64
+
+	// The position in the column.
+	y float64
+
+	// The bottom of the columns on the page, i.e. the largest position in them.
+	bottom float64
+}
+
+// layout lays out the components of the container and returns the position after them.
+func (l *columnsLayout) layout() (columnsPos, error) {
+	var err error
+	pos := columnsPos{top: l.ctx.Y, y: l.ctx.Y, bottom: l.ctx.Y}
+	var segment []Drawable
+	for _, component := range l.cols.components {
+		if !component.span {
+			segment = append(segment, component.drawable)
+			continue
+		}
+
+		if pos, err = l.layoutSegment(segment, pos); err != nil {
+			return pos, err
+		}
+		segment = nil
+
+		if pos, err = l.drawSpanning(component.drawable, pos); err != nil {
+			return pos, err
+		}
+	}
+	return l.layoutSegment(segment, pos)
+}
+
+// trial returns a layout for trying out positions of the components.
+func (l *columnsLayout) trial() *columnsLayout {
+	return &columnsLayout{cols: l.cols, ctx: l.ctx, colWidth: l.colWidth, reserved: l.reserved}
+}
+
+// placeNotes places the footnotes referenced in the columns on their pages. It returns true if the
+// footnotes of a page take more room than is reserved for them, reserving the room for them.
+func (l *columnsLayout) placeNotes() bool {
+	f := l.ctx.footnotes
+	if f == nil || f.endnotes {
+		return false
+	}
+
+	for _, ref := range l.notes {
+		f.unplace(ref.notes)
+	}
+	for _, ref := range l.notes {
+		f.place(ref.notes, l.ctx.Page+ref.page, ref.bottom, l.ctx.PageWidth, l.ctx.PageHeight, true)
+	}
+
+	more := false
+	for page := range l.pages {
+		if area := f.areaHeight(l.ctx.Page + page); area > l.reserved[page]+0.01 {
+			l.reserved[page] = area
+			more = true
+		}
+	}
+	return more
+}
+
+// block returns the block of page `page`.
+func (l *columnsLayout) block(page int) *Block {
+	for len(l.pages) <= page {
+		l.pages = append(l.pages, NewBlock(l.ctx.PageWidth, l.ctx.PageHeight))
+	}
+	return l.pages[page]
+}
+
+// colX returns the left of column `col`.
+func (l *columnsLayout) colX(col int) float64 {
+	return l.ctx.X + float64(col)*(l.colWidth+l.cols.gap)
+}
+
+// pageBottom returns the bottom of the columns of full columns on page `page`, above the room
+// reserved for footnotes.
+func (l *columnsLayout) pageBottom(page int) float64 {
+	return l.ctx.PageHeight - l.ctx.Margins.bottom - l.reserved[page]
+}
+
+// colBottom returns the bottom of the column at position `pos`. The columns of page `limitPage`
+// end `limit` below their top.
+func (l *columnsLayout) colBottom(pos columnsPos, limitPage int, limit float64) float64 {
+	bottom := l.pageBottom(pos.page)
+	if pos.page == limitPage && pos.top+limit < bottom {
+		bottom = pos.top + limit
+	}
+	return bottom
+}
+
+// deferNotes returns the references to footnotes recorded in `f` from page `page`, moved to page
+// index `index` and by `dy` down.
+func deferNotes(f *Footnotes, page, index int, dy float64) []footnoteRef {
+	if f == nil || !f.deferring {
+		return nil
+	}
+
+	var refs []footnoteRef
+	for _, ref := range f.refs {
+		if ref.page == page {
+			refs = append(refs, footnoteRef{notes: ref.notes, page: index, bottom: ref.bottom + dy})
+		}
+	}
+	return refs
+}
+
+// nextColumn returns the top of the column after the column of `pos`, whose content reaches
+// `bottom`.
+func (l *columnsLayout) nextColumn(pos columnsPos, bottom float64) columnsPos {
+	if bottom > pos.bottom {
+		pos.bottom = bottom
+	}
+
+	pos.col++
+	if pos.col == l.cols.count {
+		pos.col = 0
+		pos.page++
+		pos.top = l.ctx.Margins.top
+		pos.bottom = pos.top
+	}
+	pos.y = pos.top
+	return pos
+}
+
+// flow lays out `components` in the columns from position `pos`. The columns of page `limitPage`
+// end `limit` below their top. It returns the position after the components and the positions
+// that each of them starts at.
+//
+// Components are drawn in a context whose page margins are the bounds of their column, so that
+// the blocks of components that wrap across pages are laid out for a column. The blocks after the
+// first are moved to the next columns. As the blocks are laid out for columns with the same top
+// as the column the component starts in, the columns of the next pages may be filled to a shorter
+// height than they have. The footnotes referenced in the blocks are placed after the blocks are
+// moved (see placeNotes).
+func (l *columnsLayout) flow(components []Drawable, pos columnsPos, limitPage int,
+	limit float64) (columnsPos, []columnsPos, error) {
+	var starts []columnsPos
+	for _, d := range components {
+		starts = append(starts, pos)
+
+		x := l.colX(pos.col)
+		bottom := l.colBottom(pos, limitPage, limit)
+
+		ctx := l.ctx
+		ctx.Page += pos.page
+		ctx.X = x
+		ctx.Y = pos.y
+		ctx.Width = l.colWidth
+		ctx.Height = bottom - pos.y
+		ctx.Margins = margins{
+			left:   x,
+			right:  ctx.PageWidth - x - l.colWidth,
+			top:    pos.top,
+			bottom: ctx.PageHeight - bottom,
+		}
+		ctx.Inline = false
+
+		// The pages of the blocks end at the bottom of the columns they are moved to.
+		start := pos
+		firstPage := ctx.Page
+		ctx.footnotes = l.ctx.footnotes.deferred(func(page int) float64 {
+			col := start
+			for i := firstPage; i < page; i++ {
+				col = l.nextColumn(col, 0)
+			}
+			return l.colBottom(col, limitPage, limit) - (col.top - start.top)
+		})
+
+		blocks, updCtx, err := d.GeneratePageBlocks(ctx)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to generate column blocks: %v", err)
+			return pos, nil, err
+		}
+
+		cur := pos
+		var dy float64
+		for i, blk := range blocks {
+			if i > 0 {
+				cur = l.nextColumn(cur, l.colBottom(cur, limitPage, limit))
+				dx := l.colX(cur.col) - x
+				dy = cur.top - pos.top
+				translateBlock(blk, dx, dy)
+			}
+			if err := l.block(cur.page).mergeBlocks(blk); err != nil {
+				return pos, nil, err
+			}
+			l.notes = append(l.notes, deferNotes(ctx.footnotes, firstPage+i, cur.page, dy)...)
+		}
+
+		cur.y = updCtx.Y + dy
+		if cur.y > cur.bottom {
+			cur.bottom = cur.y
+		}
+		pos = cur
+	}
+
+	return pos, starts, nil
+}
+
+// layoutSegment lays out `components`, which are drawn in the columns between spanning
+// components, from position `pos`, and returns the position after them. If the columns are
+// balanced, the height of the columns on the last page of the components is the smallest height
+// that the components that start on that page fit in.
+func (l *columnsLayout) layoutSegment(components []Drawable, pos columnsPos) (columnsPos, error) {
+	if !l.cols.balanced || len(components) == 0 {
+		end, _, err := l.flow(components, pos, -1, 0)
+		return end, err
+	}
+
+	// Find the components that start on the last page. Only the columns that they are drawn in
+	// are balanced.
+	end, starts, err := l.trial().flow(components, pos, -1, 0)
+	if err != nil {
+		return pos, err
+	}
+	lastPage := end.page
+	k := 0
+	for k < len(starts) && starts[k].page < lastPage {
+		k++
+	}
+	if k == len(starts) {
+		end, _, err := l.flow(components, pos, -1, 0)
+		return end, err
+	}
+
+	// Find the smallest height of the columns that the components fit in on the last page.
+	fits := func(limit float64) (bool, error) {
+		end, _, err := l.trial().flow(components[k:], starts[k], lastPage, limit)
+		return end.page == lastPage, err
+	}
+	lo, hi := 0.0, l.pageBottom(lastPage)-starts[k].top
+	for hi-lo > 0.5 {
+		mid := (lo + hi) / 2
+		ok, err := fits(mid)
+		if err != nil {
+			return pos, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	if pos, _, err = l.flow(components[:k], pos, -1, 0); err != nil {
+		return pos, err
+	}
+	end, _, err = l.flow(components[k:], pos, lastPage, hi)
+	return end, err
+}
+
+// drawSpanning draws the spanning component `d` across the columns below position `pos` and
+// returns the position of the columns after it.
+func (l *columnsLayout) drawSpanning(d Drawable, pos columnsPos) (columnsPos, error) {
+	ctx := l.ctx
+	ctx.Page += pos.page
+	ctx.Y = pos.bottom
+	ctx.Height = l.pageBottom(pos.page) - pos.bottom
+	ctx.Margins.left = ctx.X
+	ctx.Margins.right = ctx.PageWidth - ctx.X - ctx.Width
+	ctx.Inline = false
+
+	firstPage := ctx.Page
+	ctx.footnotes = l.ctx.footnotes.deferred(func(page int) float64 {
+		return l.pageBottom(pos.page + page - firstPage)
+	})
+
+	blocks, updCtx, err := d.GeneratePageBlocks(ctx)
+	if err != nil {
+		common.Log.Debug("ERROR: unable to generate spanning blocks: %v", err)
+		return pos, err
+	}
+
+	page := pos.page
+	for i, blk := range blocks {
+		page = pos.page + i
+		if err := l.block(page).mergeBlocks(blk); err != nil {
+			return pos, err
+		}
+		l.notes = append(l.notes, deferNotes(ctx.footnotes, firstPage+i, page, 0)...)
+	}
+
+	return columnsPos{page: page, top: updCtx.Y, y: updCtx.Y, bottom: updCtx.Y}, nil
+}
+
+// translateBlock moves the contents and the annotations of `blk` by `dx` to the right and by
+// `dy` down.
+func translateBlock(blk *Block, dx, dy float64) {
+	blk.translate(dx, dy)
+
+	for _, annotation := range blk.annotations {
+		rect, ok := core.GetArray(annotation.Rect)
+		if !ok {
+			continue
+		}
+		coords, err := rect.ToFloat64Array()
+		if err != nil || len(coords) != 4 {
+			continue
+		}
+
+		rect.Set(0, core.MakeFloat(coords[0]+dx))
+		rect.Set(1, core.MakeFloat(coords[1]-dy))
+		rect.Set(2, core.MakeFloat(coords[2]+dx))
+		rect.Set(3, core.MakeFloat(coords[3]-dy))
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/extractor"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// columnsTestWord is a word extracted from a page drawn with columns.
+type columnsTestWord struct {
+	text string
+	x, y float64
+}
+
+// columnsTestWordRegexp matches the words drawn in the columns tests.
+var columnsTestWordRegexp = regexp.MustCompile(`^(Heading|[PL]\d\d)$`)
+
+// extractColumnsTestWords returns the words of the pages of the PDF in `data`.
+func extractColumnsTestWords(t *testing.T, data []byte) [][]columnsTestWord {
+	r, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	numPages, err := r.GetNumPages()
+	require.NoError(t, err)
+
+	var pages [][]columnsTestWord
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		require.NoError(t, err)
+		e, err := extractor.New(page)
+		require.NoError(t, err)
+		pageText, _, _, err := e.ExtractPageText()
+		require.NoError(t, err)
+
+		var words []columnsTestWord
+		var word *columnsTestWord
+		for _, mark := range pageText.Marks().Elements() {
+			if mark.Meta {
+				word = nil
+				continue
+			}
+			if word == nil {
+				words = append(words, columnsTestWord{x: mark.BBox.Llx, y: mark.BBox.Lly})
+				word = &words[len(words)-1]
+			}
+			word.text += mark.Text
+		}
+
+		// Leave out the words of the watermark of unlicensed copies.
+		var filtered []columnsTestWord
+		for _, w := range words {
+			if columnsTestWordRegexp.MatchString(w.text) {
+				filtered = append(filtered, w)
+			}
+		}
+		pages = append(pages, filtered)
+	}
+	return pages
+}
+
+func TestColumns(t *testing.T) {
+	c := New()
+	c.SetPageMargins(50, 50, 50, 50)
+
+	cols := c.NewColumns(2)
+	cols.SetGap(20)
+	require.Equal(t, 2, cols.Count())
+
+	heading := c.NewParagraph("Heading")
+	heading.SetFontSize(20)
+	cols.AddSpanning(heading)
+	for i := 1; i <= 50; i++ {
+		p := c.NewParagraph(fmt.Sprintf("P%02d", i))
+		p.SetFontSize(20)
+		p.SetMargins(0, 0, 0, 20)
+		cols.Add(p)
+	}
+	require.NoError(t, c.Draw(cols))
+
+	// The context is below the columns.
+	ctx := c.Context()
+	require.Equal(t, 2, ctx.Page)
+	require.Equal(t, 50.0, ctx.X)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := extractColumnsTestWords(t, buf.Bytes())
+	require.Len(t, pages, 2)
+
+	// The column width is (595.28 - 100 - 20) / 2.
+	colX := []float64{50, 50 + (c.Width()-100-20)/2 + 20}
+	column := func(w columnsTestWord) int {
+		if w.x >= colX[1]-1 {
+			return 1
+		}
+		require.InDelta(t, colX[0], w.x, 1, w.text)
+		return 0
+	}
+
+	// The paragraphs flow down the columns below the heading and onto the next page.
+	var headingY float64
+	var seen []string
+	for n, words := range pages {
+		var colWords [2][]columnsTestWord
+		for _, w := range words {
+			if w.text == "Heading" {
+				require.Equal(t, 0, n)
+				headingY = w.y
+				continue
+			}
+			k := column(w)
+			colWords[k] = append(colWords[k], w)
+		}
+
+		for k := range colWords {
+			for i, w := range colWords[k] {
+				if n == 0 {
+					require.Less(t, w.y, headingY, w.text)
+				}
+				if i > 0 {
+					require.Less(t, w.y, colWords[k][i-1].y, w.text)
+				}
+				seen = append(seen, w.text)
+			}
+		}
+
+		// The columns of the last page are balanced.
+		if n == len(pages)-1 {
+			require.InDelta(t, len(colWords[0]), len(colWords[1]), 1)
+		}
+	}
+	require.Len(t, seen, 50)
+	for i, text := range seen {
+		require.Equal(t, fmt.Sprintf("P%02d", i+1), text)
+	}
+}
+
+func TestColumnsWrapping(t *testing.T) {
+	c := New()
+	c.SetPageMargins(50, 50, 50, 50)
+
+	// A styled paragraph that wraps from the first column to the second one.
+	cols := c.NewColumns(2)
+	cols.SetBalanced(false)
+	p := c.NewStyledParagraph()
+	for i := 1; i <= 40; i++ {
+		chunk := p.Append(fmt.Sprintf("L%02d\n", i))
+		chunk.Style.FontSize = 20
+	}
+	cols.Add(p)
+	require.NoError(t, c.Draw(cols))
+	require.Equal(t, 1, c.Context().Page)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := extractColumnsTestWords(t, buf.Bytes())
+	require.Len(t, pages, 1)
+
+	// The lines that don't fit in the first column are at the top of the second one.
+	var first, second []columnsTestWord
+	for _, w := range pages[0] {
+		if w.x < c.Width()/2 {
+			first = append(first, w)
+		} else {
+			second = append(second, w)
+		}
+	}
+	require.Len(t, first, 34)
+	require.Len(t, second, 6)
+	require.Equal(t, "L35", second[0].text)
+	require.InDelta(t, first[0].y, second[0].y, 0.01)
+}
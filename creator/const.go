@@ -41,6 +41,18 @@ const (
 	TextAlignmentJustify
 )
 
+// LineBreaking options for wrapping paragraph text into lines.
+type LineBreaking int
+
+// The options supported for line breaking are:
+// greedy - LineBreakingGreedy: fill each line with as much text as fits.
+// optimal - LineBreakingOptimal: choose the breaks of all the lines of a paragraph together, as in
+// the Knuth-Plass algorithm of TeX, so that the spacing of the lines is as even as possible.
+const (
+	LineBreakingGreedy LineBreaking = iota
+	LineBreakingOptimal
+)
+
 // TextRenderingMode determines whether showing text shall cause glyph
 // outlines to be stroked, filled, used as a clipping boundary, or some
 // combination of the three.
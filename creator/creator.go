@@ -51,6 +51,9 @@ type Creator struct {
 	// The table of contents.
 	toc *TOC
 
+	// The footnotes.
+	footnotes *Footnotes
+
 	// Controls whether outlines will be generated.
 	AddOutlines bool
 
@@ -159,6 +162,9 @@ func New() *Creator {
 	// Initialize creator table of contents.
 	c.toc = c.NewTOC("Table of Contents")
 
+	// Initialize footnotes.
+	c.footnotes = newFootnotes(c.NewTextStyle(), &c.pageMargins)
+
 	// Initialize outline.
 	c.AddOutlines = true
 	c.outline = model.NewOutline()
@@ -293,6 +299,12 @@ func (c *Creator) newPage() *model.PdfPage {
 	return page
 }
 
+// Footnotes returns the footnotes of the creator, whose settings control how
+// the footnotes referenced in styled paragraphs are numbered and drawn.
+func (c *Creator) Footnotes() *Footnotes {
+	return c.footnotes
+}
+
 // Initialize the drawing context, moving to upper left corner.
 func (c *Creator) initContext() {
 	// Update context, move to upper left corner.
@@ -303,6 +315,7 @@ func (c *Creator) initContext() {
 	c.context.PageHeight = c.pageHeight
 	c.context.PageWidth = c.pageWidth
 	c.context.Margins = c.pageMargins
+	c.context.footnotes = c.footnotes
 }
 
 // NewPage adds a new Page to the Creator and sets as the active Page.
@@ -380,6 +393,12 @@ func (c *Creator) Finalize() error {
 		return nil
 	}
 
+	// Draw the footnotes, adding pages for the footnotes carried past the
+	// last page.
+	if err := c.drawFootnotes(); err != nil {
+		return err
+	}
+
 	totPages := len(c.pages)
 
 	// Estimate number of additional generated pages and update TOC.
@@ -617,6 +636,9 @@ func (c *Creator) Draw(d Drawable) error {
 		c.NewPage()
 	}
 
+	// End the available height above the footnotes of the page.
+	c.context.Height = c.footnotes.fitHeight(c.context, c.context.Height)
+
 	blocks, ctx, err := d.GeneratePageBlocks(c.context)
 	if err != nil {
 		return err
@@ -644,6 +666,51 @@ func (c *Creator) Draw(d Drawable) error {
 	c.context.X = ctx.X
 	c.context.Y = ctx.Y
 	c.context.Height = ctx.PageHeight - ctx.Y - ctx.Margins.bottom
+	c.context.Height = c.footnotes.fitHeight(c.context, c.context.Height)
+
+	return nil
+}
+
+// drawFootnotes draws the footnotes collected as endnotes at the end of the
+// document and the footnotes of the pages at their bottom.
+func (c *Creator) drawFootnotes() error {
+	if c.footnotes.endnotes {
+		for _, d := range c.footnotes.endnoteComponents(0, c.context.Width) {
+			if err := c.Draw(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	last := c.footnotes.lastPage()
+	for len(c.pages) < last {
+		c.NewPage()
+	}
+
+	for idx := 0; idx < last; idx++ {
+		page := c.pages[idx]
+		mbox, err := page.GetMediaBox()
+		if err != nil {
+			return err
+		}
+
+		width, height := mbox.Urx-mbox.Llx, mbox.Ury-mbox.Lly
+		blk, err := c.footnotes.pageBlock(idx+1, width, height)
+		if err != nil {
+			common.Log.Debug("ERROR: drawing page %d footnotes: %v", idx+1, err)
+			return err
+		}
+		if blk == nil {
+			continue
+		}
+
+		c.setActivePage(page)
+		blk.SetPos(0, 0)
+		if err := c.Draw(blk); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -797,6 +864,27 @@ func (c *Creator) NewDivision() *Division {
 	return newDivision()
 }
 
+// NewColumns returns a new Columns container component with `count` columns.
+func (c *Creator) NewColumns(count int) *Columns {
+	return newColumns(count)
+}
+
+// NewFootnote creates a new footnote with the specified text, numbered after
+// the footnotes created before it. Its marker is added to styled paragraphs
+// with StyledParagraph.AddFootnote.
+func (c *Creator) NewFootnote(text string) *Footnote {
+	c.footnotes.count++
+
+	content := newStyledParagraph(c.footnotes.style)
+	content.Append(text)
+
+	return &Footnote{
+		number:  c.footnotes.count,
+		content: content,
+		notes:   c.footnotes,
+	}
+}
+
 // NewTOC creates a new table of contents.
 func (c *Creator) NewTOC(title string) *TOC {
 	headingStyle := c.NewTextStyle()
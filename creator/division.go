@@ -165,6 +165,11 @@ func (div *Division) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 				divCtx.Y = ctx.Margins.top
 				divCtx.Height = ctx.PageHeight - ctx.Margins.top
 
+				// End above the footnotes carried to the new page.
+				pageCtx := updCtx
+				pageCtx.Y = divCtx.Y
+				divCtx.Height = ctx.footnotes.fitHeight(pageCtx, divCtx.Height)
+
 				tmpCtx.Y = divCtx.Y
 				tmpCtx.Height = divCtx.Height
 				lineHeight = updCtx.Height - divCtx.Height
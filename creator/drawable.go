@@ -46,4 +46,8 @@ type DrawContext struct {
 
 	// Controls whether the components are stacked horizontally
 	Inline bool
+
+	// The footnotes of the creator. Styled paragraphs place the footnotes
+	// that they reference at the bottom of the pages and end above them.
+	footnotes *Footnotes
 }
@@ -0,0 +1,138 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"unicode"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// Text whose style has fallback fonts is split into runs of runes that are drawn with the same
+// font. Each rune is drawn with the first of the style's font and fallback fonts that has a glyph
+// for it.
+
+// fontHasRune returns true if `font` has a glyph for `r`.
+// NOTE: GetRuneMetrics can't be used for this as it returns the font's missing width for runes
+// the font doesn't have.
+func fontHasRune(font *model.PdfFont, r rune) bool {
+	enc := font.Encoder()
+	if enc == nil {
+		return false
+	}
+	_, ok := enc.RuneToCharcode(r)
+	return ok
+}
+
+// fontRun is a run of runes[start:end] that is drawn with `font`.
+type fontRun struct {
+	font       *model.PdfFont
+	start, end int
+}
+
+// fontRuns splits `runes` into runs of runes that are drawn with the same font of `style`.
+// Line feeds and soft hyphens, which are not drawn, stay in the font of the runes before them.
+// Spaces, combining marks and joiners stay in it if it has glyphs for them, so that words and
+// clusters are not split between fonts. Runes that none of
+// the fonts have glyphs for are drawn with style.Font.
+func fontRuns(style *TextStyle, runes []rune) []fontRun {
+	if len(style.FallbackFonts) == 0 {
+		return []fontRun{{font: style.Font, start: 0, end: len(runes)}}
+	}
+
+	var runs []fontRun
+	for i, r := range runes {
+		if n := len(runs); n > 0 {
+			run := &runs[n-1]
+			if r == '\u000A' || r == softHyphen || (keepsFont(r) && fontHasRune(run.font, r)) {
+				run.end = i + 1
+				continue
+			}
+		}
+
+		font := style.Font
+		if !fontHasRune(font, r) {
+			for _, fallback := range style.FallbackFonts {
+				if fontHasRune(fallback, r) {
+					font = fallback
+					break
+				}
+			}
+		}
+		if n := len(runs); n > 0 && runs[n-1].font == font {
+			runs[n-1].end = i + 1
+			continue
+		}
+		runs = append(runs, fontRun{font: font, start: i, end: i + 1})
+	}
+	return runs
+}
+
+// keepsFont returns true if `r` is drawn in the font of the rune before it when the font has a
+// glyph for it.
+func keepsFont(r rune) bool {
+	switch r {
+	case ' ', 0x200c, 0x200d: // Space, zero width non-joiner and zero width joiner.
+		return true
+	}
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Variation_Selector)
+}
+
+// splitByFont returns `chunk` split into chunks whose text is drawn with a single font. The chunks
+// have the style of `chunk` with the font that their text is drawn with and no fallback fonts. If
+// `chunk` has an annotation, the chunks have copies of it. `chunk` is returned if its style has no
+// fallback fonts.
+func splitByFont(chunk *TextChunk) []*TextChunk {
+	if len(chunk.Style.FallbackFonts) == 0 {
+		return []*TextChunk{chunk}
+	}
+
+	runes := []rune(chunk.Text)
+	var chunks []*TextChunk
+	for i, run := range fontRuns(&chunk.Style, runes) {
+		piece := *chunk
+		piece.Text = string(runes[run.start:run.end])
+		piece.Style.Font = run.font
+		piece.Style.FallbackFonts = nil
+		if i > 0 {
+			piece.annotation = copyAnnotation(chunk.annotation)
+		}
+		chunks = append(chunks, &piece)
+	}
+	if len(chunks) == 0 {
+		piece := *chunk
+		piece.Style.FallbackFonts = nil
+		chunks = append(chunks, &piece)
+	}
+	return chunks
+}
+
+// runeWidths returns the widths in glyph space units of `runes` drawn with the fonts of `style`.
+// The runes drawn with fonts that can shape text are measured as they are shaped, so the width of a
+// cluster of runes drawn with a single glyph is the width of its first rune (see shapedRuneWidths).
+// Line feeds and soft hyphens have zero width. If a font has no metrics for a rune, the index of the rune is
+// returned as well as nil widths, otherwise -1 is returned.
+func runeWidths(style *TextStyle, runes []rune) ([]float64, int) {
+	widths := make([]float64, len(runes))
+	for _, run := range fontRuns(style, runes) {
+		text := runes[run.start:run.end]
+		if shaped := shapedRuneWidths(run.font, text); shaped != nil {
+			copy(widths[run.start:], shaped)
+			continue
+		}
+		for i, r := range text {
+			if r == '\u000A' || r == softHyphen { // LF or SHY
+				continue
+			}
+			metrics, found := run.font.GetRuneMetrics(r)
+			if !found {
+				return nil, run.start + i
+			}
+			widths[run.start+i] = metrics.Wx
+		}
+	}
+	return widths, -1
+}
@@ -0,0 +1,564 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gnaoh1379/unipdf/common"
+)
+
+// FootnoteNumbering represents the numbering style of the markers and labels of footnotes.
+type FootnoteNumbering int
+
+// Footnote numbering styles.
+const (
+	// FootnoteNumberingDecimal numbers footnotes 1, 2, 3, ...
+	FootnoteNumberingDecimal FootnoteNumbering = iota
+
+	// FootnoteNumberingLowerRoman numbers footnotes i, ii, iii, ...
+	FootnoteNumberingLowerRoman
+
+	// FootnoteNumberingLowerLetter numbers footnotes a, b, ..., z, aa, bb, ...
+	FootnoteNumberingLowerLetter
+
+	// FootnoteNumberingSymbols numbers footnotes *, †, ‡, §, ¶, **, ††, ...
+	FootnoteNumberingSymbols
+)
+
+// footnoteSymbols are the labels of the first footnotes numbered with FootnoteNumberingSymbols.
+var footnoteSymbols = []string{"*", "†", "‡", "§", "¶"}
+
+// maxCarriedFootnotes is the largest fraction of the height between the top and bottom margins of
+// a page that the footnote lines carried to the page take, leaving the rest for the body.
+const maxCarriedFootnotes = 0.5
+
+// Footnotes holds the settings and the layout of the footnotes of a creator (see
+// Creator.Footnotes). Footnotes are referenced by markers in styled paragraphs (see
+// StyledParagraph.AddFootnote) and their text is drawn at the bottom of the page that the line
+// with the marker is drawn on, below a separator rule. The body area of the page shrinks to make
+// room for them. The lines of a footnote that don't fit on the page are carried to the bottom of
+// the next pages, where the carried lines take at most half of the height between the top and
+// bottom margins.
+//
+// In endnote mode (see SetEndnotes), the footnotes are collected instead and drawn at the end of
+// the top-level chapter that references them, or at the end of the document if they are not
+// referenced in a chapter.
+//
+// The components that wrap onto the next page end above the footnotes carried to it.
+type Footnotes struct {
+	// The numbering style of the markers and labels.
+	numbering FootnoteNumbering
+
+	// Controls whether the footnotes are drawn as endnotes.
+	endnotes bool
+
+	// The style of the text of new footnotes.
+	style TextStyle
+
+	// Separator rule properties. No rule is drawn if its length is 0.
+	sepLength     float64
+	sepWidth      float64
+	sepColor      Color
+	sepSpaceAbove float64
+	sepSpaceBelow float64
+
+	// The page margins of the creator. The footnotes span the width between the left and right
+	// margins and end at the bottom margin.
+	pageMargins *margins
+
+	// The number of footnotes created.
+	count int
+
+	// The footnotes placed on pages, in the order they were placed.
+	placed []*Footnote
+
+	// The footnotes collected to be drawn as endnotes.
+	collected []*Footnote
+
+	// Deferred footnotes record the references to footnotes instead of placing them, for
+	// components that lay out their contents on pages before moving them, such as Columns.
+	// bottom returns the bottom of the body area of the pages of the contents.
+	deferring bool
+	refs      []footnoteRef
+	bottom    func(page int) float64
+}
+
+// footnoteRef is a reference to footnotes from a line of text whose bottom is at `bottom` on page
+// `page`.
+type footnoteRef struct {
+	notes  []*Footnote
+	page   int
+	bottom float64
+}
+
+// Footnote represents a note that is referenced by a marker in a styled paragraph.
+// Footnotes are created with Creator.NewFootnote and are numbered in the order they are created.
+type Footnote struct {
+	// The number of the footnote.
+	number int
+
+	// The text of the footnote.
+	content *StyledParagraph
+
+	// The footnotes of the creator the footnote belongs to.
+	notes *Footnotes
+
+	// The page that the footnote is placed on (0 if not placed), the heights of its lines and
+	// the ends of the lines drawn on each page: the lines drawn on page `page`+i end before line
+	// splits[i]. The lines that don't fit on a page are carried to the next one.
+	page    int
+	splits  []int
+	heights []float64
+}
+
+// pageLines returns the first line and the end of the lines of the footnote drawn on page `page`.
+func (note *Footnote) pageLines(page int) (int, int) {
+	i := page - note.page
+	if note.page == 0 || i < 0 || i >= len(note.splits) {
+		return 0, 0
+	}
+	if i == 0 {
+		return 0, note.splits[0]
+	}
+	return note.splits[i-1], note.splits[i]
+}
+
+// newFootnotes returns the footnote settings of a creator with page margins `pageMargins`. The
+// text of the footnotes has style `style` with a font size of 8.
+func newFootnotes(style TextStyle, pageMargins *margins) *Footnotes {
+	style.FontSize = 8
+
+	return &Footnotes{
+		style:         style,
+		sepLength:     144,
+		sepWidth:      0.5,
+		sepColor:      ColorBlack,
+		sepSpaceAbove: 6,
+		sepSpaceBelow: 4,
+		pageMargins:   pageMargins,
+	}
+}
+
+// SetNumbering sets the numbering style of the markers and labels of the footnotes
+// (FootnoteNumberingDecimal default).
+func (f *Footnotes) SetNumbering(numbering FootnoteNumbering) {
+	f.numbering = numbering
+}
+
+// SetEndnotes sets whether the footnotes are drawn as endnotes, at the end of the top-level
+// chapters that reference them, rather than at the bottom of the pages (default false).
+func (f *Footnotes) SetEndnotes(endnotes bool) {
+	f.endnotes = endnotes
+}
+
+// SetTextStyle sets the style of the text of the footnotes created after the call.
+// The default style uses the regular font of the creator with a font size of 8.
+func (f *Footnotes) SetTextStyle(style TextStyle) {
+	f.style = style
+}
+
+// SetSeparator sets the length, the line width and the color of the rule drawn above the
+// footnotes. No rule is drawn if `length` is 0. The rule is 144 points long and 0.5 points
+// wide by default.
+func (f *Footnotes) SetSeparator(length, lineWidth float64, color Color) {
+	f.sepLength = length
+	f.sepWidth = lineWidth
+	f.sepColor = color
+}
+
+// SetSeparatorSpacing sets the space above and below the separator rule (6 and 4 points
+// default).
+func (f *Footnotes) SetSeparatorSpacing(above, below float64) {
+	f.sepSpaceAbove = above
+	f.sepSpaceBelow = below
+}
+
+// Number returns the number of the footnote.
+func (note *Footnote) Number() int {
+	return note.number
+}
+
+// Label returns the label of the footnote in the numbering style of the footnotes, e.g. "3" or
+// "iii".
+func (note *Footnote) Label() string {
+	n := note.number
+	switch note.notes.numbering {
+	case FootnoteNumberingLowerRoman:
+		return lowerRoman(n)
+	case FootnoteNumberingLowerLetter:
+		return strings.Repeat(string(rune('a'+(n-1)%26)), (n-1)/26+1)
+	case FootnoteNumberingSymbols:
+		count := len(footnoteSymbols)
+		return strings.Repeat(footnoteSymbols[(n-1)%count], (n-1)/count+1)
+	}
+	return strconv.Itoa(n)
+}
+
+// Content returns the styled paragraph with the text of the footnote. Chunks can be appended to
+// it to add text with other styles. The label of the footnote is drawn before it.
+func (note *Footnote) Content() *StyledParagraph {
+	return note.content
+}
+
+// lowerRoman returns `n` in lowercase roman numerals.
+func lowerRoman(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	numerals := []string{"m", "cm", "d", "cd", "c", "xc", "l", "xl", "x", "ix", "v", "iv", "i"}
+
+	var sb strings.Builder
+	for i, v := range values {
+		for n >= v {
+			sb.WriteString(numerals[i])
+			n -= v
+		}
+	}
+	return sb.String()
+}
+
+// footnoteMarkerStyle returns the style of the markers of footnotes in text of `style`: a
+// smaller font raised above the baseline.
+func footnoteMarkerStyle(style TextStyle) TextStyle {
+	style.TextRise = 0.35 * style.FontSize
+	style.FontSize *= 0.65
+	return style
+}
+
+// paragraph returns a styled paragraph with the label and the text of the footnote.
+func (note *Footnote) paragraph() *StyledParagraph {
+	content := note.content
+
+	p := newStyledParagraph(content.defaultStyle)
+	p.alignment = content.alignment
+	p.lineHeight = content.lineHeight
+	p.lineBreaking = content.lineBreaking
+	p.chunks = append([]*TextChunk{
+		NewTextChunk(note.Label(), footnoteMarkerStyle(content.defaultStyle)),
+		NewTextChunk(" ", content.defaultStyle),
+	}, content.chunks...)
+
+	return p
+}
+
+// areaWidth returns the width of the footnotes on pages of width `pageWidth`.
+func (f *Footnotes) areaWidth(pageWidth float64) float64 {
+	return pageWidth - f.pageMargins.left - f.pageMargins.right
+}
+
+// separatorHeight returns the height of the separator above the footnotes of a page, including
+// the space around the rule.
+func (f *Footnotes) separatorHeight() float64 {
+	height := f.sepSpaceAbove + f.sepSpaceBelow
+	if f.sepLength > 0 {
+		height += f.sepWidth
+	}
+	return height
+}
+
+// areaHeight returns the height of the footnotes drawn on page `page`, including the separator:
+// the lines carried from the previous pages and the lines of the footnotes placed on the page.
+func (f *Footnotes) areaHeight(page int) float64 {
+	var height float64
+	for _, note := range f.placed {
+		from, to := note.pageLines(page)
+		height += sumHeights(note.heights[from:to])
+	}
+
+	if height > 0 {
+		height += f.separatorHeight()
+	}
+	return height
+}
+
+// fitHeight returns the available height `height` of context `ctx` reduced to end above the
+// footnotes of its page. It returns `height` if `f` is nil or in endnote mode.
+func (f *Footnotes) fitHeight(ctx DrawContext, height float64) float64 {
+	if f == nil || f.endnotes {
+		return height
+	}
+	if f.deferring {
+		if h := f.bottom(ctx.Page) - ctx.Y; h < height {
+			return h
+		}
+		return height
+	}
+
+	area := f.areaHeight(ctx.Page)
+	if area == 0 {
+		return height
+	}
+	if h := ctx.PageHeight - f.pageMargins.bottom - area - ctx.Y; h < height {
+		if h < 0 {
+			return 0
+		}
+		return h
+	}
+	return height
+}
+
+// place places `notes`, which are referenced in a line of text whose bottom is at `bottom`, on
+// page `page` of width `pageWidth` and height `pageHeight`. The lines of the footnotes that don't
+// fit below the line are carried to the next pages (see carryRoom). It returns false, leaving the
+// footnotes unplaced, if the first line of the first footnote doesn't fit, unless `force` is
+// true.
+// Footnotes that are placed again, e.g. because their paragraph is laid out again, are moved.
+func (f *Footnotes) place(notes []*Footnote, page int, bottom, pageWidth, pageHeight float64,
+	force bool) bool {
+	if f.deferring {
+		f.refs = append(f.refs, footnoteRef{notes: notes, page: page, bottom: bottom})
+		return true
+	}
+
+	type placement struct {
+		page    int
+		splits  []int
+		heights []float64
+	}
+	saved := make([]placement, len(notes))
+	for i, note := range notes {
+		saved[i] = placement{note.page, note.splits, note.heights}
+		note.page = 0
+	}
+
+	area := f.areaHeight(page)
+	if area == 0 {
+		area = f.separatorHeight()
+	}
+	room := pageHeight - f.pageMargins.bottom - bottom - area
+
+	width := f.areaWidth(pageWidth)
+	for i, note := range notes {
+		p := note.paragraph()
+		p.SetWidth(width)
+
+		var heights []float64
+		for k := range p.lines {
+			_, h := p.getLineHeight(k)
+			heights = append(heights, h)
+		}
+
+		split := 0
+		for split < len(heights) && heights[split] <= room {
+			room -= heights[split]
+			split++
+		}
+		if i == 0 && split == 0 && !force {
+			for j, note := range notes {
+				note.page, note.splits, note.heights = saved[j].page, saved[j].splits, saved[j].heights
+			}
+			return false
+		}
+		if split < len(heights) {
+			// The next footnotes are carried to the next page.
+			room = 0
+		}
+
+		if saved[i].page == 0 {
+			f.placed = append(f.placed, note)
+		}
+		note.page, note.splits, note.heights = page, []int{split}, heights
+
+		// The lines that don't fit are carried page by page. A page takes at least one line
+		// unless it has other footnotes, so that the carrying ends.
+		for end := split; end < len(heights); {
+			next := page + len(note.splits)
+			carryRoom := f.carryRoom(next, pageHeight)
+			empty := f.areaHeight(next) == 0
+			for end < len(heights) && (heights[end] <= carryRoom || empty) {
+				carryRoom -= heights[end]
+				end++
+				empty = false
+			}
+			note.splits = append(note.splits, end)
+		}
+	}
+
+	return true
+}
+
+// carryRoom returns the height left for the footnote lines carried to page `page` of height
+// `pageHeight`.
+func (f *Footnotes) carryRoom(page int, pageHeight float64) float64 {
+	area := f.areaHeight(page)
+	if area == 0 {
+		area = f.separatorHeight()
+	}
+	return maxCarriedFootnotes*(pageHeight-f.pageMargins.top-f.pageMargins.bottom) - area
+}
+
+// deferred returns footnotes with the settings of `f` that record the references to footnotes
+// instead of placing them, and whose pages end at the bottoms returned by `bottom`. It returns `f`
+// if `f` is nil or in endnote mode.
+func (f *Footnotes) deferred(bottom func(page int) float64) *Footnotes {
+	if f == nil || f.endnotes {
+		return f
+	}
+
+	d := *f
+	d.placed = nil
+	d.collected = nil
+	d.deferring = true
+	d.refs = nil
+	d.bottom = bottom
+	return &d
+}
+
+// unplace removes `notes` from the footnotes placed on pages.
+func (f *Footnotes) unplace(notes []*Footnote) {
+	var placed []*Footnote
+	for _, note := range f.placed {
+		removed := false
+		for _, n := range notes {
+			if n == note {
+				removed = true
+				break
+			}
+		}
+		if removed {
+			note.page = 0
+			continue
+		}
+		placed = append(placed, note)
+	}
+	f.placed = placed
+}
+
+// collect collects `notes` to be drawn as endnotes.
+func (f *Footnotes) collect(notes []*Footnote) {
+	for _, note := range notes {
+		collected := false
+		for _, c := range f.collected {
+			if c == note {
+				collected = true
+				break
+			}
+		}
+		if !collected {
+			f.collected = append(f.collected, note)
+		}
+	}
+}
+
+// endnoteComponents returns the components that draw the footnotes collected as endnotes from index
+// `from` on, and removes them from the collected footnotes.
+func (f *Footnotes) endnoteComponents(from int, width float64) []Drawable {
+	if from >= len(f.collected) {
+		return nil
+	}
+	notes := f.collected[from:]
+	f.collected = f.collected[:from]
+
+	components := []Drawable{f.separator(width)}
+	for _, note := range notes {
+		components = append(components, note.paragraph())
+	}
+	return components
+}
+
+// separator returns a block of width `width` with the separator rule drawn at its left.
+func (f *Footnotes) separator(width float64) *Block {
+	blk := NewBlock(width, f.separatorHeight())
+	if f.sepLength <= 0 {
+		return blk
+	}
+
+	length := f.sepLength
+	if length > width {
+		length = width
+	}
+
+	y := f.sepSpaceAbove + f.sepWidth/2
+	line := newLine(0, y, length, y)
+	line.SetLineWidth(f.sepWidth)
+	line.SetColor(f.sepColor)
+	if err := blk.Draw(line); err != nil {
+		common.Log.Debug("ERROR: unable to draw footnote separator: %v", err)
+	}
+	return blk
+}
+
+// lastPage returns the last page that footnotes are drawn on, or 0 if there are none.
+func (f *Footnotes) lastPage() int {
+	last := 0
+	for _, note := range f.placed {
+		if page := note.page + len(note.splits) - 1; page > last {
+			last = page
+		}
+	}
+	return last
+}
+
+// pageBlock returns a block of page `page`, of width `pageWidth` and height `pageHeight`, with
+// the footnotes of the page drawn at its bottom, or nil if the page has no footnotes.
+func (f *Footnotes) pageBlock(page int, pageWidth, pageHeight float64) (*Block, error) {
+	area := f.areaHeight(page)
+	if area == 0 {
+		return nil, nil
+	}
+
+	blk := NewBlock(pageWidth, pageHeight)
+	width := f.areaWidth(pageWidth)
+	y := pageHeight - f.pageMargins.bottom - area
+
+	sep := f.separator(width)
+	sep.SetPos(f.pageMargins.left, y)
+	if err := blk.Draw(sep); err != nil {
+		return nil, err
+	}
+	y += sep.Height()
+
+	// The lines carried from the previous pages are drawn first.
+	drawLines := func(note *Footnote) error {
+		from, to := note.pageLines(page)
+		if from >= to {
+			return nil
+		}
+
+		p := note.paragraph()
+		p.SetWidth(width)
+		ctx := DrawContext{
+			Page:       page,
+			X:          f.pageMargins.left,
+			Y:          y,
+			Width:      width,
+			Height:     pageHeight - y,
+			Margins:    *f.pageMargins,
+			PageWidth:  pageWidth,
+			PageHeight: pageHeight,
+		}
+		if _, _, err := drawStyledParagraphOnBlock(blk, p, p.lines[from:to], ctx); err != nil {
+			return err
+		}
+		y += sumHeights(note.heights[from:to])
+		return nil
+	}
+	for _, note := range f.placed {
+		if note.page < page {
+			if err := drawLines(note); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, note := range f.placed {
+		if note.page == page {
+			if err := drawLines(note); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return blk, nil
+}
+
+// sumHeights returns the sum of `heights`.
+func sumHeights(heights []float64) float64 {
+	var sum float64
+	for _, h := range heights {
+		sum += h
+	}
+	return sum
+}
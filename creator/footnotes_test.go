@@ -0,0 +1,404 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+)
+
+// footnotesTestWordRegexp matches the words drawn in the footnotes tests: body lines, note lines,
+// labels and chapter titles.
+var footnotesTestWordRegexp = regexp.MustCompile(`^([BN]\d+[a-z]?|[0-9]+|First|Second)$`)
+
+func TestFootnotes(t *testing.T) {
+	c := New()
+	c.SetPageMargins(50, 50, 50, 50)
+
+	// The lines are 20 points high, so 34 lines fit on a page without footnotes.
+	style := c.NewTextStyle()
+	style.FontSize = 20
+	p := c.NewStyledParagraph()
+	for i := 1; i <= 50; i++ {
+		p.Append(fmt.Sprintf("B%02d ", i)).Style = style
+		switch i {
+		case 5:
+			p.AddFootnote(c.NewFootnote("N1a\nN1b\nN1c"))
+		case 30:
+			p.AddFootnote(c.NewFootnote("N2a\nN2b\nN2c\nN2d\nN2e\nN2f\nN2g\nN2h"))
+		}
+		p.Append("\n")
+	}
+	require.NoError(t, c.Draw(p))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := extractTestWords(t, buf.Bytes(), footnotesTestWordRegexp)
+	require.Len(t, pages, 2)
+
+	// split returns the body lines, the footnote markers and the footnote lines of a page.
+	split := func(words []testWord) (body, markers, notes []testWord) {
+		for _, w := range words {
+			switch {
+			case w.text[0] == 'B':
+				body = append(body, w)
+			case w.text[0] == 'N':
+				notes = append(notes, w)
+			case w.x > 50:
+				markers = append(markers, w)
+			}
+		}
+		return body, markers, notes
+	}
+	texts := func(words []testWord) []string {
+		var texts []string
+		for _, w := range words {
+			texts = append(texts, w.text)
+		}
+		return texts
+	}
+
+	// The body of the first page shrinks to make room for both footnotes. The lines of the
+	// second footnote that don't fit are carried to the next page.
+	body, markers, notes := split(pages[0])
+	require.Len(t, body, 30)
+	require.Equal(t, []string{"1", "2"}, texts(markers))
+	require.Greater(t, markers[0].y, body[4].y)
+	require.Greater(t, markers[1].y, body[29].y)
+	require.Equal(t, []string{"N1a", "N1b", "N1c", "N2a", "N2b", "N2c", "N2d", "N2e", "N2f", "N2g"},
+		texts(notes))
+	require.LessOrEqual(t, notes[0].y, body[29].y-20)
+	require.InDelta(t, 50, notes[len(notes)-1].y, 0.01)
+
+	body, markers, notes = split(pages[1])
+	require.Len(t, body, 20)
+	require.Equal(t, "B31", body[0].text)
+	require.Empty(t, markers)
+	require.Equal(t, []string{"N2h"}, texts(notes))
+	require.InDelta(t, 50, notes[0].y, 0.01)
+}
+
+func TestFootnotesTable(t *testing.T) {
+	const note = "N1a\nN1b\nN1c\nN1d\nN1e\nN1f\nN1g\nN1h\nN1i\nN1j\nN1k"
+
+	testcases := []struct {
+		lines int
+		page  int
+		notes []string
+	}{
+		// The table starts above the footnote of the paragraph.
+		{20, 0, strings.Split(note, "\n")},
+		// The table ends above the footnote line carried to the second page.
+		{30, 1, []string{"N1k"}},
+	}
+
+	for _, tc := range testcases {
+		c := New()
+		c.SetPageMargins(50, 50, 50, 50)
+
+		style := c.NewTextStyle()
+		style.FontSize = 20
+		p := c.NewStyledParagraph()
+		for i := 1; i <= tc.lines; i++ {
+			p.Append(fmt.Sprintf("B%02d ", i)).Style = style
+			if i == tc.lines {
+				p.AddFootnote(c.NewFootnote(note))
+			}
+			p.Append("\n")
+		}
+		require.NoError(t, c.Draw(p))
+
+		table := c.NewTable(1)
+		for i := 1; i <= 40; i++ {
+			cp := c.NewParagraph(fmt.Sprintf("R%02d", i))
+			cp.SetFontSize(12)
+			require.NoError(t, table.NewCell().SetContent(cp))
+		}
+		require.NoError(t, c.Draw(table))
+
+		var buf bytes.Buffer
+		require.NoError(t, c.Write(&buf))
+		pages := extractTestWords(t, buf.Bytes(), regexp.MustCompile(`^[RN]\d+[a-z]?$`))
+		require.True(t, len(pages) > tc.page)
+
+		var rows []testWord
+		var notes []string
+		var noteTop float64
+		for _, w := range pages[tc.page] {
+			if w.text[0] == 'R' {
+				rows = append(rows, w)
+				continue
+			}
+			notes = append(notes, w.text)
+			if w.y > noteTop {
+				noteTop = w.y
+			}
+		}
+		require.Equal(t, tc.notes, notes)
+		require.NotEmpty(t, rows)
+
+		// The footnote area is the separator and the 8pt footnote lines.
+		areaTop := noteTop + 8 + c.Footnotes().separatorHeight()
+		for _, w := range rows {
+			require.Greater(t, w.y, areaTop, w.text)
+		}
+	}
+}
+
+func TestFootnotesColumns(t *testing.T) {
+	c := New()
+	c.SetPageMargins(50, 50, 50, 50)
+
+	// The lines are 20 points high, so 34 lines fit in a column without footnotes. The first
+	// footnote is referenced in the second column of the first page, the second one on the
+	// second page.
+	style := c.NewTextStyle()
+	style.FontSize = 20
+	p := c.NewStyledParagraph()
+	for i := 1; i <= 80; i++ {
+		p.Append(fmt.Sprintf("B%02d ", i)).Style = style
+		switch i {
+		case 40:
+			p.AddFootnote(c.NewFootnote("N1a\nN1b\nN1c"))
+		case 75:
+			p.AddFootnote(c.NewFootnote("N2a\nN2b"))
+		}
+		p.Append("\n")
+	}
+	cols := c.NewColumns(2)
+	cols.Add(p)
+	require.NoError(t, c.Draw(cols))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := extractTestWords(t, buf.Bytes(), footnotesTestWordRegexp)
+	require.Len(t, pages, 2)
+
+	expected := []struct {
+		marker string
+		notes  []string
+	}{
+		{"1", []string{"N1a", "N1b", "N1c"}},
+		{"2", []string{"N2a", "N2b"}},
+	}
+	for i, want := range expected {
+		var body []testWord
+		var markers, notes []string
+		var noteTop float64
+		for _, w := range pages[i] {
+			switch w.text[0] {
+			case 'B':
+				body = append(body, w)
+			case 'N':
+				notes = append(notes, w.text)
+				if w.y > noteTop {
+					noteTop = w.y
+				}
+			default:
+				// The labels of the footnotes are drawn at the left margin.
+				if w.x > 50 {
+					markers = append(markers, w.text)
+				}
+			}
+		}
+
+		// The footnotes are drawn on the page of their marker, below the body of both columns.
+		require.Equal(t, []string{want.marker}, markers)
+		require.Equal(t, want.notes, notes)
+		areaTop := noteTop + 8 + c.Footnotes().separatorHeight()
+		for _, w := range body {
+			require.Greater(t, w.y, areaTop, w.text)
+		}
+	}
+}
+
+func TestFootnotesLong(t *testing.T) {
+	c := New()
+	c.SetPageMargins(50, 50, 50, 50)
+
+	// The footnote has 40 lines of 24pt text and is taller than a page. Large text keeps the
+	// words of each page within the marks kept by the extractor of unlicensed copies. The body
+	// lines are 20 points high.
+	noteStyle := c.NewTextStyle()
+	noteStyle.FontSize = 24
+	c.Footnotes().SetTextStyle(noteStyle)
+
+	var lines []string
+	for i := 1; i <= 40; i++ {
+		lines = append(lines, fmt.Sprintf("N%02d", i))
+	}
+	style := c.NewTextStyle()
+	style.FontSize = 20
+	p := c.NewStyledParagraph()
+	for i := 1; i <= 60; i++ {
+		p.Append(fmt.Sprintf("B%02d ", i)).Style = style
+		if i == 5 {
+			p.AddFootnote(c.NewFootnote(strings.Join(lines, "\n")))
+		}
+		p.Append("\n")
+	}
+	require.NoError(t, c.Draw(p))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := extractTestWords(t, buf.Bytes(), footnotesTestWordRegexp)
+	require.True(t, len(pages) >= 3, "%d pages", len(pages))
+
+	// The lines of the footnote are carried page by page, in order, and each page keeps the
+	// lower half of its body area at most for the carried lines.
+	var notes, body []string
+	for i, words := range pages {
+		var bodyBottom float64 = 792
+		noteTop := 0.0
+		for _, w := range words {
+			switch w.text[0] {
+			case 'B':
+				body = append(body, w.text)
+				if w.y < bodyBottom {
+					bodyBottom = w.y
+				}
+			case 'N':
+				notes = append(notes, w.text)
+				require.True(t, w.y >= 50-0.01, "page %d: %s at %.2f", i+1, w.text, w.y)
+				if w.y > noteTop {
+					noteTop = w.y
+				}
+			}
+		}
+		if noteTop == 0 {
+			continue
+		}
+		areaTop := noteTop + noteStyle.FontSize + c.Footnotes().separatorHeight()
+		require.Greater(t, bodyBottom, areaTop, "page %d", i+1)
+		if i > 0 {
+			require.LessOrEqual(t, areaTop, 50+0.5*692+noteStyle.FontSize, "page %d", i+1)
+		}
+	}
+	require.Equal(t, lines, notes)
+	require.Len(t, body, 60)
+	for i, text := range body {
+		require.Equal(t, fmt.Sprintf("B%02d", i+1), text)
+	}
+}
+
+func TestFootnoteNumbering(t *testing.T) {
+	c := New()
+	var notes []*Footnote
+	for i := 0; i < 30; i++ {
+		notes = append(notes, c.NewFootnote("Note"))
+	}
+
+	testcases := []struct {
+		numbering FootnoteNumbering
+		labels    map[int]string
+	}{
+		{FootnoteNumberingDecimal, map[int]string{1: "1", 4: "4", 30: "30"}},
+		{FootnoteNumberingLowerRoman, map[int]string{1: "i", 4: "iv", 9: "ix", 14: "xiv", 29: "xxix"}},
+		{FootnoteNumberingLowerLetter, map[int]string{1: "a", 26: "z", 27: "aa", 28: "bb"}},
+		{FootnoteNumberingSymbols, map[int]string{1: "*", 2: "†", 5: "¶", 6: "**", 12: "†††"}},
+	}
+	for _, tcase := range testcases {
+		c.Footnotes().SetNumbering(tcase.numbering)
+		for number, label := range tcase.labels {
+			require.Equal(t, number, notes[number-1].Number())
+			require.Equal(t, label, notes[number-1].Label())
+		}
+	}
+
+	// The markers are updated to the numbering style when the paragraph is drawn.
+	p := c.NewStyledParagraph()
+	marker := p.AddFootnote(notes[3])
+	c.Footnotes().SetNumbering(FootnoteNumberingLowerRoman)
+	require.NoError(t, c.Draw(p))
+	require.Equal(t, "iv", marker.Text)
+}
+
+func TestEndnotes(t *testing.T) {
+	c := New()
+	c.SetPageMargins(50, 50, 50, 50)
+	c.Footnotes().SetEndnotes(true)
+
+	// The endnotes of each chapter are drawn at its end.
+	for i, title := range []string{"First", "Second"} {
+		ch := c.NewChapter(title)
+		for j := 1; j <= 2; j++ {
+			p := c.NewStyledParagraph()
+			p.Append(fmt.Sprintf("B%d%d ", i+1, j))
+			p.AddFootnote(c.NewFootnote(fmt.Sprintf("N%d%d", i+1, j)))
+			require.NoError(t, ch.Add(p))
+		}
+		require.NoError(t, c.Draw(ch))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := extractTestWords(t, buf.Bytes(), footnotesTestWordRegexp)
+	require.Len(t, pages, 1)
+
+	var texts []string
+	for i, w := range pages[0] {
+		if i > 0 {
+			require.Less(t, w.y, pages[0][i-1].y+5, w.text)
+		}
+		texts = append(texts, w.text)
+	}
+	require.Equal(t, []string{
+		"First", "B11", "1", "B12", "2", "1", "N11", "2", "N12",
+		"Second", "B21", "3", "B22", "4", "3", "N21", "4", "N22",
+	}, texts)
+}
+
+func TestStyledParagraphLeading(t *testing.T) {
+	c := New()
+	c.NewPage()
+
+	// leadings returns the leading that each line after the first is moved to with.
+	leadings := func(p *StyledParagraph) (leadings []float64, setBeforeMove int) {
+		blocks, _, err := p.GeneratePageBlocks(c.Context())
+		require.NoError(t, err)
+		require.Len(t, blocks, 1)
+
+		var leading float64
+		var prev string
+		for _, op := range *blocks[0].contents {
+			switch op.Operand {
+			case "TL":
+				leading, err = core.GetNumberAsFloat(op.Params[0])
+				require.NoError(t, err)
+			case "T*":
+				leadings = append(leadings, leading)
+				if prev == "TL" {
+					setBeforeMove++
+				}
+			}
+			prev = op.Operand
+		}
+		return leadings, setBeforeMove
+	}
+
+	// The leading of lines of a single font size is not set again before moving to the next line.
+	p := c.NewStyledParagraph()
+	p.Append("First line\nSecond line\nThird line")
+	l, set := leadings(p)
+	require.Equal(t, []float64{10, 10}, l)
+	require.Equal(t, 0, set)
+
+	// The leading is set to the height of the line when it ends with a smaller footnote marker.
+	p = c.NewStyledParagraph()
+	p.Append("First line")
+	p.AddFootnote(c.NewFootnote("Note"))
+	p.Append("\nSecond line\nThird line")
+	l, set = leadings(p)
+	require.Equal(t, []float64{10, 10}, l)
+	require.Equal(t, 1, set)
+}
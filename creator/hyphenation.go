@@ -0,0 +1,294 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+)
+
+// softHyphen (U+00AD) marks a position in a word where a line can be broken. It is not drawn,
+// except as a hyphen at the end of a line that is broken at it.
+const softHyphen = '\u00AD'
+
+// Hyphenator hyphenates words with Liang's algorithm, using the hyphenation patterns of a
+// language in the format of TeX, e.g. the patterns of the hyph-utf8 project. A text style with a
+// hyphenator (see TextStyle.Hyphenator) has soft hyphens inserted at the hyphenation points of its
+// words before its text is wrapped into lines.
+//
+// No patterns are bundled with the package, as each language has its own patterns file under its
+// own license. Callers must supply the patterns of the language of their text, e.g. by loading
+// hyph-en-us.tex of the hyph-utf8 project (https://github.com/hyphenation/tex-hyphen) with
+// NewHyphenatorFromTeX:
+//
+//	f, err := os.Open("hyph-en-us.tex")
+//	if err != nil {
+//		return err
+//	}
+//	defer f.Close()
+//	h, err := creator.NewHyphenatorFromTeX(f)
+//	if err != nil {
+//		return err
+//	}
+//	style.Hyphenator = h
+//
+// The minimum lengths of the parts of hyphenated words are not part of the patterns files and
+// should be set to the values recommended for the language, which hyph-utf8 lists in the header
+// of each file (see LeftMin and RightMin).
+type Hyphenator struct {
+	// LeftMin and RightMin are the minimum number of runes before the first and after the last
+	// hyphenation point of a word. They depend on the language, e.g. they are 2 and 3 for English.
+	LeftMin  int
+	RightMin int
+
+	// patterns maps the letters of the patterns to their inter-letter values.
+	patterns map[string][]int
+
+	// maxLen is the number of letters of the longest pattern.
+	maxLen int
+
+	// exceptions maps words to their hyphenation points.
+	exceptions map[string][]int
+}
+
+// NewHyphenator returns a new hyphenator that uses the TeX hyphenation `patterns`, e.g. "hy3ph",
+// and the hyphenated `exceptions` words that the patterns don't hyphenate correctly, e.g.
+// "as-so-ciate". LeftMin and RightMin are set to 2 and 3, the values for English. The patterns
+// must be supplied by the caller, e.g. from the .pat.txt and .hyp.txt files of hyph-utf8.
+func NewHyphenator(patterns, exceptions []string) (*Hyphenator, error) {
+	h := &Hyphenator{
+		LeftMin:    2,
+		RightMin:   3,
+		patterns:   map[string][]int{},
+		exceptions: map[string][]int{},
+	}
+
+	for _, pattern := range patterns {
+		var letters []rune
+		values := []int{0}
+		for _, r := range pattern {
+			if r >= '0' && r <= '9' {
+				values[len(values)-1] = int(r - '0')
+				continue
+			}
+			letters = append(letters, unicode.ToLower(r))
+			values = append(values, 0)
+		}
+		if len(letters) == 0 {
+			return nil, errors.New("invalid hyphenation pattern: " + pattern)
+		}
+
+		h.patterns[string(letters)] = values
+		if len(letters) > h.maxLen {
+			h.maxLen = len(letters)
+		}
+	}
+
+	for _, exception := range exceptions {
+		var letters []rune
+		var points []int
+		for _, r := range exception {
+			if r == '-' {
+				points = append(points, len(letters))
+				continue
+			}
+			letters = append(letters, unicode.ToLower(r))
+		}
+		h.exceptions[string(letters)] = points
+	}
+
+	return h, nil
+}
+
+// NewHyphenatorFromTeX returns a new hyphenator that uses the patterns and exceptions of the
+// \patterns{...} and \hyphenation{...} groups of a TeX hyphenation patterns file, e.g.
+// hyph-en-us.tex of the hyph-utf8 project, read from `r`. Comments and other commands in the file
+// are ignored.
+func NewHyphenatorFromTeX(r io.Reader) (*Hyphenator, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove comments.
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if idx := strings.IndexByte(line, '%'); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+	text := strings.Join(lines, "\n")
+
+	group := func(command string) ([]string, error) {
+		var words []string
+		for rest := text; ; {
+			idx := strings.Index(rest, command+"{")
+			if idx < 0 {
+				return words, nil
+			}
+			rest = rest[idx+len(command)+1:]
+
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, errors.New("unterminated " + command + " group")
+			}
+			words = append(words, strings.Fields(rest[:end])...)
+			rest = rest[end+1:]
+		}
+	}
+
+	patterns, err := group(`\patterns`)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("no hyphenation patterns found")
+	}
+	exceptions, err := group(`\hyphenation`)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHyphenator(patterns, exceptions)
+}
+
+// Hyphenate returns the parts of `word` between its hyphenation points.
+func (h *Hyphenator) Hyphenate(word string) []string {
+	runes := []rune(word)
+
+	var parts []string
+	start := 0
+	for _, point := range h.points(runes) {
+		parts = append(parts, string(runes[start:point]))
+		start = point
+	}
+	return append(parts, string(runes[start:]))
+}
+
+// points returns the indices of the runes of `word` that the word can be hyphenated before.
+func (h *Hyphenator) points(word []rune) []int {
+	n := len(word)
+	if n < h.LeftMin+h.RightMin || n < 2 {
+		return nil
+	}
+
+	lower := make([]rune, n)
+	for i, r := range word {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	var points []int
+	if exception, ok := h.exceptions[string(lower)]; ok {
+		for _, point := range exception {
+			if point >= h.LeftMin && n-point >= h.RightMin {
+				points = append(points, point)
+			}
+		}
+		return points
+	}
+
+	// The word is delimited by dots, which match the word boundaries in patterns. values[i] is the
+	// value of the position before rune i of the delimited word.
+	w := make([]rune, 0, n+2)
+	w = append(w, '.')
+	w = append(w, lower...)
+	w = append(w, '.')
+
+	values := make([]int, len(w)+1)
+	for i := range w {
+		for j := i + 1; j <= len(w) && j-i <= h.maxLen; j++ {
+			pattern, ok := h.patterns[string(w[i:j])]
+			if !ok {
+				continue
+			}
+			for k, v := range pattern {
+				if v > values[i+k] {
+					values[i+k] = v
+				}
+			}
+		}
+	}
+
+	// Odd values are hyphenation points. Rune i of the word is rune i+1 of the delimited word.
+	for i := h.LeftMin; i <= n-h.RightMin; i++ {
+		if i > 0 && values[i+1]%2 == 1 {
+			points = append(points, i)
+		}
+	}
+	return points
+}
+
+// hyphenateText returns `text` with soft hyphens inserted at the hyphenation points of its words.
+// The words are the runs of letters of the text. Words next to soft hyphens are left as they are.
+func (h *Hyphenator) hyphenateText(text string) string {
+	runes := []rune(text)
+	result := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			result = append(result, runes[i])
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.Is(unicode.Mn, runes[j])) {
+			j++
+		}
+		word := runes[i:j]
+		if (i > 0 && runes[i-1] == softHyphen) || (j < len(runes) && runes[j] == softHyphen) {
+			result = append(result, word...)
+			i = j
+			continue
+		}
+
+		start := 0
+		for _, point := range h.points(word) {
+			result = append(result, word[start:point]...)
+			result = append(result, softHyphen)
+			start = point
+		}
+		result = append(result, word[start:]...)
+		i = j
+	}
+	return string(result)
+}
+
+// hyphenateChunk returns a copy of `chunk` whose text has soft hyphens inserted at the hyphenation
+// points of its words by the hyphenator of its style. `chunk` is returned if its style has no
+// hyphenator.
+func hyphenateChunk(chunk *TextChunk) *TextChunk {
+	if chunk.Style.Hyphenator == nil {
+		return chunk
+	}
+
+	hyphenated := *chunk
+	hyphenated.Text = chunk.Style.Hyphenator.hyphenateText(chunk.Text)
+	return &hyphenated
+}
+
+// softHyphenText returns the text of a line of wrapped text with its soft hyphens removed. A soft
+// hyphen at the end of the line, which the line is broken at, is replaced by a hyphen.
+func softHyphenText(line string) string {
+	hyphen := strings.HasSuffix(line, string(softHyphen))
+	line = strings.Replace(line, string(softHyphen), "", -1)
+	if hyphen {
+		line += "-"
+	}
+	return line
+}
+
+// hyphenWidth returns the width in text space units (1/1000 of a point) of the hyphen that is
+// drawn at the end of lines broken at soft hyphens in text of `style`.
+func hyphenWidth(style *TextStyle) float64 {
+	metrics, found := style.Font.GetRuneMetrics('-')
+	if !found {
+		return 0
+	}
+	return style.FontSize*metrics.Wx + style.CharSpacing*1000.0
+}
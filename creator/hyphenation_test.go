@@ -0,0 +1,89 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testHyphenationPatterns are the patterns that hyphenate "hyphenation" in Appendix H of
+// The TeXbook.
+var testHyphenationPatterns = []string{
+	"hy3ph", "he2n", "hena4", "hen5at", "1na", "n2at", "1tio", "2io", "o2n",
+}
+
+func TestHyphenator(t *testing.T) {
+	h, err := NewHyphenator(testHyphenationPatterns, []string{"ta-ble"})
+	require.NoError(t, err)
+
+	testcases := []struct {
+		word     string
+		expected []string
+	}{
+		{"hyphenation", []string{"hy", "phen", "ation"}},
+		{"Hyphenation", []string{"Hy", "phen", "ation"}},
+		{"table", []string{"ta", "ble"}},
+		{"Table", []string{"Ta", "ble"}},
+		{"hen", []string{"hen"}},
+		{"", []string{""}},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.expected, h.Hyphenate(tc.word), tc.word)
+	}
+
+	// The minimum lengths of the parts at the start and the end of words.
+	h.LeftMin = 3
+	require.Equal(t, []string{"hyphen", "ation"}, h.Hyphenate("hyphenation"))
+	h.RightMin = 6
+	require.Equal(t, []string{"hyphenation"}, h.Hyphenate("hyphenation"))
+
+	_, err = NewHyphenator([]string{"12"}, nil)
+	require.Error(t, err)
+}
+
+func TestHyphenatorFromTeX(t *testing.T) {
+	tex := `% Hyphenation patterns.
+\message{Test patterns}
+\patterns{ % Patterns of The TeXbook.
+hy3ph he2n hena4 hen5at
+1na n2at 1tio 2io o2n
+}
+\hyphenation{
+ta-ble % Exception.
+}`
+	h, err := NewHyphenatorFromTeX(strings.NewReader(tex))
+	require.NoError(t, err)
+	require.Equal(t, []string{"hy", "phen", "ation"}, h.Hyphenate("hyphenation"))
+	require.Equal(t, []string{"ta", "ble"}, h.Hyphenate("table"))
+
+	_, err = NewHyphenatorFromTeX(strings.NewReader(`\hyphenation{ta-ble}`))
+	require.Error(t, err)
+	_, err = NewHyphenatorFromTeX(strings.NewReader(`\patterns{hy3ph`))
+	require.Error(t, err)
+}
+
+func TestHyphenateText(t *testing.T) {
+	h, err := NewHyphenator(testHyphenationPatterns, nil)
+	require.NoError(t, err)
+
+	shy := string(softHyphen)
+	testcases := []struct {
+		text     string
+		expected string
+	}{
+		{"hyphenation", "hy" + shy + "phen" + shy + "ation"},
+		{"(hyphenation), hyphenation.", "(hy" + shy + "phen" + shy + "ation), hy" + shy + "phen" + shy + "ation."},
+		// Words with soft hyphens are not hyphenated.
+		{"hyphen" + shy + "ation", "hyphen" + shy + "ation"},
+		{"1234 hen", "1234 hen"},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.expected, h.hyphenateText(tc.text), tc.text)
+	}
+}
@@ -212,6 +212,7 @@ func (img *Image) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, er
 			newContext.Y = ctx.Margins.top // + p.Margins.top
 			newContext.X = ctx.Margins.left + img.margins.left
 			newContext.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom - img.margins.bottom
+			newContext.Height = ctx.footnotes.fitHeight(newContext, newContext.Height)
 			newContext.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - img.margins.left - img.margins.right
 			ctx = newContext
 		} else {
@@ -0,0 +1,370 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/gnaoh1379/unipdf/common"
+)
+
+// Text is broken into lines optimally (LineBreakingOptimal) with the algorithm of Knuth and Plass,
+// "Breaking Paragraphs into Lines" (1981). The text is modelled as a sequence of boxes (words),
+// glue (spaces that can stretch and, for justified text, shrink) and penalties (soft hyphens and
+// forced breaks). Of all the ways to break the text at glue and penalties into lines that fit, the
+// one with the least total demerits is chosen. The demerits of a line grow with the cube of how
+// much its spaces are stretched or shrunk, and are increased for hyphens, for consecutive
+// hyphenated lines and for lines that are much looser or tighter than the line before them.
+
+// Line breaking parameters. The penalties and demerits are those of plain TeX. Unlike in TeX, the
+// badness of lines isn't limited to 10000, so that of two loose lines the looser one is worse even
+// in narrow columns, where most lines are loose. Underfull lines that can't stretch, e.g. lines of
+// a single word, have the badness lineBreakMaxBadness.
+const (
+	lineBreakLinePenalty     = 10
+	lineBreakHyphenPenalty   = 50
+	lineBreakFlaggedDemerits = 3000
+	lineBreakFitnessDemerits = 10000
+	lineBreakMaxBadness      = 1e7
+)
+
+// breakItemKind is the kind of an item of text that is broken into lines.
+type breakItemKind int
+
+const (
+	breakBox breakItemKind = iota
+	breakGlue
+	breakPenalty
+)
+
+// breakItem is an item of text that is broken into lines: a box, glue or a penalty. Widths are
+// in text space units (1/1000 of a point).
+type breakItem struct {
+	kind    breakItemKind
+	width   float64
+	stretch float64
+	shrink  float64
+	penalty float64
+	// flagged penalties are breaks at which a hyphen is added.
+	flagged bool
+	// pos is the index of the rune that the glue or penalty is made of in the runes of all the text.
+	pos int
+}
+
+// forced returns true if the item is a penalty that forces a line break.
+func (item breakItem) forced() bool {
+	return item.kind == breakPenalty && math.IsInf(item.penalty, -1)
+}
+
+// breakNode is a feasible line break.
+type breakNode struct {
+	// item is the index of the item that the line is broken at.
+	item int
+	// fitness is the fitness class of the line that ends at the break: 0 for tight, 1 for decent,
+	// 2 for loose and 3 for very loose lines.
+	fitness int
+	// totalWidth, totalStretch and totalShrink are the sums of the items before the first box
+	// after the break, i.e. of the items that are not part of the next line.
+	totalWidth   float64
+	totalStretch float64
+	totalShrink  float64
+	// demerits are the total demerits of the lines up to the break.
+	demerits float64
+	// prev is the break of the previous line.
+	prev *breakNode
+}
+
+// optimalLines breaks the text of `chunks` into lines of width `width` (points) with the fewest
+// total demerits. The chunks are split at the line breaks. The spaces that lines are broken at
+// are left out and a hyphen is added to the lines that are broken at soft hyphens. Spaces can
+// only shrink if `justify` is true.
+// It returns nil lines if the text can't be broken into lines that fit, e.g. if it has words that
+// are wider than the lines.
+func optimalLines(chunks []*TextChunk, width float64, justify bool) ([][]*TextChunk, error) {
+	items, err := breakItems(chunks, justify)
+	if err != nil {
+		return nil, err
+	}
+
+	breaks := optimalBreaks(items, width*1000.0)
+	if breaks == nil {
+		return nil, nil
+	}
+
+	// offsets[k] is the index of the first rune of chunks[k] in the runes of all the text.
+	var runes [][]rune
+	offsets := make([]int, len(chunks)+1)
+	for k, chunk := range chunks {
+		runes = append(runes, []rune(chunk.Text))
+		offsets[k+1] = offsets[k] + len(runes[k])
+	}
+	chunkAt := func(pos int) int {
+		for k := range chunks {
+			if pos < offsets[k+1] {
+				return k
+			}
+		}
+		return len(chunks) - 1
+	}
+	runeAt := func(pos int) rune {
+		k := chunkAt(pos)
+		return runes[k][pos-offsets[k]]
+	}
+
+	var lines [][]*TextChunk
+	start := 0
+	for n, b := range breaks {
+		item := items[b]
+		end := item.pos
+
+		var line []*TextChunk
+		for k, chunk := range chunks {
+			lo, hi := offsets[k], offsets[k+1]
+			if lo < start {
+				lo = start
+			}
+			if hi > end {
+				hi = end
+			}
+			if lo >= hi {
+				continue
+			}
+
+			line = append(line, &TextChunk{
+				Text:       string(runes[k][lo-offsets[k] : hi-offsets[k]]),
+				Style:      chunk.Style,
+				annotation: copyAnnotation(chunk.annotation),
+				footnote:   chunk.footnote,
+			})
+		}
+
+		if len(line) == 0 && (item.flagged || (item.forced() && n < len(breaks)-1)) {
+			// Keep the lines that only have a hyphen or that end at line feeds.
+			chunk := chunks[chunkAt(end)]
+			line = append(line, &TextChunk{
+				Style:      chunk.Style,
+				annotation: copyAnnotation(chunk.annotation),
+			})
+		}
+		if len(line) > 0 {
+			// The spaces before forced breaks are left out too.
+			last := line[len(line)-1]
+			last.Text = strings.TrimRight(last.Text, " ")
+			if item.flagged {
+				last.Text += "-"
+			}
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+
+		// The spaces and soft hyphens after the break are left out.
+		start = end + 1
+		for start < offsets[len(chunks)] {
+			if r := runeAt(start); r != ' ' && r != softHyphen {
+				break
+			}
+			start++
+		}
+	}
+
+	return lines, nil
+}
+
+// breakItems returns the items of the text of `chunks`. Spaces are glue that can stretch by half
+// of their width and, if `justify` is true, shrink by a third of it. Soft hyphens are flagged
+// penalties whose width is the width of a hyphen, and line feeds and the end of the text are
+// forced breaks.
+func breakItems(chunks []*TextChunk, justify bool) ([]breakItem, error) {
+	var items []breakItem
+	pos := 0
+	for _, chunk := range chunks {
+		style := &chunk.Style
+		runes := []rune(chunk.Text)
+		glyphWidths, missing := runeWidths(style, runes)
+		if missing >= 0 {
+			common.Log.Debug("Rune char metrics not found! %v\n", runes[missing])
+			return nil, errors.New("glyph char metrics missing")
+		}
+
+		for i, r := range runes {
+			w := style.FontSize * glyphWidths[i]
+			switch r {
+			case '\u000A': // LF
+				items = append(items, breakItem{kind: breakPenalty, penalty: math.Inf(-1), pos: pos})
+			case ' ':
+				item := breakItem{kind: breakGlue, width: w, stretch: w / 2, pos: pos}
+				if justify {
+					item.shrink = w / 3
+				}
+				items = append(items, item)
+			case softHyphen:
+				items = append(items, breakItem{
+					kind:    breakPenalty,
+					width:   hyphenWidth(style),
+					penalty: lineBreakHyphenPenalty,
+					flagged: true,
+					pos:     pos,
+				})
+			default:
+				w += style.CharSpacing * 1000.0
+				if n := len(items); n > 0 && items[n-1].kind == breakBox {
+					items[n-1].width += w
+				} else {
+					items = append(items, breakItem{kind: breakBox, width: w, pos: pos})
+				}
+			}
+			pos++
+		}
+	}
+
+	return append(items, breakItem{kind: breakPenalty, penalty: math.Inf(-1), pos: pos}), nil
+}
+
+// optimalBreaks returns the indices of the items that `items` are broken into lines of width
+// `lineWidth` at, with the fewest total demerits. The last break is at the last item, which must
+// be a forced break. It returns nil if the items can't be broken into lines that fit.
+func optimalBreaks(items []breakItem, lineWidth float64) []int {
+	active := []*breakNode{{item: -1, fitness: 1}}
+	var sumWidth, sumStretch, sumShrink float64
+
+	for b, item := range items {
+		switch item.kind {
+		case breakBox:
+			sumWidth += item.width
+			continue
+		case breakGlue:
+			// Glue is a legal break if it follows a box.
+			if b == 0 || items[b-1].kind != breakBox {
+				sumWidth += item.width
+				sumStretch += item.stretch
+				sumShrink += item.shrink
+				continue
+			}
+		}
+
+		// Find the best break at item `b` for each fitness class of the line that ends at it.
+		var best [4]*breakNode
+		var remaining []*breakNode
+		for _, a := range active {
+			w := sumWidth - a.totalWidth
+			if item.kind == breakPenalty {
+				w += item.width
+			}
+
+			var ratio float64
+			switch {
+			case w < lineWidth:
+				if item.forced() {
+					ratio = 0
+				} else if stretch := sumStretch - a.totalStretch; stretch > 0 {
+					ratio = (lineWidth - w) / stretch
+				} else {
+					ratio = math.Inf(1)
+				}
+			case w > lineWidth:
+				if shrink := sumShrink - a.totalShrink; shrink > 0 {
+					ratio = (lineWidth - w) / shrink
+				} else {
+					ratio = math.Inf(-1)
+				}
+			}
+
+			// The lines from `a` to later breaks don't fit if the line to this break doesn't fit
+			// without a hyphen. No lines continue past forced breaks.
+			natural := sumWidth - a.totalWidth
+			if natural <= lineWidth+sumShrink-a.totalShrink && !item.forced() {
+				remaining = append(remaining, a)
+			}
+			if ratio < -1 {
+				continue
+			}
+
+			badness := lineBreakMaxBadness
+			if !math.IsInf(ratio, 1) {
+				badness = math.Min(100*math.Pow(math.Abs(ratio), 3), lineBreakMaxBadness)
+			}
+			demerits := math.Pow(lineBreakLinePenalty+badness, 2)
+			if item.kind == breakPenalty && item.penalty >= 0 {
+				demerits += item.penalty * item.penalty
+			}
+			if item.flagged && a.item >= 0 && items[a.item].flagged {
+				demerits += lineBreakFlaggedDemerits
+			}
+
+			fitness := 1
+			switch {
+			case ratio < -0.5:
+				fitness = 0
+			case ratio > 1:
+				fitness = 3
+			case ratio > 0.5:
+				fitness = 2
+			}
+			if fitness-a.fitness > 1 || a.fitness-fitness > 1 {
+				demerits += lineBreakFitnessDemerits
+			}
+
+			demerits += a.demerits
+			if best[fitness] == nil || demerits < best[fitness].demerits {
+				best[fitness] = &breakNode{item: b, fitness: fitness, demerits: demerits, prev: a}
+			}
+		}
+		active = remaining
+
+		// The items after the break that are left out of the next line: glue and penalties up to
+		// the next box or forced break.
+		totalWidth, totalStretch, totalShrink := sumWidth, sumStretch, sumShrink
+		for i := b; i < len(items); i++ {
+			next := items[i]
+			if next.kind == breakBox || (i > b && next.forced()) {
+				break
+			}
+			if next.kind == breakGlue {
+				totalWidth += next.width
+				totalStretch += next.stretch
+				totalShrink += next.shrink
+			}
+		}
+		for _, node := range best {
+			if node == nil {
+				continue
+			}
+			node.totalWidth = totalWidth
+			node.totalStretch = totalStretch
+			node.totalShrink = totalShrink
+			active = append(active, node)
+		}
+		if len(active) == 0 {
+			return nil
+		}
+
+		if item.kind == breakGlue {
+			sumWidth += item.width
+			sumStretch += item.stretch
+			sumShrink += item.shrink
+		}
+	}
+
+	// All the active breaks are at the last item, which is a forced break.
+	var last *breakNode
+	for _, node := range active {
+		if last == nil || node.demerits < last.demerits {
+			last = node
+		}
+	}
+	if last == nil || last.item != len(items)-1 {
+		return nil
+	}
+
+	var breaks []int
+	for node := last; node.prev != nil; node = node.prev {
+		breaks = append([]int{node.item}, breaks...)
+	}
+	return breaks
+}
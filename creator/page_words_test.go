@@ -0,0 +1,67 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/extractor"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// testWord is a word extracted from a page.
+type testWord struct {
+	text string
+	x, y float64
+}
+
+// extractTestWords returns the words of the pages of the PDF in `data` that match `re`. Words
+// are runs of text marks between spaces and line breaks. The bottom left corner of the first mark
+// of a word is its position.
+func extractTestWords(t *testing.T, data []byte, re *regexp.Regexp) [][]testWord {
+	r, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	numPages, err := r.GetNumPages()
+	require.NoError(t, err)
+
+	var pages [][]testWord
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		require.NoError(t, err)
+		e, err := extractor.New(page)
+		require.NoError(t, err)
+		pageText, _, _, err := e.ExtractPageText()
+		require.NoError(t, err)
+
+		var words []testWord
+		var word *testWord
+		for _, mark := range pageText.Marks().Elements() {
+			if mark.Meta {
+				word = nil
+				continue
+			}
+			if word == nil {
+				words = append(words, testWord{x: mark.BBox.Llx, y: mark.BBox.Lly})
+				word = &words[len(words)-1]
+			}
+			word.text += mark.Text
+		}
+
+		// Leave out the words of the watermark of unlicensed copies.
+		var filtered []testWord
+		for _, w := range words {
+			if re.MatchString(w.text) {
+				filtered = append(filtered, w)
+			}
+		}
+		pages = append(pages, filtered)
+	}
+	return pages
+}
@@ -24,6 +24,7 @@ func (p *PageBreak) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	newContext.Y = ctx.Margins.top
 	newContext.X = ctx.Margins.left
 	newContext.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom
+	newContext.Height = ctx.footnotes.fitHeight(newContext, newContext.Height)
 	newContext.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right
 	ctx = newContext
 
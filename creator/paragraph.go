@@ -24,6 +24,9 @@ type Paragraph struct {
 	// The font to be used to draw the text.
 	textFont *model.PdfFont
 
+	// The fonts used for the runes that textFont doesn't have glyphs for.
+	fallbackFonts []*model.PdfFont
+
 	// The font size (points).
 	fontSize float64
 
@@ -37,8 +40,12 @@ type Paragraph struct {
 	alignment TextAlignment
 
 	// Wrapping properties.
-	enableWrap bool
-	wrapWidth  float64
+	enableWrap   bool
+	wrapWidth    float64
+	lineBreaking LineBreaking
+
+	// The hyphenator used to hyphenate the words of the text when it is wrapped.
+	hyphenator *Hyphenator
 
 	// defaultWrap defines whether wrapping has been defined explictly or whether default behavior should
 	// be observed. Default behavior depends on context: normally wrap is expected, except for example in
@@ -72,17 +79,19 @@ type Paragraph struct {
 // and use SetFont on the paragraph to override the defaut one.
 func newParagraph(text string, style TextStyle) *Paragraph {
 	p := &Paragraph{
-		text:        text,
-		textFont:    style.Font,
-		fontSize:    style.FontSize,
-		lineHeight:  1.0,
-		enableWrap:  true,
-		defaultWrap: true,
-		alignment:   TextAlignmentLeft,
-		angle:       0,
-		scaleX:      1,
-		scaleY:      1,
-		positioning: positionRelative,
+		text:          text,
+		textFont:      style.Font,
+		fallbackFonts: style.FallbackFonts,
+		hyphenator:    style.Hyphenator,
+		fontSize:      style.FontSize,
+		lineHeight:    1.0,
+		enableWrap:    true,
+		defaultWrap:   true,
+		alignment:     TextAlignmentLeft,
+		angle:         0,
+		scaleX:        1,
+		scaleY:        1,
+		positioning:   positionRelative,
 	}
 
 	p.SetColor(style.Color)
@@ -94,6 +103,12 @@ func (p *Paragraph) SetFont(font *model.PdfFont) {
 	p.textFont = font
 }
 
+// SetFallbackFonts sets the fonts used, in order, for the runes that the Paragraph's font doesn't
+// have glyphs for. See TextStyle.FallbackFonts.
+func (p *Paragraph) SetFallbackFonts(fonts ...*model.PdfFont) {
+	p.fallbackFonts = fonts
+}
+
 // SetFontSize sets the font size in document units (points).
 func (p *Paragraph) SetFontSize(fontSize float64) {
 	p.fontSize = fontSize
@@ -125,6 +140,18 @@ func (p *Paragraph) SetEnableWrap(enableWrap bool) {
 	p.defaultWrap = false
 }
 
+// SetLineBreaking sets the algorithm used to break the text into lines when it is wrapped
+// (LineBreakingGreedy default).
+func (p *Paragraph) SetLineBreaking(lineBreaking LineBreaking) {
+	p.lineBreaking = lineBreaking
+}
+
+// SetHyphenator sets the hyphenator used to hyphenate the words of the text when it is wrapped.
+// See TextStyle.Hyphenator.
+func (p *Paragraph) SetHyphenator(hyphenator *Hyphenator) {
+	p.hyphenator = hyphenator
+}
+
 // SetColor sets the color of the Paragraph text.
 //
 // Example:
@@ -190,43 +217,36 @@ func (p *Paragraph) Height() float64 {
 	return float64(len(p.textLines)) * p.lineHeight * p.fontSize
 }
 
-// getTextWidth calculates the text width as if all in one line (not taking wrapping into account).
-func (p *Paragraph) getTextWidth() float64 {
-	w := 0.0
-
-	for _, r := range p.text {
-		// Ignore newline for this.. Handles as if all in one line.
-		if r == '\u000A' { // LF
-			continue
-		}
-
-		metrics, found := p.textFont.GetRuneMetrics(r)
-		if !found {
-			common.Log.Debug("ERROR: Rune char metrics not found! (rune 0x%04x=%c)", r, r)
-			return -1 // FIXME: return error.
-		}
-		w += p.fontSize * metrics.Wx
+// textStyle returns the style of the text of the Paragraph.
+func (p *Paragraph) textStyle() TextStyle {
+	return TextStyle{
+		Font:          p.textFont,
+		FallbackFonts: p.fallbackFonts,
+		FontSize:      p.fontSize,
+		Hyphenator:    p.hyphenator,
 	}
+}
 
-	return w
+// getTextWidth calculates the text width as if all in one line (not taking wrapping into account).
+func (p *Paragraph) getTextWidth() float64 {
+	return p.getTextLineWidth(p.text)
 }
 
 // getTextLineWidth calculates the text width of a provided line of text.
 func (p *Paragraph) getTextLineWidth(line string) float64 {
-	var width float64
-	for _, r := range line {
-		// Ignore newline for this.. Handles as if all in one line.
-		if r == '\u000A' { // LF
-			continue
-		}
-
-		metrics, found := p.textFont.GetRuneMetrics(r)
-		if !found {
-			common.Log.Debug("ERROR: Rune char metrics not found! (rune 0x%04x=%c)", r, r)
-			return -1 // FIXME: return error.
-		}
+	style := p.textStyle()
+	runes := []rune(line)
+	glyphWidths, missing := runeWidths(&style, runes)
+	if missing >= 0 {
+		r := runes[missing]
+		common.Log.Debug("ERROR: Rune char metrics not found! (rune 0x%04x=%c)", r, r)
+		return -1 // FIXME: return error.
+	}
 
-		width += p.fontSize * metrics.Wx
+	// Newlines have zero width, so the text is handled as if it was all in one line.
+	var width float64
+	for _, w := range glyphWidths {
+		width += p.fontSize * w
 	}
 
 	return width
@@ -249,18 +269,34 @@ func (p *Paragraph) getMaxLineWidth() float64 {
 	return width
 }
 
-// Simple algorithm to wrap the text into lines (greedy algorithm - fill the lines).
-// TODO: Consider the Knuth/Plass algorithm or an alternative.
+// wrapText wraps the text into lines. By default, it uses a simple greedy algorithm that fills the
+// lines. With LineBreakingOptimal, the breaks of all the lines are chosen together with the
+// Knuth/Plass algorithm, unless the text can't be broken into lines that fit.
 func (p *Paragraph) wrapText() error {
 	if !p.enableWrap || int(p.wrapWidth) <= 0 {
 		p.textLines = []string{p.text}
 		return nil
 	}
 
-	chunk := NewTextChunk(p.text, TextStyle{
-		Font:     p.textFont,
-		FontSize: p.fontSize,
-	})
+	chunk := NewTextChunk(p.text, p.textStyle())
+	if p.lineBreaking == LineBreakingOptimal {
+		chunks := splitByFont(hyphenateChunk(chunk))
+		lines, err := optimalLines(chunks, p.wrapWidth, p.alignment == TextAlignmentJustify)
+		if err != nil {
+			return err
+		}
+		if lines != nil {
+			p.textLines = nil
+			for _, line := range lines {
+				var text string
+				for _, chunk := range line {
+					text += chunk.Text
+				}
+				p.textLines = append(p.textLines, softHyphenText(text))
+			}
+			return nil
+		}
+	}
 
 	lines, err := chunk.Wrap(p.wrapWidth)
 	if err != nil {
@@ -303,6 +339,7 @@ func (p *Paragraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 			newContext.Y = ctx.Margins.top // + p.Margins.top
 			newContext.X = ctx.Margins.left + p.margins.left
 			newContext.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom - p.margins.bottom
+			newContext.Height = ctx.footnotes.fitHeight(newContext, newContext.Height)
 			newContext.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - p.margins.left - p.margins.right
 			ctx = newContext
 		}
@@ -350,6 +387,24 @@ func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContex
 		return ctx, err
 	}
 
+	// The fallback fonts are added to the resources when they are used.
+	fontNames := map[*model.PdfFont]core.PdfObjectName{p.textFont: fontName}
+	getFontName := func(font *model.PdfFont) (core.PdfObjectName, error) {
+		if name, ok := fontNames[font]; ok {
+			return name, nil
+		}
+		for blk.resources.HasFontByName(fontName) {
+			num++
+			fontName = core.PdfObjectName("Font" + strconv.Itoa(num))
+		}
+		if err := blk.resources.SetFontByName(fontName, font.ToPdfObject()); err != nil {
+			return "", err
+		}
+		fontNames[font] = fontName
+		return fontName, nil
+	}
+	style := p.textStyle()
+
 	// Wrap the text into lines.
 	p.wrapText()
 
@@ -378,6 +433,14 @@ func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContex
 		runes := []rune(line)
 
 		// Get width of the line (excluding spaces).
+		glyphWidths, missing := runeWidths(&style, runes)
+		if missing >= 0 {
+			r := runes[missing]
+			common.Log.Debug("Unsupported rune i=%d rune=0x%04x=%c in font %s %s",
+				missing, r, r,
+				p.textFont.BaseFont(), p.textFont.Subtype())
+			return ctx, errors.New("unsupported text glyph")
+		}
 		w := 0.0
 		spaces := 0
 		for i, r := range runes {
@@ -385,18 +448,7 @@ func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContex
 				spaces++
 				continue
 			}
-			if r == '\u000A' { // LF
-				continue
-			}
-			metrics, found := p.textFont.GetRuneMetrics(r)
-			if !found {
-				common.Log.Debug("Unsupported rune i=%d rune=0x%04x=%c in font %s %s",
-					i, r, r,
-					p.textFont.BaseFont(), p.textFont.Subtype())
-				return ctx, errors.New("unsupported text glyph")
-			}
-
-			w += p.fontSize * metrics.Wx
+			w += p.fontSize * glyphWidths[i]
 		}
 
 		var objs []core.PdfObject
@@ -421,32 +473,72 @@ func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContex
 			shift := (p.wrapWidth*1000.0 - textWidth) / p.fontSize
 			objs = append(objs, core.MakeFloat(-shift))
 		}
-		enc := p.textFont.Encoder()
 
+		// The text in each font is drawn with its own TJ operations. Words in fonts that can shape
+		// text are shaped.
 		var encoded []byte
-		for _, r := range runes {
-			if r == '\u000A' { // LF
-				continue
+		drawn := false
+		flush := func() {
+			if len(encoded) > 0 {
+				objs = append(objs, core.MakeStringFromBytes(encoded))
+				encoded = nil
+			}
+			if len(objs) > 0 {
+				cc.Add_TJ(objs...)
+				objs = nil
+				drawn = true
 			}
-			if r == ' ' { // TODO: What about \t and other spaces.
-				if len(encoded) > 0 {
-					objs = append(objs, core.MakeStringFromBytes(encoded))
-					encoded = nil
+		}
+		font := p.textFont
+		for _, run := range fontRuns(&style, runes) {
+			if run.font != font {
+				flush()
+				name, err := getFontName(run.font)
+				if err != nil {
+					return ctx, err
 				}
-				objs = append(objs, core.MakeFloat(-spaceWidth))
-			} else {
-				if _, ok := enc.RuneToCharcode(r); !ok {
-					common.Log.Debug("unsupported rune in text encoding: %#x (%c)", r, r)
+				cc.Add_Tf(name, p.fontSize)
+				font = run.font
+			}
+			enc := font.Encoder()
+			shape := font.CanShapeText()
+
+			for i := run.start; i < run.end; i++ {
+				r := runes[i]
+				if r == '\u000A' || r == softHyphen { // LF or SHY
 					continue
 				}
-				encoded = append(encoded, enc.Encode(string(r))...)
+				if r == ' ' { // TODO: What about \t and other spaces.
+					if len(encoded) > 0 {
+						objs = append(objs, core.MakeStringFromBytes(encoded))
+						encoded = nil
+					}
+					objs = append(objs, core.MakeFloat(-spaceWidth))
+				} else if shape {
+					j := i + 1
+					for j < run.end && !isWordBreak(runes[j]) {
+						j++
+					}
+					flush()
+					addShapedText(cc, font, runes[i:j], false, p.fontSize)
+					drawn = true
+					i = j - 1
+				} else {
+					if _, ok := enc.RuneToCharcode(r); !ok {
+						common.Log.Debug("unsupported rune in text encoding: %#x (%c)", r, r)
+						continue
+					}
+					encoded = append(encoded, enc.Encode(string(r))...)
+				}
 			}
 		}
-		if len(encoded) > 0 {
-			objs = append(objs, core.MakeStringFromBytes(encoded))
+		flush()
+		if !drawn {
+			cc.Add_TJ()
+		}
+		if font != p.textFont {
+			cc.Add_Tf(fontNames[p.textFont], p.fontSize)
 		}
-
-		cc.Add_TJ(objs...)
 	}
 	cc.Add_ET()
 	cc.Add_Q()
@@ -8,6 +8,7 @@ package creator
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -33,3 +34,43 @@ func benchmarkParagraphAdding(b *testing.B, loops int) {
 func BenchmarkParagraphAdding1(b *testing.B)   { benchmarkParagraphAdding(b, 1) }
 func BenchmarkParagraphAdding10(b *testing.B)  { benchmarkParagraphAdding(b, 10) }
 func BenchmarkParagraphAdding100(b *testing.B) { benchmarkParagraphAdding(b, 100) }
+
+// maxSpaceStretch returns the largest width that the spaces of the justified lines of `p` are
+// widened by.
+func maxSpaceStretch(p *Paragraph) float64 {
+	var stretch float64
+	for _, line := range p.textLines[:len(p.textLines)-1] {
+		s := (p.wrapWidth - p.getTextLineWidth(line)/1000.0) / float64(strings.Count(line, " "))
+		if s > stretch {
+			stretch = s
+		}
+	}
+	return stretch
+}
+
+func TestParagraphOptimalLineBreaking(t *testing.T) {
+	text := "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat."
+
+	c := New()
+	greedy := c.NewParagraph(text)
+	greedy.SetTextAlignment(TextAlignmentJustify)
+	greedy.SetWidth(150)
+
+	optimal := c.NewParagraph(text)
+	optimal.SetTextAlignment(TextAlignmentJustify)
+	optimal.SetLineBreaking(LineBreakingOptimal)
+	optimal.SetWidth(150)
+
+	// The lines have the same words and their spaces are more even.
+	require.Equal(t, strings.Fields(text), strings.Fields(strings.Join(optimal.textLines, " ")))
+	require.Less(t, maxSpaceStretch(optimal), maxSpaceStretch(greedy))
+	require.NoError(t, c.Draw(optimal))
+
+	// Text that can't be broken into lines that fit is wrapped greedily.
+	p := c.NewParagraph("Incomprehensibilities")
+	p.SetLineBreaking(LineBreakingOptimal)
+	p.SetWidth(50)
+	greedy.SetText("Incomprehensibilities")
+	greedy.SetWidth(50)
+	require.Equal(t, greedy.textLines, p.textLines)
+}
@@ -37,8 +37,9 @@ type StyledParagraph struct {
 	lineHeight float64
 
 	// Wrapping properties.
-	enableWrap bool
-	wrapWidth  float64
+	enableWrap   bool
+	wrapWidth    float64
+	lineBreaking LineBreaking
 
 	// defaultWrap defines whether wrapping has been defined explictly or whether default behavior should
 	// be observed. Default behavior depends on context: normally wrap is expected, except for example in
@@ -135,6 +136,16 @@ func (p *StyledParagraph) AddInternalLink(text string, page int64, x, y, zoom fl
 	return p.appendChunk(chunk)
 }
 
+// AddFootnote adds the marker of footnote `note` to the paragraph. The marker is
+// the label of the footnote in a smaller font, raised above the baseline. The
+// text of the footnote is drawn at the bottom of the page that the marker is
+// drawn on or, in endnote mode, at the end of the chapter (see Footnotes).
+func (p *StyledParagraph) AddFootnote(note *Footnote) *TextChunk {
+	chunk := NewTextChunk(note.Label(), footnoteMarkerStyle(p.defaultStyle))
+	chunk.footnote = note
+	return p.appendChunk(chunk)
+}
+
 // Reset removes all the text chunks the paragraph contains.
 func (p *StyledParagraph) Reset() {
 	p.chunks = []*TextChunk{}
@@ -162,6 +173,12 @@ func (p *StyledParagraph) SetEnableWrap(enableWrap bool) {
 	p.defaultWrap = false
 }
 
+// SetLineBreaking sets the algorithm used to break the text into lines when it is wrapped
+// (LineBreakingGreedy default).
+func (p *StyledParagraph) SetLineBreaking(lineBreaking LineBreaking) {
+	p.lineBreaking = lineBreaking
+}
+
 // SetPos sets absolute positioning with specified coordinates.
 func (p *StyledParagraph) SetPos(x, y float64) {
 	p.positioning = positionAbsolute
@@ -271,27 +288,31 @@ func (p *StyledParagraph) getLineHeight(idx int) (capHeight, height float64) {
 // wrapping into account).
 func (p *StyledParagraph) getTextWidth() float64 {
 	var width float64
-	lenChunks := len(p.chunks)
+	chunks := p.fontChunks()
+	lenChunks := len(chunks)
 
-	for i, chunk := range p.chunks {
+	for i, chunk := range chunks {
 		style := &chunk.Style
 		lenRunes := len(chunk.Text)
 
+		runes := []rune(chunk.Text)
+		glyphWidths, missing := runeWidths(style, runes)
+		if missing >= 0 {
+			common.Log.Debug("Rune char metrics not found! %v\n", runes[missing])
+
+			// FIXME: return error.
+			return -1
+		}
+
+		ri := -1
 		for j, r := range chunk.Text {
+			ri++
 			// Ignore newline for this. Handles as if all in one line.
-			if r == '\u000A' { // LF
+			if r == '\u000A' || r == softHyphen { // LF or SHY
 				continue
 			}
 
-			metrics, found := style.Font.GetRuneMetrics(r)
-			if !found {
-				common.Log.Debug("Rune char metrics not found! %v\n", r)
-
-				// FIXME: return error.
-				return -1
-			}
-
-			width += style.FontSize * metrics.Wx
+			width += style.FontSize * glyphWidths[ri]
 
 			// Do not add character spacing for the last character of the line.
 			if r != ' ' && (i != lenChunks-1 || j != lenRunes-1) {
@@ -312,21 +333,24 @@ func (p *StyledParagraph) getTextLineWidth(line []*TextChunk) float64 {
 		style := &chunk.Style
 		lenRunes := len(chunk.Text)
 
+		runes := []rune(chunk.Text)
+		glyphWidths, missing := runeWidths(style, runes)
+		if missing >= 0 {
+			common.Log.Debug("Rune char metrics not found! %v\n", runes[missing])
+
+			// FIXME: return error.
+			return -1
+		}
+
+		ri := -1
 		for j, r := range chunk.Text {
+			ri++
 			// Ignore newline for this. Handles as if all in one line.
-			if r == '\u000A' { // LF
+			if r == '\u000A' || r == softHyphen { // LF or SHY
 				continue
 			}
 
-			metrics, found := style.Font.GetRuneMetrics(r)
-			if !found {
-				common.Log.Debug("Rune char metrics not found! %v\n", r)
-
-				// FIXME: return error.
-				return -1
-			}
-
-			width += style.FontSize * metrics.Wx
+			width += style.FontSize * glyphWidths[ri]
 
 			// Do not add character spacing for the last character of the line.
 			if r != ' ' && (i != lenChunks-1 || j != lenRunes-1) {
@@ -368,36 +392,56 @@ func (p *StyledParagraph) getTextHeight() float64 {
 	return height
 }
 
-// wrapText splits text into lines. It uses a simple greedy algorithm to wrap
-// fill the lines.
-// TODO: Consider the Knuth/Plass algorithm or an alternative.
+// fontChunks returns the chunks of the paragraph split into chunks whose text is drawn with a
+// single font. See TextStyle.FallbackFonts.
+func (p *StyledParagraph) fontChunks() []*TextChunk {
+	var chunks []*TextChunk
+	for _, chunk := range p.chunks {
+		chunks = append(chunks, splitByFont(chunk)...)
+	}
+	return chunks
+}
+
+// wrapChunks returns the chunks of the paragraph that are wrapped into lines: the chunks with the
+// words of the chunks whose style has a hyphenator hyphenated, split into chunks whose text is
+// drawn with a single font.
+func (p *StyledParagraph) wrapChunks() []*TextChunk {
+	var chunks []*TextChunk
+	for _, chunk := range p.chunks {
+		chunks = append(chunks, splitByFont(hyphenateChunk(chunk))...)
+	}
+	return chunks
+}
+
+// wrapText splits text into lines. By default, it uses a simple greedy
+// algorithm to fill the lines. With LineBreakingOptimal, the breaks of all
+// the lines are chosen together with the Knuth/Plass algorithm, unless the
+// text can't be broken into lines that fit.
 func (p *StyledParagraph) wrapText() error {
 	if !p.enableWrap || int(p.wrapWidth) <= 0 {
-		p.lines = [][]*TextChunk{p.chunks}
+		p.lines = [][]*TextChunk{p.fontChunks()}
+		p.reorderLines()
 		return nil
 	}
 
-	p.lines = [][]*TextChunk{}
-	var line []*TextChunk
-	var lineWidth float64
-
-	copyAnnotation := func(src *model.PdfAnnotation) *model.PdfAnnotation {
-		if src == nil {
-			return nil
+	chunks := p.wrapChunks()
+	if p.lineBreaking == LineBreakingOptimal {
+		lines, err := optimalLines(chunks, p.wrapWidth, p.alignment == TextAlignmentJustify)
+		if err != nil {
+			return err
 		}
-
-		var annotation *model.PdfAnnotation
-		switch t := src.GetContext().(type) {
-		case *model.PdfAnnotationLink:
-			if annot := copyLinkAnnotation(t); annot != nil {
-				annotation = annot.PdfAnnotation
-			}
+		if lines != nil {
+			p.lines = lines
+			p.reorderLines()
+			return nil
 		}
-
-		return annotation
 	}
 
-	for _, chunk := range p.chunks {
+	p.lines = [][]*TextChunk{}
+	var line []*TextChunk
+	var lineWidth float64
+
+	for _, chunk := range chunks {
 		style := chunk.Style
 		annotation := chunk.annotation
 
@@ -406,7 +450,13 @@ func (p *StyledParagraph) wrapText() error {
 			widths []float64
 		)
 
-		for _, r := range chunk.Text {
+		runes := []rune(chunk.Text)
+		glyphWidths, missing := runeWidths(&style, runes)
+		if missing >= 0 {
+			common.Log.Debug("Rune char metrics not found! %v\n", runes[missing])
+			return errors.New("glyph char metrics missing")
+		}
+		for i, r := range runes {
 			// newline wrapping.
 			if r == '\u000A' { // LF
 				// moves to next line.
@@ -414,6 +464,7 @@ func (p *StyledParagraph) wrapText() error {
 					Text:       strings.TrimRightFunc(string(part), unicode.IsSpace),
 					Style:      style,
 					annotation: copyAnnotation(annotation),
+					footnote:   chunk.footnote,
 				})
 				p.lines = append(p.lines, line)
 				line = nil
@@ -425,36 +476,40 @@ func (p *StyledParagraph) wrapText() error {
 			}
 			isSpace := r == ' '
 
-			metrics, found := style.Font.GetRuneMetrics(r)
-			if !found {
-				common.Log.Debug("Rune char metrics not found! %v\n", r)
-				return errors.New("glyph char metrics missing")
-			}
-			w := style.FontSize * metrics.Wx
+			w := style.FontSize * glyphWidths[i]
 
 			charWidth := w
-			if !isSpace {
+			if !isSpace && r != softHyphen {
 				charWidth = w + style.CharSpacing*1000.0
 			}
 
 			if lineWidth+w > p.wrapWidth*1000.0 {
 				// Goes out of bounds: Wrap.
-				// Breaks on the character.
-				// TODO: when goes outside: back up to next space,
+				// Back up to the last space of the chunk, or to the last
+				// soft hyphen that the line fits with a hyphen at,
 				// otherwise break on the character.
 				idx := -1
 				if !isSpace {
+					lw := lineWidth
 					for j := len(part) - 1; j >= 0; j-- {
+						lw -= widths[j]
 						if part[j] == ' ' {
 							idx = j
 							break
 						}
+						if part[j] == softHyphen && j > 0 && lw+hyphenWidth(&style) <= p.wrapWidth*1000.0 {
+							idx = j
+							break
+						}
 					}
 				}
 
 				text := string(part)
 				if idx >= 0 {
 					text = string(part[0 : idx+1])
+					if part[idx] == softHyphen {
+						text = string(part[0:idx]) + "-"
+					}
 
 					part = part[idx+1:]
 					part = append(part, r)
@@ -481,6 +536,7 @@ func (p *StyledParagraph) wrapText() error {
 					Text:       strings.TrimRightFunc(string(text), unicode.IsSpace),
 					Style:      style,
 					annotation: copyAnnotation(annotation),
+					footnote:   chunk.footnote,
 				})
 				p.lines = append(p.lines, line)
 				line = []*TextChunk{}
@@ -496,6 +552,7 @@ func (p *StyledParagraph) wrapText() error {
 				Text:       string(part),
 				Style:      style,
 				annotation: copyAnnotation(annotation),
+				footnote:   chunk.footnote,
 			})
 		}
 	}
@@ -503,6 +560,7 @@ func (p *StyledParagraph) wrapText() error {
 	if len(line) > 0 {
 		p.lines = append(p.lines, line)
 	}
+	p.reorderLines()
 
 	return nil
 }
@@ -536,6 +594,13 @@ func (p *StyledParagraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawCon
 		p.beforeRender(p, ctx)
 	}
 
+	// Update the footnote markers to the numbering style of the footnotes.
+	for _, chunk := range p.chunks {
+		if chunk.footnote != nil {
+			chunk.Text = chunk.footnote.Label()
+		}
+	}
+
 	// Wrap paragraph chunks into lines, based on the available context width.
 	if err := p.wrapText(); err != nil {
 		return nil, ctx, err
@@ -566,6 +631,7 @@ func (p *StyledParagraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawCon
 		newCtx.Y = ctx.Margins.top
 		newCtx.X = ctx.Margins.left + p.margins.left
 		newCtx.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom - p.margins.bottom
+		newCtx.Height = ctx.footnotes.fitHeight(newCtx, newCtx.Height)
 		newCtx.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - p.margins.left - p.margins.right
 		ctx = newCtx
 	}
@@ -605,6 +671,7 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 	var yOffset float64
 	var nextBlockLines [][]*TextChunk
 	var totalHeight float64
+	placedNotes := map[*Footnote]bool{}
 	for i, line := range lines {
 		var fontLine []core.PdfObjectName
 		var height float64
@@ -636,6 +703,37 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			break
 		}
 
+		// Check if the line fits above the footnotes of the page, placing
+		// the footnotes it references.
+		if relativePos && ctx.footnotes != nil {
+			var notes []*Footnote
+			for _, chunk := range line {
+				if note := chunk.footnote; note != nil && !placedNotes[note] {
+					placedNotes[note] = true
+					notes = append(notes, note)
+				}
+			}
+
+			if ctx.footnotes.endnotes {
+				ctx.footnotes.collect(notes)
+			} else {
+				// The first line on a page is drawn even if it doesn't fit.
+				force := i == 0 && ctx.Y <= ctx.Margins.top
+				bottom := ctx.Y + totalHeight + height
+				if len(notes) > 0 {
+					if !ctx.footnotes.place(notes, ctx.Page, bottom, ctx.PageWidth, ctx.PageHeight, force) {
+						nextBlockLines = lines[i:]
+						lines = lines[:i]
+						break
+					}
+				} else if !force && ctx.footnotes.fitHeight(ctx, ctx.Height) < totalHeight+height {
+					nextBlockLines = lines[i:]
+					lines = lines[:i]
+					break
+				}
+			}
+		}
+
 		totalHeight += height
 		fonts = append(fonts, fontLine)
 	}
@@ -654,11 +752,20 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 	cc.Add_BT()
 
 	currY := yPos
+	// The leading set by the last TL operator and the height of the
+	// previous line.
+	var leading, prevHeight float64
 	for idx, line := range lines {
 		currX := ctx.X
 
 		if idx != 0 {
-			// Move to next line if not first.
+			// Move to next line if not first. The leading is the height of
+			// the previous line, which may have chunks with smaller fonts
+			// after its largest one, e.g. footnote markers.
+			if leading != prevHeight {
+				cc.Add_TL(prevHeight)
+				leading = prevHeight
+			}
 			cc.Add_Tstar()
 		}
 
@@ -688,22 +795,24 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			var chunkSpaces uint
 			var chunkWidth float64
 			lenChunk := len(chunk.Text)
+			runes := []rune(chunk.Text)
+			glyphWidths, missing := runeWidths(style, runes)
+			if missing >= 0 {
+				common.Log.Debug("Unsupported rune %v in font\n", runes[missing])
+				return ctx, nil, errors.New("unsupported text glyph")
+			}
+			ri := -1
 			for i, r := range chunk.Text {
+				ri++
 				if r == ' ' {
 					chunkSpaces++
 					continue
 				}
-				if r == '\u000A' { // LF
+				if r == '\u000A' || r == softHyphen { // LF or SHY
 					continue
 				}
 
-				metrics, found := style.Font.GetRuneMetrics(r)
-				if !found {
-					common.Log.Debug("Unsupported rune %v in font\n", r)
-					return ctx, nil, errors.New("unsupported text glyph")
-				}
-
-				chunkWidth += style.FontSize * metrics.Wx
+				chunkWidth += style.FontSize * glyphWidths[ri]
 
 				// Do not add character spacing for the last character of the line.
 				if i != lenChunk-1 {
@@ -748,6 +857,7 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			cc.Add_Tf(defaultFontName, defaultFontSize).
 				Add_TL(defaultFontSize * p.lineHeight).
 				Add_TJ(objs...)
+			leading = defaultFontSize * p.lineHeight
 		}
 
 		// Render line text chunks.
@@ -764,6 +874,11 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			// Set chunk character spacing.
 			cc.Add_Tc(style.CharSpacing)
 
+			// Set chunk text rise.
+			if style.TextRise != 0 {
+				cc.Add_Ts(style.TextRise)
+			}
+
 			if p.alignment != TextAlignmentJustify || isLastLine {
 				spaceMetrics, found := style.Font.GetRuneMetrics(' ')
 				if !found {
@@ -775,41 +890,49 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 				spaceWidth = spaceMetrics.Wx
 			}
 			enc := style.Font.Encoder()
+			shape := style.Font.CanShapeText()
 
-			var encStr []byte
-			for _, rn := range chunk.Text {
-				if r == '\u000A' { // LF
-					continue
-				}
-				if rn == ' ' {
-					if len(encStr) > 0 {
-						cc.Add_rg(r, g, b).
-							Add_Tf(fonts[idx][k], style.FontSize).
-							Add_TL(style.FontSize * p.lineHeight).
-							Add_TJ([]core.PdfObject{core.MakeStringFromBytes(encStr)}...)
-
-						encStr = nil
-					}
-
+			for _, word := range chunkWords(chunk) {
+				if word[0] == ' ' {
 					cc.Add_Tf(fontName, fontSize).
 						Add_TL(fontSize * p.lineHeight).
 						Add_TJ([]core.PdfObject{core.MakeFloat(-spaceWidth)}...)
+					leading = fontSize * p.lineHeight
 
 					chunkWidths[k] += spaceWidth * fontSize
-				} else {
+					continue
+				}
+
+				if shape {
+					cc.Add_rg(r, g, b).
+						Add_Tf(fonts[idx][k], style.FontSize).
+						Add_TL(style.FontSize * p.lineHeight)
+					leading = style.FontSize * p.lineHeight
+					addShapedText(cc, style.Font, word, chunk.rtl, style.FontSize)
+					continue
+				}
+
+				if chunk.rtl {
+					for i, j := 0, len(word)-1; i < j; i, j = i+1, j-1 {
+						word[i], word[j] = word[j], word[i]
+					}
+				}
+				var encStr []byte
+				for _, rn := range word {
 					if _, ok := enc.RuneToCharcode(rn); !ok {
 						common.Log.Debug("unsupported rune in text encoding: %#x (%c)", rn, rn)
 						continue
 					}
 					encStr = append(encStr, enc.Encode(string(rn))...)
 				}
-			}
 
-			if len(encStr) > 0 {
-				cc.Add_rg(r, g, b).
-					Add_Tf(fonts[idx][k], style.FontSize).
-					Add_TL(style.FontSize * p.lineHeight).
-					Add_TJ([]core.PdfObject{core.MakeStringFromBytes(encStr)}...)
+				if len(encStr) > 0 {
+					cc.Add_rg(r, g, b).
+						Add_Tf(fonts[idx][k], style.FontSize).
+						Add_TL(style.FontSize * p.lineHeight).
+						Add_TJ([]core.PdfObject{core.MakeStringFromBytes(encStr)}...)
+					leading = style.FontSize * p.lineHeight
+				}
 			}
 
 			chunkWidth := chunkWidths[k] / 1000.0
@@ -875,9 +998,15 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 
 			// Reset character spacing.
 			cc.Add_Tc(0)
+
+			// Reset text rise.
+			if style.TextRise != 0 {
+				cc.Add_Ts(0)
+			}
 		}
 
 		currY -= height
+		prevHeight = height
 	}
 	cc.Add_ET()
 	cc.Add_Q()
@@ -891,6 +1020,7 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 		pHeight := totalHeight + p.margins.bottom
 		ctx.Y += pHeight
 		ctx.Height -= pHeight
+		ctx.Height = ctx.footnotes.fitHeight(ctx, ctx.Height)
 
 		// If the division is inline, calculate context new X coordinate.
 		if ctx.Inline {
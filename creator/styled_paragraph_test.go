@@ -6,8 +6,12 @@
 package creator
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
+	"github.com/gnaoh1379/unipdf/extractor"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
 	"github.com/gnaoh1379/unipdf/model"
 	"github.com/stretchr/testify/require"
 )
@@ -897,3 +901,143 @@ func TestStyledParagraphMultiBlock(t *testing.T) {
 	// Write output file.
 	testWriteAndRender(t, c, "styled_paragraph_multiblock.pdf")
 }
+
+// TestStyledParagraphShaping tests that text in complex scripts drawn with a composite font is
+// shaped and reordered, and that the original text is extracted.
+func TestStyledParagraphShaping(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+
+	c := New()
+	c.EnableFontSubsetting(font)
+	texts := []string{
+		// Reordered matra, conjunct, half form and reph.
+		"कि क्ष स्ति शर्म",
+		"Hello שלום world",
+		"שלום עולם",
+	}
+	for _, text := range texts {
+		// The text of each paragraph is extracted from its own page as the text extracted
+		// without a license is truncated.
+		c.NewPage()
+		p := c.NewStyledParagraph()
+		p.SetText(text).Style.Font = font
+		require.NoError(t, c.Draw(p))
+	}
+
+	// The Hebrew word of a left-to-right line is drawn in its own right-to-left chunk.
+	p := c.NewStyledParagraph()
+	p.SetText(texts[1]).Style.Font = font
+	require.NoError(t, p.wrapText())
+	require.Len(t, p.lines, 1)
+	var pieces []string
+	for _, chunk := range p.lines[0] {
+		pieces = append(pieces, chunk.Text)
+		require.Equal(t, chunk.Text == "שלום", chunk.rtl, chunk.Text)
+	}
+	require.Equal(t, []string{"Hello ", "שלום", " world"}, pieces)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	for i, text := range texts {
+		page, err := r.GetPage(i + 1)
+		require.NoError(t, err)
+		e, err := extractor.New(page)
+		require.NoError(t, err)
+		extracted, err := e.ExtractText()
+		require.NoError(t, err)
+		// Trim off the license watermark.
+		extracted = strings.SplitN(extracted, "\n", 2)[0]
+		require.Equal(t, text, extracted)
+	}
+}
+
+// TestStyledParagraphFallbackFonts tests that the runes of text that the font of its style doesn't
+// have glyphs for are drawn with the style's fallback fonts and are extracted.
+func TestStyledParagraphFallbackFonts(t *testing.T) {
+	roboto, err := model.NewCompositePdfFontFromTTFFile(testRobotoRegularTTFFile)
+	require.NoError(t, err)
+	freeSans, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+	helvetica := newStandard14Font(t, model.HelveticaName)
+
+	c := New()
+	c.EnableFontSubsetting(roboto)
+	c.EnableFontSubsetting(freeSans)
+	// Roboto has Cyrillic but not Armenian glyphs, FreeSans has both.
+	text := "Hello Привет Բարեւ"
+
+	// Spaces are drawn with the font of the word before them.
+	p := c.NewStyledParagraph()
+	chunk := p.Append(text)
+	chunk.Style.Font = helvetica
+	chunk.Style.FallbackFonts = []*model.PdfFont{roboto, freeSans}
+	require.NoError(t, p.wrapText())
+	require.Len(t, p.lines, 1)
+	var pieces []string
+	var fonts []*model.PdfFont
+	for _, chunk := range p.lines[0] {
+		pieces = append(pieces, chunk.Text)
+		fonts = append(fonts, chunk.Style.Font)
+	}
+	require.Equal(t, []string{"Hello ", "Привет ", "Բարեւ"}, pieces)
+	require.Equal(t, []*model.PdfFont{helvetica, roboto, freeSans}, fonts)
+	require.NoError(t, c.Draw(p))
+
+	c.NewPage()
+	para := c.NewParagraph(text)
+	para.SetFont(helvetica)
+	para.SetFallbackFonts(roboto, freeSans)
+	require.NoError(t, c.Draw(para))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+
+	// The fallback fonts are subset to the runes they are used for.
+	enc, ok := roboto.Encoder().(*textencoding.TrueTypeFontEncoder)
+	require.True(t, ok)
+	require.ElementsMatch(t, []rune("Привет "), enc.RegisteredRunes())
+
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	for i := 1; i <= 2; i++ {
+		page, err := r.GetPage(i)
+		require.NoError(t, err)
+		e, err := extractor.New(page)
+		require.NoError(t, err)
+		extracted, err := e.ExtractText()
+		require.NoError(t, err)
+		// Trim off the license watermark.
+		extracted = strings.SplitN(extracted, "\n", 2)[0]
+		require.Equal(t, text, extracted, "page %d", i)
+	}
+}
+
+func TestStyledParagraphHyphenation(t *testing.T) {
+	h, err := NewHyphenator(testHyphenationPatterns, nil)
+	require.NoError(t, err)
+
+	c := New()
+	for _, lineBreaking := range []LineBreaking{LineBreakingGreedy, LineBreakingOptimal} {
+		p := c.NewStyledParagraph()
+		p.SetLineBreaking(lineBreaking)
+		p.Append("The ")
+		chunk := p.Append("hyphenation")
+		chunk.Style.Hyphenator = h
+		p.Append(" of words")
+		p.SetWidth(40)
+
+		var lines []string
+		for _, line := range p.lines {
+			var text string
+			for _, chunk := range line {
+				text += chunk.Text
+			}
+			lines = append(lines, strings.Replace(text, string(softHyphen), "", -1))
+		}
+		require.Equal(t, []string{"The hy-", "phen-", "ation of", "words"}, lines, "%d", lineBreaking)
+		require.NoError(t, c.Draw(p))
+	}
+}
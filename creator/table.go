@@ -272,6 +272,7 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	ulY := ctx.Y
 
 	ctx.Height = ctx.PageHeight - ctx.Y - ctx.Margins.bottom
+	ctx.Height = ctx.footnotes.fitHeight(ctx, ctx.Height)
 	origHeight := ctx.Height
 
 	// Start row keeps track of starting row (wraps to 0 on new page).
@@ -449,6 +450,8 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 
 			ctx.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom
 			ctx.Page++
+			ctx.Y = ulY
+			ctx.Height = ctx.footnotes.fitHeight(ctx, ctx.Height)
 			origHeight = ctx.Height
 
 			startrow = cell.row - 1
@@ -29,6 +29,14 @@ type TextChunk struct {
 	// Internally used in order to skip processing the annotation
 	// if it has already been processed by the parent component.
 	annotationProcessed bool
+
+	// Internally used to mark chunks of right-to-left text created by
+	// the reordering of styled paragraph lines. Their text is in logical
+	// order and is drawn in reverse.
+	rtl bool
+
+	// The footnote that the chunk is the marker of.
+	footnote *Footnote
 }
 
 // NewTextChunk returns a new text chunk instance.
@@ -45,8 +53,26 @@ func (tc *TextChunk) SetAnnotation(annotation *model.PdfAnnotation) {
 }
 
 // Wrap wraps the text of the chunk into lines based on its style and the
-// specified width.
+// specified width. Lines can be broken at the soft hyphens (U+00AD) of the
+// text, which are shown as hyphens at the end of the lines broken at them
+// and are removed elsewhere. If the style has a hyphenator, the words of the
+// text are hyphenated before it is wrapped.
 func (tc *TextChunk) Wrap(width float64) ([]string, error) {
+	lines, err := tc.wrap(width)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, line := range lines {
+		lines[i] = softHyphenText(line)
+	}
+	return lines, nil
+}
+
+// wrap wraps the text of the chunk into lines like Wrap, except that the soft
+// hyphens of the lines are kept. The lines that are broken at soft hyphens end
+// with them.
+func (tc *TextChunk) wrap(width float64) ([]string, error) {
 	if int(width) <= 0 {
 		return []string{tc.Text}, nil
 	}
@@ -57,9 +83,18 @@ func (tc *TextChunk) Wrap(width float64) ([]string, error) {
 	var widths []float64
 
 	style := tc.Style
-	runes := []rune(tc.Text)
+	runes := []rune(hyphenateChunk(tc).Text)
+	glyphWidths, missing := runeWidths(&style, runes)
+	if missing >= 0 {
+		r := runes[missing]
+		common.Log.Debug("ERROR: Rune char metrics not found! rune=0x%04x=%c font=%s %#q",
+			r, r, style.Font.BaseFont(), style.Font.Subtype())
+		common.Log.Trace("Font: %#v", style.Font)
+		common.Log.Trace("Encoder: %#v", style.Font.Encoder())
+		return nil, errors.New("glyph char metrics missing")
+	}
 
-	for _, r := range runes {
+	for i, r := range runes {
 		// Move to the next line due to newline wrapping (LF).
 		if r == '\u000A' {
 			lines = append(lines, strings.TrimRightFunc(string(line), unicode.IsSpace)+string(r))
@@ -70,30 +105,30 @@ func (tc *TextChunk) Wrap(width float64) ([]string, error) {
 		}
 		isSpace := r == ' '
 
-		metrics, found := style.Font.GetRuneMetrics(r)
-		if !found {
-			common.Log.Debug("ERROR: Rune char metrics not found! rune=0x%04x=%c font=%s %#q",
-				r, r, style.Font.BaseFont(), style.Font.Subtype())
-			common.Log.Trace("Font: %#v", style.Font)
-			common.Log.Trace("Encoder: %#v", style.Font.Encoder())
-			return nil, errors.New("glyph char metrics missing")
-		}
-		w := style.FontSize * metrics.Wx
+		w := style.FontSize * glyphWidths[i]
 
 		charWidth := w
-		if !isSpace {
+		if !isSpace && r != softHyphen {
 			charWidth = w + style.CharSpacing*1000.0
 		}
 
 		if lineWidth+w > width*1000.0 {
-			// Goes out of bounds. Break on the character.
+			// Goes out of bounds. Back up to the last space, or to the
+			// last soft hyphen that the line fits with a hyphen at, or
+			// break on the character.
 			idx := -1
 			if !isSpace {
+				lw := lineWidth
 				for i := len(line) - 1; i >= 0; i-- {
+					lw -= widths[i]
 					if line[i] == ' ' {
 						idx = i
 						break
 					}
+					if line[i] == softHyphen && i > 0 && lw+hyphenWidth(&style) <= width*1000.0 {
+						idx = i
+						break
+					}
 				}
 			}
 
@@ -142,7 +177,7 @@ func (tc *TextChunk) Wrap(width float64) ([]string, error) {
 // line height values, the passed in height must be divided by the line height:
 // height = height / lineHeight
 func (tc *TextChunk) Fit(width, height float64) (*TextChunk, error) {
-	lines, err := tc.Wrap(width)
+	lines, err := tc.wrap(width)
 	if err != nil {
 		return nil, err
 	}
@@ -151,13 +186,37 @@ func (tc *TextChunk) Fit(width, height float64) (*TextChunk, error) {
 	if fit >= len(lines) {
 		return nil, nil
 	}
-	lf := "\u000A"
-	tc.Text = strings.Replace(strings.Join(lines[:fit], " "), lf+" ", lf, -1)
 
-	remainder := strings.Replace(strings.Join(lines[fit:], " "), lf+" ", lf, -1)
+	// The hyphen of the last line is kept if it is broken at a soft hyphen.
+	fitLines := append([]string{}, lines[:fit]...)
+	if fit > 0 {
+		if last := fitLines[fit-1]; strings.HasSuffix(last, string(softHyphen)) {
+			fitLines[fit-1] = strings.TrimSuffix(last, string(softHyphen)) + "-"
+		}
+	}
+	tc.Text = joinWrappedLines(fitLines)
+
+	remainder := joinWrappedLines(lines[fit:])
 	return NewTextChunk(remainder, tc.Style), nil
 }
 
+// joinWrappedLines joins lines of text wrapped by TextChunk.wrap back into
+// text. The lines are separated by spaces, except after line feeds and the
+// soft hyphens that lines are broken at.
+func joinWrappedLines(lines []string) string {
+	var text strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			if !strings.HasSuffix(prev, "\u000A") && !strings.HasSuffix(prev, string(softHyphen)) {
+				text.WriteByte(' ')
+			}
+		}
+		text.WriteString(line)
+	}
+	return text.String()
+}
+
 // newExternalLinkAnnotation returns a new external link annotation.
 func newExternalLinkAnnotation(url string) *model.PdfAnnotation {
 	annotation := model.NewPdfAnnotationLink()
@@ -200,6 +259,24 @@ func newInternalLinkAnnotation(page int64, x, y, zoom float64) *model.PdfAnnotat
 	return annotation.PdfAnnotation
 }
 
+// copyAnnotation returns a copy of annotation `src` for text chunks created from the chunk it is
+// set on. Only link annotations are copied, nil is returned for other annotations.
+func copyAnnotation(src *model.PdfAnnotation) *model.PdfAnnotation {
+	if src == nil {
+		return nil
+	}
+
+	var annotation *model.PdfAnnotation
+	switch t := src.GetContext().(type) {
+	case *model.PdfAnnotationLink:
+		if annot := copyLinkAnnotation(t); annot != nil {
+			annotation = annot.PdfAnnotation
+		}
+	}
+
+	return annotation
+}
+
 // copyLinkAnnotation returns a new link annotation based on an existing one.
 func copyLinkAnnotation(link *model.PdfAnnotationLink) *model.PdfAnnotationLink {
 	if link == nil {
@@ -128,3 +128,32 @@ func TestTextChunkFit(t *testing.T) {
 		tc = tc2
 	}
 }
+
+func TestTextChunkWrapSoftHyphens(t *testing.T) {
+	style := TextStyle{
+		Font:     model.DefaultFont(),
+		FontSize: 10,
+	}
+	shy := string(softHyphen)
+
+	// Lines are broken at soft hyphens, which are removed elsewhere.
+	tc := NewTextChunk("The hy"+shy+"phen"+shy+"ation of words", style)
+	lines, err := tc.Wrap(60)
+	require.NoError(t, err)
+	require.Equal(t, []string{"The hyphen-", "ation of", "words"}, lines)
+
+	// The words of text whose style has a hyphenator are hyphenated.
+	h, err := NewHyphenator(testHyphenationPatterns, nil)
+	require.NoError(t, err)
+	style.Hyphenator = h
+	tc = NewTextChunk("The hyphenation of words", style)
+	lines, err = tc.Wrap(40)
+	require.NoError(t, err)
+	require.Equal(t, []string{"The hy-", "phen-", "ation of", "words"}, lines)
+
+	// The fitted text keeps the hyphen it is broken at.
+	remainder, err := tc.Fit(40, 10)
+	require.NoError(t, err)
+	require.Equal(t, "The hy-", tc.Text)
+	require.Equal(t, "phen"+shy+"ation of words", remainder.Text)
+}
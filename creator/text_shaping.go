@@ -0,0 +1,292 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"sort"
+
+	"github.com/gnaoh1379/unipdf/contentstream"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/bidi"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// Text in fonts that can shape text (see model.PdfFont.CanShapeText) is shaped a word at a time, so
+// that ligatures, conjuncts, joining forms, kerning and mark positioning are applied, and styled
+// paragraph lines that contain right-to-left text are reordered with the Unicode Bidirectional
+// Algorithm before they are drawn.
+
+// shapedRuneWidths returns the widths in glyph space units of `runes` drawn in `font` when the words
+// in `runes` are shaped. The advance of the glyphs of a cluster of runes, e.g. of a ligature, is the
+// width of the cluster's first rune and the other runes have zero width. Line feeds and soft
+// hyphens have zero width and words are shaped in parts between their soft hyphens.
+// It returns nil if `font` doesn't shape text or doesn't have glyphs for all the runes, which are
+// then measured one by one.
+func shapedRuneWidths(font *model.PdfFont, runes []rune) []float64 {
+	if !font.CanShapeText() {
+		return nil
+	}
+	for _, r := range runes {
+		if r == '\u000A' || r == softHyphen { // LF or SHY
+			continue
+		}
+		if _, found := font.GetRuneMetrics(r); !found {
+			return nil
+		}
+	}
+
+	widths := make([]float64, len(runes))
+	for i := 0; i < len(runes); {
+		if r := runes[i]; isWordBreak(r) {
+			if metrics, found := font.GetRuneMetrics(r); found && r == ' ' {
+				widths[i] = metrics.Wx
+			}
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && !isWordBreak(runes[j]) {
+			j++
+		}
+		glyphs, _ := font.ShapeText(runes[i:j], false)
+		for _, g := range glyphs {
+			widths[i+g.Cluster] += g.XAdvance
+		}
+		i = j
+	}
+	return widths
+}
+
+// isWordBreak returns true if `r` ends the words that are shaped: a space, a line feed or a soft
+// hyphen.
+func isWordBreak(r rune) bool {
+	return r == ' ' || r == '\u000A' || r == softHyphen
+}
+
+// chunkWords splits the text of `chunk` into words and single spaces in the order they are drawn.
+// Line feeds and soft hyphens are not drawn and are left out. Words are split at soft hyphens.
+func chunkWords(chunk *TextChunk) [][]rune {
+	var words [][]rune
+	var word []rune
+	for _, r := range chunk.Text {
+		if r == '\u000A' { // LF
+			continue
+		}
+		if r == softHyphen {
+			if len(word) > 0 {
+				words = append(words, word)
+				word = nil
+			}
+			continue
+		}
+		if r != ' ' {
+			word = append(word, r)
+			continue
+		}
+		if len(word) > 0 {
+			words = append(words, word)
+			word = nil
+		}
+		words = append(words, []rune{r})
+	}
+	if len(word) > 0 {
+		words = append(words, word)
+	}
+	if chunk.rtl {
+		for i, j := 0, len(words)-1; i < j; i, j = i+1, j-1 {
+			words[i], words[j] = words[j], words[i]
+		}
+	}
+	return words
+}
+
+// addShapedText adds the operations that draw `text`, shaped in `font` with size `fontSize`, to
+// `cc`. `rtl` is true if `text` is written right-to-left. The glyphs of clusters whose ToUnicode
+// text differs from the runes they are drawn for, e.g. the reordered glyphs of Indic syllables, are
+// marked with their runes (ActualText) so that the text is extracted correctly.
+func addShapedText(cc *contentstream.ContentCreator, font *model.PdfFont, text []rune, rtl bool,
+	fontSize float64) {
+	glyphs, ok := font.ShapeText(text, rtl)
+	if !ok {
+		return
+	}
+
+	// The runes of cluster c are text[c:clusterEnd[c]].
+	var clusters []int
+	clusterEnd := map[int]int{}
+	for _, g := range glyphs {
+		if _, ok := clusterEnd[g.Cluster]; !ok {
+			clusters = append(clusters, g.Cluster)
+			clusterEnd[g.Cluster] = len(text)
+		}
+	}
+	sort.Ints(clusters)
+	for i := 0; i < len(clusters)-1; i++ {
+		clusterEnd[clusters[i]] = clusters[i+1]
+	}
+
+	var (
+		arr    []core.PdfObject
+		adjust float64 // Pending pen movement in glyph space units.
+		rise   float64
+	)
+	flush := func() {
+		if adjust != 0 {
+			arr = append(arr, core.MakeFloat(-adjust))
+			adjust = 0
+		}
+		if len(arr) > 0 {
+			cc.Add_TJ(arr...)
+			arr = nil
+		}
+	}
+	for i := 0; i < len(glyphs); {
+		cluster := glyphs[i].Cluster
+		j := i + 1
+		for j < len(glyphs) && glyphs[j].Cluster == cluster {
+			j++
+		}
+
+		// The extracted text of the cluster is the text of its glyphs in logical order.
+		var extracted string
+		for k := i; k < j; k++ {
+			if rtl {
+				extracted += glyphs[i+j-1-k].Text
+			} else {
+				extracted += glyphs[k].Text
+			}
+		}
+		actual := string(text[cluster:clusterEnd[cluster]])
+		if extracted != actual {
+			flush()
+			props := core.MakeDict()
+			props.Set("ActualText", core.MakeEncodedString(actual, true))
+			cc.Add_BDC("Span", props)
+		}
+
+		for _, g := range glyphs[i:j] {
+			if g.YOffset != rise {
+				flush()
+				rise = g.YOffset
+				cc.Add_Ts(rise * fontSize / 1000.0)
+			}
+			adjust += g.XOffset
+			if adjust != 0 {
+				arr = append(arr, core.MakeFloat(-adjust))
+			}
+			arr = append(arr, core.MakeStringFromBytes([]byte{byte(g.Code >> 8), byte(g.Code)}))
+			adjust = g.XAdvance - g.Width - g.XOffset
+		}
+
+		if extracted != actual {
+			flush()
+			cc.Add_EMC()
+		}
+		i = j
+	}
+	flush()
+	if rise != 0 {
+		cc.Add_Ts(0)
+	}
+}
+
+// paragraphRTL returns true if the base direction of the text of `chunks` is right-to-left, which
+// is the case if its first strongly directional character is right-to-left.
+func paragraphRTL(chunks []*TextChunk) bool {
+	for _, chunk := range chunks {
+		for _, r := range chunk.Text {
+			switch bidi.LookupClass(r) {
+			case bidi.L:
+				return false
+			case bidi.R, bidi.AL:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reorderLines reorders the chunks of the paragraph's lines that contain right-to-left text into the
+// order they are drawn in. The chunks are split into pieces of text in a single direction. The text
+// of each piece remains in logical order and right-to-left pieces are reversed when they are drawn.
+func (p *StyledParagraph) reorderLines() {
+	hasRTL := false
+	for _, chunk := range p.chunks {
+		for _, r := range chunk.Text {
+			if c := bidi.LookupClass(r); c == bidi.R || c == bidi.AL {
+				hasRTL = true
+				break
+			}
+		}
+	}
+	if !hasRTL {
+		return
+	}
+
+	rtl := paragraphRTL(p.chunks)
+	for i, line := range p.lines {
+		p.lines[i] = reorderLine(line, rtl)
+	}
+}
+
+// reorderLine returns the chunks of `line`, whose base direction is right-to-left if `rtl` is true,
+// split into pieces in a single direction in the order they are drawn. If a chunk with an annotation
+// is split, its pieces have copies of the annotation.
+func reorderLine(line []*TextChunk, rtl bool) []*TextChunk {
+	var runes []rune
+	var owners []int
+	var classes []bidi.Class
+	for k, chunk := range line {
+		for _, r := range chunk.Text {
+			runes = append(runes, r)
+			owners = append(owners, k)
+			classes = append(classes, bidi.LookupClass(r))
+		}
+	}
+	levels := bidi.Levels(classes, rtl)
+	reorder := false
+	for _, level := range levels {
+		if level > 0 {
+			reorder = true
+			break
+		}
+	}
+	if !reorder {
+		return line
+	}
+
+	order := bidi.Order(levels)
+	hasPiece := make([]bool, len(line))
+	var pieces []*TextChunk
+	for i := 0; i < len(order); {
+		k := order[i]
+		step := 1
+		if levels[k]%2 == 1 {
+			step = -1
+		}
+		j := i + 1
+		for j < len(order) && order[j] == order[j-1]+step && owners[order[j]] == owners[k] &&
+			levels[order[j]] == levels[k] {
+			j++
+		}
+		lo, hi := order[i], order[j-1]
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+
+		owner := owners[k]
+		piece := *line[owner]
+		piece.Text = string(runes[lo : hi+1])
+		piece.rtl = step < 0
+		if hasPiece[owner] {
+			piece.annotation = copyAnnotation(piece.annotation)
+		}
+		hasPiece[owner] = true
+		pieces = append(pieces, &piece)
+		i = j
+	}
+	return pieces
+}
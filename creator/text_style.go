@@ -17,6 +17,13 @@ type TextStyle struct {
 	// The font the text will use.
 	Font *model.PdfFont
 
+	// The fonts used, in order, for the runes that Font doesn't have glyphs
+	// for. Each rune is drawn with the first of Font and FallbackFonts that
+	// has a glyph for it. When font subsetting is enabled for the fonts with
+	// Creator.EnableFontSubsetting, each font only embeds the glyphs it is
+	// used for.
+	FallbackFonts []*model.PdfFont
+
 	// The size of the font.
 	FontSize float64
 
@@ -25,6 +32,15 @@ type TextStyle struct {
 
 	// The rendering mode.
 	RenderingMode TextRenderingMode
+
+	// The distance that the baseline of the text is raised by (positive)
+	// or lowered by (negative), e.g. for superscripts.
+	TextRise float64
+
+	// The hyphenator used to hyphenate the words of the text when it is
+	// wrapped into lines. Lines can also be broken at the soft hyphens
+	// (U+00AD) of the text, whether or not the style has a hyphenator.
+	Hyphenator *Hyphenator
 }
 
 // newTextStyle creates a new text style object using the specified font.
@@ -8,6 +8,8 @@ package extractor
 import (
 	"fmt"
 
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
 	"github.com/gnaoh1379/unipdf/model"
 )
 
@@ -17,6 +19,10 @@ type Extractor struct {
 	contents  string
 	resources *model.PdfPageResources
 	mediaBox  model.PdfRectangle
+	cropBox   model.PdfRectangle // The visible region of the page. The media box if not set.
+
+	options    Options
+	structPage *structPage // The part of Options.StructTree on the page.
 
 	// fontCache is a simple LRU cache that is used to prevent redundant constructions of PdfFonts
 	// from PDF objects. NOTE: This is not a conventional glyph cache. It only caches PdfFonts.
@@ -33,8 +39,35 @@ type Extractor struct {
 	textCount int
 }
 
+// Options contains options for controlling text extraction.
+type Options struct {
+	// DropInvisibleText removes the text that can't be seen on the page from the extracted text.
+	// By default, invisible text is extracted and the Visibility field of its TextMarks describes
+	// why it can't be seen.
+	DropInvisibleText bool
+	// StructTree is the logical structure tree of the document containing the page. If it is set,
+	// the StructType fields of TextMarks are the types of the structure elements containing them.
+	// See NewStructTree.
+	StructTree *StructTree
+	// StructureOrder extracts text in the logical order of StructTree instead of the reading order
+	// found from the positions of the text on the page. Text that isn't in the structure tree
+	// follows the structured text. It has no effect if StructTree is not set.
+	StructureOrder bool
+	// GlyphNameFallback maps the character codes of fonts that have no text in the fonts'
+	// ToUnicode CMaps and encodings to the text of their glyph names in the embedded font
+	// programs. The glyph names of fonts with guessed encodings and no ToUnicode CMaps are
+	// preferred over their encodings. See PageText.FontDiagnostics.
+	GlyphNameFallback bool
+}
+
 // New returns an Extractor instance for extracting content from the input PDF page.
 func New(page *model.PdfPage) (*Extractor, error) {
+	return NewWithOptions(page, nil)
+}
+
+// NewWithOptions returns an Extractor instance for extracting content from the input PDF page
+// with `options`. The options parameter can be nil for the default options.
+func NewWithOptions(page *model.PdfPage, options *Options) (*Extractor, error) {
 	contents, err := page.GetAllContentStreams()
 	if err != nil {
 		return nil, err
@@ -56,9 +89,62 @@ func New(page *model.PdfPage) (*Extractor, error) {
 		fontCache:   map[string]fontEntry{},
 		formResults: map[string]textResult{},
 	}
+	if cropBox := getCropBox(page); cropBox != nil {
+		e.cropBox = *cropBox
+	}
+	if options != nil {
+		e.options = *options
+	}
+	if objNum, ok := pageObjectNumber(page); ok {
+		e.structPage = e.options.StructTree.page(objNum)
+	}
 	return e, nil
 }
 
+// getCropBox returns the crop box of `page`, which may be inherited from its ancestors in the page
+// tree, or nil if it doesn't have one.
+func getCropBox(page *model.PdfPage) *model.PdfRectangle {
+	if page.CropBox != nil {
+		return page.CropBox
+	}
+	for node := page.Parent; node != nil; {
+		dict, ok := core.GetDict(node)
+		if !ok {
+			return nil
+		}
+		if arr, ok := core.GetArray(dict.Get("CropBox")); ok {
+			rect, err := model.NewPdfRectangle(*arr)
+			if err != nil {
+				common.Log.Debug("ERROR: invalid CropBox. err=%v", err)
+				return nil
+			}
+			return rect
+		}
+		node = dict.Get("Parent")
+	}
+	return nil
+}
+
+// visibleBox returns the region of the page that is visible. This is the crop box clipped to the
+// media box.
+func (e *Extractor) visibleBox() model.PdfRectangle {
+	if e.cropBox == (model.PdfRectangle{}) {
+		return e.mediaBox
+	}
+	box := e.cropBox
+	if box.Llx > box.Urx {
+		box.Llx, box.Urx = box.Urx, box.Llx
+	}
+	if box.Lly > box.Ury {
+		box.Lly, box.Ury = box.Ury, box.Lly
+	}
+	box, ok := rectIntersection(box, e.mediaBox)
+	if !ok {
+		return e.mediaBox
+	}
+	return box
+}
+
 // NewFromContents creates a new extractor from contents and page resources.
 func NewFromContents(contents string, resources *model.PdfPageResources) (*Extractor, error) {
 	e := &Extractor{
@@ -6,6 +6,8 @@
 package extractor
 
 import (
+	"math"
+
 	"github.com/gnaoh1379/unipdf/common"
 	"github.com/gnaoh1379/unipdf/contentstream"
 	"github.com/gnaoh1379/unipdf/core"
@@ -16,6 +18,10 @@ import (
 // PDF pages.
 type ImageExtractOptions struct {
 	IncludeInlineStencilMasks bool
+
+	// RawImages extracts images as they are stored in the PDF, without decoding or re-encoding
+	// them. The Raw fields of the ImageMarks are set and the Image fields are nil.
+	RawImages bool
 }
 
 // ExtractPageImages returns the image contents of the page extractor, including data
@@ -59,6 +65,50 @@ type ImageMark struct {
 
 	// Angle in degrees, if rotated.
 	Angle float64
+
+	// Raw is the image as it is stored in the PDF. It is only set when ImageExtractOptions.RawImages
+	// is true.
+	Raw *RawImage
+}
+
+// RawImage is an image as it is stored in a PDF.
+type RawImage struct {
+	// Data is the image data. It is encoded with Filter, if Filter is set, and otherwise it is the
+	// image samples. For example, Data is the contents of a JPEG file if Filter is DCTDecode.
+	Data []byte
+
+	// Filter is the name of the image compression filter that Data is encoded with: DCTDecode
+	// (JPEG), JPXDecode (JPEG 2000), JBIG2Decode or CCITTFaxDecode. It is empty if Data is the
+	// image samples. Other filters, such as FlateDecode, are always decoded.
+	Filter string
+
+	// DecodeParms is the parameters of Filter, e.g. the JBIG2Globals of a JBIG2 image. It is nil if
+	// there are no parameters.
+	DecodeParms core.PdfObject
+
+	// Dimensions of the image in pixels.
+	Width  int
+	Height int
+
+	// BitsPerComponent is the number of bits used to represent each color component.
+	BitsPerComponent int
+
+	// ColorSpace is the color space of the image. It is nil for image masks.
+	ColorSpace model.PdfColorspace
+
+	// ICCProfile is the ICC profile of the image if its color space is ICC based.
+	ICCProfile []byte
+
+	// SMask is the soft mask of the image, if it has one.
+	SMask *RawImage
+
+	// ObjectNumber is the object number of the image XObject. Images drawn several times in a
+	// document have the same object number. It is 0 for inline images.
+	ObjectNumber int64
+
+	// Effective resolution of the image where it is drawn on the page in pixels per inch.
+	DPIX float64
+	DPIY float64
 }
 
 // Provide context for image extraction content stream processing.
@@ -78,6 +128,7 @@ type imageExtractContext struct {
 type cachedImage struct {
 	image *model.Image
 	cs    model.PdfColorspace
+	raw   *RawImage
 }
 
 func (ctx *imageExtractContext) extractContentStreamImages(contents string, resources *model.PdfPageResources) error {
@@ -153,19 +204,35 @@ func (ctx *imageExtractContext) extractInlineImage(iimg *contentstream.ContentSt
 		cs = model.NewPdfColorspaceDeviceGray()
 	}
 
-	rgbImg, err := cs.ImageToRGB(*img)
-	if err != nil {
-		return err
-	}
-
 	imgMark := ImageMark{
-		Image:  &rgbImg,
 		Width:  gs.CTM.ScalingFactorX(),
 		Height: gs.CTM.ScalingFactorY(),
 		Angle:  gs.CTM.Angle(),
 	}
 	imgMark.X, imgMark.Y = gs.CTM.Translation()
 
+	if ctx.options.RawImages {
+		// Inline images are small and are stored in the content stream so their samples are
+		// extracted.
+		raw := &RawImage{
+			Data:             img.Data,
+			Width:            int(img.Width),
+			Height:           int(img.Height),
+			BitsPerComponent: int(img.BitsPerComponent),
+			ColorSpace:       cs,
+		}
+		if isMask, _ := iimg.IsMask(); isMask {
+			raw.ColorSpace = nil
+		}
+		imgMark.setRaw(raw)
+	} else {
+		rgbImg, err := cs.ImageToRGB(*img)
+		if err != nil {
+			return err
+		}
+		imgMark.Image = &rgbImg
+	}
+
 	ctx.extractedImages = append(ctx.extractedImages, imgMark)
 	ctx.inlineImages++
 	return nil
@@ -188,34 +255,38 @@ func (ctx *imageExtractContext) extractXObjectImage(name *core.PdfObjectName, gs
 			return nil
 		}
 
-		img, err := ximg.ToImage()
+		cimg = &cachedImage{
+			cs: ximg.ColorSpace,
+		}
+		if ctx.options.RawImages {
+			cimg.raw, err = newRawImage(stream, ximg)
+		} else {
+			cimg.image, err = ximg.ToImage()
+		}
 		if err != nil {
 			return err
 		}
-
-		cimg = &cachedImage{
-			image: img,
-			cs:    ximg.ColorSpace,
-		}
 		ctx.cacheXObjectImages[stream] = cimg
 	}
-	img := cimg.image
-	cs := cimg.cs
-
-	rgbImg, err := cs.ImageToRGB(*img)
-	if err != nil {
-		return err
-	}
 
 	common.Log.Debug("@Do CTM: %s", gs.CTM.String())
 	imgMark := ImageMark{
-		Image:  &rgbImg,
 		Width:  gs.CTM.ScalingFactorX(),
 		Height: gs.CTM.ScalingFactorY(),
 		Angle:  gs.CTM.Angle(),
 	}
 	imgMark.X, imgMark.Y = gs.CTM.Translation()
 
+	if cimg.raw != nil {
+		imgMark.setRaw(cimg.raw)
+	} else {
+		rgbImg, err := cimg.cs.ImageToRGB(*cimg.image)
+		if err != nil {
+			return err
+		}
+		imgMark.Image = &rgbImg
+	}
+
 	ctx.extractedImages = append(ctx.extractedImages, imgMark)
 	ctx.xObjectImages++
 	return nil
@@ -250,3 +321,122 @@ func (ctx *imageExtractContext) extractFormImages(name *core.PdfObjectName, gs c
 	ctx.xObjectForms++
 	return nil
 }
+
+// setRaw sets the raw image of `mark` to a copy of `raw` with the resolution of the image where it
+// is drawn. The image data is shared between the copies.
+func (mark *ImageMark) setRaw(raw *RawImage) {
+	r := *raw
+	if mark.Width != 0 {
+		r.DPIX = float64(r.Width) * 72 / math.Abs(mark.Width)
+	}
+	if mark.Height != 0 {
+		r.DPIY = float64(r.Height) * 72 / math.Abs(mark.Height)
+	}
+	mark.Raw = &r
+}
+
+// imageFilters are the filters that compress image data. The data of image XObjects compressed
+// with these filters is not decoded.
+var imageFilters = map[string]bool{
+	core.StreamEncodingFilterNameDCT:      true,
+	core.StreamEncodingFilterNameJPX:      true,
+	core.StreamEncodingFilterNameJBIG2:    true,
+	core.StreamEncodingFilterNameCCITTFax: true,
+}
+
+// newRawImage returns the RawImage for image XObject `ximg` with stream `stream`.
+func newRawImage(stream *core.PdfObjectStream, ximg *model.XObjectImage) (*RawImage, error) {
+	raw := &RawImage{
+		ColorSpace:   ximg.ColorSpace,
+		ObjectNumber: stream.ObjectNumber,
+	}
+	if ximg.Width != nil {
+		raw.Width = int(*ximg.Width)
+	}
+	if ximg.Height != nil {
+		raw.Height = int(*ximg.Height)
+	}
+	if ximg.BitsPerComponent != nil {
+		raw.BitsPerComponent = int(*ximg.BitsPerComponent)
+	}
+	if isMask, _ := core.GetBoolVal(ximg.ImageMask); isMask {
+		raw.ColorSpace = nil
+	}
+	if icc, ok := raw.ColorSpace.(*model.PdfColorspaceICCBased); ok {
+		raw.ICCProfile = icc.Data
+	}
+
+	data, filter, decodeParms, err := decodeNonImageFilters(stream)
+	if err != nil {
+		return nil, err
+	}
+	raw.Data, raw.Filter, raw.DecodeParms = data, filter, decodeParms
+
+	if smaskStream, ok := core.GetStream(ximg.SMask); ok {
+		smask, err := model.NewXObjectImageFromStream(smaskStream)
+		if err != nil {
+			return nil, err
+		}
+		raw.SMask, err = newRawImage(smaskStream, smask)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// decodeNonImageFilters returns the data of image stream `stream` decoded with all its filters
+// except a final image compression filter. It also returns the name and parameters of the image
+// compression filter if there is one.
+func decodeNonImageFilters(stream *core.PdfObjectStream) ([]byte, string, core.PdfObject, error) {
+	var filters, decodeParms []core.PdfObject
+	filterObj := core.TraceToDirectObject(stream.Get("Filter"))
+	parmsObj := core.TraceToDirectObject(stream.Get("DecodeParms"))
+	if arr, ok := filterObj.(*core.PdfObjectArray); ok {
+		filters = arr.Elements()
+	} else if filterObj != nil {
+		filters = []core.PdfObject{filterObj}
+	}
+	if arr, ok := parmsObj.(*core.PdfObjectArray); ok {
+		decodeParms = arr.Elements()
+	} else if parmsObj != nil && len(filters) == 1 {
+		decodeParms = []core.PdfObject{parmsObj}
+	}
+
+	n := len(filters)
+	var last string
+	if n > 0 {
+		last, _ = core.GetNameVal(filters[n-1])
+	}
+	if !imageFilters[last] {
+		data, err := core.DecodeStream(stream)
+		return data, "", nil, err
+	}
+
+	var lastParms core.PdfObject
+	if len(decodeParms) == n {
+		lastParms = core.TraceToDirectObject(decodeParms[n-1])
+		if _, isNull := lastParms.(*core.PdfObjectNull); isNull {
+			lastParms = nil
+		}
+	}
+	if n == 1 {
+		return stream.Stream, last, lastParms, nil
+	}
+
+	// Decode the other filters by decoding a copy of the stream without the image filter.
+	dict := core.MakeDict()
+	dict.Set("Filter", core.MakeArray(filters[:n-1]...))
+	if len(decodeParms) == n {
+		parms := core.MakeArray()
+		for _, obj := range decodeParms[:n-1] {
+			if _, ok := core.GetDict(obj); !ok {
+				obj = core.MakeDict()
+			}
+			parms.Append(obj)
+		}
+		dict.Set("DecodeParms", parms)
+	}
+	data, err := core.DecodeStream(&core.PdfObjectStream{PdfObjectDictionary: dict, Stream: stream.Stream})
+	return data, last, lastParms, err
+}
@@ -6,6 +6,9 @@
 package extractor
 
 import (
+	"bytes"
+	"image"
+	"image/color"
 	"math"
 	"os"
 	"path/filepath"
@@ -333,6 +336,69 @@ func TestImageExtractionRealWorld(t *testing.T) {
 	}
 }
 
+// Test extraction of images as they are stored in the PDF.
+func TestImageExtractionRaw(t *testing.T) {
+	goImg := image.NewRGBA(image.Rect(0, 0, 48, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 48; x++ {
+			goImg.Set(x, y, color.RGBA{R: uint8(5 * x), G: uint8(10 * y), B: 128, A: 255})
+		}
+	}
+	img, err := model.ImageHandling.NewImageFromGoImage(goImg)
+	require.NoError(t, err)
+	icc, err := model.NewPdfColorspaceICCBased(3)
+	require.NoError(t, err)
+	icc.Data = []byte("ICC profile")
+	ximg, err := model.NewXObjectImageFromImage(img, icc, core.NewDCTEncoder())
+	require.NoError(t, err)
+
+	maskImg := model.Image{Width: 48, Height: 24, BitsPerComponent: 8, ColorComponents: 1,
+		Data: bytes.Repeat([]byte{0x80}, 48*24)}
+	smask, err := model.NewXObjectImageFromImage(&maskImg, model.NewPdfColorspaceDeviceGray(),
+		core.NewFlateEncoder())
+	require.NoError(t, err)
+	ximg.SMask = smask.ToPdfObject()
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 600, Ury: 800}
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im0", ximg))
+	contents := "q 144 0 0 72 100 100 cm /Im0 Do Q q 48 0 0 24 300 300 cm /Im0 Do Q"
+	require.NoError(t, page.SetContentStreams([]string{contents}, core.NewRawEncoder()))
+	reader := readTestPages(t, []*model.PdfPage{page}, nil)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+
+	pageExtractor, err := New(page)
+	require.NoError(t, err)
+	pageImages, err := pageExtractor.ExtractPageImages(&ImageExtractOptions{RawImages: true})
+	require.NoError(t, err)
+	require.Len(t, pageImages.Images, 2)
+
+	expectedDPI := []float64{24, 72}
+	for i, mark := range pageImages.Images {
+		assert.Nil(t, mark.Image)
+		raw := mark.Raw
+		require.NotNil(t, raw)
+		assert.Equal(t, core.StreamEncodingFilterNameDCT, raw.Filter)
+		assert.Equal(t, []byte{0xff, 0xd8}, raw.Data[:2], "not JPEG data")
+		assert.Equal(t, 48, raw.Width)
+		assert.Equal(t, 24, raw.Height)
+		assert.Equal(t, 8, raw.BitsPerComponent)
+		assert.Equal(t, []byte("ICC profile"), raw.ICCProfile)
+		assert.NotZero(t, raw.ObjectNumber)
+		assert.InDelta(t, expectedDPI[i], raw.DPIX, 1e-6)
+		assert.InDelta(t, expectedDPI[i], raw.DPIY, 1e-6)
+
+		// The soft mask is decoded.
+		require.NotNil(t, raw.SMask)
+		assert.Empty(t, raw.SMask.Filter)
+		assert.Equal(t, maskImg.Data, raw.SMask.Data)
+		assert.NotEqual(t, raw.ObjectNumber, raw.SMask.ObjectNumber)
+	}
+	// Images drawn more than once have the same data and object number.
+	assert.Equal(t, pageImages.Images[0].Raw.ObjectNumber, pageImages.Images[1].Raw.ObjectNumber)
+}
+
 func BenchmarkImageExtraction(b *testing.B) {
 	cnt := 0
 	for i := 0; i < b.N; i++ {
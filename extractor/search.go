@@ -0,0 +1,271 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/gnaoh1379/unipdf/annotator"
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// SearchOptions contains options for controlling how text is matched by Find.
+// Runs of white space, including line breaks, in the query and the extracted text always match
+// each other.
+type SearchOptions struct {
+	// IgnoreCase makes matching case-insensitive.
+	IgnoreCase bool
+	// IgnoreDiacritics makes matching ignore accents and other diacritical marks in the extracted
+	// text and in the query, so that "resume" matches "résumé". Regular expressions are matched
+	// against the text without its diacritical marks.
+	IgnoreDiacritics bool
+	// WholeWords restricts matches to text that is not preceded or followed by a letter or digit.
+	WholeWords bool
+	// Regexp specifies that the query is a regular expression with the syntax of the regexp package.
+	Regexp bool
+}
+
+// SearchHit is an occurrence of a search query in the extracted text of a page.
+type SearchHit struct {
+	// PageNum is the (1-offset) number of the page containing the hit. It is 0 for hits found with
+	// PageText.Find.
+	PageNum int
+	// Text is the matched text. It is pageText.Text()[Start:End].
+	Text string
+	// Start and End are the offsets of the start and end of Text in the extracted page text.
+	Start, End int
+	// BBox is the bounding box of the hit.
+	BBox model.PdfRectangle
+	// Rects are the bounding boxes of the parts of the hit on each line of text. A hit that doesn't
+	// span lines has one rectangle equal to BBox.
+	Rects []model.PdfRectangle
+}
+
+// Find returns the occurrences of `query` in the text of the pages of the document read by
+// `reader`, in page order. The options parameter can be nil for the default options, which match
+// `query` as case-sensitive literal text.
+func Find(reader *model.PdfReader, query string, options *SearchOptions) ([]SearchHit, error) {
+	m, err := newTextMatcher(query, options)
+	if err != nil {
+		return nil, err
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	var hits []SearchHit
+	for pageNum := 1; pageNum <= numPages; pageNum++ {
+		page, err := reader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+		ex, err := New(page)
+		if err != nil {
+			return nil, err
+		}
+		pageText, _, _, err := ex.ExtractPageText()
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, m.find(pageText, pageNum)...)
+	}
+	return hits, nil
+}
+
+// Find returns the occurrences of `query` in the text of `pt`. See the package level Find.
+func (pt PageText) Find(query string, options *SearchOptions) ([]SearchHit, error) {
+	m, err := newTextMatcher(query, options)
+	if err != nil {
+		return nil, err
+	}
+	return m.find(&pt, 0), nil
+}
+
+// AddHighlightAnnotations adds a highlight annotation to the page of `reader` containing each hit
+// in `hits`. `color` is the highlight color. It is yellow if nil. The annotated pages can be
+// written with model.PdfWriter.
+func AddHighlightAnnotations(reader *model.PdfReader, hits []SearchHit, color *model.PdfColorDeviceRGB) error {
+	for _, hit := range hits {
+		page, err := reader.GetPage(hit.PageNum)
+		if err != nil {
+			return err
+		}
+		annotation, err := annotator.CreateHighlightAnnotation(annotator.HighlightAnnotationDef{
+			Rects:    hit.Rects,
+			Color:    color,
+			Contents: hit.Text,
+		})
+		if err != nil {
+			return err
+		}
+		page.AddAnnotation(annotation)
+	}
+	return nil
+}
+
+// textMatcher finds the occurrences of a search query in extracted text.
+type textMatcher struct {
+	re      *regexp.Regexp
+	options SearchOptions
+}
+
+// newTextMatcher returns a textMatcher for `query` and `options`.
+func newTextMatcher(query string, options *SearchOptions) (*textMatcher, error) {
+	if query == "" {
+		return nil, errors.New("empty search query")
+	}
+	m := &textMatcher{}
+	if options != nil {
+		m.options = *options
+	}
+
+	pattern := query
+	if !m.options.Regexp {
+		pattern = regexp.QuoteMeta(normalizeSearchText(query, m.options).text)
+	}
+	if m.options.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	m.re = re
+	return m, nil
+}
+
+// find returns the hits for `m` in `pt`, the text of page number `pageNum`.
+func (m *textMatcher) find(pt *PageText, pageNum int) []SearchHit {
+	text := pt.Text()
+	marks := pt.Marks()
+	normalized := normalizeSearchText(text, m.options)
+
+	var hits []SearchHit
+	for _, loc := range m.re.FindAllStringIndex(normalized.text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		start, end := normalized.starts[loc[0]], normalized.ends[loc[1]-1]
+		if m.options.WholeWords && !isWholeWord(text, start, end) {
+			continue
+		}
+		spanMarks, err := marks.RangeOffset(start, end)
+		if err != nil {
+			common.Log.Debug("ERROR: find: no marks for hit. start=%d end=%d err=%v", start, end, err)
+			continue
+		}
+		rects := lineRects(spanMarks)
+		if len(rects) == 0 {
+			continue
+		}
+		bbox := rects[0]
+		for _, r := range rects[1:] {
+			bbox = rectUnion(bbox, r)
+		}
+		hits = append(hits, SearchHit{
+			PageNum: pageNum,
+			Text:    text[start:end],
+			Start:   start,
+			End:     end,
+			BBox:    bbox,
+			Rects:   rects,
+		})
+	}
+	return hits
+}
+
+// lineRects returns the bounding boxes of the marks in `ma` on each line of text. Lines are
+// separated by the line break marks in `ma`.
+func lineRects(ma *TextMarkArray) []model.PdfRectangle {
+	var rects []model.PdfRectangle
+	newLine := true
+	for _, tm := range ma.Elements() {
+		if tm.Meta && strings.ContainsRune(tm.Text, '\n') {
+			newLine = true
+			continue
+		}
+		if tm.Meta || isTextSpace(tm.Text) {
+			continue
+		}
+		if newLine {
+			rects = append(rects, tm.BBox)
+			newLine = false
+		} else {
+			rects[len(rects)-1] = rectUnion(rects[len(rects)-1], tm.BBox)
+		}
+	}
+	return rects
+}
+
+// isWholeWord returns true if text[start:end] is not preceded or followed by a letter or digit.
+func isWholeWord(text string, start, end int) bool {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+	}
+	if before, size := utf8.DecodeLastRuneInString(text[:start]); size > 0 && isWordRune(before) {
+		return false
+	}
+	if after, size := utf8.DecodeRuneInString(text[end:]); size > 0 && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+// searchText is text that has been normalized for searching. The bytes text[i] were derived from
+// the runes original[starts[i]:ends[i]] of the original text.
+type searchText struct {
+	text         string
+	starts, ends []int
+}
+
+// normalizeSearchText returns `text` normalized for matching with `options`. Runs of white space
+// are replaced by a single space and, if options.IgnoreDiacritics is true, diacritical marks are
+// removed.
+func normalizeSearchText(text string, options SearchOptions) searchText {
+	var b strings.Builder
+	var st searchText
+	add := func(s string, start, end int) {
+		b.WriteString(s)
+		for i := 0; i < len(s); i++ {
+			st.starts = append(st.starts, start)
+			st.ends = append(st.ends, end)
+		}
+	}
+	space := false
+	for i, end := 0, 0; i < len(text); i = end {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		end = i + size
+		if unicode.IsSpace(r) {
+			if space {
+				st.ends[len(st.ends)-1] = end
+			} else {
+				add(" ", i, end)
+			}
+			space = true
+			continue
+		}
+		space = false
+		s := string(r)
+		if options.IgnoreDiacritics {
+			s = strings.Map(func(r rune) rune {
+				if unicode.Is(unicode.Mn, r) {
+					return -1
+				}
+				return r
+			}, norm.NFD.String(s))
+		}
+		add(s, i, end)
+	}
+	st.text = b.String()
+	return st
+}
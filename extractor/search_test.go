@@ -0,0 +1,128 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestFind tests searching the text of a document with the different search options and
+// highlighting the hits.
+func TestFind(t *testing.T) {
+	pageContents := []string{`
+		BT /Helvetica 12 Tf 100 700 Td (The Caf\351 serves coffee.) Tj ET
+		BT /Helvetica 12 Tf 100 600 Td (Order number 1234 is) Tj 0 -14 Td (ready for pickup.) Tj ET
+		`, `
+		BT /Helvetica 12 Tf 100 700 Td (Cafeteria and cafe hours.) Tj ET
+		`,
+	}
+	reader := makeTestReader(t, pageContents)
+
+	testCases := []struct {
+		query    string
+		options  *SearchOptions
+		expected []string // Page number and text of each hit.
+	}{
+		{"Caf", nil, []string{"1:Caf", "2:Caf"}},
+		{"cafe", nil, []string{"2:cafe"}},
+		{"cafe", &SearchOptions{IgnoreCase: true}, []string{"2:Cafe", "2:cafe"}},
+		{"cafe", &SearchOptions{IgnoreCase: true, IgnoreDiacritics: true},
+			[]string{"1:Café", "2:Cafe", "2:cafe"}},
+		{"cafe", &SearchOptions{IgnoreCase: true, IgnoreDiacritics: true, WholeWords: true},
+			[]string{"1:Café", "2:cafe"}},
+		{`\d+`, &SearchOptions{Regexp: true}, []string{"1:1234"}},
+		{"is ready", nil, []string{"1:is\nready"}},
+	}
+
+	for _, tc := range testCases {
+		hits, err := Find(reader, tc.query, tc.options)
+		require.NoError(t, err)
+		var got []string
+		for _, hit := range hits {
+			got = append(got, fmt.Sprintf("%d:%s", hit.PageNum, hit.Text))
+		}
+		assert.Equal(t, tc.expected, got, "query=%q options=%+v", tc.query, tc.options)
+	}
+
+	// A hit that spans two lines has a rectangle for each line.
+	hits, err := Find(reader, "is ready", nil)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	hit := hits[0]
+	require.Len(t, hit.Rects, 2)
+	assert.True(t, hit.Rects[0].Lly > hit.Rects[1].Ury)
+	assert.InDelta(t, hit.Rects[1].Llx, 100, 0.01)
+	assert.Equal(t, hit.BBox, rectUnion(hit.Rects[0], hit.Rects[1]))
+
+	_, err = Find(reader, "", nil)
+	assert.Error(t, err)
+	_, err = Find(reader, "(", &SearchOptions{Regexp: true})
+	assert.Error(t, err)
+
+	// Highlight the hits.
+	hits, err = Find(reader, "cafe", &SearchOptions{IgnoreCase: true, IgnoreDiacritics: true})
+	require.NoError(t, err)
+	require.NoError(t, AddHighlightAnnotations(reader, hits, nil))
+	for pageNum, expected := range []int{1, 2} {
+		page, err := reader.GetPage(pageNum + 1)
+		require.NoError(t, err)
+		annotations, err := page.GetAnnotations()
+		require.NoError(t, err)
+		require.Len(t, annotations, expected)
+		highlight, ok := annotations[0].GetContext().(*model.PdfAnnotationHighlight)
+		require.True(t, ok)
+		quads, ok := core.GetArray(highlight.QuadPoints)
+		require.True(t, ok)
+		assert.Equal(t, 8, quads.Len())
+	}
+}
+
+// makeTestReader returns a PdfReader for a document with pages having contents `pageContents`
+// and using the Helvetica font.
+func makeTestReader(t *testing.T, pageContents []string) *model.PdfReader {
+	return readTestPages(t, makeTestPages(t, pageContents), nil)
+}
+
+// makeTestPages returns pages having contents `pageContents` and using the Helvetica font.
+func makeTestPages(t *testing.T, pageContents []string) []*model.PdfPage {
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	var pages []*model.PdfPage
+	for _, contents := range pageContents {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 600, Ury: 800}
+		page.Resources = model.NewPdfPageResources()
+		page.Resources.SetFontByName("Helvetica", helvetica.ToPdfObject())
+		require.NoError(t, page.SetContentStreams([]string{contents}, core.NewRawEncoder()))
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// readTestPages returns a PdfReader for a document with pages `pages`. The document is tagged
+// with structure tree root `structTreeRoot` if it isn't nil.
+func readTestPages(t *testing.T, pages []*model.PdfPage,
+	structTreeRoot core.PdfObject) *model.PdfReader {
+	writer := model.NewPdfWriter()
+	for _, page := range pages {
+		require.NoError(t, writer.AddPage(page))
+	}
+	if structTreeRoot != nil {
+		require.NoError(t, writer.SetStructTreeRoot(structTreeRoot))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writer.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader
+}
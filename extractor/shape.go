@@ -0,0 +1,484 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/contentstream"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// ExtractPageShapes returns the paths painted on the page of the extractor. These are the vector
+// graphics of the page: lines, rectangles, curves and the regions they enclose. Paths inside form
+// XObjects are included. Paths that are only used for clipping are not.
+func (e *Extractor) ExtractPageShapes() (*PageShapes, error) {
+	ctx := &shapeExtractContext{}
+	state := shapeState{lineWidth: 1, clip: e.mediaBox}
+	err := ctx.extractContentStreamShapes(e.contents, e.resources, transform.IdentityMatrix(), state, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &PageShapes{Shapes: ctx.shapes}, nil
+}
+
+// PageShapes represents the paths painted on a PDF page.
+type PageShapes struct {
+	Shapes []ShapeMark
+}
+
+// ShapeMark represents a path painted on a page. All coordinates and lengths are in device
+// coordinates.
+type ShapeMark struct {
+	// Subpaths are the subpaths of the path.
+	Subpaths []Subpath
+
+	// BBox is the bounding box of the path. The bounding boxes of curves include their control
+	// points.
+	BBox model.PdfRectangle
+
+	// Stroked is true if the path is stroked. StrokeColor, LineWidth, DashArray and DashPhase describe
+	// the stroke.
+	Stroked     bool
+	StrokeColor color.Color
+	LineWidth   float64
+	DashArray   []float64
+	DashPhase   float64
+
+	// Filled is true if the path is filled. FillColor and FillRule describe the fill.
+	Filled    bool
+	FillColor color.Color
+	FillRule  FillRule
+
+	// Clipped is true if the path was painted inside a clipping path. ClipBBox is the bounding box
+	// of the clipping region if Clipped is true, or the page media box otherwise.
+	Clipped  bool
+	ClipBBox model.PdfRectangle
+}
+
+// FillRule is the rule that determines which points are inside a filled path.
+type FillRule int
+
+// Fill rules.
+const (
+	FillRuleNonZeroWinding FillRule = iota
+	FillRuleEvenOdd
+)
+
+// Subpath is a connected sequence of straight lines and curves in a path. A subpath starts at Start
+// and each segment starts at the end of the previous one.
+type Subpath struct {
+	Start    PathPoint
+	Segments []PathSegment
+	// Closed is true if the subpath was closed. The end of a closed subpath is joined to its start.
+	Closed bool
+}
+
+// PathSegment is a straight line or a cubic Bézier curve in a Subpath.
+type PathSegment struct {
+	// Curve is true for curves and false for straight lines.
+	Curve bool
+	// C1 and C2 are the control points of curves.
+	C1, C2 PathPoint
+	// End is the end point of the segment.
+	End PathPoint
+}
+
+// PathPoint is a point in a path.
+type PathPoint struct {
+	X, Y float64
+}
+
+// Rectangle returns the rectangle drawn by `s` if `s` is a single axis-aligned rectangle, such as
+// a box or a thick line drawn with the `re` operator.
+func (s *ShapeMark) Rectangle() (model.PdfRectangle, bool) {
+	if len(s.Subpaths) != 1 {
+		return model.PdfRectangle{}, false
+	}
+	vertices, ok := quadVertices(s.Subpaths[0], true)
+	if !ok {
+		return model.PdfRectangle{}, false
+	}
+	// The sides must alternate between horizontal and vertical.
+	var horizontal [4]bool
+	for i, a := range vertices {
+		b := vertices[(i+1)%4]
+		switch {
+		case a.Y == b.Y && a.X != b.X:
+			horizontal[i] = true
+		case a.X == b.X && a.Y != b.Y:
+			horizontal[i] = false
+		default:
+			return model.PdfRectangle{}, false
+		}
+		if i > 0 && horizontal[i] == horizontal[i-1] {
+			return model.PdfRectangle{}, false
+		}
+	}
+	return s.BBox, true
+}
+
+// quadVertices returns the vertices of `sp` if `sp` is a quadrilateral: four straight lines, the
+// last of which ends at the start of `sp`, or three straight lines joined to the start by closing
+// `sp`. If `closed` is false, three straight lines are accepted without closing `sp`, as filling a
+// subpath closes it.
+func quadVertices(sp Subpath, closed bool) ([]PathPoint, bool) {
+	vertices := []PathPoint{sp.Start}
+	for _, seg := range sp.Segments {
+		if seg.Curve {
+			return nil, false
+		}
+		vertices = append(vertices, seg.End)
+	}
+	if n := len(vertices); n == 5 && vertices[4] == vertices[0] {
+		vertices = vertices[:4]
+	} else if n != 4 || closed && !sp.Closed {
+		return nil, false
+	}
+	return vertices, true
+}
+
+// shapeExtractContext is the context for extracting the shapes of a page.
+type shapeExtractContext struct {
+	shapes []ShapeMark // The shapes extracted so far.
+}
+
+// shapeState is the part of the graphics state used for extracting shapes that
+// contentstream.GraphicsState doesn't track.
+type shapeState struct {
+	lineWidth float64            // Line width in user space.
+	dashArray []float64          // Dash array in user space.
+	dashPhase float64            // Dash phase in user space.
+	clipped   bool               // Has a clipping path been set?
+	clip      model.PdfRectangle // Bounding box of the clipping region in device coordinates.
+}
+
+// shapePath is a path being constructed in a content stream. Points are in device coordinates.
+type shapePath struct {
+	subpaths []Subpath
+	current  PathPoint // The current point.
+	clip     bool      // Is the path a clipping path? This is true if W or W* was applied to it.
+}
+
+// extractContentStreamShapes appends the shapes painted by content stream `contents` with
+// resources `resources` to `ctx.shapes`. `parentCTM` maps the content stream's user space to
+// device space and `state` is the initial state.
+func (ctx *shapeExtractContext) extractContentStreamShapes(contents string,
+	resources *model.PdfPageResources, parentCTM transform.Matrix, state shapeState, level int) error {
+	if level > maxFormStack {
+		err := errors.New("form stack overflow")
+		common.Log.Debug("ERROR: extractContentStreamShapes. recursion level=%d err=%v", level, err)
+		return err
+	}
+
+	cstreamParser := contentstream.NewContentStreamParser(contents)
+	operations, err := cstreamParser.Parse()
+	if err != nil {
+		return err
+	}
+
+	var savedStates []shapeState
+	var path shapePath
+
+	processor := contentstream.NewContentStreamProcessor(*operations)
+	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
+			resources *model.PdfPageResources) error {
+			ctm := parentCTM.Mult(gs.CTM)
+			switch op.Operand {
+			case "q":
+				savedStates = append(savedStates, state)
+			case "Q":
+				if len(savedStates) > 0 {
+					state = savedStates[len(savedStates)-1]
+					savedStates = savedStates[:len(savedStates)-1]
+				}
+			case "w":
+				if width, err := core.GetNumbersAsFloat(op.Params); err == nil && len(width) == 1 {
+					state.lineWidth = width[0]
+				}
+			case "d":
+				if len(op.Params) == 2 {
+					state.setDash(op.Params[0], op.Params[1])
+				}
+			case "gs":
+				if len(op.Params) == 1 {
+					ctx.applyExtGState(op.Params[0], resources, &state)
+				}
+			case "m", "l", "c", "v", "y", "h", "re":
+				params, err := core.GetNumbersAsFloat(op.Params)
+				if err != nil {
+					common.Log.Debug("ERROR: invalid path operands. op=%s err=%v", op, err)
+					break
+				}
+				path.add(op.Operand, params, ctm)
+			case "W", "W*":
+				path.clip = true
+			case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n":
+				switch op.Operand {
+				case "s", "b", "b*":
+					path.add("h", nil, ctm)
+				}
+				bbox, ok := path.bbox()
+				if ok && op.Operand != "n" {
+					ctx.shapes = append(ctx.shapes, path.toShapeMark(op.Operand, gs, ctm, state, bbox))
+				}
+				if ok && path.clip {
+					state.intersectClip(bbox)
+				}
+				path = shapePath{}
+			case "Do":
+				if len(op.Params) == 0 {
+					return core.ErrRangeError
+				}
+				name, ok := core.GetName(op.Params[0])
+				if !ok {
+					return errTypeCheck
+				}
+				if _, xtype := resources.GetXObjectByName(*name); xtype != model.XObjectTypeForm {
+					break
+				}
+				return ctx.extractFormShapes(name, resources, ctm, state, level)
+			}
+			return nil
+		})
+
+	return processor.Process(resources)
+}
+
+// extractFormShapes appends the shapes painted by the form XObject named `name` in `resources` to
+// `ctx.shapes`. `ctm` maps the user space of the content stream drawing the form to device space.
+func (ctx *shapeExtractContext) extractFormShapes(name *core.PdfObjectName,
+	resources *model.PdfPageResources, ctm transform.Matrix, state shapeState, level int) error {
+	xform, err := resources.GetXObjectFormByName(*name)
+	if err != nil {
+		return err
+	}
+	if xform == nil {
+		return nil
+	}
+	formContent, err := xform.GetContentStream()
+	if err != nil {
+		return err
+	}
+	formResources := xform.Resources
+	if formResources == nil {
+		formResources = resources
+	}
+
+	if array, ok := core.GetArray(xform.Matrix); ok {
+		if mf, err := core.GetNumbersAsFloat(array.Elements()); err == nil && len(mf) == 6 {
+			ctm = ctm.Mult(transform.NewMatrix(mf[0], mf[1], mf[2], mf[3], mf[4], mf[5]))
+		}
+	}
+	// Forms are clipped to their bounding boxes.
+	if array, ok := core.GetArray(xform.BBox); ok {
+		if bf, err := core.GetNumbersAsFloat(array.Elements()); err == nil && len(bf) == 4 {
+			var clip shapePath
+			clip.add("re", []float64{bf[0], bf[1], bf[2] - bf[0], bf[3] - bf[1]}, ctm)
+			if bbox, ok := clip.bbox(); ok {
+				state.intersectClip(bbox)
+			}
+		}
+	}
+	return ctx.extractContentStreamShapes(string(formContent), formResources, ctm, state, level+1)
+}
+
+// applyExtGState applies the line width and dash pattern of the graphics state parameter
+// dictionary named `nameObj` in `resources` to `state`.
+func (ctx *shapeExtractContext) applyExtGState(nameObj core.PdfObject,
+	resources *model.PdfPageResources, state *shapeState) {
+	name, ok := core.GetName(nameObj)
+	if !ok {
+		return
+	}
+	obj, ok := resources.GetExtGState(*name)
+	if !ok {
+		common.Log.Debug("ERROR: missing ExtGState %s", *name)
+		return
+	}
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return
+	}
+	if width, err := core.GetNumberAsFloat(dict.Get("LW")); err == nil {
+		state.lineWidth = width
+	}
+	if dash, ok := core.GetArray(dict.Get("D")); ok && dash.Len() == 2 {
+		state.setDash(dash.Get(0), dash.Get(1))
+	}
+}
+
+// setDash sets the dash pattern of `state` to dash array `arrayObj` and dash phase `phaseObj`.
+func (state *shapeState) setDash(arrayObj, phaseObj core.PdfObject) {
+	array, ok := core.GetArray(arrayObj)
+	if !ok {
+		return
+	}
+	dashes, err := core.GetNumbersAsFloat(array.Elements())
+	if err != nil {
+		return
+	}
+	phase, err := core.GetNumberAsFloat(phaseObj)
+	if err != nil {
+		return
+	}
+	state.dashArray, state.dashPhase = dashes, phase
+}
+
+// intersectClip intersects the clipping region of `state` with `bbox`.
+func (state *shapeState) intersectClip(bbox model.PdfRectangle) {
+	if !state.clipped {
+		state.clip = bbox
+		state.clipped = true
+		return
+	}
+	clip := model.PdfRectangle{
+		Llx: math.Max(state.clip.Llx, bbox.Llx),
+		Lly: math.Max(state.clip.Lly, bbox.Lly),
+		Urx: math.Min(state.clip.Urx, bbox.Urx),
+		Ury: math.Min(state.clip.Ury, bbox.Ury),
+	}
+	if clip.Llx > clip.Urx || clip.Lly > clip.Ury {
+		clip = model.PdfRectangle{Llx: clip.Llx, Lly: clip.Lly, Urx: clip.Llx, Ury: clip.Lly}
+	}
+	state.clip = clip
+}
+
+// add applies path construction operator `operand` with operands `params` to `p`. `ctm` maps user
+// space to device space.
+func (p *shapePath) add(operand string, params []float64, ctm transform.Matrix) {
+	point := func(i int) PathPoint {
+		x, y := ctm.Transform(params[i], params[i+1])
+		return PathPoint{X: x, Y: y}
+	}
+	switch operand {
+	case "m":
+		if len(params) < 2 {
+			return
+		}
+		p.current = point(0)
+		// A moveto that follows another one replaces it.
+		if n := len(p.subpaths); n > 0 && len(p.subpaths[n-1].Segments) == 0 && !p.subpaths[n-1].Closed {
+			p.subpaths = p.subpaths[:n-1]
+		}
+		p.subpaths = append(p.subpaths, Subpath{Start: p.current})
+	case "l":
+		if len(params) < 2 {
+			return
+		}
+		p.addSegment(PathSegment{End: point(0)})
+	case "c":
+		if len(params) < 6 {
+			return
+		}
+		p.addSegment(PathSegment{Curve: true, C1: point(0), C2: point(2), End: point(4)})
+	case "v":
+		if len(params) < 4 {
+			return
+		}
+		p.addSegment(PathSegment{Curve: true, C1: p.current, C2: point(0), End: point(2)})
+	case "y":
+		if len(params) < 4 {
+			return
+		}
+		end := point(2)
+		p.addSegment(PathSegment{Curve: true, C1: point(0), C2: end, End: end})
+	case "h":
+		if n := len(p.subpaths); n > 0 && !p.subpaths[n-1].Closed {
+			p.subpaths[n-1].Closed = true
+			p.current = p.subpaths[n-1].Start
+		}
+	case "re":
+		if len(params) < 4 {
+			return
+		}
+		x, y, w, h := params[0], params[1], params[2], params[3]
+		p.add("m", []float64{x, y}, ctm)
+		p.add("l", []float64{x + w, y}, ctm)
+		p.add("l", []float64{x + w, y + h}, ctm)
+		p.add("l", []float64{x, y + h}, ctm)
+		p.add("h", nil, ctm)
+	}
+}
+
+// addSegment appends `seg` to the current subpath of `p`. A new subpath is started at the current
+// point if there is no current subpath or if it has been closed.
+func (p *shapePath) addSegment(seg PathSegment) {
+	if n := len(p.subpaths); n == 0 || p.subpaths[n-1].Closed {
+		p.subpaths = append(p.subpaths, Subpath{Start: p.current})
+	}
+	sp := &p.subpaths[len(p.subpaths)-1]
+	sp.Segments = append(sp.Segments, seg)
+	p.current = seg.End
+}
+
+// bbox returns the bounding box of `p` including the control points of its curves. It returns
+// false if `p` is empty.
+func (p *shapePath) bbox() (model.PdfRectangle, bool) {
+	var r model.PdfRectangle
+	first := true
+	extend := func(pt PathPoint) {
+		if first {
+			r = model.PdfRectangle{Llx: pt.X, Lly: pt.Y, Urx: pt.X, Ury: pt.Y}
+			first = false
+			return
+		}
+		r.Llx, r.Urx = math.Min(r.Llx, pt.X), math.Max(r.Urx, pt.X)
+		r.Lly, r.Ury = math.Min(r.Lly, pt.Y), math.Max(r.Ury, pt.Y)
+	}
+	for _, sp := range p.subpaths {
+		extend(sp.Start)
+		for _, seg := range sp.Segments {
+			if seg.Curve {
+				extend(seg.C1)
+				extend(seg.C2)
+			}
+			extend(seg.End)
+		}
+	}
+	return r, !first
+}
+
+// toShapeMark returns the ShapeMark for `p` painted with path painting operator `operand` with
+// graphics state `gs` and `state`. `ctm` maps user space to device space and `bbox` is the bounding
+// box of `p`.
+func (p *shapePath) toShapeMark(operand string, gs contentstream.GraphicsState, ctm transform.Matrix,
+	state shapeState, bbox model.PdfRectangle) ShapeMark {
+	shape := ShapeMark{
+		Subpaths: p.subpaths,
+		BBox:     bbox,
+		Clipped:  state.clipped,
+		ClipBBox: state.clip,
+	}
+	switch operand {
+	case "S", "s", "B", "B*", "b", "b*":
+		// Line widths and dash lengths are scaled by the average scaling of the CTM.
+		scale := math.Sqrt(math.Abs(ctm[0]*ctm[4] - ctm[1]*ctm[3]))
+		shape.Stroked = true
+		shape.StrokeColor = pdfColorToGoColor(gs.ColorspaceStroking, gs.ColorStroking)
+		shape.LineWidth = state.lineWidth * scale
+		for _, d := range state.dashArray {
+			shape.DashArray = append(shape.DashArray, d*scale)
+		}
+		shape.DashPhase = state.dashPhase * scale
+	}
+	switch operand {
+	case "f", "F", "f*", "B", "B*", "b", "b*":
+		shape.Filled = true
+		shape.FillColor = pdfColorToGoColor(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+		switch operand {
+		case "f*", "B*", "b*":
+			shape.FillRule = FillRuleEvenOdd
+		}
+	}
+	return shape
+}
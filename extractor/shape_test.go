@@ -0,0 +1,93 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestShapeExtraction tests the extraction of paths, including paths in form XObjects and clipped
+// paths.
+func TestShapeExtraction(t *testing.T) {
+	contents := `
+		2 w [3 1] 0 d 100 100 m 200 100 l S [] 0 d
+		1 0 0 rg 50 50 20 10 re f
+		q 10 10 100 100 re W n
+		0 0 1 RG 0 0 m 50 50 200 200 300 300 c s
+		Q
+		q 2 0 0 2 0 0 cm 1 w 0 1 0 rg 0 0 m 10 0 l 10 10 l h B* Q
+		0 0 m 1 1 l n
+		q 1 0 0 1 300 400 cm /Fm0 Do Q
+		`
+	form := model.NewXObjectForm()
+	form.BBox = core.MakeArrayFromFloats([]float64{0, 0, 50, 50})
+	form.Matrix = core.MakeArrayFromFloats([]float64{1, 0, 0, 1, 10, 20})
+	err := form.SetContentStream([]byte("0 0 100 100 re S"), core.NewRawEncoder())
+	require.NoError(t, err)
+
+	resources := model.NewPdfPageResources()
+	require.NoError(t, resources.SetXObjectFormByName("Fm0", form))
+
+	e := Extractor{resources: resources, contents: contents, mediaBox: r(0, 0, 600, 800)}
+	pageShapes, err := e.ExtractPageShapes()
+	require.NoError(t, err)
+	shapes := pageShapes.Shapes
+	require.Len(t, shapes, 5)
+
+	// Dashed line.
+	line := shapes[0]
+	assert.True(t, line.Stroked)
+	assert.False(t, line.Filled)
+	assert.Equal(t, 2.0, line.LineWidth)
+	assert.Equal(t, []float64{3, 1}, line.DashArray)
+	assert.Equal(t, r(100, 100, 200, 100), line.BBox)
+	assert.False(t, line.Clipped)
+	assert.Equal(t, r(0, 0, 600, 800), line.ClipBBox)
+
+	// Filled rectangle.
+	rect := shapes[1]
+	assert.True(t, rect.Filled)
+	assert.False(t, rect.Stroked)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, color.RGBAModel.Convert(rect.FillColor))
+	bbox, ok := rect.Rectangle()
+	assert.True(t, ok)
+	assert.Equal(t, r(50, 50, 70, 60), bbox)
+
+	// Clipped closed curve.
+	curve := shapes[2]
+	require.Len(t, curve.Subpaths, 1)
+	assert.True(t, curve.Subpaths[0].Closed)
+	assert.True(t, curve.Subpaths[0].Segments[0].Curve)
+	assert.Equal(t, PathPoint{X: 300, Y: 300}, curve.Subpaths[0].Segments[0].End)
+	assert.True(t, curve.Clipped)
+	assert.Equal(t, r(10, 10, 110, 110), curve.ClipBBox)
+	_, ok = curve.Rectangle()
+	assert.False(t, ok)
+
+	// Scaled triangle that is filled with the even-odd rule and stroked.
+	triangle := shapes[3]
+	assert.True(t, triangle.Filled)
+	assert.True(t, triangle.Stroked)
+	assert.Equal(t, FillRuleEvenOdd, triangle.FillRule)
+	assert.Equal(t, 2.0, triangle.LineWidth)
+	assert.Equal(t, r(0, 0, 20, 20), triangle.BBox)
+	assert.Empty(t, triangle.DashArray)
+
+	// Rectangle in a form XObject, clipped to the form's bounding box.
+	formRect := shapes[4]
+	bbox, ok = formRect.Rectangle()
+	assert.True(t, ok)
+	assert.Equal(t, r(310, 420, 410, 520), bbox)
+	assert.True(t, formRect.Clipped)
+	assert.Equal(t, r(310, 420, 360, 470), formRect.ClipBBox)
+}
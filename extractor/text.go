@@ -55,6 +55,14 @@ func (e *Extractor) ExtractPageText() (*PageText, int, int, error) {
 	if err != nil {
 		return nil, numChars, numMisses, err
 	}
+	pt.markBackgroundText()
+	if e.options.DropInvisibleText {
+		pt.dropInvisibleText()
+	}
+	pt.applyMarkedContent(e.structPage)
+	if e.options.StructureOrder {
+		pt.structure = e.structPage
+	}
 	pt.computeViews()
 	procBuf(pt)
 
@@ -72,7 +80,12 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 	state := newTextState(e.mediaBox)
 	var savedStates stateStack
 	to := newTextObject(e, resources, contentstream.GraphicsState{}, &state, &savedStates)
+	to.fonts = &pageText.fonts
 	var inTextObj bool
+	var shape shapePath // The current path. Used to find rulings, clipping paths and filled areas.
+	var clip shapeState // The clipping region. Only its bounding box is tracked.
+	var savedClips []shapeState
+	var mc *markedContent // The innermost marked-content sequence.
 
 	if level > maxFormStack {
 		err := errors.New("form stack overflow")
@@ -106,6 +119,7 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 			switch operand {
 			case "q": // Push current graphics state to the stack.
 				savedStates.push(&state)
+				savedClips = append(savedClips, clip)
 			case "Q": // Pop graphics state from the stack.
 				if !savedStates.empty() {
 					state = *savedStates.top()
@@ -113,6 +127,10 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 						savedStates.pop()
 					}
 				}
+				if len(savedClips) > 0 {
+					clip = savedClips[len(savedClips)-1]
+					savedClips = savedClips[:len(savedClips)-1]
+				}
 			case "BT": // Begin text
 				// Begin a text object, initializing the text matrix, Tm, and
 				// the text line matrix, Tlm, to the identity matrix. Text
@@ -128,6 +146,9 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 				graphicsState := gs
 				graphicsState.CTM = parentCTM.Mult(graphicsState.CTM)
 				to = newTextObject(e, resources, graphicsState, &state, &savedStates)
+				to.clip = clip
+				to.mc = mc
+				to.fonts = &pageText.fonts
 			case "ET": // End Text
 				// End text object, discarding text matrix. If the current
 				// text object contains text marks, they are added to the
@@ -321,6 +342,11 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 				}
 
 				_, xtype := resources.GetXObjectByName(*name)
+				if xtype == model.XObjectTypeImage {
+					area := imageArea(parentCTM.Mult(gs.CTM))
+					area.numMarks = len(pageText.marks)
+					pageText.fills = append(pageText.fills, area)
+				}
 				if xtype != model.XObjectTypeForm {
 					break
 				}
@@ -352,9 +378,83 @@ func (e *Extractor) extractPageText(contents string, resources *model.PdfPageRes
 					e.formResults[name.String()] = formResult
 				}
 
-				pageText.marks = append(pageText.marks, formResult.pageText.marks...)
+				numMarks := len(pageText.marks)
+				for _, tm := range formResult.pageText.marks {
+					// The form's marks are cached so marks that are clipped by the current
+					// clipping path or are in the current marked-content sequence are copied.
+					clipped := tm.visibility == TextVisible && clip.clipped &&
+						!intersects(tm.originaBBox, clip.clip)
+					if clipped || (tm.mc == nil && mc != nil) {
+						copied := *tm
+						if clipped {
+							copied.visibility = TextClipped
+						}
+						if copied.mc == nil {
+							copied.mc = mc
+						}
+						tm = &copied
+					}
+					pageText.marks = append(pageText.marks, tm)
+				}
+				for _, area := range formResult.pageText.fills {
+					area.numMarks += numMarks
+					pageText.fills = append(pageText.fills, area)
+				}
+				pageText.rulings = append(pageText.rulings, formResult.pageText.rulings...)
+				pageText.fonts.merge(formResult.pageText.fonts)
 				state.numChars += formResult.numChars
 				state.numMisses += formResult.numMisses
+			case "BI": // Inline image.
+				area := imageArea(parentCTM.Mult(gs.CTM))
+				area.numMarks = len(pageText.marks)
+				pageText.fills = append(pageText.fills, area)
+			case "sh": // Paint the clipping region with a shading.
+				area := filledArea{PdfRectangle: e.mediaBox, numMarks: len(pageText.marks)}
+				if clip.clipped {
+					area.PdfRectangle = clip.clip
+				}
+				pageText.fills = append(pageText.fills, area)
+			case "BMC", "BDC": // Begin marked-content sequence.
+				mc = newMarkedContent(op, resources, mc)
+				to.mc = mc
+			case "EMC": // End marked-content sequence.
+				if mc == nil {
+					common.Log.Debug("EMC called outside of a marked-content sequence")
+					break
+				}
+				mc = mc.parent
+				to.mc = mc
+			case "m", "l", "c", "v", "y", "h", "re": // Construct path.
+				params, err := core.GetNumbersAsFloat(op.Params)
+				if err != nil {
+					common.Log.Debug("ERROR: invalid path operands. op=%s err=%v", op, err)
+					break
+				}
+				shape.add(operand, params, parentCTM.Mult(gs.CTM))
+			case "W", "W*": // Set clipping path.
+				shape.clip = true
+			case "S", "s", "f", "F", "f*", "B", "B*", "b", "b*", "n": // Paint path.
+				stroke := pdfColorToGoColor(gs.ColorspaceStroking, gs.ColorStroking)
+				fill := pdfColorToGoColor(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+				pageText.rulings = append(pageText.rulings, rulingsOf(&shape, operand, stroke, fill)...)
+
+				// Filling a path closes it.
+				switch operand {
+				case "f", "F", "f*", "B", "B*", "b", "b*":
+					shape.add("h", nil, parentCTM.Mult(gs.CTM))
+					if _, ok := gs.ColorspaceNonStroking.(*model.PdfColorspaceSpecialPattern); ok {
+						// The colors of patterns are not known.
+						fill = nil
+					}
+					if area, ok := shape.filledArea(fill); ok {
+						area.numMarks = len(pageText.marks)
+						pageText.fills = append(pageText.fills, area)
+					}
+				}
+				if bbox, ok := shape.bbox(); ok && shape.clip {
+					clip.intersectClip(bbox)
+				}
+				shape = shapePath{}
 			case "rg", "g", "k", "cs", "sc", "scn":
 				// Set non-stroking color/colorspace.
 				to.gs.ColorspaceNonStroking = gs.ColorspaceNonStroking
@@ -436,7 +536,7 @@ func (to *textObject) showText(charcodes []byte) error {
 
 // showTextAdjusted "TJ". Show text with adjustable spacing.
 func (to *textObject) showTextAdjusted(args *core.PdfObjectArray) error {
-	vertical := false
+	vertical := to.getCurrentFont().IsVertical()
 	for _, o := range args.Elements() {
 		switch o.(type) {
 		case *core.PdfObjectFloat, *core.PdfObjectInteger:
@@ -685,6 +785,9 @@ type textObject struct {
 	tlm         transform.Matrix // Text line matrix. For the start of line pointer.
 	marks       []*textMark      // Text marks get written here.
 	invalidFont bool             // Flag that gets set true when we can't handle the current font.
+	clip        shapeState       // The clipping region when the text object was started.
+	mc          *markedContent   // The innermost marked-content sequence the text is drawn in.
+	fonts       *fontUsageList   // The fonts used on the page or form. Text is recorded here.
 }
 
 // newTextState returns a default textState.
@@ -739,6 +842,7 @@ func (to *textObject) renderText(data []byte) error {
 	font := to.getCurrentFont()
 	charcodes := font.BytesToCharcodes(data)
 	texts, numChars, numMisses := font.CharcodesToStrings(charcodes)
+	to.fonts.record(font, charcodes, texts, to.e.options.GlyphNameFallback)
 	if numMisses > 0 {
 		common.Log.Debug("renderText: numChars=%d numMisses=%d", numChars, numMisses)
 	}
@@ -771,6 +875,7 @@ func (to *textObject) renderText(data []byte) error {
 
 	fillColor := to.getFillColor()
 	strokeColor := to.getStrokeColor()
+	vertical := font.IsVertical()
 
 	for i, text := range texts {
 		r := []rune(text)
@@ -779,9 +884,22 @@ func (to *textObject) renderText(data []byte) error {
 		}
 
 		code := charcodes[i]
+
+		// origin is the displacement from the text cursor to the glyph's horizontal origin. In
+		// vertical writing mode the text cursor is at the glyph's vertical origin (section 9.7.4.3).
+		origin := transform.IdentityMatrix()
+		var vm model.VerticalMetrics
+		if vertical {
+			vm, _ = font.GetCharVerticalMetrics(code)
+			origin = translationMatrix(transform.Point{
+				X: -vm.Vx * glyphTextRatio * tfs,
+				Y: -vm.Vy * glyphTextRatio * tfs,
+			})
+		}
+
 		// The location of the text on the page in device coordinates is given by trm, the text
 		// rendering matrix.
-		trm := to.gs.CTM.Mult(to.tm).Mult(stateMatrix)
+		trm := to.gs.CTM.Mult(to.tm).Mult(origin).Mult(stateMatrix)
 
 		// calculate the text location displacement due to writing `r`. We will use this to update
 		// to.tm
@@ -805,6 +923,10 @@ func (to *textObject) renderText(data []byte) error {
 		// t is the displacement of the text cursor when the character is rendered.
 		t0 := transform.Point{X: (c.X*tfs + w) * th}
 		t := transform.Point{X: (c.X*tfs + state.tc + w) * th}
+		if vertical {
+			// Vertical text moves the text cursor downwards and isn't horizontally scaled.
+			t = transform.Point{Y: vm.W1y*glyphTextRatio*tfs + state.tc + w}
+		}
 		if verboseGeom {
 			common.Log.Info("tfs=%.2f tc=%.2f tw=%.2f th=%.2f", tfs, state.tc, state.tw, th)
 			common.Log.Info("dx,dy=%.3f t0=%.2f t=%.2f", c, t0, t)
@@ -814,7 +936,7 @@ func (to *textObject) renderText(data []byte) error {
 		// td0 is where this character ends. td is where the next character starts.
 		td0 := translationMatrix(t0)
 		td := translationMatrix(t)
-		end := to.gs.CTM.Mult(to.tm).Mult(td0)
+		end := to.gs.CTM.Mult(to.tm).Mult(origin).Mult(td0)
 
 		if verboseGeom {
 			common.Log.Info("end:\n\tCTM=%s\n\t tm=%s\n"+
@@ -833,12 +955,16 @@ func (to *textObject) renderText(data []byte) error {
 			font,
 			to.state.tc,
 			fillColor,
-			strokeColor)
+			strokeColor,
+			vertical)
 
 		if !onPage {
 			common.Log.Debug("Text mark outside page. Skipping")
 			continue
 		}
+		mark.paintColor = to.paintColor()
+		mark.visibility = to.visibility(mark.originaBBox)
+		mark.mc = to.mc
 		if font == nil {
 			common.Log.Debug("ERROR: No font.")
 		} else if font.Encoder() == nil {
@@ -889,6 +1015,11 @@ type PageText struct {
 	viewText   string             // Extracted page text.
 	viewMarks  []TextMark         // Public view of text marks.
 	viewTables []TextTable        // Public view of text tables.
+	paras      paraList           // The paras on the page in reading order. Used by Layout().
+	rulings    []ruling           // Lines drawn on the page. Used to find table cells.
+	fills      []filledArea       // Rectangles filled on the page. Used to find hidden text.
+	structure  *structPage        // The structure tree on the page if text is in structure order.
+	fonts      fontUsageList      // The fonts the text on the page was drawn in.
 	pageSize   model.PdfRectangle // Page size. Used to calculate depth.
 }
 
@@ -930,30 +1061,53 @@ func (pt PageText) Tables() []TextTable {
 // The comments above the TextMark definition describe how to use the []TextMark to
 // maps substrings of the page text to locations on the PDF page.
 func (pt *PageText) computeViews() {
+	var paras paraList
+	if pt.structure != nil {
+		paras = pt.structureParas()
+	} else {
+		paras = makeOrientedParas(pt.marks, pt.rulings, pt.pageSize)
+	}
+	// Build the public viewable fields from the paraLis
+	b := new(bytes.Buffer)
+	paras.writeText(b)
+	pt.viewText = b.String()
+	pt.viewMarks = paras.toTextMarks()
+	pt.viewTables = paras.tables()
+	pt.paras = paras
+}
+
+// makeOrientedParas returns the paras made from `marks` on a page of size `pageSize` in reading
+// order. `rulings` are the lines drawn on the page.
+func makeOrientedParas(marks []*textMark, rulings []ruling, pageSize model.PdfRectangle) paraList {
 	// Extract text paragraphs one orientation at a time.
 	// If there are texts with several orientations on a page then the all the text of the same
 	// orientation gets extracted togther.
 	var paras paraList
-	n := len(pt.marks)
+	n := len(marks)
 	for orient := 0; orient < 360 && n > 0; orient += 90 {
-		marks := make([]*textMark, 0, len(pt.marks)-n)
-		for _, tm := range pt.marks {
+		oriented := make([]*textMark, 0, len(marks)-n)
+		for _, tm := range marks {
 			if tm.orient == orient {
-				marks = append(marks, tm)
+				oriented = append(oriented, tm)
 			}
 		}
-		if len(marks) > 0 {
-			parasOrient := makeTextPage(marks, pt.pageSize)
+		if len(oriented) > 0 {
+			n -= len(oriented)
+			// Tables bounded by ruling lines are extracted before the rest of the text as their
+			// cells are known. Only horizontal text is placed in these tables.
+			var tables paraList
+			if orient == 0 && len(rulings) > 0 {
+				tables, oriented = findRuledTables(oriented, rulings, pageSize)
+			}
+			parasOrient := makeTextPage(oriented, pageSize)
+			if len(tables) > 0 {
+				parasOrient = append(parasOrient, tables...)
+				parasOrient.sortReadingOrder()
+			}
 			paras = append(paras, parasOrient...)
-			n -= len(marks)
 		}
 	}
-	// Build the public viewable fields from the paraLis
-	b := new(bytes.Buffer)
-	paras.writeText(b)
-	pt.viewText = b.String()
-	pt.viewMarks = paras.toTextMarks()
-	pt.viewTables = paras.tables()
+	return paras
 }
 
 // TextMarkArray is a collection of TextMarks.
@@ -1098,6 +1252,20 @@ type TextMark struct {
 	// StrokeColor is the stroke color of the text.
 	// The color is nil for spaces and line breaks (i.e. the Meta field is true).
 	StrokeColor color.Color
+	// Visibility describes whether the text can be seen on the page. Text that is drawn but can't
+	// be seen is used to hide content from readers. See Options.DropInvisibleText.
+	Visibility TextVisibility
+	// MCID is the marked-content identifier of the marked-content sequence containing the text.
+	// It links the text to an element of the document's structure tree. It is -1 for text that is
+	// not in a sequence with an MCID.
+	MCID int
+	// StructType is the structure type of the text, e.g. "P", "H1" or "Span". This is the type of
+	// the structure element containing the text if Options.StructTree is set and otherwise the tag
+	// of the marked-content sequence containing the text, e.g. "Artifact" for page headers.
+	StructType string
+	// Lang is the language of the text, e.g. "en-US", if it is given by the marked-content
+	// sequence or the structure element containing the text.
+	Lang string
 }
 
 // String returns a string describing `tm`.
@@ -1124,6 +1292,7 @@ var spaceMark = TextMark{
 	Original:    " ",
 	Meta:        true,
 	FillColor:   color.White,
+	MCID:        -1,
 	StrokeColor: color.White,
 }
 
@@ -1131,17 +1300,27 @@ var spaceMark = TextMark{
 // Cells are ordered top-to-bottom, left-to-right.
 // Cells[y] is the (0-offset) y'th row in the table.
 // Cells[y][x] is the (0-offset) x'th column in the table.
+// Cells that span several rows or columns are stored at their top left positions. The positions
+// covered by a spanning cell hold empty cells with zero RowSpan and ColSpan.
 type TextTable struct {
-	W, H  int
-	Cells [][]TableCell
+	// BBox is the bounding box of the table.
+	BBox  model.PdfRectangle `json:"bbox"`
+	W     int                `json:"w"`
+	H     int                `json:"h"`
+	Cells [][]TableCell      `json:"cells"`
 }
 
 // TableCell is a cell in a TextTable.
 type TableCell struct {
+	// BBox is the bounding box of the cell.
+	BBox model.PdfRectangle `json:"bbox"`
+	// RowSpan and ColSpan are the number of rows and columns spanned by the cell.
+	RowSpan int `json:"rowSpan"`
+	ColSpan int `json:"colSpan"`
 	// Text is the extracted text.
-	Text string
+	Text string `json:"text"`
 	// Marks returns the TextMarks corresponding to the text in Text.
-	Marks TextMarkArray
+	Marks TextMarkArray `json:"-"`
 }
 
 // getCurrentFont returns the font on top of the font stack, or DefaultFont if the font stack is
@@ -0,0 +1,85 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"github.com/gnaoh1379/unipdf/internal/bidi"
+)
+
+// resolveDirection determines the base direction of `l` and, if `l` contains right-to-left text,
+// the logical order of its marks.
+// The words in a textLine are in visual order, left to right on the page, which is the reading
+// order of left-to-right scripts only. Arabic and Hebrew text is read from right to left, with
+// embedded numbers and left-to-right words read from left to right, so lines containing such text
+// are reordered with the Unicode Bidirectional Algorithm (UAX #9). As the algorithm maps visual
+// order to logical order as well as logical order to visual order for the texts found in PDFs, we
+// apply it to the marks as they are laid out on the page.
+// The base direction of `l` is right-to-left if most of its strongly directional characters are
+// right-to-left.
+func (l *textLine) resolveDirection() {
+	var marks []*textMark
+	var classes []bidi.Class
+	numL, numR := 0, 0
+	for _, w := range l.words {
+		if w.newWord {
+			marks = append(marks, nil)
+			classes = append(classes, bidi.WS)
+		}
+		for _, tm := range w.marks {
+			class := bidi.TextClass(tm.text)
+			switch class {
+			case bidi.L:
+				numL++
+			case bidi.R, bidi.AL:
+				numR++
+			}
+			marks = append(marks, tm)
+			classes = append(classes, class)
+		}
+	}
+	l.order = nil
+	l.rtl = numR > numL
+	if numR == 0 {
+		return
+	}
+
+	levels := bidi.Levels(classes, l.rtl)
+	l.order = make([]*textMark, len(marks))
+	for i, k := range bidi.Order(levels) {
+		l.order[i] = marks[k]
+	}
+}
+
+// rtl returns true if most of the lines of `p` are written right-to-left.
+func (p *textPara) rtl() bool {
+	numRTL := 0
+	for _, line := range p.lines {
+		if line.rtl {
+			numRTL++
+		}
+	}
+	return 2*numRTL > len(p.lines)
+}
+
+// rtl returns true if most of the paras in `paras` are written right-to-left.
+func (paras paraList) rtl() bool {
+	numRTL := 0
+	for _, para := range paras {
+		if para.rtl() {
+			numRTL++
+		}
+	}
+	return 2*numRTL > len(paras)
+}
+
+// mirrorX reflects the bounding boxes of `paras` about the y axis. This is used to sort
+// right-to-left paras in reading order with the code that sorts left-to-right paras.
+func (paras paraList) mirrorX() {
+	for _, para := range paras {
+		para.Llx, para.Urx = -para.Urx, -para.Llx
+		para.eBBox.Llx, para.eBBox.Urx = -para.eBBox.Urx, -para.eBBox.Llx
+	}
+}
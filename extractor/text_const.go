@@ -85,4 +85,32 @@ const (
 
 	// Minimum number of cells in a textTable
 	minTableParas = 6
+
+	// Minimum number of non-empty cells in a textTable found from ruling lines.
+	minRuledTableCells = 2
+)
+
+// The following constants are the parameters of the detection of table ruling lines. They are in
+// points.
+const (
+	// Maximum distance between lines that are treated as the same line or as touching.
+	rulingTol = 2.0
+	// Minimum length of a line that can separate table cells.
+	minRulingLength = 2.0
+	// Maximum thickness of a filled rectangle that is drawn as a line.
+	maxRulingThickness = 3.0
+)
+
+// The following constants are the parameters of the heuristics that guess the roles of TextBlocks.
+const (
+	// Minimum ratio of a heading's font size to the body text font size.
+	headingFontSizeR = 1.15
+	// Maximum number of lines in a heading.
+	maxHeadingLines = 3
+	// Maximum number of runes in a heading.
+	maxHeadingRunes = 200
+	// Maximum heading level.
+	maxHeadingLevel = 6
+	// Fraction of the page height at the top and bottom of pages where headers and footers are found.
+	headerFooterR = 0.12
 )
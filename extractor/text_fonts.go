@@ -0,0 +1,160 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"sort"
+
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// FontDiagnostics describes how well the character codes drawn in a font on a page were mapped to
+// text. It can be used to find the fonts responsible for missing or garbled extracted text.
+type FontDiagnostics struct {
+	// Font is the font.
+	Font *model.PdfFont
+	// BaseFont is the font's "BaseFont" name.
+	BaseFont string
+	// Subtype is the font's "Subtype". It is of the form "Type0:CIDFontType2" for composite fonts.
+	Subtype string
+	// HasToUnicode is true if the font has a "ToUnicode" CMap.
+	HasToUnicode bool
+	// Encoding describes the encoding used to map character codes not in the ToUnicode CMap.
+	Encoding string
+	// EncodingGuessed is true if the encoding isn't given in the font dictionary.
+	// See model.PdfFont.IsEncodingGuessed.
+	EncodingGuessed bool
+	// NumChars is the number of character codes drawn in the font.
+	NumChars int
+	// NumMisses is the number of character codes that couldn't be mapped to text.
+	NumMisses int
+	// NumFallbacks is the number of character codes whose text was changed to the text of their
+	// glyph names in the embedded font program. See Options.GlyphNameFallback.
+	NumFallbacks int
+	// MissedCodes are the distinct character codes that couldn't be mapped to text in ascending
+	// order.
+	MissedCodes []textencoding.CharCode
+}
+
+// FontDiagnostics returns the diagnostics of the fonts used to draw text on the page in the order
+// the fonts were first used.
+func (pt PageText) FontDiagnostics() []FontDiagnostics {
+	diagnostics := make([]FontDiagnostics, len(pt.fonts.usages))
+	for i, u := range pt.fonts.usages {
+		font := u.font
+		diag := FontDiagnostics{
+			Font:            font,
+			BaseFont:        font.BaseFont(),
+			Subtype:         font.Subtype(),
+			HasToUnicode:    font.HasToUnicode(),
+			EncodingGuessed: font.IsEncodingGuessed(),
+			NumChars:        u.numChars,
+			NumMisses:       u.numMisses,
+			NumFallbacks:    u.numFallbacks,
+		}
+		if encoder := font.Encoder(); encoder != nil {
+			diag.Encoding = encoder.String()
+		}
+		for code := range u.missed {
+			diag.MissedCodes = append(diag.MissedCodes, code)
+		}
+		sort.Slice(diag.MissedCodes, func(i, j int) bool {
+			return diag.MissedCodes[i] < diag.MissedCodes[j]
+		})
+		diagnostics[i] = diag
+	}
+	return diagnostics
+}
+
+// fontUsage counts the character codes drawn in a font.
+type fontUsage struct {
+	font         *model.PdfFont
+	numChars     int
+	numMisses    int
+	numFallbacks int
+	missed       map[textencoding.CharCode]struct{} // Codes that couldn't be mapped to text.
+}
+
+// fontUsageList is the fontUsages of the fonts used on a page or form in the order they were first
+// used.
+type fontUsageList struct {
+	usages []*fontUsage
+	index  map[*model.PdfFont]*fontUsage
+}
+
+// get returns the fontUsage of `font` in `fl`, adding it if necessary.
+func (fl *fontUsageList) get(font *model.PdfFont) *fontUsage {
+	if u, ok := fl.index[font]; ok {
+		return u
+	}
+	if fl.index == nil {
+		fl.index = map[*model.PdfFont]*fontUsage{}
+	}
+	u := &fontUsage{font: font, missed: map[textencoding.CharCode]struct{}{}}
+	fl.usages = append(fl.usages, u)
+	fl.index[font] = u
+	return u
+}
+
+// merge adds the counts in `other` to `fl`. It is used to add the fonts used in a form to the fonts
+// used in the page or form that draws it.
+func (fl *fontUsageList) merge(other fontUsageList) {
+	for _, o := range other.usages {
+		u := fl.get(o.font)
+		u.numChars += o.numChars
+		u.numMisses += o.numMisses
+		u.numFallbacks += o.numFallbacks
+		for code := range o.missed {
+			u.missed[code] = struct{}{}
+		}
+	}
+}
+
+// record adds the character codes `charcodes` drawn in `font` and the texts `texts` they were
+// mapped to to `fl`. If `fallback` is true, the texts of codes that couldn't be mapped, and of
+// all codes if `font` has a guessed encoding and no ToUnicode CMap, are replaced by the text of
+// their glyph names in the font program embedded in `font` where possible.
+// The per-font counts are kept apart from the page's numMisses, which counts the codes that
+// CharcodesToStrings couldn't map, before any fallback.
+func (fl *fontUsageList) record(font *model.PdfFont, charcodes []textencoding.CharCode,
+	texts []string, fallback bool) {
+	u := fl.get(font)
+	guessed := !font.HasToUnicode() && font.IsEncodingGuessed()
+	for i, code := range charcodes {
+		// Codes with glyph names that aren't in the glyph list are mapped to "\x00" by simple
+		// encodings and aren't extracted.
+		missed := texts[i] == textencoding.MissingCodeString || texts[i] == "\x00"
+		if fallback && (missed || guessed) {
+			if text, ok := glyphNameText(font, code); ok {
+				if text != texts[i] {
+					texts[i] = text
+					u.numFallbacks++
+				}
+				continue
+			}
+		}
+		if missed {
+			u.numMisses++
+			u.missed[code] = struct{}{}
+		}
+	}
+	u.numChars += len(charcodes)
+}
+
+// glyphNameText returns the text of the name of the glyph selected by `code` in the font program
+// embedded in `font`.
+func glyphNameText(font *model.PdfFont, code textencoding.CharCode) (string, bool) {
+	glyph, ok := font.EmbeddedGlyphName(code)
+	if !ok || glyph == ".notdef" {
+		return "", false
+	}
+	r, ok := textencoding.GlyphToRune(glyph)
+	if !ok {
+		return "", false
+	}
+	return textencoding.RuneToString(r), true
+}
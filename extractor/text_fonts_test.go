@@ -0,0 +1,99 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestFontDiagnostics tests that the fonts text is drawn in are reported with the character codes
+// that couldn't be mapped to text, and that Options.GlyphNameFallback maps the codes of fonts
+// without ToUnicode CMaps to text with the glyph names in their embedded font programs.
+func TestFontDiagnostics(t *testing.T) {
+	// A composite TrueType font with its ToUnicode CMap removed. Its Identity-H encoding maps
+	// glyph IDs to text as if they were Unicode code points.
+	openSans, err := model.NewCompositePdfFontFromTTFFile("../model/testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+	data, numMisses := openSans.StringToCharcodeBytes("Hello")
+	require.Zero(t, numMisses)
+	openSansObj := openSans.ToPdfObject()
+	openSansDict, ok := core.GetDict(openSansObj)
+	require.True(t, ok)
+	openSansDict.Remove("ToUnicode")
+
+	// A simple font whose Differences name a glyph that isn't in the glyph list.
+	differences := core.MakeDict()
+	differences.Set("Type", core.MakeName("Encoding"))
+	differences.Set("BaseEncoding", core.MakeName("WinAnsiEncoding"))
+	differences.Set("Differences", core.MakeArray(core.MakeInteger(65), core.MakeName("blot")))
+	helveticaDict, ok := core.GetDict(model.NewStandard14FontMustCompile(model.HelveticaName).ToPdfObject())
+	require.True(t, ok)
+	helveticaDict.Set("Encoding", differences)
+
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("OpenSans", openSansObj)
+	resources.SetFontByName("Helvetica", helveticaDict)
+	contents := fmt.Sprintf(`
+		BT /Helvetica 12 Tf 100 700 Td (xAyA) Tj ET
+		BT /OpenSans 12 Tf 100 650 Td <%X> Tj ET
+		`, data)
+
+	extract := func(options Options) (*PageText, int) {
+		e := Extractor{
+			resources: resources,
+			contents:  contents,
+			mediaBox:  r(0, 0, 600, 800),
+			options:   options,
+		}
+		pageText, _, numMisses, err := e.ExtractPageText()
+		require.NoError(t, err)
+		return pageText, numMisses
+	}
+
+	// The page's miss count is the count of CharcodesToStrings, which maps the code of the glyph
+	// that isn't in the glyph list to "\x00" without counting it. The fonts count it as missed.
+	pageText, numMisses := extract(Options{})
+	assert.Zero(t, numMisses)
+	assert.NotContains(t, pageText.Text(), "Hello")
+	diagnostics := pageText.FontDiagnostics()
+	require.Len(t, diagnostics, 2)
+
+	helvetica := diagnostics[0]
+	assert.Equal(t, "Helvetica", helvetica.BaseFont)
+	assert.Equal(t, "Type1", helvetica.Subtype)
+	assert.False(t, helvetica.HasToUnicode)
+	assert.False(t, helvetica.EncodingGuessed)
+	assert.Equal(t, 4, helvetica.NumChars)
+	assert.Equal(t, 2, helvetica.NumMisses)
+	assert.Equal(t, []textencoding.CharCode{65}, helvetica.MissedCodes)
+
+	composite := diagnostics[1]
+	assert.Equal(t, "Type0:CIDFontType2", composite.Subtype)
+	assert.False(t, composite.HasToUnicode)
+	assert.True(t, composite.EncodingGuessed)
+	assert.Equal(t, 5, composite.NumChars)
+	assert.Zero(t, composite.NumMisses)
+	assert.Zero(t, composite.NumFallbacks)
+
+	pageText, numMisses = extract(Options{GlyphNameFallback: true})
+	assert.Zero(t, numMisses)
+	assert.Contains(t, strings.Fields(pageText.Text()), "Hello")
+	diagnostics = pageText.FontDiagnostics()
+	require.Len(t, diagnostics, 2)
+	// Helvetica isn't embedded so its missing codes can't be mapped.
+	assert.Equal(t, 2, diagnostics[0].NumMisses)
+	assert.Zero(t, diagnostics[0].NumFallbacks)
+	assert.Equal(t, 5, diagnostics[1].NumFallbacks)
+}
@@ -0,0 +1,584 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// DocumentLayout is the layout of the text of a document. It can be serialized to JSON with
+// encoding/json or exported as HTML with WriteHTML.
+type DocumentLayout struct {
+	// Pages are the layouts of the document's pages in page order.
+	Pages []*PageLayout `json:"pages"`
+}
+
+// PageLayout is the layout of the text on a page.
+type PageLayout struct {
+	// BBox is the page's media box.
+	BBox model.PdfRectangle `json:"bbox"`
+	// Blocks are the text blocks on the page in reading order.
+	Blocks []TextBlock `json:"blocks"`
+}
+
+// TextBlock is a block of text on a page: a paragraph, heading, list item, table, or the text in a
+// page header or footer. The text of a block is read together.
+type TextBlock struct {
+	// Role is the role of the block in the document. It is guessed from the block's content, font
+	// size and position.
+	Role TextBlockRole `json:"role"`
+	// Level is the level of a heading, from 1 for the most prominent headings to 6. It is 0 for
+	// other blocks.
+	Level int `json:"level,omitempty"`
+	// ListMarker is the bullet or enumerator at the start of a list item, e.g. "•" or "2.".
+	ListMarker string `json:"listMarker,omitempty"`
+	// RTL is true if the block is written right-to-left.
+	RTL bool `json:"rtl,omitempty"`
+	// BBox is the bounding box of the block.
+	BBox model.PdfRectangle `json:"bbox"`
+	// Text is the extracted text of the block. Hyphenated words split across lines are joined.
+	Text string `json:"text"`
+	// Lines are the lines in the block. They are nil for tables.
+	Lines []TextLine `json:"lines,omitempty"`
+	// Cells are the table cells of table blocks. Cells[y][x] is the cell in the y'th row and the
+	// x'th column. Cells that are missing from the table have no lines. Cells that span several
+	// rows or columns are stored at their top left positions. The positions covered by a spanning
+	// cell hold empty blocks with zero RowSpan and ColSpan, which are serialized as null.
+	Cells [][]TextBlock `json:"cells,omitempty"`
+	// RowSpan and ColSpan are the number of rows and columns spanned by a table cell. They are 0
+	// for other blocks.
+	RowSpan int `json:"rowSpan,omitempty"`
+	ColSpan int `json:"colSpan,omitempty"`
+}
+
+// MarshalJSON makes TextBlock implement the json.Marshaler interface. The table positions covered
+// by spanning cells are serialized as null.
+func (b TextBlock) MarshalJSON() ([]byte, error) {
+	type textBlock TextBlock
+	v := struct {
+		textBlock
+		Cells [][]*TextBlock `json:"cells,omitempty"`
+	}{textBlock: textBlock(b)}
+	if b.Cells != nil {
+		v.Cells = make([][]*TextBlock, len(b.Cells))
+		for y, row := range b.Cells {
+			v.Cells[y] = make([]*TextBlock, len(row))
+			for x := range row {
+				if row[x].isCell() {
+					v.Cells[y][x] = &row[x]
+				}
+			}
+		}
+	}
+	return json.Marshal(v)
+}
+
+// isCell returns true if table cell block `b` is not a position covered by a spanning cell.
+func (b *TextBlock) isCell() bool {
+	return b.RowSpan > 0 && b.ColSpan > 0
+}
+
+// TextLine is a line of text in a TextBlock.
+type TextLine struct {
+	// BBox is the bounding box of the line.
+	BBox model.PdfRectangle `json:"bbox"`
+	// Text is the extracted text of the line.
+	Text string `json:"text"`
+	// Words are the words of the line in reading order.
+	Words []TextWord `json:"words"`
+}
+
+// TextWord is a word in a TextLine.
+type TextWord struct {
+	// Text is the extracted text of the word.
+	Text string `json:"text"`
+	// BBox is the bounding box of the word.
+	BBox model.PdfRectangle `json:"bbox"`
+	// Font is the font of the first character of the word. It is serialized as the font's name.
+	Font *model.PdfFont `json:"-"`
+	// FontSize is the largest font size in the word.
+	FontSize float64 `json:"fontSize"`
+	// FillColor is the fill color of the first character of the word. It is serialized as an
+	// #rrggbb string.
+	FillColor color.Color `json:"-"`
+}
+
+// MarshalJSON makes TextWord implement the json.Marshaler interface.
+func (w TextWord) MarshalJSON() ([]byte, error) {
+	type textWord TextWord
+	v := struct {
+		textWord
+		Font  string `json:"font,omitempty"`
+		Color string `json:"color,omitempty"`
+	}{textWord: textWord(w)}
+	if w.Font != nil {
+		v.Font = w.Font.BaseFont()
+	}
+	if w.FillColor != nil {
+		v.Color = hexColor(w.FillColor)
+	}
+	return json.Marshal(v)
+}
+
+// TextBlockRole is the role of a TextBlock in a document.
+type TextBlockRole int
+
+// TextBlock roles.
+const (
+	TextBlockParagraph TextBlockRole = iota
+	TextBlockHeading
+	TextBlockListItem
+	TextBlockTable
+	TextBlockHeader
+	TextBlockFooter
+)
+
+// textBlockRoleNames are the names of the TextBlockRoles used in String() and their JSON
+// representation.
+var textBlockRoleNames = []string{"paragraph", "heading", "listItem", "table", "header", "footer"}
+
+// String returns the name of `role`.
+func (role TextBlockRole) String() string {
+	if role < 0 || int(role) >= len(textBlockRoleNames) {
+		return fmt.Sprintf("TextBlockRole(%d)", int(role))
+	}
+	return textBlockRoleNames[role]
+}
+
+// MarshalText makes TextBlockRole implement the encoding.TextMarshaler interface.
+func (role TextBlockRole) MarshalText() ([]byte, error) {
+	return []byte(role.String()), nil
+}
+
+// UnmarshalText makes TextBlockRole implement the encoding.TextUnmarshaler interface.
+func (role *TextBlockRole) UnmarshalText(text []byte) error {
+	for i, name := range textBlockRoleNames {
+		if name == string(text) {
+			*role = TextBlockRole(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown text block role %q", text)
+}
+
+// Layout returns the layout of the text on the page. The roles of the text blocks are guessed from
+// the content of this page only so no blocks are identified as page headers or footers. Use
+// NewDocumentLayout to guess the roles from the content of all the pages in a document.
+func (pt PageText) Layout() *PageLayout {
+	page := &PageLayout{BBox: pt.pageSize}
+	for _, para := range pt.paras {
+		page.Blocks = append(page.Blocks, para.toTextBlocks()...)
+	}
+	classifyBlocks([]*PageLayout{page})
+	return page
+}
+
+// NewDocumentLayout returns the layout of a document whose pages have layouts `pages`. The roles
+// of the text blocks on all the pages are guessed again from the content of the whole document:
+// headings are ranked by their font sizes across all pages and blocks that are repeated at the top
+// or bottom of the pages are identified as page headers or footers.
+func NewDocumentLayout(pages []*PageLayout) *DocumentLayout {
+	classifyBlocks(pages)
+	return &DocumentLayout{Pages: pages}
+}
+
+// classifyBlocks guesses the roles of the text blocks on `pages`, the pages of a document.
+//   - Tables are tables and blocks starting with list markers are list items.
+//   - Short blocks with fonts larger than the body text font, or short bold lines, are headings.
+//     Headings are ranked by font size.
+//   - Blocks at the top and bottom of pages whose text, ignoring numbers, is repeated on other
+//     pages are page headers and footers.
+//   - All other blocks are paragraphs.
+func classifyBlocks(pages []*PageLayout) {
+	// The body text font size is the font size of the most runes in the document.
+	runeCounts := map[float64]int{}
+	for _, page := range pages {
+		for i := range page.Blocks {
+			b := &page.Blocks[i]
+			b.Role, b.Level = b.structuralRole(), 0
+			b.forEachWord(func(w *TextWord) {
+				runeCounts[roundFontSize(w.FontSize)] += utf8.RuneCountInString(w.Text)
+			})
+		}
+	}
+	bodySize, bodyCount := 0.0, 0
+	for size, n := range runeCounts {
+		if n > bodyCount || (n == bodyCount && size < bodySize) {
+			bodySize, bodyCount = size, n
+		}
+	}
+
+	// Find the headings and rank them by font size.
+	headingSizes := map[*TextBlock]float64{}
+	sizeSet := map[float64]struct{}{}
+	for _, page := range pages {
+		for i := range page.Blocks {
+			b := &page.Blocks[i]
+			if size, ok := b.headingSize(bodySize); ok {
+				headingSizes[b] = size
+				sizeSet[size] = struct{}{}
+			}
+		}
+	}
+	sizes := make([]float64, 0, len(sizeSet))
+	for size := range sizeSet {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+	for b, size := range headingSizes {
+		b.Role = TextBlockHeading
+		b.Level = sort.Search(len(sizes), func(i int) bool { return sizes[i] <= size }) + 1
+		if b.Level > maxHeadingLevel {
+			b.Level = maxHeadingLevel
+		}
+	}
+
+	if len(pages) > 1 {
+		markHeadersFooters(pages)
+	}
+}
+
+// structuralRole returns the role of `b` that is implied by its structure.
+func (b *TextBlock) structuralRole() TextBlockRole {
+	switch {
+	case b.Cells != nil:
+		return TextBlockTable
+	case b.ListMarker != "":
+		return TextBlockListItem
+	}
+	return TextBlockParagraph
+}
+
+// forEachWord calls `f` for each word in `b`, including the words in its table cells.
+func (b *TextBlock) forEachWord(f func(w *TextWord)) {
+	for i := range b.Lines {
+		for j := range b.Lines[i].Words {
+			f(&b.Lines[i].Words[j])
+		}
+	}
+	for _, row := range b.Cells {
+		for i := range row {
+			row[i].forEachWord(f)
+		}
+	}
+}
+
+// headingSize returns the font size of `b` and true if `b` looks like a heading in a document
+// whose body text font size is `bodySize`.
+func (b *TextBlock) headingSize(bodySize float64) (float64, bool) {
+	if b.Role == TextBlockTable || len(b.Lines) == 0 || len(b.Lines) > maxHeadingLines ||
+		utf8.RuneCountInString(b.Text) > maxHeadingRunes || strings.IndexFunc(b.Text, unicode.IsLetter) < 0 {
+		return 0, false
+	}
+
+	// The font size and boldness of the block are those of the most runes in the block.
+	sizeCounts := map[float64]int{}
+	numRunes, numBold := 0, 0
+	b.forEachWord(func(w *TextWord) {
+		n := utf8.RuneCountInString(w.Text)
+		sizeCounts[roundFontSize(w.FontSize)] += n
+		numRunes += n
+		if isBoldFont(w.Font) {
+			numBold += n
+		}
+	})
+	size, count := 0.0, 0
+	for s, n := range sizeCounts {
+		if n > count || (n == count && s > size) {
+			size, count = s, n
+		}
+	}
+
+	if size >= headingFontSizeR*bodySize {
+		return size, true
+	}
+	bold := 2*numBold > numRunes
+	text := strings.TrimSpace(b.Text)
+	if bold && len(b.Lines) == 1 && size >= bodySize && !strings.HasSuffix(text, ".") {
+		return size, true
+	}
+	return 0, false
+}
+
+// markHeadersFooters marks the blocks at the tops and bottoms of `pages` whose text, ignoring
+// numbers, is repeated on other pages as page headers and footers.
+func markHeadersFooters(pages []*PageLayout) {
+	type candidate struct {
+		block *TextBlock
+		role  TextBlockRole
+	}
+	candidates := map[string][]candidate{}
+	pageCounts := map[string]map[int]struct{}{}
+	for pageIdx, page := range pages {
+		margin := headerFooterR * page.BBox.Height()
+		for i := range page.Blocks {
+			b := &page.Blocks[i]
+			var role TextBlockRole
+			switch {
+			case b.BBox.Lly >= page.BBox.Ury-margin:
+				role = TextBlockHeader
+			case b.BBox.Ury <= page.BBox.Lly+margin:
+				role = TextBlockFooter
+			default:
+				continue
+			}
+			key := headerFooterKey(b.Text)
+			if key == "" {
+				continue
+			}
+			candidates[key] = append(candidates[key], candidate{b, role})
+			if pageCounts[key] == nil {
+				pageCounts[key] = map[int]struct{}{}
+			}
+			pageCounts[key][pageIdx] = struct{}{}
+		}
+	}
+	for key, cands := range candidates {
+		if len(pageCounts[key]) < 2 {
+			continue
+		}
+		for _, c := range cands {
+			c.block.Role, c.block.Level = c.role, 0
+		}
+	}
+}
+
+// headerFooterKey returns the text used to find page headers and footers with text `text` on
+// different pages. Runs of digits, which are typically page numbers, are replaced by "#".
+func headerFooterKey(text string) string {
+	var sb strings.Builder
+	inDigits := false
+	for _, field := range strings.Fields(strings.ToLower(text)) {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		for _, r := range field {
+			if unicode.IsDigit(r) {
+				if !inDigits {
+					sb.WriteByte('#')
+				}
+				inDigits = true
+				continue
+			}
+			inDigits = false
+			sb.WriteRune(r)
+		}
+		inDigits = false
+	}
+	return sb.String()
+}
+
+// roundFontSize rounds `size` to the nearest half point so that font sizes that differ by
+// rounding errors are treated as the same size.
+func roundFontSize(size float64) float64 {
+	return math.Round(2*size) / 2
+}
+
+// isBoldFont returns true if `font`'s name indicates that it is a bold font.
+func isBoldFont(font *model.PdfFont) bool {
+	if font == nil {
+		return false
+	}
+	name := strings.ToLower(font.BaseFont())
+	for _, weight := range []string{"bold", "black", "heavy", "demi"} {
+		if strings.Contains(name, weight) {
+			return true
+		}
+	}
+	return false
+}
+
+// toTextBlocks returns the TextBlocks corresponding to `p`. Paras with lines starting with list
+// markers are split into one block per list item.
+func (p *textPara) toTextBlocks() []TextBlock {
+	if p.table != nil {
+		return []TextBlock{p.table.toTextBlock()}
+	}
+	var blocks []TextBlock
+	start := 0
+	for i := 1; i <= len(p.lines); i++ {
+		if i < len(p.lines) && listMarker(p.lines[i]) == "" {
+			continue
+		}
+		blocks = append(blocks, newTextBlock(p.lines[start:i]))
+		start = i
+	}
+	return blocks
+}
+
+// toTextBlock returns the TextBlock corresponding to `t`.
+func (t *textTable) toTextBlock() TextBlock {
+	block := TextBlock{Role: TextBlockTable, Cells: make([][]TextBlock, t.h)}
+	first := true
+	for y := 0; y < t.h; y++ {
+		block.Cells[y] = make([]TextBlock, t.w)
+		for x := 0; x < t.w; x++ {
+			cell := t.get(x, y)
+			if cell == nil {
+				// Covered by a spanning cell.
+				continue
+			}
+			if len(cell.lines) == 0 {
+				block.Cells[y][x] = TextBlock{Role: TextBlockParagraph, BBox: cell.PdfRectangle}
+			} else {
+				block.Cells[y][x] = newTextBlock(cell.lines)
+			}
+			block.Cells[y][x].RowSpan, block.Cells[y][x].ColSpan = 1, 1
+			if span, ok := t.spans[cellIndex(x, y)]; ok {
+				block.Cells[y][x].RowSpan, block.Cells[y][x].ColSpan = span.rows, span.cols
+			}
+			if first {
+				block.BBox = block.Cells[y][x].BBox
+				first = false
+			} else {
+				block.BBox = rectUnion(block.BBox, block.Cells[y][x].BBox)
+			}
+		}
+	}
+	para := textPara{table: t}
+	block.Text = para.text()
+	return block
+}
+
+// newTextBlock returns a paragraph or list item TextBlock containing `lines`.
+func newTextBlock(lines []*textLine) TextBlock {
+	block := TextBlock{
+		Role:       TextBlockParagraph,
+		ListMarker: listMarker(lines[0]),
+		Text:       makeTextPara(model.PdfRectangle{}, lines).text(),
+		Lines:      make([]TextLine, 0, len(lines)),
+	}
+	if block.ListMarker != "" {
+		block.Role = TextBlockListItem
+	}
+	numRTL := 0
+	for i, l := range lines {
+		line := l.toTextLine()
+		if i == 0 {
+			block.BBox = line.BBox
+		} else {
+			block.BBox = rectUnion(block.BBox, line.BBox)
+		}
+		if l.rtl {
+			numRTL++
+		}
+		block.Lines = append(block.Lines, line)
+	}
+	block.RTL = 2*numRTL > len(lines)
+	return block
+}
+
+// toTextLine returns the TextLine corresponding to `l`.
+func (l *textLine) toTextLine() TextLine {
+	line := TextLine{Text: l.text()}
+	for i, marks := range l.wholeWords() {
+		word := TextWord{
+			BBox:      marks[0].originaBBox,
+			Font:      marks[0].font,
+			FillColor: marks[0].fillColor,
+		}
+		var text strings.Builder
+		for _, tm := range marks {
+			text.WriteString(tm.text)
+			word.BBox = rectUnion(word.BBox, tm.originaBBox)
+			word.FontSize = math.Max(word.FontSize, tm.fontsize)
+		}
+		word.Text = text.String()
+		if i == 0 {
+			line.BBox = word.BBox
+		} else {
+			line.BBox = rectUnion(line.BBox, word.BBox)
+		}
+		line.Words = append(line.Words, word)
+	}
+	return line
+}
+
+// wholeWords returns the marks of the whole words in `l` in reading order.
+func (l *textLine) wholeWords() [][]*textMark {
+	var words [][]*textMark
+	if l.order != nil {
+		var word []*textMark
+		for _, tm := range l.order {
+			if tm == nil {
+				if len(word) > 0 {
+					words = append(words, word)
+				}
+				word = nil
+				continue
+			}
+			word = append(word, tm)
+		}
+		if len(word) > 0 {
+			words = append(words, word)
+		}
+		return words
+	}
+	for i, w := range l.words {
+		if i == 0 || w.newWord {
+			words = append(words, nil)
+		}
+		words[len(words)-1] = append(words[len(words)-1], w.marks...)
+	}
+	return words
+}
+
+// listBullets are the characters that are used as bullets of list items. wordBullets are the
+// bullets that are also used at the start of other words.
+const (
+	listBullets = "•◦▪▫‣⁃●○■□◆◇►▸✓✔❖·" + wordBullets
+	wordBullets = "-–—*"
+)
+
+// reEnumerator matches the enumerators of list items such as "1.", "a)", "(iv)" and "2.3.".
+var reEnumerator = regexp.MustCompile(
+	`^(\(?([0-9]{1,3}|[a-zA-Z]|[ivxlcdm]{1,6}|[IVXLCDM]{1,6})\)|([0-9]{1,3}\.)+|[a-zA-Z]\.|[ivxlcdm]{1,6}\.|[IVXLCDM]{1,6}\.)$`)
+
+// listMarker returns the list marker at the start of `l` if `l` starts with one, or "" if it
+// doesn't.
+func listMarker(l *textLine) string {
+	words := l.wholeWords()
+	if len(words) == 0 {
+		return ""
+	}
+	var first strings.Builder
+	for _, tm := range words[0] {
+		first.WriteString(tm.text)
+	}
+	text := first.String()
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return ""
+	}
+	if strings.ContainsRune(listBullets, runes[0]) {
+		// Dashes and asterisks only mark list items when they are separate words. e.g. "- item"
+		// is a list item but "-5" and "*note" aren't.
+		if len(runes) == 1 || !strings.ContainsRune(wordBullets, runes[0]) {
+			return string(runes[0])
+		}
+		return ""
+	}
+	if len(words) > 1 && reEnumerator.MatchString(text) {
+		return text
+	}
+	return ""
+}
+
+// hexColor returns the #rrggbb representation of `c`.
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
@@ -0,0 +1,146 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes the JSON serialization of `d` to `w`.
+func (d *DocumentLayout) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteJSON writes the JSON serialization of `page` to `w`.
+func (page *PageLayout) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(page)
+}
+
+// WriteHTML writes `d` to `w` as an HTML document. Each page is written as a section containing
+// the page's blocks as semantic HTML elements: headings as h1-h6 elements, consecutive list items
+// as ul or ol elements, tables as table elements, page headers and footers as header and footer
+// elements and paragraphs as p elements.
+func (d *DocumentLayout) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n</head>\n<body>\n")
+	for _, page := range d.Pages {
+		page.writeHTML(bw)
+	}
+	bw.WriteString("</body>\n</html>\n")
+	return bw.Flush()
+}
+
+// WriteHTML writes `page` to `w` as an HTML document. See DocumentLayout.WriteHTML.
+func (page *PageLayout) WriteHTML(w io.Writer) error {
+	d := DocumentLayout{Pages: []*PageLayout{page}}
+	return d.WriteHTML(w)
+}
+
+// writeHTML writes the HTML section element of `page` to `w`.
+func (page *PageLayout) writeHTML(w *bufio.Writer) {
+	w.WriteString("<section class=\"page\">\n")
+	list := ""
+	for i := range page.Blocks {
+		b := &page.Blocks[i]
+
+		// Open and close the lists around runs of list items.
+		tag := ""
+		if b.Role == TextBlockListItem {
+			tag = "ul"
+			if reEnumerator.MatchString(b.ListMarker) {
+				tag = "ol"
+			}
+		}
+		if tag != list {
+			if list != "" {
+				w.WriteString("</" + list + ">\n")
+			}
+			if tag != "" {
+				w.WriteString("<" + tag + ">\n")
+			}
+			list = tag
+		}
+
+		switch b.Role {
+		case TextBlockHeading:
+			level := b.Level
+			if level < 1 {
+				level = 1
+			}
+			tag := fmt.Sprintf("h%d", level)
+			writeHTMLElement(w, tag, "", b.RTL, b.Text)
+			w.WriteString("\n")
+		case TextBlockListItem:
+			text := strings.TrimSpace(strings.TrimPrefix(b.Text, b.ListMarker))
+			writeHTMLElement(w, "li", "", b.RTL, text)
+			w.WriteString("\n")
+		case TextBlockTable:
+			b.writeTableHTML(w)
+		case TextBlockHeader, TextBlockFooter:
+			tag := "header"
+			if b.Role == TextBlockFooter {
+				tag = "footer"
+			}
+			w.WriteString("<" + tag + ">")
+			writeHTMLElement(w, "p", "", b.RTL, b.Text)
+			w.WriteString("</" + tag + ">\n")
+		default:
+			writeHTMLElement(w, "p", "", b.RTL, b.Text)
+			w.WriteString("\n")
+		}
+	}
+	if list != "" {
+		w.WriteString("</" + list + ">\n")
+	}
+	w.WriteString("</section>\n")
+}
+
+// writeTableHTML writes the HTML table element of table block `b` to `w`. Spanning cells are
+// written with rowspan and colspan attributes and the positions they cover are skipped.
+func (b *TextBlock) writeTableHTML(w *bufio.Writer) {
+	w.WriteString("<table>\n")
+	for _, row := range b.Cells {
+		w.WriteString("<tr>")
+		for i := range row {
+			cell := &row[i]
+			if !cell.isCell() {
+				continue
+			}
+			attrs := ""
+			if cell.RowSpan > 1 {
+				attrs += fmt.Sprintf(" rowspan=\"%d\"", cell.RowSpan)
+			}
+			if cell.ColSpan > 1 {
+				attrs += fmt.Sprintf(" colspan=\"%d\"", cell.ColSpan)
+			}
+			writeHTMLElement(w, "td", attrs, cell.RTL, cell.Text)
+		}
+		w.WriteString("</tr>\n")
+	}
+	w.WriteString("</table>\n")
+}
+
+// writeHTMLElement writes an HTML element with tag `tag`, attributes `attrs` and text content
+// `text` to `w`. The lines of `text` are joined with spaces. The element is marked as
+// right-to-left if `rtl` is true.
+func writeHTMLElement(w *bufio.Writer, tag, attrs string, rtl bool, text string) {
+	w.WriteString("<" + tag + attrs)
+	if rtl {
+		w.WriteString(" dir=\"rtl\"")
+	}
+	w.WriteString(">")
+	w.WriteString(html.EscapeString(strings.Join(strings.Fields(text), " ")))
+	w.WriteString("</" + tag + ">")
+}
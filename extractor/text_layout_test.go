@@ -0,0 +1,121 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestDocumentLayout tests the roles of the blocks in document layouts and their export.
+func TestDocumentLayout(t *testing.T) {
+	pageContents := []string{`
+		BT /Helvetica 10 Tf 72 770 Td (ACME Report) Tj ET
+		BT /Helvetica 24 Tf 72 650 Td (Introduction) Tj ET
+		BT /Helvetica 16 Tf 72 600 Td (Background) Tj ET
+		BT /Helvetica 12 Tf 72 560 Td (This is the body text of the report.) Tj
+			0 -14 Td (It has two lines & more.) Tj ET
+		BT /Helvetica 12 Tf 72 480 Td (1. First item) Tj 0 -14 Td (2. Second item) Tj ET
+		BT /Helvetica 10 Tf 300 40 Td (Page 1) Tj ET
+		`, `
+		BT /Helvetica 10 Tf 72 770 Td (ACME Report) Tj ET
+		BT /Helvetica 16 Tf 72 650 Td (Conclusion) Tj ET
+		BT /Helvetica 12 Tf 72 600 Td (The report is concluded here.) Tj ET
+		BT /Helvetica 10 Tf 300 40 Td (Page 2) Tj ET
+		`,
+	}
+	type expectedBlock struct {
+		role  TextBlockRole
+		level int
+		text  string
+	}
+	expected := [][]expectedBlock{
+		{
+			{TextBlockHeader, 0, "ACME Report"},
+			{TextBlockHeading, 1, "Introduction"},
+			{TextBlockHeading, 2, "Background"},
+			{TextBlockParagraph, 0, "This is the body text of the report.\nIt has two lines & more."},
+			{TextBlockListItem, 0, "1. First item"},
+			{TextBlockListItem, 0, "2. Second item"},
+			{TextBlockFooter, 0, "Page 1"},
+		},
+		{
+			{TextBlockHeader, 0, "ACME Report"},
+			{TextBlockHeading, 2, "Conclusion"},
+			{TextBlockParagraph, 0, "The report is concluded here."},
+			{TextBlockFooter, 0, "Page 2"},
+		},
+	}
+
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("Helvetica", helvetica.ToPdfObject())
+
+	var pages []*PageLayout
+	for _, contents := range pageContents {
+		e := Extractor{resources: resources, contents: contents, mediaBox: r(0, 0, 600, 800)}
+		pageText, _, _, err := e.ExtractPageText()
+		if err != nil {
+			t.Fatalf("Error extracting text: err=%v", err)
+		}
+		pages = append(pages, pageText.Layout())
+	}
+	doc := NewDocumentLayout(pages)
+
+	for i, page := range doc.Pages {
+		if len(page.Blocks) != len(expected[i]) {
+			t.Fatalf("page %d: %d blocks. Expected %d", i+1, len(page.Blocks), len(expected[i]))
+		}
+		for j, b := range page.Blocks {
+			exp := expected[i][j]
+			if b.Role != exp.role || b.Level != exp.level || b.Text != exp.text {
+				t.Fatalf("page %d block %d: got %s %d %q. Expected %s %d %q", i+1, j,
+					b.Role, b.Level, b.Text, exp.role, exp.level, exp.text)
+			}
+		}
+	}
+	word := doc.Pages[0].Blocks[3].Lines[1].Words[4]
+	if word.Text != "&" || word.FontSize != 12 || word.BBox.Lly != 546 {
+		t.Fatalf("Unexpected word %+v", word)
+	}
+
+	var buf bytes.Buffer
+	if err := doc.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded DocumentLayout
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(decoded.Pages) != 2 || decoded.Pages[0].Blocks[2].Role != TextBlockHeading ||
+		decoded.Pages[0].Blocks[2].Lines[0].Words[0].Text != "Background" {
+		t.Fatalf("JSON round trip failed: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"font": "Helvetica"`) {
+		t.Fatalf("JSON doesn't contain font name: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := doc.WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	for _, s := range []string{
+		"<header><p>ACME Report</p></header>",
+		"<h1>Introduction</h1>",
+		"<h2>Background</h2>",
+		"<p>This is the body text of the report. It has two lines &amp; more.</p>",
+		"<ol>\n<li>First item</li>\n<li>Second item</li>\n</ol>",
+		"<footer><p>Page 2</p></footer>",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Fatalf("HTML doesn't contain %q:\n%s", s, buf.String())
+		}
+	}
+}
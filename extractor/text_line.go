@@ -19,6 +19,10 @@ type textLine struct {
 	depth              float64     // Distance from bottom of line to top of page.
 	words              []*textWord // Words in this line.
 	fontsize           float64     // Largest word font size.
+	rtl                bool        // Is the base direction of the line right-to-left?
+	// The marks in this line in reading order, with nil marks for the spaces between words, if the
+	// line contains right-to-left text. nil if the words are read in the order they appear in.
+	order []*textMark
 }
 
 // newTextLine creates a line with the font and bbox size of the first word in `b`, removes the word
@@ -47,6 +51,17 @@ func (l *textLine) bbox() model.PdfRectangle {
 
 // text returns the extracted text contained in line.
 func (l *textLine) text() string {
+	if l.order != nil {
+		var sb strings.Builder
+		for _, tm := range l.order {
+			if tm == nil {
+				sb.WriteString(" ")
+			} else {
+				sb.WriteString(tm.text)
+			}
+		}
+		return sb.String()
+	}
 	var words []string
 	for _, w := range l.words {
 		if w.newWord {
@@ -61,6 +76,16 @@ func (l *textLine) text() string {
 // `offset` is used to give the TextMarks the correct Offset values.
 func (l *textLine) toTextMarks(offset *int) []TextMark {
 	var marks []TextMark
+	if l.order != nil {
+		for _, tm := range l.order {
+			if tm == nil {
+				marks = appendSpaceMark(marks, offset, " ")
+			} else {
+				marks = appendTextMark(marks, offset, tm.ToTextMark())
+			}
+		}
+		return marks
+	}
 	for _, w := range l.words {
 		if w.newWord {
 			marks = appendSpaceMark(marks, offset, " ")
@@ -106,6 +131,9 @@ func (l *textLine) markWordBoundaries() {
 // a space.
 // TODO(peterwilliams97): Figure out a better heuristic
 func (l *textLine) endsInHyphen() bool {
+	if l.order != nil {
+		return endsInHyphen([]rune(l.text()))
+	}
 	// Computing l.text() is a little expensive so we filter out simple cases first.
 	lastWord := l.words[len(l.words)-1]
 	runes := []rune(lastWord.text)
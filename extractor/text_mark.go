@@ -30,14 +30,21 @@ type textMark struct {
 	originaBBox        model.PdfRectangle // Bounding box without orientation correction.
 	fillColor          color.Color        // Text fill color.
 	strokeColor        color.Color        // Text stroke color.
+	paintColor         color.Color        // The color the glyph is painted with.
+	visibility         TextVisibility     // Can the text be seen on the page?
+	mc                 *markedContent     // The innermost marked-content sequence containing the text.
+	mcid               int                // The MCID of the text's marked content. -1 for none.
+	structType         string             // The structure type of the text.
+	lang               string             // The language of the text.
 }
 
 // newTextMark returns a textMark for text `text` rendered with text rendering matrix (TRM) `trm`
 // and end of character device coordinates `end`. `spaceWidth` is our best guess at the width of a
-// space in the font the text is rendered in device coordinates.
+// space in the font the text is rendered in device coordinates. `vertical` is true for glyphs
+// drawn in vertical writing mode.
 func (to *textObject) newTextMark(text string, trm transform.Matrix, end transform.Point,
 	spaceWidth float64, font *model.PdfFont, charspacing float64,
-	fillColor, strokeColor color.Color) (textMark, bool) {
+	fillColor, strokeColor color.Color, vertical bool) (textMark, bool) {
 	theta := trm.Angle()
 	orient := nearestMultiple(theta, orientationGranularity)
 	var height float64
@@ -78,6 +85,13 @@ func (to *textObject) newTextMark(text string, trm transform.Matrix, end transfo
 	}
 	bbox = clipped
 
+	// Vertically written glyphs are upright but they are read from top to bottom in columns that
+	// are read from right to left. This is how text rotated clockwise by 90 degrees is read so we
+	// arrange the glyphs as if they were rotated.
+	if vertical && orient%360 == 0 {
+		orient = 90
+	}
+
 	// The orientedBBox is bbox rotated and translated so the base of the character is at Lly.
 	orientedBBox := bbox
 	orientedMBox := to.e.mediaBox
@@ -150,6 +164,10 @@ func (tm *textMark) ToTextMark() TextMark {
 		FontSize:    tm.fontsize,
 		FillColor:   tm.fillColor,
 		StrokeColor: tm.strokeColor,
+		Visibility:  tm.visibility,
+		MCID:        tm.mcid,
+		StructType:  tm.structType,
+		Lang:        tm.lang,
 	}
 }
 
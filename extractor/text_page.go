@@ -242,10 +242,19 @@ func (paras paraList) sortReadingOrder() {
 	if len(paras) <= 1 {
 		return
 	}
+	// Columns of right-to-left text are read from right to left so we sort the paras of such
+	// pages as if the page was mirrored.
+	rtl := paras.rtl()
+	if rtl {
+		paras.mirrorX()
+	}
 	paras.computeEBBoxes()
 	sort.Slice(paras, func(i, j int) bool { return diffDepthReading(paras[i], paras[j]) <= 0 })
 	order := paras.topoOrder()
 	paras.reorder(order)
+	if rtl {
+		paras.mirrorX()
+	}
 }
 
 // topoOrder returns the ordering of the topological sort of `paras` using readBefore() to determine
@@ -63,8 +63,11 @@ func (p *textPara) depth() float64 {
 	if len(p.lines) > 0 {
 		return p.lines[0].depth
 	}
-	// Use the top left cell of the table if there is one
-	return p.table.get(0, 0).depth()
+	// Use the top left cell of the table that contains text if there is one
+	if p.table != nil {
+		return p.table.depth()
+	}
+	return 0
 }
 
 // text is a convenience function that returns the text `p` including tables.
@@ -301,6 +304,7 @@ func (b *wordBag) arrangeText() *textPara {
 			}
 
 			line.markWordBoundaries()
+			line.resolveDirection()
 			lines = append(lines, line)
 		}
 	}
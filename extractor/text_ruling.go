@@ -0,0 +1,487 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// ruling is a horizontal or vertical line drawn on a page. Rulings are the lines that can separate
+// the cells of tables. They are found from stroked line segments and from filled rectangles.
+// Coordinates are in device space.
+type ruling struct {
+	horizontal bool    // Is this a horizontal line?
+	pos        float64 // y coordinate of a horizontal line. x coordinate of a vertical line.
+	lo, hi     float64 // Extent of the line along its direction.
+}
+
+// rulingsOf returns the rulings drawn by path painting operator `operand` painting `p` with stroke
+// color `stroke` and fill color `fill`. The straight lines of stroked paths and the filled
+// axis-aligned rectangles are rulings. Lines and rectangles painted white are assumed to be
+// invisible. The operators that close the path before stroking it close `p`.
+func rulingsOf(p *shapePath, operand string, stroke, fill color.Color) []ruling {
+	var rulings []ruling
+	switch operand {
+	case "s", "b", "b*":
+		p.add("h", nil, transform.IdentityMatrix())
+	}
+	switch operand {
+	case "S", "s", "B", "B*", "b", "b*":
+		if isWhite(stroke) {
+			break
+		}
+		for _, sp := range p.subpaths {
+			start := sp.Start
+			for _, seg := range sp.Segments {
+				if !seg.Curve {
+					if r, ok := segmentRuling(start, seg.End); ok {
+						rulings = append(rulings, r)
+					}
+				}
+				start = seg.End
+			}
+			if sp.Closed {
+				if r, ok := segmentRuling(start, sp.Start); ok {
+					rulings = append(rulings, r)
+				}
+			}
+		}
+	}
+	switch operand {
+	case "f", "F", "f*", "B", "B*", "b", "b*":
+		if isWhite(fill) {
+			break
+		}
+		for _, sp := range p.subpaths {
+			if r, ok := subpathRect(sp); ok {
+				rulings = append(rulings, rectRulings(r)...)
+			}
+		}
+	}
+	return rulings
+}
+
+// subpathRect returns the rectangle filled by `sp` if `sp` is a quadrilateral whose sides are
+// horizontal or vertical, such as a rectangle added by the `re` operator and drawn with a CTM that
+// keeps it axis-aligned. Filling a subpath closes it.
+func subpathRect(sp Subpath) (model.PdfRectangle, bool) {
+	corners, ok := quadVertices(sp, false)
+	if !ok {
+		return model.PdfRectangle{}, false
+	}
+	for i, a := range corners {
+		if _, ok := segmentRuling(a, corners[(i+1)%4]); !ok {
+			return model.PdfRectangle{}, false
+		}
+	}
+	return model.PdfRectangle{
+		Llx: math.Min(corners[0].X, corners[2].X),
+		Lly: math.Min(corners[0].Y, corners[2].Y),
+		Urx: math.Max(corners[0].X, corners[2].X),
+		Ury: math.Max(corners[0].Y, corners[2].Y),
+	}, true
+}
+
+// segmentRuling returns the ruling along the line segment from `a` to `b` if the segment is
+// horizontal or vertical.
+func segmentRuling(a, b PathPoint) (ruling, bool) {
+	dx, dy := math.Abs(a.X-b.X), math.Abs(a.Y-b.Y)
+	switch {
+	case dy <= rulingTol && dx >= minRulingLength:
+		return ruling{horizontal: true, pos: (a.Y + b.Y) / 2,
+			lo: math.Min(a.X, b.X), hi: math.Max(a.X, b.X)}, true
+	case dx <= rulingTol && dy >= minRulingLength:
+		return ruling{horizontal: false, pos: (a.X + b.X) / 2,
+			lo: math.Min(a.Y, b.Y), hi: math.Max(a.Y, b.Y)}, true
+	}
+	return ruling{}, false
+}
+
+// rectRulings returns the rulings drawn by filling rectangle `r`. Thin rectangles are drawn as
+// lines. The edges of other rectangles are the boundaries of shaded regions such as table rows or
+// cells.
+func rectRulings(r model.PdfRectangle) []ruling {
+	w, h := r.Width(), r.Height()
+	switch {
+	case w <= maxRulingThickness && h <= maxRulingThickness:
+		return nil
+	case h <= maxRulingThickness:
+		return []ruling{{horizontal: true, pos: (r.Lly + r.Ury) / 2, lo: r.Llx, hi: r.Urx}}
+	case w <= maxRulingThickness:
+		return []ruling{{horizontal: false, pos: (r.Llx + r.Urx) / 2, lo: r.Lly, hi: r.Ury}}
+	}
+	return []ruling{
+		{horizontal: true, pos: r.Lly, lo: r.Llx, hi: r.Urx},
+		{horizontal: true, pos: r.Ury, lo: r.Llx, hi: r.Urx},
+		{horizontal: false, pos: r.Llx, lo: r.Lly, hi: r.Ury},
+		{horizontal: false, pos: r.Urx, lo: r.Lly, hi: r.Ury},
+	}
+}
+
+// isWhite returns true if `c` is white or close to it.
+func isWhite(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	const limit = 0xf000
+	return r >= limit && g >= limit && b >= limit
+}
+
+// ruledGrid is a grid of table cells bounded by rulings. Cells that aren't separated by rulings are
+// merged into cells that span several rows and/or columns.
+type ruledGrid struct {
+	xs     []float64   // x coordinates of the column boundaries in increasing order.
+	ys     []float64   // y coordinates of the row boundaries in decreasing order.
+	cells  []ruledCell // The (possibly merged) cells of the grid.
+	cellOf []int       // cellOf[y*w+x] is the index in `cells` of the cell covering column x, row y.
+}
+
+// ruledCell is a cell in a ruledGrid. It covers columns x to x+w-1 and rows y to y+h-1.
+type ruledCell struct {
+	x, y, w, h int
+}
+
+// findRuledTables finds the tables whose cells are bounded by `rulings` and returns paras
+// containing those tables along with the `marks` that aren't in the tables. The marks are assigned
+// to table cells by the centers of their bounding boxes and the order of the remaining marks is
+// preserved.
+// Tables with fewer than minRuledTableCells non-empty cells are not returned. This excludes boxes
+// and frames drawn around text.
+func findRuledTables(marks []*textMark, rulings []ruling, pageSize model.PdfRectangle) (
+	paraList, []*textMark) {
+	grids := makeRuledGrids(rulings)
+	if len(grids) == 0 {
+		return nil, marks
+	}
+
+	// gridOf[i] and cellOf[i] are the grid and cell containing marks[i]. gridOf[i] is -1 for marks
+	// that aren't in a grid.
+	gridOf := make([]int, len(marks))
+	cellOf := make([]int, len(marks))
+	filled := make([]map[int]struct{}, len(grids))
+	for i := range grids {
+		filled[i] = map[int]struct{}{}
+	}
+	for i, tm := range marks {
+		gridOf[i] = -1
+		x, y := (tm.Llx+tm.Urx)/2, (tm.Lly+tm.Ury)/2
+		for j, g := range grids {
+			if c, ok := g.cellAt(x, y); ok {
+				gridOf[i], cellOf[i] = j, c
+				filled[j][c] = struct{}{}
+				break
+			}
+		}
+	}
+
+	cellMarks := make([]map[int][]*textMark, len(grids))
+	for j := range grids {
+		cellMarks[j] = map[int][]*textMark{}
+	}
+	var rest []*textMark
+	for i, tm := range marks {
+		j := gridOf[i]
+		if j < 0 || len(filled[j]) < minRuledTableCells {
+			rest = append(rest, tm)
+			continue
+		}
+		cellMarks[j][cellOf[i]] = append(cellMarks[j][cellOf[i]], tm)
+	}
+
+	var tables paraList
+	for j, g := range grids {
+		if len(filled[j]) < minRuledTableCells {
+			continue
+		}
+		para := g.newTablePara(cellMarks[j], pageSize)
+		para.table.log("ruled")
+		tables = append(tables, para)
+	}
+	return tables, rest
+}
+
+// makeRuledGrids returns the grids formed by `rulings`. Each set of connected horizontal and
+// vertical rulings forms at most one grid.
+func makeRuledGrids(rulings []ruling) []*ruledGrid {
+	var hs, vs []ruling
+	for _, r := range rulings {
+		if r.horizontal {
+			hs = append(hs, r)
+		} else {
+			vs = append(vs, r)
+		}
+	}
+	hs, vs = mergeRulings(hs), mergeRulings(vs)
+	if len(hs) < 2 || len(vs) < 2 {
+		return nil
+	}
+
+	// Group the rulings into connected sets. Rulings hs[i] and vs[j] are numbered i and len(hs)+j.
+	parent := make([]int, len(hs)+len(vs))
+	for i := range parent {
+		parent[i] = i
+	}
+	for i, h := range hs {
+		for j, v := range vs {
+			if crosses(h, v) {
+				union(parent, i, len(hs)+j)
+			}
+		}
+	}
+	var roots []int
+	groupH := map[int][]ruling{}
+	groupV := map[int][]ruling{}
+	for i := range parent {
+		root := find(parent, i)
+		if _, ok := groupH[root]; !ok {
+			if _, ok := groupV[root]; !ok {
+				roots = append(roots, root)
+			}
+		}
+		if i < len(hs) {
+			groupH[root] = append(groupH[root], hs[i])
+		} else {
+			groupV[root] = append(groupV[root], vs[i-len(hs)])
+		}
+	}
+
+	var grids []*ruledGrid
+	for _, root := range roots {
+		if g := newRuledGrid(groupH[root], groupV[root]); g != nil {
+			grids = append(grids, g)
+		}
+	}
+	return grids
+}
+
+// newRuledGrid returns the grid formed by connected horizontal rulings `hs` and vertical rulings
+// `vs` or nil if they don't form a grid with at least 2 cells.
+func newRuledGrid(hs, vs []ruling) *ruledGrid {
+	if len(hs) < 2 || len(vs) < 2 {
+		return nil
+	}
+	xs := rulingPositions(vs)
+	ys := rulingPositions(hs)
+	for i, j := 0, len(ys)-1; i < j; i, j = i+1, j-1 {
+		ys[i], ys[j] = ys[j], ys[i]
+	}
+	w, h := len(xs)-1, len(ys)-1
+	if w < 1 || h < 1 || w*h < 2 {
+		return nil
+	}
+
+	// Merge the neighboring grid positions that aren't separated by a ruling.
+	parent := make([]int, w*h)
+	for i := range parent {
+		parent[i] = i
+	}
+	for y := 0; y < h; y++ {
+		midY := (ys[y] + ys[y+1]) / 2
+		for x := 0; x < w; x++ {
+			midX := (xs[x] + xs[x+1]) / 2
+			if x < w-1 && !covers(vs, xs[x+1], midY) {
+				union(parent, y*w+x, y*w+x+1)
+			}
+			if y < h-1 && !covers(hs, ys[y+1], midX) {
+				union(parent, y*w+x, (y+1)*w+x)
+			}
+		}
+	}
+
+	g := &ruledGrid{xs: xs, ys: ys, cellOf: make([]int, w*h)}
+	cellIdx := map[int]int{}
+	count := map[int]int{}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			root := find(parent, y*w+x)
+			i, ok := cellIdx[root]
+			if !ok {
+				i = len(g.cells)
+				cellIdx[root] = i
+				g.cells = append(g.cells, ruledCell{x: x, y: y, w: 1, h: 1})
+			}
+			c := &g.cells[i]
+			if x < c.x {
+				c.w += c.x - x
+				c.x = x
+			}
+			if x >= c.x+c.w {
+				c.w = x - c.x + 1
+			}
+			if y >= c.y+c.h {
+				c.h = y - c.y + 1
+			}
+			g.cellOf[y*w+x] = i
+			count[i]++
+		}
+	}
+	if len(g.cells) < 2 {
+		return nil
+	}
+	// Merged cells must be rectangular. Grids whose rulings form other shapes are not tables.
+	for i, c := range g.cells {
+		if count[i] != c.w*c.h {
+			common.Log.Debug("newRuledGrid: non-rectangular cell %+v", c)
+			return nil
+		}
+	}
+	return g
+}
+
+// cellAt returns the index of the cell of `g` containing point (`x`, `y`).
+func (g *ruledGrid) cellAt(x, y float64) (int, bool) {
+	w, h := len(g.xs)-1, len(g.ys)-1
+	if x < g.xs[0] || x > g.xs[w] || y > g.ys[0] || y < g.ys[h] {
+		return 0, false
+	}
+	col := 0
+	for col < w-1 && x > g.xs[col+1] {
+		col++
+	}
+	row := 0
+	for row < h-1 && y < g.ys[row+1] {
+		row++
+	}
+	return g.cellOf[row*w+col], true
+}
+
+// newTablePara returns a textPara containing the table formed by `g` and `cellMarks`, the marks in
+// each cell of `g`.
+func (g *ruledGrid) newTablePara(cellMarks map[int][]*textMark, pageSize model.PdfRectangle) *textPara {
+	w, h := len(g.xs)-1, len(g.ys)-1
+	bbox := model.PdfRectangle{Llx: g.xs[0], Lly: g.ys[h], Urx: g.xs[w], Ury: g.ys[0]}
+	t := &textTable{
+		PdfRectangle: bbox,
+		w:            w,
+		h:            h,
+		cells:        map[uint64]*textPara{},
+		spans:        map[uint64]cellSpan{},
+	}
+	for i, c := range g.cells {
+		rect := model.PdfRectangle{
+			Llx: g.xs[c.x],
+			Lly: g.ys[c.y+c.h],
+			Urx: g.xs[c.x+c.w],
+			Ury: g.ys[c.y],
+		}
+		t.put(c.x, c.y, newCellPara(cellMarks[i], rect, pageSize))
+		if c.w > 1 || c.h > 1 {
+			t.spans[cellIndex(c.x, c.y)] = cellSpan{cols: c.w, rows: c.h}
+		}
+	}
+	return &textPara{PdfRectangle: bbox, eBBox: bbox, table: t}
+}
+
+// newCellPara returns a table cell textPara with bounding box `rect` containing `marks`. All the
+// text in a cell is arranged as a single paragraph.
+func newCellPara(marks []*textMark, rect model.PdfRectangle, pageSize model.PdfRectangle) *textPara {
+	para := &textPara{PdfRectangle: rect, eBBox: rect, isCell: true}
+	if len(marks) == 0 {
+		return para
+	}
+	words := makeTextWords(marks, pageSize)
+	if len(words) == 0 {
+		return para
+	}
+	if p := makeWordBag(words, pageSize.Ury).arrangeText(); p != nil {
+		para.lines = p.lines
+	}
+	return para
+}
+
+// mergeRulings returns `rulings`, which are all horizontal or all vertical, with the overlapping
+// and touching collinear rulings merged.
+func mergeRulings(rulings []ruling) []ruling {
+	if len(rulings) == 0 {
+		return nil
+	}
+	sort.Slice(rulings, func(i, j int) bool {
+		ri, rj := rulings[i], rulings[j]
+		if ri.pos != rj.pos {
+			return ri.pos < rj.pos
+		}
+		return ri.lo < rj.lo
+	})
+
+	var merged []ruling
+	for i0 := 0; i0 < len(rulings); {
+		// rulings[i0:i1] are collinear.
+		i1 := i0 + 1
+		for i1 < len(rulings) && rulings[i1].pos-rulings[i1-1].pos <= rulingTol {
+			i1++
+		}
+		line := append([]ruling(nil), rulings[i0:i1]...)
+		sort.Slice(line, func(i, j int) bool { return line[i].lo < line[j].lo })
+		r := line[0]
+		for _, next := range line[1:] {
+			if next.lo <= r.hi+rulingTol {
+				r.hi = math.Max(r.hi, next.hi)
+				continue
+			}
+			merged = append(merged, r)
+			r = next
+		}
+		merged = append(merged, r)
+		i0 = i1
+	}
+	return merged
+}
+
+// rulingPositions returns the distinct positions of `rulings` in increasing order. Positions closer
+// than rulingTol are treated as the same position.
+func rulingPositions(rulings []ruling) []float64 {
+	pos := make([]float64, len(rulings))
+	for i, r := range rulings {
+		pos[i] = r.pos
+	}
+	sort.Float64s(pos)
+	distinct := pos[:1]
+	for _, p := range pos[1:] {
+		if p-distinct[len(distinct)-1] > rulingTol {
+			distinct = append(distinct, p)
+		}
+	}
+	return distinct
+}
+
+// crosses returns true if horizontal ruling `h` and vertical ruling `v` cross or touch.
+func crosses(h, v ruling) bool {
+	return h.lo-rulingTol <= v.pos && v.pos <= h.hi+rulingTol &&
+		v.lo-rulingTol <= h.pos && h.pos <= v.hi+rulingTol
+}
+
+// covers returns true if one of `rulings` at position `pos` extends across `at`.
+func covers(rulings []ruling, pos, at float64) bool {
+	for _, r := range rulings {
+		if math.Abs(r.pos-pos) <= rulingTol && r.lo-rulingTol <= at && at <= r.hi+rulingTol {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the root of the set containing `i` in disjoint set forest `parent`.
+func find(parent []int, i int) int {
+	for parent[i] != i {
+		parent[i] = parent[parent[i]]
+		i = parent[i]
+	}
+	return i
+}
+
+// union merges the sets containing `i` and `j` in disjoint set forest `parent`.
+func union(parent []int, i, j int) {
+	ri, rj := find(parent, i), find(parent, j)
+	if ri < rj {
+		parent[rj] = ri
+	} else if rj < ri {
+		parent[ri] = rj
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestRuledTables tests the extraction of tables whose cells are bounded by ruling lines, including
+// a cell spanning two columns, an empty cell and a cell with wrapped text.
+func TestRuledTables(t *testing.T) {
+	contents := `
+		BT /Helvetica 12 Tf 100 720 Td (Table 1) Tj ET
+		0.9 g 100 670 300 30 re f 0 g
+		100 590 300 110 re S
+		100 670 m 400 670 l 100 620 m 400 620 l S
+		250 670 m 250 590 l S
+		BT /Helvetica 10 Tf 110 680 Td (Quarterly results) Tj ET
+		BT /Helvetica 10 Tf 110 650 Td (Alpha) Tj 0 -12 Td (Beta) Tj ET
+		BT /Helvetica 10 Tf 260 650 Td (1) Tj ET
+		BT /Helvetica 10 Tf 110 600 Td (Gamma) Tj ET
+		`
+	type expectedCell struct {
+		text             string
+		rowSpan, colSpan int
+	}
+	expected := [][]expectedCell{
+		{{"Quarterly results", 1, 2}, {"", 0, 0}},
+		{{"Alpha\nBeta", 1, 1}, {"1", 1, 1}},
+		{{"Gamma", 1, 1}, {"", 1, 1}},
+	}
+
+	resources := model.NewPdfPageResources()
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources.SetFontByName("Helvetica", helvetica.ToPdfObject())
+	e := Extractor{resources: resources, contents: contents, mediaBox: r(0, 0, 600, 800)}
+	pageText, _, _, err := e.ExtractPageText()
+	if err != nil {
+		t.Fatalf("Error extracting text: err=%v", err)
+	}
+
+	tables := pageText.Tables()
+	if len(tables) != 1 {
+		t.Fatalf("%d tables. Expected 1", len(tables))
+	}
+	table := tables[0]
+	if table.W != 2 || table.H != 3 {
+		t.Fatalf("table is %d x %d. Expected 2 x 3", table.W, table.H)
+	}
+	if table.BBox != r(100, 590, 400, 700) {
+		t.Fatalf("table bbox=%.2f", table.BBox)
+	}
+	for y, row := range expected {
+		for x, exp := range row {
+			cell := table.Cells[y][x]
+			if cell.Text != exp.text || cell.RowSpan != exp.rowSpan || cell.ColSpan != exp.colSpan {
+				t.Fatalf("cell %d,%d: got %q %dx%d. Expected %q %dx%d", x, y, cell.Text,
+					cell.ColSpan, cell.RowSpan, exp.text, exp.colSpan, exp.rowSpan)
+			}
+		}
+	}
+	if bbox := table.Cells[0][0].BBox; bbox != r(100, 670, 400, 700) {
+		t.Fatalf("spanning cell bbox=%.2f", bbox)
+	}
+	if text := pageText.Text(); !strings.HasPrefix(text, "Table 1\n") {
+		t.Fatalf("text outside the table is not first. text=%q", text)
+	}
+	layout := pageText.Layout()
+	if len(layout.Blocks) != 2 || layout.Blocks[1].Role != TextBlockTable {
+		t.Fatalf("layout blocks=%+v", layout.Blocks)
+	}
+	for y, row := range expected {
+		for x, exp := range row {
+			cell := layout.Blocks[1].Cells[y][x]
+			if cell.Text != exp.text || cell.RowSpan != exp.rowSpan || cell.ColSpan != exp.colSpan {
+				t.Fatalf("layout cell %d,%d: got %q %dx%d. Expected %q %dx%d", x, y, cell.Text,
+					cell.ColSpan, cell.RowSpan, exp.text, exp.colSpan, exp.rowSpan)
+			}
+		}
+	}
+
+	// The HTML and JSON exports of the layout skip the position covered by the spanning cell.
+	var html bytes.Buffer
+	if err := layout.WriteHTML(&html); err != nil {
+		t.Fatalf("WriteHTML failed. err=%v", err)
+	}
+	expectedHTML := "<table>\n" +
+		"<tr><td colspan=\"2\">Quarterly results</td></tr>\n" +
+		"<tr><td>Alpha Beta</td><td>1</td></tr>\n" +
+		"<tr><td>Gamma</td><td></td></tr>\n" +
+		"</table>\n"
+	if !strings.Contains(html.String(), expectedHTML) {
+		t.Fatalf("HTML table mismatch. Got %q. Expected %q", html.String(), expectedHTML)
+	}
+	var layoutJSON bytes.Buffer
+	if err := layout.WriteJSON(&layoutJSON); err != nil {
+		t.Fatalf("WriteJSON failed. err=%v", err)
+	}
+	var decodedLayout struct {
+		Blocks []struct {
+			Cells [][]*struct {
+				Text    string `json:"text"`
+				ColSpan int    `json:"colSpan"`
+			} `json:"cells"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(layoutJSON.Bytes(), &decodedLayout); err != nil {
+		t.Fatalf("invalid JSON. err=%v", err)
+	}
+	cells := decodedLayout.Blocks[1].Cells
+	if cells[0][0] == nil || cells[0][0].ColSpan != 2 || cells[0][1] != nil ||
+		cells[2][1] == nil || cells[2][1].Text != "" {
+		t.Fatalf("layout JSON cells mismatch. %s", layoutJSON.String())
+	}
+
+	var csv bytes.Buffer
+	if err := table.WriteCSV(&csv); err != nil {
+		t.Fatalf("WriteCSV failed. err=%v", err)
+	}
+	expectedCSV := "Quarterly results,\n\"Alpha\nBeta\",1\nGamma,\n"
+	if csv.String() != expectedCSV {
+		t.Fatalf("CSV mismatch. Got %q. Expected %q", csv.String(), expectedCSV)
+	}
+
+	var buf bytes.Buffer
+	if err := table.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed. err=%v", err)
+	}
+	var decoded TextTable
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON. err=%v", err)
+	}
+	if decoded.BBox != table.BBox || decoded.Cells[0][0].ColSpan != 2 ||
+		decoded.Cells[1][0].Text != "Alpha\nBeta" {
+		t.Fatalf("JSON round trip mismatch. %s", buf.String())
+	}
+}
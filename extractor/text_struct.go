@@ -0,0 +1,386 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/contentstream"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// ErrNoStructTree is returned by NewStructTree for documents that don't have a logical structure
+// tree, i.e. that are not tagged.
+var ErrNoStructTree = errors.New("document has no structure tree")
+
+// StructTree is the logical structure tree of a tagged PDF document. Set Options.StructTree to
+// a document's StructTree to give the TextMarks extracted from its pages the types of the
+// structure elements that contain them and to extract text in structure order.
+// See section 14.7 "Logical Structure" (p. 578 PDF32000_2008).
+type StructTree struct {
+	pages map[int64]*structPage // The structure on each page keyed by page object number.
+}
+
+// structPage is the part of a structure tree that refers to the marked content on one page.
+type structPage struct {
+	elems map[int]*structElem // The structure element containing each MCID.
+	order map[int]int         // The position of each MCID in the structure tree's logical order.
+}
+
+// structElem is a structure element.
+type structElem struct {
+	typ   string      // The structure type mapped to a standard type by the tree's RoleMap.
+	lang  string      // The language of the element, which may be inherited from its ancestors.
+	block *structElem // The element, or its nearest ancestor, that is not an inline element.
+}
+
+// inlineStructTypes are the types of the standard inline-level structure elements. The content of
+// these elements is part of the paragraph that contains them.
+// See section 14.8.4.4 "Inline-Level Structure Elements" (p. 600 PDF32000_2008).
+var inlineStructTypes = map[string]bool{
+	"Span": true, "Quote": true, "Note": true, "Reference": true, "BibEntry": true, "Code": true,
+	"Link": true, "Annot": true, "Ruby": true, "Warichu": true,
+}
+
+// maxStructDepth is the maximum depth of structure tree that is read. It guards against reference
+// loops in damaged documents.
+const maxStructDepth = 100
+
+// NewStructTree returns the logical structure tree of the document read by `reader`. It returns
+// ErrNoStructTree if the document doesn't have one.
+func NewStructTree(reader *model.PdfReader) (*StructTree, error) {
+	obj, err := reader.GetStructTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	root, ok := core.GetDict(obj)
+	if !ok {
+		return nil, ErrNoStructTree
+	}
+	b := structTreeBuilder{
+		tree:    &StructTree{pages: map[int64]*structPage{}},
+		visited: map[*core.PdfObjectDictionary]bool{},
+	}
+	b.roleMap, _ = core.GetDict(root.Get("RoleMap"))
+	b.addKids(root.Get("K"), nil, -1, 0)
+	return b.tree, nil
+}
+
+// page returns the structure on the page with object number `objNum` or nil if the structure tree
+// doesn't refer to that page.
+func (st *StructTree) page(objNum int64) *structPage {
+	if st == nil {
+		return nil
+	}
+	return st.pages[objNum]
+}
+
+// structTreeBuilder builds a StructTree by traversing the structure elements of a document in
+// logical order.
+type structTreeBuilder struct {
+	tree    *StructTree
+	roleMap *core.PdfObjectDictionary
+	visited map[*core.PdfObjectDictionary]bool
+}
+
+// addKids adds the kids `kids` (the K entry of a structure element) of structure element `parent`
+// on the page with object number `pageNum` to the tree.
+func (b *structTreeBuilder) addKids(kids core.PdfObject, parent *structElem, pageNum int64, depth int) {
+	if depth > maxStructDepth {
+		common.Log.Debug("ERROR: structure tree is too deep. depth=%d", depth)
+		return
+	}
+	if arr, ok := core.GetArray(kids); ok {
+		for _, kid := range arr.Elements() {
+			b.addKid(kid, parent, pageNum, depth)
+		}
+		return
+	}
+	if kids != nil {
+		b.addKid(kids, parent, pageNum, depth)
+	}
+}
+
+// addKid adds `kid`, a kid of structure element `parent` on the page with object number `pageNum`,
+// to the tree. `kid` is either an MCID, a marked-content reference, an object reference or a
+// structure element.
+func (b *structTreeBuilder) addKid(kid core.PdfObject, parent *structElem, pageNum int64, depth int) {
+	if mcid, ok := core.GetIntVal(kid); ok {
+		b.addMCID(mcid, parent, pageNum)
+		return
+	}
+	dict, ok := core.GetDict(kid)
+	if !ok || b.visited[dict] {
+		return
+	}
+	b.visited[dict] = true
+	if objNum, ok := objectNumber(dict.Get("Pg")); ok {
+		pageNum = objNum
+	}
+
+	switch typ, _ := core.GetNameVal(dict.Get("Type")); typ {
+	case "MCR":
+		// Marked content in a form XObject (Stm) is not numbered in the page's content stream.
+		if dict.Get("Stm") != nil {
+			return
+		}
+		if mcid, ok := core.GetIntVal(dict.Get("MCID")); ok {
+			b.addMCID(mcid, parent, pageNum)
+		}
+		return
+	case "OBJR":
+		return
+	}
+
+	s, _ := core.GetNameVal(dict.Get("S"))
+	elem := &structElem{typ: b.standardType(s)}
+	if parent != nil {
+		elem.lang = parent.lang
+	}
+	if lang, ok := core.GetString(dict.Get("Lang")); ok {
+		elem.lang = lang.Decoded()
+	}
+	elem.block = elem
+	if inlineStructTypes[elem.typ] && parent != nil {
+		elem.block = parent.block
+	}
+	b.addKids(dict.Get("K"), elem, pageNum, depth+1)
+}
+
+// addMCID adds the marked-content sequence with identifier `mcid` on the page with object number
+// `pageNum` to structure element `parent`.
+func (b *structTreeBuilder) addMCID(mcid int, parent *structElem, pageNum int64) {
+	if parent == nil || pageNum < 0 {
+		common.Log.Debug("ERROR: MCID without structure element or page. mcid=%d", mcid)
+		return
+	}
+	sp, ok := b.tree.pages[pageNum]
+	if !ok {
+		sp = &structPage{elems: map[int]*structElem{}, order: map[int]int{}}
+		b.tree.pages[pageNum] = sp
+	}
+	if _, ok := sp.elems[mcid]; ok {
+		return
+	}
+	sp.elems[mcid] = parent
+	sp.order[mcid] = len(sp.order)
+}
+
+// standardType returns structure type `s` mapped to a standard structure type by the structure
+// tree's RoleMap. `s` is returned if it is not mapped.
+func (b *structTreeBuilder) standardType(s string) string {
+	if b.roleMap == nil {
+		return s
+	}
+	// Role maps can be chained. The number of lookups is limited in case of cycles.
+	for i := 0; i < 10; i++ {
+		mapped, ok := core.GetNameVal(b.roleMap.Get(core.PdfObjectName(s)))
+		if !ok || mapped == s {
+			break
+		}
+		s = mapped
+	}
+	return s
+}
+
+// objectNumber returns the object number of `obj` if it is an indirect object or a reference.
+func objectNumber(obj core.PdfObject) (int64, bool) {
+	switch t := obj.(type) {
+	case *core.PdfObjectReference:
+		return t.ObjectNumber, true
+	case *core.PdfIndirectObject:
+		return t.ObjectNumber, true
+	}
+	return 0, false
+}
+
+// pageObjectNumber returns the object number of `page` if it has one.
+func pageObjectNumber(page *model.PdfPage) (int64, bool) {
+	ind, ok := page.GetContainingPdfObject().(*core.PdfIndirectObject)
+	if !ok {
+		return 0, false
+	}
+	return ind.ObjectNumber, true
+}
+
+// elem returns the structure element containing the marked content with identifier `mcid`, or nil
+// if there isn't one.
+func (sp *structPage) elem(mcid int) *structElem {
+	if sp == nil || mcid < 0 {
+		return nil
+	}
+	return sp.elems[mcid]
+}
+
+// markedContent is a marked-content sequence. Marked-content sequences are delimited by the BMC or
+// BDC and EMC operators. They have a tag and, for BDC, a property list.
+// See section 14.6 "Marked Content" (p. 558 PDF32000_2008).
+type markedContent struct {
+	parent     *markedContent // The sequence that contains this sequence.
+	tag        string         // The tag. This is the structure type of the content in tagged PDFs.
+	mcid       int            // The marked-content identifier or -1 if there is none.
+	actualText *string        // The replacement text for the content of the sequence, if any.
+	lang       string         // The language of the text in the sequence, if it is specified.
+}
+
+// newMarkedContent returns the marked-content sequence begun by the BMC or BDC operation `op`
+// within sequence `parent`. Property lists referred to by name are looked up in `resources`.
+func newMarkedContent(op *contentstream.ContentStreamOperation, resources *model.PdfPageResources,
+	parent *markedContent) *markedContent {
+	mc := &markedContent{parent: parent, mcid: -1}
+	if len(op.Params) > 0 {
+		mc.tag, _ = core.GetNameVal(op.Params[0])
+	}
+	if op.Operand != "BDC" || len(op.Params) < 2 {
+		return mc
+	}
+
+	props, ok := core.GetDict(op.Params[1])
+	if name, isName := core.GetName(op.Params[1]); isName && resources != nil {
+		if properties, found := core.GetDict(resources.Properties); found {
+			props, ok = core.GetDict(properties.Get(*name))
+		}
+	}
+	if !ok {
+		common.Log.Debug("ERROR: no property list for marked content. op=%s", op)
+		return mc
+	}
+	if mcid, ok := core.GetIntVal(props.Get("MCID")); ok {
+		mc.mcid = mcid
+	}
+	if text, ok := core.GetString(props.Get("ActualText")); ok {
+		actualText := text.Decoded()
+		mc.actualText = &actualText
+	}
+	if lang, ok := core.GetString(props.Get("Lang")); ok {
+		mc.lang = lang.Decoded()
+	}
+	return mc
+}
+
+// replacement returns the outermost of `mc` and the sequences containing it that has replacement
+// text, or nil if none of them have replacement text.
+func (mc *markedContent) replacement() *markedContent {
+	var outer *markedContent
+	for ; mc != nil; mc = mc.parent {
+		if mc.actualText != nil {
+			outer = mc
+		}
+	}
+	return outer
+}
+
+// applyMarkedContent sets the MCIDs, structure types and languages of the marks in `pt` from the
+// marked-content sequences that contain them and the structure elements on the page `sp`, which
+// may be nil. Runs of marks with replacement text (ActualText) are replaced by a single mark with
+// that text.
+func (pt *PageText) applyMarkedContent(sp *structPage) {
+	marks := pt.marks[:0]
+	for i := 0; i < len(pt.marks); {
+		tm := pt.marks[i]
+		j := i + 1
+		if mc := tm.mc.replacement(); mc != nil {
+			for j < len(pt.marks) && pt.marks[j].mc.replacement() == mc {
+				j++
+			}
+			tm = mergeMarks(pt.marks[i:j], *mc.actualText)
+		}
+		i = j
+		if tm == nil {
+			continue
+		}
+		tm.setMarkedContent(sp)
+		marks = append(marks, tm)
+	}
+	pt.marks = marks
+}
+
+// mergeMarks returns a mark with text `text` that covers the marks `marks`. It returns nil if
+// `text` is empty, as the marks are to be removed then.
+func mergeMarks(marks []*textMark, text string) *textMark {
+	if text == "" {
+		return nil
+	}
+	merged := *marks[0]
+	var original strings.Builder
+	for _, tm := range marks {
+		original.WriteString(tm.original)
+		merged.PdfRectangle = rectUnion(merged.PdfRectangle, tm.PdfRectangle)
+		merged.originaBBox = rectUnion(merged.originaBBox, tm.originaBBox)
+		merged.end = tm.end
+	}
+	merged.text = text
+	merged.original = original.String()
+	return &merged
+}
+
+// setMarkedContent sets the MCID, structure type and language of `tm` from its marked-content
+// sequences and, if it is not nil, the structure on its page `sp`.
+// NOTE: Marks from form XObjects are shared between the places the forms are drawn. This function
+// sets the same values each time it is called on a shared mark.
+func (tm *textMark) setMarkedContent(sp *structPage) {
+	tm.mcid, tm.structType, tm.lang = -1, "", ""
+	for mc := tm.mc; mc != nil; mc = mc.parent {
+		if tm.mcid < 0 && mc.mcid >= 0 {
+			tm.mcid = mc.mcid
+			tm.structType = mc.tag
+		}
+		if tm.lang == "" {
+			tm.lang = mc.lang
+		}
+	}
+	if tm.mcid < 0 && tm.mc != nil {
+		tm.structType = tm.mc.tag
+	}
+	if elem := sp.elem(tm.mcid); elem != nil {
+		tm.structType = elem.typ
+		if tm.lang == "" {
+			tm.lang = elem.lang
+		}
+	}
+}
+
+// structureParas returns the paras of `pt` in the logical order of the structure on its page. The
+// marks of each block-level structure element are arranged into paras separately. The marks that
+// aren't in the structure tree follow the structured marks and are arranged in reading order.
+func (pt *PageText) structureParas() paraList {
+	sp := pt.structure
+	position := func(tm *textMark) int {
+		if order, ok := sp.order[tm.mcid]; ok && tm.mcid >= 0 {
+			return order
+		}
+		return len(sp.order)
+	}
+	marks := make([]*textMark, len(pt.marks))
+	copy(marks, pt.marks)
+	sort.SliceStable(marks, func(i, j int) bool {
+		return position(marks[i]) < position(marks[j])
+	})
+
+	var paras paraList
+	for i := 0; i < len(marks); {
+		block := blockOf(sp.elem(marks[i].mcid))
+		j := i + 1
+		for j < len(marks) && blockOf(sp.elem(marks[j].mcid)) == block {
+			j++
+		}
+		paras = append(paras, makeOrientedParas(marks[i:j], nil, pt.pageSize)...)
+		i = j
+	}
+	return paras
+}
+
+// blockOf returns the block-level element containing `elem` or nil if `elem` is nil.
+func blockOf(elem *structElem) *structElem {
+	if elem == nil {
+		return nil
+	}
+	return elem.block
+}
@@ -0,0 +1,171 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestMarkedContent tests that text extraction honors ActualText, sets the MCIDs, structure types
+// and languages of marks and extracts text in structure order.
+func TestMarkedContent(t *testing.T) {
+	contents := `
+		/Artifact BMC BT /Helvetica 12 Tf 100 750 Td (Header) Tj ET EMC
+		/P <</MCID 0 /Lang (fr)>> BDC BT /Helvetica 12 Tf 100 700 Td (Alpha) Tj ET EMC
+		BT /Helvetica 12 Tf 100 600 Td
+		/Heading <</MCID 1>> BDC (Be) Tj /Span <</ActualText (ta)>> BDC (xy) Tj EMC EMC
+		ET
+		BT /Helvetica 12 Tf 100 500 Td
+		/P <</MCID 2>> BDC (Gamma) Tj EMC
+		100 0 Td /Span /Pr1 BDC (Delta) Tj /Span <</ActualText ()>> BDC (-) Tj EMC EMC
+		ET
+		`
+	reader := makeTaggedTestReader(t, contents)
+	// The writer may add a watermark to the bottom of the page. Only the words above are checked.
+	words := func(text string) []string {
+		fields := strings.Fields(text)
+		if len(fields) > 5 {
+			fields = fields[:5]
+		}
+		return fields
+	}
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	// Without the structure tree, the structure types are the marked-content tags.
+	e, err := New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Header", "Alpha", "Beta", "Gamma", "Delta"},
+		words(pageText.Text()))
+	assert.Equal(t, map[string]string{
+		"Header": "-1 Artifact ",
+		"Alpha":  "0 P fr",
+		"Beta":   "1 Heading ",
+		"Gamma":  "2 P ",
+		"Delta":  "3 Span ",
+	}, wordStructure(t, pageText))
+
+	// With the structure tree, the structure types are role mapped and languages are inherited.
+	tree, err := NewStructTree(reader)
+	require.NoError(t, err)
+	e, err = NewWithOptions(page, &Options{StructTree: tree})
+	require.NoError(t, err)
+	pageText, _, _, err = e.ExtractPageText()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Header", "Alpha", "Beta", "Gamma", "Delta"},
+		words(pageText.Text()))
+	assert.Equal(t, map[string]string{
+		"Header": "-1 Artifact ",
+		"Alpha":  "0 P fr",
+		"Beta":   "1 H1 ",
+		"Gamma":  "2 P en-US",
+		"Delta":  "3 Span en-US",
+	}, wordStructure(t, pageText))
+
+	// In structure order, the inline Span is part of the paragraph containing it and the
+	// unstructured text comes last.
+	e, err = NewWithOptions(page, &Options{StructTree: tree, StructureOrder: true})
+	require.NoError(t, err)
+	pageText, _, _, err = e.ExtractPageText()
+	require.NoError(t, err)
+	text := pageText.Text()
+	assert.Equal(t, []string{"Beta", "Alpha", "Gamma", "Delta", "Header"}, words(text))
+	assert.Contains(t, text, "Gamma Delta")
+
+	// Untagged documents don't have a structure tree.
+	_, err = NewStructTree(makeTestReader(t, []string{contents}))
+	assert.Equal(t, ErrNoStructTree, err)
+}
+
+// wordStructure returns a map from the words in `pageText` above the bottom margin to their
+// "MCID StructType Lang".
+// All the marks in a word are expected to have the same values.
+func wordStructure(t *testing.T, pageText *PageText) map[string]string {
+	words := map[string]string{}
+	var word []TextMark
+	addWord := func() {
+		if len(word) == 0 {
+			return
+		}
+		var text strings.Builder
+		for _, tm := range word {
+			text.WriteString(tm.Text)
+			require.Equal(t, word[0].MCID, tm.MCID)
+			require.Equal(t, word[0].StructType, tm.StructType)
+			require.Equal(t, word[0].Lang, tm.Lang)
+		}
+		tm := word[0]
+		words[text.String()] = fmt.Sprintf("%d %s %s", tm.MCID, tm.StructType, tm.Lang)
+		word = nil
+	}
+	for _, tm := range pageText.Marks().Elements() {
+		if tm.Meta {
+			addWord()
+			continue
+		}
+		if tm.BBox.Lly < 100 {
+			continue
+		}
+		word = append(word, tm)
+	}
+	addWord()
+	return words
+}
+
+// makeTaggedTestReader returns a PdfReader for a tagged document with a page having contents
+// `contents`. The page's marked content is in the structure tree
+//
+//	Document
+//	  Heading (MCID 1) role mapped to H1
+//	  P (MCID 0)
+//	  P (MCID 2) with language en-US
+//	    Span (MCID 3 in property list Pr1)
+func makeTaggedTestReader(t *testing.T, contents string) *model.PdfReader {
+	pages := makeTestPages(t, []string{contents})
+	page := pages[0]
+	properties := core.MakeDict()
+	pr1 := core.MakeDict()
+	pr1.Set("MCID", core.MakeInteger(3))
+	properties.Set("Pr1", pr1)
+	page.Resources.Properties = properties
+
+	elem := func(s string, kids core.PdfObject) (*core.PdfIndirectObject, *core.PdfObjectDictionary) {
+		d := core.MakeDict()
+		d.Set("Type", core.MakeName("StructElem"))
+		d.Set("S", core.MakeName(s))
+		d.Set("K", kids)
+		return core.MakeIndirectObject(d), d
+	}
+	mcr := core.MakeDict()
+	mcr.Set("Type", core.MakeName("MCR"))
+	mcr.Set("MCID", core.MakeInteger(3))
+	mcr.Set("Pg", page.GetContainingPdfObject())
+	heading, _ := elem("Heading", core.MakeInteger(1))
+	p1, _ := elem("P", core.MakeInteger(0))
+	span, _ := elem("Span", mcr)
+	p2, p2Dict := elem("P", core.MakeArray(core.MakeInteger(2), span))
+	p2Dict.Set("Lang", core.MakeString("en-US"))
+	doc, docDict := elem("Document", core.MakeArray(heading, p1, p2))
+	docDict.Set("Pg", page.GetContainingPdfObject())
+
+	roleMap := core.MakeDict()
+	roleMap.Set("Heading", core.MakeName("H1"))
+	root := core.MakeDict()
+	root.Set("Type", core.MakeName("StructTreeRoot"))
+	root.Set("K", doc)
+	root.Set("RoleMap", roleMap)
+	return readTestPages(t, pages, core.MakeIndirectObject(root))
+}
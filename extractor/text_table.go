@@ -6,7 +6,10 @@
 package extractor
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"sort"
 
 	"github.com/gnaoh1379/unipdf/common"
@@ -18,6 +21,14 @@ type textTable struct {
 	model.PdfRectangle                      // Bounding rectangle.
 	w, h               int                  // w=number of columns. h=number of rows.
 	cells              map[uint64]*textPara // The cells
+	// The spans of the cells that span more than one row or column. Cells covered by a spanning
+	// cell are not in `cells`. Only tables found from ruling lines have spanning cells.
+	spans map[uint64]cellSpan
+}
+
+// cellSpan is the number of columns and rows spanned by a textTable cell.
+type cellSpan struct {
+	cols, rows int
 }
 
 // String returns a description of `t`.
@@ -239,6 +250,7 @@ func (t *textTable) markCells() {
 // newTablePara returns a textPara containing `t`.
 func (t *textTable) newTablePara() *textPara {
 	bbox := t.computeBbox()
+	t.PdfRectangle = bbox
 	return &textPara{
 		PdfRectangle: bbox,
 		eBBox:        bbox,
@@ -267,12 +279,34 @@ func (t *textTable) toTextTable() TextTable {
 		cells[y] = make([]TableCell, t.w)
 		for x := 0; x < t.w; x++ {
 			c := t.get(x, y)
-			cells[y][x].Text = c.text()
+			if c == nil {
+				// Covered by a spanning cell.
+				continue
+			}
+			cell := &cells[y][x]
+			cell.BBox = c.PdfRectangle
+			cell.ColSpan, cell.RowSpan = 1, 1
+			if span, ok := t.spans[cellIndex(x, y)]; ok {
+				cell.ColSpan, cell.RowSpan = span.cols, span.rows
+			}
+			cell.Text = c.text()
 			offset := 0
-			cells[y][x].Marks.marks = c.toTextMarks(&offset)
+			cell.Marks.marks = c.toTextMarks(&offset)
 		}
 	}
-	return TextTable{W: t.w, H: t.h, Cells: cells}
+	return TextTable{BBox: t.PdfRectangle, W: t.w, H: t.h, Cells: cells}
+}
+
+// depth returns the depth of the first cell of `t`, in row-major order, that contains text.
+func (t *textTable) depth() float64 {
+	for y := 0; y < t.h; y++ {
+		for x := 0; x < t.w; x++ {
+			if c := t.get(x, y); c != nil && len(c.lines) > 0 {
+				return c.depth()
+			}
+		}
+	}
+	return 0
 }
 
 // get returns the cell at `x`, `y`.
@@ -300,7 +334,36 @@ func (t *textTable) log(title string) {
 	for y := 0; y < t.h; y++ {
 		for x := 0; x < t.w; x++ {
 			p := t.get(x, y)
+			if p == nil {
+				continue
+			}
 			fmt.Printf("%4d %2d: %6.2f %q\n", x, y, p.PdfRectangle, truncate(p.text(), 50))
 		}
 	}
 }
+
+// WriteCSV writes the text of the cells of `t` to `w` as CSV records, one record per row. The text of
+// a cell that spans several rows or columns is written in its top left position and the positions
+// covered by the cell are empty.
+func (t *TextTable) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	for _, row := range t.Cells {
+		record := make([]string, len(row))
+		for x, cell := range row {
+			record[x] = cell.Text
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the JSON serialization of `t`, including the bounding boxes and spans of the
+// table and its cells, to `w`.
+func (t *TextTable) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
@@ -22,7 +22,9 @@ import (
 	"unicode/utf8"
 
 	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
 	"github.com/gnaoh1379/unipdf/creator"
+	"github.com/gnaoh1379/unipdf/internal/cmap"
 	"github.com/gnaoh1379/unipdf/model"
 	"golang.org/x/text/unicode/norm"
 )
@@ -141,6 +143,133 @@ func TestTextExtractionFragments(t *testing.T) {
 	}
 }
 
+// TestTextExtractionDirection tests the extraction of right-to-left and vertical text.
+func TestTextExtractionDirection(t *testing.T) {
+	// The codes of the Hebrew font are 1: ש 2: ל 3: ו 4: ם 5: 1 6: 2 7: A 8: B.
+	// The codes of the vertical font are 1: 日 2: 本 3: 語 4: 中 5: 文.
+	fragmentTests := []struct {
+		name     string
+		contents string
+		text     string
+	}{
+		{
+			name: "right-to-left in visual order",
+			contents: `
+		BT
+		/Hebrew 24 Tf
+		100 500 Td
+		<0005000600000004000300020001> Tj
+		ET
+		`,
+			text: "שלום 12",
+		},
+		{
+			name: "right-to-left in logical order",
+			contents: `
+		BT
+		/Hebrew 24 Tf
+		172 500 Td
+		<0001> Tj -12 0 Td <0002> Tj -12 0 Td <0003> Tj -12 0 Td <0004> Tj
+		0 -30 Td
+		<0002> Tj -12 0 Td <0001> Tj
+		ET
+		`,
+			text: "שלום\nלש",
+		},
+		{
+			name: "left-to-right in right-to-left",
+			contents: `
+		BT
+		/Hebrew 24 Tf
+		100 500 Td
+		<000700080000000400030002000100000005> Tj
+		ET
+		`,
+			text: "1 שלום AB",
+		},
+		{
+			name: "right-to-left in left-to-right",
+			contents: `
+		BT
+		/Hebrew 24 Tf
+		100 500 Td
+		<0007000800000004000300020001000000070007> Tj
+		ET
+		`,
+			text: "AB שלום AA",
+		},
+		{
+			name: "vertical",
+			contents: `
+		BT
+		/Vertical 20 Tf
+		1 0 0 1 300 700 Tm
+		<000100020003> Tj
+		1 0 0 1 270 700 Tm
+		[<0004> -10 <0005>] TJ
+		ET
+		`,
+			text: "日本語\n中文",
+		},
+	}
+
+	// Setup mock resources.
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("Hebrew", makeTestType0Font(t, "Identity-H", " שלום12AB"))
+	resources.SetFontByName("Vertical", makeTestType0Font(t, "Identity-V", " 日本語中文"))
+
+	for _, f := range fragmentTests {
+		t.Run(f.name, func(t *testing.T) {
+			e := Extractor{resources: resources, contents: f.contents, mediaBox: r(0, 0, 600, 800)}
+			pageText, _, _, err := e.ExtractPageText()
+			if err != nil {
+				t.Fatalf("Error extracting text: %q err=%v", f.name, err)
+			}
+			text := pageText.Text()
+			if trimmed := strings.TrimRight(text, "\n"); trimmed != f.text {
+				t.Fatalf("Text mismatch: %q Got %q. Expected %q", f.name, trimmed, f.text)
+			}
+			for _, tm := range pageText.Marks().Elements() {
+				if text[tm.Offset:tm.Offset+len(tm.Text)] != tm.Text {
+					t.Fatalf("Mark mismatch: %q offset=%d text=%q", f.name, tm.Offset, tm.Text)
+				}
+			}
+		})
+	}
+}
+
+// makeTestType0Font returns a Type0 font with encoding `encoding` whose ToUnicode CMap maps
+// the 2 byte character code i to the ith rune of `runes`. All glyphs are half an em wide.
+func makeTestType0Font(t *testing.T, encoding, runes string) core.PdfObject {
+	codeToRune := map[cmap.CharCode]rune{}
+	for i, r := range []rune(runes) {
+		codeToRune[cmap.CharCode(i)] = r
+	}
+	toUnicode, err := cmap.NewToUnicodeCMap(codeToRune).Stream()
+	if err != nil {
+		t.Fatalf("Error creating ToUnicode CMap: %v", err)
+	}
+	parser := core.NewParserFromString(`<<
+		/Type /Font
+		/Subtype /Type0
+		/BaseFont /Test
+		/Encoding /` + encoding + `
+		/DescendantFonts [<<
+			/Type /Font
+			/Subtype /CIDFontType2
+			/BaseFont /Test
+			/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >>
+			/DW 500
+			>>]
+		>>`)
+	font, err := parser.ParseDict()
+	if err != nil {
+		t.Fatalf("Error parsing font: %v", err)
+	}
+	font.Set("ToUnicode", toUnicode)
+	return font
+}
+
 // TestTextExtractionFiles tests text extraction on a set of PDF files.
 // It checks for the existence of specified strings of words on specified pages.
 // We currently only check within lines as our line order is still improving.
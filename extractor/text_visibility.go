@@ -0,0 +1,166 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image/color"
+
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TextVisibility describes whether the text of a TextMark can be seen on its page and, if it can't,
+// why not.
+type TextVisibility int
+
+// Text visibilities.
+const (
+	// TextVisible is text that can be seen on the page.
+	TextVisible TextVisibility = iota
+	// TextInvisibleRenderMode is text drawn with an invisible text rendering mode, 3 or 7. The text
+	// layers that OCR software adds to scanned pages are drawn this way.
+	TextInvisibleRenderMode
+	// TextBackgroundColor is text drawn in the color of the background it is drawn on, such as white
+	// text on a white page.
+	TextBackgroundColor
+	// TextOutsideCropBox is text outside the page's crop box, the region of the page that is
+	// displayed.
+	TextOutsideCropBox
+	// TextClipped is text that is completely outside the clipping path it is drawn with.
+	TextClipped
+)
+
+var textVisibilityNames = []string{"visible", "invisibleRenderMode", "backgroundColor",
+	"outsideCropBox", "clipped"}
+
+// String returns a description of `v`.
+func (v TextVisibility) String() string {
+	if v < 0 || int(v) >= len(textVisibilityNames) {
+		return "unknown"
+	}
+	return textVisibilityNames[v]
+}
+
+// filledArea is an area painted on a page. Filled areas are the backgrounds that text is drawn on.
+type filledArea struct {
+	model.PdfRectangle             // The area, or its bounding box, in device coordinates.
+	color              color.Color // The fill color. nil if the area isn't filled with a known color.
+	numMarks           int         // The number of page marks drawn before the area was filled.
+}
+
+// filledArea returns the filledArea for `p` filled with color `fill`. Areas that aren't
+// axis-aligned rectangles, such as rounded boxes, don't cover all of their bounding box so the color
+// of their background is not known.
+func (p *shapePath) filledArea(fill color.Color) (filledArea, bool) {
+	bbox, ok := p.bbox()
+	if !ok {
+		return filledArea{}, false
+	}
+	shape := ShapeMark{Subpaths: p.subpaths, BBox: bbox}
+	rect, ok := shape.Rectangle()
+	if !ok {
+		return filledArea{PdfRectangle: bbox}, true
+	}
+	return filledArea{PdfRectangle: rect, color: fill}, true
+}
+
+// imageArea returns the area painted by an image drawn with current transformation matrix `ctm`.
+// The colors of images are not known.
+func imageArea(ctm transform.Matrix) filledArea {
+	var p shapePath
+	p.add("re", []float64{0, 0, 1, 1}, ctm)
+	bbox, _ := p.bbox()
+	return filledArea{PdfRectangle: bbox}
+}
+
+// visibility returns the visibility of a mark with device bounding box `bbox` drawn by `to` as far
+// as it can be determined while the mark is drawn. Text drawn in the color of its background is
+// found after all the page's marks have been drawn. See PageText.markBackgroundText.
+func (to *textObject) visibility(bbox model.PdfRectangle) TextVisibility {
+	// state.tmode holds the operand of the Tr operator.
+	switch int(to.state.tmode) {
+	case 3, 7:
+		return TextInvisibleRenderMode
+	}
+	if !intersects(bbox, to.e.visibleBox()) {
+		return TextOutsideCropBox
+	}
+	if to.clip.clipped && !intersects(bbox, to.clip.clip) {
+		return TextClipped
+	}
+	return TextVisible
+}
+
+// paintColor returns the color the glyphs drawn by `to` are painted with. This is the stroke color
+// for text that is stroked but not filled and the fill color otherwise.
+func (to *textObject) paintColor() color.Color {
+	switch int(to.state.tmode) {
+	case 1, 5:
+		return to.getStrokeColor()
+	}
+	return to.getFillColor()
+}
+
+// markBackgroundText sets the visibility of the visible marks in `pt` that are painted in the color
+// of the background they are drawn on to TextBackgroundColor. The background of a mark is the last
+// area filled before the mark was drawn that contains the center of the mark, or the white page if
+// there is no such area. Marks that overlap areas painted since then that are not filled with the
+// color of the mark, such as images, shadings or rounded boxes, are visible.
+func (pt *PageText) markBackgroundText() {
+	for i, tm := range pt.marks {
+		if tm.visibility != TextVisible || tm.paintColor == nil {
+			continue
+		}
+		bbox := tm.originaBBox
+		x, y := (bbox.Llx+bbox.Urx)/2, (bbox.Lly+bbox.Ury)/2
+		var background color.Color = color.White
+		for j := len(pt.fills) - 1; j >= 0; j-- {
+			f := pt.fills[j]
+			if f.numMarks > i || !intersects(bbox, f.PdfRectangle) {
+				continue
+			}
+			covers := f.Llx <= x && x <= f.Urx && f.Lly <= y && y <= f.Ury
+			if f.color == nil || !covers && !similarColors(tm.paintColor, f.color) {
+				// Part of the mark is drawn on paint of another or an unknown color.
+				background = nil
+				break
+			}
+			if covers {
+				background = f.color
+				break
+			}
+		}
+		if background != nil && similarColors(tm.paintColor, background) {
+			tm.visibility = TextBackgroundColor
+		}
+	}
+}
+
+// dropInvisibleText removes the marks that can't be seen from `pt`.
+func (pt *PageText) dropInvisibleText() {
+	visible := pt.marks[:0]
+	for _, tm := range pt.marks {
+		if tm.visibility == TextVisible {
+			visible = append(visible, tm)
+		}
+	}
+	pt.marks = visible
+}
+
+// similarColors returns true if `c1` and `c2` are too similar to distinguish text painted in one
+// color on a background of the other.
+func similarColors(c1, c2 color.Color) bool {
+	const tol = 0x0a00
+	r1, g1, b1, _ := c1.RGBA()
+	r2, g2, b2, _ := c2.RGBA()
+	diff := func(a, b uint32) uint32 {
+		if a > b {
+			return a - b
+		}
+		return b - a
+	}
+	return diff(r1, r2) <= tol && diff(g1, g2) <= tol && diff(b1, b2) <= tol
+}
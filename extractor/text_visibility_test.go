@@ -0,0 +1,124 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestTextVisibility tests that text that can't be seen on the page is flagged with the reason
+// it can't be seen and is dropped when Options.DropInvisibleText is set.
+func TestTextVisibility(t *testing.T) {
+	contents := `
+		BT /Helvetica 12 Tf 100 700 Td (Shown) Tj ET
+		BT /Helvetica 12 Tf 3 Tr 100 650 Td (Ocr) Tj 0 Tr ET
+		q BT /Helvetica 12 Tf 1 1 1 rg 100 600 Td (White) Tj ET Q
+		0 0 0 rg 90 540 200 30 re f
+		q BT /Helvetica 12 Tf 1 1 1 rg 100 550 Td (Reversed) Tj ET Q
+		BT /Helvetica 12 Tf 100 780 Td (Cropped) Tj ET
+		q 0 0 50 50 re W n BT /Helvetica 12 Tf 100 500 Td (Clipped) Tj ET Q
+		BT /Helvetica 12 Tf 100 450 Td (Unclipped) Tj ET
+		`
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("Helvetica", helvetica.ToPdfObject())
+
+	// The expected visibility of the text on each baseline.
+	expected := map[float64]TextVisibility{
+		700: TextVisible,
+		650: TextInvisibleRenderMode,
+		600: TextBackgroundColor,
+		550: TextVisible,
+		780: TextOutsideCropBox,
+		500: TextClipped,
+		450: TextVisible,
+	}
+
+	e := Extractor{
+		resources: resources,
+		contents:  contents,
+		mediaBox:  r(0, 0, 600, 800),
+		cropBox:   r(0, 0, 600, 760),
+	}
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+	require.Len(t, pageText.marks, len("ShownOcrWhiteReversedCroppedClippedUnclipped"))
+	for _, tm := range pageText.marks {
+		assert.Equal(t, expected[tm.originaBBox.Lly], tm.visibility, "mark %s", tm)
+	}
+	for _, tm := range pageText.Marks().Elements() {
+		if !tm.Meta {
+			assert.Equal(t, expected[tm.BBox.Lly], tm.Visibility, "mark %q", tm.Text)
+		}
+	}
+
+	e.options.DropInvisibleText = true
+	pageText, _, _, err = e.ExtractPageText()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Shown", "Reversed", "Unclipped"}, strings.Fields(pageText.Text()))
+}
+
+// TestTextBackground tests that text is flagged as drawn in the color of its background only if
+// it is drawn on the page or on a rectangle filled with its color, and not on images, shadings or
+// other shapes whose colors are not known.
+func TestTextBackground(t *testing.T) {
+	contents := `
+		1 1 1 rg 90 690 200 30 re f
+		q 200 0 0 30 90 690 cm /Im0 Do Q
+		q BT /Helvetica 12 Tf 1 1 1 rg 100 700 Td (Image) Tj ET Q
+		0 0 0 rg 100 640 m 280 640 l 290 640 290 650 290 650 c 290 660 l
+		290 670 280 670 280 670 c 100 670 l 90 670 90 660 90 660 c 90 650 l
+		90 640 100 640 100 640 c f
+		q BT /Helvetica 12 Tf 1 1 1 rg 100 650 Td (Rounded) Tj ET Q
+		q 90 590 200 30 re W n /Sh0 sh Q
+		q BT /Helvetica 12 Tf 1 1 1 rg 100 600 Td (Shading) Tj ET Q
+		0 0 0 rg 90 540 200 30 re f 1 1 1 rg 90 540 200 30 re f
+		q BT /Helvetica 12 Tf 1 1 1 rg 100 550 Td (Covered) Tj ET Q
+		`
+	helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+	resources := model.NewPdfPageResources()
+	resources.SetFontByName("Helvetica", helvetica.ToPdfObject())
+
+	// A dark image.
+	goImg := image.NewRGBA(image.Rect(0, 0, 20, 3))
+	draw.Draw(goImg, goImg.Bounds(), image.NewUniform(color.RGBA{A: 255}), image.Point{}, draw.Src)
+	img, err := model.ImageHandling.NewImageFromGoImage(goImg)
+	require.NoError(t, err)
+	ximg, err := model.NewXObjectImageFromImage(img, model.NewPdfColorspaceDeviceRGB(),
+		core.NewFlateEncoder())
+	require.NoError(t, err)
+	require.NoError(t, resources.SetXObjectImageByName("Im0", ximg))
+
+	// The expected visibility of the text on each baseline.
+	expected := map[float64]TextVisibility{
+		700: TextVisible,
+		650: TextVisible,
+		600: TextVisible,
+		550: TextBackgroundColor,
+	}
+
+	e := Extractor{
+		resources: resources,
+		contents:  contents,
+		mediaBox:  r(0, 0, 600, 800),
+	}
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+	require.Len(t, pageText.marks, len("ImageRoundedShadingCovered"))
+	for _, tm := range pageText.marks {
+		assert.Equal(t, expected[tm.originaBBox.Lly], tm.visibility, "mark %s", tm)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package bidi implements the parts of the Unicode Bidirectional Algorithm (UAX #9) that are needed
+// for PDF text: resolving the embedding levels of a paragraph and reordering its characters. It is
+// used to find the logical order of extracted text and the visual order of drawn text.
+package bidi
+
+import (
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Class is the bidirectional character type of a character.
+type Class = bidi.Class
+
+// Bidirectional character types.
+const (
+	L   = bidi.L   // Left-to-right
+	R   = bidi.R   // Right-to-left
+	AL  = bidi.AL  // Arabic letter
+	EN  = bidi.EN  // European number
+	ES  = bidi.ES  // European separator
+	ET  = bidi.ET  // European terminator
+	AN  = bidi.AN  // Arabic number
+	CS  = bidi.CS  // Common separator
+	NSM = bidi.NSM // Non-spacing mark
+	WS  = bidi.WS  // Whitespace
+	ON  = bidi.ON  // Other neutral
+)
+
+// LookupClass returns the bidirectional character type of `r`.
+func LookupClass(r rune) Class {
+	props, _ := bidi.LookupRune(r)
+	return props.Class()
+}
+
+// TextClass returns the bidirectional character type of `text`, e.g. the text of a glyph. This is
+// the type of the first strongly directional rune in `text` or the type of its first rune if it has
+// no strongly directional runes.
+func TextClass(text string) bidi.Class {
+	class := bidi.ON
+	for i, r := range text {
+		c := LookupClass(r)
+		switch c {
+		case bidi.L, bidi.R, bidi.AL:
+			return c
+		}
+		if i == 0 {
+			class = c
+		}
+	}
+	return class
+}
+
+// Levels returns the embedding levels of the characters of a paragraph whose bidirectional
+// character types are `classes` and whose base direction is right-to-left if `rtl` is true.
+// The levels are resolved with rules W1-W7, N1-N2 and I1-I2 of the Unicode Bidirectional
+// Algorithm. Explicit embeddings and isolates aren't used in PDF text so their formatting
+// characters are treated as neutrals.
+func Levels(classes []bidi.Class, rtl bool) []int {
+	n := len(classes)
+	baseLevel, sos := 0, bidi.L
+	if rtl {
+		baseLevel, sos = 1, bidi.R
+	}
+
+	types := make([]bidi.Class, n)
+	for i, c := range classes {
+		switch c {
+		case bidi.L, bidi.R, bidi.AL, bidi.EN, bidi.ES, bidi.ET, bidi.AN, bidi.CS, bidi.NSM:
+			types[i] = c
+		default:
+			types[i] = bidi.ON
+		}
+	}
+
+	// W1. Non-spacing marks take the type of the previous character.
+	prev := sos
+	for i, t := range types {
+		if t == bidi.NSM {
+			types[i] = prev
+		}
+		prev = types[i]
+	}
+
+	// W2, W3. European numbers following Arabic letters are Arabic numbers. Arabic letters are
+	// right-to-left.
+	strong := sos
+	for i, t := range types {
+		switch t {
+		case bidi.L, bidi.R, bidi.AL:
+			strong = t
+		case bidi.EN:
+			if strong == bidi.AL {
+				types[i] = bidi.AN
+			}
+		}
+	}
+	for i, t := range types {
+		if t == bidi.AL {
+			types[i] = bidi.R
+		}
+	}
+
+	// W4. Single separators between numbers of the same type take that type.
+	for i := 1; i < n-1; i++ {
+		before, after := types[i-1], types[i+1]
+		switch {
+		case types[i] == bidi.ES && before == bidi.EN && after == bidi.EN:
+			types[i] = bidi.EN
+		case types[i] == bidi.CS && before == after && (before == bidi.EN || before == bidi.AN):
+			types[i] = before
+		}
+	}
+
+	// W5. Terminators adjacent to European numbers are European numbers.
+	for i := 0; i < n; i++ {
+		if types[i] != bidi.ET {
+			continue
+		}
+		j := i
+		for j < n && types[j] == bidi.ET {
+			j++
+		}
+		if (i > 0 && types[i-1] == bidi.EN) || (j < n && types[j] == bidi.EN) {
+			for k := i; k < j; k++ {
+				types[k] = bidi.EN
+			}
+		}
+		i = j
+	}
+
+	// W6. Remaining separators and terminators are neutrals.
+	for i, t := range types {
+		switch t {
+		case bidi.ES, bidi.ET, bidi.CS:
+			types[i] = bidi.ON
+		}
+	}
+
+	// W7. European numbers following left-to-right text are left-to-right.
+	strong = sos
+	for i, t := range types {
+		switch t {
+		case bidi.L, bidi.R:
+			strong = t
+		case bidi.EN:
+			if strong == bidi.L {
+				types[i] = bidi.L
+			}
+		}
+	}
+
+	// N1, N2. Neutrals between characters of the same direction take that direction. Other
+	// neutrals take the base direction. Numbers are treated as right-to-left.
+	direction := func(i int) bidi.Class {
+		if i < 0 || i >= n {
+			return sos
+		}
+		if types[i] == bidi.L {
+			return bidi.L
+		}
+		return bidi.R
+	}
+	for i := 0; i < n; i++ {
+		if types[i] != bidi.ON {
+			continue
+		}
+		j := i
+		for j < n && types[j] == bidi.ON {
+			j++
+		}
+		dir := sos
+		if before := direction(i - 1); before == direction(j) {
+			dir = before
+		}
+		for k := i; k < j; k++ {
+			types[k] = dir
+		}
+		i = j
+	}
+
+	// I1, I2. Resolve the implicit levels.
+	levels := make([]int, n)
+	for i, t := range types {
+		level := baseLevel
+		switch {
+		case baseLevel == 0 && t == bidi.R:
+			level++
+		case baseLevel == 0 && (t == bidi.EN || t == bidi.AN):
+			level += 2
+		case baseLevel == 1 && t != bidi.R:
+			level++
+		}
+		levels[i] = level
+	}
+	return levels
+}
+
+// Order returns the indexes of the characters having embedding levels `levels` in reordered
+// order. From the highest level to the lowest odd level, each run of characters at that level or
+// higher is reversed (rule L2 of the Unicode Bidirectional Algorithm).
+func Order(levels []int) []int {
+	n := len(levels)
+	order := make([]int, n)
+	highest, lowestOdd := 0, 0
+	for i, level := range levels {
+		order[i] = i
+		if level > highest {
+			highest = level
+		}
+		if level%2 == 1 && (lowestOdd == 0 || level < lowestOdd) {
+			lowestOdd = level
+		}
+	}
+	if lowestOdd == 0 {
+		return order
+	}
+
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < n; i++ {
+			if levels[order[i]] < level {
+				continue
+			}
+			j := i
+			for j < n && levels[order[j]] >= level {
+				j++
+			}
+			for lo, hi := i, j-1; lo < hi; lo, hi = lo+1, hi-1 {
+				order[lo], order[hi] = order[hi], order[lo]
+			}
+			i = j
+		}
+	}
+	return order
+}
@@ -133,7 +133,13 @@ func NewToUnicodeCMap(codeToRune map[CharCode]rune) *CMap {
 	for code, r := range codeToRune {
 		codeToUnicode[code] = string(r)
 	}
+	return NewToUnicodeCMapFromStrings(codeToUnicode)
+}
 
+// NewToUnicodeCMapFromStrings returns an identity CMap with codeToUnicode matching the
+// `codeToUnicode` arg. Unlike NewToUnicodeCMap, it can map character codes to strings of several
+// runes, such as the text of ligature glyphs.
+func NewToUnicodeCMapFromStrings(codeToUnicode map[CharCode]string) *CMap {
 	cmap := &CMap{
 		name:  "Adobe-Identity-UCS",
 		ctype: 2,
@@ -145,9 +151,9 @@ func NewToUnicodeCMap(codeToRune map[CharCode]rune) *CMap {
 		},
 		codespaces:    []Codespace{{Low: 0, High: 0xffff}},
 		codeToUnicode: codeToUnicode,
-		unicodeToCode: make(map[string]CharCode, len(codeToRune)),
-		codeToCID:     make(map[CharCode]CharCode, len(codeToRune)),
-		cidToCode:     make(map[CharCode]CharCode, len(codeToRune)),
+		unicodeToCode: make(map[string]CharCode, len(codeToUnicode)),
+		codeToCID:     make(map[CharCode]CharCode, len(codeToUnicode)),
+		cidToCode:     make(map[CharCode]CharCode, len(codeToUnicode)),
 	}
 
 	cmap.computeInverseMappings()
@@ -508,7 +514,7 @@ func (cmap *CMap) toBfData() string {
 	prevRune := cmap.codeToUnicode[codes[0]]
 	for _, c := range codes[1:] {
 		currRune := cmap.codeToUnicode[c]
-		if c == currCharRange.code1+1 && lastRune(currRune) == lastRune(prevRune)+1 {
+		if c == currCharRange.code1+1 && isNextInRange(prevRune, currRune) {
 			currCharRange.code1 = c
 		} else {
 			charRanges = append(charRanges, currCharRange)
@@ -565,10 +571,15 @@ func (cmap *CMap) toBfData() string {
 	return strings.Join(lines, "\n")
 }
 
-// lastRune returns the last rune in `s`.
-func lastRune(s string) rune {
-	runes := []rune(s)
-	return runes[len(runes)-1]
+// isNextInRange returns true if `s` can follow `prev` in a bfrange, where the last rune of the
+// destination string is incremented for each character code: the strings have the same length
+// and differ only in their last runes, and the last rune of `s` is one more than that of `prev`.
+func isNextInRange(prev, s string) bool {
+	p, r := []rune(prev), []rune(s)
+	if len(p) == 0 || len(p) != len(r) || string(p[:len(p)-1]) != string(r[:len(r)-1]) {
+		return false
+	}
+	return r[len(r)-1] == p[len(p)-1]+1
 }
 
 // hexCode return the CMap hex code for `s`.
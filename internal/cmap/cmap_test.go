@@ -702,3 +702,29 @@ func checkCmapWriteRead(t *testing.T, codeToUnicode map[CharCode]rune) {
 		}
 	}
 }
+
+// TestCMapCreationStrings checks that CMaps that map character codes to strings of several runes,
+// such as the text of ligature glyphs, are written and read back correctly.
+func TestCMapCreationStrings(t *testing.T) {
+	codeToUnicode := map[CharCode]string{
+		0x0010: "a",
+		0x0011: "ab", // Its last rune follows "a" but it is longer.
+		0x0012: "fi",
+		0x0013: "fj",  // Can follow "fi" in a range.
+		0x0014: "gk",  // Its last rune follows "fj" but its prefix differs.
+		0x0015: "क्ष", // Devanagari conjunct.
+	}
+	cmap0 := NewToUnicodeCMapFromStrings(codeToUnicode)
+	cmap, err := LoadCmapFromDataCID(cmap0.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to load CMap: %v", err)
+	}
+	if len(cmap.codeToUnicode) != len(codeToUnicode) {
+		t.Fatalf("Incorrect length. expected=%d test=%d", len(codeToUnicode), len(cmap.codeToUnicode))
+	}
+	for code, s := range codeToUnicode {
+		if u, ok := cmap.CharcodeToUnicode(code); !ok || u != s {
+			t.Errorf("Unicode mismatch: code=0x%04x expected=%q test=%q", code, s, u)
+		}
+	}
+}
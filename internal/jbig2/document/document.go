@@ -66,6 +66,9 @@ type Document struct {
 
 	w *writer.Buffer
 
+	// EncodeGlobals defines if the PDF mode document stores the symbols shared between the pages in the
+	// global segments. It allows to add multiple pages, where each of them is encoded as a separate embedded
+	// stream with the 'EncodePages' method.
 	EncodeGlobals bool
 	// globalSymbolsNumber is the number of globally defined symbols.
 	globalSymbolsNumber int
@@ -97,7 +100,7 @@ func InitEncodeDocument(fullHeaders bool) *Document {
 func (d *Document) AddGenericPage(bm *bitmap.Bitmap, duplicateLineRemoval bool) (err error) {
 	const processName = "Document.AddGenericPage"
 	// check if this is PDFMode and there is already a page
-	if !d.FullHeaders && d.NumberOfPages != 0 {
+	if !d.FullHeaders && !d.EncodeGlobals && d.NumberOfPages != 0 {
 		return errors.Error(processName, "document already contains page. FileMode disallows adding more than one page")
 	}
 	// initialize page
@@ -129,7 +132,7 @@ func (d *Document) AddGenericPage(bm *bitmap.Bitmap, duplicateLineRemoval bool)
 func (d *Document) AddClassifiedPage(bm *bitmap.Bitmap, method classer.Method) (err error) {
 	const processName = "Document.AddClassifiedPage"
 	// check if this is PDFMode and there is already a page
-	if !d.FullHeaders && d.NumberOfPages != 0 {
+	if !d.FullHeaders && !d.EncodeGlobals && d.NumberOfPages != 0 {
 		return errors.Error(processName, "document already contains page. FileMode disallows adding more than one page")
 	}
 	// initialize the classer if not set yet
@@ -170,19 +173,19 @@ func (d *Document) AddClassifiedPage(bm *bitmap.Bitmap, method classer.Method) (
 
 // completeClassifiedPages completes the pages with the classification encoding type.
 // creates global symbol dictionary segment.
-func (d *Document) completeClassifiedPages() (err error) {
+func (d *Document) completeClassifiedPages() (globalSD *segments.Header, err error) {
 	const processName = "completeClassifiedPages"
 
 	// check if the Classer is initialized
 	if d.Classer == nil {
-		return nil
+		return nil, nil
 	}
 	// map symbol number to the times it was used
 	d.symbolsUsed = make([]int, d.Classer.UndilatedTemplates.Size())
 	for i := 0; i < d.Classer.ClassIDs.Size(); i++ {
 		classID, err := d.Classer.ClassIDs.Get(i)
 		if err != nil {
-			return errors.Wrapf(err, processName, "class with id: '%d'", i)
+			return nil, errors.Wrapf(err, processName, "class with id: '%d'", i)
 		}
 		d.symbolsUsed[classID]++
 	}
@@ -204,7 +207,7 @@ func (d *Document) completeClassifiedPages() (err error) {
 	// for that page.
 	for i, pageNumber := range *d.Classer.ComponentPageNumbers {
 		if page, ok = d.Pages[pageNumber]; !ok {
-			return errors.Errorf(processName, "page: '%d' not found", i)
+			return nil, errors.Errorf(processName, "page: '%d' not found", i)
 		}
 		// make sure that the page is not Generic Encoded.
 		if page.EncodingMethod == GenericEM {
@@ -217,7 +220,7 @@ func (d *Document) completeClassifiedPages() (err error) {
 		// check if the symbol was used on this page exclusively.
 		symbol, err := d.Classer.ClassIDs.Get(i)
 		if err != nil {
-			return errors.Wrapf(err, processName, "no such classID: %d", i)
+			return nil, errors.Wrapf(err, processName, "no such classID: %d", i)
 		}
 		if d.symbolsUsed[symbol] == 1 && d.NumberOfPages != 1 {
 			sus := append(d.singleUseSymbols[pageNumber], symbol)
@@ -225,27 +228,30 @@ func (d *Document) completeClassifiedPages() (err error) {
 		}
 	}
 	if err = d.Classer.ComputeLLCorners(); err != nil {
-		return errors.Wrap(err, processName, "")
+		return nil, errors.Wrap(err, processName, "")
 	}
 
-	// TODO: set the page number of the symold dictionary depending if there are more than one page.
-	// This is related with the mapping[*bitmap.Bitmap]int
-	// create global symbols
-	if _, err = d.addSymbolDictionary(0, d.Classer.UndilatedTemplates, globalSymbols, d.symbolIndexMap, false); err != nil {
-		return errors.Wrap(err, processName, "")
+	// the PDF embedded stream of a single page should be self contained - the symbol dictionary is
+	// stored within the page segments. Otherwise it is stored in the global segments.
+	var pageNumber int
+	if !d.FullHeaders && !d.EncodeGlobals {
+		pageNumber = 1
 	}
-	return nil
+	d.globalSymbolsNumber = len(globalSymbols)
+	if globalSD, err = d.addSymbolDictionary(pageNumber, d.Classer.UndilatedTemplates, globalSymbols, d.symbolIndexMap, true); err != nil {
+		return nil, errors.Wrap(err, processName, "")
+	}
+	return globalSD, nil
 }
 
-func (d *Document) produceClassifiedPages() (err error) {
+func (d *Document) produceClassifiedPages(globalSD *segments.Header) (err error) {
 	const processName = "produceClassifiedPages"
 	if d.Classer == nil {
 		return nil
 	}
 	var (
-		page     *Page
-		ok       bool
-		globalSD *segments.Header
+		page *Page
+		ok   bool
 	)
 	// iterate over all pages and find if it is of type different than GenericEM.
 	for i := 1; i <= int(d.NumberOfPages); i++ {
@@ -255,11 +261,6 @@ func (d *Document) produceClassifiedPages() (err error) {
 		if page.EncodingMethod == GenericEM {
 			continue
 		}
-		if globalSD == nil {
-			if globalSD, err = d.GlobalSegments.GetSymbolDictionary(); err != nil {
-				return errors.Wrap(err, processName, "")
-			}
-		}
 		// produce new classified page.
 		if err = d.produceClassifiedPage(page, globalSD); err != nil {
 			return errors.Wrapf(err, processName, "page: '%d'", i)
@@ -281,7 +282,7 @@ func (d *Document) produceClassifiedPage(page *Page, globalSD *segments.Header)
 	if len(d.singleUseSymbols[page.PageNumber]) > 0 {
 		// create new symbols dictionary
 		secondSymbolMap = map[int]int{}
-		extraSDHeader, err := d.addSymbolDictionary(page.PageNumber, d.Classer.UndilatedTemplates, d.singleUseSymbols[page.PageNumber], secondSymbolMap, false)
+		extraSDHeader, err := d.addSymbolDictionary(page.PageNumber, d.Classer.UndilatedTemplates, d.singleUseSymbols[page.PageNumber], secondSymbolMap, true)
 		if err != nil {
 			return errors.Wrap(err, processName, "")
 		}
@@ -440,6 +441,9 @@ func (d *Document) completeSymbols() (err error) {
 // Encode encodes the given document and stores into 'w' writer.
 func (d *Document) Encode() (data []byte, err error) {
 	const processName = "Document.Encode"
+	if !d.FullHeaders && d.NumberOfPages > 1 {
+		return nil, errors.Error(processName, "PDF mode document with multiple pages must be encoded using EncodePages")
+	}
 	var n, temp int
 	// if the full headers flag is on, encode file header
 	if d.FullHeaders {
@@ -452,18 +456,14 @@ func (d *Document) Encode() (data []byte, err error) {
 		seg  *segments.Header
 		page *Page
 	)
-	// complete classified pages
-	if err = d.completeClassifiedPages(); err != nil {
-		return nil, errors.Wrap(err, processName, "")
-	}
-	// produce classified pages
-	if err = d.produceClassifiedPages(); err != nil {
+	// complete and produce the classified pages.
+	if err = d.completeClassification(); err != nil {
 		return nil, errors.Wrap(err, processName, "")
 	}
 
 	if d.GlobalSegments != nil {
 		for _, seg = range d.GlobalSegments.Segments {
-			if err = d.encodeSegment(seg, &n); err != nil {
+			if err = d.encodeSegment(seg, d.w, &n); err != nil {
 				return nil, errors.Wrap(err, processName, "")
 			}
 		}
@@ -474,7 +474,7 @@ func (d *Document) Encode() (data []byte, err error) {
 			return nil, errors.Errorf(processName, "page: '%d' not found", i)
 		}
 		for _, seg = range page.Segments {
-			if err = d.encodeSegment(seg, &n); err != nil {
+			if err = d.encodeSegment(seg, d.w, &n); err != nil {
 				return nil, errors.Wrap(err, processName, "")
 			}
 		}
@@ -496,11 +496,69 @@ func (d *Document) Encode() (data []byte, err error) {
 	return data, nil
 }
 
-func (d *Document) encodeSegment(seg *segments.Header, n *int) error {
+// EncodePages encodes the PDF mode document with the 'EncodeGlobals' flag set. The global segments
+// are encoded as the 'globals' data, that should be stored in the JBIG2Globals stream.
+// Each page is encoded as a separate embedded stream referring to the page number 1,
+// the 'pages' are in the order of their page numbers.
+func (d *Document) EncodePages() (globals []byte, pages [][]byte, err error) {
+	const processName = "Document.EncodePages"
+	if d.FullHeaders || !d.EncodeGlobals {
+		return nil, nil, errors.Error(processName, "only the PDF mode document with the EncodeGlobals flag could be encoded by pages")
+	}
+	// complete and produce the classified pages.
+	if err = d.completeClassification(); err != nil {
+		return nil, nil, errors.Wrap(err, processName, "")
+	}
+
+	var n int
+	// the global segments are numbered first, so that the page segments could refer them.
+	if d.GlobalSegments != nil {
+		w := writer.BufferedMSB()
+		for _, seg := range d.GlobalSegments.Segments {
+			if err = d.encodeSegment(seg, w, &n); err != nil {
+				return nil, nil, errors.Wrap(err, processName, "globals")
+			}
+		}
+		globals = w.Data()
+	}
+
+	for i := 1; i <= int(d.NumberOfPages); i++ {
+		page, ok := d.Pages[i]
+		if !ok {
+			return nil, nil, errors.Errorf(processName, "page: '%d' not found", i)
+		}
+		w := writer.BufferedMSB()
+		for _, seg := range page.Segments {
+			// each embedded stream contains a single page with the number 1.
+			seg.PageAssociation = 1
+			if err = d.encodeSegment(seg, w, &n); err != nil {
+				return nil, nil, errors.Wrapf(err, processName, "page: '%d'", i)
+			}
+		}
+		pages = append(pages, w.Data())
+	}
+	return globals, pages, nil
+}
+
+// completeClassification completes the classified pages and produces their symbol dictionaries
+// and text regions.
+func (d *Document) completeClassification() error {
+	const processName = "completeClassification"
+	globalSD, err := d.completeClassifiedPages()
+	if err != nil {
+		return errors.Wrap(err, processName, "")
+	}
+	if err = d.produceClassifiedPages(globalSD); err != nil {
+		return errors.Wrap(err, processName, "")
+	}
+	return nil
+}
+
+func (d *Document) encodeSegment(seg *segments.Header, w *writer.Buffer, n *int) error {
 	const processName = "encodeSegment"
 	seg.SegmentNumber = d.nextSegmentNumber()
 
-	temp, err := seg.Encode(d.w)
+	temp, err := seg.Encode(w)
 	if err != nil {
 		return errors.Wrapf(err, processName, "segment: '%d'", seg.SegmentNumber)
 	}
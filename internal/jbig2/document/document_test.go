@@ -16,6 +16,7 @@ import (
 
 	"github.com/gnaoh1379/unipdf/internal/jbig2/bitmap"
 	"github.com/gnaoh1379/unipdf/internal/jbig2/document/segments"
+	"github.com/gnaoh1379/unipdf/internal/jbig2/encoder/classer"
 	"github.com/gnaoh1379/unipdf/internal/jbig2/reader"
 )
 
@@ -679,6 +680,138 @@ func TestEncodeDocument(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("Classified", func(t *testing.T) {
+		methods := map[string]classer.Method{"Correlation": classer.Correlation, "RankHaus": classer.RankHaus}
+		first := getSymbolsPage(t, "DO IT NOW", "OR NEVER")
+		second := getSymbolsPage(t, "NOW OR NEVER", "DO IT")
+
+		t.Run("FullHeaders", func(t *testing.T) {
+			for name, method := range methods {
+				t.Run(name, func(t *testing.T) {
+					d := InitEncodeDocument(true)
+					require.NoError(t, d.AddClassifiedPage(first, method))
+					require.NoError(t, d.AddClassifiedPage(second, method))
+
+					data, err := d.Encode()
+					require.NoError(t, err)
+
+					// the repeated symbols should be classified into the same classes.
+					assert.Less(t, d.Classer.UndilatedTemplates.Size(), d.Classer.ClassIDs.Size())
+
+					decoded, err := DecodeDocument(reader.New(data), nil)
+					require.NoError(t, err)
+					assert.Equal(t, uint32(2), decoded.NumberOfPages)
+
+					// the symbols are stored in the global symbol dictionary.
+					require.NotNil(t, decoded.GlobalSegments)
+					_, err = decoded.GlobalSegments.GetSymbolDictionary()
+					require.NoError(t, err)
+
+					for i, expected := range []*bitmap.Bitmap{first, second} {
+						page, err := decoded.GetPage(i + 1)
+						require.NoError(t, err)
+
+						bm, err := page.GetBitmap()
+						require.NoError(t, err)
+						assert.True(t, expected.Equals(bm), "page: %d", i+1)
+					}
+				})
+			}
+		})
+
+		t.Run("PDFMode", func(t *testing.T) {
+			for name, method := range methods {
+				t.Run(name, func(t *testing.T) {
+					d := InitEncodeDocument(false)
+					require.NoError(t, d.AddClassifiedPage(first, method))
+
+					data, err := d.Encode()
+					require.NoError(t, err)
+
+					// the single page stream is self contained.
+					assert.Nil(t, d.GlobalSegments)
+
+					decoded, err := DecodeDocument(reader.New(data), nil)
+					require.NoError(t, err)
+
+					page, err := decoded.GetPage(1)
+					require.NoError(t, err)
+
+					bm, err := page.GetBitmap()
+					require.NoError(t, err)
+					assert.True(t, first.Equals(bm))
+				})
+			}
+		})
+
+		t.Run("EncodeGlobals", func(t *testing.T) {
+			for name, method := range methods {
+				t.Run(name, func(t *testing.T) {
+					d := InitEncodeDocument(false)
+					d.EncodeGlobals = true
+					require.NoError(t, d.AddClassifiedPage(first, method))
+					require.NoError(t, d.AddClassifiedPage(second, method))
+
+					// multiple pages in the pdf mode must be encoded by pages.
+					_, err := d.Encode()
+					require.Error(t, err)
+
+					globals, pages, err := d.EncodePages()
+					require.NoError(t, err)
+					require.Len(t, pages, 2)
+
+					gdoc, err := DecodeDocument(reader.New(globals), nil)
+					require.NoError(t, err)
+					require.NotNil(t, gdoc.GlobalSegments)
+
+					for i, expected := range []*bitmap.Bitmap{first, second} {
+						decoded, err := DecodeDocument(reader.New(pages[i]), gdoc.GlobalSegments)
+						require.NoError(t, err)
+
+						page, err := decoded.GetPage(1)
+						require.NoError(t, err)
+
+						bm, err := page.GetBitmap()
+						require.NoError(t, err)
+						assert.True(t, expected.Equals(bm), "page: %d", i+1)
+					}
+				})
+			}
+		})
+	})
+}
+
+// getSymbolsPage gets the test page bitmap with the provided lines of text.
+func getSymbolsPage(t *testing.T, lines ...string) *bitmap.Bitmap {
+	t.Helper()
+	const scale, space = 2, 6
+	symbols := map[rune]func(*testing.T, ...int) *bitmap.Bitmap{
+		'D': bitmap.TstDSymbol, 'E': bitmap.TstESymbol, 'I': bitmap.TstISymbol,
+		'N': bitmap.TstNSymbol, 'O': bitmap.TstOSymbol, 'R': bitmap.TstRSymbol,
+		'T': bitmap.TstTSymbol, 'V': bitmap.TstVSymbol, 'W': bitmap.TstWSymbol,
+	}
+	bms := &bitmap.Bitmaps{}
+	width, y := 0, space
+	for _, line := range lines {
+		x := space
+		for _, r := range line {
+			if r == ' ' {
+				x += space
+				continue
+			}
+			sym, ok := symbols[r]
+			require.True(t, ok, "symbol: %c", r)
+			bitmap.TstAddSymbol(t, bms, sym(t, scale), &x, y, scale)
+		}
+		if x > width {
+			width = x
+		}
+		y += 5*scale + space
+	}
+	bm := bitmap.New(width+space, y)
+	bitmap.TstWriteSymbols(t, bms, bm)
+	return bm
 }
 
 func getFrame(t *testing.T) *bitmap.Bitmap {
@@ -131,19 +131,12 @@ func (p *Page) addTextRegionSegment(referredTo []*segments.Header, globalSymbols
 		Type:            segments.TImmediateTextRegion,
 	}
 
-	// if the text region referes only to global symbol dictionary
-	// it shold be stored just after page information segment
-	tp := segments.TPageInformation
-	if localSymbolsMap != nil {
-		// otherwise store it after local symbol dictionary
-		tp = segments.TSymbolDictionary
-	}
-
+	// the text region should be stored just after page information segment
+	// and the symbol dictionaries stored within the page.
 	var index int
-	for ; index < len(p.Segments); index++ {
-		if p.Segments[index].Type == tp {
-			index++
-			break
+	for i, seg := range p.Segments {
+		if seg.Type == segments.TPageInformation || seg.Type == segments.TSymbolDictionary {
+			index = i + 1
 		}
 	}
 	p.Segments = append(p.Segments, nil)
@@ -103,12 +103,12 @@ func (s *SymbolDictionary) InitEncode(symbols *bitmap.Bitmaps, symbolList []int,
 	s.symbols = symbols
 	s.symbolList = make([]int, len(symbolList))
 	copy(s.symbolList, symbolList)
-	// the symbols and symbollist should have the same length
-	if len(s.symbolList) != s.symbols.Size() {
-		return errors.Error(processName, "symbols and symbolList of different size")
+	// the symbolList selects a subset of the symbols - it cannot be larger than them.
+	if len(s.symbolList) > s.symbols.Size() {
+		return errors.Error(processName, "symbolList larger than the symbols")
 	}
-	s.NumberOfNewSymbols = uint32(symbols.Size())
-	s.NumberOfExportedSymbols = uint32(symbols.Size())
+	s.NumberOfNewSymbols = uint32(len(symbolList))
+	s.NumberOfExportedSymbols = uint32(len(symbolList))
 	s.symbolMap = symbolMap
 	s.unborderSymbols = unborderSymbols
 	return nil
@@ -232,7 +232,14 @@ func (s *SymbolDictionary) encodeSymbols(w writer.BinaryWriter) (n int, err erro
 	}
 	mapping := map[*bitmap.Bitmap]int{}
 	for i, bm := range symbols.Values {
-		mapping[bm] = i
+		if s.unborderSymbols {
+			// the classified templates contains the border added by the classer.
+			if bm, err = bm.RemoveBorder(BorderSize); err != nil {
+				return 0, errors.Wrapf(err, processName, "unbordering symbol: '%d'", i)
+			}
+			symbols.Values[i] = bm
+		}
+		mapping[bm] = s.symbolList[i]
 	}
 
 	// sort symbols by height.
@@ -278,8 +285,7 @@ func (s *SymbolDictionary) encodeSymbols(w writer.BinaryWriter) (n int, err erro
 				if err = ectx.EncodeBitmap(bm, false); err != nil {
 					return 0, errors.Wrapf(err, processName, "Height: %d Width: %d", height, width)
 				}
-				idx := mapping[bm]
-				s.symbolMap[idx] = number
+				s.symbolMap[mapping[bm]] = number
 				number++
 			}
 		}
@@ -996,42 +996,53 @@ func (t *TextRegion) encodeSymbols(w writer.BinaryWriter) (n int, err error) {
 			return n, errors.Wrap(err, processName, "")
 		}
 
-		// deltaS is the difference in the 'x' value between the symbols.
+		stripeT = stripeY
+
+		// currentS is the 's' coordinate of the right edge of the last symbol in the stripe.
 		var currentS int
 		// iterate over the symbols in the stripe
 		for i, symbol := range stripe.IntSlice {
+			// get the assigned symbol index
+			assigned, err := t.assignments.Get(symbol)
+			if err != nil {
+				return n, errors.Wrap(err, processName, "")
+			}
+			// the symbols are stored with the border - the decoder uses the width of the unbordered one.
+			bm, err := t.symbols.GetBitmap(assigned)
+			if err != nil {
+				return n, errors.Wrapf(err, processName, "symbol: '%d'", assigned)
+			}
+			symbolWidth := bm.Width - 2*BorderSize
+
+			x := int(stripe.XAtIndex(i))
 			switch i {
 			case 0:
 				// the first symbol is encoded with the 'IAFS'
-				deltaFS := int(stripe.XAtIndex(i)) - firsts
+				deltaFS := x - firsts
 				if err = encodeCtx.EncodeInteger(encoder.IAFS, deltaFS); err != nil {
 					return n, errors.Wrap(err, processName, "")
 				}
 				firsts += deltaFS
-				currentS = firsts
 			default:
 				// all other symbols in the stripe are encoded using 'IADS'
-				// encode only the difference between the last symbol 'x' and this one.
-				deltaS := int(stripe.XAtIndex(i)) - currentS
+				// encode only the difference between the last symbol right edge and this one.
+				deltaS := x - currentS - int(t.SbdsOffset)
 				if err = encodeCtx.EncodeInteger(encoder.IADS, deltaS); err != nil {
 					return n, errors.Wrap(err, processName, "")
 				}
-				currentS += deltaS
-			}
-			// get the assigned symbol index
-			assigned, err := t.assignments.Get(symbol)
-			if err != nil {
-				return n, errors.Wrap(err, processName, "")
 			}
+			// with the bottom left reference corner the decoder moves the 's' to the right edge of the symbol.
+			currentS = x + symbolWidth - 1
 
 			// try to find the symbol in the global map
 			symbolID, ok := t.globalSymbolsMap[assigned]
 			if !ok {
-				// and in the local map
+				// and in the local map - local symbols are numbered after the global ones.
 				symbolID, ok = t.localSymbolsMap[assigned]
 				if !ok {
 					return n, errors.Errorf(processName, "Symobl: '%d' is not found in global and local symbol map", assigned)
 				}
+				symbolID += len(t.globalSymbolsMap)
 			}
 			// encode the symbol id.
 			if err = encodeCtx.EncodeIAID(t.symBits, symbolID); err != nil {
@@ -145,9 +145,10 @@ func (c *Classer) ComputeLLCorners() (err error) {
 			common.Log.Debug("Getting UndilatedTemplates failed: %v", err)
 			return errors.Wrap(err, processName, "Undilated Templates")
 		}
-		h = bm.Height
+		// the templates contains the border, where the UL corners are related to the unbordered symbol.
+		h = bm.Height - 2*JbAddedPixels
 		// Add the global LL corner point.
-		c.PtaLL.AddPoint(x1, y1+float32(h)) // )-1-2*float32(JbAddedPixels)
+		c.PtaLL.AddPoint(x1, y1+float32(h-1))
 	}
 	return nil
 }
@@ -53,17 +53,23 @@ func finalAlignmentPositioning(s *bitmap.Bitmap, x, y, iDelX, iDelY int, t *bitm
 	if err != nil {
 		return pt, errors.Wrap(err, processName, "")
 	}
-	d, _, err := s.ClipRectangle(box)
+	d, boxC, err := s.ClipRectangle(box)
 	if err != nil {
 		common.Log.Error("Can't clip rectangle: %v", box)
 		return pt, errors.Wrap(err, processName, "")
 	}
-	r := bitmap.New(d.Width, d.Height)
+	// the box gets clipped for the components lying at the edges of the source,
+	// the clipped bitmap is then placed at its position within the template sized one.
+	dx, dy := boxC.Min.X-bx, boxC.Min.Y-by
+	r := bitmap.New(w, h)
 	minCount := math.MaxInt32
 	var i, j, count, minX, minY int
 	for i = -1; i <= 1; i++ {
 		for j = -1; j <= 1; j++ {
-			if _, err = bitmap.Copy(r, d); err != nil {
+			if err = r.RasterOperation(0, 0, w, h, bitmap.PixClr, nil, 0, 0); err != nil {
+				return pt, errors.Wrap(err, processName, "")
+			}
+			if err = r.RasterOperation(dx, dy, d.Width, d.Height, bitmap.PixSrc, d, 0, 0); err != nil {
 				return pt, errors.Wrap(err, processName, "")
 			}
 			if err = r.RasterOperation(j, i, w, h, bitmap.PixSrcXorDst, t, 0, 0); err != nil {
@@ -98,7 +98,6 @@ func (c *Classer) classifyCorrelation(boxa *bitmap.Boxes, pixas *bitmap.Bitmaps,
 		area, area1, area2 int
 		threshold          float64
 		x1, y1, x2, y2     float32
-		ct1, ct2           bitmap.Point
 		found              bool
 		findContext        *similarTemplatesFinder
 		i                  int
@@ -116,7 +115,7 @@ func (c *Classer) classifyCorrelation(boxa *bitmap.Boxes, pixas *bitmap.Bitmaps,
 		found = false
 		nt := len(c.UndilatedTemplates.Values)
 		findContext = initSimilarTemplatesFinder(c, bm1)
-		for iclass := findContext.Next(); iclass > -1; {
+		for iclass := findContext.Next(); iclass > -1; iclass = findContext.Next() {
 			// get the template
 			if bm2, err = c.UndilatedTemplates.GetBitmap(iclass); err != nil {
 				return errors.Wrap(err, processName, "unidlated[iclass] = bm2")
@@ -138,7 +137,7 @@ func (c *Classer) classifyCorrelation(boxa *bitmap.Boxes, pixas *bitmap.Bitmaps,
 				threshold = c.Settings.Thresh
 			}
 
-			overThreshold, err := bitmap.CorrelationScoreThresholded(bm1, bm2, area1, area2, ct1.X-ct2.X, ct1.Y-ct2.Y, MaxDiffWidth, MaxDiffHeight, sumtab, pixRowCts[i], float32(threshold))
+			overThreshold, err := bitmap.CorrelationScoreThresholded(bm1, bm2, area1, area2, x1-x2, y1-y2, MaxDiffWidth, MaxDiffHeight, sumtab, pixRowCts[i], float32(threshold))
 			if err != nil {
 				return errors.Wrap(err, processName, "")
 			}
@@ -220,7 +219,7 @@ func (c *Classer) classifyCorrelation(boxa *bitmap.Boxes, pixas *bitmap.Bitmaps,
 			c.ClassInstances.AddBitmaps(bitmaps)
 			c.CentroidPointsTemplates.AddPoint(x1, y1)
 			c.FgTemplates.AddInt(area1)
-			c.UndilatedTemplates.AddBitmap(bm)
+			c.UndilatedTemplates.AddBitmap(bm1)
 
 			area = (bm1.Width - 2*JbAddedPixels) * (bm1.Height - 2*JbAddedPixels)
 			if err = c.TemplateAreas.Add(area); err != nil {
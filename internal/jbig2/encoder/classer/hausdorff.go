@@ -51,14 +51,14 @@ func (c *Classer) classifyRankHaus(boxa *bitmap.Boxes, pixa *bitmap.Bitmaps, pag
 		if err != nil {
 			return errors.Wrap(err, processName, "")
 		}
-		bms1.Values[n] = bm1 // un-dilated
-		bms2.Values[n] = bm2 // dilated
+		bms1.Values[i] = bm1 // un-dilated
+		bms2.Values[i] = bm2 // dilated
 	}
 	pta, err := bitmap.Centroids(bms1.Values)
 	if err != nil {
 		return errors.Wrap(err, processName, "")
 	}
-	if err = pta.Add(c.CentroidPoints); err != nil {
+	if err = c.CentroidPoints.Add(pta); err != nil {
 		common.Log.Trace("No centroids to add")
 	}
 
@@ -93,7 +93,7 @@ func (c *Classer) classifyRankHouseOne(boxa *bitmap.Boxes, pixa, bms1, bms2 *bit
 
 		found = false
 		findContext := initSimilarTemplatesFinder(c, bm1)
-		for iClass = findContext.Next(); iClass > -1; {
+		for iClass = findContext.Next(); iClass > -1; iClass = findContext.Next() {
 			bm3, err = c.UndilatedTemplates.GetBitmap(iClass)
 			if err != nil {
 				return errors.Wrap(err, processName, "bm3")
@@ -202,7 +202,7 @@ func (c *Classer) classifyRankHouseNonOne(boxa *bitmap.Boxes, pixa, bms1, bms2 *
 		nt := len(c.UndilatedTemplates.Values)
 		found = false
 		findContext := initSimilarTemplatesFinder(c, bm1)
-		for iClass = findContext.Next(); iClass > -1; {
+		for iClass = findContext.Next(); iClass > -1; iClass = findContext.Next() {
 			if bm3, err = c.UndilatedTemplates.GetBitmap(iClass); err != nil {
 				return errors.Wrap(err, processName, "pixat.[iClass]")
 			}
@@ -27,10 +27,11 @@ type similarTemplatesFinder struct {
 }
 
 // initSimilarTemplatesFinder initializes the templatesState context.
+// The 'bms' is the bordered component bitmap.
 func initSimilarTemplatesFinder(c *Classer, bms *bitmap.Bitmap) *similarTemplatesFinder {
 	return &similarTemplatesFinder{
-		Width:   bms.Width,
-		Height:  bms.Height,
+		Width:   bms.Width - 2*JbAddedPixels,
+		Height:  bms.Height - 2*JbAddedPixels,
 		Classer: c,
 	}
 }
@@ -64,9 +65,10 @@ func (f *similarTemplatesFinder) Next() int {
 			f.N = 0
 		}
 		size = len(f.CurrentNumbers)
-		for ; f.N < size; f.N++ {
+		for f.N < size {
 			templ = f.CurrentNumbers[f.N]
-			bmT, err = f.Classer.DilatedTemplates.GetBitmap(templ)
+			f.N++
+			bmT, err = f.Classer.UndilatedTemplates.GetBitmap(templ)
 			if err != nil {
 				common.Log.Debug("FindNextTemplate: template not found: ")
 				return 0
@@ -0,0 +1,663 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gnaoh1379/unipdf/common"
+)
+
+// Codestream markers.
+const (
+	markerSOC = 0xff4f // Start of codestream.
+	markerSIZ = 0xff51 // Image and tile size.
+	markerCOD = 0xff52 // Coding style default.
+	markerCOC = 0xff53 // Coding style component.
+	markerTLM = 0xff55 // Tile-part lengths.
+	markerPLM = 0xff57 // Packet length, main header.
+	markerPLT = 0xff58 // Packet length, tile-part header.
+	markerQCD = 0xff5c // Quantization default.
+	markerQCC = 0xff5d // Quantization component.
+	markerRGN = 0xff5e // Region of interest.
+	markerPOC = 0xff5f // Progression order change.
+	markerPPM = 0xff60 // Packed packet headers, main header.
+	markerPPT = 0xff61 // Packed packet headers, tile-part header.
+	markerCRG = 0xff63 // Component registration.
+	markerCOM = 0xff64 // Comment.
+	markerSOT = 0xff90 // Start of tile-part.
+	markerSOP = 0xff91 // Start of packet.
+	markerEPH = 0xff92 // End of packet header.
+	markerSOD = 0xff93 // Start of data.
+	markerEOC = 0xffd9 // End of codestream.
+)
+
+// Progression orders.
+const (
+	progressionLRCP = iota
+	progressionRLCP
+	progressionRPCL
+	progressionPCRL
+	progressionCPRL
+)
+
+// Code-block coding style flags.
+const (
+	cbStyleBypass       = 0x01 // Selective arithmetic coding bypass.
+	cbStyleReset        = 0x02 // Reset context probabilities on coding pass boundaries.
+	cbStyleTermAll      = 0x04 // Termination on each coding pass.
+	cbStyleCausal       = 0x08 // Vertically causal context.
+	cbStylePredictable  = 0x10 // Predictable termination.
+	cbStyleSegmentation = 0x20 // Segmentation symbols are used.
+)
+
+// Quantization styles.
+const (
+	quantizationNone            = 0
+	quantizationScalarDerived   = 1
+	quantizationScalarExpounded = 2
+)
+
+// maxLevels is the maximum number of the wavelet decomposition levels.
+const maxLevels = 32
+
+// maxImageSamples is the largest number of samples of all the components of an image that is
+// decoded. Larger images are rejected before any of their samples are allocated.
+const maxImageSamples = 1 << 28
+
+// componentSize is the SIZ information of a single image component.
+type componentSize struct {
+	precision int
+	signed    bool
+	dx, dy    int
+}
+
+// imageSize is the content of the SIZ marker segment.
+type imageSize struct {
+	x1, y1           int // Xsiz, Ysiz.
+	x0, y0           int // XOsiz, YOsiz.
+	tileW, tileH     int // XTsiz, YTsiz.
+	tileX0, tileY0   int // XTOsiz, YTOsiz.
+	components       []componentSize
+	numTilesX        int
+	numTilesY        int
+	componentIndexSz int // Size of the component index in COC, QCC, RGN and POC segments.
+}
+
+// codingStyle holds the component related coding parameters of the COD and COC segments.
+type codingStyle struct {
+	levels       int
+	xcb, ycb     int // Code-block size exponents.
+	blockStyle   int
+	reversible   bool
+	precinctSize []int // PPx | PPy<<4 per resolution level.
+}
+
+// progressionStyle holds the component independent coding parameters of the COD segment.
+type progressionStyle struct {
+	order  int
+	layers int
+	mct    bool
+	sop    bool
+	eph    bool
+}
+
+// quantization holds the content of the QCD and QCC segments.
+type quantization struct {
+	style     int
+	guardBits int
+	exponents []int
+	mantissas []int
+}
+
+// progressionChange is a single POC entry.
+type progressionChange struct {
+	resStart, compStart int
+	layerEnd            int
+	resEnd, compEnd     int
+	order               int
+}
+
+// codingParams are the parameters given either in the main or in the tile header.
+type codingParams struct {
+	progression  *progressionStyle
+	cod          *codingStyle
+	coc          []*codingStyle
+	qcd          *quantization
+	qcc          []*quantization
+	roiShift     []int
+	changes      []progressionChange
+	hasRoiShifts bool
+}
+
+func newCodingParams(numComponents int) *codingParams {
+	return &codingParams{
+		coc:      make([]*codingStyle, numComponents),
+		qcc:      make([]*quantization, numComponents),
+		roiShift: make([]int, numComponents),
+	}
+}
+
+// tilePart is a single tile-part of the codestream.
+type tilePart struct {
+	tileIndex int
+	data      []byte
+	// packedHeaders are the packet headers from the PPM segments assigned to the tile-part.
+	packedHeaders []byte
+}
+
+// tileData accumulates all tile-parts of a single tile.
+type tileData struct {
+	index         int
+	params        *codingParams
+	data          []byte
+	packedHeaders []byte
+	hasPPM        bool
+	ppt           map[int][]byte
+	parts         int
+}
+
+// codestream is the parsed JPEG 2000 codestream.
+type codestream struct {
+	size  imageSize
+	main  *codingParams
+	tiles []*tileData
+}
+
+// segmentReader is the helper to read the marker segments content.
+type segmentReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *segmentReader) u8() int {
+	if r.pos+1 > len(r.data) {
+		r.err = errors.New("jpx: marker segment too short")
+		return 0
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return int(v)
+}
+
+func (r *segmentReader) u16() int {
+	if r.pos+2 > len(r.data) {
+		r.err = errors.New("jpx: marker segment too short")
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return int(v)
+}
+
+func (r *segmentReader) u32() int {
+	if r.pos+4 > len(r.data) {
+		r.err = errors.New("jpx: marker segment too short")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return int(v)
+}
+
+func (r *segmentReader) componentIndex(size int) int {
+	if size == 1 {
+		return r.u8()
+	}
+	return r.u16()
+}
+
+func (r *segmentReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+// parseCodestream parses the main header and splits the codestream into tiles.
+// If 'headerOnly' is set only the main header is read.
+func parseCodestream(data []byte, headerOnly bool) (*codestream, error) {
+	if len(data) < 2 || binary.BigEndian.Uint16(data) != markerSOC {
+		return nil, errors.New("jpx: missing SOC marker")
+	}
+	cs := &codestream{}
+	pos := 2
+	sizRead := false
+	var ppm [][]byte
+	ppmIndices := []int{}
+	// Parse the main header.
+	for {
+		if pos+4 > len(data) {
+			return nil, errors.New("jpx: unexpected end of the main header")
+		}
+		marker := int(binary.BigEndian.Uint16(data[pos:]))
+		if marker == markerSOT {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, fmt.Errorf("jpx: invalid marker segment length: 0x%x", marker)
+		}
+		segment := data[pos+4 : pos+2+length]
+		pos += 2 + length
+
+		if !sizRead && marker != markerSIZ {
+			return nil, errors.New("jpx: SIZ marker must follow the SOC marker")
+		}
+		var err error
+		switch marker {
+		case markerSIZ:
+			if err = cs.parseSIZ(segment); err != nil {
+				return nil, err
+			}
+			sizRead = true
+			cs.main = newCodingParams(len(cs.size.components))
+		case markerPPM:
+			if len(segment) < 1 {
+				return nil, errors.New("jpx: invalid PPM segment")
+			}
+			ppmIndices = append(ppmIndices, int(segment[0]))
+			ppm = append(ppm, segment[1:])
+		case markerTLM, markerPLM, markerCRG, markerCOM:
+			// Not needed for decoding.
+		default:
+			if err = cs.parseCodingMarker(cs.main, marker, segment); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cs.main.cod == nil || cs.main.qcd == nil {
+		return nil, errors.New("jpx: missing COD or QCD marker in the main header")
+	}
+	if headerOnly {
+		return cs, nil
+	}
+
+	// Concatenate the PPM segments in their Zppm order.
+	var packedHeaders [][]byte
+	if len(ppm) > 0 {
+		order := make([]int, len(ppm))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return ppmIndices[order[i]] < ppmIndices[order[j]] })
+		var all []byte
+		for _, i := range order {
+			all = append(all, ppm[i]...)
+		}
+		for len(all) >= 4 {
+			n := int(binary.BigEndian.Uint32(all))
+			all = all[4:]
+			if n > len(all) {
+				n = len(all)
+			}
+			packedHeaders = append(packedHeaders, all[:n])
+			all = all[n:]
+		}
+	}
+
+	cs.tiles = make([]*tileData, cs.size.numTilesX*cs.size.numTilesY)
+	tilePartIndex := 0
+	for pos+2 <= len(data) {
+		marker := int(binary.BigEndian.Uint16(data[pos:]))
+		if marker == markerEOC {
+			break
+		}
+		if marker != markerSOT {
+			common.Log.Debug("jpx: unexpected marker 0x%x instead of SOT - stop reading", marker)
+			break
+		}
+		tileStart := pos
+		if pos+12 > len(data) {
+			return nil, errors.New("jpx: truncated SOT marker segment")
+		}
+		r := &segmentReader{data: data[pos+4 : pos+12]}
+		tileIndex := r.u16()
+		tileLength := r.u32()
+		r.u8() // TPsot
+		r.u8() // TNsot
+		if tileIndex >= len(cs.tiles) {
+			return nil, fmt.Errorf("jpx: invalid tile index: %d", tileIndex)
+		}
+		tile := cs.tiles[tileIndex]
+		if tile == nil {
+			tile = &tileData{
+				index:  tileIndex,
+				params: newCodingParams(len(cs.size.components)),
+				ppt:    map[int][]byte{},
+			}
+			cs.tiles[tileIndex] = tile
+		}
+		pos += 12
+		// Parse the tile-part header.
+		for {
+			if pos+2 > len(data) {
+				return nil, errors.New("jpx: unexpected end of the tile-part header")
+			}
+			marker = int(binary.BigEndian.Uint16(data[pos:]))
+			if marker == markerSOD {
+				pos += 2
+				break
+			}
+			if pos+4 > len(data) {
+				return nil, errors.New("jpx: unexpected end of the tile-part header")
+			}
+			length := int(binary.BigEndian.Uint16(data[pos+2:]))
+			if length < 2 || pos+2+length > len(data) {
+				return nil, fmt.Errorf("jpx: invalid marker segment length: 0x%x", marker)
+			}
+			segment := data[pos+4 : pos+2+length]
+			pos += 2 + length
+			switch marker {
+			case markerPPT:
+				if len(segment) < 1 {
+					return nil, errors.New("jpx: invalid PPT segment")
+				}
+				tile.ppt[int(segment[0])+256*tile.parts] = segment[1:]
+			case markerPLT, markerCOM:
+			case markerCOD, markerCOC, markerQCD, markerQCC, markerRGN:
+				if tile.parts > 0 {
+					// These are allowed only in the first tile-part header.
+					common.Log.Debug("jpx: marker 0x%x in the non-first tile-part header ignored", marker)
+					continue
+				}
+				if err := cs.parseCodingMarker(tile.params, marker, segment); err != nil {
+					return nil, err
+				}
+			case markerPOC:
+				if err := cs.parseCodingMarker(tile.params, marker, segment); err != nil {
+					return nil, err
+				}
+			default:
+				common.Log.Debug("jpx: unexpected marker 0x%x in the tile-part header ignored", marker)
+			}
+		}
+		end := tileStart + tileLength
+		if tileLength == 0 || end > len(data) {
+			// The tile-part extends up to the EOC marker.
+			end = len(data)
+			if end-2 >= pos && binary.BigEndian.Uint16(data[end-2:]) == markerEOC {
+				end -= 2
+			}
+		}
+		if end < pos {
+			return nil, errors.New("jpx: invalid tile-part length")
+		}
+		tile.data = append(tile.data, data[pos:end]...)
+		if packedHeaders != nil {
+			tile.hasPPM = true
+			if tilePartIndex < len(packedHeaders) {
+				tile.packedHeaders = append(tile.packedHeaders, packedHeaders[tilePartIndex]...)
+			}
+		}
+		tile.parts++
+		tilePartIndex++
+		pos = end
+	}
+
+	// Concatenate the PPT segments in their Zppt order.
+	for _, tile := range cs.tiles {
+		if tile == nil || len(tile.ppt) == 0 {
+			continue
+		}
+		keys := make([]int, 0, len(tile.ppt))
+		for k := range tile.ppt {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+		for _, k := range keys {
+			tile.packedHeaders = append(tile.packedHeaders, tile.ppt[k]...)
+		}
+		tile.hasPPM = true
+	}
+	return cs, nil
+}
+
+// parseSIZ parses the image and tile size marker segment.
+func (cs *codestream) parseSIZ(segment []byte) error {
+	r := &segmentReader{data: segment}
+	r.u16() // Rsiz - capabilities.
+	s := &cs.size
+	s.x1 = r.u32()
+	s.y1 = r.u32()
+	s.x0 = r.u32()
+	s.y0 = r.u32()
+	s.tileW = r.u32()
+	s.tileH = r.u32()
+	s.tileX0 = r.u32()
+	s.tileY0 = r.u32()
+	numComponents := r.u16()
+	if r.err != nil {
+		return r.err
+	}
+	if numComponents == 0 || numComponents > 16384 {
+		return fmt.Errorf("jpx: invalid number of components: %d", numComponents)
+	}
+	if s.x1 <= s.x0 || s.y1 <= s.y0 || s.tileW == 0 || s.tileH == 0 ||
+		s.tileX0 > s.x0 || s.tileY0 > s.y0 || s.tileX0+s.tileW <= s.x0 || s.tileY0+s.tileH <= s.y0 {
+		return errors.New("jpx: invalid image or tile size")
+	}
+	for i := 0; i < numComponents; i++ {
+		ssiz := r.u8()
+		c := componentSize{
+			precision: ssiz&0x7f + 1,
+			signed:    ssiz&0x80 != 0,
+			dx:        r.u8(),
+			dy:        r.u8(),
+		}
+		if c.dx == 0 || c.dy == 0 || c.precision > 38 {
+			return errors.New("jpx: invalid component parameters")
+		}
+		s.components = append(s.components, c)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	// The sizes are 32-bit, so the area doesn't overflow and neither does its product with the
+	// number of components if the area is within the limit.
+	area := uint64(s.x1-s.x0) * uint64(s.y1-s.y0)
+	if area > maxImageSamples || area*uint64(numComponents) > maxImageSamples {
+		return fmt.Errorf("jpx: image too large: %dx%d with %d components",
+			s.x1-s.x0, s.y1-s.y0, numComponents)
+	}
+	s.numTilesX = ceilDiv(s.x1-s.tileX0, s.tileW)
+	s.numTilesY = ceilDiv(s.y1-s.tileY0, s.tileH)
+	if s.numTilesX*s.numTilesY > 65535 {
+		return errors.New("jpx: too many tiles")
+	}
+	s.componentIndexSz = 1
+	if numComponents >= 257 {
+		s.componentIndexSz = 2
+	}
+	return nil
+}
+
+// parseCodingMarker parses the marker segments which may be found both in the main
+// and in the tile-part headers.
+func (cs *codestream) parseCodingMarker(p *codingParams, marker int, segment []byte) error {
+	r := &segmentReader{data: segment}
+	numComponents := len(cs.size.components)
+	switch marker {
+	case markerCOD:
+		scod := r.u8()
+		ps := &progressionStyle{
+			sop: scod&0x02 != 0,
+			eph: scod&0x04 != 0,
+		}
+		ps.order = r.u8()
+		ps.layers = r.u16()
+		ps.mct = r.u8() != 0
+		style, err := parseCodingStyle(r, scod&0x01 != 0)
+		if err != nil {
+			return err
+		}
+		if ps.layers == 0 || ps.order > progressionCPRL {
+			return errors.New("jpx: invalid COD marker segment")
+		}
+		p.progression = ps
+		p.cod = style
+	case markerCOC:
+		c := r.componentIndex(cs.size.componentIndexSz)
+		scoc := r.u8()
+		style, err := parseCodingStyle(r, scoc&0x01 != 0)
+		if err != nil {
+			return err
+		}
+		if c >= numComponents {
+			return fmt.Errorf("jpx: invalid COC component index: %d", c)
+		}
+		p.coc[c] = style
+	case markerQCD:
+		q, err := parseQuantization(r)
+		if err != nil {
+			return err
+		}
+		p.qcd = q
+	case markerQCC:
+		c := r.componentIndex(cs.size.componentIndexSz)
+		q, err := parseQuantization(r)
+		if err != nil {
+			return err
+		}
+		if c >= numComponents {
+			return fmt.Errorf("jpx: invalid QCC component index: %d", c)
+		}
+		p.qcc[c] = q
+	case markerRGN:
+		c := r.componentIndex(cs.size.componentIndexSz)
+		style := r.u8()
+		shift := r.u8()
+		if r.err != nil {
+			return r.err
+		}
+		if c >= numComponents || style != 0 {
+			return errors.New("jpx: invalid RGN marker segment")
+		}
+		p.roiShift[c] = shift
+		p.hasRoiShifts = true
+	case markerPOC:
+		entrySize := 5 + 2*cs.size.componentIndexSz
+		for r.remaining() >= entrySize {
+			ch := progressionChange{}
+			ch.resStart = r.u8()
+			ch.compStart = r.componentIndex(cs.size.componentIndexSz)
+			ch.layerEnd = r.u16()
+			ch.resEnd = r.u8()
+			ch.compEnd = r.componentIndex(cs.size.componentIndexSz)
+			if cs.size.componentIndexSz == 1 && ch.compEnd == 0 {
+				ch.compEnd = 256
+			}
+			ch.order = r.u8()
+			if ch.order > progressionCPRL {
+				return errors.New("jpx: invalid progression order in the POC segment")
+			}
+			p.changes = append(p.changes, ch)
+		}
+	}
+	return r.err
+}
+
+// parseCodingStyle parses the SPcod/SPcoc parameters.
+func parseCodingStyle(r *segmentReader, definedPrecincts bool) (*codingStyle, error) {
+	s := &codingStyle{}
+	s.levels = r.u8()
+	s.xcb = r.u8() + 2
+	s.ycb = r.u8() + 2
+	s.blockStyle = r.u8()
+	s.reversible = r.u8() == 1
+	if r.err != nil {
+		return nil, r.err
+	}
+	if s.levels > maxLevels || s.xcb > 10 || s.ycb > 10 || s.xcb+s.ycb > 12 {
+		return nil, errors.New("jpx: invalid coding style parameters")
+	}
+	s.precinctSize = make([]int, s.levels+1)
+	for i := range s.precinctSize {
+		if definedPrecincts {
+			s.precinctSize[i] = r.u8()
+		} else {
+			s.precinctSize[i] = 15 | 15<<4
+		}
+	}
+	return s, r.err
+}
+
+// parseQuantization parses the Sqcx and SPqcx parameters.
+func parseQuantization(r *segmentReader) (*quantization, error) {
+	sq := r.u8()
+	q := &quantization{
+		style:     sq & 0x1f,
+		guardBits: sq >> 5,
+	}
+	switch q.style {
+	case quantizationNone:
+		for r.remaining() > 0 {
+			q.exponents = append(q.exponents, r.u8()>>3)
+			q.mantissas = append(q.mantissas, 0)
+		}
+	case quantizationScalarDerived, quantizationScalarExpounded:
+		for r.remaining() >= 2 {
+			v := r.u16()
+			q.exponents = append(q.exponents, v>>11)
+			q.mantissas = append(q.mantissas, v&0x7ff)
+		}
+	default:
+		return nil, fmt.Errorf("jpx: unsupported quantization style: %d", q.style)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(q.exponents) == 0 {
+		return nil, errors.New("jpx: empty quantization marker segment")
+	}
+	return q, nil
+}
+
+// tileParams resolves the coding parameters of the tile according to the marker segments
+// precedence: tile COC/QCC > tile COD/QCD > main COC/QCC > main COD/QCD.
+func (cs *codestream) tileParams(t *codingParams) (*progressionStyle, []*codingStyle, []*quantization, []int, []progressionChange) {
+	numComponents := len(cs.size.components)
+	styles := make([]*codingStyle, numComponents)
+	quants := make([]*quantization, numComponents)
+	roi := make([]int, numComponents)
+	for c := 0; c < numComponents; c++ {
+		switch {
+		case t.coc[c] != nil:
+			styles[c] = t.coc[c]
+		case t.cod != nil:
+			styles[c] = t.cod
+		case cs.main.coc[c] != nil:
+			styles[c] = cs.main.coc[c]
+		default:
+			styles[c] = cs.main.cod
+		}
+		switch {
+		case t.qcc[c] != nil:
+			quants[c] = t.qcc[c]
+		case t.qcd != nil:
+			quants[c] = t.qcd
+		case cs.main.qcc[c] != nil:
+			quants[c] = cs.main.qcc[c]
+		default:
+			quants[c] = cs.main.qcd
+		}
+		if t.hasRoiShifts {
+			roi[c] = t.roiShift[c]
+		} else {
+			roi[c] = cs.main.roiShift[c]
+		}
+	}
+	progression := cs.main.progression
+	if t.progression != nil {
+		progression = t.progression
+	}
+	changes := cs.main.changes
+	if t.changes != nil {
+		changes = t.changes
+	}
+	return progression, styles, quants, roi, changes
+}
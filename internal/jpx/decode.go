@@ -0,0 +1,656 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+)
+
+// Config describes the decoded image without decoding the image data.
+type Config struct {
+	Width  int
+	Height int
+	// ColorComponents is the number of the color channels (without the opacity channel).
+	ColorComponents int
+	// BitsPerComponent is the number of bits of the decoded samples. Components with
+	// a precision not supported by PDF (1, 2, 4, 8 or 16 bits) are scaled to 8 or 16 bits.
+	BitsPerComponent int
+	ColorSpace       ColorSpace
+	// ICCProfile is the embedded ICC profile if the ColorSpace is ColorSpaceICC.
+	ICCProfile []byte
+	// HasAlpha is set if the image has an opacity channel.
+	HasAlpha bool
+	// AlphaPremultiplied is set if the color samples are premultiplied by the opacity.
+	AlphaPremultiplied bool
+}
+
+// Image is the decoded JPEG 2000 image.
+type Image struct {
+	Config
+	// Data holds the interleaved color samples. Each row starts at a byte boundary,
+	// the same way as the sample data of the PDF image XObjects.
+	Data []byte
+	// Alpha holds the opacity samples stored the same way as the color samples
+	// or nil if the image has no opacity channel.
+	Alpha []byte
+}
+
+var jp2Signature = []byte{0, 0, 0, 0x0c, 'j', 'P', ' ', ' ', 0x0d, 0x0a, 0x87, 0x0a}
+
+// outputChannel maps the output channel to the codestream component.
+type outputChannel struct {
+	component int
+	// column is the palette column or -1 if the component samples are used directly.
+	column    int
+	precision int
+	signed    bool
+}
+
+// layout describes how the components are assembled into the output image.
+type layout struct {
+	cfg     Config
+	color   []outputChannel
+	alpha   *outputChannel
+	palette *palette
+	isYCC   bool
+}
+
+// DecodeConfig reads the image parameters from the JPEG 2000 'data' without decoding it.
+func DecodeConfig(data []byte) (Config, error) {
+	cs, ff, err := parse(data, true)
+	if err != nil {
+		return Config{}, err
+	}
+	l, err := newLayout(cs, ff)
+	if err != nil {
+		return Config{}, err
+	}
+	return l.cfg, nil
+}
+
+// Decode decodes the JPEG 2000 'data' which is either a raw codestream or a JP2 file.
+func Decode(data []byte) (*Image, error) {
+	cs, ff, err := parse(data, false)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newLayout(cs, ff)
+	if err != nil {
+		return nil, err
+	}
+	planes, err := cs.decodeTiles()
+	if err != nil {
+		return nil, err
+	}
+	return l.assemble(cs, planes)
+}
+
+// parse parses the file format boxes (if any) and the codestream.
+func parse(data []byte, headerOnly bool) (*codestream, *fileFormat, error) {
+	var ff *fileFormat
+	codestreamData := data
+	if len(data) >= len(jp2Signature) && bytes.Equal(data[:len(jp2Signature)], jp2Signature) {
+		var err error
+		ff, codestreamData, err = parseFileFormat(data)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if len(data) < 2 || binary.BigEndian.Uint16(data) != markerSOC {
+		return nil, nil, errors.New("jpx: not a JPEG 2000 codestream or JP2 file")
+	}
+	cs, err := parseCodestream(codestreamData, headerOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cs, ff, nil
+}
+
+// newLayout determines the color and opacity channels of the output image.
+func newLayout(cs *codestream, ff *fileFormat) (*layout, error) {
+	comps := cs.size.components
+	l := &layout{}
+	var channels []outputChannel
+	if ff != nil && ff.palette != nil {
+		l.palette = ff.palette
+		mapping := ff.channelMap
+		if len(mapping) == 0 {
+			// Missing component mapping - map the first component through all columns.
+			for i := range ff.palette.depths {
+				mapping = append(mapping, channelMapping{component: 0, column: i})
+			}
+		}
+		for _, m := range mapping {
+			if m.component >= len(comps) || m.column >= len(ff.palette.depths) {
+				return nil, errors.New("jpx: invalid component mapping")
+			}
+			ch := outputChannel{component: m.component, column: m.column}
+			if m.column >= 0 {
+				ch.precision = ff.palette.depths[m.column]
+				ch.signed = ff.palette.signed[m.column]
+			} else {
+				ch.precision = comps[m.component].precision
+				ch.signed = comps[m.component].signed
+			}
+			channels = append(channels, ch)
+		}
+	} else {
+		for i, c := range comps {
+			channels = append(channels, outputChannel{
+				component: i,
+				column:    -1,
+				precision: c.precision,
+				signed:    c.signed,
+			})
+		}
+	}
+
+	colorSpace := ColorSpaceUnknown
+	if ff != nil {
+		colorSpace = ff.colorSpace
+		l.isYCC = ff.isYCC
+		l.cfg.ICCProfile = ff.iccProfile
+	}
+
+	if ff != nil && len(ff.channels) > 0 {
+		// The channel definitions specify the color and the opacity channels.
+		type assoc struct {
+			ch    outputChannel
+			order int
+		}
+		var colors []assoc
+		for i, def := range ff.channels {
+			if def.index >= len(channels) {
+				continue
+			}
+			ch := channels[def.index]
+			switch def.typ {
+			case channelColor:
+				order := def.association
+				if order == 0 || order == 0xffff {
+					order = 0x10000 + i
+				}
+				colors = append(colors, assoc{ch: ch, order: order})
+			case channelOpacity, channelPremultipliedAlpha:
+				if l.alpha == nil {
+					alpha := ch
+					l.alpha = &alpha
+					l.cfg.AlphaPremultiplied = def.typ == channelPremultipliedAlpha
+				}
+			}
+		}
+		for i := 1; i < len(colors); i++ {
+			for j := i; j > 0 && colors[j].order < colors[j-1].order; j-- {
+				colors[j], colors[j-1] = colors[j-1], colors[j]
+			}
+		}
+		for _, c := range colors {
+			l.color = append(l.color, c.ch)
+		}
+	} else {
+		numColors := len(channels)
+		switch colorSpace {
+		case ColorSpaceGray:
+			numColors = 1
+		case ColorSpaceRGB:
+			numColors = 3
+		case ColorSpaceCMYK:
+			numColors = 4
+		case ColorSpaceICC:
+			numColors = iccComponents(l.cfg.ICCProfile, len(channels))
+		default:
+			if len(channels) == 2 {
+				numColors = 1
+			}
+		}
+		if numColors > len(channels) {
+			numColors = len(channels)
+		}
+		l.color = channels[:numColors]
+		if len(channels) > numColors {
+			alpha := channels[numColors]
+			l.alpha = &alpha
+		}
+	}
+	if len(l.color) == 0 {
+		return nil, errors.New("jpx: no color channels")
+	}
+
+	if colorSpace == ColorSpaceUnknown ||
+		(colorSpace == ColorSpaceGray && len(l.color) != 1) ||
+		(colorSpace == ColorSpaceRGB && len(l.color) != 3) ||
+		(colorSpace == ColorSpaceCMYK && len(l.color) != 4) {
+		switch len(l.color) {
+		case 1:
+			colorSpace = ColorSpaceGray
+		case 3:
+			colorSpace = ColorSpaceRGB
+		case 4:
+			colorSpace = ColorSpaceCMYK
+		default:
+			colorSpace = ColorSpaceUnknown
+		}
+	}
+	if colorSpace != ColorSpaceRGB || len(l.color) != 3 {
+		l.isYCC = false
+	}
+
+	// Determine the output bits per component.
+	all := append([]outputChannel{}, l.color...)
+	if l.alpha != nil {
+		all = append(all, *l.alpha)
+	}
+	bpc := all[0].precision
+	maxPrecision := 0
+	for _, ch := range all {
+		if ch.precision != bpc {
+			bpc = 0
+		}
+		maxPrecision = max(maxPrecision, ch.precision)
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		if maxPrecision <= 8 {
+			bpc = 8
+		} else {
+			bpc = 16
+		}
+	}
+	if l.isYCC && bpc < 8 {
+		bpc = 8
+	}
+
+	l.cfg.Width = cs.size.x1 - cs.size.x0
+	l.cfg.Height = cs.size.y1 - cs.size.y0
+	l.cfg.ColorComponents = len(l.color)
+	l.cfg.BitsPerComponent = bpc
+	l.cfg.ColorSpace = colorSpace
+	l.cfg.HasAlpha = l.alpha != nil
+	if colorSpace != ColorSpaceICC {
+		l.cfg.ICCProfile = nil
+	}
+	return l, nil
+}
+
+// iccComponents returns the number of components of the ICC profile color space
+// or 'def' if it is not recognized.
+func iccComponents(profile []byte, def int) int {
+	if len(profile) < 20 {
+		return def
+	}
+	switch string(profile[16:20]) {
+	case "GRAY":
+		return 1
+	case "RGB ", "Lab ", "YCbr", "XYZ ":
+		return 3
+	case "CMYK":
+		return 4
+	}
+	return def
+}
+
+// componentPlane holds the reconstructed samples of a single image component.
+type componentPlane struct {
+	x0, y0 int
+	width  int
+	height int
+	data   []int32
+}
+
+// decodeTiles decodes all the tiles and returns the reconstructed image components.
+func (cs *codestream) decodeTiles() ([]*componentPlane, error) {
+	s := &cs.size
+	planes := make([]*componentPlane, len(s.components))
+	for i, c := range s.components {
+		p := &componentPlane{
+			x0: ceilDiv(s.x0, c.dx),
+			y0: ceilDiv(s.y0, c.dy),
+		}
+		p.width = ceilDiv(s.x1, c.dx) - p.x0
+		p.height = ceilDiv(s.y1, c.dy) - p.y0
+		if p.width*p.height > 1<<28 {
+			return nil, errors.New("jpx: image too large")
+		}
+		p.data = make([]int32, p.width*p.height)
+		planes[i] = p
+	}
+	dec := &codeBlockDecoder{}
+	for _, td := range cs.tiles {
+		if td == nil {
+			continue
+		}
+		t, err := cs.newTile(td)
+		if err != nil {
+			return nil, err
+		}
+		pr := &packetReader{
+			body: &bitReader{data: td.data},
+			sop:  t.progression.sop,
+			eph:  t.progression.eph,
+		}
+		pr.header = pr.body
+		if td.hasPPM {
+			pr.header = &bitReader{data: td.packedHeaders}
+		}
+		err = t.forEachPacket(func(layer, res, comp, precinct int) error {
+			return t.readPacket(pr, layer, res, comp, precinct)
+		})
+		if err != nil {
+			// Truncated data is common - decode the available packets.
+			common.Log.Debug("jpx: tile %d: %v", t.index, err)
+		}
+		if err = t.reconstruct(dec, planes); err != nil {
+			return nil, err
+		}
+	}
+	return planes, nil
+}
+
+// reconstruct decodes the code-blocks of the tile, performs the inverse wavelet and
+// component transformations and stores the samples in the component 'planes'.
+func (t *tile) reconstruct(dec *codeBlockDecoder, planes []*componentPlane) error {
+	samples := make([][]float32, len(t.components))
+	for c, tc := range t.components {
+		if err := tc.decodeCodeBlocks(dec); err != nil {
+			return err
+		}
+		samples[c] = tc.inverseTransform()
+	}
+
+	if t.progression.mct && len(t.components) >= 3 {
+		c0, c1, c2 := t.components[0], t.components[1], t.components[2]
+		sameSize := c0.x1-c0.x0 == c1.x1-c1.x0 && c0.x1-c0.x0 == c2.x1-c2.x0 &&
+			c0.y1-c0.y0 == c1.y1-c1.y0 && c0.y1-c0.y0 == c2.y1-c2.y0
+		if !sameSize {
+			return errors.New("jpx: component transform requires the components of equal size")
+		}
+		y0, y1, y2 := samples[0], samples[1], samples[2]
+		if c0.style.reversible {
+			for i := range y0 {
+				g := y0[i] - float32(math.Floor(float64(y1[i]+y2[i])/4))
+				y0[i], y1[i], y2[i] = y2[i]+g, g, y1[i]+g
+			}
+		} else {
+			for i := range y0 {
+				y, cb, cr := y0[i], y1[i], y2[i]
+				y0[i] = y + 1.402*cr
+				y1[i] = y - 0.34413*cb - 0.71414*cr
+				y2[i] = y + 1.772*cb
+			}
+		}
+	}
+
+	for c, tc := range t.components {
+		p := planes[c]
+		prec := uint(tc.precision)
+		shift := float32(0)
+		lo, hi := float32(-(int64(1) << (prec - 1))), float32(int64(1)<<(prec-1)-1)
+		if !tc.signed {
+			shift = float32(int64(1) << (prec - 1))
+			lo, hi = 0, float32(int64(1)<<prec-1)
+		}
+		w := tc.x1 - tc.x0
+		buf := samples[c]
+		for y := tc.y0; y < tc.y1; y++ {
+			py := y - p.y0
+			if py < 0 || py >= p.height {
+				continue
+			}
+			for x := tc.x0; x < tc.x1; x++ {
+				px := x - p.x0
+				if px < 0 || px >= p.width {
+					continue
+				}
+				v := buf[(y-tc.y0)*w+x-tc.x0] + shift
+				if !tc.style.reversible {
+					v = float32(math.Floor(float64(v) + 0.5))
+				}
+				if v < lo {
+					v = lo
+				} else if v > hi {
+					v = hi
+				}
+				p.data[py*p.width+px] = int32(v)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeCodeBlocks decodes all code-blocks of the tile component and stores the
+// dequantized coefficients in the subbands.
+func (tc *tileComponent) decodeCodeBlocks(dec *codeBlockDecoder) error {
+	for _, res := range tc.resolutions {
+		for _, b := range res.bands {
+			bw := b.x1 - b.x0
+			bh := b.y1 - b.y0
+			if bw <= 0 || bh <= 0 {
+				b.coefficients = nil
+				continue
+			}
+			b.coefficients = make([]float32, bw*bh)
+			for _, pb := range b.precincts {
+				for _, cb := range pb.blocks {
+					if cb.numPasses == 0 {
+						continue
+					}
+					w, h := cb.x1-cb.x0, cb.y1-cb.y0
+					dec.init(w, h, b.kind, tc.style.blockStyle)
+					if err := dec.decode(cb, b.numBitplanes); err != nil {
+						return err
+					}
+					tc.dequantize(b, cb, dec)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dequantize stores the coefficients of the decoded code-block in the subband.
+func (tc *tileComponent) dequantize(b *subband, cb *codeBlock, dec *codeBlockDecoder) {
+	bw := b.x1 - b.x0
+	roi := uint(tc.roiShift)
+	for y := 0; y < dec.height; y++ {
+		row := (cb.y0-b.y0+y)*bw + cb.x0 - b.x0
+		for x := 0; x < dec.width; x++ {
+			v := dec.coefficient(x, y)
+			if v == 0 {
+				continue
+			}
+			negative := v < 0
+			if negative {
+				v = -v
+			}
+			if roi > 0 && v>>1 >= 1<<roi {
+				v >>= roi
+			}
+			var f float32
+			if tc.style.reversible {
+				f = float32(v >> 1)
+			} else {
+				f = float32(float64(v) / 2 * b.stepSize)
+			}
+			if negative {
+				f = -f
+			}
+			b.coefficients[row+x] = f
+		}
+	}
+}
+
+// assemble builds the output image from the reconstructed component planes.
+func (l *layout) assemble(cs *codestream, planes []*componentPlane) (*Image, error) {
+	img := &Image{Config: l.cfg}
+	width, height := l.cfg.Width, l.cfg.Height
+	bpc := l.cfg.BitsPerComponent
+	s := &cs.size
+
+	sample := func(ch outputChannel, x, y int) int64 {
+		comp := s.components[ch.component]
+		p := planes[ch.component]
+		px := clamp((s.x0+x)/comp.dx-p.x0, 0, p.width-1)
+		py := clamp((s.y0+y)/comp.dy-p.y0, 0, p.height-1)
+		v := int64(p.data[py*p.width+px])
+		if ch.column >= 0 {
+			if comp.signed {
+				v += 1 << uint(comp.precision-1)
+			}
+			idx := clamp(int(v), 0, len(l.palette.entries)-1)
+			v = int64(l.palette.entries[idx][ch.column])
+		}
+		if ch.signed {
+			v += 1 << uint(ch.precision-1)
+		}
+		return v
+	}
+	rescale := func(v int64, precision int) int64 {
+		if precision == bpc {
+			return v
+		}
+		maxIn := int64(1)<<uint(precision) - 1
+		maxOut := int64(1)<<uint(bpc) - 1
+		return (v*maxOut + maxIn/2) / maxIn
+	}
+
+	color, err := newSampleWriter(width, height, len(l.color), bpc)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]int64, len(l.color))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for i, ch := range l.color {
+				values[i] = sample(ch, x, y)
+			}
+			if l.isYCC {
+				l.yccToRGB(values)
+				for i := range values {
+					values[i] = rescale(values[i], l.color[0].precision)
+				}
+			} else {
+				for i, ch := range l.color {
+					values[i] = rescale(values[i], ch.precision)
+				}
+			}
+			for _, v := range values {
+				color.write(v)
+			}
+		}
+		color.endRow()
+	}
+	img.Data = color.data
+
+	if l.alpha != nil {
+		alpha, err := newSampleWriter(width, height, 1, bpc)
+		if err != nil {
+			return nil, err
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				alpha.write(rescale(sample(*l.alpha, x, y), l.alpha.precision))
+			}
+			alpha.endRow()
+		}
+		img.Alpha = alpha.data
+	}
+	return img, nil
+}
+
+// yccToRGB converts the YCbCr 'values' to RGB in place.
+func (l *layout) yccToRGB(values []int64) {
+	prec := l.color[0].precision
+	half := float64(int64(1) << uint(prec-1))
+	maxVal := float64(int64(1)<<uint(prec) - 1)
+	y := float64(values[0])
+	cb := float64(values[1]) - half
+	cr := float64(values[2]) - half
+	rgb := [3]float64{
+		y + 1.402*cr,
+		y - 0.344136*cb - 0.714136*cr,
+		y + 1.772*cb,
+	}
+	for i, v := range rgb {
+		v = math.Floor(v + 0.5)
+		if v < 0 {
+			v = 0
+		} else if v > maxVal {
+			v = maxVal
+		}
+		values[i] = int64(v)
+	}
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// sampleWriter packs the samples into rows starting at a byte boundary.
+type sampleWriter struct {
+	data []byte
+	bpc  int
+	pos  int
+	acc  uint32
+	bits int
+}
+
+// newSampleWriter returns a writer of the samples of an image of the given size. It returns an
+// error if the size of the samples overflows or exceeds the limit of the decoded images.
+func newSampleWriter(width, height, components, bpc int) (*sampleWriter, error) {
+	if width <= 0 || height <= 0 || components <= 0 || components > 16384 || bpc <= 0 || bpc > 16 {
+		return nil, errors.New("jpx: invalid output image size")
+	}
+	// None of the products overflow as the factors are limited.
+	if width > maxImageSamples || height > maxImageSamples ||
+		uint64(width)*uint64(height) > maxImageSamples {
+		return nil, errors.New("jpx: image too large")
+	}
+	rowSize := (uint64(width)*uint64(components)*uint64(bpc) + 7) / 8
+	if size := rowSize * uint64(height); size > maxImageSamples*2 {
+		return nil, errors.New("jpx: image too large")
+	}
+	return &sampleWriter{data: make([]byte, int(rowSize)*height), bpc: bpc}, nil
+}
+
+func (w *sampleWriter) write(v int64) {
+	switch w.bpc {
+	case 8:
+		w.data[w.pos] = byte(v)
+		w.pos++
+	case 16:
+		w.data[w.pos] = byte(v >> 8)
+		w.data[w.pos+1] = byte(v)
+		w.pos += 2
+	default:
+		w.acc = w.acc<<uint(w.bpc) | uint32(v)&(1<<uint(w.bpc)-1)
+		w.bits += w.bpc
+		if w.bits == 8 {
+			w.data[w.pos] = byte(w.acc)
+			w.pos++
+			w.acc, w.bits = 0, 0
+		}
+	}
+}
+
+// endRow pads the current row to the byte boundary.
+func (w *sampleWriter) endRow() {
+	if w.bits > 0 {
+		w.data[w.pos] = byte(w.acc << uint(8-w.bits))
+		w.pos++
+		w.acc, w.bits = 0, 0
+	}
+}
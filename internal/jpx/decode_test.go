@@ -0,0 +1,302 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testImage returns the encoder of the image with 'numComps' components of the given size
+// filled with a gradient and some noise.
+func testImage(width, height, numComps, precision int) *testEncoder {
+	rnd := rand.New(rand.NewSource(int64(width*height + numComps)))
+	maxValue := 1<<uint(precision) - 1
+	e := &testEncoder{
+		width:  width,
+		height: height,
+		tileW:  width,
+		tileH:  height,
+		levels: 3,
+		xcb:    4,
+		ycb:    4,
+		layers: 1,
+	}
+	for c := 0; c < numComps; c++ {
+		samples := make([]int32, width*height)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := (x*(c+1)*maxValue/width + y*maxValue/height) / 2
+				v += rnd.Intn(maxValue/8+1) - maxValue/16
+				samples[y*width+x] = int32(clamp(v, 0, maxValue))
+			}
+		}
+		e.comps = append(e.comps, testComponent{precision: precision, dx: 1, dy: 1, samples: samples})
+	}
+	return e
+}
+
+// interleave returns the expected 8-bit interleaved samples of the components.
+func (e *testEncoder) interleave(comps ...int) []byte {
+	var data []byte
+	for i := 0; i < e.width*e.height; i++ {
+		for _, c := range comps {
+			data = append(data, byte(e.comps[c].samples[i]))
+		}
+	}
+	return data
+}
+
+func TestDecodeReversible(t *testing.T) {
+	type testCase struct {
+		name  string
+		setup func(e *testEncoder)
+	}
+	testCases := []testCase{
+		{"default", func(e *testEncoder) {}},
+		{"offset", func(e *testEncoder) {
+			e.x0, e.y0 = 3, 1
+			e.tileW, e.tileH = e.width+3, e.height+1
+		}},
+		{"tiles", func(e *testEncoder) {
+			e.tileW, e.tileH = 16, 8
+		}},
+		{"precincts RPCL", func(e *testEncoder) {
+			e.precinct = 4
+			e.order = progressionRPCL
+		}},
+		{"precincts PCRL", func(e *testEncoder) {
+			e.precinct = 3
+			e.order = progressionPCRL
+			e.xcb, e.ycb = 2, 2
+		}},
+		{"CPRL with SOP and EPH", func(e *testEncoder) {
+			e.order = progressionCPRL
+			e.precinct = 4
+			e.sop, e.eph = true, true
+		}},
+		{"layers", func(e *testEncoder) {
+			e.layers = 3
+			e.style = cbStyleTermAll
+			e.order = progressionRLCP
+		}},
+		{"packed headers", func(e *testEncoder) {
+			e.ppt = true
+			e.tileW, e.tileH = 20, 20
+		}},
+		{"bypass", func(e *testEncoder) {
+			e.style = cbStyleBypass | cbStyleReset | cbStyleCausal | cbStyleSegmentation
+		}},
+		{"no decomposition", func(e *testEncoder) {
+			e.levels = 0
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := testImage(37, 23, 1, 8)
+			tc.setup(e)
+			img, err := Decode(e.encode(t))
+			require.NoError(t, err)
+			require.Equal(t, 37, img.Width)
+			require.Equal(t, 23, img.Height)
+			require.Equal(t, 1, img.ColorComponents)
+			require.Equal(t, 8, img.BitsPerComponent)
+			require.Equal(t, ColorSpaceGray, img.ColorSpace)
+			require.Nil(t, img.Alpha)
+			require.Equal(t, e.interleave(0), img.Data)
+		})
+	}
+}
+
+func TestDecodeRGB(t *testing.T) {
+	e := testImage(29, 17, 3, 8)
+	e.mct = true
+	e.tileW, e.tileH = 16, 16
+	e.layers = 2
+	e.style = cbStyleTermAll
+
+	img, err := Decode(e.encode(t))
+	require.NoError(t, err)
+	require.Equal(t, 3, img.ColorComponents)
+	require.Equal(t, ColorSpaceRGB, img.ColorSpace)
+	require.Equal(t, e.interleave(0, 1, 2), img.Data)
+}
+
+func TestDecodeIrreversible(t *testing.T) {
+	e := testImage(33, 21, 3, 8)
+	e.mct = true
+	e.irreversible = true
+
+	img, err := Decode(e.encode(t))
+	require.NoError(t, err)
+	expected := e.interleave(0, 1, 2)
+	require.Len(t, img.Data, len(expected))
+	// The quantization errors are amplified by the inverse component transform.
+	sum := 0.0
+	for i := range expected {
+		require.InDelta(t, expected[i], img.Data[i], 5, "sample %d", i)
+		sum += math.Abs(float64(expected[i]) - float64(img.Data[i]))
+	}
+	require.True(t, sum/float64(len(expected)) < 1)
+}
+
+func TestDecodeBitDepths(t *testing.T) {
+	t.Run("1 bit", func(t *testing.T) {
+		e := testImage(10, 3, 1, 1)
+		img, err := Decode(e.encode(t))
+		require.NoError(t, err)
+		require.Equal(t, 1, img.BitsPerComponent)
+		// The rows are padded to the byte boundary.
+		require.Len(t, img.Data, 2*3)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 10; x++ {
+				bit := img.Data[y*2+x/8] >> uint(7-x%8) & 1
+				require.Equal(t, e.comps[0].samples[y*10+x], int32(bit))
+			}
+		}
+	})
+	t.Run("12 bit", func(t *testing.T) {
+		e := testImage(9, 7, 1, 12)
+		img, err := Decode(e.encode(t))
+		require.NoError(t, err)
+		require.Equal(t, 16, img.BitsPerComponent)
+		require.Len(t, img.Data, 9*7*2)
+		for i, v := range e.comps[0].samples {
+			got := int(img.Data[2*i])<<8 | int(img.Data[2*i+1])
+			require.Equal(t, int(math.Round(float64(v)*65535/4095)), got)
+		}
+	})
+}
+
+func TestDecodeSubsampled(t *testing.T) {
+	e := testImage(20, 10, 3, 8)
+	// Subsample the chroma components twice horizontally.
+	for c := 1; c < 3; c++ {
+		comp := &e.comps[c]
+		comp.dx = 2
+		var samples []int32
+		for y := 0; y < e.height; y++ {
+			for x := 0; x < e.width; x += 2 {
+				samples = append(samples, comp.samples[y*e.width+x])
+			}
+		}
+		comp.samples = samples
+	}
+	img, err := Decode(e.encode(t))
+	require.NoError(t, err)
+	require.Equal(t, 3, img.ColorComponents)
+	for i := 0; i < e.width*e.height; i++ {
+		x, y := i%e.width, i/e.width
+		require.Equal(t, byte(e.comps[0].samples[i]), img.Data[3*i])
+		require.Equal(t, byte(e.comps[1].samples[y*e.width/2+x/2]), img.Data[3*i+1])
+		require.Equal(t, byte(e.comps[2].samples[y*e.width/2+x/2]), img.Data[3*i+2])
+	}
+}
+
+func TestDecodeJP2Alpha(t *testing.T) {
+	e := testImage(12, 8, 4, 8)
+	codestream := e.encode(t)
+
+	var ihdr, colr, cdef bytes.Buffer
+	putU32(&ihdr, e.height)
+	putU32(&ihdr, e.width)
+	putU16(&ihdr, 4)
+	ihdr.Write([]byte{7, 7, 0, 0})
+	colr.Write([]byte{1, 0, 0})
+	putU32(&colr, enumCSsRGB)
+	// The opacity is stored in the first component.
+	putU16(&cdef, 4)
+	for _, def := range [][3]int{{0, channelOpacity, 0}, {1, channelColor, 1}, {2, channelColor, 2}, {3, channelColor, 3}} {
+		putU16(&cdef, def[0])
+		putU16(&cdef, def[1])
+		putU16(&cdef, def[2])
+	}
+	data := wrapJP2(codestream,
+		makeBox(boxImageHeader, ihdr.Bytes()),
+		makeBox(boxColorSpec, colr.Bytes()),
+		makeBox(boxChannelDef, cdef.Bytes()))
+
+	cfg, err := DecodeConfig(data)
+	require.NoError(t, err)
+	require.Equal(t, Config{
+		Width:            12,
+		Height:           8,
+		ColorComponents:  3,
+		BitsPerComponent: 8,
+		ColorSpace:       ColorSpaceRGB,
+		HasAlpha:         true,
+	}, cfg)
+
+	img, err := Decode(data)
+	require.NoError(t, err)
+	require.Equal(t, cfg, img.Config)
+	require.Equal(t, e.interleave(1, 2, 3), img.Data)
+	require.Equal(t, e.interleave(0), img.Alpha)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode([]byte("not an image"))
+	require.Error(t, err)
+
+	e := testImage(8, 8, 1, 8)
+	data := e.encode(t)
+	_, err = DecodeConfig(data[:20])
+	require.Error(t, err)
+}
+
+func TestDecodeHugeSize(t *testing.T) {
+	data := testImage(8, 8, 1, 8).encode(t)
+	siz := bytes.Index(data, []byte{0xff, 0x51})
+	require.True(t, siz >= 0)
+
+	put32 := func(b []byte, offset int, v uint32) {
+		b[offset] = byte(v >> 24)
+		b[offset+1] = byte(v >> 16)
+		b[offset+2] = byte(v >> 8)
+		b[offset+3] = byte(v)
+	}
+
+	// Xsiz and Ysiz of a single tile image, and of an image with 65536x65536 tiles of the
+	// maximum size, are rejected before the samples are allocated.
+	for _, size := range []uint32{0x10000, 0xffffffff} {
+		huge := append([]byte{}, data...)
+		put32(huge, siz+6, size)  // Xsiz.
+		put32(huge, siz+10, size) // Ysiz.
+		put32(huge, siz+22, size) // XTsiz.
+		put32(huge, siz+26, size) // YTsiz.
+
+		_, err := DecodeConfig(huge)
+		require.Error(t, err)
+		_, err = Decode(huge)
+		require.Error(t, err)
+	}
+
+	// A single large component is rejected too.
+	huge := append([]byte{}, data...)
+	put32(huge, siz+6, 0x4000)
+	put32(huge, siz+10, 0x4001)
+	put32(huge, siz+22, 0x4000)
+	put32(huge, siz+26, 0x4001)
+	_, err := Decode(huge)
+	require.Error(t, err)
+}
+
+func TestNewSampleWriterLimits(t *testing.T) {
+	_, err := newSampleWriter(1<<20, 1<<20, 3, 8)
+	require.Error(t, err)
+	_, err = newSampleWriter(1<<62, 4, 3, 8)
+	require.Error(t, err)
+	_, err = newSampleWriter(0, 4, 3, 8)
+	require.Error(t, err)
+
+	w, err := newSampleWriter(10, 4, 3, 4)
+	require.NoError(t, err)
+	require.Len(t, w.data, 60)
+}
@@ -0,0 +1,12 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package jpx implements the JPEG 2000 (ITU-T T.800 | ISO/IEC 15444-1) image decoder
+// used by the JPXDecode filter. It reads both raw codestreams and codestreams wrapped
+// in the JP2 file format, and supports multiple tiles and tile-parts, all progression
+// orders (including POC changes), packed packet headers, the reversible 5/3 and the
+// irreversible 9/7 wavelet transforms, the multiple component transforms, palettes
+// and opacity (alpha) channels.
+package jpx
@@ -0,0 +1,148 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"math"
+)
+
+// Lifting coefficients of the irreversible 9/7 wavelet filter (Table F.4).
+const (
+	liftAlpha = -1.586134342059924
+	liftBeta  = -0.052980118572961
+	liftGamma = 0.882911075530934
+	liftDelta = 0.443506852043971
+	liftK     = 1.230174104914001
+)
+
+// inverseTransform performs the inverse discrete wavelet transformation of the tile
+// component (F.3) and returns its reconstructed samples.
+func (tc *tileComponent) inverseTransform() []float32 {
+	res0 := tc.resolutions[0]
+	a := res0.bands[0].coefficients
+	reversible := tc.style.reversible
+	var line, ext []float32
+	for r := 1; r < len(tc.resolutions); r++ {
+		prev := tc.resolutions[r-1]
+		res := tc.resolutions[r]
+		w, h := res.x1-res.x0, res.y1-res.y0
+		out := make([]float32, w*h)
+		if w == 0 || h == 0 {
+			a = out
+			continue
+		}
+
+		// Interleave the subbands (2D_INTERLEAVE).
+		pw := prev.x1 - prev.x0
+		for y := prev.y0; y < prev.y1; y++ {
+			for x := prev.x0; x < prev.x1; x++ {
+				out[(2*y-res.y0)*w+2*x-res.x0] = a[(y-prev.y0)*pw+x-prev.x0]
+			}
+		}
+		for _, b := range res.bands {
+			xo, yo := 0, 0
+			if b.kind == bandHL || b.kind == bandHH {
+				xo = 1
+			}
+			if b.kind == bandLH || b.kind == bandHH {
+				yo = 1
+			}
+			bw := b.x1 - b.x0
+			for y := b.y0; y < b.y1; y++ {
+				for x := b.x0; x < b.x1; x++ {
+					out[(2*y+yo-res.y0)*w+2*x+xo-res.x0] = b.coefficients[(y-b.y0)*bw+x-b.x0]
+				}
+			}
+		}
+
+		// Horizontal and vertical reconstruction (HOR_SR and VER_SR).
+		line = growFloats(line, max(w, h))
+		ext = growFloats(ext, max(w, h)+8)
+		for y := 0; y < h; y++ {
+			row := out[y*w : (y+1)*w]
+			inverse1D(row, res.x0, reversible, ext)
+		}
+		col := line[:h]
+		for x := 0; x < w; x++ {
+			for y := 0; y < h; y++ {
+				col[y] = out[y*w+x]
+			}
+			inverse1D(col, res.y0, reversible, ext)
+			for y := 0; y < h; y++ {
+				out[y*w+x] = col[y]
+			}
+		}
+		a = out
+	}
+	return a
+}
+
+func growFloats(s []float32, n int) []float32 {
+	if cap(s) < n {
+		return make([]float32, n)
+	}
+	return s[:n]
+}
+
+// inverse1D performs the 1D_SR procedure on the signal 'x' which starts at the index
+// 'i0'. The 'ext' is a working buffer with space for the extended signal.
+func inverse1D(x []float32, i0 int, reversible bool, ext []float32) {
+	n := len(x)
+	if n == 1 {
+		if i0&1 == 1 {
+			x[0] /= 2
+		}
+		return
+	}
+	pad := 2
+	if !reversible {
+		pad = 4
+	}
+	ext = ext[:n+2*pad]
+	// Periodic symmetric extension of the signal (1D_EXTR).
+	period := 2 * (n - 1)
+	for k := range ext {
+		i := (k - pad) % period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		ext[k] = x[i]
+	}
+	// Even absolute indices hold the low-pass coefficients. The padding is even, so the
+	// parity of 'i0+k' is the parity of the absolute index of ext[k].
+	last := len(ext) - 1
+	if reversible {
+		for k := 1 + (i0+1)&1; k < last; k += 2 {
+			ext[k] -= float32(math.Floor(float64(ext[k-1]+ext[k+1]+2) / 4))
+		}
+		for k := 1 + i0&1; k < last; k += 2 {
+			ext[k] += float32(math.Floor(float64(ext[k-1]+ext[k+1]) / 2))
+		}
+	} else {
+		even := (i0) & 1
+		for k := even; k <= last; k += 2 {
+			ext[k] *= liftK
+		}
+		for k := 1 - even; k <= last; k += 2 {
+			ext[k] *= 1 / liftK
+		}
+		lift := func(start int, coefficient float32) {
+			for k := start; k < last; k += 2 {
+				ext[k] -= coefficient * (ext[k-1] + ext[k+1])
+			}
+		}
+		first := 1 + (i0+1)&1 // The first even index in (0, last).
+		second := 1 + i0&1    // The first odd index in (0, last).
+		lift(first, liftDelta)
+		lift(second, liftGamma)
+		lift(first, liftBeta)
+		lift(second, liftAlpha)
+	}
+	copy(x, ext[pad:pad+n])
+}
@@ -0,0 +1,971 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// This file implements a minimal JPEG 2000 encoder used to produce the test codestreams
+// for the decoder.
+
+// testComponent is a component of the image to encode.
+type testComponent struct {
+	precision int
+	signed    bool
+	dx, dy    int
+	// samples of the component on its own grid starting at ceil(x0/dx), ceil(y0/dy).
+	samples []int32
+}
+
+// testEncoder holds the encoding parameters.
+type testEncoder struct {
+	width, height int
+	x0, y0        int
+	tileW, tileH  int
+	comps         []testComponent
+	levels        int
+	xcb, ycb      int
+	precinct      int // Precinct size exponent (both directions), 0 for the default.
+	order         int
+	layers        int
+	style         int
+	sop, eph, ppt bool
+	mct           bool
+	irreversible  bool
+}
+
+func (e *testEncoder) compSize(c int) (x0, y0, w, h int) {
+	comp := e.comps[c]
+	x0 = ceilDiv(e.x0, comp.dx)
+	y0 = ceilDiv(e.y0, comp.dy)
+	w = ceilDiv(e.x0+e.width, comp.dx) - x0
+	h = ceilDiv(e.y0+e.height, comp.dy) - y0
+	return x0, y0, w, h
+}
+
+func putU16(b *bytes.Buffer, v int) {
+	b.WriteByte(byte(v >> 8))
+	b.WriteByte(byte(v))
+}
+
+func putU32(b *bytes.Buffer, v int) {
+	putU16(b, v>>16)
+	putU16(b, v&0xffff)
+}
+
+func putSegment(b *bytes.Buffer, marker int, content []byte) {
+	putU16(b, marker)
+	putU16(b, len(content)+2)
+	b.Write(content)
+}
+
+// mainHeader writes the main header of the codestream.
+func (e *testEncoder) mainHeader() []byte {
+	var b, seg bytes.Buffer
+	putU16(&b, markerSOC)
+
+	putU16(&seg, 0)
+	putU32(&seg, e.x0+e.width)
+	putU32(&seg, e.y0+e.height)
+	putU32(&seg, e.x0)
+	putU32(&seg, e.y0)
+	putU32(&seg, e.tileW)
+	putU32(&seg, e.tileH)
+	putU32(&seg, 0)
+	putU32(&seg, 0)
+	putU16(&seg, len(e.comps))
+	for _, c := range e.comps {
+		ssiz := c.precision - 1
+		if c.signed {
+			ssiz |= 0x80
+		}
+		seg.WriteByte(byte(ssiz))
+		seg.WriteByte(byte(c.dx))
+		seg.WriteByte(byte(c.dy))
+	}
+	putSegment(&b, markerSIZ, seg.Bytes())
+
+	seg.Reset()
+	scod := 0
+	if e.precinct > 0 {
+		scod |= 1
+	}
+	if e.sop {
+		scod |= 2
+	}
+	if e.eph {
+		scod |= 4
+	}
+	seg.WriteByte(byte(scod))
+	seg.WriteByte(byte(e.order))
+	putU16(&seg, e.layers)
+	if e.mct {
+		seg.WriteByte(1)
+	} else {
+		seg.WriteByte(0)
+	}
+	seg.WriteByte(byte(e.levels))
+	seg.WriteByte(byte(e.xcb - 2))
+	seg.WriteByte(byte(e.ycb - 2))
+	seg.WriteByte(byte(e.style))
+	if e.irreversible {
+		seg.WriteByte(0)
+	} else {
+		seg.WriteByte(1)
+	}
+	if e.precinct > 0 {
+		for r := 0; r <= e.levels; r++ {
+			seg.WriteByte(byte(e.precinct | e.precinct<<4))
+		}
+	}
+	putSegment(&b, markerCOD, seg.Bytes())
+
+	// The quantization: guard bits 2, exponents with one extra bit for the component
+	// transform. The irreversible step sizes are 1.
+	seg.Reset()
+	prec := e.comps[0].precision
+	if e.irreversible {
+		seg.WriteByte(2<<5 | quantizationScalarExpounded)
+	} else {
+		seg.WriteByte(2<<5 | quantizationNone)
+	}
+	for i := 0; i < 3*e.levels+1; i++ {
+		gain := 0
+		if i > 0 {
+			gain = []int{1, 1, 2}[(i-1)%3]
+		}
+		if e.irreversible {
+			putU16(&seg, (prec+gain)<<11)
+		} else {
+			seg.WriteByte(byte((prec + gain + 1) << 3))
+		}
+	}
+	putSegment(&b, markerQCD, seg.Bytes())
+	return b.Bytes()
+}
+
+// encode returns the encoded codestream.
+func (e *testEncoder) encode(t *testing.T) []byte {
+	header := e.mainHeader()
+	cs, err := parseCodestream(append(append([]byte{}, header...), 0xff, 0x90, 0, 10), true)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	out.Write(header)
+	for index := 0; index < cs.size.numTilesX*cs.size.numTilesY; index++ {
+		tl, err := cs.newTile(&tileData{index: index, params: newCodingParams(len(e.comps))})
+		require.NoError(t, err)
+		e.encodeTile(t, tl)
+		headers, body := e.writePackets(t, tl)
+
+		var tileHeader bytes.Buffer
+		if e.ppt {
+			var seg bytes.Buffer
+			seg.WriteByte(0)
+			seg.Write(headers)
+			putSegment(&tileHeader, markerPPT, seg.Bytes())
+		}
+		putU16(&out, markerSOT)
+		putU16(&out, 10)
+		putU16(&out, index)
+		putU32(&out, 12+tileHeader.Len()+2+len(body))
+		out.WriteByte(0)
+		out.WriteByte(1)
+		out.Write(tileHeader.Bytes())
+		putU16(&out, markerSOD)
+		out.Write(body)
+	}
+	putU16(&out, markerEOC)
+	return out.Bytes()
+}
+
+// encodeTile transforms the tile samples and encodes its code-blocks.
+func (e *testEncoder) encodeTile(t *testing.T, tl *tile) {
+	samples := make([][]float64, len(tl.components))
+	for c, tc := range tl.components {
+		px0, py0, pw, _ := e.compSize(c)
+		w := tc.x1 - tc.x0
+		buf := make([]float64, w*(tc.y1-tc.y0))
+		for y := tc.y0; y < tc.y1; y++ {
+			for x := tc.x0; x < tc.x1; x++ {
+				v := float64(e.comps[c].samples[(y-py0)*pw+x-px0])
+				if !e.comps[c].signed {
+					v -= float64(int(1) << uint(e.comps[c].precision-1))
+				}
+				buf[(y-tc.y0)*w+x-tc.x0] = v
+			}
+		}
+		samples[c] = buf
+	}
+	if e.mct {
+		r, g, b := samples[0], samples[1], samples[2]
+		for i := range r {
+			if e.irreversible {
+				y := 0.299*r[i] + 0.587*g[i] + 0.114*b[i]
+				cb := -0.16875*r[i] - 0.33126*g[i] + 0.5*b[i]
+				cr := 0.5*r[i] - 0.41869*g[i] - 0.08131*b[i]
+				r[i], g[i], b[i] = y, cb, cr
+			} else {
+				y := math.Floor((r[i] + 2*g[i] + b[i]) / 4)
+				r[i], g[i], b[i] = y, b[i]-g[i], r[i]-g[i]
+			}
+		}
+	}
+	for c, tc := range tl.components {
+		e.forwardTransform(t, tc, samples[c])
+		for _, res := range tc.resolutions {
+			for _, b := range res.bands {
+				for _, pb := range b.precincts {
+					for _, cb := range pb.blocks {
+						e.encodeCodeBlock(t, b, cb)
+					}
+				}
+			}
+		}
+	}
+}
+
+// forwardTransform performs the forward wavelet transform and stores the (quantized)
+// coefficients in the subbands.
+func (e *testEncoder) forwardTransform(t *testing.T, tc *tileComponent, a []float64) {
+	for r := len(tc.resolutions) - 1; r > 0; r-- {
+		res := tc.resolutions[r]
+		w, h := res.x1-res.x0, res.y1-res.y0
+		// Vertical and horizontal analysis (VER_SD and HOR_SD).
+		col := make([]float64, h)
+		for x := 0; x < w; x++ {
+			for y := 0; y < h; y++ {
+				col[y] = a[y*w+x]
+			}
+			forward1D(col, res.y0, !e.irreversible)
+			for y := 0; y < h; y++ {
+				a[y*w+x] = col[y]
+			}
+		}
+		for y := 0; y < h; y++ {
+			forward1D(a[y*w:(y+1)*w], res.x0, !e.irreversible)
+		}
+		// Deinterleave.
+		prev := tc.resolutions[r-1]
+		pw, ph := prev.x1-prev.x0, prev.y1-prev.y0
+		ll := make([]float64, 0, pw*ph)
+		for y := res.y0; y < res.y1; y++ {
+			for x := res.x0; x < res.x1; x++ {
+				if x%2 == 0 && y%2 == 0 {
+					ll = append(ll, a[(y-res.y0)*w+x-res.x0])
+				}
+			}
+		}
+		require.Equal(t, pw*ph, len(ll))
+		for _, b := range res.bands {
+			xo, yo := 0, 0
+			if b.kind == bandHL || b.kind == bandHH {
+				xo = 1
+			}
+			if b.kind == bandLH || b.kind == bandHH {
+				yo = 1
+			}
+			var coefs []float64
+			for y := res.y0; y < res.y1; y++ {
+				for x := res.x0; x < res.x1; x++ {
+					if x%2 == xo && y%2 == yo {
+						coefs = append(coefs, a[(y-res.y0)*w+x-res.x0])
+					}
+				}
+			}
+			require.Equal(t, (b.x1-b.x0)*(b.y1-b.y0), len(coefs))
+			e.storeBand(b, coefs)
+		}
+		a = ll
+	}
+	e.storeBand(tc.resolutions[0].bands[0], a)
+}
+
+// storeBand quantizes the coefficients into the subband.
+func (e *testEncoder) storeBand(b *subband, coefs []float64) {
+	b.coefficients = make([]float32, len(coefs))
+	for i, v := range coefs {
+		if e.irreversible {
+			q := math.Floor(math.Abs(v) / b.stepSize)
+			if v < 0 {
+				q = -q
+			}
+			v = q
+		}
+		b.coefficients[i] = float32(v)
+	}
+}
+
+// forward1D performs the 1D_SD procedure.
+func forward1D(x []float64, i0 int, reversible bool) {
+	n := len(x)
+	if n == 1 {
+		if i0&1 == 1 {
+			x[0] *= 2
+		}
+		return
+	}
+	pad := 4
+	ext := make([]float64, n+2*pad)
+	period := 2 * (n - 1)
+	for k := range ext {
+		i := (k - pad) % period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		ext[k] = x[i]
+	}
+	isEven := func(k int) bool { return (i0+k-pad)%2 == 0 }
+	step := func(even bool, f func(k int)) {
+		for k := 1; k < len(ext)-1; k++ {
+			if isEven(k) == even {
+				f(k)
+			}
+		}
+	}
+	if reversible {
+		step(false, func(k int) { ext[k] -= math.Floor((ext[k-1] + ext[k+1]) / 2) })
+		step(true, func(k int) { ext[k] += math.Floor((ext[k-1] + ext[k+1] + 2) / 4) })
+	} else {
+		step(false, func(k int) { ext[k] += liftAlpha * (ext[k-1] + ext[k+1]) })
+		step(true, func(k int) { ext[k] += liftBeta * (ext[k-1] + ext[k+1]) })
+		step(false, func(k int) { ext[k] += liftGamma * (ext[k-1] + ext[k+1]) })
+		step(true, func(k int) { ext[k] += liftDelta * (ext[k-1] + ext[k+1]) })
+		for k := range ext {
+			if isEven(k) {
+				ext[k] /= liftK
+			} else {
+				ext[k] *= liftK
+			}
+		}
+	}
+	copy(x, ext[pad:pad+n])
+}
+
+// encodedSegment is a terminated codeword segment produced by the tier-1 encoder.
+type encodedSegment struct {
+	data   []byte
+	passes int
+}
+
+// blockCode holds the encoded code-block.
+type blockCode struct {
+	zeroBitplanes int
+	segments      []encodedSegment
+	passLayer     []int // The layer of each pass.
+}
+
+var blockCodes = map[*codeBlock]*blockCode{}
+
+// encodeCodeBlock performs the tier-1 encoding of the code-block.
+func (e *testEncoder) encodeCodeBlock(t *testing.T, b *subband, cb *codeBlock) {
+	w, h := cb.x1-cb.x0, cb.y1-cb.y0
+	bw := b.x1 - b.x0
+	values := make([]int32, w*h)
+	maxMag := int32(0)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := int32(b.coefficients[(cb.y0-b.y0+y)*bw+cb.x0-b.x0+x])
+			values[y*w+x] = v
+			if v < 0 {
+				v = -v
+			}
+			if v > maxMag {
+				maxMag = v
+			}
+		}
+	}
+	code := &blockCode{}
+	blockCodes[cb] = code
+	if maxMag == 0 {
+		return
+	}
+	top := 0
+	for maxMag>>uint(top+1) != 0 {
+		top++
+	}
+	require.True(t, top < b.numBitplanes, "too many bitplanes")
+	code.zeroBitplanes = b.numBitplanes - 1 - top
+	numPasses := 3*top + 1
+
+	enc := &t1Encoder{values: values}
+	enc.d.init(w, h, b.kind, e.style)
+	var seg *encodedSegment
+	for pass := 0; pass < numPasses; pass++ {
+		if seg == nil {
+			seg = &encodedSegment{}
+			enc.d.useRaw = isRawPass(pass, e.style)
+			if enc.d.useRaw {
+				enc.raw = rawEncoder{capacity: 8}
+			} else {
+				enc.mq.init()
+			}
+		}
+		bitplane := top - (pass+2)/3
+		switch passType(pass) {
+		case passSignificance:
+			enc.significancePass(bitplane)
+		case passRefinement:
+			enc.refinementPass(bitplane)
+		case passCleanup:
+			enc.cleanupPass(bitplane)
+		}
+		if e.style&cbStyleReset != 0 {
+			enc.d.resetContexts()
+		}
+		seg.passes++
+		if isTerminatingPass(pass, e.style) || pass == numPasses-1 {
+			if enc.d.useRaw {
+				seg.data = enc.raw.flush()
+			} else {
+				seg.data = enc.mq.flush()
+			}
+			code.segments = append(code.segments, *seg)
+			seg = nil
+		}
+	}
+	for pass := 0; pass < numPasses; pass++ {
+		code.passLayer = append(code.passLayer, pass*e.layers/numPasses)
+	}
+}
+
+// t1Encoder encodes the code-block coding passes. It uses the decoder state for the
+// context modeling.
+type t1Encoder struct {
+	d      codeBlockDecoder
+	values []int32
+	mq     mqEncoder
+	raw    rawEncoder
+}
+
+func (e *t1Encoder) bitAt(x, y, bitplane int) int {
+	v := e.values[y*e.d.width+x]
+	if v < 0 {
+		v = -v
+	}
+	return int(v>>uint(bitplane)) & 1
+}
+
+func (e *t1Encoder) encodeBit(bit, cx int) {
+	if e.d.useRaw {
+		e.raw.put(bit)
+	} else {
+		e.mq.encode(bit, &e.d.contexts[cx])
+	}
+}
+
+func (e *t1Encoder) encodeSign(x, y, i int, stripeEnd bool) {
+	negative := 0
+	if e.values[y*e.d.width+x] < 0 {
+		negative = 1
+	}
+	if e.d.useRaw {
+		e.raw.put(negative)
+	} else {
+		cx, xor := e.d.signContext(i, stripeEnd)
+		e.mq.encode(negative^xor, &e.d.contexts[cx])
+	}
+	e.d.setSignificant(x, y, i, 0, negative == 1)
+}
+
+func (e *t1Encoder) significancePass(bitplane int) {
+	d := &e.d
+	for y0 := 0; y0 < d.height; y0 += 4 {
+		for x := 0; x < d.width; x++ {
+			for y := y0; y < min(y0+4, d.height); y++ {
+				i := (y+1)*d.stride + x + 1
+				if d.flags[i]&flagSignificant != 0 {
+					continue
+				}
+				cx := d.zeroContext(i, y == y0+3)
+				if cx == 0 {
+					continue
+				}
+				d.flags[i] |= flagVisited
+				bit := e.bitAt(x, y, bitplane)
+				e.encodeBit(bit, cx)
+				if bit == 1 {
+					e.encodeSign(x, y, i, y == y0+3)
+				}
+			}
+		}
+	}
+}
+
+func (e *t1Encoder) refinementPass(bitplane int) {
+	d := &e.d
+	for y0 := 0; y0 < d.height; y0 += 4 {
+		for x := 0; x < d.width; x++ {
+			for y := y0; y < min(y0+4, d.height); y++ {
+				i := (y+1)*d.stride + x + 1
+				f := d.flags[i]
+				if f&flagSignificant == 0 || f&flagVisited != 0 {
+					continue
+				}
+				cx := ctxRefinement + 2
+				if f&flagRefined == 0 {
+					cx = ctxRefinement
+					if h, v, dg := d.neighbors(i, y == y0+3); h+v+dg > 0 {
+						cx++
+					}
+				}
+				e.encodeBit(e.bitAt(x, y, bitplane), cx)
+				d.flags[i] |= flagRefined
+			}
+		}
+	}
+}
+
+func (e *t1Encoder) cleanupPass(bitplane int) {
+	d := &e.d
+	for y0 := 0; y0 < d.height; y0 += 4 {
+		y1 := min(y0+4, d.height)
+		for x := 0; x < d.width; x++ {
+			y := y0
+			if y1-y0 == 4 && d.canUseRunLength(x, y0) {
+				r := 0
+				for r < 4 && e.bitAt(x, y0+r, bitplane) == 0 {
+					r++
+				}
+				if r == 4 {
+					e.mq.encode(0, &d.contexts[ctxRunLength])
+					continue
+				}
+				e.mq.encode(1, &d.contexts[ctxRunLength])
+				e.mq.encode(r>>1, &d.contexts[ctxUniform])
+				e.mq.encode(r&1, &d.contexts[ctxUniform])
+				y = y0 + r
+				e.encodeSign(x, y, (y+1)*d.stride+x+1, y == y0+3)
+				y++
+			}
+			for ; y < y1; y++ {
+				i := (y+1)*d.stride + x + 1
+				if d.flags[i]&(flagSignificant|flagVisited) != 0 {
+					continue
+				}
+				bit := e.bitAt(x, y, bitplane)
+				e.mq.encode(bit, &d.contexts[d.zeroContext(i, y == y0+3)])
+				if bit == 1 {
+					e.encodeSign(x, y, i, y == y0+3)
+				}
+			}
+		}
+	}
+	if d.style&cbStyleSegmentation != 0 {
+		for _, bit := range []int{1, 0, 1, 0} {
+			e.mq.encode(bit, &d.contexts[ctxUniform])
+		}
+	}
+	for i := range d.flags {
+		d.flags[i] &^= flagVisited
+	}
+}
+
+// mqEncoder is the MQ arithmetic encoder (C.2).
+type mqEncoder struct {
+	out []byte
+	bp  int
+	a   uint32
+	c   uint64
+	ct  int
+}
+
+func (e *mqEncoder) init() {
+	e.out = []byte{0}
+	e.bp = 0
+	e.a = 0x8000
+	e.c = 0
+	e.ct = 12
+}
+
+func (e *mqEncoder) encode(bit int, cx *mqContext) {
+	q := qeTable[cx.index]
+	e.a -= q.qe
+	if bit == int(cx.mps) {
+		if e.a&0x8000 != 0 {
+			e.c += uint64(q.qe)
+			return
+		}
+		if e.a < q.qe {
+			e.a = q.qe
+		} else {
+			e.c += uint64(q.qe)
+		}
+		cx.index = q.nmps
+	} else {
+		if e.a < q.qe {
+			e.c += uint64(q.qe)
+		} else {
+			e.a = q.qe
+		}
+		if q.switchMPS == 1 {
+			cx.mps = 1 - cx.mps
+		}
+		cx.index = q.nlps
+	}
+	for {
+		e.a <<= 1
+		e.c <<= 1
+		e.ct--
+		if e.ct == 0 {
+			e.byteOut()
+		}
+		if e.a&0x8000 != 0 {
+			break
+		}
+	}
+}
+
+func (e *mqEncoder) put(b byte) {
+	if e.bp == len(e.out) {
+		e.out = append(e.out, b)
+	} else {
+		e.out[e.bp] = b
+	}
+}
+
+func (e *mqEncoder) byteOut() {
+	if e.out[e.bp] == 0xff {
+		e.bp++
+		e.put(byte(e.c >> 20))
+		e.c &= 0xfffff
+		e.ct = 7
+		return
+	}
+	if e.c < 0x8000000 {
+		e.bp++
+		e.put(byte(e.c >> 19))
+		e.c &= 0x7ffff
+		e.ct = 8
+		return
+	}
+	e.out[e.bp]++
+	if e.out[e.bp] == 0xff {
+		e.c &= 0x7ffffff
+		e.bp++
+		e.put(byte(e.c >> 20))
+		e.c &= 0xfffff
+		e.ct = 7
+		return
+	}
+	e.bp++
+	e.put(byte(e.c >> 19))
+	e.c &= 0x7ffff
+	e.ct = 8
+}
+
+func (e *mqEncoder) flush() []byte {
+	tempc := e.c + uint64(e.a)
+	e.c |= 0xffff
+	if e.c >= tempc {
+		e.c -= 0x8000
+	}
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	e.c <<= uint(e.ct)
+	e.byteOut()
+	data := e.out[1 : e.bp+1]
+	if len(data) > 0 && data[len(data)-1] == 0xff {
+		data = data[:len(data)-1]
+	}
+	return append([]byte{}, data...)
+}
+
+// rawEncoder writes the raw coding passes.
+type rawEncoder struct {
+	out      []byte
+	cur      int
+	bits     int
+	capacity int
+}
+
+func (e *rawEncoder) put(bit int) {
+	e.cur = e.cur<<1 | bit
+	e.bits++
+	if e.bits == e.capacity {
+		e.out = append(e.out, byte(e.cur))
+		e.capacity = 8
+		if e.cur == 0xff {
+			e.capacity = 7
+		}
+		e.cur, e.bits = 0, 0
+	}
+}
+
+func (e *rawEncoder) flush() []byte {
+	for e.bits > 0 {
+		e.put(0)
+	}
+	return e.out
+}
+
+// headerWriter writes the packet header bits with the bit stuffing.
+type headerWriter struct {
+	out      []byte
+	cur      int
+	bits     int
+	capacity int
+}
+
+func (w *headerWriter) bit(b int) {
+	if w.capacity == 0 {
+		w.capacity = 8
+	}
+	w.cur = w.cur<<1 | b
+	w.bits++
+	if w.bits == w.capacity {
+		w.out = append(w.out, byte(w.cur))
+		w.capacity = 8
+		if w.cur == 0xff {
+			w.capacity = 7
+		}
+		w.cur, w.bits = 0, 0
+	}
+}
+
+func (w *headerWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.bit(v >> uint(i) & 1)
+	}
+}
+
+func (w *headerWriter) end() []byte {
+	for w.bits > 0 {
+		w.bit(0)
+	}
+	if len(w.out) > 0 && w.out[len(w.out)-1] == 0xff {
+		w.out = append(w.out, 0)
+	}
+	return w.out
+}
+
+// encodeTagTree is the encoder side of the tag tree.
+type encodeTagTree struct {
+	tree   *tagTree
+	values map[*tagTreeNode]int
+}
+
+func newEncodeTagTree(width, height int, leafValues []int) *encodeTagTree {
+	t := &encodeTagTree{tree: newTagTree(width, height), values: map[*tagTreeNode]int{}}
+	for i, leaf := range t.tree.leaves {
+		for n := leaf; n != nil; n = n.parent {
+			if v, ok := t.values[n]; !ok || leafValues[i] < v {
+				t.values[n] = leafValues[i]
+			}
+		}
+	}
+	return t
+}
+
+func (t *encodeTagTree) encode(w *headerWriter, x, y, threshold int) {
+	var path []*tagTreeNode
+	for n := t.tree.leaves[y*t.tree.width+x]; n != nil; n = n.parent {
+		path = append(path, n)
+	}
+	low := 0
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		if n.low < low {
+			n.low = low
+		}
+		for !n.known && n.low < threshold {
+			if n.low >= t.values[n] {
+				w.bit(1)
+				n.known = true
+			} else {
+				w.bit(0)
+				n.low++
+			}
+		}
+		low = n.low
+	}
+}
+
+// writePackets writes the packets of the tile and returns the packet headers (if
+// packed) and the tile data.
+func (e *testEncoder) writePackets(t *testing.T, tl *tile) ([]byte, []byte) {
+	type precinctState struct {
+		inclusion, zero *encodeTagTree
+	}
+	states := map[*precinctBand]*precinctState{}
+	state := func(pb *precinctBand) *precinctState {
+		if s, ok := states[pb]; ok {
+			return s
+		}
+		inc := make([]int, len(pb.blocks))
+		zero := make([]int, len(pb.blocks))
+		for i, cb := range pb.blocks {
+			code := blockCodes[cb]
+			inc[i] = 1 << 20
+			if len(code.passLayer) > 0 {
+				inc[i] = code.passLayer[0]
+			}
+			zero[i] = code.zeroBitplanes
+		}
+		s := &precinctState{
+			inclusion: newEncodeTagTree(pb.numW, pb.numH, inc),
+			zero:      newEncodeTagTree(pb.numW, pb.numH, zero),
+		}
+		states[pb] = s
+		return s
+	}
+	passesDone := map[*codeBlock]int{}
+	lblock := map[*codeBlock]int{}
+	var headers, body bytes.Buffer
+	sequence := 0
+	tl.forEachPacket(func(layer, r, c, p int) error {
+		res := tl.components[c].resolutions[r]
+		hw := &headerWriter{}
+		var data bytes.Buffer
+		empty := true
+		for _, b := range res.bands {
+			for _, cb := range b.precincts[p].blocks {
+				code := blockCodes[cb]
+				for _, l := range code.passLayer {
+					if l == layer {
+						empty = false
+					}
+				}
+			}
+		}
+		if empty {
+			hw.bit(0)
+		} else {
+			hw.bit(1)
+			for _, b := range res.bands {
+				pb := b.precincts[p]
+				st := state(pb)
+				for k, cb := range pb.blocks {
+					x, y := k%pb.numW, k/pb.numW
+					code := blockCodes[cb]
+					start := passesDone[cb]
+					n := 0
+					for _, l := range code.passLayer {
+						if l == layer {
+							n++
+						}
+					}
+					if start == 0 {
+						st.inclusion.encode(hw, x, y, layer+1)
+						if n == 0 {
+							continue
+						}
+						st.zero.encode(hw, x, y, code.zeroBitplanes+1)
+					} else {
+						if n == 0 {
+							hw.bit(0)
+							continue
+						}
+						hw.bit(1)
+					}
+					// Number of passes.
+					switch {
+					case n == 1:
+						hw.write(0, 1)
+					case n == 2:
+						hw.write(2, 2)
+					case n <= 5:
+						hw.write(3, 2)
+						hw.write(n-3, 2)
+					case n <= 36:
+						hw.write(15, 4)
+						hw.write(n-6, 5)
+					default:
+						hw.write(511, 9)
+						hw.write(n-37, 7)
+					}
+					// Group the passes into the codeword segments.
+					var groups, lengths []int
+					segStart := 0
+					var segIndex int
+					for i, seg := range code.segments {
+						if start < segStart+seg.passes {
+							segIndex = i
+							break
+						}
+						segStart += seg.passes
+					}
+					for pass := start; pass < start+n; {
+						seg := code.segments[segIndex]
+						k := min(segStart+seg.passes, start+n) - pass
+						require.Equal(t, pass, segStart, "layer split within a segment")
+						groups = append(groups, k)
+						lengths = append(lengths, len(seg.data))
+						data.Write(seg.data)
+						pass += k
+						segStart += seg.passes
+						segIndex++
+					}
+					if _, ok := lblock[cb]; !ok {
+						lblock[cb] = 3
+					}
+					need := lblock[cb]
+					for i, k := range groups {
+						for lengths[i] >= 1<<uint(need+log2(k)) {
+							need++
+						}
+					}
+					for ; lblock[cb] < need; lblock[cb]++ {
+						hw.bit(1)
+					}
+					hw.bit(0)
+					for i, k := range groups {
+						hw.write(lengths[i], lblock[cb]+log2(k))
+					}
+					passesDone[cb] = start + n
+				}
+			}
+		}
+		header := hw.end()
+		if e.sop {
+			putU16(&body, markerSOP)
+			putU16(&body, 4)
+			putU16(&body, sequence)
+		}
+		sequence++
+		target := &body
+		if e.ppt {
+			target = &headers
+		}
+		target.Write(header)
+		if e.eph {
+			putU16(target, markerEPH)
+		}
+		body.Write(data.Bytes())
+		return nil
+	})
+	return headers.Bytes(), body.Bytes()
+}
+
+// wrapJP2 wraps the codestream into the JP2 file format.
+func wrapJP2(codestream []byte, boxes ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(jp2Signature)
+	b.Write(makeBox(boxFileType, []byte("jp2 \x00\x00\x00\x00jp2 ")))
+	var header []byte
+	for _, box := range boxes {
+		header = append(header, box...)
+	}
+	b.Write(makeBox(boxHeader, header))
+	b.Write(makeBox(boxContiguousCodes, codestream))
+	return b.Bytes()
+}
+
+func makeBox(typ uint32, content []byte) []byte {
+	b := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(b, uint32(8+len(content)))
+	binary.BigEndian.PutUint32(b[4:], typ)
+	return append(b, content...)
+}
@@ -0,0 +1,308 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// JP2 box types.
+const (
+	boxSignature       = 0x6a502020 // 'jP  '
+	boxFileType        = 0x66747970 // 'ftyp'
+	boxHeader          = 0x6a703268 // 'jp2h'
+	boxImageHeader     = 0x69686472 // 'ihdr'
+	boxColorSpec       = 0x636f6c72 // 'colr'
+	boxPalette         = 0x70636c72 // 'pclr'
+	boxComponentMap    = 0x636d6170 // 'cmap'
+	boxChannelDef      = 0x63646566 // 'cdef'
+	boxContiguousCodes = 0x6a703263 // 'jp2c'
+)
+
+// ColorSpace is the color space of the decoded image, as declared by the JP2 'colr' box
+// or guessed from the number of components of a raw codestream.
+type ColorSpace int
+
+// ColorSpace enum values.
+const (
+	ColorSpaceUnknown ColorSpace = iota
+	ColorSpaceGray
+	ColorSpaceRGB
+	ColorSpaceCMYK
+	// ColorSpaceICC is used when the color space is given by an embedded ICC profile.
+	ColorSpaceICC
+)
+
+// String implements fmt.Stringer interface.
+func (cs ColorSpace) String() string {
+	switch cs {
+	case ColorSpaceGray:
+		return "Gray"
+	case ColorSpaceRGB:
+		return "RGB"
+	case ColorSpaceCMYK:
+		return "CMYK"
+	case ColorSpaceICC:
+		return "ICC"
+	}
+	return "Unknown"
+}
+
+// Enumerated color spaces of the 'colr' box (ISO/IEC 15444-1 Table I.10 and 15444-2 Table M.25).
+const (
+	enumCSCMYK      = 12
+	enumCSsRGB      = 16
+	enumCSGreyscale = 17
+	enumCSsYCC      = 18
+	enumCSesRGB     = 20
+	enumCSROMMRGB   = 21
+	enumCSYPbPr1125 = 22
+	enumCSYPbPr1250 = 23
+	enumCSesYCC     = 24
+)
+
+// channel types of the 'cdef' box.
+const (
+	channelColor              = 0
+	channelOpacity            = 1
+	channelPremultipliedAlpha = 2
+)
+
+// box is a single JP2 box.
+type box struct {
+	typ      uint32
+	contents []byte
+}
+
+// fileFormat is the information gathered from the JP2 boxes which wrap the codestream.
+type fileFormat struct {
+	colorSpace ColorSpace
+	isYCC      bool
+	iccProfile []byte
+	palette    *palette
+	// channelMap maps output channels to the codestream components (and palette columns).
+	channelMap []channelMapping
+	// channels holds the channel definitions (types and associations).
+	channels []channelDef
+}
+
+// palette is the content of the 'pclr' box.
+type palette struct {
+	entries [][]int32 // [entry][column]
+	depths  []int
+	signed  []bool
+}
+
+// channelMapping is a single entry of the 'cmap' box.
+type channelMapping struct {
+	component int
+	// column is the palette column or -1 if the component is used directly.
+	column int
+}
+
+// channelDef is a single entry of the 'cdef' box.
+type channelDef struct {
+	index       int
+	typ         int
+	association int
+}
+
+// readBoxes splits 'data' into the sequence of JP2 boxes.
+func readBoxes(data []byte) ([]box, error) {
+	var boxes []box
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 8 {
+			return nil, errors.New("jpx: truncated box header")
+		}
+		length := uint64(binary.BigEndian.Uint32(data[pos:]))
+		typ := binary.BigEndian.Uint32(data[pos+4:])
+		headerLength := uint64(8)
+		switch length {
+		case 0:
+			// The box extends to the end of the file.
+			length = uint64(len(data) - pos)
+		case 1:
+			if len(data)-pos < 16 {
+				return nil, errors.New("jpx: truncated extended box header")
+			}
+			length = binary.BigEndian.Uint64(data[pos+8:])
+			headerLength = 16
+		}
+		if length < headerLength {
+			return nil, fmt.Errorf("jpx: invalid box length: %d", length)
+		}
+		end := uint64(pos) + length
+		if end > uint64(len(data)) {
+			// Be lenient with the last box which is often truncated by encoders.
+			end = uint64(len(data))
+		}
+		boxes = append(boxes, box{typ: typ, contents: data[uint64(pos)+headerLength : end]})
+		pos = int(end)
+	}
+	return boxes, nil
+}
+
+// parseFileFormat reads the JP2 boxes and returns the file format information and the
+// contiguous codestream.
+func parseFileFormat(data []byte) (*fileFormat, []byte, error) {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	ff := &fileFormat{}
+	var codestream []byte
+	for _, b := range boxes {
+		switch b.typ {
+		case boxHeader:
+			if err = ff.parseHeader(b.contents); err != nil {
+				return nil, nil, err
+			}
+		case boxContiguousCodes:
+			if codestream == nil {
+				codestream = b.contents
+			}
+		}
+	}
+	if codestream == nil {
+		return nil, nil, errors.New("jpx: no codestream box found")
+	}
+	return ff, codestream, nil
+}
+
+// parseHeader parses the contents of the JP2 header super box.
+func (ff *fileFormat) parseHeader(data []byte) error {
+	boxes, err := readBoxes(data)
+	if err != nil {
+		return err
+	}
+	colorSpecRead := false
+	for _, b := range boxes {
+		switch b.typ {
+		case boxColorSpec:
+			// Only the first color specification is required to be understood by the readers.
+			if colorSpecRead {
+				continue
+			}
+			colorSpecRead = ff.parseColorSpec(b.contents)
+		case boxPalette:
+			if ff.palette, err = parsePalette(b.contents); err != nil {
+				return err
+			}
+		case boxComponentMap:
+			if len(b.contents)%4 != 0 {
+				return errors.New("jpx: invalid component mapping box")
+			}
+			for i := 0; i < len(b.contents); i += 4 {
+				m := channelMapping{
+					component: int(binary.BigEndian.Uint16(b.contents[i:])),
+					column:    -1,
+				}
+				if b.contents[i+2] == 1 {
+					m.column = int(b.contents[i+3])
+				}
+				ff.channelMap = append(ff.channelMap, m)
+			}
+		case boxChannelDef:
+			if len(b.contents) < 2 {
+				return errors.New("jpx: invalid channel definition box")
+			}
+			n := int(binary.BigEndian.Uint16(b.contents))
+			if len(b.contents) < 2+6*n {
+				return errors.New("jpx: truncated channel definition box")
+			}
+			for i := 0; i < n; i++ {
+				p := b.contents[2+6*i:]
+				ff.channels = append(ff.channels, channelDef{
+					index:       int(binary.BigEndian.Uint16(p)),
+					typ:         int(binary.BigEndian.Uint16(p[2:])),
+					association: int(binary.BigEndian.Uint16(p[4:])),
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// parseColorSpec parses the 'colr' box. Returns false if the specification method is not
+// understood and the box should be ignored.
+func (ff *fileFormat) parseColorSpec(data []byte) bool {
+	if len(data) < 3 {
+		return false
+	}
+	switch method := data[0]; method {
+	case 1:
+		if len(data) < 7 {
+			return false
+		}
+		switch enumCS := binary.BigEndian.Uint32(data[3:]); enumCS {
+		case enumCSsRGB, enumCSesRGB, enumCSROMMRGB:
+			ff.colorSpace = ColorSpaceRGB
+		case enumCSsYCC, enumCSesYCC, enumCSYPbPr1125, enumCSYPbPr1250:
+			ff.colorSpace = ColorSpaceRGB
+			ff.isYCC = true
+		case enumCSGreyscale:
+			ff.colorSpace = ColorSpaceGray
+		case enumCSCMYK:
+			ff.colorSpace = ColorSpaceCMYK
+		default:
+			ff.colorSpace = ColorSpaceUnknown
+		}
+	case 2, 3:
+		ff.colorSpace = ColorSpaceICC
+		ff.iccProfile = data[3:]
+	default:
+		return false
+	}
+	return true
+}
+
+// parsePalette parses the 'pclr' box.
+func parsePalette(data []byte) (*palette, error) {
+	if len(data) < 3 {
+		return nil, errors.New("jpx: invalid palette box")
+	}
+	numEntries := int(binary.BigEndian.Uint16(data))
+	numColumns := int(data[2])
+	if len(data) < 3+numColumns {
+		return nil, errors.New("jpx: truncated palette box")
+	}
+	p := &palette{
+		depths: make([]int, numColumns),
+		signed: make([]bool, numColumns),
+	}
+	var bytesPerEntry []int
+	for i := 0; i < numColumns; i++ {
+		b := data[3+i]
+		p.depths[i] = int(b&0x7f) + 1
+		p.signed[i] = b&0x80 != 0
+		bytesPerEntry = append(bytesPerEntry, (p.depths[i]+7)/8)
+	}
+	pos := 3 + numColumns
+	p.entries = make([][]int32, numEntries)
+	for i := range p.entries {
+		entry := make([]int32, numColumns)
+		for j := 0; j < numColumns; j++ {
+			n := bytesPerEntry[j]
+			if pos+n > len(data) {
+				return nil, errors.New("jpx: truncated palette entries")
+			}
+			var v uint32
+			for k := 0; k < n; k++ {
+				v = v<<8 | uint32(data[pos+k])
+			}
+			pos += n
+			if p.signed[j] && v&(1<<uint(p.depths[j]-1)) != 0 {
+				entry[j] = int32(v) - 1<<uint(p.depths[j])
+			} else {
+				entry[j] = int32(v)
+			}
+		}
+		p.entries[i] = entry
+	}
+	return p, nil
+}
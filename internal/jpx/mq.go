@@ -0,0 +1,179 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+// qeEntry is a single row of the MQ-coder probability estimation table (Table C.2).
+type qeEntry struct {
+	qe        uint32
+	nmps      uint8
+	nlps      uint8
+	switchMPS uint8
+}
+
+var qeTable = [47]qeEntry{
+	{0x5601, 1, 1, 1}, {0x3401, 2, 6, 0}, {0x1801, 3, 9, 0}, {0x0AC1, 4, 12, 0},
+	{0x0521, 5, 29, 0}, {0x0221, 38, 33, 0}, {0x5601, 7, 6, 1}, {0x5401, 8, 14, 0},
+	{0x4801, 9, 14, 0}, {0x3801, 10, 14, 0}, {0x3001, 11, 17, 0}, {0x2401, 12, 18, 0},
+	{0x1C01, 13, 20, 0}, {0x1601, 29, 21, 0}, {0x5601, 15, 14, 1}, {0x5401, 16, 14, 0},
+	{0x5101, 17, 15, 0}, {0x4801, 18, 16, 0}, {0x3801, 19, 17, 0}, {0x3401, 20, 18, 0},
+	{0x3001, 21, 19, 0}, {0x2801, 22, 19, 0}, {0x2401, 23, 20, 0}, {0x2201, 24, 21, 0},
+	{0x1C01, 25, 22, 0}, {0x1801, 26, 23, 0}, {0x1601, 27, 24, 0}, {0x1401, 28, 25, 0},
+	{0x1201, 29, 26, 0}, {0x1101, 30, 27, 0}, {0x0AC1, 31, 28, 0}, {0x09C1, 32, 29, 0},
+	{0x08A1, 33, 30, 0}, {0x0521, 34, 31, 0}, {0x0441, 35, 32, 0}, {0x02A1, 36, 33, 0},
+	{0x0221, 37, 34, 0}, {0x0141, 38, 35, 0}, {0x0111, 39, 36, 0}, {0x0085, 40, 37, 0},
+	{0x0049, 41, 38, 0}, {0x0025, 42, 39, 0}, {0x0015, 43, 40, 0}, {0x0009, 44, 41, 0},
+	{0x0005, 45, 42, 0}, {0x0001, 45, 43, 0}, {0x5601, 46, 46, 0},
+}
+
+// mqContext is the state of a single MQ-coder context: the index in the qeTable
+// and the most probable symbol.
+type mqContext struct {
+	index uint8
+	mps   uint8
+}
+
+// mqDecoder is the MQ arithmetic decoder described in Annex C of T.800.
+type mqDecoder struct {
+	data  []byte
+	pos   int
+	chigh uint32
+	clow  uint32
+	a     uint32
+	ct    int
+}
+
+// reset initializes the decoder (INITDEC) with the codeword segment 'data'.
+func (d *mqDecoder) reset(data []byte) {
+	d.data = data
+	d.pos = 0
+	d.chigh = uint32(d.byteAt(0))
+	d.clow = 0
+	d.byteIn()
+	d.chigh = (d.chigh<<7)&0xffff | (d.clow>>9)&0x7f
+	d.clow = (d.clow << 7) & 0xffff
+	d.ct -= 7
+	d.a = 0x8000
+}
+
+// byteAt returns the byte at position 'i' of the segment. The segment is virtually
+// terminated by 0xFF bytes.
+func (d *mqDecoder) byteAt(i int) byte {
+	if i < len(d.data) {
+		return d.data[i]
+	}
+	return 0xff
+}
+
+// byteIn implements the BYTEIN procedure.
+func (d *mqDecoder) byteIn() {
+	if d.byteAt(d.pos) == 0xff {
+		if d.byteAt(d.pos+1) > 0x8f {
+			d.clow += 0xff00
+			d.ct = 8
+		} else {
+			d.pos++
+			d.clow += uint32(d.byteAt(d.pos)) << 9
+			d.ct = 7
+		}
+	} else {
+		d.pos++
+		d.clow += uint32(d.byteAt(d.pos)) << 8
+		d.ct = 8
+	}
+	if d.clow > 0xffff {
+		d.chigh += d.clow >> 16
+		d.clow &= 0xffff
+	}
+}
+
+// decode decodes a single binary decision in the context 'cx'.
+func (d *mqDecoder) decode(cx *mqContext) int {
+	e := &qeTable[cx.index]
+	qe := e.qe
+	a := d.a - qe
+	var bit uint8
+	if d.chigh < qe {
+		// LPS exchange.
+		if a < qe {
+			a = qe
+			bit = cx.mps
+			cx.index = e.nmps
+		} else {
+			a = qe
+			bit = 1 ^ cx.mps
+			if e.switchMPS == 1 {
+				cx.mps = bit
+			}
+			cx.index = e.nlps
+		}
+	} else {
+		d.chigh -= qe
+		if a&0x8000 != 0 {
+			d.a = a
+			return int(cx.mps)
+		}
+		// MPS exchange.
+		if a < qe {
+			bit = 1 ^ cx.mps
+			if e.switchMPS == 1 {
+				cx.mps = bit
+			}
+			cx.index = e.nlps
+		} else {
+			bit = cx.mps
+			cx.index = e.nmps
+		}
+	}
+	// Renormalization.
+	for {
+		if d.ct == 0 {
+			d.byteIn()
+		}
+		a <<= 1
+		d.chigh = (d.chigh<<1)&0xffff | (d.clow>>15)&1
+		d.clow = (d.clow << 1) & 0xffff
+		d.ct--
+		if a&0x8000 != 0 {
+			break
+		}
+	}
+	d.a = a
+	return int(bit)
+}
+
+// rawDecoder reads the raw (bypassed) coding passes.
+type rawDecoder struct {
+	data   []byte
+	pos    int
+	cur    byte
+	left   int
+	lastFF bool
+}
+
+func (d *rawDecoder) reset(data []byte) {
+	*d = rawDecoder{data: data}
+}
+
+// decode returns the next raw bit. After an 0xFF byte the most significant bit of the
+// following byte is a stuffed zero bit and is skipped.
+func (d *rawDecoder) decode() int {
+	if d.left == 0 {
+		if d.pos < len(d.data) {
+			d.cur = d.data[d.pos]
+		} else {
+			d.cur = 0xff
+		}
+		d.pos++
+		if d.lastFF {
+			d.left = 7
+		} else {
+			d.left = 8
+		}
+		d.lastFF = d.cur == 0xff
+	}
+	d.left--
+	return int(d.cur>>uint(d.left)) & 1
+}
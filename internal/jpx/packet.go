@@ -0,0 +1,423 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"errors"
+	"sort"
+)
+
+var errUnexpectedEnd = errors.New("jpx: unexpected end of the packet data")
+
+// bitReader reads the packet header bits. After an 0xFF byte only seven bits of the
+// following byte are used - its most significant bit is a stuffed zero bit.
+type bitReader struct {
+	data   []byte
+	pos    int
+	cur    byte
+	left   int
+	lastFF bool
+	err    error
+}
+
+func (r *bitReader) bit() int {
+	if r.left == 0 {
+		if r.pos >= len(r.data) {
+			r.err = errUnexpectedEnd
+			return 0
+		}
+		r.cur = r.data[r.pos]
+		r.pos++
+		if r.lastFF {
+			r.left = 7
+		} else {
+			r.left = 8
+		}
+		r.lastFF = r.cur == 0xff
+	}
+	r.left--
+	return int(r.cur>>uint(r.left)) & 1
+}
+
+func (r *bitReader) bits(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | r.bit()
+	}
+	return v
+}
+
+// align skips the remaining bits of the current byte at the end of the packet header.
+func (r *bitReader) align() {
+	r.left = 0
+	if r.lastFF {
+		// The header can't end with an 0xFF byte, the following byte belongs to it.
+		r.pos++
+		r.lastFF = false
+	}
+}
+
+// skipMarker skips the two byte 'marker' (and the following 'length' bytes) if it is
+// found at the current position.
+func (r *bitReader) skipMarker(marker, length int) {
+	if r.pos+2 <= len(r.data) && int(r.data[r.pos])<<8|int(r.data[r.pos+1]) == marker {
+		r.pos += 2 + length
+	}
+}
+
+// numPasses decodes the number of the new coding passes (Table B.4).
+func (r *bitReader) numPasses() int {
+	if r.bit() == 0 {
+		return 1
+	}
+	if r.bit() == 0 {
+		return 2
+	}
+	if v := r.bits(2); v < 3 {
+		return 3 + v
+	}
+	if v := r.bits(5); v < 31 {
+		return 6 + v
+	}
+	return 37 + r.bits(7)
+}
+
+// log2 returns floor(log2(n)) for positive 'n'.
+func log2(n int) int {
+	v := 0
+	for n > 1 {
+		n >>= 1
+		v++
+	}
+	return v
+}
+
+// packetReader reads the packets of a single tile.
+type packetReader struct {
+	body *bitReader
+	// header is the reader of the packed packet headers (PPM or PPT) or the 'body' reader
+	// if the headers are within the tile data.
+	header *bitReader
+	sop    bool
+	eph    bool
+}
+
+// contribution is the new data of a code-block included in the packet.
+type contribution struct {
+	block   *codeBlock
+	style   int
+	passes  []int
+	lengths []int
+}
+
+// readPacket reads the packet of the 'layer', resolution 'res', component 'comp' and
+// precinct 'precinct' and assigns its data to the code-blocks.
+func (t *tile) readPacket(pr *packetReader, layer, res, comp, precinct int) error {
+	tc := t.components[comp]
+	r := tc.resolutions[res]
+	if pr.sop {
+		pr.body.skipMarker(markerSOP, 4)
+	}
+	hr := pr.header
+	if hr == pr.body {
+		hr.left = 0
+		hr.lastFF = false
+	}
+	var contributions []contribution
+	if hr.bit() == 1 {
+		for _, b := range r.bands {
+			pb := b.precincts[precinct]
+			for k, cb := range pb.blocks {
+				if hr.err != nil {
+					return hr.err
+				}
+				x, y := k%pb.numW, k/pb.numW
+				var included bool
+				if !cb.included {
+					v, known := pb.inclusion.decode(hr, x, y, layer+1)
+					included = known && v <= layer
+				} else {
+					included = hr.bit() == 1
+				}
+				if !included {
+					continue
+				}
+				if !cb.included {
+					v, _ := pb.zeroBitplanes.decode(hr, x, y, 1<<30)
+					cb.zeroBitplanes = v
+					cb.included = true
+				}
+				n := hr.numPasses()
+				for hr.bit() == 1 && hr.err == nil {
+					cb.lblock++
+				}
+				ctr := contribution{block: cb, style: tc.style.blockStyle}
+				// Each codeword segment has its own length.
+				for pass, end := cb.numPasses, cb.numPasses+n; pass < end; {
+					k := 1
+					for !isTerminatingPass(pass+k-1, ctr.style) && pass+k < end {
+						k++
+					}
+					ctr.passes = append(ctr.passes, k)
+					ctr.lengths = append(ctr.lengths, hr.bits(cb.lblock+log2(k)))
+					pass += k
+				}
+				cb.numPasses += n
+				contributions = append(contributions, ctr)
+			}
+		}
+	}
+	if hr.err != nil {
+		return hr.err
+	}
+	hr.align()
+	if pr.eph {
+		hr.skipMarker(markerEPH, 0)
+	}
+
+	// Read the code-block contributions from the packet body.
+	body := pr.body
+	for _, ctr := range contributions {
+		cb := ctr.block
+		pass := cb.numPasses
+		for _, k := range ctr.passes {
+			pass -= k
+		}
+		for i, k := range ctr.passes {
+			n := ctr.lengths[i]
+			if body.pos+n > len(body.data) {
+				return errUnexpectedEnd
+			}
+			data := body.data[body.pos : body.pos+n]
+			body.pos += n
+			var seg *codewordSegment
+			if len(cb.segments) > 0 && !cb.segments[len(cb.segments)-1].terminated {
+				seg = cb.segments[len(cb.segments)-1]
+			} else {
+				seg = &codewordSegment{}
+				cb.segments = append(cb.segments, seg)
+			}
+			seg.data = append(seg.data, data...)
+			seg.passes += k
+			pass += k
+			seg.terminated = isTerminatingPass(pass-1, ctr.style)
+		}
+	}
+	return nil
+}
+
+// packetKey identifies a packet of the tile.
+type packetKey struct {
+	layer, res, comp, precinct int
+}
+
+// forEachPacket calls 'fn' for each packet of the tile in the progression order.
+func (t *tile) forEachPacket(fn func(layer, res, comp, precinct int) error) error {
+	numLayers := t.progression.layers
+	maxRes := 0
+	for _, tc := range t.components {
+		maxRes = max(maxRes, len(tc.resolutions))
+	}
+	volumes := append([]progressionChange{}, t.changes...)
+	// The default progression covers the packets not listed in the progression changes.
+	volumes = append(volumes, progressionChange{
+		layerEnd: numLayers,
+		resEnd:   maxRes,
+		compEnd:  len(t.components),
+		order:    t.progression.order,
+	})
+	var seen map[packetKey]bool
+	if len(volumes) > 1 {
+		seen = map[packetKey]bool{}
+	}
+	emit := func(l, r, c, p int) error {
+		if seen != nil {
+			key := packetKey{l, r, c, p}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+		return fn(l, r, c, p)
+	}
+	for _, v := range volumes {
+		v.layerEnd = min(v.layerEnd, numLayers)
+		v.resEnd = min(v.resEnd, maxRes)
+		v.compEnd = min(v.compEnd, len(t.components))
+		var err error
+		switch v.order {
+		case progressionLRCP:
+			err = t.iterateLRCP(v, emit)
+		case progressionRLCP:
+			err = t.iterateRLCP(v, emit)
+		default:
+			err = t.iteratePositions(v, emit)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// numPrecincts returns the number of precincts of the component 'c' at the resolution 'r'
+// or 0 if the resolution doesn't exist.
+func (t *tile) numPrecincts(c, r int) int {
+	tc := t.components[c]
+	if r >= len(tc.resolutions) {
+		return 0
+	}
+	res := tc.resolutions[r]
+	return res.numPrecW * res.numPrecH
+}
+
+func (t *tile) iterateLRCP(v progressionChange, emit func(l, r, c, p int) error) error {
+	for l := 0; l < v.layerEnd; l++ {
+		for r := v.resStart; r < v.resEnd; r++ {
+			for c := v.compStart; c < v.compEnd; c++ {
+				for p, n := 0, t.numPrecincts(c, r); p < n; p++ {
+					if err := emit(l, r, c, p); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (t *tile) iterateRLCP(v progressionChange, emit func(l, r, c, p int) error) error {
+	for r := v.resStart; r < v.resEnd; r++ {
+		for l := 0; l < v.layerEnd; l++ {
+			for c := v.compStart; c < v.compEnd; c++ {
+				for p, n := 0, t.numPrecincts(c, r); p < n; p++ {
+					if err := emit(l, r, c, p); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// iteratePositions iterates the packets in the position driven progression orders
+// RPCL, PCRL and CPRL (B.12.1.3 - B.12.1.5).
+func (t *tile) iteratePositions(v progressionChange, emit func(l, r, c, p int) error) error {
+	xs, ys := t.precinctPositions(v)
+	// precinct returns the precinct index of the component 'c' at the resolution 'r'
+	// starting at the reference grid position (x,y) or -1 if no precinct starts there.
+	precinct := func(c, r, x, y int) int {
+		tc := t.components[c]
+		if r >= len(tc.resolutions) {
+			return -1
+		}
+		res := tc.resolutions[r]
+		if res.numPrecW == 0 || res.numPrecH == 0 {
+			return -1
+		}
+		scale := uint(len(tc.resolutions) - 1 - r)
+		stepX := tc.dx << (uint(res.ppx) + scale)
+		stepY := tc.dy << (uint(res.ppy) + scale)
+		if !(y%stepY == 0 || (y == t.y0 && (res.y0<<scale)%(1<<(uint(res.ppy)+scale)) != 0)) {
+			return -1
+		}
+		if !(x%stepX == 0 || (x == t.x0 && (res.x0<<scale)%(1<<(uint(res.ppx)+scale)) != 0)) {
+			return -1
+		}
+		px := ceilDiv(x, tc.dx<<scale)>>uint(res.ppx) - res.precX0
+		py := ceilDiv(y, tc.dy<<scale)>>uint(res.ppy) - res.precY0
+		if px < 0 || py < 0 || px >= res.numPrecW || py >= res.numPrecH {
+			return -1
+		}
+		return py*res.numPrecW + px
+	}
+	layers := func(r, c, p int) error {
+		for l := 0; l < v.layerEnd; l++ {
+			if err := emit(l, r, c, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch v.order {
+	case progressionRPCL:
+		for r := v.resStart; r < v.resEnd; r++ {
+			for _, y := range ys {
+				for _, x := range xs {
+					for c := v.compStart; c < v.compEnd; c++ {
+						if p := precinct(c, r, x, y); p >= 0 {
+							if err := layers(r, c, p); err != nil {
+								return err
+							}
+						}
+					}
+				}
+			}
+		}
+	case progressionPCRL:
+		for _, y := range ys {
+			for _, x := range xs {
+				for c := v.compStart; c < v.compEnd; c++ {
+					for r := v.resStart; r < v.resEnd; r++ {
+						if p := precinct(c, r, x, y); p >= 0 {
+							if err := layers(r, c, p); err != nil {
+								return err
+							}
+						}
+					}
+				}
+			}
+		}
+	case progressionCPRL:
+		for c := v.compStart; c < v.compEnd; c++ {
+			for _, y := range ys {
+				for _, x := range xs {
+					for r := v.resStart; r < v.resEnd; r++ {
+						if p := precinct(c, r, x, y); p >= 0 {
+							if err := layers(r, c, p); err != nil {
+								return err
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// precinctPositions returns the sorted reference grid positions where the precincts of
+// the progression volume 'v' may start.
+func (t *tile) precinctPositions(v progressionChange) ([]int, []int) {
+	xset := map[int]bool{t.x0: true}
+	yset := map[int]bool{t.y0: true}
+	for c := v.compStart; c < v.compEnd; c++ {
+		tc := t.components[c]
+		for r := v.resStart; r < v.resEnd && r < len(tc.resolutions); r++ {
+			res := tc.resolutions[r]
+			scale := uint(len(tc.resolutions) - 1 - r)
+			stepX := tc.dx << (uint(res.ppx) + scale)
+			stepY := tc.dy << (uint(res.ppy) + scale)
+			for x := (t.x0/stepX + 1) * stepX; x < t.x1; x += stepX {
+				xset[x] = true
+			}
+			for y := (t.y0/stepY + 1) * stepY; y < t.y1; y += stepY {
+				yset[y] = true
+			}
+		}
+	}
+	return sortedKeys(xset), sortedKeys(yset)
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,434 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"errors"
+)
+
+// Subband orientations.
+const (
+	bandLL = iota
+	bandHL
+	bandLH
+	bandHH
+)
+
+// Contexts of the coefficient bit modeling (Annex D).
+const (
+	ctxRefinement = 14
+	ctxRunLength  = 17
+	ctxUniform    = 18
+	numContexts   = 19
+)
+
+// Coefficient state flags.
+const (
+	flagSignificant = 1 << iota
+	flagNegative
+	flagVisited
+	flagRefined
+)
+
+// Coding pass types.
+const (
+	passSignificance = iota
+	passRefinement
+	passCleanup
+)
+
+// maxBitplanes is the maximum number of magnitude bit-planes the tier-1 decoder handles.
+const maxBitplanes = 30
+
+// zcContextLL is the zero coding context lookup (Table D.1) for the LL and LH subbands
+// indexed by [h][v][d] neighbor significance counts (d limited to 2).
+var zcContextLL = [3][3][3]uint8{
+	{{0, 1, 2}, {3, 3, 3}, {4, 4, 4}},
+	{{5, 6, 6}, {7, 7, 7}, {7, 7, 7}},
+	{{8, 8, 8}, {8, 8, 8}, {8, 8, 8}},
+}
+
+// zcContextHH is the zero coding context lookup for the HH subband indexed by [h+v][d]
+// (h+v limited to 2, d limited to 3).
+var zcContextHH = [3][4]uint8{
+	{0, 3, 6, 8},
+	{1, 4, 7, 8},
+	{2, 5, 7, 8},
+}
+
+// signContext is the sign coding context and XOR bit lookup (Table D.3) indexed by the
+// horizontal and vertical contributions incremented by one.
+var signContext = [3][3][2]uint8{
+	{{13, 1}, {12, 1}, {11, 1}},
+	{{10, 1}, {9, 0}, {10, 0}},
+	{{11, 0}, {12, 0}, {13, 0}},
+}
+
+// codeBlockDecoder decodes the code-block coding passes (tier-1 decoding).
+type codeBlockDecoder struct {
+	width, height int
+	stride        int
+	band          int
+	style         int
+	flags         []uint8
+	// magnitudes are kept in half units, so that the middle of the uncertainty interval
+	// is available for the reconstruction.
+	magnitudes []int32
+	contexts   [numContexts]mqContext
+	mq         mqDecoder
+	raw        rawDecoder
+	useRaw     bool
+}
+
+func (d *codeBlockDecoder) init(width, height, band, style int) {
+	d.width = width
+	d.height = height
+	d.stride = width + 2
+	d.band = band
+	d.style = style
+	size := d.stride * (height + 2)
+	if cap(d.flags) < size {
+		d.flags = make([]uint8, size)
+	} else {
+		d.flags = d.flags[:size]
+		for i := range d.flags {
+			d.flags[i] = 0
+		}
+	}
+	n := width * height
+	if cap(d.magnitudes) < n {
+		d.magnitudes = make([]int32, n)
+	} else {
+		d.magnitudes = d.magnitudes[:n]
+		for i := range d.magnitudes {
+			d.magnitudes[i] = 0
+		}
+	}
+	d.resetContexts()
+}
+
+func (d *codeBlockDecoder) resetContexts() {
+	for i := range d.contexts {
+		d.contexts[i] = mqContext{}
+	}
+	d.contexts[0].index = 4
+	d.contexts[ctxRunLength].index = 3
+	d.contexts[ctxUniform].index = 46
+}
+
+// passType returns the type of the coding pass with the index 'pass'. The first pass
+// is always the cleanup pass.
+func passType(pass int) int {
+	if pass == 0 {
+		return passCleanup
+	}
+	return (pass - 1) % 3
+}
+
+// isRawPass checks if the coding pass is coded without the arithmetic coder when the
+// selective arithmetic coding bypass is used.
+func isRawPass(pass, style int) bool {
+	return style&cbStyleBypass != 0 && pass >= 10 && passType(pass) != passCleanup
+}
+
+// isTerminatingPass checks if the codeword segment ends after the coding pass.
+func isTerminatingPass(pass, style int) bool {
+	if style&cbStyleTermAll != 0 {
+		return true
+	}
+	if style&cbStyleBypass != 0 {
+		if pass == 9 {
+			return true
+		}
+		if pass >= 10 {
+			return passType(pass) != passSignificance
+		}
+	}
+	return false
+}
+
+// decode decodes the codeword segments of the code-block 'cb' which has 'numBitplanes'
+// magnitude bit-planes.
+func (d *codeBlockDecoder) decode(cb *codeBlock, numBitplanes int) error {
+	top := numBitplanes - 1 - cb.zeroBitplanes
+	if top < 0 || cb.numPasses == 0 {
+		return nil
+	}
+	if top >= maxBitplanes {
+		return errors.New("jpx: too many magnitude bit-planes")
+	}
+	pass := 0
+	for _, seg := range cb.segments {
+		d.useRaw = isRawPass(pass, d.style)
+		if d.useRaw {
+			d.raw.reset(seg.data)
+		} else {
+			d.mq.reset(seg.data)
+		}
+		for i := 0; i < seg.passes; i, pass = i+1, pass+1 {
+			bitplane := top - (pass+2)/3
+			if bitplane < 0 {
+				return nil
+			}
+			switch passType(pass) {
+			case passSignificance:
+				d.significancePass(bitplane)
+			case passRefinement:
+				d.refinementPass(bitplane)
+			case passCleanup:
+				d.cleanupPass(bitplane)
+			}
+			if d.style&cbStyleReset != 0 {
+				d.resetContexts()
+			}
+		}
+	}
+	return nil
+}
+
+// bit decodes a single bit either with the arithmetic decoder in context 'cx' or
+// directly from the raw segment.
+func (d *codeBlockDecoder) bit(cx int) int {
+	if d.useRaw {
+		return d.raw.decode()
+	}
+	return d.mq.decode(&d.contexts[cx])
+}
+
+// isSignificant returns 1 if the coefficient at flag index 'i' is significant.
+func (d *codeBlockDecoder) isSignificant(i int) int {
+	return int(d.flags[i] & flagSignificant)
+}
+
+// neighbors returns the number of significant horizontal, vertical and diagonal
+// neighbors of the coefficient at flag index 'i'.
+func (d *codeBlockDecoder) neighbors(i int, stripeEnd bool) (h, v, dg int) {
+	s := d.stride
+	h = d.isSignificant(i-1) + d.isSignificant(i+1)
+	v = d.isSignificant(i - s)
+	dg = d.isSignificant(i-s-1) + d.isSignificant(i-s+1)
+	if !stripeEnd || d.style&cbStyleCausal == 0 {
+		v += d.isSignificant(i + s)
+		dg += d.isSignificant(i+s-1) + d.isSignificant(i+s+1)
+	}
+	return h, v, dg
+}
+
+// zeroContext returns the zero coding context of the coefficient at flag index 'i'.
+func (d *codeBlockDecoder) zeroContext(i int, stripeEnd bool) int {
+	h, v, dg := d.neighbors(i, stripeEnd)
+	switch d.band {
+	case bandHL:
+		h, v = v, h
+		fallthrough
+	case bandLL, bandLH:
+		if dg > 2 {
+			dg = 2
+		}
+		return int(zcContextLL[h][v][dg])
+	}
+	hv := h + v
+	if hv > 2 {
+		hv = 2
+	}
+	if dg > 3 {
+		dg = 3
+	}
+	return int(zcContextHH[hv][dg])
+}
+
+// contribution returns the sign contribution of the coefficient at flag index 'i'.
+func (d *codeBlockDecoder) contribution(i int) int {
+	f := d.flags[i]
+	if f&flagSignificant == 0 {
+		return 0
+	}
+	if f&flagNegative != 0 {
+		return -1
+	}
+	return 1
+}
+
+// decodeSign decodes the sign of the coefficient at flag index 'i'.
+// Returns true if the coefficient is negative.
+func (d *codeBlockDecoder) decodeSign(i int, stripeEnd bool) bool {
+	if d.useRaw {
+		return d.raw.decode() == 1
+	}
+	cx, xor := d.signContext(i, stripeEnd)
+	return d.mq.decode(&d.contexts[cx])^xor == 1
+}
+
+// signContext returns the sign coding context and the XOR bit of the coefficient at
+// flag index 'i'.
+func (d *codeBlockDecoder) signContext(i int, stripeEnd bool) (int, int) {
+	s := d.stride
+	h := d.contribution(i-1) + d.contribution(i+1)
+	v := d.contribution(i - s)
+	if !stripeEnd || d.style&cbStyleCausal == 0 {
+		v += d.contribution(i + s)
+	}
+	e := signContext[clampSign(h)+1][clampSign(v)+1]
+	return int(e[0]), int(e[1])
+}
+
+func clampSign(v int) int {
+	if v < -1 {
+		return -1
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// setSignificant marks the coefficient at (x,y) with the flag index 'i' significant in
+// the bit-plane 'bitplane'.
+func (d *codeBlockDecoder) setSignificant(x, y, i, bitplane int, negative bool) {
+	d.flags[i] |= flagSignificant
+	if negative {
+		d.flags[i] |= flagNegative
+	}
+	d.magnitudes[y*d.width+x] = 3 << uint(bitplane)
+}
+
+// significancePass decodes the significance propagation pass.
+func (d *codeBlockDecoder) significancePass(bitplane int) {
+	for y0 := 0; y0 < d.height; y0 += 4 {
+		y1 := min(y0+4, d.height)
+		for x := 0; x < d.width; x++ {
+			for y := y0; y < y1; y++ {
+				i := (y+1)*d.stride + x + 1
+				if d.flags[i]&flagSignificant != 0 {
+					continue
+				}
+				stripeEnd := y == y0+3
+				cx := d.zeroContext(i, stripeEnd)
+				if cx == 0 {
+					continue
+				}
+				d.flags[i] |= flagVisited
+				if d.bit(cx) == 1 {
+					d.setSignificant(x, y, i, bitplane, d.decodeSign(i, stripeEnd))
+				}
+			}
+		}
+	}
+}
+
+// refinementPass decodes the magnitude refinement pass.
+func (d *codeBlockDecoder) refinementPass(bitplane int) {
+	for y0 := 0; y0 < d.height; y0 += 4 {
+		y1 := min(y0+4, d.height)
+		for x := 0; x < d.width; x++ {
+			for y := y0; y < y1; y++ {
+				i := (y+1)*d.stride + x + 1
+				f := d.flags[i]
+				if f&flagSignificant == 0 || f&flagVisited != 0 {
+					continue
+				}
+				cx := ctxRefinement + 2
+				if f&flagRefined == 0 {
+					cx = ctxRefinement
+					if h, v, dg := d.neighbors(i, y == y0+3); h+v+dg > 0 {
+						cx++
+					}
+				}
+				k := y*d.width + x
+				if d.bit(cx) == 1 {
+					d.magnitudes[k] += 1 << uint(bitplane)
+				} else {
+					d.magnitudes[k] -= 1 << uint(bitplane)
+				}
+				d.flags[i] |= flagRefined
+			}
+		}
+	}
+}
+
+// cleanupPass decodes the cleanup pass.
+func (d *codeBlockDecoder) cleanupPass(bitplane int) {
+	s := d.stride
+	for y0 := 0; y0 < d.height; y0 += 4 {
+		y1 := min(y0+4, d.height)
+		for x := 0; x < d.width; x++ {
+			y := y0
+			if y1-y0 == 4 && d.canUseRunLength(x, y0) {
+				if d.mq.decode(&d.contexts[ctxRunLength]) == 0 {
+					continue
+				}
+				r := d.mq.decode(&d.contexts[ctxUniform]) << 1
+				r |= d.mq.decode(&d.contexts[ctxUniform])
+				y = y0 + r
+				i := (y+1)*s + x + 1
+				d.setSignificant(x, y, i, bitplane, d.decodeSign(i, y == y0+3))
+				y++
+			}
+			for ; y < y1; y++ {
+				i := (y+1)*s + x + 1
+				if d.flags[i]&(flagSignificant|flagVisited) != 0 {
+					continue
+				}
+				stripeEnd := y == y0+3
+				if d.mq.decode(&d.contexts[d.zeroContext(i, stripeEnd)]) == 1 {
+					d.setSignificant(x, y, i, bitplane, d.decodeSign(i, stripeEnd))
+				}
+			}
+		}
+	}
+	if d.style&cbStyleSegmentation != 0 {
+		// The segmentation symbol 1010 is decoded and ignored.
+		for i := 0; i < 4; i++ {
+			d.mq.decode(&d.contexts[ctxUniform])
+		}
+	}
+	for i := range d.flags {
+		d.flags[i] &^= flagVisited
+	}
+}
+
+// canUseRunLength checks if the column of the stripe starting at (x, y0) is decoded in
+// the run-length mode.
+func (d *codeBlockDecoder) canUseRunLength(x, y0 int) bool {
+	for y := y0; y < y0+4; y++ {
+		i := (y+1)*d.stride + x + 1
+		if d.flags[i]&(flagSignificant|flagVisited) != 0 {
+			return false
+		}
+		if d.zeroContext(i, y == y0+3) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// coefficient returns the signed value of the decoded coefficient at (x,y) in half units.
+func (d *codeBlockDecoder) coefficient(x, y int) int32 {
+	m := d.magnitudes[y*d.width+x]
+	if d.flags[(y+1)*d.stride+x+1]&flagNegative != 0 {
+		return -m
+	}
+	return m
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ceilDiv returns ceil(a/b) for non-negative 'a' and positive 'b'.
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,77 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+// tagTreeNode is a single node of the tag tree.
+type tagTreeNode struct {
+	parent *tagTreeNode
+	// low is the current lower bound of the node value. If 'known' is set it is the value.
+	low   int
+	known bool
+}
+
+// tagTree is the tag tree (B.10.2) used to code the code-block inclusion information and
+// the number of the missing most significant bit-planes.
+type tagTree struct {
+	width  int
+	leaves []*tagTreeNode
+	path   []*tagTreeNode
+}
+
+// newTagTree creates the tag tree for the 'width' x 'height' array of code-blocks.
+func newTagTree(width, height int) *tagTree {
+	t := &tagTree{width: width}
+	if width == 0 || height == 0 {
+		return t
+	}
+	level := make([]*tagTreeNode, width*height)
+	for i := range level {
+		level[i] = &tagTreeNode{}
+	}
+	t.leaves = level
+	w, h := width, height
+	for w > 1 || h > 1 {
+		pw, ph := (w+1)/2, (h+1)/2
+		parents := make([]*tagTreeNode, pw*ph)
+		for i := range parents {
+			parents[i] = &tagTreeNode{}
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				level[y*w+x].parent = parents[(y/2)*pw+x/2]
+			}
+		}
+		level = parents
+		w, h = pw, ph
+	}
+	return t
+}
+
+// decode decodes the value of the leaf (x,y) up to the 'threshold'. Returns true if the
+// value is known to be lower than the threshold, i.e. the value is fully decoded.
+func (t *tagTree) decode(r *bitReader, x, y, threshold int) (int, bool) {
+	t.path = t.path[:0]
+	for n := t.leaves[y*t.width+x]; n != nil; n = n.parent {
+		t.path = append(t.path, n)
+	}
+	low := 0
+	for i := len(t.path) - 1; i >= 0; i-- {
+		n := t.path[i]
+		if n.low < low {
+			n.low = low
+		}
+		for !n.known && n.low < threshold && r.err == nil {
+			if r.bit() == 1 {
+				n.known = true
+			} else {
+				n.low++
+			}
+		}
+		low = n.low
+	}
+	leaf := t.path[0]
+	return leaf.low, leaf.known
+}
@@ -0,0 +1,277 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package jpx
+
+import (
+	"errors"
+	"math"
+)
+
+// tile is a single tile of the image being decoded.
+type tile struct {
+	index          int
+	x0, y0, x1, y1 int
+	progression    *progressionStyle
+	changes        []progressionChange
+	components     []*tileComponent
+}
+
+// tileComponent is a component of the tile.
+type tileComponent struct {
+	index          int
+	x0, y0, x1, y1 int
+	dx, dy         int
+	precision      int
+	signed         bool
+	style          *codingStyle
+	quant          *quantization
+	roiShift       int
+	resolutions    []*resolution
+}
+
+// resolution is a single resolution level of the tile component.
+type resolution struct {
+	level          int
+	x0, y0, x1, y1 int
+	ppx, ppy       int
+	// precX0 and precY0 are the indices of the first precinct in the precinct grid.
+	precX0, precY0 int
+	numPrecW       int
+	numPrecH       int
+	bands          []*subband
+}
+
+// subband holds the code-blocks of a subband.
+type subband struct {
+	kind           int
+	x0, y0, x1, y1 int
+	xcb, ycb       int
+	numBitplanes   int
+	stepSize       float64
+	coefficients   []float32
+	// precincts are the code-block groups of the subband in each precinct of the resolution.
+	precincts []*precinctBand
+}
+
+// precinctBand is the set of code-blocks of a single subband within a precinct.
+type precinctBand struct {
+	numW, numH    int
+	blocks        []*codeBlock
+	inclusion     *tagTree
+	zeroBitplanes *tagTree
+}
+
+// codeBlock is a single code-block and its codeword segments.
+type codeBlock struct {
+	x0, y0, x1, y1 int
+	included       bool
+	lblock         int
+	zeroBitplanes  int
+	numPasses      int
+	segments       []*codewordSegment
+}
+
+// codewordSegment is a terminated sequence of coding passes.
+type codewordSegment struct {
+	data       []byte
+	passes     int
+	terminated bool
+}
+
+// newTile creates the tile with the index 'index' and computes the geometry of its
+// components, resolutions, subbands, precincts and code-blocks.
+func (cs *codestream) newTile(td *tileData) (*tile, error) {
+	s := &cs.size
+	p := td.index % s.numTilesX
+	q := td.index / s.numTilesX
+	t := &tile{
+		index: td.index,
+		x0:    max(s.tileX0+p*s.tileW, s.x0),
+		y0:    max(s.tileY0+q*s.tileH, s.y0),
+		x1:    min(s.tileX0+(p+1)*s.tileW, s.x1),
+		y1:    min(s.tileY0+(q+1)*s.tileH, s.y1),
+	}
+	progression, styles, quants, roi, changes := cs.tileParams(td.params)
+	t.progression = progression
+	t.changes = changes
+	for c, comp := range s.components {
+		tc := &tileComponent{
+			index:     c,
+			x0:        ceilDiv(t.x0, comp.dx),
+			y0:        ceilDiv(t.y0, comp.dy),
+			x1:        ceilDiv(t.x1, comp.dx),
+			y1:        ceilDiv(t.y1, comp.dy),
+			dx:        comp.dx,
+			dy:        comp.dy,
+			precision: comp.precision,
+			signed:    comp.signed,
+			style:     styles[c],
+			quant:     quants[c],
+			roiShift:  roi[c],
+		}
+		if err := tc.build(); err != nil {
+			return nil, err
+		}
+		t.components = append(t.components, tc)
+	}
+	return t, nil
+}
+
+// build computes the resolutions and subbands of the tile component.
+func (tc *tileComponent) build() error {
+	numLevels := tc.style.levels
+	for r := 0; r <= numLevels; r++ {
+		scale := numLevels - r
+		res := &resolution{
+			level: r,
+			x0:    ceilDivPow2(tc.x0, scale),
+			y0:    ceilDivPow2(tc.y0, scale),
+			x1:    ceilDivPow2(tc.x1, scale),
+			y1:    ceilDivPow2(tc.y1, scale),
+			ppx:   tc.style.precinctSize[r] & 0xf,
+			ppy:   tc.style.precinctSize[r] >> 4,
+		}
+		if r > 0 && (res.ppx == 0 || res.ppy == 0) {
+			return errors.New("jpx: invalid precinct size")
+		}
+		if res.x1 > res.x0 && res.y1 > res.y0 {
+			res.precX0 = res.x0 >> uint(res.ppx)
+			res.precY0 = res.y0 >> uint(res.ppy)
+			res.numPrecW = ceilDivPow2(res.x1, res.ppx) - res.precX0
+			res.numPrecH = ceilDivPow2(res.y1, res.ppy) - res.precY0
+		}
+
+		// The code-block size is limited by the precinct size.
+		xcb, ycb := tc.style.xcb, tc.style.ycb
+		ppx, ppy := res.ppx, res.ppy
+		if r > 0 {
+			ppx--
+			ppy--
+		}
+		xcb = min(xcb, ppx)
+		ycb = min(ycb, ppy)
+
+		var kinds []int
+		if r == 0 {
+			kinds = []int{bandLL}
+		} else {
+			kinds = []int{bandHL, bandLH, bandHH}
+		}
+		for _, kind := range kinds {
+			nb := numLevels - r + 1
+			if r == 0 {
+				nb = numLevels
+			}
+			xob, yob := 0, 0
+			if kind == bandHL || kind == bandHH {
+				xob = 1
+			}
+			if kind == bandLH || kind == bandHH {
+				yob = 1
+			}
+			b := &subband{
+				kind: kind,
+				xcb:  xcb,
+				ycb:  ycb,
+			}
+			if nb == 0 {
+				b.x0, b.y0, b.x1, b.y1 = tc.x0, tc.y0, tc.x1, tc.y1
+			} else {
+				ox := xob << uint(nb-1)
+				oy := yob << uint(nb-1)
+				b.x0 = ceilDivPow2(tc.x0-ox, nb)
+				b.y0 = ceilDivPow2(tc.y0-oy, nb)
+				b.x1 = ceilDivPow2(tc.x1-ox, nb)
+				b.y1 = ceilDivPow2(tc.y1-oy, nb)
+			}
+			if err := tc.quantize(b, r, nb); err != nil {
+				return err
+			}
+			b.buildPrecincts(res, ppx, ppy)
+			res.bands = append(res.bands, b)
+		}
+		tc.resolutions = append(tc.resolutions, res)
+	}
+	return nil
+}
+
+// quantize sets the number of magnitude bit-planes and the quantization step size of
+// the subband 'b' in the resolution 'r' with the decomposition level 'nb'.
+func (tc *tileComponent) quantize(b *subband, r, nb int) error {
+	q := tc.quant
+	var exponent, mantissa int
+	switch q.style {
+	case quantizationScalarDerived:
+		exponent = q.exponents[0] - tc.style.levels + nb
+		mantissa = q.mantissas[0]
+	default:
+		i := 0
+		if r > 0 {
+			i = 3*(r-1) + b.kind
+		}
+		if i >= len(q.exponents) {
+			return errors.New("jpx: missing quantization parameters")
+		}
+		exponent = q.exponents[i]
+		mantissa = q.mantissas[i]
+	}
+	b.numBitplanes = q.guardBits + exponent - 1 + tc.roiShift
+	if !tc.style.reversible {
+		gain := 0
+		switch b.kind {
+		case bandHL, bandLH:
+			gain = 1
+		case bandHH:
+			gain = 2
+		}
+		b.stepSize = math.Pow(2, float64(tc.precision+gain-exponent)) * (1 + float64(mantissa)/2048)
+	}
+	return nil
+}
+
+// buildPrecincts partitions the subband into the code-blocks of each precinct of the
+// resolution 'res'. The 'ppx' and 'ppy' are the precinct size exponents in the subband.
+func (b *subband) buildPrecincts(res *resolution, ppx, ppy int) {
+	b.precincts = make([]*precinctBand, res.numPrecW*res.numPrecH)
+	for py := 0; py < res.numPrecH; py++ {
+		for px := 0; px < res.numPrecW; px++ {
+			// The precinct area within the subband.
+			x0 := max((res.precX0+px)<<uint(ppx), b.x0)
+			y0 := max((res.precY0+py)<<uint(ppy), b.y0)
+			x1 := min((res.precX0+px+1)<<uint(ppx), b.x1)
+			y1 := min((res.precY0+py+1)<<uint(ppy), b.y1)
+			pb := &precinctBand{}
+			b.precincts[py*res.numPrecW+px] = pb
+			if x1 <= x0 || y1 <= y0 {
+				continue
+			}
+			cbx0 := x0 >> uint(b.xcb)
+			cby0 := y0 >> uint(b.ycb)
+			cbx1 := ceilDivPow2(x1, b.xcb)
+			cby1 := ceilDivPow2(y1, b.ycb)
+			pb.numW = cbx1 - cbx0
+			pb.numH = cby1 - cby0
+			for j := cby0; j < cby1; j++ {
+				for i := cbx0; i < cbx1; i++ {
+					pb.blocks = append(pb.blocks, &codeBlock{
+						x0:     max(i<<uint(b.xcb), x0),
+						y0:     max(j<<uint(b.ycb), y0),
+						x1:     min((i+1)<<uint(b.xcb), x1),
+						y1:     min((j+1)<<uint(b.ycb), y1),
+						lblock: 3,
+					})
+				}
+			}
+			pb.inclusion = newTagTree(pb.numW, pb.numH)
+			pb.zeroBitplanes = newTagTree(pb.numW, pb.numH)
+		}
+	}
+}
+
+// ceilDivPow2 returns ceil(a / 2^n) for any integer 'a'.
+func ceilDivPow2(a, n int) int {
+	return -((-a) >> uint(n))
+}
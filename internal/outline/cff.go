@@ -0,0 +1,817 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errCFFFormat = errors.New("cff: invalid font data")
+
+// DICT operators (The Compact Font Format Specification, Appendix H and
+// The CFF2 Charstring Format). Two byte operators are stored as 1200 plus
+// the second byte.
+const (
+	opCharset       = 15
+	opEncoding      = 16
+	opCharStrings   = 17
+	opPrivate       = 18
+	opSubrs         = 19
+	opDefaultWidthX = 20
+	opNominalWidthX = 21
+	opVSIndex       = 22
+	opBlend         = 23
+	opVStore        = 24
+	opFontMatrix    = 1207
+	opROS           = 1230
+	opFDArray       = 1236
+	opFDSelect      = 1237
+)
+
+// CFFFont represents a Compact Font Format font program, either in CFF or
+// CFF2 format. Only the default instance of CFF2 variable fonts is
+// supported.
+type CFFFont struct {
+	// Encoding is the built-in encoding of name-keyed fonts, mapping
+	// character codes to glyph names.
+	Encoding map[byte]string
+
+	data     []byte
+	cff2     bool
+	cidKeyed bool
+	strings  [][]byte
+
+	// m is the top level font matrix. The font matrix of the font dicts of
+	// CID-keyed fonts is applied on top of it, if present.
+	m         matrix
+	hasMatrix bool
+
+	charStrings [][]byte
+	gsubrs      [][]byte
+
+	// charset maps glyph identifiers to string identifiers for name-keyed
+	// fonts and to CIDs for CID-keyed fonts.
+	charset   []int
+	nameToGID map[string]int
+	cidToGID  map[int]int
+
+	fds      []*cffFontDict
+	fdSelect []int
+
+	// Number of regions of the item variation data subtables of CFF2 fonts.
+	regionCounts []int
+}
+
+// cffFontDict contains the private data used by the charstrings of a font
+// dict.
+type cffFontDict struct {
+	m             matrix
+	subrs         [][]byte
+	defaultWidthX float64
+	nominalWidthX float64
+	vsindex       int
+}
+
+// ParseCFF parses the CFF or CFF2 font program in `data`, as found in
+// FontFile3 streams with the Type1C or CIDFontType0C subtypes.
+func ParseCFF(data []byte) (*CFFFont, error) {
+	r := &cffReader{data: data}
+	major := r.u8()
+	r.u8()
+	hdrSize := r.u8()
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	font := &CFFFont{
+		data:      data,
+		m:         defaultMatrix,
+		nameToGID: map[string]int{},
+		cidToGID:  map[int]int{},
+	}
+
+	var top cffDict
+	switch major {
+	case 1:
+		r.seek(hdrSize)
+		r.index(false)
+		topDicts := r.index(false)
+		font.strings = r.index(false)
+		font.gsubrs = r.index(false)
+		if r.err != nil {
+			return nil, r.err
+		}
+		if len(topDicts) == 0 {
+			return nil, errors.New("cff: missing top dict")
+		}
+
+		dict, err := parseDict(topDicts[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		top = dict
+	case 2:
+		font.cff2 = true
+		topSize := r.u16()
+		r.seek(hdrSize)
+		topData := r.bytes(topSize)
+		font.gsubrs = r.index(true)
+		if r.err != nil {
+			return nil, r.err
+		}
+
+		dict, err := parseDict(topData, nil)
+		if err != nil {
+			return nil, err
+		}
+		top = dict
+	default:
+		return nil, fmt.Errorf("cff: unsupported version %d", major)
+	}
+
+	if err := font.load(top); err != nil {
+		return nil, err
+	}
+	return font, nil
+}
+
+// ParseOpenType parses the CFF or CFF2 table of the OpenType font program in
+// `data`, as found in FontFile3 streams with the OpenType subtype.
+func ParseOpenType(data []byte) (*CFFFont, error) {
+	r := &cffReader{data: data}
+	r.u32()
+	numTables := r.u16()
+	r.bytes(6)
+
+	for i := 0; i < numTables && r.err == nil; i++ {
+		tag := string(r.bytes(4))
+		r.u32()
+		offset := r.u32()
+		length := r.u32()
+		if tag != "CFF " && tag != "CFF2" {
+			continue
+		}
+		if r.err != nil || offset+length > len(data) {
+			return nil, errCFFFormat
+		}
+		return ParseCFF(data[offset : offset+length])
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return nil, errors.New("cff: OpenType font has no CFF table")
+}
+
+// IsCIDKeyed returns true if the glyphs of the font are identified by CIDs
+// rather than by names.
+func (f *CFFFont) IsCIDKeyed() bool {
+	return f.cidKeyed
+}
+
+// NumGlyphs returns the number of glyphs of the font.
+func (f *CFFFont) NumGlyphs() int {
+	return len(f.charStrings)
+}
+
+// HasGlyph returns true if the font contains the glyph with the specified
+// name.
+func (f *CFFFont) HasGlyph(name string) bool {
+	_, ok := f.nameToGID[name]
+	return ok
+}
+
+// GlyphName returns the name of the glyph with the specified glyph
+// identifier. Only name-keyed fonts have glyph names, so the name is empty
+// for CID-keyed fonts.
+func (f *CFFFont) GlyphName(gid int) string {
+	if f.cidKeyed {
+		return ""
+	}
+	return f.glyphName(gid)
+}
+
+// GlyphByName returns the glyph with the specified name. Only name-keyed
+// fonts have glyph names.
+func (f *CFFFont) GlyphByName(name string) (*Glyph, error) {
+	gid, ok := f.nameToGID[name]
+	if !ok {
+		return nil, fmt.Errorf("cff: glyph %q not found", name)
+	}
+	return f.GlyphByGID(gid)
+}
+
+// GlyphByCID returns the glyph with the specified CID. For fonts which are
+// not CID-keyed, the CID is used as glyph identifier.
+func (f *CFFFont) GlyphByCID(cid int) (*Glyph, error) {
+	if !f.cidKeyed {
+		return f.GlyphByGID(cid)
+	}
+
+	gid, ok := f.cidToGID[cid]
+	if !ok {
+		return nil, fmt.Errorf("cff: glyph for CID %d not found", cid)
+	}
+	return f.GlyphByGID(gid)
+}
+
+// GlyphByGID returns the glyph with the specified glyph identifier.
+func (f *CFFFont) GlyphByGID(gid int) (*Glyph, error) {
+	if gid < 0 || gid >= len(f.charStrings) {
+		return nil, fmt.Errorf("cff: glyph %d out of range", gid)
+	}
+
+	fd := f.fontDict(gid)
+	dec := &type2Decoder{font: f, fd: fd, b: &pathBuilder{m: fd.m}}
+	if err := dec.run(f.charStrings[gid], 0); err != nil {
+		return nil, err
+	}
+	dec.b.closePath()
+
+	var advance float64
+	if !f.cff2 {
+		width := fd.defaultWidthX
+		if dec.hasWidth {
+			width = fd.nominalWidthX + dec.width
+		}
+		advance = fd.m.transform(width, 0).X - fd.m.transform(0, 0).X
+	}
+	return &Glyph{Path: dec.b.path, Advance: advance}, nil
+}
+
+// fontDict returns the font dict used by the specified glyph.
+func (f *CFFFont) fontDict(gid int) *cffFontDict {
+	idx := 0
+	if gid < len(f.fdSelect) {
+		idx = f.fdSelect[gid]
+	}
+	if idx < 0 || idx >= len(f.fds) {
+		idx = 0
+	}
+	return f.fds[idx]
+}
+
+// load loads the font data referenced by the top dict.
+func (f *CFFFont) load(top cffDict) error {
+	if m := top[opFontMatrix]; len(m) == 6 {
+		copy(f.m[:], m)
+		f.hasMatrix = true
+	}
+
+	offset, ok := top.int(opCharStrings)
+	if !ok {
+		return errors.New("cff: missing charstrings")
+	}
+	r := &cffReader{data: f.data}
+	r.seek(offset)
+	f.charStrings = r.index(f.cff2)
+	if r.err != nil {
+		return r.err
+	}
+	if len(f.charStrings) == 0 {
+		return errors.New("cff: no charstrings")
+	}
+
+	if offset, ok := top.int(opVStore); ok && f.cff2 {
+		if err := f.parseVariationStore(offset); err != nil {
+			return err
+		}
+	}
+
+	_, f.cidKeyed = top[opROS]
+	if f.cff2 || f.cidKeyed {
+		if err := f.parseFDArray(top); err != nil {
+			return err
+		}
+	} else {
+		fd, err := f.parsePrivate(top[opPrivate], f.m)
+		if err != nil {
+			return err
+		}
+		f.fds = []*cffFontDict{fd}
+	}
+	if f.cff2 {
+		return nil
+	}
+
+	charset, _ := top.int(opCharset)
+	if err := f.parseCharset(charset); err != nil {
+		return err
+	}
+	if f.cidKeyed {
+		return nil
+	}
+
+	encoding, _ := top.int(opEncoding)
+	return f.parseEncoding(encoding)
+}
+
+// parseFDArray parses the font dicts and the font dict selector of
+// CID-keyed and CFF2 fonts.
+func (f *CFFFont) parseFDArray(top cffDict) error {
+	offset, ok := top.int(opFDArray)
+	if !ok {
+		return errors.New("cff: missing font dict array")
+	}
+	r := &cffReader{data: f.data}
+	r.seek(offset)
+	dicts := r.index(f.cff2)
+	if r.err != nil {
+		return r.err
+	}
+	if len(dicts) == 0 {
+		return errors.New("cff: empty font dict array")
+	}
+
+	for _, data := range dicts {
+		dict, err := parseDict(data, nil)
+		if err != nil {
+			return err
+		}
+
+		m := f.m
+		if fm := dict[opFontMatrix]; len(fm) == 6 {
+			copy(m[:], fm)
+			if f.hasMatrix {
+				m = m.mult(f.m)
+			}
+		}
+		fd, err := f.parsePrivate(dict[opPrivate], m)
+		if err != nil {
+			return err
+		}
+		f.fds = append(f.fds, fd)
+	}
+
+	offset, ok = top.int(opFDSelect)
+	if !ok {
+		return nil
+	}
+	return f.parseFDSelect(offset)
+}
+
+// parsePrivate parses the private dict referenced by the specified Private
+// operator operands (size and offset).
+func (f *CFFFont) parsePrivate(operands []float64, m matrix) (*cffFontDict, error) {
+	fd := &cffFontDict{m: m}
+	if len(operands) < 2 {
+		return fd, nil
+	}
+
+	size, offset := int(operands[0]), int(operands[1])
+	if size < 0 || offset < 0 || offset+size > len(f.data) {
+		return nil, errCFFFormat
+	}
+	dict, err := parseDict(f.data[offset:offset+size], f.regionCounts)
+	if err != nil {
+		return nil, err
+	}
+
+	fd.defaultWidthX = dict.number(opDefaultWidthX)
+	fd.nominalWidthX = dict.number(opNominalWidthX)
+	fd.vsindex, _ = dict.int(opVSIndex)
+	if subrs, ok := dict.int(opSubrs); ok {
+		r := &cffReader{data: f.data}
+		r.seek(offset + subrs)
+		fd.subrs = r.index(f.cff2)
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	return fd, nil
+}
+
+// parseCharset parses the charset of the font, located at `offset`.
+func (f *CFFFont) parseCharset(offset int) error {
+	n := len(f.charStrings)
+	f.charset = make([]int, n)
+
+	switch offset {
+	case 0:
+		// ISOAdobe charset.
+		for gid := range f.charset {
+			f.charset[gid] = gid
+		}
+	case 1, 2:
+		// The Expert and ExpertSubset charsets are not supported.
+		f.charset = nil
+		return nil
+	default:
+		r := &cffReader{data: f.data}
+		r.seek(offset)
+		switch format := r.u8(); format {
+		case 0:
+			for gid := 1; gid < n && r.err == nil; gid++ {
+				f.charset[gid] = r.u16()
+			}
+		case 1, 2:
+			for gid := 1; gid < n && r.err == nil; {
+				first := r.u16()
+				var left int
+				if format == 1 {
+					left = r.u8()
+				} else {
+					left = r.u16()
+				}
+				for i := 0; i <= left && gid < n; i++ {
+					f.charset[gid] = first + i
+					gid++
+				}
+			}
+		default:
+			return fmt.Errorf("cff: unsupported charset format %d", format)
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	for gid, id := range f.charset {
+		if f.cidKeyed {
+			f.cidToGID[id] = gid
+		} else {
+			f.nameToGID[f.sidString(id)] = gid
+		}
+	}
+	return nil
+}
+
+// parseEncoding parses the encoding of the font, located at `offset`.
+func (f *CFFFont) parseEncoding(offset int) error {
+	f.Encoding = map[byte]string{}
+
+	switch offset {
+	case 0:
+		for code, name := range standardEncoding() {
+			if f.HasGlyph(name) {
+				f.Encoding[code] = name
+			}
+		}
+		return nil
+	case 1:
+		// The Expert encoding is not supported.
+		return nil
+	}
+
+	r := &cffReader{data: f.data}
+	r.seek(offset)
+	format := r.u8()
+	switch format & 0x7f {
+	case 0:
+		count := r.u8()
+		for gid := 1; gid <= count && r.err == nil; gid++ {
+			f.Encoding[byte(r.u8())] = f.glyphName(gid)
+		}
+	case 1:
+		ranges := r.u8()
+		gid := 1
+		for i := 0; i < ranges && r.err == nil; i++ {
+			first, left := r.u8(), r.u8()
+			for code := first; code <= first+left && code < 256; code++ {
+				f.Encoding[byte(code)] = f.glyphName(gid)
+				gid++
+			}
+		}
+	default:
+		return fmt.Errorf("cff: unsupported encoding format %d", format&0x7f)
+	}
+
+	// Supplemental encodings map additional codes to glyphs.
+	if format&0x80 != 0 {
+		count := r.u8()
+		for i := 0; i < count && r.err == nil; i++ {
+			code, sid := r.u8(), r.u16()
+			f.Encoding[byte(code)] = f.sidString(sid)
+		}
+	}
+	return r.err
+}
+
+// parseFDSelect parses the font dict selector, located at `offset`.
+func (f *CFFFont) parseFDSelect(offset int) error {
+	n := len(f.charStrings)
+	f.fdSelect = make([]int, n)
+
+	r := &cffReader{data: f.data}
+	r.seek(offset)
+	switch format := r.u8(); format {
+	case 0:
+		for gid := 0; gid < n && r.err == nil; gid++ {
+			f.fdSelect[gid] = r.u8()
+		}
+	case 3, 4:
+		var ranges int
+		if format == 3 {
+			ranges = r.u16()
+		} else {
+			ranges = r.u32()
+		}
+
+		read := func() (int, int) {
+			if format == 3 {
+				return r.u16(), r.u8()
+			}
+			return r.u32(), r.u16()
+		}
+		first, fd := read()
+		for i := 0; i < ranges && r.err == nil; i++ {
+			var next, nextFD int
+			if i == ranges-1 {
+				// The last range is followed by a sentinel glyph identifier.
+				if format == 3 {
+					next = r.u16()
+				} else {
+					next = r.u32()
+				}
+			} else {
+				next, nextFD = read()
+			}
+			for gid := first; gid < next && gid < n; gid++ {
+				f.fdSelect[gid] = fd
+			}
+			first, fd = next, nextFD
+		}
+	default:
+		return fmt.Errorf("cff: unsupported FDSelect format %d", format)
+	}
+	return r.err
+}
+
+// parseVariationStore reads the number of regions of each item variation
+// data subtable of the variation store located at `offset`. The region
+// counts are needed to skip the deltas of blend operators.
+func (f *CFFFont) parseVariationStore(offset int) error {
+	r := &cffReader{data: f.data}
+	r.seek(offset)
+	r.u16()
+	start := r.pos
+	r.u16()
+	r.u32()
+	count := r.u16()
+	offsets := make([]int, 0, count)
+	for i := 0; i < count && r.err == nil; i++ {
+		offsets = append(offsets, r.u32())
+	}
+
+	for _, off := range offsets {
+		r.seek(start + off)
+		r.u16()
+		r.u16()
+		f.regionCounts = append(f.regionCounts, r.u16())
+	}
+	return r.err
+}
+
+// glyphName returns the name of the specified glyph.
+func (f *CFFFont) glyphName(gid int) string {
+	if gid < 0 || gid >= len(f.charset) {
+		return ""
+	}
+	return f.sidString(f.charset[gid])
+}
+
+// sidString returns the string with the specified string identifier.
+func (f *CFFFont) sidString(sid int) string {
+	if sid < len(cffStandardStrings) {
+		return cffStandardStrings[sid]
+	}
+	sid -= len(cffStandardStrings)
+	if sid < len(f.strings) {
+		return string(f.strings[sid])
+	}
+	return ""
+}
+
+// cffDict maps DICT operators to their operands.
+type cffDict map[int][]float64
+
+// int returns the first operand of the specified operator as an integer.
+func (d cffDict) int(op int) (int, bool) {
+	operands := d[op]
+	if len(operands) == 0 {
+		return 0, false
+	}
+	return int(operands[0]), true
+}
+
+// number returns the first operand of the specified operator, or 0 if the
+// operator is not present.
+func (d cffDict) number(op int) float64 {
+	operands := d[op]
+	if len(operands) == 0 {
+		return 0
+	}
+	return operands[0]
+}
+
+// parseDict parses DICT data. The region counts are used to process the
+// blend operators of CFF2 private dicts, of which only the default values
+// are kept.
+func parseDict(data []byte, regionCounts []int) (cffDict, error) {
+	dict := cffDict{}
+	var stack []float64
+	vsindex := 0
+
+	for i := 0; i < len(data); {
+		v := data[i]
+		i++
+
+		// Operands.
+		switch {
+		case v == 28:
+			if i+2 > len(data) {
+				return nil, errCFFFormat
+			}
+			stack = append(stack, float64(int16(uint16(data[i])<<8|uint16(data[i+1]))))
+			i += 2
+			continue
+		case v == 29:
+			if i+4 > len(data) {
+				return nil, errCFFFormat
+			}
+			n := int32(uint32(data[i])<<24 | uint32(data[i+1])<<16 | uint32(data[i+2])<<8 | uint32(data[i+3]))
+			stack = append(stack, float64(n))
+			i += 4
+			continue
+		case v == 30:
+			val, next, err := parseReal(data, i)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, val)
+			i = next
+			continue
+		case v >= 32 && v <= 246:
+			stack = append(stack, float64(int(v)-139))
+			continue
+		case v >= 247 && v <= 254:
+			if i >= len(data) {
+				return nil, errCFFFormat
+			}
+			if v <= 250 {
+				stack = append(stack, float64((int(v)-247)*256+int(data[i])+108))
+			} else {
+				stack = append(stack, float64(-(int(v)-251)*256-int(data[i])-108))
+			}
+			i++
+			continue
+		case v == 255:
+			return nil, errCFFFormat
+		}
+
+		// Operators.
+		op := int(v)
+		if v == 12 {
+			if i >= len(data) {
+				return nil, errCFFFormat
+			}
+			op = 1200 + int(data[i])
+			i++
+		}
+
+		switch op {
+		case opVSIndex:
+			if len(stack) > 0 {
+				vsindex = int(stack[0])
+			}
+		case opBlend:
+			if len(stack) == 0 {
+				return nil, errStackUnderflow
+			}
+			n := int(stack[len(stack)-1])
+			regions := 0
+			if vsindex >= 0 && vsindex < len(regionCounts) {
+				regions = regionCounts[vsindex]
+			}
+			count := n*(regions+1) + 1
+			if n < 0 || count > len(stack) {
+				return nil, errStackUnderflow
+			}
+			stack = stack[:len(stack)-count+n]
+			continue
+		}
+		dict[op] = stack
+		stack = nil
+	}
+
+	return dict, nil
+}
+
+// parseReal parses a real number operand starting at position `i`. Returns
+// the number and the position following it.
+func parseReal(data []byte, i int) (float64, int, error) {
+	var sb strings.Builder
+	for i < len(data) {
+		b := data[i]
+		i++
+		for _, nibble := range [2]byte{b >> 4, b & 0x0f} {
+			switch {
+			case nibble <= 9:
+				sb.WriteByte('0' + nibble)
+			case nibble == 0x0a:
+				sb.WriteByte('.')
+			case nibble == 0x0b:
+				sb.WriteByte('E')
+			case nibble == 0x0c:
+				sb.WriteString("E-")
+			case nibble == 0x0e:
+				sb.WriteByte('-')
+			case nibble == 0x0f:
+				if sb.Len() == 0 {
+					return 0, i, nil
+				}
+				val, err := strconv.ParseFloat(sb.String(), 64)
+				return val, i, err
+			}
+		}
+	}
+	return 0, i, errCFFFormat
+}
+
+// cffReader reads the binary structures of CFF data. Reading past the end of
+// the data sets the error of the reader, which is returned by the callers
+// once a structure is read.
+type cffReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *cffReader) seek(pos int) {
+	if pos < 0 || pos > len(r.data) {
+		r.err = errCFFFormat
+		return
+	}
+	r.pos = pos
+}
+
+func (r *cffReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = errCFFFormat
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *cffReader) offset(size int) int {
+	var v int
+	for _, b := range r.bytes(size) {
+		v = v<<8 | int(b)
+	}
+	return v
+}
+
+func (r *cffReader) u8() int  { return r.offset(1) }
+func (r *cffReader) u16() int { return r.offset(2) }
+func (r *cffReader) u32() int { return r.offset(4) }
+
+// index reads an INDEX structure. The count of CFF2 INDEX structures is
+// stored using 4 bytes instead of 2.
+func (r *cffReader) index(cff2 bool) [][]byte {
+	var count int
+	if cff2 {
+		count = r.u32()
+	} else {
+		count = r.u16()
+	}
+	if count == 0 || r.err != nil {
+		return nil
+	}
+	offSize := r.u8()
+	if offSize < 1 || offSize > 4 || count > len(r.data) {
+		r.err = errCFFFormat
+		return nil
+	}
+
+	offsets := make([]int, count+1)
+	for i := range offsets {
+		offsets[i] = r.offset(offSize)
+	}
+	if r.err != nil {
+		return nil
+	}
+
+	// Offsets are relative to the byte preceding the object data.
+	base := r.pos - 1
+	items := make([][]byte, count)
+	for i := range items {
+		start, end := base+offsets[i], base+offsets[i+1]
+		if offsets[i] < 1 || end < start || end > len(r.data) {
+			r.err = errCFFFormat
+			return nil
+		}
+		items[i] = r.data[start:end]
+	}
+	r.pos = base + offsets[count]
+	return items
+}
@@ -0,0 +1,184 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// cffIndex returns an INDEX structure containing the specified items.
+func cffIndex(items ...[]byte) []byte {
+	if len(items) == 0 {
+		return []byte{0, 0}
+	}
+
+	out := []byte{byte(len(items) >> 8), byte(len(items)), 2}
+	offset := 1
+	out = append(out, byte(offset>>8), byte(offset))
+	var data []byte
+	for _, item := range items {
+		offset += len(item)
+		out = append(out, byte(offset>>8), byte(offset))
+		data = append(data, item...)
+	}
+	return append(out, data...)
+}
+
+// dictInt returns the 5 byte DICT encoding of `v`.
+func dictInt(v int) []byte {
+	out := []byte{29, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(out[1:], uint32(v))
+	return out
+}
+
+// testCFFFont returns a name-keyed CFF font containing a square glyph
+// named A, drawn partially by a global subroutine.
+func testCFFFont() []byte {
+	header := []byte{1, 0, 4, 1}
+	names := cffIndex([]byte("Test"))
+	strs := cffIndex()
+	// 0 -300 rlineto return
+	gsubrs := cffIndex([]byte{139, 251, 192, 5, 11})
+
+	// Charset format 0, with glyph 1 named A (SID 34).
+	charset := []byte{0, 0, 34}
+	charStrings := cffIndex(
+		// endchar
+		[]byte{14},
+		// 500 100 100 rmoveto 300 300 -300 hlineto -107 callgsubr endchar
+		[]byte{248, 136, 239, 239, 21, 247, 192, 247, 192, 251, 192, 6, 32, 29, 14},
+	)
+	// 250 defaultWidthX 100 nominalWidthX
+	private := []byte{247, 142, 20, 239, 21}
+
+	// The top dict uses fixed size operands, so that its size is known
+	// before the offsets are computed.
+	topDict := func(charsetOffset, charStringsOffset, privateOffset int) []byte {
+		var dict []byte
+		dict = append(dict, dictInt(charsetOffset)...)
+		dict = append(dict, opCharset)
+		dict = append(dict, dictInt(charStringsOffset)...)
+		dict = append(dict, opCharStrings)
+		dict = append(dict, dictInt(len(private))...)
+		dict = append(dict, dictInt(privateOffset)...)
+		return append(dict, opPrivate)
+	}
+	topSize := len(cffIndex(topDict(0, 0, 0)))
+
+	charsetOffset := len(header) + len(names) + topSize + len(strs) + len(gsubrs)
+	charStringsOffset := charsetOffset + len(charset)
+	privateOffset := charStringsOffset + len(charStrings)
+
+	var data []byte
+	data = append(data, header...)
+	data = append(data, names...)
+	data = append(data, cffIndex(topDict(charsetOffset, charStringsOffset, privateOffset))...)
+	data = append(data, strs...)
+	data = append(data, gsubrs...)
+	data = append(data, charset...)
+	data = append(data, charStrings...)
+	return append(data, private...)
+}
+
+func TestCFFGlyphs(t *testing.T) {
+	font, err := ParseCFF(testCFFFont())
+	require.NoError(t, err)
+	require.False(t, font.IsCIDKeyed())
+	require.Equal(t, 2, font.NumGlyphs())
+	require.Equal(t, "A", font.Encoding[65])
+	require.True(t, font.HasGlyph("A"))
+
+	glyph, err := font.GlyphByName("A")
+	require.NoError(t, err)
+	require.InDelta(t, 0.6, glyph.Advance, 1e-9)
+
+	expected := []struct {
+		typ SegmentType
+		p   Point
+	}{
+		{SegmentMoveTo, Point{0.1, 0.1}},
+		{SegmentLineTo, Point{0.4, 0.1}},
+		{SegmentLineTo, Point{0.4, 0.4}},
+		{SegmentLineTo, Point{0.1, 0.4}},
+		{SegmentLineTo, Point{0.1, 0.1}},
+		{SegmentClose, Point{}},
+	}
+	require.Len(t, glyph.Path, len(expected))
+	for i, e := range expected {
+		require.Equal(t, e.typ, glyph.Path[i].Type)
+		require.InDelta(t, e.p.X, glyph.Path[i].Points[0].X, 1e-9)
+		require.InDelta(t, e.p.Y, glyph.Path[i].Points[0].Y, 1e-9)
+	}
+
+	// Glyphs without an explicit width use the default width.
+	notdef, err := font.GlyphByGID(0)
+	require.NoError(t, err)
+	require.InDelta(t, 0.25, notdef.Advance, 1e-9)
+	require.Empty(t, notdef.Path)
+
+	_, err = font.GlyphByGID(2)
+	require.Error(t, err)
+}
+
+func TestCFFOpenType(t *testing.T) {
+	cff := testCFFFont()
+
+	data := []byte("OTTO")
+	data = append(data, 0, 1, 0, 0, 0, 0, 0, 0)
+	record := make([]byte, 16)
+	copy(record, "CFF ")
+	binary.BigEndian.PutUint32(record[8:], uint32(len(data)+len(record)))
+	binary.BigEndian.PutUint32(record[12:], uint32(len(cff)))
+	data = append(data, record...)
+	data = append(data, cff...)
+
+	font, err := ParseOpenType(data)
+	require.NoError(t, err)
+	require.True(t, font.HasGlyph("A"))
+
+	_, err = ParseOpenType(data[:28])
+	require.Error(t, err)
+}
+
+func TestCFFDictOperands(t *testing.T) {
+	data := []byte{
+		// -2.25 (real)
+		30, 0xe2, 0xa2, 0x5f,
+		// 0.001 (real)
+		30, 0x0a, 0x00, 0x1f,
+		// 1000 and -1000
+		28, 0x03, 0xe8, 254, 124,
+		// 12 7 (FontMatrix)
+		12, 7,
+	}
+	dict, err := parseDict(data, nil)
+	require.NoError(t, err)
+	require.Equal(t, []float64{-2.25, 0.001, 1000, -1000}, dict[opFontMatrix])
+
+	// Blend operators keep the default values only.
+	data = []byte{
+		// 1 vsindex
+		140, 22,
+		// 10 20 1 2 3 4 2 blend 19 (Subrs)
+		149, 159, 140, 141, 142, 143, 141, 23, 19,
+	}
+	dict, err = parseDict(data, []int{0, 2})
+	require.NoError(t, err)
+	require.Equal(t, []float64{10, 20}, dict[opSubrs])
+}
+
+func TestType2Arithmetic(t *testing.T) {
+	font := &CFFFont{}
+	dec := &type2Decoder{font: font, fd: &cffFontDict{}, b: &pathBuilder{m: defaultMatrix}}
+
+	// 6 4 sub 3 mul 2 2 1 roll exch div
+	err := dec.run([]byte{145, 143, 12, 11, 142, 12, 24, 141, 141, 140, 12, 30, 12, 28, 12, 12}, 0)
+	require.NoError(t, err)
+	require.Equal(t, []float64{3}, dec.stack)
+}
@@ -0,0 +1,13 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package outline extracts the glyph outlines of the font programs embedded
+// in PDF files. It parses Type 1 fonts (FontFile streams), including the
+// eexec encrypted private dictionary and Type 1 charstrings, and Compact
+// Font Format fonts (FontFile3 streams) using Type 2 charstrings, either
+// bare or wrapped in OpenType files, name-keyed or CID-keyed, as well as
+// the default instance of CFF2 fonts. The outlines are expressed in text
+// space, with the font matrix of the font program already applied.
+package outline
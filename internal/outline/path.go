@@ -0,0 +1,133 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+// Point represents a point of a glyph outline.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// SegmentType represents the type of the segments of glyph outlines.
+type SegmentType int
+
+// Segment types.
+const (
+	SegmentMoveTo SegmentType = iota
+	SegmentLineTo
+	SegmentCubicTo
+	SegmentClose
+)
+
+// Segment represents a segment of a glyph outline. Move and line segments
+// use the first point, cubic Bézier curves use all three points: the two
+// control points followed by the end point.
+type Segment struct {
+	Type   SegmentType
+	Points [3]Point
+}
+
+// Path represents the outline of a glyph.
+type Path []Segment
+
+// Glyph represents a glyph of a font program.
+type Glyph struct {
+	// Path is the outline of the glyph, in text space units.
+	Path Path
+
+	// Advance is the horizontal displacement of the glyph, in text space
+	// units.
+	Advance float64
+}
+
+// matrix represents a font matrix, laid out as [a b c d e f].
+type matrix [6]float64
+
+// defaultMatrix is the font matrix used by font programs which do not
+// specify one.
+var defaultMatrix = matrix{0.001, 0, 0, 0.001, 0, 0}
+
+// transform returns the point x, y transformed by `m`.
+func (m matrix) transform(x, y float64) Point {
+	return Point{
+		X: m[0]*x + m[2]*y + m[4],
+		Y: m[1]*x + m[3]*y + m[5],
+	}
+}
+
+// mult returns the matrix obtained by applying `m` and then `b`.
+func (m matrix) mult(b matrix) matrix {
+	return matrix{
+		m[0]*b[0] + m[1]*b[2], m[0]*b[1] + m[1]*b[3],
+		m[2]*b[0] + m[3]*b[2], m[2]*b[1] + m[3]*b[3],
+		m[4]*b[0] + m[5]*b[2] + b[4], m[4]*b[1] + m[5]*b[3] + b[5],
+	}
+}
+
+// pathBuilder builds glyph outlines in glyph space and converts them to
+// text space using a font matrix.
+type pathBuilder struct {
+	m       matrix
+	path    Path
+	open    bool
+	x, y    float64
+	offsetX float64
+	offsetY float64
+}
+
+func (b *pathBuilder) moveTo(x, y float64) {
+	b.closePath()
+	b.x, b.y = x, y
+	b.path = append(b.path, Segment{
+		Type:   SegmentMoveTo,
+		Points: [3]Point{b.point(x, y)},
+	})
+	b.open = true
+}
+
+func (b *pathBuilder) lineTo(x, y float64) {
+	if !b.open {
+		b.moveTo(b.x, b.y)
+	}
+	b.x, b.y = x, y
+	b.path = append(b.path, Segment{
+		Type:   SegmentLineTo,
+		Points: [3]Point{b.point(x, y)},
+	})
+}
+
+func (b *pathBuilder) cubicTo(x1, y1, x2, y2, x3, y3 float64) {
+	if !b.open {
+		b.moveTo(b.x, b.y)
+	}
+	b.x, b.y = x3, y3
+	b.path = append(b.path, Segment{
+		Type:   SegmentCubicTo,
+		Points: [3]Point{b.point(x1, y1), b.point(x2, y2), b.point(x3, y3)},
+	})
+}
+
+// rcurveTo adds a cubic Bézier curve specified by coordinates relative to
+// the current point and to the previous control point.
+func (b *pathBuilder) rcurveTo(dx1, dy1, dx2, dy2, dx3, dy3 float64) {
+	x1, y1 := b.x+dx1, b.y+dy1
+	x2, y2 := x1+dx2, y1+dy2
+	x3, y3 := x2+dx3, y2+dy3
+	b.cubicTo(x1, y1, x2, y2, x3, y3)
+}
+
+func (b *pathBuilder) closePath() {
+	if !b.open {
+		return
+	}
+	b.path = append(b.path, Segment{Type: SegmentClose})
+	b.open = false
+}
+
+// point converts the specified glyph space point to text space.
+func (b *pathBuilder) point(x, y float64) Point {
+	return b.m.transform(x+b.offsetX, y+b.offsetY)
+}
@@ -0,0 +1,430 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Encryption keys of Type 1 fonts (Adobe Type 1 Font Format, chapter 7).
+const (
+	eexecKey      = 55665
+	charstringKey = 4330
+)
+
+// Type1Font represents a Type 1 font program.
+type Type1Font struct {
+	// Encoding is the built-in encoding of the font, mapping character
+	// codes to glyph names.
+	Encoding map[byte]string
+
+	m           matrix
+	lenIV       int
+	subrs       [][]byte
+	charStrings map[string][]byte
+}
+
+// ParseType1 parses the Type 1 font program in `data`. The data can be
+// either in PFA or PFB format, as found in FontFile streams.
+func ParseType1(data []byte) (*Type1Font, error) {
+	data = stripPFB(data)
+
+	// The font program consists of a clear text portion followed by the
+	// eexec encrypted portion.
+	idx := bytes.Index(data, []byte("eexec"))
+	if idx < 0 {
+		return nil, errors.New("type1: eexec section not found")
+	}
+	clear, encrypted := data[:idx], data[idx+len("eexec"):]
+	for len(encrypted) > 0 && isSpace(encrypted[0]) {
+		encrypted = encrypted[1:]
+	}
+	if isHex(encrypted) {
+		encrypted = decodeHex(encrypted)
+	}
+	private := decrypt(encrypted, eexecKey, 4)
+
+	font := &Type1Font{
+		m:           defaultMatrix,
+		lenIV:       4,
+		charStrings: map[string][]byte{},
+	}
+	if err := font.parseClearText(clear); err != nil {
+		return nil, err
+	}
+	if err := font.parsePrivate(private); err != nil {
+		return nil, err
+	}
+	if len(font.charStrings) == 0 {
+		return nil, errors.New("type1: no charstrings")
+	}
+
+	return font, nil
+}
+
+// GlyphByName returns the glyph with the specified name.
+func (f *Type1Font) GlyphByName(name string) (*Glyph, error) {
+	charString, ok := f.charStrings[name]
+	if !ok {
+		return nil, fmt.Errorf("type1: glyph %q not found", name)
+	}
+
+	dec := &type1Decoder{font: f, b: &pathBuilder{m: f.m}}
+	if err := dec.run(charString, 0); err != nil {
+		return nil, err
+	}
+	dec.b.closePath()
+
+	width := f.m.transform(dec.wx, dec.wy)
+	origin := f.m.transform(0, 0)
+	return &Glyph{Path: dec.b.path, Advance: width.X - origin.X}, nil
+}
+
+// HasGlyph returns true if the font contains the glyph with the specified
+// name.
+func (f *Type1Font) HasGlyph(name string) bool {
+	_, ok := f.charStrings[name]
+	return ok
+}
+
+// parseClearText parses the font matrix and the encoding of the font from
+// the clear text portion of the font program.
+func (f *Type1Font) parseClearText(data []byte) error {
+	lex := &type1Lexer{data: data}
+	for {
+		tok := lex.token()
+		if tok == nil {
+			return nil
+		}
+
+		switch string(tok) {
+		case "/FontMatrix":
+			vals, err := lex.numberArray()
+			if err != nil {
+				return err
+			}
+			if len(vals) != 6 {
+				return errors.New("type1: invalid font matrix")
+			}
+			copy(f.m[:], vals)
+		case "/Encoding":
+			f.Encoding = parseType1Encoding(lex)
+		}
+	}
+}
+
+// parseType1Encoding parses the encoding defined after the /Encoding key.
+func parseType1Encoding(lex *type1Lexer) map[byte]string {
+	tok := lex.token()
+	if string(tok) == "StandardEncoding" {
+		return standardEncoding()
+	}
+
+	// Custom encodings are defined using entries of the form
+	// `dup code /name put`, ending with `readonly def` or `def`.
+	encoding := map[byte]string{}
+	for tok != nil && string(tok) != "def" {
+		if string(tok) == "dup" {
+			code, err := strconv.Atoi(string(lex.token()))
+			name := lex.token()
+			if err == nil && code >= 0 && code < 256 && len(name) > 1 && name[0] == '/' {
+				encoding[byte(code)] = string(name[1:])
+			}
+		}
+		tok = lex.token()
+	}
+	return encoding
+}
+
+// parsePrivate parses the subroutines and the charstrings of the font from
+// the decrypted portion of the font program.
+func (f *Type1Font) parsePrivate(data []byte) error {
+	lex := &type1Lexer{data: data}
+	for {
+		tok := lex.token()
+		if tok == nil {
+			return nil
+		}
+
+		switch string(tok) {
+		case "/lenIV":
+			if n, err := strconv.Atoi(string(lex.token())); err == nil {
+				f.lenIV = n
+			}
+		case "/Subrs":
+			count, err := strconv.Atoi(string(lex.token()))
+			if err != nil || count < 0 {
+				return errors.New("type1: invalid Subrs count")
+			}
+			f.subrs = make([][]byte, count)
+
+			// Entries have the form `dup index length RD <binary> NP`.
+			for {
+				tok := lex.peek()
+				if string(tok) == "array" || string(tok) == "NP" || string(tok) == "|" ||
+					string(tok) == "noaccess" || string(tok) == "put" {
+					lex.token()
+					continue
+				}
+				if string(tok) != "dup" {
+					break
+				}
+				lex.token()
+
+				index, err := strconv.Atoi(string(lex.token()))
+				if err != nil {
+					return errors.New("type1: invalid subroutine index")
+				}
+				bin, err := lex.binary()
+				if err != nil {
+					return err
+				}
+				if index >= 0 && index < count {
+					f.subrs[index] = bin
+				}
+			}
+		case "/CharStrings":
+			// Entries have the form `/name length RD <binary> ND`, ending
+			// with `end`.
+			for {
+				tok := lex.token()
+				if tok == nil || string(tok) == "end" {
+					break
+				}
+				if len(tok) < 2 || tok[0] != '/' {
+					continue
+				}
+
+				bin, err := lex.binary()
+				if err != nil {
+					return err
+				}
+				f.charStrings[string(tok[1:])] = bin
+			}
+		}
+	}
+}
+
+// decryptCharString returns the decrypted charstring.
+func (f *Type1Font) decryptCharString(data []byte) []byte {
+	if f.lenIV < 0 {
+		return data
+	}
+	return decrypt(data, charstringKey, f.lenIV)
+}
+
+// type1Lexer tokenizes the PostScript code of Type 1 fonts.
+type type1Lexer struct {
+	data []byte
+	pos  int
+}
+
+// token returns the next token. Returns nil when the end of the data is
+// reached.
+func (l *type1Lexer) token() []byte {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isSpace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+	if l.pos >= len(l.data) {
+		return nil
+	}
+
+	start := l.pos
+	switch c := l.data[l.pos]; c {
+	case '[', ']', '{', '}':
+		l.pos++
+		return l.data[start:l.pos]
+	case '(':
+		// Skip strings, taking nested parentheses into account.
+		depth := 0
+		for l.pos < len(l.data) {
+			c := l.data[l.pos]
+			l.pos++
+			switch c {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if l.pos > len(l.data) {
+			l.pos = len(l.data)
+		}
+		return l.data[start:l.pos]
+	}
+
+	l.pos++
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// peek returns the next token without consuming it.
+func (l *type1Lexer) peek() []byte {
+	pos := l.pos
+	tok := l.token()
+	l.pos = pos
+	return tok
+}
+
+// numberArray reads an array of numbers.
+func (l *type1Lexer) numberArray() ([]float64, error) {
+	if tok := l.token(); string(tok) != "[" && string(tok) != "{" {
+		return nil, errors.New("type1: array expected")
+	}
+
+	var vals []float64
+	for {
+		tok := l.token()
+		if tok == nil {
+			return nil, errors.New("type1: unterminated array")
+		}
+		if string(tok) == "]" || string(tok) == "}" {
+			return vals, nil
+		}
+		val, err := strconv.ParseFloat(string(tok), 64)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+}
+
+// binary reads binary data of the form `length RD <binary>`. The RD token
+// is followed by a single space, after which the binary data starts.
+func (l *type1Lexer) binary() ([]byte, error) {
+	n, err := strconv.Atoi(string(l.token()))
+	if err != nil || n < 0 {
+		return nil, errors.New("type1: invalid binary data length")
+	}
+	if l.token() == nil {
+		return nil, errors.New("type1: unexpected end of data")
+	}
+
+	start := l.pos + 1
+	end := start + n
+	if end > len(l.data) {
+		return nil, errors.New("type1: binary data out of range")
+	}
+	l.pos = end
+	return l.data[start:end], nil
+}
+
+// stripPFB removes the segment headers of fonts in PFB format.
+func stripPFB(data []byte) []byte {
+	if len(data) < 6 || data[0] != 0x80 {
+		return data
+	}
+
+	var out []byte
+	for len(data) >= 6 && data[0] == 0x80 && data[1] != 3 {
+		n := int(data[2]) | int(data[3])<<8 | int(data[4])<<16 | int(data[5])<<24
+		data = data[6:]
+		if n > len(data) {
+			n = len(data)
+		}
+		out = append(out, data[:n]...)
+		data = data[n:]
+	}
+	return out
+}
+
+// decrypt decrypts the data using the specified key and discards the
+// first `skip` bytes of the result (Adobe Type 1 Font Format, section 7.2).
+func decrypt(data []byte, key uint16, skip int) []byte {
+	const c1, c2 = 52845, 22719
+
+	r := key
+	out := make([]byte, len(data))
+	for i, c := range data {
+		out[i] = c ^ byte(r>>8)
+		r = (uint16(c)+r)*c1 + c2
+	}
+	if skip > len(out) {
+		return nil
+	}
+	return out[skip:]
+}
+
+// isHex returns true if the encrypted portion of the font is encoded in
+// hexadecimal format. The first four bytes are checked, as specified in
+// section 7.2 of the Adobe Type 1 Font Format specification.
+func isHex(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	for _, c := range data[:4] {
+		if !isHexDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeHex decodes hexadecimal data, ignoring white space.
+func decodeHex(data []byte) []byte {
+	out := make([]byte, 0, len(data)/2)
+	var cur byte
+	high := true
+	for _, c := range data {
+		var v byte
+		switch {
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		case isSpace(c):
+			continue
+		default:
+			return out
+		}
+		if high {
+			cur = v << 4
+		} else {
+			out = append(out, cur|v)
+		}
+		high = !high
+	}
+	return out
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
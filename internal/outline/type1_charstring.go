@@ -0,0 +1,357 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+import (
+	"errors"
+)
+
+// Limits guarding against malformed charstrings.
+const (
+	maxSubrDepth  = 10
+	maxStackDepth = 48
+)
+
+var (
+	errStackUnderflow = errors.New("charstring: stack underflow")
+	errStackOverflow  = errors.New("charstring: stack overflow")
+	errSubrDepth      = errors.New("charstring: subroutine nesting too deep")
+	errInvalidSubr    = errors.New("charstring: invalid subroutine")
+)
+
+// type1Decoder interprets Type 1 charstrings (Adobe Type 1 Font Format,
+// chapter 6).
+type type1Decoder struct {
+	font *Type1Font
+	b    *pathBuilder
+
+	stack   []float64
+	psStack []float64
+
+	// Width and side bearing of the glyph.
+	sbx, sby float64
+	wx, wy   float64
+
+	// Flex state.
+	flexing    bool
+	flexPoints []Point
+
+	seac bool
+	done bool
+}
+
+// run interprets the specified encrypted charstring.
+func (d *type1Decoder) run(charString []byte, depth int) error {
+	if depth > maxSubrDepth {
+		return errSubrDepth
+	}
+
+	data := d.font.decryptCharString(charString)
+	for i := 0; i < len(data) && !d.done; {
+		v := data[i]
+		i++
+
+		// Numbers.
+		switch {
+		case v >= 32 && v <= 246:
+			if err := d.push(float64(int(v) - 139)); err != nil {
+				return err
+			}
+			continue
+		case v >= 247 && v <= 250:
+			if i >= len(data) {
+				return errStackUnderflow
+			}
+			if err := d.push(float64((int(v)-247)*256 + int(data[i]) + 108)); err != nil {
+				return err
+			}
+			i++
+			continue
+		case v >= 251 && v <= 254:
+			if i >= len(data) {
+				return errStackUnderflow
+			}
+			if err := d.push(float64(-(int(v)-251)*256 - int(data[i]) - 108)); err != nil {
+				return err
+			}
+			i++
+			continue
+		case v == 255:
+			if i+4 > len(data) {
+				return errStackUnderflow
+			}
+			n := int32(uint32(data[i])<<24 | uint32(data[i+1])<<16 | uint32(data[i+2])<<8 | uint32(data[i+3]))
+			if err := d.push(float64(n)); err != nil {
+				return err
+			}
+			i += 4
+			continue
+		}
+
+		// Commands.
+		op := int(v)
+		if v == 12 {
+			if i >= len(data) {
+				return errStackUnderflow
+			}
+			op = 1200 + int(data[i])
+			i++
+		}
+
+		clear := true
+		switch op {
+		case 1, 3, 1200, 1201, 1202: // hstem, vstem, dotsection, vstem3, hstem3
+		case 4: // vmoveto
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.rmoveto(0, d.stack[0])
+		case 5: // rlineto
+			if len(d.stack) < 2 {
+				return errStackUnderflow
+			}
+			d.b.lineTo(d.b.x+d.stack[0], d.b.y+d.stack[1])
+		case 6: // hlineto
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.b.lineTo(d.b.x+d.stack[0], d.b.y)
+		case 7: // vlineto
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.b.lineTo(d.b.x, d.b.y+d.stack[0])
+		case 8: // rrcurveto
+			if len(d.stack) < 6 {
+				return errStackUnderflow
+			}
+			s := d.stack
+			d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+		case 9: // closepath
+			d.b.closePath()
+		case 10: // callsubr
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			n := int(d.pop())
+			if n < 0 || n >= len(d.font.subrs) || d.font.subrs[n] == nil {
+				return errInvalidSubr
+			}
+			if err := d.run(d.font.subrs[n], depth+1); err != nil {
+				return err
+			}
+			clear = false
+		case 11: // return
+			return nil
+		case 13: // hsbw
+			if len(d.stack) < 2 {
+				return errStackUnderflow
+			}
+			d.setSideBearing(d.stack[0], 0, d.stack[1], 0)
+		case 14: // endchar
+			d.b.closePath()
+			d.done = true
+		case 21: // rmoveto
+			if len(d.stack) < 2 {
+				return errStackUnderflow
+			}
+			d.rmoveto(d.stack[0], d.stack[1])
+		case 22: // hmoveto
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.rmoveto(d.stack[0], 0)
+		case 30: // vhcurveto
+			if len(d.stack) < 4 {
+				return errStackUnderflow
+			}
+			s := d.stack
+			d.b.rcurveTo(0, s[0], s[1], s[2], s[3], 0)
+		case 31: // hvcurveto
+			if len(d.stack) < 4 {
+				return errStackUnderflow
+			}
+			s := d.stack
+			d.b.rcurveTo(s[0], 0, s[1], s[2], 0, s[3])
+		case 1206: // seac
+			if len(d.stack) < 5 {
+				return errStackUnderflow
+			}
+			s := d.stack
+			if err := d.composite(s[0], s[1], s[2], int(s[3]), int(s[4])); err != nil {
+				return err
+			}
+			d.done = true
+		case 1207: // sbw
+			if len(d.stack) < 4 {
+				return errStackUnderflow
+			}
+			s := d.stack
+			d.setSideBearing(s[0], s[1], s[2], s[3])
+		case 1212: // div
+			if len(d.stack) < 2 {
+				return errStackUnderflow
+			}
+			b, a := d.pop(), d.pop()
+			if b == 0 {
+				return errors.New("charstring: division by zero")
+			}
+			if err := d.push(a / b); err != nil {
+				return err
+			}
+			clear = false
+		case 1216: // callothersubr
+			if err := d.callOtherSubr(); err != nil {
+				return err
+			}
+			clear = false
+		case 1217: // pop
+			if len(d.psStack) > 0 {
+				v := d.psStack[len(d.psStack)-1]
+				d.psStack = d.psStack[:len(d.psStack)-1]
+				if err := d.push(v); err != nil {
+					return err
+				}
+			}
+			clear = false
+		case 1233: // setcurrentpoint
+			if len(d.stack) < 2 {
+				return errStackUnderflow
+			}
+			d.b.x, d.b.y = d.stack[0], d.stack[1]
+		default:
+			// Unknown commands are ignored.
+		}
+
+		if clear {
+			d.stack = d.stack[:0]
+		}
+	}
+
+	return nil
+}
+
+// setSideBearing processes the hsbw and sbw commands, which set the side
+// bearing point and the width of the glyph. The width of the components of
+// composite glyphs is ignored.
+func (d *type1Decoder) setSideBearing(sbx, sby, wx, wy float64) {
+	d.sbx, d.sby = sbx, sby
+	d.b.x, d.b.y = sbx, sby
+	if !d.seac {
+		d.wx, d.wy = wx, wy
+	}
+}
+
+// rmoveto moves the current point. While processing flex hints, the points
+// are collected instead.
+func (d *type1Decoder) rmoveto(dx, dy float64) {
+	x, y := d.b.x+dx, d.b.y+dy
+	if d.flexing {
+		d.b.x, d.b.y = x, y
+		d.flexPoints = append(d.flexPoints, Point{X: x, Y: y})
+		return
+	}
+	d.b.moveTo(x, y)
+}
+
+// callOtherSubr processes the callothersubr command. Only the flex and hint
+// replacement subroutines, which have standard definitions, are supported.
+func (d *type1Decoder) callOtherSubr() error {
+	if len(d.stack) < 2 {
+		return errStackUnderflow
+	}
+	n := int(d.pop())
+	argc := int(d.pop())
+	if argc < 0 || argc > len(d.stack) {
+		return errStackUnderflow
+	}
+	args := make([]float64, argc)
+	copy(args, d.stack[len(d.stack)-argc:])
+	d.stack = d.stack[:len(d.stack)-argc]
+
+	switch n {
+	case 0:
+		// End of flex. The collected points are the reference point
+		// followed by the points of two curves.
+		d.flexing = false
+		pts := d.flexPoints
+		d.flexPoints = nil
+		if len(pts) >= 7 {
+			d.b.cubicTo(pts[1].X, pts[1].Y, pts[2].X, pts[2].Y, pts[3].X, pts[3].Y)
+			d.b.cubicTo(pts[4].X, pts[4].Y, pts[5].X, pts[5].Y, pts[6].X, pts[6].Y)
+		}
+
+		// The end point is retrieved using `pop pop setcurrentpoint`.
+		d.psStack = append(d.psStack, d.b.y, d.b.x)
+	case 1:
+		// Start of flex.
+		d.flexing = true
+		d.flexPoints = d.flexPoints[:0]
+	case 2:
+		// Flex points are collected by rmoveto.
+	default:
+		// Hint replacement and other subroutines: the arguments are
+		// returned unchanged by the subsequent pop commands.
+		for i := len(args) - 1; i >= 0; i-- {
+			d.psStack = append(d.psStack, args[i])
+		}
+	}
+	return nil
+}
+
+// composite processes the seac command, which builds an accented glyph from
+// the standard encoding glyphs `bchar` and `achar`.
+func (d *type1Decoder) composite(asb, adx, ady float64, bchar, achar int) error {
+	encoding := standardEncoding()
+	base, ok := encoding[byte(bchar)]
+	if !ok || bchar < 0 || bchar > 255 {
+		return errors.New("charstring: invalid seac base character")
+	}
+	accent, ok := encoding[byte(achar)]
+	if !ok || achar < 0 || achar > 255 {
+		return errors.New("charstring: invalid seac accent character")
+	}
+
+	baseData, ok := d.font.charStrings[base]
+	if !ok {
+		return errors.New("charstring: seac base glyph not found")
+	}
+	accentData, ok := d.font.charStrings[accent]
+	if !ok {
+		return errors.New("charstring: seac accent glyph not found")
+	}
+
+	// The base glyph is positioned at the origin of the composite glyph.
+	d.b.closePath()
+	sub := &type1Decoder{font: d.font, b: d.b, seac: true}
+	if err := sub.run(baseData, 0); err != nil {
+		return err
+	}
+	d.b.closePath()
+
+	// The accent is positioned so that its left side bearing point is
+	// located at adx, ady.
+	d.b.offsetX, d.b.offsetY = adx-asb, ady
+	sub = &type1Decoder{font: d.font, b: d.b, seac: true}
+	err := sub.run(accentData, 0)
+	d.b.closePath()
+	d.b.offsetX, d.b.offsetY = 0, 0
+	return err
+}
+
+func (d *type1Decoder) push(v float64) error {
+	if len(d.stack) >= maxStackDepth {
+		return errStackOverflow
+	}
+	d.stack = append(d.stack, v)
+	return nil
+}
+
+func (d *type1Decoder) pop() float64 {
+	v := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return v
+}
@@ -0,0 +1,145 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// encrypt encrypts the data using the Type 1 font encryption algorithm,
+// prepending 4 zero bytes.
+func encrypt(data []byte, key uint16) []byte {
+	const c1, c2 = 52845, 22719
+
+	r := key
+	plain := append([]byte{0, 0, 0, 0}, data...)
+	out := make([]byte, len(plain))
+	for i, p := range plain {
+		c := p ^ byte(r>>8)
+		r = (uint16(c)+r)*c1 + c2
+		out[i] = c
+	}
+	return out
+}
+
+// testType1Font returns a Type 1 font program containing a square glyph
+// drawn partially by a subroutine, and an accented glyph built using seac.
+func testType1Font(hexEncoded bool) []byte {
+	// 300 0 rlineto return
+	subr := []byte{247, 192, 139, 5, 11}
+
+	charStrings := map[string][]byte{
+		// 0 0 hsbw endchar
+		".notdef": {139, 139, 13, 14},
+		// 0 500 hsbw 100 100 rmoveto 0 callsubr 0 300 rlineto
+		// -300 0 rlineto closepath endchar
+		"square": {
+			139, 248, 136, 13, 239, 239, 21, 139, 10,
+			139, 247, 192, 5, 251, 192, 139, 5, 9, 14,
+		},
+		// 0 200 hsbw 0 0 rmoveto 100 hlineto 100 vlineto closepath endchar
+		"acute": {139, 247, 92, 13, 139, 139, 21, 239, 6, 239, 7, 9, 14},
+		// 0 500 hsbw 0 0 100 65 194 seac
+		"Aacute": {139, 248, 136, 13, 139, 139, 239, 204, 247, 86, 12, 6},
+	}
+	charStrings["A"] = charStrings["square"]
+
+	var private bytes.Buffer
+	private.WriteString("dup /Private 8 dict dup begin\n/lenIV 4 def\n/Subrs 1 array\n")
+	bin := encrypt(subr, charstringKey)
+	fmt.Fprintf(&private, "dup 0 %d RD ", len(bin))
+	private.Write(bin)
+	private.WriteString(" NP\nND\n2 index /CharStrings 5 dict dup begin\n")
+	for _, name := range []string{".notdef", "square", "A", "acute", "Aacute"} {
+		bin := encrypt(charStrings[name], charstringKey)
+		fmt.Fprintf(&private, "/%s %d RD ", name, len(bin))
+		private.Write(bin)
+		private.WriteString(" ND\n")
+	}
+	private.WriteString("end\nend\nreadonly put\nmark currentfile closefile\n")
+
+	var font bytes.Buffer
+	font.WriteString("%!PS-AdobeFont-1.0: Test 001.000\n")
+	font.WriteString("/FontName /Test def\n/FontMatrix [0.001 0 0 0.001 0 0] readonly def\n")
+	font.WriteString("/Encoding 256 array\n0 1 255 {1 index exch /.notdef put} for\n")
+	font.WriteString("dup 65 /square put\nreadonly def\ncurrentfile eexec\n")
+
+	encrypted := encrypt(private.Bytes(), eexecKey)
+	if hexEncoded {
+		font.WriteString(hex.EncodeToString(encrypted))
+	} else {
+		font.Write(encrypted)
+	}
+	return font.Bytes()
+}
+
+func TestType1Glyphs(t *testing.T) {
+	for _, hexEncoded := range []bool{false, true} {
+		font, err := ParseType1(testType1Font(hexEncoded))
+		require.NoError(t, err)
+		require.Equal(t, "square", font.Encoding[65])
+		require.True(t, font.HasGlyph("square"))
+		require.False(t, font.HasGlyph("B"))
+
+		glyph, err := font.GlyphByName("square")
+		require.NoError(t, err)
+		require.InDelta(t, 0.5, glyph.Advance, 1e-9)
+		require.Len(t, glyph.Path, 5)
+
+		expected := []Point{{0.1, 0.1}, {0.4, 0.1}, {0.4, 0.4}, {0.1, 0.4}}
+		for i, p := range expected {
+			require.InDelta(t, p.X, glyph.Path[i].Points[0].X, 1e-9)
+			require.InDelta(t, p.Y, glyph.Path[i].Points[0].Y, 1e-9)
+		}
+		require.Equal(t, SegmentMoveTo, glyph.Path[0].Type)
+		require.Equal(t, SegmentClose, glyph.Path[4].Type)
+
+		_, err = font.GlyphByName("B")
+		require.Error(t, err)
+	}
+}
+
+func TestType1Seac(t *testing.T) {
+	font, err := ParseType1(testType1Font(false))
+	require.NoError(t, err)
+
+	glyph, err := font.GlyphByName("Aacute")
+	require.NoError(t, err)
+	require.InDelta(t, 0.5, glyph.Advance, 1e-9)
+
+	// The base glyph is followed by the accent, offset by 100 units
+	// vertically.
+	var moves []Point
+	for _, seg := range glyph.Path {
+		if seg.Type == SegmentMoveTo {
+			moves = append(moves, seg.Points[0])
+		}
+	}
+	require.Len(t, moves, 2)
+	require.InDelta(t, 0.1, moves[0].X, 1e-9)
+	require.InDelta(t, 0.1, moves[0].Y, 1e-9)
+	require.InDelta(t, 0, moves[1].X, 1e-9)
+	require.InDelta(t, 0.1, moves[1].Y, 1e-9)
+}
+
+func TestStripPFB(t *testing.T) {
+	segment := func(typ byte, data string) []byte {
+		n := len(data)
+		return append([]byte{0x80, typ, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}, data...)
+	}
+
+	var data []byte
+	data = append(data, segment(1, "clear ")...)
+	data = append(data, segment(2, "binary")...)
+	data = append(data, 0x80, 3)
+	require.Equal(t, []byte("clear binary"), stripPFB(data))
+	require.Equal(t, []byte("plain"), stripPFB([]byte("plain")))
+}
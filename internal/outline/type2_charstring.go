@@ -0,0 +1,506 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package outline
+
+import (
+	"errors"
+	"math"
+)
+
+// Operand stack limits of Type 2 and CFF2 charstrings.
+const (
+	maxType2Stack = 48
+	maxCFF2Stack  = 513
+)
+
+// type2Decoder interprets Type 2 charstrings (Adobe Technical Note #5177) and
+// CFF2 charstrings.
+type type2Decoder struct {
+	font *CFFFont
+	fd   *cffFontDict
+	b    *pathBuilder
+
+	stack     []float64
+	transient [32]float64
+	nStems    int
+	vsindex   int
+
+	// The width of the glyph is specified as an optional first operand of
+	// the first stack clearing operator.
+	width      float64
+	hasWidth   bool
+	widthFound bool
+
+	done bool
+}
+
+// run interprets the specified charstring.
+func (d *type2Decoder) run(data []byte, depth int) error {
+	if depth > maxSubrDepth {
+		return errSubrDepth
+	}
+	if depth == 0 {
+		d.vsindex = d.fd.vsindex
+	}
+
+	for i := 0; i < len(data) && !d.done; {
+		v := data[i]
+		i++
+
+		// Numbers.
+		switch {
+		case v == 28:
+			if i+2 > len(data) {
+				return errStackUnderflow
+			}
+			if err := d.push(float64(int16(uint16(data[i])<<8 | uint16(data[i+1])))); err != nil {
+				return err
+			}
+			i += 2
+			continue
+		case v >= 32 && v <= 246:
+			if err := d.push(float64(int(v) - 139)); err != nil {
+				return err
+			}
+			continue
+		case v >= 247 && v <= 254:
+			if i >= len(data) {
+				return errStackUnderflow
+			}
+			n := (int(v)-247)*256 + int(data[i]) + 108
+			if v >= 251 {
+				n = -(int(v)-251)*256 - int(data[i]) - 108
+			}
+			if err := d.push(float64(n)); err != nil {
+				return err
+			}
+			i++
+			continue
+		case v == 255:
+			if i+4 > len(data) {
+				return errStackUnderflow
+			}
+			n := int32(uint32(data[i])<<24 | uint32(data[i+1])<<16 | uint32(data[i+2])<<8 | uint32(data[i+3]))
+			if err := d.push(float64(n) / 65536); err != nil {
+				return err
+			}
+			i += 4
+			continue
+		}
+
+		// Operators.
+		op := int(v)
+		if v == 12 {
+			if i >= len(data) {
+				return errStackUnderflow
+			}
+			op = 1200 + int(data[i])
+			i++
+		}
+
+		clear := true
+		switch op {
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			d.parseWidth(len(d.stack)%2 == 1)
+			d.nStems += len(d.stack) / 2
+		case 19, 20: // hintmask, cntrmask
+			// Stem hints can be specified before the mask, in which case
+			// the vstem operator is implied.
+			d.parseWidth(len(d.stack)%2 == 1)
+			d.nStems += len(d.stack) / 2
+			i += (d.nStems + 7) / 8
+		case 21: // rmoveto
+			d.parseWidth(len(d.stack) > 2)
+			if len(d.stack) < 2 {
+				return errStackUnderflow
+			}
+			d.b.moveTo(d.b.x+d.stack[0], d.b.y+d.stack[1])
+		case 22: // hmoveto
+			d.parseWidth(len(d.stack) > 1)
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.b.moveTo(d.b.x+d.stack[0], d.b.y)
+		case 4: // vmoveto
+			d.parseWidth(len(d.stack) > 1)
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.b.moveTo(d.b.x, d.b.y+d.stack[0])
+		case 5: // rlineto
+			for s := d.stack; len(s) >= 2; s = s[2:] {
+				d.b.lineTo(d.b.x+s[0], d.b.y+s[1])
+			}
+		case 6, 7: // hlineto, vlineto
+			horizontal := op == 6
+			for _, delta := range d.stack {
+				if horizontal {
+					d.b.lineTo(d.b.x+delta, d.b.y)
+				} else {
+					d.b.lineTo(d.b.x, d.b.y+delta)
+				}
+				horizontal = !horizontal
+			}
+		case 8: // rrcurveto
+			for s := d.stack; len(s) >= 6; s = s[6:] {
+				d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			}
+		case 24: // rcurveline
+			s := d.stack
+			for ; len(s) >= 8; s = s[6:] {
+				d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			}
+			if len(s) >= 2 {
+				d.b.lineTo(d.b.x+s[0], d.b.y+s[1])
+			}
+		case 25: // rlinecurve
+			s := d.stack
+			for ; len(s) >= 8; s = s[2:] {
+				d.b.lineTo(d.b.x+s[0], d.b.y+s[1])
+			}
+			if len(s) >= 6 {
+				d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+			}
+		case 26: // vvcurveto
+			s := d.stack
+			var dx1 float64
+			if len(s)%4 == 1 {
+				dx1, s = s[0], s[1:]
+			}
+			for ; len(s) >= 4; s = s[4:] {
+				d.b.rcurveTo(dx1, s[0], s[1], s[2], 0, s[3])
+				dx1 = 0
+			}
+		case 27: // hhcurveto
+			s := d.stack
+			var dy1 float64
+			if len(s)%4 == 1 {
+				dy1, s = s[0], s[1:]
+			}
+			for ; len(s) >= 4; s = s[4:] {
+				d.b.rcurveTo(s[0], dy1, s[1], s[2], s[3], 0)
+				dy1 = 0
+			}
+		case 30, 31: // vhcurveto, hvcurveto
+			horizontal := op == 31
+			for s := d.stack; len(s) >= 4; s = s[4:] {
+				// The last curve can have an additional final delta.
+				var df float64
+				if len(s) == 5 {
+					df = s[4]
+				}
+				if horizontal {
+					d.b.rcurveTo(s[0], 0, s[1], s[2], df, s[3])
+				} else {
+					d.b.rcurveTo(0, s[0], s[1], s[2], s[3], df)
+				}
+				horizontal = !horizontal
+			}
+		case 10, 29: // callsubr, callgsubr
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			subrs := d.fd.subrs
+			if op == 29 {
+				subrs = d.font.gsubrs
+			}
+			n := int(d.pop()) + subrBias(len(subrs))
+			if n < 0 || n >= len(subrs) {
+				return errInvalidSubr
+			}
+			if err := d.run(subrs[n], depth+1); err != nil {
+				return err
+			}
+			clear = false
+		case 11: // return
+			return nil
+		case 14: // endchar
+			d.parseWidth(len(d.stack) == 1 || len(d.stack) == 5)
+			if len(d.stack) == 4 {
+				s := d.stack
+				if err := d.composite(s[0], s[1], int(s[2]), int(s[3])); err != nil {
+					return err
+				}
+			}
+			d.b.closePath()
+			d.done = true
+		case 15: // vsindex
+			if len(d.stack) < 1 {
+				return errStackUnderflow
+			}
+			d.vsindex = int(d.stack[0])
+		case 16: // blend
+			if err := d.blend(); err != nil {
+				return err
+			}
+			clear = false
+		case 1234, 1235, 1236, 1237: // hflex, flex, hflex1, flex1
+			if err := d.flex(op); err != nil {
+				return err
+			}
+		default:
+			if op >= 1200 {
+				if err := d.arithmetic(op); err != nil {
+					return err
+				}
+				clear = false
+			}
+			// Other operators are reserved and ignored.
+		}
+
+		if clear {
+			d.stack = d.stack[:0]
+		}
+	}
+	return nil
+}
+
+// parseWidth removes the width from the operand stack, if present. Only the
+// first stack clearing operator can specify the width.
+func (d *type2Decoder) parseWidth(present bool) {
+	if d.widthFound {
+		return
+	}
+	d.widthFound = true
+	if !present || d.font.cff2 {
+		return
+	}
+	d.width, d.hasWidth = d.stack[0], true
+	d.stack = d.stack[1:]
+}
+
+// flex processes the flex operators, which are rendered as two curves.
+func (d *type2Decoder) flex(op int) error {
+	s := d.stack
+	switch op {
+	case 1234: // hflex
+		if len(s) < 7 {
+			return errStackUnderflow
+		}
+		d.b.rcurveTo(s[0], 0, s[1], s[2], s[3], 0)
+		d.b.rcurveTo(s[4], 0, s[5], -s[2], s[6], 0)
+	case 1235: // flex
+		if len(s) < 13 {
+			return errStackUnderflow
+		}
+		d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+		d.b.rcurveTo(s[6], s[7], s[8], s[9], s[10], s[11])
+	case 1236: // hflex1
+		if len(s) < 9 {
+			return errStackUnderflow
+		}
+		d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], 0)
+		d.b.rcurveTo(s[5], 0, s[6], s[7], s[8], -(s[1] + s[3] + s[7]))
+	case 1237: // flex1
+		if len(s) < 11 {
+			return errStackUnderflow
+		}
+		var dx, dy float64
+		for i := 0; i < 10; i += 2 {
+			dx += s[i]
+			dy += s[i+1]
+		}
+
+		// The last delta is horizontal or vertical depending on the
+		// direction of the curves, the other coordinate returning to the
+		// starting point.
+		dx6, dy6 := -dx, s[10]
+		if math.Abs(dx) > math.Abs(dy) {
+			dx6, dy6 = s[10], -dy
+		}
+		d.b.rcurveTo(s[0], s[1], s[2], s[3], s[4], s[5])
+		d.b.rcurveTo(s[6], s[7], s[8], s[9], dx6, dy6)
+	}
+	return nil
+}
+
+// arithmetic processes the arithmetic, conditional and storage operators.
+func (d *type2Decoder) arithmetic(op int) error {
+	need := map[int]int{
+		1203: 2, 1204: 2, 1205: 1, 1209: 1, 1210: 2, 1211: 2, 1212: 2,
+		1214: 1, 1215: 2, 1218: 1, 1220: 2, 1221: 1, 1222: 4, 1224: 2,
+		1226: 1, 1227: 1, 1228: 2, 1229: 1, 1230: 2,
+	}[op]
+	if len(d.stack) < need {
+		return errStackUnderflow
+	}
+
+	boolean := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	switch op {
+	case 1203: // and
+		b, a := d.pop(), d.pop()
+		return d.push(boolean(a != 0 && b != 0))
+	case 1204: // or
+		b, a := d.pop(), d.pop()
+		return d.push(boolean(a != 0 || b != 0))
+	case 1205: // not
+		return d.push(boolean(d.pop() == 0))
+	case 1209: // abs
+		return d.push(math.Abs(d.pop()))
+	case 1210: // add
+		b, a := d.pop(), d.pop()
+		return d.push(a + b)
+	case 1211: // sub
+		b, a := d.pop(), d.pop()
+		return d.push(a - b)
+	case 1212: // div
+		b, a := d.pop(), d.pop()
+		if b == 0 {
+			return errors.New("charstring: division by zero")
+		}
+		return d.push(a / b)
+	case 1214: // neg
+		return d.push(-d.pop())
+	case 1215: // eq
+		b, a := d.pop(), d.pop()
+		return d.push(boolean(a == b))
+	case 1218: // drop
+		d.pop()
+	case 1220: // put
+		i, v := int(d.pop()), d.pop()
+		if i >= 0 && i < len(d.transient) {
+			d.transient[i] = v
+		}
+	case 1221: // get
+		i := int(d.pop())
+		var v float64
+		if i >= 0 && i < len(d.transient) {
+			v = d.transient[i]
+		}
+		return d.push(v)
+	case 1222: // ifelse
+		v2, v1, s2, s1 := d.pop(), d.pop(), d.pop(), d.pop()
+		if v1 > v2 {
+			return d.push(s2)
+		}
+		return d.push(s1)
+	case 1223: // random
+		// A fixed value keeps the rendering deterministic.
+		return d.push(0.5)
+	case 1224: // mul
+		b, a := d.pop(), d.pop()
+		return d.push(a * b)
+	case 1226: // sqrt
+		return d.push(math.Sqrt(math.Abs(d.pop())))
+	case 1227: // dup
+		v := d.stack[len(d.stack)-1]
+		return d.push(v)
+	case 1228: // exch
+		n := len(d.stack)
+		d.stack[n-1], d.stack[n-2] = d.stack[n-2], d.stack[n-1]
+	case 1229: // index
+		i := int(d.pop())
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(d.stack) {
+			return errStackUnderflow
+		}
+		return d.push(d.stack[len(d.stack)-1-i])
+	case 1230: // roll
+		j, n := int(d.pop()), int(d.pop())
+		if n <= 0 || n > len(d.stack) {
+			return errStackUnderflow
+		}
+		items := d.stack[len(d.stack)-n:]
+		rolled := make([]float64, n)
+		for i, v := range items {
+			rolled[((i+j)%n+n)%n] = v
+		}
+		copy(items, rolled)
+	}
+	return nil
+}
+
+// blend processes the blend operator of CFF2 charstrings. Only the default
+// instance is rendered, so the deltas are discarded.
+func (d *type2Decoder) blend() error {
+	if len(d.stack) < 1 {
+		return errStackUnderflow
+	}
+	n := int(d.pop())
+	regions := 0
+	if d.vsindex >= 0 && d.vsindex < len(d.font.regionCounts) {
+		regions = d.font.regionCounts[d.vsindex]
+	}
+	count := n * (regions + 1)
+	if n < 0 || count > len(d.stack) {
+		return errStackUnderflow
+	}
+	d.stack = d.stack[:len(d.stack)-count+n]
+	return nil
+}
+
+// composite processes the endchar operator with the seac arguments, which
+// builds an accented glyph from the standard encoding glyphs `bchar` and
+// `achar`.
+func (d *type2Decoder) composite(adx, ady float64, bchar, achar int) error {
+	if bchar < 0 || bchar > 255 || achar < 0 || achar > 255 {
+		return errors.New("charstring: invalid seac character")
+	}
+	encoding := standardEncoding()
+	base, ok := d.font.nameToGID[encoding[byte(bchar)]]
+	if !ok {
+		return errors.New("charstring: seac base glyph not found")
+	}
+	accent, ok := d.font.nameToGID[encoding[byte(achar)]]
+	if !ok {
+		return errors.New("charstring: seac accent glyph not found")
+	}
+
+	// The components can specify their own width, which is ignored.
+	d.b.closePath()
+	d.b.x, d.b.y = 0, 0
+	sub := &type2Decoder{font: d.font, fd: d.fd, b: d.b}
+	if err := sub.run(d.font.charStrings[base], 0); err != nil {
+		return err
+	}
+	d.b.closePath()
+
+	d.b.offsetX, d.b.offsetY = adx, ady
+	d.b.x, d.b.y = 0, 0
+	sub = &type2Decoder{font: d.font, fd: d.fd, b: d.b}
+	err := sub.run(d.font.charStrings[accent], 0)
+	d.b.closePath()
+	d.b.offsetX, d.b.offsetY = 0, 0
+	return err
+}
+
+func (d *type2Decoder) push(v float64) error {
+	limit := maxType2Stack
+	if d.font.cff2 {
+		limit = maxCFF2Stack
+	}
+	if len(d.stack) >= limit {
+		return errStackOverflow
+	}
+	d.stack = append(d.stack, v)
+	return nil
+}
+
+func (d *type2Decoder) pop() float64 {
+	v := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return v
+}
+
+// subrBias returns the bias added to subroutine numbers, which depends on
+// the number of subroutines.
+func subrBias(count int) int {
+	switch {
+	case count < 1240:
+		return 107
+	case count < 33900:
+		return 1131
+	}
+	return 32768
+}
@@ -126,8 +126,8 @@ func (m *Matrix) Clone() Matrix {
 
 // Transform returns coordinates `x`,`y` transformed by `m`.
 func (m *Matrix) Transform(x, y float64) (float64, float64) {
-	xp := x*m[0] + y*m[1] + m[6]
-	yp := x*m[3] + y*m[4] + m[7]
+	xp := x*m[0] + y*m[3] + m[6]
+	yp := x*m[1] + y*m[4] + m[7]
 	return xp, yp
 }
 
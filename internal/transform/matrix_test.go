@@ -61,3 +61,44 @@ func makeAngleCase(r, theta float64) angleCase {
 	d := a
 	return angleCase{params{a, b, c, d, 0, 0}, theta}
 }
+
+// TestTransform tests that Matrix.Transform maps points as the PDF transformation
+// [a b c d tx ty] does: x' = a*x + c*y + tx, y' = b*x + d*y + ty.
+func TestTransform(t *testing.T) {
+	s := math.Sqrt2 / 2
+	testcases := []struct {
+		name   string
+		params params
+		x, y   float64
+		xp, yp float64
+	}{
+		{"identity", params{1, 0, 0, 1, 0, 0}, 3, 4, 3, 4},
+		{"scale and translate", params{2, 0, 0, 3, 10, 20}, 1, 1, 12, 23},
+		{"rotate 90", params{0, 1, -1, 0, 0, 0}, 1, 0, 0, 1},
+		{"rotate 90 y axis", params{0, 1, -1, 0, 0, 0}, 0, 1, -1, 0},
+		{"rotate 90 and translate", params{0, 1, -1, 0, 10, 20}, 1, 2, 8, 21},
+		{"rotate 45", params{s, s, -s, s, 0, 0}, 1, 1, 0, math.Sqrt2},
+		{"rotate -30 and translate", params{math.Sqrt(3) / 2, -0.5, 0.5, math.Sqrt(3) / 2, 5, 5},
+			2, 0, 5 + math.Sqrt(3), 4},
+		{"skew x", params{1, 0, 0.5, 1, 0, 0}, 2, 2, 3, 2},
+		{"skew y", params{1, 0.5, 0, 1, 0, 0}, 2, 2, 2, 3},
+		{"skew and translate", params{1, 0.25, 0.5, 1, -1, 1}, 4, 2, 4, 4},
+	}
+
+	const tol = 1.0e-10
+	for _, tc := range testcases {
+		p := tc.params
+		m := NewMatrix(p.a, p.b, p.c, p.d, p.tx, p.ty)
+		xp, yp := m.Transform(tc.x, tc.y)
+		if math.Abs(xp-tc.xp) > tol || math.Abs(yp-tc.yp) > tol {
+			t.Fatalf("%s: m=%s (%g, %g) -> (%g, %g). Expected (%g, %g)",
+				tc.name, m, tc.x, tc.y, xp, yp, tc.xp, tc.yp)
+		}
+
+		// Transforming a point is the same as concatenating its translation with the matrix.
+		pm := m.Mult(TranslationMatrix(tc.x, tc.y))
+		if tx, ty := pm.Translation(); math.Abs(tx-xp) > tol || math.Abs(ty-yp) > tol {
+			t.Fatalf("%s: Transform=(%g, %g) Mult=(%g, %g)", tc.name, xp, yp, tx, ty)
+		}
+	}
+}
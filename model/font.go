@@ -39,6 +39,8 @@ type pdfFont interface {
 // etc.
 type PdfFont struct {
 	context pdfFont // The underlying font: Type0, Type1, Truetype, etc..
+
+	glyphNames *embeddedGlyphNames // Glyph names of the embedded font program. Loaded on demand.
 }
 
 // SubsetRegistered subsets the font to only the glyphs that have been registered by the encoder.
@@ -101,6 +103,13 @@ func (font *PdfFont) IsCID() bool {
 	return font.baseFields().isCIDFont()
 }
 
+// IsVertical returns true if the font uses vertical writing mode. This is the case for Type0 fonts
+// whose encoding CMap has a WMode of 1, such as Identity-V.
+func (font *PdfFont) IsVertical() bool {
+	t, ok := font.context.(*pdfFontType0)
+	return ok && t.vertical
+}
+
 // FontDescriptor returns font's PdfFontDescriptor. This may be a builtin descriptor for standard 14
 // fonts but must be an explicit descriptor for other fonts.
 func (font *PdfFont) FontDescriptor() *PdfFontDescriptor {
@@ -122,6 +131,28 @@ func (font *PdfFont) ToUnicode() string {
 	return font.baseFields().toUnicodeCmap.Name()
 }
 
+// HasToUnicode returns true if `font` has a "ToUnicode" CMap. Unlike ToUnicode, it doesn't depend
+// on the CMap being named.
+func (font *PdfFont) HasToUnicode() bool {
+	return font.baseFields().toUnicodeCmap != nil
+}
+
+// IsEncodingGuessed returns true if the encoding `font` uses to map character codes to text is not
+// given in its font dictionary. This is the case for simple fonts without an "Encoding" entry,
+// whose encodings are taken from their font programs or standard encodings, and for composite
+// fonts with Identity encodings, whose character codes are treated as Unicode code points.
+// The text of fonts with guessed encodings and no "ToUnicode" CMap is often wrong.
+func (font *PdfFont) IsEncodingGuessed() bool {
+	switch t := font.context.(type) {
+	case *pdfFontSimple:
+		return t.Encoding == nil
+	case *pdfFontType0:
+		_, ok := t.encoder.(*textencoding.IdentityEncoder)
+		return ok
+	}
+	return false
+}
+
 // DefaultFont returns the default font, which is currently the built in Helvetica.
 func DefaultFont() *PdfFont {
 	helvetica, ok := fonts.NewStdFontByName(HelveticaName)
@@ -650,6 +681,36 @@ func (font *PdfFont) GetCharMetrics(code textencoding.CharCode) (CharMetrics, bo
 	return nometrics, false
 }
 
+// VerticalMetrics represents the metrics of a glyph used in vertical writing mode. The values are
+// in glyph space units (1/1000 of text space units).
+type VerticalMetrics struct {
+	// W1y is the vertical displacement of the glyph. It is negative for text written downwards.
+	W1y float64
+
+	// Vx and Vy are the components of the position vector from the glyph's horizontal origin to
+	// its vertical origin.
+	Vx, Vy float64
+}
+
+// GetCharVerticalMetrics returns the vertical writing mode metrics for character code `code`.
+// A bool flag is returned to indicate whether `font` has vertical metrics, which is only the case
+// for composite fonts and their CIDFonts.
+func (font *PdfFont) GetCharVerticalMetrics(code textencoding.CharCode) (VerticalMetrics, bool) {
+	switch t := font.context.(type) {
+	case *pdfFontType0:
+		if t.DescendantFont != nil {
+			return t.DescendantFont.GetCharVerticalMetrics(code)
+		}
+	case *pdfCIDFontType0:
+		m, _ := t.GetCharMetrics(code)
+		return t.vmetrics.get(code, m.Wx), true
+	case *pdfCIDFontType2:
+		m, _ := t.GetCharMetrics(code)
+		return t.vmetrics.get(code, m.Wx), true
+	}
+	return VerticalMetrics{}, false
+}
+
 // actualFont returns the Font in font.context
 func (font PdfFont) actualFont() pdfFont {
 	if font.context == nil {
@@ -110,6 +110,11 @@ type pdfFontType0 struct {
 	Encoding       core.PdfObject
 	DescendantFont *PdfFont // Can be either CIDFontType0 or CIDFontType2 font.
 	codeToCID      *cmap.CMap
+	vertical       bool // Does the font use vertical writing mode?
+
+	// shaping is used to shape text in fonts created from TrueType font files with OpenType
+	// layout tables. It is nil for other fonts.
+	shaping *fontShaping
 }
 
 // pdfFontType0FromSkeleton returns a pdfFontType0 with its common fields initalized.
@@ -239,7 +244,14 @@ func (font *pdfFontType0) subsetRegistered() error {
 	case *textencoding.TrueTypeFontEncoder:
 		// Means the font has been loaded from TTF file.
 		runes = tenc.RegisteredRunes()
-		subset, err = fnt.SubsetKeepRunes(runes)
+		indices := fnt.LookupRunes(runes)
+		if font.shaping != nil {
+			// Keep the glyphs of shaped text. Their GIDs are their character codes.
+			for _, gid := range font.shaping.glyphs() {
+				indices = append(indices, unitype.GlyphIndex(gid))
+			}
+		}
+		subset, err = fnt.SubsetKeepIndices(indices)
 		if err != nil {
 			common.Log.Debug("ERROR: %v", err)
 			return err
@@ -284,15 +296,22 @@ func (font *pdfFontType0) subsetRegistered() error {
 
 	// Update info for ToUnicode CMap entry.
 	if font.toUnicodeCmap != nil {
-		codeToUnicode := make(map[cmap.CharCode]rune, len(runes))
+		codeToUnicode := make(map[cmap.CharCode]string, len(runes))
 		for _, r := range runes {
 			cc, ok := font.encoder.RuneToCharcode(r)
 			if !ok {
 				continue
 			}
-			codeToUnicode[cmap.CharCode(cc)] = r
+			codeToUnicode[cmap.CharCode(cc)] = string(r)
 		}
-		font.toUnicodeCmap = cmap.NewToUnicodeCMap(codeToUnicode)
+		if font.shaping != nil {
+			for _, gid := range font.shaping.glyphs() {
+				if text, ok := font.shaping.text(gid); ok {
+					codeToUnicode[cmap.CharCode(gid)] = text
+				}
+			}
+		}
+		font.toUnicodeCmap = cmap.NewToUnicodeCMapFromStrings(codeToUnicode)
 	}
 
 	stream, err = core.MakeStream(buf.Bytes(), core.NewFlateEncoder())
@@ -390,6 +409,7 @@ func newPdfFontType0FromPdfObject(d *core.PdfObjectDictionary, base *fontCommon)
 			common.Log.Debug("Unhandled cmap %q", encoderName)
 		}
 	}
+	font.vertical = isVerticalEncoding(d.Get("Encoding"))
 
 	if cidToUnicode := df.baseFields().toUnicodeCmap; cidToUnicode != nil {
 		if dfn := cidToUnicode.Name(); dfn == "Adobe-CNS1-UCS2" || dfn == "Adobe-GB1-UCS2" ||
@@ -425,6 +445,7 @@ type pdfCIDFontType0 struct {
 
 	widths       map[textencoding.CharCode]float64
 	defaultWidth float64
+	vmetrics     cidVerticalMetrics
 }
 
 // pdfCIDFontType0FromSkeleton returns a pdfCIDFontType0 with its common fields initalized.
@@ -508,6 +529,7 @@ func newPdfCIDFontType0FromPdfObject(d *core.PdfObjectDictionary, base *fontComm
 		fontWidths = map[textencoding.CharCode]float64{}
 	}
 	font.widths = fontWidths
+	font.vmetrics = parseCIDFontVerticalMetrics(font.DW2, font.W2)
 
 	return font, nil
 }
@@ -539,6 +561,7 @@ type pdfCIDFontType2 struct {
 
 	widths       map[textencoding.CharCode]float64
 	defaultWidth float64
+	vmetrics     cidVerticalMetrics
 
 	// Mapping between unicode runes to widths.
 	// TODO(dennwc): it is used only in GetGlyphCharMetrics
@@ -666,6 +689,7 @@ func newPdfCIDFontType2FromPdfObject(d *core.PdfObjectDictionary, base *fontComm
 		fontWidths = map[textencoding.CharCode]float64{}
 	}
 	font.widths = fontWidths
+	font.vmetrics = parseCIDFontVerticalMetrics(font.DW2, font.W2)
 
 	return font, nil
 }
@@ -731,6 +755,93 @@ func parseCIDFontWidthsArray(w core.PdfObject) (map[textencoding.CharCode]float6
 	return fontWidths, nil
 }
 
+// isVerticalEncoding returns true if the Type0 font Encoding `obj` specifies vertical writing mode.
+// This is the case for the predefined CMaps whose names end in -V, such as Identity-V, and for
+// embedded CMaps having a WMode of 1.
+func isVerticalEncoding(obj core.PdfObject) bool {
+	if name, ok := core.GetNameVal(obj); ok {
+		return strings.HasSuffix(name, "-V")
+	}
+	if stream, ok := core.GetStream(obj); ok {
+		wmode, _ := core.GetIntVal(stream.Get("WMode"))
+		return wmode == 1
+	}
+	return false
+}
+
+// cidVerticalMetrics represents the glyph metrics of a CIDFont used in vertical writing mode, as
+// specified by its DW2 and W2 entries (section 9.7.4.3 "Glyph Metrics in CIDFonts").
+type cidVerticalMetrics struct {
+	vy      float64 // Default vertical component of the position vector.
+	w1y     float64 // Default vertical displacement.
+	metrics map[textencoding.CharCode]VerticalMetrics
+}
+
+// get returns the vertical metrics of character code `code` whose horizontal width is `w0`.
+// Glyphs without explicit metrics have their vertical origin horizontally centred.
+func (vm cidVerticalMetrics) get(code textencoding.CharCode, w0 float64) VerticalMetrics {
+	if m, ok := vm.metrics[code]; ok {
+		return m
+	}
+	return VerticalMetrics{W1y: vm.w1y, Vx: w0 / 2, Vy: vm.vy}
+}
+
+// parseCIDFontVerticalMetrics returns the vertical metrics specified by the DW2 and W2 entries,
+// `dw2` and `w2`, of a CIDFont. Invalid W2 entries are ignored.
+func parseCIDFontVerticalMetrics(dw2, w2 core.PdfObject) cidVerticalMetrics {
+	vm := cidVerticalMetrics{vy: 880, w1y: -1000}
+	if arr, ok := core.GetArray(dw2); ok && arr.Len() == 2 {
+		if vals, err := arr.ToFloat64Array(); err == nil {
+			vm.vy, vm.w1y = vals[0], vals[1]
+		}
+	}
+
+	arr, ok := core.GetArray(w2)
+	if !ok {
+		return vm
+	}
+	vm.metrics = map[textencoding.CharCode]VerticalMetrics{}
+	for i := 0; i < arr.Len()-1; {
+		first, ok := core.GetIntVal(arr.Get(i))
+		if !ok {
+			common.Log.Debug("ERROR: Bad font W2 array: i=%d %s", i, arr)
+			break
+		}
+
+		// The entry has the form c [w1y v1x v1y w1y v1x v1y ...].
+		if vals, ok := core.GetArray(arr.Get(i + 1)); ok {
+			nums, err := vals.ToFloat64Array()
+			if err != nil {
+				common.Log.Debug("ERROR: Bad font W2 array: i=%d %s", i, arr)
+				break
+			}
+			for j := 0; j+2 < len(nums); j += 3 {
+				code := textencoding.CharCode(first + j/3)
+				vm.metrics[code] = VerticalMetrics{W1y: nums[j], Vx: nums[j+1], Vy: nums[j+2]}
+			}
+			i += 2
+			continue
+		}
+
+		// The entry has the form cfirst clast w1y v1x v1y.
+		if i+4 >= arr.Len() {
+			common.Log.Debug("ERROR: Bad font W2 array: i=%d %s", i, arr)
+			break
+		}
+		last, ok := core.GetIntVal(arr.Get(i + 1))
+		nums, err := core.GetNumbersAsFloat(arr.Elements()[i+2 : i+5])
+		if !ok || err != nil || last < first {
+			common.Log.Debug("ERROR: Bad font W2 array: i=%d %s", i, arr)
+			break
+		}
+		for code := first; code <= last; code++ {
+			vm.metrics[textencoding.CharCode(code)] = VerticalMetrics{W1y: nums[0], Vx: nums[1], Vy: nums[2]}
+		}
+		i += 5
+	}
+	return vm
+}
+
 // NewCompositePdfFontFromTTFFile loads a composite font from a TTF font file. Composite fonts can
 // be used to represent unicode fonts which can have multi-byte character codes, representing a wide
 // range of values. They are often used for symbolic languages, including Chinese, Japanese and Korean.
@@ -795,21 +906,29 @@ func NewCompositePdfFontFromTTF(r io.ReadSeeker) (*PdfFont, error) {
 
 	missingWidth := k * float64(ttf.Widths[0])
 
-	// Construct a rune ➞ width map.
+	// Construct rune ➞ width and GID ➞ width maps.
 	runeToWidthMap := make(map[rune]int)
+	gidToWidthMap := make(map[fonts.GID]int)
 	for _, r := range runes {
 		gid := ttf.Chars[r]
 
-		w := k * float64(ttf.Widths[gid])
-		runeToWidthMap[r] = int(w)
+		w := int(k * float64(ttf.Widths[gid]))
+		runeToWidthMap[r] = w
+		gidToWidthMap[gid] = w
 	}
 	cidfont.runeToWidthMap = runeToWidthMap
+	// The character codes are GIDs.
+	cidfont.widths = make(map[textencoding.CharCode]float64, len(gidToWidthMap))
+	for gid, w := range gidToWidthMap {
+		cidfont.widths[textencoding.CharCode(gid)] = float64(w)
+	}
 
 	// Default width.
 	cidfont.DW = core.MakeInteger(int64(missingWidth))
+	cidfont.defaultWidth = missingWidth
 
 	// Construct W array.  Stores character code to width mappings.
-	wArr := makeCIDWidthArr(runes, runeToWidthMap, ttf.Chars)
+	wArr := makeCIDWidthArr(gidToWidthMap)
 	cidfont.W = core.MakeIndirectObject(wArr)
 
 	d := core.MakeDict()
@@ -876,8 +995,8 @@ func NewCompositePdfFontFromTTF(r io.ReadSeeker) (*PdfFont, error) {
 	}
 
 	// Generate CMap for the Type 0 font, which is the inverse of ttf.Chars.
+	codeToUnicode := make(map[cmap.CharCode]rune, len(ttf.Chars))
 	if len(ttf.Chars) > 0 {
-		codeToUnicode := make(map[cmap.CharCode]rune, len(ttf.Chars))
 		for r, gid := range ttf.Chars {
 			cid := cmap.CharCode(gid)
 			if rn, ok := codeToUnicode[cid]; !ok || (ok && rn > r) {
@@ -886,6 +1005,9 @@ func NewCompositePdfFontFromTTF(r io.ReadSeeker) (*PdfFont, error) {
 		}
 		type0.toUnicodeCmap = cmap.NewToUnicodeCMap(codeToUnicode)
 	}
+	if ttf.CanShape() {
+		type0.shaping = newFontShaping(&ttf, k, gidToWidthMap, codeToUnicode)
+	}
 
 	// Build Font.
 	font := PdfFont{
@@ -895,7 +1017,9 @@ func NewCompositePdfFontFromTTF(r io.ReadSeeker) (*PdfFont, error) {
 	return &font, nil
 }
 
-func makeCIDWidthArr(runes []rune, widths map[rune]int, gids map[rune]fonts.GID) *core.PdfObjectArray {
+// makeCIDWidthArr returns a W array for a CIDFontType2 font with CIDs that are GIDs, whose glyph
+// widths are `widths`.
+func makeCIDWidthArr(widths map[fonts.GID]int) *core.PdfObjectArray {
 	// Construct W array. Stores character code to width mappings.
 	arr := &core.PdfObjectArray{}
 
@@ -907,26 +1031,24 @@ func makeCIDWidthArr(runes []rune, widths map[rune]int, gids map[rune]fonts.GID)
 
 	// We always use the second format.
 
-	// TODO(dennwc): this can be done on GIDs instead of runes
-	for i := 0; i < len(runes); {
-		w := widths[runes[i]]
+	gids := make([]fonts.GID, 0, len(widths))
+	for gid := range widths {
+		gids = append(gids, gid)
+	}
+	sort.Slice(gids, func(i, j int) bool { return gids[i] < gids[j] })
 
+	for i := 0; i < len(gids); {
+		w := widths[gids[i]]
+
+		// Extend the range over the following consecutive GIDs with the same width.
 		li := i
-		for j := i + 1; j < len(runes); j++ {
-			w2 := widths[runes[j]]
-			if w == w2 {
-				li = j
-			} else {
-				break
-			}
+		for j := i + 1; j < len(gids) && gids[j] == gids[li]+1 && widths[gids[j]] == w; j++ {
+			li = j
 		}
 
 		// The W maps from CID to width, here CID = GID.
-		gid1 := gids[runes[i]]
-		gid2 := gids[runes[li]]
-
-		arr.Append(core.MakeInteger(int64(gid1)))
-		arr.Append(core.MakeInteger(int64(gid2)))
+		arr.Append(core.MakeInteger(int64(gids[i])))
+		arr.Append(core.MakeInteger(int64(gids[li])))
 		arr.Append(core.MakeInteger(int64(w)))
 
 		i = li + 1
@@ -1,41 +1,28 @@
 package model
 
 import (
-	"sort"
 	"testing"
 
 	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
 	"github.com/gnaoh1379/unipdf/model/internal/fonts"
 )
 
 func TestCIDWidthArr(t *testing.T) {
-	widths := map[rune]int{
-		'a': 1,
-		'b': 1,
-		'c': 1,
-		'd': 2,
-		'e': 3,
-		'f': 3,
-		'g': 4,
-	}
-	gids := map[rune]fonts.GID{
-		'a': 1,
-		'b': 2,
-		'c': 3,
-		'd': 4,
-		'e': 5,
-		'f': 6,
-		'g': 7,
-	}
-	var runes []rune
-	for r := range widths {
-		runes = append(runes, r)
-	}
-	sort.Slice(runes, func(i, j int) bool {
-		return gids[runes[i]] < gids[runes[j]]
-	})
-
-	arr := makeCIDWidthArr(runes, widths, gids)
+	widths := map[fonts.GID]int{
+		1: 1,
+		2: 1,
+		3: 1,
+		4: 2,
+		5: 3,
+		6: 3,
+		7: 4,
+		// Glyphs that aren't consecutive are in separate ranges.
+		9:  4,
+		10: 4,
+	}
+
+	arr := makeCIDWidthArr(widths)
 
 	var out []int64
 	for i := 0; i < arr.Len(); i++ {
@@ -49,6 +36,7 @@ func TestCIDWidthArr(t *testing.T) {
 		4, 4, 2,
 		5, 6, 3,
 		7, 7, 4,
+		9, 10, 4,
 	}
 	if len(out) != len(exp) {
 		t.Fatalf("\n%v\nvs\n%v", out, exp)
@@ -59,3 +47,53 @@ func TestCIDWidthArr(t *testing.T) {
 		}
 	}
 }
+
+func TestCIDVerticalMetrics(t *testing.T) {
+	parser := core.NewParserFromString(`<< /Type /Font
+		/Subtype /Type0
+		/Encoding /Identity-V
+		/BaseFont /Vertical
+		/DescendantFonts [<<
+			/Type /Font
+			/Subtype /CIDFontType2
+			/BaseFont /Vertical
+			/CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >>
+			/DW 1000
+			/W [10 [500]]
+			/DW2 [900 -1100]
+			/W2 [20 [-500 250 800 -600 300 850] 30 32 -700 350 900]
+			>>]
+		>>`)
+	obj, err := parser.ParseDict()
+	if err != nil {
+		t.Fatalf("Failed to parse font dict: %v", err)
+	}
+	font, err := NewPdfFontFromPdfObject(obj)
+	if err != nil {
+		t.Fatalf("Failed to load font: %v", err)
+	}
+	if !font.IsVertical() {
+		t.Fatalf("Identity-V font should be vertical")
+	}
+
+	tests := []struct {
+		code     int
+		expected VerticalMetrics
+	}{
+		{1, VerticalMetrics{W1y: -1100, Vx: 500, Vy: 900}},
+		{10, VerticalMetrics{W1y: -1100, Vx: 250, Vy: 900}},
+		{20, VerticalMetrics{W1y: -500, Vx: 250, Vy: 800}},
+		{21, VerticalMetrics{W1y: -600, Vx: 300, Vy: 850}},
+		{31, VerticalMetrics{W1y: -700, Vx: 350, Vy: 900}},
+	}
+	for _, test := range tests {
+		m, ok := font.GetCharVerticalMetrics(textencoding.CharCode(test.code))
+		if !ok || m != test.expected {
+			t.Errorf("code=%d: got %+v (%t), expected %+v", test.code, m, ok, test.expected)
+		}
+	}
+
+	if NewStandard14FontMustCompile(HelveticaName).IsVertical() {
+		t.Errorf("Simple fonts should not be vertical")
+	}
+}
@@ -0,0 +1,179 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/cmap"
+	"github.com/gnaoh1379/unipdf/internal/outline"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
+)
+
+// embeddedGlyphNames maps the character codes of a font to the names of the glyphs they select in
+// the font program embedded in the font.
+type embeddedGlyphNames struct {
+	// codeNames maps the character codes of simple fonts to glyph names.
+	codeNames map[textencoding.CharCode]textencoding.GlyphName
+	// gidNames are the glyph names of composite fonts indexed by GID.
+	gidNames []textencoding.GlyphName
+	// cidToGID maps CIDs to GIDs for composite fonts. CIDs are GIDs if it is nil.
+	cidToGID []uint16
+}
+
+// EmbeddedGlyphName returns the name of the glyph selected by character code `code` in the font
+// program embedded in `font`. The glyph names are read from the built-in encodings of Type 1 and
+// CFF font programs, from the charsets of name-keyed CFF font programs and from the "post" tables
+// of TrueType font programs. It returns false if the font program is not embedded or doesn't name
+// the glyph.
+// These names can be used to find the text of codes that neither the font's ToUnicode CMap nor its
+// encoding maps to text.
+func (font *PdfFont) EmbeddedGlyphName(code textencoding.CharCode) (textencoding.GlyphName, bool) {
+	if font.glyphNames == nil {
+		font.glyphNames = font.loadEmbeddedGlyphNames()
+	}
+	g := font.glyphNames
+	if g.codeNames != nil {
+		name, ok := g.codeNames[code]
+		return name, ok && name != ""
+	}
+	if g.gidNames == nil {
+		return "", false
+	}
+
+	cid := code
+	if type0, ok := font.context.(*pdfFontType0); ok && type0.codeToCID != nil {
+		c, ok := type0.codeToCID.CharcodeToCID(cmap.CharCode(code))
+		if !ok {
+			return "", false
+		}
+		cid = textencoding.CharCode(c)
+	}
+	gid := int(cid)
+	if g.cidToGID != nil {
+		if gid >= len(g.cidToGID) {
+			return "", false
+		}
+		gid = int(g.cidToGID[gid])
+	}
+	if gid <= 0 || gid >= len(g.gidNames) || g.gidNames[gid] == "" {
+		return "", false
+	}
+	return g.gidNames[gid], true
+}
+
+// loadEmbeddedGlyphNames returns the glyph names of the font program embedded in `font`. The
+// returned glyph names are empty if the font program can't be read.
+func (font *PdfFont) loadEmbeddedGlyphNames() *embeddedGlyphNames {
+	g := &embeddedGlyphNames{}
+	descriptor := font.FontDescriptor()
+	if descriptor == nil {
+		return g
+	}
+	composite := font.baseFields().isCIDFont()
+	if type0, ok := font.context.(*pdfFontType0); ok && type0.DescendantFont != nil {
+		composite = true
+		if cidFont, ok := type0.DescendantFont.context.(*pdfCIDFontType2); ok {
+			g.cidToGID = loadCIDToGIDMap(cidFont.CIDToGIDMap)
+		}
+	}
+
+	switch {
+	case descriptor.FontFile != nil:
+		data, err := decodeFontFile(descriptor.FontFile)
+		if err != nil || composite {
+			return g
+		}
+		type1, err := outline.ParseType1(data)
+		if err != nil {
+			common.Log.Debug("ERROR: could not parse Type 1 font program. err=%v", err)
+			return g
+		}
+		g.codeNames = encodingGlyphNames(type1.Encoding)
+	case descriptor.FontFile3 != nil:
+		data, err := decodeFontFile(descriptor.FontFile3)
+		if err != nil {
+			return g
+		}
+		stream, _ := core.GetStream(descriptor.FontFile3)
+		var cff *outline.CFFFont
+		if subtype, _ := core.GetNameVal(stream.Get("Subtype")); subtype == "OpenType" {
+			cff, err = outline.ParseOpenType(data)
+		} else {
+			cff, err = outline.ParseCFF(data)
+		}
+		if err != nil {
+			common.Log.Debug("ERROR: could not parse CFF font program. err=%v", err)
+			return g
+		}
+		if !composite {
+			g.codeNames = encodingGlyphNames(cff.Encoding)
+			return g
+		}
+		g.gidNames = make([]textencoding.GlyphName, cff.NumGlyphs())
+		for gid := range g.gidNames {
+			g.gidNames[gid] = textencoding.GlyphName(cff.GlyphName(gid))
+		}
+	case descriptor.fontFile2 != nil:
+		ttf := descriptor.fontFile2
+		if composite {
+			g.gidNames = ttf.GlyphNames
+			return g
+		}
+		// The character codes of simple TrueType fonts select glyphs through the font's (3,0) or
+		// (1,0) cmap. Codes are offset by 0xF000 in (3,0) cmaps.
+		g.codeNames = map[textencoding.CharCode]textencoding.GlyphName{}
+		for code := 0; code <= 0xff; code++ {
+			gid, ok := ttf.Chars[rune(code)]
+			if !ok {
+				gid, ok = ttf.Chars[rune(0xf000+code)]
+			}
+			if ok && int(gid) < len(ttf.GlyphNames) {
+				g.codeNames[textencoding.CharCode(code)] = ttf.GlyphNames[gid]
+			}
+		}
+	}
+	return g
+}
+
+// decodeFontFile returns the decoded data of font file stream `obj`.
+func decodeFontFile(obj core.PdfObject) ([]byte, error) {
+	stream, ok := core.GetStream(obj)
+	if !ok {
+		common.Log.Debug("ERROR: font file is not a stream (%T)", obj)
+		return nil, core.ErrTypeError
+	}
+	return core.DecodeStream(stream)
+}
+
+// encodingGlyphNames returns the glyph names of the built-in encoding `encoding` of a font program
+// keyed by character code.
+func encodingGlyphNames(encoding map[byte]string) map[textencoding.CharCode]textencoding.GlyphName {
+	names := make(map[textencoding.CharCode]textencoding.GlyphName, len(encoding))
+	for code, name := range encoding {
+		names[textencoding.CharCode(code)] = textencoding.GlyphName(name)
+	}
+	return names
+}
+
+// loadCIDToGIDMap returns the CID to GID mapping in the CIDToGIDMap entry `obj` of a CIDFontType2
+// font, or nil if the mapping is the identity.
+func loadCIDToGIDMap(obj core.PdfObject) []uint16 {
+	stream, ok := core.GetStream(obj)
+	if !ok {
+		return nil
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		common.Log.Debug("ERROR: could not decode CIDToGIDMap. err=%v", err)
+		return nil
+	}
+	cidToGID := make([]uint16, len(data)/2)
+	for cid := range cidToGID {
+		cidToGID[cid] = uint16(data[2*cid])<<8 | uint16(data[2*cid+1])
+	}
+	return cidToGID
+}
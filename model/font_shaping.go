@@ -0,0 +1,186 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/cmap"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
+	"github.com/gnaoh1379/unipdf/model/internal/fonts"
+)
+
+// ShapedGlyph is a glyph that text is shaped into by PdfFont.ShapeText.
+// Width, XAdvance, XOffset and YOffset are in glyph space units (1/1000 em).
+type ShapedGlyph struct {
+	// Code is the character code that selects the glyph in the font. Fonts that shape text use
+	// Identity-H encoding so codes are written as 2 byte big-endian strings.
+	Code textencoding.CharCode
+	// Cluster is the index in the shaped text of the first rune of the cluster of runes that the
+	// glyph is part of. The glyphs of a cluster are drawn for all the runes of the cluster, e.g. the
+	// glyphs of a ligature or of a reordered Indic syllable.
+	Cluster int
+	// Text is the text that Code maps to in the font's ToUnicode CMap. It is the text that
+	// extracting the glyph returns.
+	Text string
+	// Width is the width of the glyph in the font's glyph widths.
+	Width float64
+	// XAdvance is the distance that the glyph moves the pen. It differs from Width for kerned
+	// glyphs and for marks positioned over other glyphs.
+	XAdvance float64
+	// XOffset and YOffset are the displacement of the glyph from the pen position.
+	XOffset, YOffset float64
+}
+
+// CanShapeText returns true if `font` can shape text with ShapeText. Only composite fonts created
+// from TrueType fonts with OpenType layout tables, e.g. by NewCompositePdfFontFromTTFFile, can.
+func (font *PdfFont) CanShapeText() bool {
+	t, ok := font.context.(*pdfFontType0)
+	return ok && t.shaping != nil
+}
+
+// ShapeText returns the glyphs that `text` is drawn with in `font`, in visual order.
+// The OpenType substitutions and positioning of the font's layout tables are applied to the text,
+// e.g. to form ligatures and the conjuncts of Indic scripts, select the joining forms of Arabic
+// letters, kern glyphs and place marks. `rtl` is true for text in a right-to-left direction, whose
+// glyphs are returned in the reverse order of the text. `text` should be in a single direction.
+// The glyphs are added to the font's widths and ToUnicode CMap so that they are written with the
+// font and the text can be extracted.
+// It returns false if `font` can't shape text. See CanShapeText.
+func (font *PdfFont) ShapeText(text []rune, rtl bool) ([]ShapedGlyph, bool) {
+	t, ok := font.context.(*pdfFontType0)
+	if !ok || t.shaping == nil {
+		return nil, false
+	}
+	return t.shapeText(text, rtl), true
+}
+
+// fontShaping is the state of composite fonts created from TrueType fonts that is used for shaping
+// text. The character codes of these fonts are GIDs.
+type fontShaping struct {
+	ttf *fonts.TtfType
+	k   float64 // Scale from font units to glyph space units.
+	// widths are the glyph space widths of the glyphs in the font's W array.
+	widths map[fonts.GID]int
+	// runeText is the ToUnicode mapping of the glyphs that runes are mapped to by the font's cmap.
+	runeText map[cmap.CharCode]rune
+	// glyphText is the text of glyphs produced by shaping that runeText doesn't map or maps to
+	// compatibility characters, e.g. ligatures and Arabic presentation forms. It overrides runeText.
+	glyphText map[fonts.GID]string
+	// used are the glyphs produced by shaping text. They are kept when the font is subset.
+	used map[fonts.GID]struct{}
+}
+
+// newFontShaping returns the shaping state of a font created from `ttf`, whose glyph space widths
+// are `widths` and whose ToUnicode mapping is `runeText`.
+func newFontShaping(ttf *fonts.TtfType, k float64, widths map[fonts.GID]int,
+	runeText map[cmap.CharCode]rune) *fontShaping {
+	return &fontShaping{
+		ttf:       ttf,
+		k:         k,
+		widths:    widths,
+		runeText:  runeText,
+		glyphText: map[fonts.GID]string{},
+		used:      map[fonts.GID]struct{}{},
+	}
+}
+
+// text returns the ToUnicode text of glyph `gid`.
+func (s *fontShaping) text(gid fonts.GID) (string, bool) {
+	if text, ok := s.glyphText[gid]; ok {
+		return text, true
+	}
+	if r, ok := s.runeText[cmap.CharCode(gid)]; ok {
+		return string(r), true
+	}
+	return "", false
+}
+
+// glyphs returns the glyphs produced by shaping text.
+func (s *fontShaping) glyphs() []fonts.GID {
+	gids := make([]fonts.GID, 0, len(s.used))
+	for gid := range s.used {
+		gids = append(gids, gid)
+	}
+	return gids
+}
+
+// toUnicode returns the ToUnicode mapping of all the glyphs the font maps runes to and of the
+// glyphs produced by shaping.
+func (s *fontShaping) toUnicode() map[cmap.CharCode]string {
+	codeToUnicode := make(map[cmap.CharCode]string, len(s.runeText)+len(s.glyphText))
+	for code, r := range s.runeText {
+		codeToUnicode[code] = string(r)
+	}
+	for gid, text := range s.glyphText {
+		codeToUnicode[cmap.CharCode(gid)] = text
+	}
+	return codeToUnicode
+}
+
+// shapeText shapes `text` in `font` and adds the glyphs that are not in the font's widths or
+// ToUnicode CMap to them.
+func (font *pdfFontType0) shapeText(text []rune, rtl bool) []ShapedGlyph {
+	s := font.shaping
+	glyphs := s.ttf.Shape(text, rtl)
+	shaped := make([]ShapedGlyph, len(glyphs))
+	changedWidths, changedText := false, false
+	for i, g := range glyphs {
+		s.used[g.GID] = struct{}{}
+		w, ok := s.widths[g.GID]
+		if !ok && int(g.GID) < len(s.ttf.Widths) {
+			w = int(s.k * float64(s.ttf.Widths[g.GID]))
+			s.widths[g.GID] = w
+			changedWidths = true
+		}
+		gtext, ok := s.text(g.GID)
+		// The text of the runes that a ligature is formed from is extracted rather than the
+		// compatibility character the font may map to the ligature, e.g. "ffi" rather than "ﬃ".
+		if len(g.Text) > 0 && (!ok || (gtext != string(g.Text) && isCompatibilityText(gtext))) {
+			gtext = string(g.Text)
+			s.glyphText[g.GID] = gtext
+			changedText = true
+		}
+		shaped[i] = ShapedGlyph{
+			Code:     textencoding.CharCode(g.GID),
+			Cluster:  g.Cluster,
+			Text:     gtext,
+			Width:    float64(w),
+			XAdvance: s.k * float64(g.XAdvance),
+			XOffset:  s.k * float64(g.XOffset),
+			YOffset:  s.k * float64(g.YOffset),
+		}
+	}
+
+	if changedWidths {
+		if cidfont, ok := font.DescendantFont.context.(*pdfCIDFontType2); ok {
+			for gid, w := range s.widths {
+				cidfont.widths[textencoding.CharCode(gid)] = float64(w)
+			}
+			if wArr, ok := cidfont.W.(*core.PdfIndirectObject); ok {
+				wArr.PdfObject = makeCIDWidthArr(s.widths)
+			} else {
+				cidfont.W = core.MakeIndirectObject(makeCIDWidthArr(s.widths))
+			}
+		}
+	}
+	if changedText {
+		font.toUnicodeCmap = cmap.NewToUnicodeCMapFromStrings(s.toUnicode())
+	}
+	if (changedWidths || changedText) && font.container != nil {
+		// Update the font objects that have already been written to pages.
+		font.ToPdfObject()
+	}
+	return shaped
+}
+
+// isCompatibilityText returns true if `text` contains compatibility characters, such as the Latin
+// ligatures and Arabic presentation forms, that are only encoded for compatibility with older
+// character sets.
+func isCompatibilityText(text string) bool {
+	return !norm.NFKC.IsNormalString(text)
+}
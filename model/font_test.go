@@ -885,3 +885,66 @@ func TestNewFontFromFile(t *testing.T) {
 		t.Fatalf("Failed to load font from file. err=%v", err)
 	}
 }
+
+// TestEmbeddedGlyphName tests that the glyph names of the character codes of a composite TrueType
+// font are read from its embedded font program.
+func TestEmbeddedGlyphName(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile("testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+	data, numMisses := font.StringToCharcodeBytes("fi!")
+	require.Zero(t, numMisses)
+
+	// Reload the font so that its font program is read from the font file stream.
+	font, err = model.NewPdfFontFromPdfObject(font.ToPdfObject())
+	require.NoError(t, err)
+	var glyphs []textencoding.GlyphName
+	for _, code := range font.BytesToCharcodes(data) {
+		glyph, ok := font.EmbeddedGlyphName(code)
+		require.True(t, ok, "code=%d", code)
+		glyphs = append(glyphs, glyph)
+	}
+	require.Equal(t, []textencoding.GlyphName{"f", "i", "exclam"}, glyphs)
+
+	_, ok := model.NewStandard14FontMustCompile(model.HelveticaName).EmbeddedGlyphName(65)
+	require.False(t, ok)
+}
+
+// TestShapeText tests that the glyphs that text is shaped into in a composite TrueType font are
+// added to the font's widths and ToUnicode CMap and are kept when the font is subset.
+func TestShapeText(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile("testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+	require.True(t, font.CanShapeText())
+	_, ok := model.NewStandard14FontMustCompile(model.HelveticaName).ShapeText([]rune("fi"), false)
+	require.False(t, ok)
+
+	glyphs, ok := font.ShapeText([]rune("office"), false)
+	require.True(t, ok)
+	require.Len(t, glyphs, 4)
+	lig := glyphs[1]
+	require.Equal(t, 1, lig.Cluster)
+	// The font maps U+FB03 "ﬃ" to the ligature glyph but its text is the shaped text.
+	require.Equal(t, "ffi", lig.Text)
+	require.NotZero(t, lig.Width)
+	require.Equal(t, 4, glyphs[2].Cluster)
+	for _, g := range glyphs {
+		metrics, ok := font.GetCharMetrics(g.Code)
+		require.True(t, ok)
+		require.Equal(t, g.Width, metrics.Wx, "code=%d", g.Code)
+	}
+
+	require.NoError(t, font.SubsetRegistered())
+	// Reload the font so that its widths and ToUnicode CMap are read from the font dictionary.
+	font, err = model.NewPdfFontFromPdfObject(font.ToPdfObject())
+	require.NoError(t, err)
+	var codes []textencoding.CharCode
+	for _, g := range glyphs {
+		codes = append(codes, g.Code)
+		metrics, ok := font.GetCharMetrics(g.Code)
+		require.True(t, ok)
+		require.Equal(t, g.Width, metrics.Wx, "code=%d", g.Code)
+	}
+	texts, _, numMisses := font.CharcodesToStrings(codes)
+	require.Zero(t, numMisses)
+	require.Equal(t, []string{"o", "ffi", "c", "e"}, texts)
+}
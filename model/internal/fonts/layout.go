@@ -0,0 +1,780 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This file parses the OpenType layout tables "GDEF", "GSUB" and "GPOS" that are used to shape
+// text. See https://docs.microsoft.com/en-us/typography/opentype/spec/chapter2
+// Only the parts of the tables used by Shape are parsed. Device tables, feature variations,
+// cursive attachment and reverse chaining substitutions are ignored.
+
+// layout holds the parsed OpenType layout tables of a font.
+type layout struct {
+	gdef *gdefTable
+	gsub *layoutTable
+	gpos *layoutTable
+}
+
+// parseLayout parses the "GDEF", "GSUB" and "GPOS" table data `gdef`, `gsub` and `gpos`. Any of
+// them may be nil.
+func parseLayout(gdef, gsub, gpos []byte) (*layout, error) {
+	l := &layout{gdef: &gdefTable{}}
+	var err error
+	if gdef != nil {
+		if l.gdef, err = parseGDEF(gdef); err != nil {
+			return nil, fmt.Errorf("GDEF: %v", err)
+		}
+	}
+	if gsub != nil {
+		if l.gsub, err = parseLayoutTable(gsub, true); err != nil {
+			return nil, fmt.Errorf("GSUB: %v", err)
+		}
+	}
+	if gpos != nil {
+		if l.gpos, err = parseLayoutTable(gpos, false); err != nil {
+			return nil, fmt.Errorf("GPOS: %v", err)
+		}
+	}
+	return l, nil
+}
+
+// otReader reads big-endian values from the data of an OpenType table. Reads outside the data
+// return 0 and set err, so tables can be parsed without checking every read.
+type otReader struct {
+	data []byte
+	err  error
+}
+
+func (r *otReader) fail(off int) {
+	if r.err == nil {
+		r.err = fmt.Errorf("offset %d outside %d byte table", off, len(r.data))
+	}
+}
+
+func (r *otReader) u16(off int) uint16 {
+	if off < 0 || off+2 > len(r.data) {
+		r.fail(off)
+		return 0
+	}
+	return binary.BigEndian.Uint16(r.data[off:])
+}
+
+func (r *otReader) i16(off int) int16 {
+	return int16(r.u16(off))
+}
+
+func (r *otReader) u32(off int) uint32 {
+	if off < 0 || off+4 > len(r.data) {
+		r.fail(off)
+		return 0
+	}
+	return binary.BigEndian.Uint32(r.data[off:])
+}
+
+func (r *otReader) tag(off int) string {
+	if off < 0 || off+4 > len(r.data) {
+		r.fail(off)
+		return ""
+	}
+	return string(r.data[off : off+4])
+}
+
+// u16s returns the `n` uint16 values at `off`.
+func (r *otReader) u16s(off, n int) []uint16 {
+	if n < 0 || off < 0 || off+2*n > len(r.data) {
+		r.fail(off)
+		return nil
+	}
+	vals := make([]uint16, n)
+	for i := range vals {
+		vals[i] = binary.BigEndian.Uint16(r.data[off+2*i:])
+	}
+	return vals
+}
+
+// offset returns the position of the table whose 16 bit offset from `base` is at `off`, or 0 if
+// the offset is null.
+func (r *otReader) offset(base, off int) int {
+	o := int(r.u16(off))
+	if o == 0 {
+		return 0
+	}
+	return base + o
+}
+
+// coverage maps the glyphs in a coverage table to their coverage indexes.
+type coverage map[GID]int
+
+// coverage parses the coverage table at `off`.
+func (r *otReader) coverage(off int) coverage {
+	cov := coverage{}
+	if off == 0 {
+		return cov
+	}
+	switch format := r.u16(off); format {
+	case 1:
+		for i, g := range r.u16s(off+4, int(r.u16(off+2))) {
+			cov[GID(g)] = i
+		}
+	case 2:
+		n := int(r.u16(off + 2))
+		for i := 0; i < n && r.err == nil; i++ {
+			rec := off + 4 + 6*i
+			start, end, index := int(r.u16(rec)), int(r.u16(rec+2)), int(r.u16(rec+4))
+			for g := start; g <= end; g++ {
+				cov[GID(g)] = index + g - start
+			}
+		}
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown coverage format %d", format)
+		}
+	}
+	return cov
+}
+
+// classDef maps glyphs to their classes in a class definition table. Glyphs not in the map are in
+// class 0.
+type classDef map[GID]uint16
+
+// classDef parses the class definition table at `off`.
+func (r *otReader) classDef(off int) classDef {
+	cd := classDef{}
+	if off == 0 {
+		return cd
+	}
+	switch format := r.u16(off); format {
+	case 1:
+		start := int(r.u16(off + 2))
+		for i, class := range r.u16s(off+6, int(r.u16(off+4))) {
+			cd[GID(start+i)] = class
+		}
+	case 2:
+		n := int(r.u16(off + 2))
+		for i := 0; i < n && r.err == nil; i++ {
+			rec := off + 4 + 6*i
+			start, end, class := int(r.u16(rec)), int(r.u16(rec+2)), r.u16(rec+4)
+			for g := start; g <= end; g++ {
+				cd[GID(g)] = class
+			}
+		}
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown class definition format %d", format)
+		}
+	}
+	return cd
+}
+
+// GDEF glyph classes.
+const (
+	glyphClassBase      = 1
+	glyphClassLigature  = 2
+	glyphClassMark      = 3
+	glyphClassComponent = 4
+)
+
+// gdefTable is a parsed "GDEF" table.
+type gdefTable struct {
+	classes           classDef
+	markAttachClasses classDef
+	markGlyphSets     []coverage
+}
+
+// parseGDEF parses the "GDEF" table `data`.
+func parseGDEF(data []byte) (*gdefTable, error) {
+	r := &otReader{data: data}
+	minor := r.u16(2)
+	g := &gdefTable{
+		classes:           r.classDef(r.offset(0, 4)),
+		markAttachClasses: r.classDef(r.offset(0, 10)),
+	}
+	if minor >= 2 {
+		if sets := r.offset(0, 12); sets != 0 {
+			n := int(r.u16(sets + 2))
+			for i := 0; i < n && r.err == nil; i++ {
+				g.markGlyphSets = append(g.markGlyphSets, r.coverage(sets+int(r.u32(sets+4+4*i))))
+			}
+		}
+	}
+	return g, r.err
+}
+
+// glyphClass returns the GDEF class of glyph `gid`.
+func (g *gdefTable) glyphClass(gid GID) uint16 {
+	return g.classes[gid]
+}
+
+// layoutTable is a parsed "GSUB" or "GPOS" table.
+type layoutTable struct {
+	scripts  map[string]*scriptTable
+	features []featureRecord
+	lookups  []*lookup
+}
+
+// scriptTable is the language systems of a script.
+type scriptTable struct {
+	defaultLangSys *langSys
+	langSys        map[string]*langSys
+}
+
+// langSys is the features used for a language system. They are indexes in layoutTable.features.
+type langSys struct {
+	required int // -1 if there is no required feature.
+	features []int
+}
+
+// featureRecord is a feature and the indexes of its lookups in layoutTable.lookups.
+type featureRecord struct {
+	tag     string
+	lookups []int
+}
+
+// Lookup flags.
+const (
+	lookupIgnoreBaseGlyphs    = 0x0002
+	lookupIgnoreLigatures     = 0x0004
+	lookupIgnoreMarks         = 0x0008
+	lookupUseMarkFilteringSet = 0x0010
+	lookupMarkAttachmentType  = 0xff00
+)
+
+// lookup is a GSUB or GPOS lookup. The types of its subtables depend on the lookup type.
+type lookup struct {
+	typ              uint16
+	flag             uint16
+	markFilteringSet int
+	subtables        []interface{}
+}
+
+// parseLayoutTable parses the "GSUB" table `data` if `gsub` is true or the "GPOS" table `data`
+// otherwise.
+func parseLayoutTable(data []byte, gsub bool) (*layoutTable, error) {
+	r := &otReader{data: data}
+	if major := r.u16(0); major != 1 {
+		return nil, fmt.Errorf("unsupported version %d", major)
+	}
+	t := &layoutTable{scripts: map[string]*scriptTable{}}
+
+	scriptList := r.offset(0, 4)
+	n := int(r.u16(scriptList))
+	for i := 0; i < n && r.err == nil; i++ {
+		rec := scriptList + 2 + 6*i
+		script := scriptList + int(r.u16(rec+4))
+		st := &scriptTable{langSys: map[string]*langSys{}}
+		if def := r.offset(script, script); def != 0 {
+			st.defaultLangSys = r.langSys(def)
+		}
+		numLangSys := int(r.u16(script + 2))
+		for j := 0; j < numLangSys && r.err == nil; j++ {
+			lrec := script + 4 + 6*j
+			st.langSys[r.tag(lrec)] = r.langSys(script + int(r.u16(lrec+4)))
+		}
+		t.scripts[r.tag(rec)] = st
+	}
+
+	featureList := r.offset(0, 6)
+	n = int(r.u16(featureList))
+	for i := 0; i < n && r.err == nil; i++ {
+		rec := featureList + 2 + 6*i
+		feature := featureList + int(r.u16(rec+4))
+		lookups := r.u16s(feature+4, int(r.u16(feature+2)))
+		fr := featureRecord{tag: r.tag(rec), lookups: make([]int, len(lookups))}
+		for j, l := range lookups {
+			fr.lookups[j] = int(l)
+		}
+		t.features = append(t.features, fr)
+	}
+
+	lookupList := r.offset(0, 8)
+	n = int(r.u16(lookupList))
+	for i := 0; i < n && r.err == nil; i++ {
+		t.lookups = append(t.lookups, r.lookup(lookupList+int(r.u16(lookupList+2+2*i)), gsub))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for _, fr := range t.features {
+		for _, l := range fr.lookups {
+			if l >= len(t.lookups) {
+				return nil, fmt.Errorf("feature %q has bad lookup index %d", fr.tag, l)
+			}
+		}
+	}
+	return t, nil
+}
+
+// langSys parses the language system table at `off`.
+func (r *otReader) langSys(off int) *langSys {
+	ls := &langSys{required: -1}
+	if req := r.u16(off + 2); req != 0xffff {
+		ls.required = int(req)
+	}
+	for _, f := range r.u16s(off+6, int(r.u16(off+4))) {
+		ls.features = append(ls.features, int(f))
+	}
+	return ls
+}
+
+// Lookup types.
+const (
+	gsubSingle          = 1
+	gsubMultiple        = 2
+	gsubAlternate       = 3
+	gsubLigature        = 4
+	gsubContext         = 5
+	gsubChainingContext = 6
+	gsubExtension       = 7
+
+	gposSingle          = 1
+	gposPair            = 2
+	gposMarkToBase      = 4
+	gposMarkToLigature  = 5
+	gposMarkToMark      = 6
+	gposContext         = 7
+	gposChainingContext = 8
+	gposExtension       = 9
+)
+
+// lookup parses the lookup table at `off` of a "GSUB" table if `gsub` is true or of a "GPOS"
+// table otherwise. Subtables of unsupported types are skipped.
+func (r *otReader) lookup(off int, gsub bool) *lookup {
+	lk := &lookup{typ: r.u16(off), flag: r.u16(off + 2)}
+	n := int(r.u16(off + 4))
+	if lk.flag&lookupUseMarkFilteringSet != 0 {
+		lk.markFilteringSet = int(r.u16(off + 6 + 2*n))
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		sub := off + int(r.u16(off+6+2*i))
+		typ := lk.typ
+		if (gsub && typ == gsubExtension) || (!gsub && typ == gposExtension) {
+			typ = r.u16(sub + 2)
+			sub += int(r.u32(sub + 4))
+		}
+		var st interface{}
+		if gsub {
+			st = r.gsubSubtable(typ, sub)
+		} else {
+			st = r.gposSubtable(typ, sub)
+		}
+		if st != nil {
+			lk.subtables = append(lk.subtables, st)
+		}
+		// The lookup type is that of the extension subtables for extension lookups.
+		lk.typ = typ
+	}
+	return lk
+}
+
+// singleSubst is a single substitution subtable. Glyphs are replaced by substitutes[i] for the
+// glyph with coverage index i, or by adding delta to their GIDs if substitutes is nil.
+type singleSubst struct {
+	cov         coverage
+	delta       int
+	substitutes []uint16
+}
+
+// multipleSubst is a multiple or alternate substitution subtable. sequences[i] is the sequence
+// that replaces the glyph with coverage index i, or its alternates.
+type multipleSubst struct {
+	cov       coverage
+	sequences [][]uint16
+	alternate bool
+}
+
+// ligatureSubst is a ligature substitution subtable. sets[i] is the ligatures that start with
+// the glyph with coverage index i in order of preference.
+type ligatureSubst struct {
+	cov  coverage
+	sets [][]ligature
+}
+
+// ligature replaces a glyph followed by components by glyph.
+type ligature struct {
+	glyph      GID
+	components []uint16
+}
+
+// gsubSubtable parses the GSUB subtable of lookup type `typ` at `off`. It returns nil for
+// unsupported types.
+func (r *otReader) gsubSubtable(typ uint16, off int) interface{} {
+	format := r.u16(off)
+	switch typ {
+	case gsubSingle:
+		st := &singleSubst{cov: r.coverage(r.offset(off, off+2))}
+		if format == 1 {
+			st.delta = int(r.i16(off + 4))
+		} else {
+			st.substitutes = r.u16s(off+6, int(r.u16(off+4)))
+		}
+		return st
+	case gsubMultiple, gsubAlternate:
+		st := &multipleSubst{cov: r.coverage(r.offset(off, off+2)), alternate: typ == gsubAlternate}
+		n := int(r.u16(off + 4))
+		for i := 0; i < n && r.err == nil; i++ {
+			seq := off + int(r.u16(off+6+2*i))
+			st.sequences = append(st.sequences, r.u16s(seq+2, int(r.u16(seq))))
+		}
+		return st
+	case gsubLigature:
+		st := &ligatureSubst{cov: r.coverage(r.offset(off, off+2))}
+		n := int(r.u16(off + 4))
+		for i := 0; i < n && r.err == nil; i++ {
+			set := off + int(r.u16(off+6+2*i))
+			var ligs []ligature
+			numLigs := int(r.u16(set))
+			for j := 0; j < numLigs && r.err == nil; j++ {
+				lig := set + int(r.u16(set+2+2*j))
+				ligs = append(ligs, ligature{
+					glyph:      GID(r.u16(lig)),
+					components: r.u16s(lig+4, int(r.u16(lig+2))-1),
+				})
+			}
+			st.sets = append(st.sets, ligs)
+		}
+		return st
+	case gsubContext:
+		return r.contextSubtable(off)
+	case gsubChainingContext:
+		return r.chainingContextSubtable(off)
+	}
+	return nil
+}
+
+// contextSubtable is a contextual or chaining contextual lookup subtable. It applies lookups to
+// the input glyphs when they and the glyphs before (backtrack) and after (lookahead) them match.
+//
+//	format 1: the glyphs are matched by GID. rules[i] are the rules for the first glyph with
+//	          coverage index i.
+//	format 2: the glyphs are matched by class. rules[i] are the rules for first glyph class i.
+//	format 3: the glyphs are matched by coverage. There is a single rule.
+type contextSubtable struct {
+	format uint16
+	cov    coverage
+	rules  [][]sequenceRule
+
+	backtrackClasses, inputClasses, lookaheadClasses classDef
+
+	backtrackCov, inputCov, lookaheadCov []coverage
+	records                              []lookupRecord
+}
+
+// sequenceRule is a rule of a format 1 or 2 contextSubtable. The backtrack glyphs are in reverse
+// order and input doesn't include the first glyph.
+type sequenceRule struct {
+	backtrack, input, lookahead []uint16
+	records                     []lookupRecord
+}
+
+// lookupRecord applies lookup `lookup` to input glyph `index` of a context match.
+type lookupRecord struct {
+	index  int
+	lookup int
+}
+
+// lookupRecords parses the `n` lookup records at `off`.
+func (r *otReader) lookupRecords(off, n int) []lookupRecord {
+	vals := r.u16s(off, 2*n)
+	records := make([]lookupRecord, len(vals)/2)
+	for i := range records {
+		records[i] = lookupRecord{index: int(vals[2*i]), lookup: int(vals[2*i+1])}
+	}
+	return records
+}
+
+// contextSubtable parses the contextual lookup subtable at `off`.
+func (r *otReader) contextSubtable(off int) *contextSubtable {
+	st := &contextSubtable{format: r.u16(off)}
+	switch st.format {
+	case 1, 2:
+		st.cov = r.coverage(r.offset(off, off+2))
+		sets := off + 6
+		if st.format == 2 {
+			st.inputClasses = r.classDef(r.offset(off, off+4))
+			sets = off + 8
+		}
+		n := int(r.u16(sets - 2))
+		for i := 0; i < n && r.err == nil; i++ {
+			set := r.offset(off, sets+2*i)
+			var rules []sequenceRule
+			numRules := 0
+			if set != 0 {
+				numRules = int(r.u16(set))
+			}
+			for j := 0; j < numRules && r.err == nil; j++ {
+				rule := set + int(r.u16(set+2+2*j))
+				numInput, numRecords := int(r.u16(rule)), int(r.u16(rule+2))
+				rules = append(rules, sequenceRule{
+					input:   r.u16s(rule+4, numInput-1),
+					records: r.lookupRecords(rule+4+2*(numInput-1), numRecords),
+				})
+			}
+			st.rules = append(st.rules, rules)
+		}
+	case 3:
+		numInput, numRecords := int(r.u16(off+2)), int(r.u16(off+4))
+		for i := 0; i < numInput && r.err == nil; i++ {
+			st.inputCov = append(st.inputCov, r.coverage(r.offset(off, off+6+2*i)))
+		}
+		st.records = r.lookupRecords(off+6+2*numInput, numRecords)
+	default:
+		return nil
+	}
+	return st
+}
+
+// chainingContextSubtable parses the chaining contextual lookup subtable at `off`.
+func (r *otReader) chainingContextSubtable(off int) *contextSubtable {
+	st := &contextSubtable{format: r.u16(off)}
+	switch st.format {
+	case 1, 2:
+		st.cov = r.coverage(r.offset(off, off+2))
+		sets := off + 6
+		if st.format == 2 {
+			st.backtrackClasses = r.classDef(r.offset(off, off+4))
+			st.inputClasses = r.classDef(r.offset(off, off+6))
+			st.lookaheadClasses = r.classDef(r.offset(off, off+8))
+			sets = off + 12
+		}
+		n := int(r.u16(sets - 2))
+		for i := 0; i < n && r.err == nil; i++ {
+			set := r.offset(off, sets+2*i)
+			var rules []sequenceRule
+			numRules := 0
+			if set != 0 {
+				numRules = int(r.u16(set))
+			}
+			for j := 0; j < numRules && r.err == nil; j++ {
+				p := set + int(r.u16(set+2+2*j))
+				var rule sequenceRule
+				n := int(r.u16(p))
+				rule.backtrack = r.u16s(p+2, n)
+				p += 2 + 2*n
+				n = int(r.u16(p))
+				rule.input = r.u16s(p+2, n-1)
+				p += 2 + 2*(n-1)
+				n = int(r.u16(p))
+				rule.lookahead = r.u16s(p+2, n)
+				p += 2 + 2*n
+				rule.records = r.lookupRecords(p+2, int(r.u16(p)))
+				rules = append(rules, rule)
+			}
+			st.rules = append(st.rules, rules)
+		}
+	case 3:
+		p := off + 2
+		coverages := func() []coverage {
+			n := int(r.u16(p))
+			var covs []coverage
+			for i := 0; i < n && r.err == nil; i++ {
+				covs = append(covs, r.coverage(r.offset(off, p+2+2*i)))
+			}
+			p += 2 + 2*n
+			return covs
+		}
+		st.backtrackCov = coverages()
+		st.inputCov = coverages()
+		st.lookaheadCov = coverages()
+		st.records = r.lookupRecords(p+2, int(r.u16(p)))
+	default:
+		return nil
+	}
+	return st
+}
+
+// valueRecord is a GPOS value record. Device table adjustments are ignored.
+type valueRecord struct {
+	xPlacement, yPlacement, xAdvance, yAdvance int16
+}
+
+// valueRecordSize returns the size in bytes of value records of format `format`.
+func valueRecordSize(format uint16) int {
+	size := 0
+	for ; format != 0; format >>= 1 {
+		size += 2 * int(format&1)
+	}
+	return size
+}
+
+// valueRecord parses the value record of format `format` at `off`.
+func (r *otReader) valueRecord(off int, format uint16) valueRecord {
+	var v valueRecord
+	fields := []*int16{&v.xPlacement, &v.yPlacement, &v.xAdvance, &v.yAdvance}
+	for i, field := range fields {
+		if format&(1<<uint(i)) != 0 {
+			*field = r.i16(off)
+			off += 2
+		}
+	}
+	return v
+}
+
+// singlePos is a single adjustment positioning subtable. values[i] is the adjustment of the glyph
+// with coverage index i, or values[0] is the adjustment of all glyphs if len(values) == 1.
+type singlePos struct {
+	cov    coverage
+	values []valueRecord
+}
+
+// pairPos is a pair adjustment positioning subtable.
+//
+//	format 1: pairSets[i] is the adjustments of pairs starting with the glyph with coverage index i.
+//	format 2: classValues[c1*class2Count+c2] is the adjustment of pairs of glyphs with classes c1
+//	          and c2.
+type pairPos struct {
+	format       uint16
+	cov          coverage
+	secondValues bool // True if the second glyph of pairs is adjusted.
+	pairSets     []map[GID][2]valueRecord
+
+	class1, class2 classDef
+	class2Count    int
+	classValues    [][2]valueRecord
+}
+
+// anchor is a GPOS anchor point.
+type anchor struct {
+	x, y int16
+}
+
+// markRecord is the class and anchor of a mark glyph.
+type markRecord struct {
+	class  uint16
+	anchor *anchor
+}
+
+// markAttachPos is a mark-to-base, mark-to-ligature or mark-to-mark attachment positioning
+// subtable. Marks in markCov are attached to the preceding glyph in baseCov.
+// bases[i][c] is the anchor for marks of class c on the base with coverage index i. For
+// mark-to-ligature subtables, ligatures[i][k][c] is the anchor on component k of ligature i.
+type markAttachPos struct {
+	typ       uint16
+	markCov   coverage
+	baseCov   coverage
+	marks     []markRecord
+	bases     [][]*anchor
+	ligatures [][][]*anchor
+}
+
+// gposSubtable parses the GPOS subtable of lookup type `typ` at `off`. It returns nil for
+// unsupported types.
+func (r *otReader) gposSubtable(typ uint16, off int) interface{} {
+	format := r.u16(off)
+	switch typ {
+	case gposSingle:
+		st := &singlePos{cov: r.coverage(r.offset(off, off+2))}
+		valueFormat := r.u16(off + 4)
+		if format == 1 {
+			st.values = []valueRecord{r.valueRecord(off+6, valueFormat)}
+		} else {
+			n, size := int(r.u16(off+6)), valueRecordSize(valueFormat)
+			for i := 0; i < n && r.err == nil; i++ {
+				st.values = append(st.values, r.valueRecord(off+8+i*size, valueFormat))
+			}
+		}
+		return st
+	case gposPair:
+		st := &pairPos{format: format, cov: r.coverage(r.offset(off, off+2))}
+		format1, format2 := r.u16(off+4), r.u16(off+6)
+		size1, size2 := valueRecordSize(format1), valueRecordSize(format2)
+		st.secondValues = format2 != 0
+		switch format {
+		case 1:
+			n := int(r.u16(off + 8))
+			for i := 0; i < n && r.err == nil; i++ {
+				set := off + int(r.u16(off+10+2*i))
+				pairs := map[GID][2]valueRecord{}
+				numPairs := int(r.u16(set))
+				for j := 0; j < numPairs && r.err == nil; j++ {
+					rec := set + 2 + j*(2+size1+size2)
+					pairs[GID(r.u16(rec))] = [2]valueRecord{
+						r.valueRecord(rec+2, format1),
+						r.valueRecord(rec+2+size1, format2),
+					}
+				}
+				st.pairSets = append(st.pairSets, pairs)
+			}
+		case 2:
+			st.class1 = r.classDef(r.offset(off, off+8))
+			st.class2 = r.classDef(r.offset(off, off+10))
+			class1Count, class2Count := int(r.u16(off+12)), int(r.u16(off+14))
+			st.class2Count = class2Count
+			rec := off + 16
+			for i := 0; i < class1Count*class2Count && r.err == nil; i++ {
+				st.classValues = append(st.classValues, [2]valueRecord{
+					r.valueRecord(rec, format1),
+					r.valueRecord(rec+size1, format2),
+				})
+				rec += size1 + size2
+			}
+		default:
+			return nil
+		}
+		return st
+	case gposMarkToBase, gposMarkToLigature, gposMarkToMark:
+		st := &markAttachPos{
+			typ:     typ,
+			markCov: r.coverage(r.offset(off, off+2)),
+			baseCov: r.coverage(r.offset(off, off+4)),
+		}
+		classCount := int(r.u16(off + 6))
+		markArray := r.offset(off, off+8)
+		n := int(r.u16(markArray))
+		for i := 0; i < n && r.err == nil; i++ {
+			rec := markArray + 2 + 4*i
+			st.marks = append(st.marks, markRecord{
+				class:  r.u16(rec),
+				anchor: r.anchor(r.offset(markArray, rec+2)),
+			})
+		}
+		baseArray := r.offset(off, off+10)
+		n = int(r.u16(baseArray))
+		for i := 0; i < n && r.err == nil; i++ {
+			if typ != gposMarkToLigature {
+				st.bases = append(st.bases, r.anchors(baseArray, baseArray+2+2*classCount*i, classCount))
+				continue
+			}
+			attach := r.offset(baseArray, baseArray+2+2*i)
+			var components [][]*anchor
+			numComponents := 0
+			if attach != 0 {
+				numComponents = int(r.u16(attach))
+			}
+			for k := 0; k < numComponents && r.err == nil; k++ {
+				components = append(components, r.anchors(attach, attach+2+2*classCount*k, classCount))
+			}
+			st.ligatures = append(st.ligatures, components)
+		}
+		return st
+	case gposContext:
+		return r.contextSubtable(off)
+	case gposChainingContext:
+		return r.chainingContextSubtable(off)
+	}
+	return nil
+}
+
+// anchors parses the `n` offsets from `base` to anchor tables at `off`.
+func (r *otReader) anchors(base, off, n int) []*anchor {
+	anchors := make([]*anchor, n)
+	for i := range anchors {
+		anchors[i] = r.anchor(r.offset(base, off+2*i))
+	}
+	return anchors
+}
+
+// anchor parses the anchor table at `off`. It returns nil if `off` is 0.
+func (r *otReader) anchor(off int) *anchor {
+	if off == 0 {
+		return nil
+	}
+	return &anchor{x: r.i16(off + 2), y: r.i16(off + 4)}
+}
@@ -0,0 +1,729 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"sort"
+	"unicode"
+)
+
+// Glyph is a glyph of text shaped by TtfType.Shape.
+type Glyph struct {
+	// GID is the glyph's index in the font.
+	GID GID
+	// Cluster is the index of the first rune of the text the glyph was formed from. Glyphs that
+	// were formed from the same runes, e.g. the glyphs of a reordered Indic syllable, have the same
+	// cluster and the runes of a cluster run up to the next cluster in logical order.
+	Cluster int
+	// Text is the text the glyph represents. It is nil for glyphs that can't be mapped to text on
+	// their own, e.g. the extra glyphs a glyph was decomposed into.
+	Text []rune
+	// XAdvance is the horizontal distance the text position moves after the glyph is drawn.
+	// XOffset and YOffset displace the glyph from the text position. All are in font units.
+	XAdvance, XOffset, YOffset int
+}
+
+// CanShape returns true if `ttf` has OpenType layout tables that Shape can apply.
+func (ttf *TtfType) CanShape() bool {
+	return ttf.layout != nil
+}
+
+// Shape returns the glyphs that draw `text` in `ttf` after applying the font's OpenType layout
+// features: glyph substitutions such as ligatures, Arabic joining forms and Indic conjuncts and
+// reordering, and glyph positioning such as kerning and mark attachment.
+// `text` is in logical order and is in a single direction, which is right to left if `rtl` is true.
+// The glyphs are returned in visual (left to right) order.
+// Runes that aren't in the font are drawn with glyph 0.
+func (ttf *TtfType) Shape(text []rune, rtl bool) []Glyph {
+	var infos []glyphInfo
+	for _, run := range scriptRuns(text) {
+		s := newShaper(ttf, run.script)
+		s.rtl = rtl
+		s.init(text, run.start, run.end)
+		s.shape()
+		infos = append(infos, s.glyphs...)
+	}
+	if rtl {
+		for i, j := 0, len(infos)-1; i < j; i, j = i+1, j-1 {
+			infos[i], infos[j] = infos[j], infos[i]
+		}
+		for i := range infos {
+			infos[i].attach = -infos[i].attach
+		}
+	}
+	resolveAttachments(infos)
+
+	glyphs := make([]Glyph, len(infos))
+	for i, g := range infos {
+		glyphs[i] = Glyph{
+			GID:      g.gid,
+			Cluster:  g.cluster,
+			Text:     g.text,
+			XAdvance: g.xAdvance,
+			XOffset:  g.xOffset,
+			YOffset:  g.yOffset,
+		}
+	}
+	return glyphs
+}
+
+// glyphInfo is a glyph in the shaping buffer.
+type glyphInfo struct {
+	gid       GID
+	cluster   int
+	text      []rune
+	codepoint rune   // The rune the glyph was mapped from before substitution.
+	mask      uint64 // The features that may be applied to the glyph.
+	class     uint16 // The glyph's GDEF class.
+
+	ligID   int  // The ligature the glyph forms, or a mark that was skipped while forming it follows.
+	ligComp int  // The (1 based) component of ligature ligID that a mark follows.
+	ligated bool // True if the glyph was formed by a ligature substitution.
+
+	// Script shaper state.
+	category uint8
+	syllable int
+	reph     bool
+
+	xAdvance, xOffset, yOffset int
+	// attach is the offset to the glyph the mark glyph is attached to, or 0 if it's not attached.
+	// The mark's anchor is (attachDX, attachDY) from that glyph's origin.
+	attach             int
+	attachDX, attachDY int
+}
+
+// shaper shapes a run of text in a single script.
+type shaper struct {
+	ttf       *TtfType
+	layout    *layout
+	plan      *shapePlan
+	script    *scriptInfo
+	rtl       bool
+	glyphs    []glyphInfo
+	nextLigID int
+}
+
+// newShaper returns a shaper for text in script `script`.
+func newShaper(ttf *TtfType, script *scriptInfo) *shaper {
+	s := &shaper{ttf: ttf, layout: ttf.layout, script: script}
+	switch {
+	case script != nil && script.tag == "arab":
+		s.plan = arabicPlan()
+	case script != nil && script.indicBase != 0:
+		s.plan = indicPlan()
+	default:
+		s.plan = defaultPlan()
+	}
+	if s.layout == nil {
+		s.layout = &layout{gdef: &gdefTable{}}
+	}
+	return s
+}
+
+// init fills the buffer with the glyphs of runes text[start:end].
+func (s *shaper) init(text []rune, start, end int) {
+	global := s.plan.globalMask()
+	for i := start; i < end; i++ {
+		r := text[i]
+		runes := []rune{r}
+		if s.plan.decompose != nil {
+			if d := s.plan.decompose(r); len(d) > 1 && s.hasGlyphs(d) {
+				runes = d
+			}
+		}
+		for k, c := range runes {
+			g := glyphInfo{
+				gid:       s.ttf.Chars[c],
+				cluster:   i,
+				codepoint: c,
+				mask:      global,
+			}
+			if k == 0 {
+				g.text = []rune{r}
+			}
+			g.class = s.glyphClass(g.gid, c)
+			s.glyphs = append(s.glyphs, g)
+		}
+	}
+}
+
+// hasGlyphs returns true if the font has glyphs for all of `runes`.
+func (s *shaper) hasGlyphs(runes []rune) bool {
+	for _, r := range runes {
+		if _, ok := s.ttf.Chars[r]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// glyphClass returns the GDEF class of glyph `gid` mapped from rune `r`. The class is derived
+// from `r` if the font doesn't classify the glyph.
+func (s *shaper) glyphClass(gid GID, r rune) uint16 {
+	if class := s.layout.gdef.glyphClass(gid); class != 0 {
+		return class
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me) {
+		return glyphClassMark
+	}
+	return glyphClassBase
+}
+
+// shape applies the plan's GSUB features, then its GPOS features.
+func (s *shaper) shape() {
+	if s.plan.setup != nil {
+		s.plan.setup(s)
+	}
+	if gsub := s.layout.gsub; gsub != nil {
+		if ls := s.langSys(gsub); ls != nil {
+			for k, stage := range s.plan.stages {
+				s.applyFeatures(gsub, ls, stage.features, k == 0)
+				if stage.pause != nil {
+					stage.pause(s)
+				}
+			}
+		}
+	}
+
+	for i := range s.glyphs {
+		g := &s.glyphs[i]
+		if int(g.gid) < len(s.ttf.Widths) {
+			g.xAdvance = int(s.ttf.Widths[g.gid])
+		}
+	}
+	if gpos := s.layout.gpos; gpos != nil {
+		if ls := s.langSys(gpos); ls != nil {
+			s.applyFeatures(gpos, ls, s.plan.gposFeatures, true)
+		}
+	}
+	// Marks don't advance the text position. Marks that weren't attached to other glyphs are
+	// moved back over the glyph before them in left to right text.
+	for i := range s.glyphs {
+		g := &s.glyphs[i]
+		if g.class != glyphClassMark && g.attach == 0 {
+			continue
+		}
+		if g.attach == 0 && !s.rtl {
+			g.xOffset -= g.xAdvance
+		}
+		g.xAdvance = 0
+	}
+}
+
+// langSys returns the default language system of the shaper's script in `table`.
+func (s *shaper) langSys(table *layoutTable) *langSys {
+	var tags []string
+	if s.script != nil {
+		tags = s.script.otTags
+	}
+	for _, tag := range append(tags, "DFLT", "dflt", "latn") {
+		if st, ok := table.scripts[tag]; ok && st.defaultLangSys != nil {
+			return st.defaultLangSys
+		}
+	}
+	return nil
+}
+
+// applyFeatures applies the lookups of features `features` of language system `ls` in `table` in
+// lookup order. Each lookup is applied to the glyphs whose masks include one of its features.
+// The language system's required feature is applied to all glyphs if `required` is true.
+func (s *shaper) applyFeatures(table *layoutTable, ls *langSys, features []string, required bool) {
+	lookupMasks := map[int]uint64{}
+	add := func(fi int, mask uint64) {
+		if fi < 0 || fi >= len(table.features) {
+			return
+		}
+		for _, l := range table.features[fi].lookups {
+			lookupMasks[l] |= mask
+		}
+	}
+	for _, fi := range ls.features {
+		if fi >= len(table.features) {
+			continue
+		}
+		tag := table.features[fi].tag
+		for _, f := range features {
+			if f == tag {
+				add(fi, s.plan.bits[tag])
+			}
+		}
+	}
+	if required {
+		add(ls.required, ^uint64(0))
+	}
+
+	lookups := make([]int, 0, len(lookupMasks))
+	for l := range lookupMasks {
+		lookups = append(lookups, l)
+	}
+	sort.Ints(lookups)
+	for _, l := range lookups {
+		s.applyLookup(table, l, lookupMasks[l])
+	}
+}
+
+// applyLookup applies lookup `index` of `table` to the glyphs whose masks share bits with `mask`.
+func (s *shaper) applyLookup(table *layoutTable, index int, mask uint64) {
+	lk := table.lookups[index]
+	for i := 0; i < len(s.glyphs); {
+		g := &s.glyphs[i]
+		if g.mask&mask == 0 || s.ignored(lk, g) {
+			i++
+			continue
+		}
+		if next, ok := s.applyAt(table, lk, i, 0); ok && next > i {
+			i = next
+		} else {
+			i++
+		}
+	}
+}
+
+// maxNesting is the maximum depth of lookups applied by contextual lookups.
+const maxNesting = 8
+
+// applyAt applies the first subtable of lookup `lk` of `table` that matches the glyph at `i`.
+// It returns the index of the glyph to continue at and true if a subtable matched.
+func (s *shaper) applyAt(table *layoutTable, lk *lookup, i, depth int) (int, bool) {
+	for _, st := range lk.subtables {
+		var next int
+		var ok bool
+		switch st := st.(type) {
+		case *singleSubst:
+			next, ok = s.singleSubst(st, i)
+		case *multipleSubst:
+			next, ok = s.multipleSubst(st, i)
+		case *ligatureSubst:
+			next, ok = s.ligatureSubst(lk, st, i)
+		case *contextSubtable:
+			next, ok = s.context(table, lk, st, i, depth)
+		case *singlePos:
+			next, ok = s.singlePos(st, i)
+		case *pairPos:
+			next, ok = s.pairPos(lk, st, i)
+		case *markAttachPos:
+			next, ok = s.markAttachPos(lk, st, i)
+		}
+		if ok {
+			return next, true
+		}
+	}
+	return 0, false
+}
+
+// ignored returns true if lookup `lk` skips glyph `g` because of its lookup flags.
+func (s *shaper) ignored(lk *lookup, g *glyphInfo) bool {
+	switch g.class {
+	case glyphClassBase:
+		return lk.flag&lookupIgnoreBaseGlyphs != 0
+	case glyphClassLigature:
+		return lk.flag&lookupIgnoreLigatures != 0
+	case glyphClassMark:
+		if lk.flag&lookupIgnoreMarks != 0 {
+			return true
+		}
+		if lk.flag&lookupUseMarkFilteringSet != 0 {
+			sets := s.layout.gdef.markGlyphSets
+			if lk.markFilteringSet >= len(sets) {
+				return true
+			}
+			_, ok := sets[lk.markFilteringSet][g.gid]
+			return !ok
+		}
+		if t := (lk.flag & lookupMarkAttachmentType) >> 8; t != 0 {
+			return s.layout.gdef.markAttachClasses[g.gid] != t
+		}
+	}
+	return false
+}
+
+// next returns the index of the first glyph after `i` that `lk` doesn't skip, or -1 if there is
+// none.
+func (s *shaper) next(lk *lookup, i int) int {
+	for i++; i < len(s.glyphs); i++ {
+		if !s.ignored(lk, &s.glyphs[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// prev returns the index of the last glyph before `i` that `lk` doesn't skip, or -1 if there is
+// none.
+func (s *shaper) prev(lk *lookup, i int) int {
+	for i--; i >= 0; i-- {
+		if !s.ignored(lk, &s.glyphs[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// setGlyph changes the glyph at `i` to `gid`. The glyph keeps its class if the font doesn't
+// classify `gid`.
+func (s *shaper) setGlyph(i int, gid GID) {
+	g := &s.glyphs[i]
+	g.gid = gid
+	if class := s.layout.gdef.glyphClass(gid); class != 0 {
+		g.class = class
+	}
+}
+
+func (s *shaper) singleSubst(st *singleSubst, i int) (int, bool) {
+	idx, ok := st.cov[s.glyphs[i].gid]
+	if !ok {
+		return 0, false
+	}
+	if st.substitutes == nil {
+		s.setGlyph(i, GID(int(s.glyphs[i].gid)+st.delta))
+	} else if idx < len(st.substitutes) {
+		s.setGlyph(i, GID(st.substitutes[idx]))
+	} else {
+		return 0, false
+	}
+	return i + 1, true
+}
+
+func (s *shaper) multipleSubst(st *multipleSubst, i int) (int, bool) {
+	idx, ok := st.cov[s.glyphs[i].gid]
+	if !ok || idx >= len(st.sequences) {
+		return 0, false
+	}
+	seq := st.sequences[idx]
+	if st.alternate {
+		if len(seq) == 0 {
+			return 0, false
+		}
+		s.setGlyph(i, GID(seq[0]))
+		return i + 1, true
+	}
+	if len(seq) == 0 {
+		s.glyphs = append(s.glyphs[:i], s.glyphs[i+1:]...)
+		return i, true
+	}
+	glyphs := make([]glyphInfo, len(seq))
+	for k := range glyphs {
+		glyphs[k] = s.glyphs[i]
+		if k > 0 {
+			glyphs[k].text = nil
+		}
+	}
+	s.glyphs = append(s.glyphs[:i], append(glyphs, s.glyphs[i+1:]...)...)
+	for k, gid := range seq {
+		s.setGlyph(i+k, GID(gid))
+	}
+	return i + len(seq), true
+}
+
+func (s *shaper) ligatureSubst(lk *lookup, st *ligatureSubst, i int) (int, bool) {
+	idx, ok := st.cov[s.glyphs[i].gid]
+	if !ok || idx >= len(st.sets) {
+		return 0, false
+	}
+	for _, lig := range st.sets[idx] {
+		positions := []int{i}
+		for j, k := i, 0; k < len(lig.components); k++ {
+			j = s.next(lk, j)
+			if j < 0 || s.glyphs[j].gid != GID(lig.components[k]) {
+				break
+			}
+			positions = append(positions, j)
+		}
+		if len(positions) == len(lig.components)+1 {
+			s.ligate(positions, lig.glyph)
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// ligate replaces the glyphs at `positions` by ligature glyph `gid`. The glyphs between them that
+// were skipped are kept after the ligature.
+func (s *shaper) ligate(positions []int, gid GID) {
+	first, last := positions[0], positions[len(positions)-1]
+	s.nextLigID++
+	cluster := s.glyphs[first].cluster
+	for k := first; k <= last; k++ {
+		if s.glyphs[k].cluster < cluster {
+			cluster = s.glyphs[k].cluster
+		}
+	}
+	var text []rune
+	comp := 0
+	for k := first; k <= last; k++ {
+		g := &s.glyphs[k]
+		g.cluster = cluster
+		if comp < len(positions) && positions[comp] == k {
+			text = append(text, g.text...)
+			comp++
+			continue
+		}
+		g.ligID = s.nextLigID
+		g.ligComp = comp
+	}
+	lig := &s.glyphs[first]
+	lig.text = text
+	lig.ligID = s.nextLigID
+	lig.ligComp = 0
+	lig.ligated = true
+	lig.class = glyphClassLigature
+	s.setGlyph(first, gid)
+	for k := len(positions) - 1; k > 0; k-- {
+		p := positions[k]
+		s.glyphs = append(s.glyphs[:p], s.glyphs[p+1:]...)
+	}
+}
+
+// context applies contextual lookup subtable `st` at glyph `i`.
+func (s *shaper) context(table *layoutTable, lk *lookup, st *contextSubtable, i, depth int) (int, bool) {
+	gid := s.glyphs[i].gid
+	// match matches the glyphs before and after `i` to the backtrack, input and lookahead
+	// sequences of lengths nb, ni and nl and applies `records` if they match.
+	match := func(nb, ni, nl int, back, input, ahead func(k int, gid GID) bool,
+		records []lookupRecord) (int, bool) {
+		positions := []int{i}
+		j := i
+		for k := 0; k < ni; k++ {
+			if j = s.next(lk, j); j < 0 || !input(k, s.glyphs[j].gid) {
+				return 0, false
+			}
+			positions = append(positions, j)
+		}
+		for k := 0; k < nl; k++ {
+			if j = s.next(lk, j); j < 0 || !ahead(k, s.glyphs[j].gid) {
+				return 0, false
+			}
+		}
+		j = i
+		for k := 0; k < nb; k++ {
+			if j = s.prev(lk, j); j < 0 || !back(k, s.glyphs[j].gid) {
+				return 0, false
+			}
+		}
+		return s.applyRecords(table, positions, records, depth), true
+	}
+
+	switch st.format {
+	case 1:
+		idx, ok := st.cov[gid]
+		if !ok || idx >= len(st.rules) {
+			return 0, false
+		}
+		for _, rule := range st.rules[idx] {
+			glyphs := func(seq []uint16) func(int, GID) bool {
+				return func(k int, gid GID) bool { return gid == GID(seq[k]) }
+			}
+			if next, ok := match(len(rule.backtrack), len(rule.input), len(rule.lookahead),
+				glyphs(rule.backtrack), glyphs(rule.input), glyphs(rule.lookahead), rule.records); ok {
+				return next, true
+			}
+		}
+	case 2:
+		if _, ok := st.cov[gid]; !ok {
+			return 0, false
+		}
+		class := int(st.inputClasses[gid])
+		if class >= len(st.rules) {
+			return 0, false
+		}
+		for _, rule := range st.rules[class] {
+			classes := func(seq []uint16, cd classDef) func(int, GID) bool {
+				return func(k int, gid GID) bool { return cd[gid] == seq[k] }
+			}
+			if next, ok := match(len(rule.backtrack), len(rule.input), len(rule.lookahead),
+				classes(rule.backtrack, st.backtrackClasses), classes(rule.input, st.inputClasses),
+				classes(rule.lookahead, st.lookaheadClasses), rule.records); ok {
+				return next, true
+			}
+		}
+	case 3:
+		if len(st.inputCov) == 0 {
+			return 0, false
+		}
+		if _, ok := st.inputCov[0][gid]; !ok {
+			return 0, false
+		}
+		covered := func(covs []coverage) func(int, GID) bool {
+			return func(k int, gid GID) bool { _, ok := covs[k][gid]; return ok }
+		}
+		return match(len(st.backtrackCov), len(st.inputCov)-1, len(st.lookaheadCov),
+			covered(st.backtrackCov), covered(st.inputCov[1:]), covered(st.lookaheadCov), st.records)
+	}
+	return 0, false
+}
+
+// applyRecords applies the lookups in `records` of a contextual lookup in `table` to the matched
+// input glyphs at `positions`. It returns the index of the glyph after the input glyphs.
+func (s *shaper) applyRecords(table *layoutTable, positions []int, records []lookupRecord,
+	depth int) int {
+	first, end := positions[0], positions[len(positions)-1]+1
+	if depth >= maxNesting {
+		return end
+	}
+	for _, rec := range records {
+		if rec.index >= len(positions) || rec.lookup >= len(table.lookups) {
+			continue
+		}
+		pos := positions[rec.index]
+		if pos < 0 || pos >= len(s.glyphs) {
+			continue
+		}
+		n := len(s.glyphs)
+		s.applyAt(table, table.lookups[rec.lookup], pos, depth+1)
+		if delta := len(s.glyphs) - n; delta != 0 {
+			for k := range positions {
+				if positions[k] > pos {
+					positions[k] += delta
+				}
+			}
+			end += delta
+		}
+	}
+	if end <= first {
+		end = first + 1
+	}
+	return end
+}
+
+// adjust adds the adjustments in `v` to the glyph at `i`.
+func (s *shaper) adjust(i int, v valueRecord) {
+	g := &s.glyphs[i]
+	g.xOffset += int(v.xPlacement)
+	g.yOffset += int(v.yPlacement)
+	g.xAdvance += int(v.xAdvance)
+}
+
+func (s *shaper) singlePos(st *singlePos, i int) (int, bool) {
+	idx, ok := st.cov[s.glyphs[i].gid]
+	if !ok || len(st.values) == 0 {
+		return 0, false
+	}
+	if len(st.values) == 1 {
+		idx = 0
+	} else if idx >= len(st.values) {
+		return 0, false
+	}
+	s.adjust(i, st.values[idx])
+	return i + 1, true
+}
+
+func (s *shaper) pairPos(lk *lookup, st *pairPos, i int) (int, bool) {
+	first := s.glyphs[i].gid
+	idx, ok := st.cov[first]
+	if !ok {
+		return 0, false
+	}
+	j := s.next(lk, i)
+	if j < 0 {
+		return 0, false
+	}
+	second := s.glyphs[j].gid
+	var values [2]valueRecord
+	switch st.format {
+	case 1:
+		if idx >= len(st.pairSets) {
+			return 0, false
+		}
+		if values, ok = st.pairSets[idx][second]; !ok {
+			return 0, false
+		}
+	case 2:
+		k := int(st.class1[first])*st.class2Count + int(st.class2[second])
+		if k >= len(st.classValues) {
+			return 0, false
+		}
+		values = st.classValues[k]
+	}
+	s.adjust(i, values[0])
+	s.adjust(j, values[1])
+	if st.secondValues {
+		return j + 1, true
+	}
+	return j, true
+}
+
+func (s *shaper) markAttachPos(lk *lookup, st *markAttachPos, i int) (int, bool) {
+	g := &s.glyphs[i]
+	markIdx, ok := st.markCov[g.gid]
+	if !ok || markIdx >= len(st.marks) {
+		return 0, false
+	}
+	mark := st.marks[markIdx]
+
+	// Marks are attached to the preceding base or ligature, or to the preceding mark for
+	// mark-to-mark attachment.
+	var j int
+	if st.typ == gposMarkToMark {
+		if j = s.prev(lk, i); j < 0 || s.glyphs[j].class != glyphClassMark {
+			return 0, false
+		}
+	} else {
+		for j = i - 1; j >= 0 && s.glyphs[j].class == glyphClassMark; j-- {
+		}
+		if j < 0 {
+			return 0, false
+		}
+	}
+	base := &s.glyphs[j]
+	baseIdx, ok := st.baseCov[base.gid]
+	if !ok {
+		return 0, false
+	}
+
+	var a *anchor
+	if st.typ == gposMarkToLigature {
+		if baseIdx >= len(st.ligatures) || len(st.ligatures[baseIdx]) == 0 {
+			return 0, false
+		}
+		components := st.ligatures[baseIdx]
+		k := len(components) - 1
+		if g.ligID != 0 && g.ligID == base.ligID && g.ligComp > 0 && g.ligComp <= len(components) {
+			k = g.ligComp - 1
+		}
+		if int(mark.class) < len(components[k]) {
+			a = components[k][mark.class]
+		}
+	} else if baseIdx < len(st.bases) && int(mark.class) < len(st.bases[baseIdx]) {
+		a = st.bases[baseIdx][mark.class]
+	}
+	if a == nil || mark.anchor == nil {
+		return 0, false
+	}
+	g.attach = j - i
+	g.attachDX = int(a.x) - int(mark.anchor.x)
+	g.attachDY = int(a.y) - int(mark.anchor.y)
+	return i + 1, true
+}
+
+// resolveAttachments sets the offsets of the attached mark glyphs in `glyphs`, which are in visual
+// order, so that their anchors are at the anchors of the glyphs they are attached to.
+func resolveAttachments(glyphs []glyphInfo) {
+	pen := make([]int, len(glyphs))
+	x := 0
+	for i, g := range glyphs {
+		pen[i] = x
+		x += g.xAdvance
+	}
+	resolved := make([]bool, len(glyphs))
+	var resolve func(i int)
+	resolve = func(i int) {
+		if resolved[i] {
+			return
+		}
+		resolved[i] = true
+		g := &glyphs[i]
+		j := i + g.attach
+		if g.attach == 0 || j < 0 || j >= len(glyphs) {
+			return
+		}
+		resolve(j)
+		g.xOffset = pen[j] + glyphs[j].xOffset + g.attachDX - pen[i]
+		g.yOffset = glyphs[j].yOffset + g.attachDY
+	}
+	for i := range glyphs {
+		resolve(i)
+	}
+}
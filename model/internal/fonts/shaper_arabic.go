@@ -0,0 +1,150 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import "unicode"
+
+// Arabic letters are drawn in different forms depending on whether they join the letters before
+// and after them. The forms are selected by the "isol", "fina", "medi" and "init" features.
+// See https://docs.microsoft.com/en-us/typography/script-development/arabic
+
+// arabicFormFeatures are the features that select the joining forms of Arabic letters.
+var arabicFormFeatures = []string{"isol", "fina", "fin2", "fin3", "medi", "med2", "init"}
+
+// arabicPlan returns the plan for Arabic text.
+func arabicPlan() *shapePlan {
+	stages := []featureStage{{features: []string{"ccmp", "locl"}}}
+	// Each form feature is applied separately so that their lookups don't interact.
+	for _, f := range arabicFormFeatures {
+		stages = append(stages, featureStage{features: []string{f}})
+	}
+	stages = append(stages,
+		featureStage{features: []string{"rlig"}},
+		featureStage{features: []string{"calt"}},
+		featureStage{features: []string{"liga", "clig", "mset"}},
+	)
+	p := newPlan(stages, append([]string{"curs"}, positionFeatures...), arabicFormFeatures...)
+	p.setup = setupArabic
+	return p
+}
+
+// setupArabic sets the masks of the Arabic joining form features in the buffer.
+func setupArabic(s *shaper) {
+	runes := make([]rune, len(s.glyphs))
+	for i, g := range s.glyphs {
+		runes[i] = g.codepoint
+	}
+	for i, form := range arabicForms(runes) {
+		if form != "" {
+			s.glyphs[i].mask |= s.plan.bits[form]
+		}
+	}
+}
+
+// Arabic joining types. See Unicode Standard section 9.2 "Arabic".
+const (
+	joinNone        = iota // U: doesn't join.
+	joinRight              // R: joins the letter before it.
+	joinDual               // D: joins the letters before and after it.
+	joinCausing            // C: joins the letters on both sides but doesn't change form.
+	joinTransparent        // T: doesn't affect joining.
+)
+
+// arabicForms returns the joining form feature of each rune in `runes`, or "" for runes that don't
+// change form.
+func arabicForms(runes []rune) []string {
+	types := make([]int, len(runes))
+	for i, r := range runes {
+		types[i] = arabicJoiningType(r)
+	}
+	joinsBefore := make([]bool, len(runes))
+	joinsAfter := make([]bool, len(runes))
+	prev := -1
+	for i, t := range types {
+		if t == joinTransparent {
+			continue
+		}
+		if prev >= 0 && (types[prev] == joinDual || types[prev] == joinCausing) && t != joinNone {
+			joinsAfter[prev] = true
+			joinsBefore[i] = true
+		}
+		prev = i
+	}
+
+	forms := make([]string, len(runes))
+	for i, t := range types {
+		switch t {
+		case joinRight:
+			forms[i] = "isol"
+			if joinsBefore[i] {
+				forms[i] = "fina"
+			}
+		case joinDual:
+			switch {
+			case joinsBefore[i] && joinsAfter[i]:
+				forms[i] = "medi"
+			case joinsBefore[i]:
+				forms[i] = "fina"
+			case joinsAfter[i]:
+				forms[i] = "init"
+			default:
+				forms[i] = "isol"
+			}
+		}
+	}
+	return forms
+}
+
+// arabicRightJoining are the ranges of right joining letters in the Arabic and Arabic Supplement
+// blocks.
+var arabicRightJoining = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0622, Hi: 0x0625, Stride: 1},
+		{Lo: 0x0627, Hi: 0x0629, Stride: 2},
+		{Lo: 0x062f, Hi: 0x0632, Stride: 1},
+		{Lo: 0x0648, Hi: 0x0648, Stride: 1},
+		{Lo: 0x0671, Hi: 0x0673, Stride: 1},
+		{Lo: 0x0675, Hi: 0x0677, Stride: 1},
+		{Lo: 0x0688, Hi: 0x0699, Stride: 1},
+		{Lo: 0x06c0, Hi: 0x06c0, Stride: 1},
+		{Lo: 0x06c3, Hi: 0x06cb, Stride: 1},
+		{Lo: 0x06cd, Hi: 0x06cf, Stride: 2},
+		{Lo: 0x06d2, Hi: 0x06d3, Stride: 1},
+		{Lo: 0x06d5, Hi: 0x06d5, Stride: 1},
+		{Lo: 0x06ee, Hi: 0x06ef, Stride: 1},
+		{Lo: 0x0759, Hi: 0x075b, Stride: 1},
+		{Lo: 0x076b, Hi: 0x076c, Stride: 1},
+		{Lo: 0x0771, Hi: 0x0771, Stride: 1},
+		{Lo: 0x0773, Hi: 0x0774, Stride: 1},
+		{Lo: 0x0778, Hi: 0x0779, Stride: 1},
+	},
+}
+
+// arabicNonJoining are the letters in the Arabic block that don't join.
+var arabicNonJoining = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0621, Hi: 0x0621, Stride: 1},
+		{Lo: 0x0674, Hi: 0x0674, Stride: 1},
+		{Lo: 0x06fd, Hi: 0x06fe, Stride: 1},
+	},
+}
+
+// arabicJoiningType returns the joining type of `r`.
+func arabicJoiningType(r rune) int {
+	switch {
+	case r == 0x0640 || r == 0x200d: // Tatweel and zero width joiner.
+		return joinCausing
+	case unicode.In(r, unicode.Mn, unicode.Me) || (unicode.Is(unicode.Cf, r) && r != 0x200c):
+		return joinTransparent
+	case unicode.Is(arabicRightJoining, r):
+		return joinRight
+	case unicode.Is(arabicNonJoining, r):
+		return joinNone
+	case (r >= 0x0620 && r <= 0x06ff || r >= 0x0750 && r <= 0x077f) && unicode.IsLetter(r):
+		return joinDual
+	}
+	return joinNone
+}
@@ -0,0 +1,255 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// Indic scripts are shaped a syllable at a time. The consonants of a syllable form conjuncts with
+// the "half", "blwf" and other features, pre-base vowel signs (matras) are drawn before the
+// consonants and a syllable initial Ra + Halant forms a reph that is drawn at the end of the
+// syllable.
+// See https://docs.microsoft.com/en-us/typography/script-development/devanagari
+
+// Indic character categories.
+const (
+	indicOther = iota
+	indicConsonant
+	indicVowel
+	indicMatra
+	indicHalant
+	indicNukta
+	indicModifier // Syllable modifiers such as Candrabindu, Anusvara and Visarga.
+	indicZWJ
+	indicZWNJ
+)
+
+// indicCategory returns the category of rune `r` in Indic script `script`. The Indic blocks share
+// a layout so the category is based on the rune's offset in the block.
+func indicCategory(script *scriptInfo, r rune) uint8 {
+	switch r {
+	case 0x200c:
+		return indicZWNJ
+	case 0x200d:
+		return indicZWJ
+	}
+	if script == nil || r < script.indicBase || r >= script.indicBase+0x80 {
+		return indicOther
+	}
+	switch off := r - script.indicBase; {
+	case off <= 0x03, off >= 0x51 && off <= 0x54:
+		return indicModifier
+	case off <= 0x14, off == 0x60, off == 0x61, off >= 0x72 && off <= 0x77:
+		return indicVowel
+	case off <= 0x39, off >= 0x58 && off <= 0x5f, off >= 0x78:
+		return indicConsonant
+	case off == 0x3c:
+		return indicNukta
+	case off == 0x4d:
+		return indicHalant
+	case off <= 0x4f && off != 0x3d, off >= 0x55 && off <= 0x57, off == 0x62, off == 0x63:
+		return indicMatra
+	}
+	return indicOther
+}
+
+// indicPreBaseMatras are the offsets in their blocks of the matras that are drawn before the
+// consonants of a syllable, keyed by script tag.
+var indicPreBaseMatras = map[string][]rune{
+	"deva": {0x3f, 0x4e},
+	"beng": {0x3f, 0x47, 0x48},
+	"guru": {0x3f},
+	"gujr": {0x3f},
+	"orya": {0x47},
+	"taml": {0x46, 0x47, 0x48},
+	"mlym": {0x46, 0x47, 0x48},
+}
+
+// indicPlan returns the plan for Indic text.
+func indicPlan() *shapePlan {
+	basic := []string{"nukt", "akhn", "rphf", "rkrf", "pref", "blwf", "abvf", "half", "pstf", "vatu", "cjct"}
+	stages := []featureStage{{features: []string{"locl", "ccmp"}}}
+	for _, f := range basic {
+		stages = append(stages, featureStage{features: []string{f}})
+	}
+	stages[len(stages)-1].pause = finalIndicReordering
+	stages = append(stages, featureStage{
+		features: []string{"init", "pres", "abvs", "blws", "psts", "haln", "calt", "clig"},
+	})
+	p := newPlan(stages, append(indicPositionFeats, positionFeatures...),
+		"rphf", "pref", "blwf", "abvf", "half", "pstf", "init")
+	p.setup = setupIndic
+	p.decompose = decomposeSplitMatra
+	return p
+}
+
+// decomposeSplitMatra returns the parts of `r` if it is a matra that is drawn in parts on both sides
+// of the consonants, such as Bengali O, or nil otherwise.
+func decomposeSplitMatra(r rune) []rune {
+	script := runeScript(r)
+	if script == nil || indicCategory(script, r) != indicMatra {
+		return nil
+	}
+	if d := []rune(norm.NFD.String(string(r))); len(d) > 1 {
+		return d
+	}
+	return nil
+}
+
+// indicSyllable is the glyphs [start, end) in the buffer that make up a syllable.
+type indicSyllable struct {
+	start, end int
+	consonant  bool // True for syllables that start with a consonant.
+}
+
+// setupIndic splits the buffer into syllables, sets the masks of the features that form conjuncts
+// and moves pre-base matras before the consonants of their syllables.
+func setupIndic(s *shaper) {
+	for i := range s.glyphs {
+		s.glyphs[i].category = indicCategory(s.script, s.glyphs[i].codepoint)
+	}
+	for k, syl := range s.indicSyllables() {
+		cluster := s.glyphs[syl.start].cluster
+		for i := syl.start; i < syl.end; i++ {
+			s.glyphs[i].syllable = k + 1
+			// The glyphs of a syllable can't be mapped to its runes one by one so they are all in
+			// the same cluster.
+			s.glyphs[i].cluster = cluster
+		}
+		if syl.consonant {
+			s.initialIndicReordering(s.glyphs[syl.start:syl.end])
+		}
+	}
+}
+
+// indicSyllables returns the syllables of the glyphs in the buffer. The syllables are found with a
+// simplified version of the syllable structure in the OpenType Indic script specifications:
+//
+//	consonant syllable: C N? (H ZW? C N?)* (H ZW? | (M N?)*) SM*
+//	vowel syllable:     V N? H? (M N?)* SM*
+func (s *shaper) indicSyllables() []indicSyllable {
+	n := len(s.glyphs)
+	cat := func(i int) uint8 {
+		if i < n {
+			return s.glyphs[i].category
+		}
+		return indicOther
+	}
+	optional := func(i int, c uint8) int {
+		if cat(i) == c {
+			i++
+		}
+		return i
+	}
+	var syllables []indicSyllable
+	for i := 0; i < n; {
+		start := i
+		c := cat(i)
+		i++
+		switch c {
+		case indicConsonant:
+			i = optional(i, indicNukta)
+			for cat(i) == indicHalant {
+				j := i + 1
+				if cat(j) == indicZWJ || cat(j) == indicZWNJ {
+					j++
+				}
+				if cat(j) != indicConsonant {
+					// The syllable ends with a dead consonant.
+					i = j
+					break
+				}
+				i = optional(j+1, indicNukta)
+			}
+		case indicVowel:
+			i = optional(i, indicNukta)
+			i = optional(i, indicHalant)
+		default:
+			syllables = append(syllables, indicSyllable{start: start, end: i})
+			continue
+		}
+		for cat(i) == indicMatra {
+			i = optional(i+1, indicNukta)
+		}
+		for cat(i) == indicModifier {
+			i++
+		}
+		syllables = append(syllables, indicSyllable{start: start, end: i, consonant: c == indicConsonant})
+	}
+	return syllables
+}
+
+// initialIndicReordering sets the masks of the features that form conjuncts in consonant syllable
+// `syl` and moves its pre-base matras to its start.
+func (s *shaper) initialIndicReordering(syl []glyphInfo) {
+	bits := s.plan.bits
+	first := 0
+	if s.script.ra != 0 && len(syl) >= 3 && syl[0].codepoint == s.script.indicBase+s.script.ra &&
+		syl[1].category == indicHalant && syl[2].category == indicConsonant {
+		syl[0].reph = true
+		syl[0].mask |= bits["rphf"]
+		syl[1].mask |= bits["rphf"]
+		first = 2
+	}
+
+	// The base consonant is the last consonant of the syllable.
+	base := -1
+	for i := len(syl) - 1; i >= first; i-- {
+		if syl[i].category == indicConsonant {
+			base = i
+			break
+		}
+	}
+	if base < 0 {
+		return
+	}
+	// Consonants before the base are drawn in half forms and consonants after it in below-base,
+	// above-base or post-base forms.
+	for i := first; i < base; i++ {
+		syl[i].mask |= bits["half"]
+	}
+	for i := base + 1; i < len(syl) && syl[i].category != indicMatra && syl[i].category != indicModifier; i++ {
+		syl[i].mask |= bits["blwf"] | bits["abvf"] | bits["pstf"]
+	}
+
+	for i := base + 1; i < len(syl); i++ {
+		if syl[i].category == indicMatra && s.isPreBaseMatra(syl[i].codepoint) {
+			m := syl[i]
+			copy(syl[1:i+1], syl[:i])
+			syl[0] = m
+		}
+	}
+}
+
+// isPreBaseMatra returns true if `r` is a matra that is drawn before the consonants of its
+// syllable.
+func (s *shaper) isPreBaseMatra(r rune) bool {
+	for _, off := range indicPreBaseMatras[s.script.tag] {
+		if r == s.script.indicBase+off {
+			return true
+		}
+	}
+	return false
+}
+
+// finalIndicReordering moves the rephs formed by the "rphf" feature to the end of their syllables.
+func finalIndicReordering(s *shaper) {
+	for start := 0; start < len(s.glyphs); {
+		end := start + 1
+		for end < len(s.glyphs) && s.glyphs[end].syllable == s.glyphs[start].syllable {
+			end++
+		}
+		for i := start; i < end-1; i++ {
+			if g := s.glyphs[i]; g.reph && g.ligated {
+				copy(s.glyphs[i:end-1], s.glyphs[i+1:end])
+				s.glyphs[end-1] = g
+				break
+			}
+		}
+		start = end
+	}
+}
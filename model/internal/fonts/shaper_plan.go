@@ -0,0 +1,159 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import "unicode"
+
+// scriptInfo describes a script that text is shaped in.
+type scriptInfo struct {
+	tag    string
+	table  *unicode.RangeTable
+	otTags []string // The OpenType script tags in order of preference.
+	// indicBase is the first rune of the script's block for Indic scripts, whose blocks share a
+	// layout. It is 0 for other scripts.
+	indicBase rune
+	// ra is the offset in the block of the consonant that forms a reph, or 0 if it doesn't.
+	ra rune
+}
+
+// scripts are the scripts that text is split into for shaping. Text in other scripts is shaped with
+// the fonts' default language system.
+var scripts = []*scriptInfo{
+	{tag: "latn", table: unicode.Latin, otTags: []string{"latn"}},
+	{tag: "cyrl", table: unicode.Cyrillic, otTags: []string{"cyrl"}},
+	{tag: "grek", table: unicode.Greek, otTags: []string{"grek"}},
+	{tag: "arab", table: unicode.Arabic, otTags: []string{"arab"}},
+	{tag: "hebr", table: unicode.Hebrew, otTags: []string{"hebr"}},
+	{tag: "thai", table: unicode.Thai, otTags: []string{"thai"}},
+	{tag: "deva", table: unicode.Devanagari, otTags: []string{"dev2", "deva"}, indicBase: 0x0900, ra: 0x30},
+	{tag: "beng", table: unicode.Bengali, otTags: []string{"bng2", "beng"}, indicBase: 0x0980, ra: 0x30},
+	{tag: "guru", table: unicode.Gurmukhi, otTags: []string{"gur2", "guru"}, indicBase: 0x0a00},
+	{tag: "gujr", table: unicode.Gujarati, otTags: []string{"gjr2", "gujr"}, indicBase: 0x0a80, ra: 0x30},
+	{tag: "orya", table: unicode.Oriya, otTags: []string{"ory2", "orya"}, indicBase: 0x0b00, ra: 0x30},
+	{tag: "taml", table: unicode.Tamil, otTags: []string{"tml2", "taml"}, indicBase: 0x0b80},
+	{tag: "telu", table: unicode.Telugu, otTags: []string{"tel2", "telu"}, indicBase: 0x0c00, ra: 0x30},
+	{tag: "knda", table: unicode.Kannada, otTags: []string{"knd2", "knda"}, indicBase: 0x0c80, ra: 0x30},
+	{tag: "mlym", table: unicode.Malayalam, otTags: []string{"mlm2", "mlym"}, indicBase: 0x0d00},
+}
+
+// runeScript returns the script of `r`, or nil if `r` is used in several scripts (e.g. spaces,
+// digits and combining marks) or is in a script that isn't in `scripts`.
+func runeScript(r rune) *scriptInfo {
+	for _, script := range scripts {
+		if unicode.Is(script.table, r) {
+			return script
+		}
+	}
+	return nil
+}
+
+// scriptRun is a run of text[start:end] in a single script.
+type scriptRun struct {
+	start, end int
+	script     *scriptInfo
+}
+
+// scriptRuns splits `text` into runs of the same script. Runes without a script of their own are
+// added to the run they are in, or to the following run at the start of the text.
+func scriptRuns(text []rune) []scriptRun {
+	var runs []scriptRun
+	for i, r := range text {
+		script := runeScript(r)
+		if len(runs) == 0 {
+			runs = append(runs, scriptRun{start: i, end: i + 1, script: script})
+			continue
+		}
+		run := &runs[len(runs)-1]
+		if script == nil || run.script == script {
+			run.end = i + 1
+			continue
+		}
+		if run.script == nil {
+			run.script = script
+			run.end = i + 1
+			continue
+		}
+		runs = append(runs, scriptRun{start: i, end: i + 1, script: script})
+	}
+	return runs
+}
+
+// featureStage is a group of GSUB features whose lookups are applied together in lookup order.
+// pause, if not nil, is called after the lookups are applied.
+type featureStage struct {
+	features []string
+	pause    func(s *shaper)
+}
+
+// shapePlan describes how text in a script is shaped.
+type shapePlan struct {
+	stages       []featureStage
+	gposFeatures []string
+	// masked are the features that are only applied to the glyphs the script shaper selects by
+	// setting their mask bits. The other features are applied to all glyphs.
+	masked map[string]bool
+	// bits are the mask bits of the features.
+	bits map[string]uint64
+	// setup is called to prepare the buffer for the GSUB features, e.g. to set the mask bits of
+	// masked features and to reorder glyphs.
+	setup func(s *shaper)
+	// decompose returns the runes that rune `r` is decomposed into before shaping, or nil if it
+	// isn't decomposed.
+	decompose func(r rune) []rune
+}
+
+// Features applied by all plans.
+var (
+	commonFeatures     = []string{"ccmp", "locl", "rlig"}
+	ligatureFeatures   = []string{"rclt", "calt", "liga", "clig"}
+	positionFeatures   = []string{"kern", "mark", "mkmk"}
+	indicPositionFeats = []string{"dist", "abvm", "blwm"}
+)
+
+// newPlan returns a plan that applies GSUB `stages` and GPOS features `gpos`. Features in `masked`
+// are only applied to glyphs selected by the script shaper.
+func newPlan(stages []featureStage, gpos []string, masked ...string) *shapePlan {
+	p := &shapePlan{
+		stages:       stages,
+		gposFeatures: gpos,
+		masked:       map[string]bool{},
+		bits:         map[string]uint64{},
+	}
+	for _, f := range masked {
+		p.masked[f] = true
+	}
+	add := func(features []string) {
+		for _, f := range features {
+			if _, ok := p.bits[f]; !ok {
+				p.bits[f] = 1 << uint(len(p.bits))
+			}
+		}
+	}
+	for _, stage := range stages {
+		add(stage.features)
+	}
+	add(gpos)
+	return p
+}
+
+// globalMask returns the mask bits of the features that are applied to all glyphs.
+func (p *shapePlan) globalMask() uint64 {
+	var mask uint64
+	for f, bit := range p.bits {
+		if !p.masked[f] {
+			mask |= bit
+		}
+	}
+	return mask
+}
+
+// defaultPlan returns the plan for scripts that don't need a script shaper.
+func defaultPlan() *shapePlan {
+	return newPlan([]featureStage{
+		{features: commonFeatures},
+		{features: ligatureFeatures},
+	}, positionFeatures)
+}
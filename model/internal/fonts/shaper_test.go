@@ -0,0 +1,128 @@
+package fonts
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// shapedText returns the GIDs and texts of the glyphs that `text` is shaped into in `ttf`.
+func shapedText(ttf *TtfType, text string, rtl bool) ([]GID, []string) {
+	var gids []GID
+	var texts []string
+	for _, g := range ttf.Shape([]rune(text), rtl) {
+		gids = append(gids, g.GID)
+		texts = append(texts, string(g.Text))
+	}
+	return gids, texts
+}
+
+func parseTestFont(t *testing.T, path string) *TtfType {
+	ttf, err := TtfParseFile(filepath.Join(fontDir, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ttf.CanShape() {
+		t.Fatalf("%s has no layout tables", path)
+	}
+	return &ttf
+}
+
+func TestShapeLatin(t *testing.T) {
+	ttf := parseTestFont(t, "roboto/Roboto-Regular.ttf")
+	o, f, i, c, e := ttf.Chars['o'], ttf.Chars['f'], ttf.Chars['i'], ttf.Chars['c'], ttf.Chars['e']
+
+	// "ffi" is drawn with a ligature glyph that represents its three runes.
+	glyphs := ttf.Shape([]rune("office"), false)
+	if len(glyphs) != 4 {
+		t.Fatalf("office: %d glyphs %+v", len(glyphs), glyphs)
+	}
+	if g := glyphs[1]; g.GID == f || g.Cluster != 1 || string(g.Text) != "ffi" {
+		t.Errorf("office: bad ligature %+v", g)
+	}
+	if g := glyphs[2]; g.GID != c || g.Cluster != 4 {
+		t.Errorf("office: bad glyph after ligature %+v", g)
+	}
+	if glyphs[0].GID != o || glyphs[3].GID != e {
+		t.Errorf("office: %+v", glyphs)
+	}
+	if gids, _ := shapedText(ttf, "fi", false); len(gids) != 1 || gids[0] == f || gids[0] == i {
+		t.Errorf("fi: no ligature %v", gids)
+	}
+
+	// "AV" is kerned.
+	glyphs = ttf.Shape([]rune("AV"), false)
+	width := int(ttf.Widths[ttf.Chars['A']]) + int(ttf.Widths[ttf.Chars['V']])
+	if kerned := glyphs[0].XAdvance + glyphs[1].XAdvance; kerned >= width {
+		t.Errorf("AV: not kerned. width=%d kerned=%d", width, kerned)
+	}
+}
+
+func TestShapeDevanagari(t *testing.T) {
+	ttf := parseTestFont(t, "FreeSans.ttf")
+	ka, ssa, i := ttf.Chars['क'], ttf.Chars['ष'], ttf.Chars['ि']
+
+	// The i matra is drawn before the consonant it follows.
+	gids, texts := shapedText(ttf, "कि", false)
+	if !reflect.DeepEqual(gids, []GID{i, ka}) || !reflect.DeepEqual(texts, []string{"ि", "क"}) {
+		t.Errorf("कि: gids=%v texts=%q", gids, texts)
+	}
+	// Ka + Halant + Ssa form a conjunct.
+	gids, texts = shapedText(ttf, "क्ष", false)
+	if len(gids) != 1 || gids[0] == ka || gids[0] == ssa || texts[0] != "क्ष" {
+		t.Errorf("क्ष: gids=%v texts=%q", gids, texts)
+	}
+	// Sa + Halant before the base Ta is drawn in half form and the i matra is drawn before both.
+	gids, texts = shapedText(ttf, "स्ति", false)
+	if len(gids) != 3 || gids[0] != i || !reflect.DeepEqual(texts, []string{"ि", "स्", "त"}) {
+		t.Errorf("स्ति: gids=%v texts=%q", gids, texts)
+	}
+	// The reph formed by Ra + Halant is moved to the end of the syllable.
+	glyphs := ttf.Shape([]rune("शर्म"), false)
+	if len(glyphs) != 3 || string(glyphs[1].Text) != "म" || string(glyphs[2].Text) != "र्" {
+		t.Errorf("शर्म: %+v", glyphs)
+	}
+	// All glyphs of a reordered syllable are in the cluster of its first rune.
+	for _, g := range glyphs[1:] {
+		if g.Cluster != 1 {
+			t.Errorf("शर्म: bad cluster %+v", g)
+		}
+	}
+}
+
+func TestShapeRTL(t *testing.T) {
+	ttf := parseTestFont(t, "FreeSans.ttf")
+	text := []rune("שלום")
+	glyphs := ttf.Shape(text, true)
+	if len(glyphs) != len(text) {
+		t.Fatalf("%d glyphs", len(glyphs))
+	}
+	// The glyphs are in visual order.
+	for k, g := range glyphs {
+		r := text[len(text)-1-k]
+		if g.GID != ttf.Chars[r] || g.Cluster != len(text)-1-k {
+			t.Errorf("glyph %d: %+v want %q", k, g, r)
+		}
+	}
+}
+
+func TestArabicForms(t *testing.T) {
+	cases := []struct {
+		text  string
+		forms []string
+	}{
+		// Alef doesn't join the letter after it.
+		{"سلام", []string{"init", "medi", "fina", "isol"}},
+		// Marks don't affect joining.
+		{"بَب", []string{"init", "", "fina"}},
+		// Hamza doesn't join.
+		{"بءب", []string{"isol", "", "isol"}},
+		// Tatweel joins the letters on both sides.
+		{"بـ", []string{"init", ""}},
+	}
+	for _, c := range cases {
+		if forms := arabicForms([]rune(c.text)); !reflect.DeepEqual(forms, c.forms) {
+			t.Errorf("%q: forms=%q want %q", c.text, forms, c.forms)
+		}
+	}
+}
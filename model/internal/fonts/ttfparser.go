@@ -107,6 +107,10 @@ type TtfType struct {
 	Chars map[rune]GID
 	// GlyphNames is a list of glyphs from the "post" section of the TrueType file.
 	GlyphNames []GlyphName
+
+	// layout holds the OpenType layout tables used by Shape. It is nil if the font has no usable
+	// GSUB or GPOS table.
+	layout *layout
 }
 
 // MakeToUnicode returns a ToUnicode CMap based on the encoding of `ttf`.
@@ -157,6 +161,7 @@ type ttfParser struct {
 	rec              TtfType
 	f                io.ReadSeeker
 	tables           map[string]uint32
+	tableLengths     map[string]uint32
 	numberOfHMetrics uint16
 	numGlyphs        uint16
 }
@@ -219,6 +224,7 @@ func (t *ttfParser) Parse() (TtfType, error) {
 	numTables := int(t.ReadUShort())
 	t.Skip(3 * 2) // searchRange, entrySelector, rangeShift
 	t.tables = make(map[string]uint32)
+	t.tableLengths = make(map[string]uint32)
 	var tag string
 	for j := 0; j < numTables; j++ {
 		tag, err = t.ReadStr(4)
@@ -227,8 +233,9 @@ func (t *ttfParser) Parse() (TtfType, error) {
 		}
 		t.Skip(4) // checkSum
 		offset := t.ReadULong()
-		t.Skip(4) // length
+		length := t.ReadULong()
 		t.tables[tag] = offset
+		t.tableLengths[tag] = length
 	}
 
 	common.Log.Trace(describeTables(t.tables))
@@ -302,10 +309,51 @@ func (t *ttfParser) ParseComponents() error {
 			return err
 		}
 	}
+	t.parseLayoutTables()
 
 	return nil
 }
 
+// parseLayoutTables parses the OpenType layout tables "GDEF", "GSUB" and "GPOS". Errors in these
+// tables are not fatal as the font can still be used without shaping.
+func (t *ttfParser) parseLayoutTables() {
+	_, hasGSUB := t.tables["GSUB"]
+	_, hasGPOS := t.tables["GPOS"]
+	if !hasGSUB && !hasGPOS {
+		return
+	}
+	var data [3][]byte
+	for i, tag := range []string{"GDEF", "GSUB", "GPOS"} {
+		if _, ok := t.tables[tag]; !ok {
+			continue
+		}
+		b, err := t.readTable(tag)
+		if err != nil {
+			common.Log.Debug("ERROR: could not read %s table. err=%v", tag, err)
+			return
+		}
+		data[i] = b
+	}
+	layout, err := parseLayout(data[0], data[1], data[2])
+	if err != nil {
+		common.Log.Debug("ERROR: could not parse layout tables. Text won't be shaped. err=%v", err)
+		return
+	}
+	t.rec.layout = layout
+}
+
+// readTable returns the data of table `tag`.
+func (t *ttfParser) readTable(tag string) ([]byte, error) {
+	if err := t.Seek(tag); err != nil {
+		return nil, err
+	}
+	data := make([]byte, t.tableLengths[tag])
+	if _, err := io.ReadFull(t.f, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (t *ttfParser) ParseHead() error {
 	if err := t.Seek("head"); err != nil {
 		return err
@@ -0,0 +1,277 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+)
+
+// OCRWord is a word found by optical character recognition and its position on a page.
+type OCRWord struct {
+	// Text is the text of the word.
+	Text string
+	// BBox is the bounding box of the word. It is in page coordinates unless the ImageWidth and
+	// ImageHeight options of OCRTextLayerOptions are set.
+	BBox PdfRectangle
+}
+
+// OCRTextLayerOptions contains options for configuring the OCR text layer added by
+// AddOCRTextLayer.
+type OCRTextLayerOptions struct {
+	// Font is the font the words are written in. It must be able to encode the text of all the
+	// words. The text is invisible so only the font's encoding and glyph widths matter.
+	// The words are written in Helvetica with WinAnsiEncoding if Font is nil. A composite font
+	// such as one from NewCompositePdfFontFromTTFFile should be used for text in other scripts.
+	Font *PdfFont
+
+	// ImageWidth and ImageHeight are the dimensions in pixels of the image that the words were
+	// recognized in. If they are set, the word bounding boxes are in the pixel coordinates of the
+	// image, whose y axis points down as in hOCR and ALTO files, and the image is taken to fill
+	// the page as it is displayed: its crop box (media box if it has none) rotated by its Rotate
+	// entry. The words are then written along the horizontal axis of the displayed page.
+	ImageWidth  float64
+	ImageHeight float64
+}
+
+// AddOCRTextLayer adds `words` to the page as invisible text (text rendering mode 3) so that the
+// text of a scanned page can be searched, selected and extracted.
+// Each word is written on a single line whose font size and horizontal scaling are chosen so that
+// it fills its bounding box. Words with empty text or empty bounding boxes are skipped.
+func (p *PdfPage) AddOCRTextLayer(words []OCRWord, opt OCRTextLayerOptions) error {
+	// `display` maps the coordinates of the words' bounding boxes to page coordinates.
+	var err error
+	var displayBox PdfRectangle
+	display := transform.IdentityMatrix()
+	imageCoords := opt.ImageWidth > 0 && opt.ImageHeight > 0
+	if imageCoords {
+		if displayBox, display, err = p.ocrDisplay(); err != nil {
+			return err
+		}
+	}
+	font := opt.Font
+	if font == nil {
+		font, err = NewStandard14Font(HelveticaName)
+		if err != nil {
+			return err
+		}
+	}
+	ascent, descent := ocrFontExtent(font)
+
+	if p.Resources == nil {
+		p.Resources = NewPdfPageResources()
+	}
+	// Find available font name for this page.
+	i := 0
+	fontName := core.PdfObjectName(fmt.Sprintf("OCR%d", i))
+	for p.Resources.HasFontByName(fontName) {
+		i++
+		fontName = core.PdfObjectName(fmt.Sprintf("OCR%d", i))
+	}
+
+	var buf bytes.Buffer
+	for _, word := range words {
+		bbox := word.BBox
+		if imageCoords {
+			bbox = imageToPageRect(bbox, displayBox, opt.ImageWidth, opt.ImageHeight)
+		}
+		if bbox.Llx > bbox.Urx {
+			bbox.Llx, bbox.Urx = bbox.Urx, bbox.Llx
+		}
+		if bbox.Lly > bbox.Ury {
+			bbox.Lly, bbox.Ury = bbox.Ury, bbox.Lly
+		}
+		width, height := bbox.Width(), bbox.Height()
+		if word.Text == "" || width <= 0 || height <= 0 {
+			continue
+		}
+
+		// The words must be extracted as they were given so encodings that substitute characters
+		// they can't encode are detected by decoding the encoded text.
+		data, numMisses := font.StringToCharcodeBytes(word.Text)
+		texts, _, _ := font.CharcodesToStrings(font.BytesToCharcodes(data))
+		if decoded := strings.Join(texts, ""); numMisses > 0 || decoded != word.Text {
+			common.Log.Debug("ERROR: font can't encode word. text=%q decoded=%q font=%s",
+				word.Text, decoded, font)
+			return fmt.Errorf("font %s can't encode %q", font.BaseFont(), word.Text)
+		}
+		textWidth := ocrTextWidth(font, word.Text, data)
+
+		// The glyphs extend from `descent` below the baseline to `ascent` above it.
+		fontSize := height * 1000 / (ascent - descent)
+		scaling := 100.0
+		if textWidth > 0 {
+			scaling = 100 * width * 1000 / (textWidth * fontSize)
+		}
+		baseline := bbox.Lly - descent*fontSize/1000
+		tm := display.Mult(transform.TranslationMatrix(bbox.Llx, baseline))
+		fmt.Fprintf(&buf, "/%s %.4f Tf\n%.4f Tz\n%s Tm\n<%X> Tj\n",
+			fontName, fontSize, scaling, ocrMatrix(tm), data)
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	if err := p.AddFont(fontName, font.ToPdfObject()); err != nil {
+		return err
+	}
+	// The existing content is wrapped in q/Q so that its graphics state changes don't affect the
+	// text layer.
+	if err := p.wrapContentStreams(); err != nil {
+		return err
+	}
+	contentStr := fmt.Sprintf("q\n"+
+		"BT\n"+
+		"3 Tr\n"+
+		"%s"+
+		"ET\n"+
+		"Q", buf.String())
+	return p.AddContentStreamByString(contentStr)
+}
+
+// ocrDisplay returns the area of the page as it is displayed, with its bottom left corner at the
+// origin, and the matrix that maps it to page coordinates. The displayed page is the page's crop
+// box, or media box if it has none, rotated clockwise by the page's Rotate entry.
+func (p *PdfPage) ocrDisplay() (PdfRectangle, transform.Matrix, error) {
+	box, err := p.GetMediaBox()
+	if err != nil {
+		return PdfRectangle{}, transform.Matrix{}, err
+	}
+	if p.CropBox != nil {
+		box = p.CropBox
+	} else if arr, ok := core.GetArray(p.getInheritedAttribute("CropBox")); ok {
+		if box, err = NewPdfRectangle(*arr); err != nil {
+			return PdfRectangle{}, transform.Matrix{}, err
+		}
+	}
+
+	var rotate int64
+	if p.Rotate != nil {
+		rotate = *p.Rotate
+	} else if val, ok := core.GetIntVal(p.getInheritedAttribute("Rotate")); ok {
+		rotate = int64(val)
+	}
+	rotate %= 360
+	if rotate < 0 {
+		rotate += 360
+	}
+
+	// The top left corner of the displayed page is the bottom left, top left, top right or
+	// bottom right corner of the box for rotations of 90, 0, 270 and 180 degrees respectively.
+	w, h := box.Width(), box.Height()
+	switch rotate {
+	case 90:
+		return PdfRectangle{Urx: h, Ury: w}, transform.NewMatrix(0, 1, -1, 0, box.Urx, box.Lly), nil
+	case 180:
+		return PdfRectangle{Urx: w, Ury: h}, transform.NewMatrix(-1, 0, 0, -1, box.Urx, box.Ury), nil
+	case 270:
+		return PdfRectangle{Urx: h, Ury: w}, transform.NewMatrix(0, -1, 1, 0, box.Llx, box.Ury), nil
+	}
+	if rotate != 0 {
+		common.Log.Debug("ERROR: invalid page Rotate %d. Ignoring it.", rotate)
+	}
+	return PdfRectangle{Urx: w, Ury: h}, transform.TranslationMatrix(box.Llx, box.Lly), nil
+}
+
+// getInheritedAttribute returns the value of the inheritable attribute `name` from the closest
+// ancestor of the page that defines it, or nil if none does.
+func (p *PdfPage) getInheritedAttribute(name core.PdfObjectName) core.PdfObject {
+	node := p.Parent
+	for node != nil {
+		dict, ok := core.GetDict(node)
+		if !ok {
+			return nil
+		}
+		if obj := dict.Get(name); obj != nil {
+			return obj
+		}
+		node = dict.Get("Parent")
+	}
+	return nil
+}
+
+// ocrMatrix returns the operands of the Tm operator that sets the text matrix to `m`.
+func ocrMatrix(m transform.Matrix) string {
+	return fmt.Sprintf("%.4f %.4f %.4f %.4f %.4f %.4f", m[0], m[1], m[3], m[4], m[6], m[7])
+}
+
+// wrapContentStreams wraps the page's content streams in a q/Q pair by adding content streams
+// before and after them.
+func (p *PdfPage) wrapContentStreams() error {
+	if p.Contents == nil {
+		return nil
+	}
+	q, err := core.MakeStream([]byte("q"), core.NewRawEncoder())
+	if err != nil {
+		return err
+	}
+	contents := core.MakeArray(q)
+	if contArray, isArray := core.GetArray(p.Contents); isArray {
+		contents.Append(contArray.Elements()...)
+	} else {
+		contents.Append(p.Contents)
+	}
+	p.Contents = contents
+	return p.AddContentStreamByString("Q")
+}
+
+// ocrTextWidth returns the width of `text`, encoded as `data`, in `font` in glyph space units.
+func ocrTextWidth(font *PdfFont, text string, data []byte) float64 {
+	// The widths are looked up by rune, as the creator does, as the character code metrics of
+	// composite fonts created from font files are not those of the glyphs their encoders select.
+	width := 0.0
+	for _, r := range text {
+		metrics, ok := font.GetRuneMetrics(r)
+		if !ok {
+			width = 0
+			break
+		}
+		width += metrics.Wx
+	}
+	if width > 0 {
+		return width
+	}
+	for _, code := range font.BytesToCharcodes(data) {
+		metrics, _ := font.GetCharMetrics(code)
+		width += metrics.Wx
+	}
+	return width
+}
+
+// ocrFontExtent returns the ascent and descent of `font` in glyph space units. Typical values are
+// returned if the font doesn't have them.
+func ocrFontExtent(font *PdfFont) (float64, float64) {
+	ascent, descent := 800.0, -200.0
+	descriptor := font.FontDescriptor()
+	if descriptor == nil {
+		return ascent, descent
+	}
+	a, errA := descriptor.GetAscent()
+	d, errD := descriptor.GetDescent()
+	if errA != nil || errD != nil || a <= d {
+		return ascent, descent
+	}
+	return a, d
+}
+
+// imageToPageRect returns the coordinates in `box` of `rect` in the pixel coordinates of a `width`
+// x `height` image of `box`. `box` is the area of the page as it is displayed: its crop box rotated
+// by its Rotate entry (see ocrDisplay). The y axis of the image points down.
+func imageToPageRect(rect, box PdfRectangle, width, height float64) PdfRectangle {
+	sx := box.Width() / width
+	sy := box.Height() / height
+	return PdfRectangle{
+		Llx: box.Llx + rect.Llx*sx,
+		Lly: box.Ury - rect.Ury*sy,
+		Urx: box.Llx + rect.Urx*sx,
+		Ury: box.Ury - rect.Lly*sy,
+	}
+}
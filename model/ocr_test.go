@@ -0,0 +1,176 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/extractor"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestAddOCRTextLayer tests that words added to a page with AddOCRTextLayer are extracted as
+// invisible text in their bounding boxes.
+func TestAddOCRTextLayer(t *testing.T) {
+	openSans, err := model.NewCompositePdfFontFromTTFFile("testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+
+	// The words are in the pixel coordinates of a 1224 x 1584 image, which is the size of a
+	// letter page scanned at 144 DPI.
+	words := []model.OCRWord{
+		{Text: "Scanned", BBox: model.PdfRectangle{Llx: 200, Lly: 200, Urx: 400, Ury: 240}},
+		{Text: "page", BBox: model.PdfRectangle{Llx: 420, Lly: 200, Urx: 520, Ury: 240}},
+		{Text: "Größe", BBox: model.PdfRectangle{Llx: 200, Lly: 300, Urx: 360, Ury: 340}},
+		{Text: "", BBox: model.PdfRectangle{Llx: 200, Lly: 400, Urx: 360, Ury: 440}},
+	}
+	// The page coordinates of the words' bounding boxes.
+	expected := map[string]model.PdfRectangle{
+		"Scanned": {Llx: 100, Lly: 672, Urx: 200, Ury: 692},
+		"page":    {Llx: 210, Lly: 672, Urx: 260, Ury: 692},
+		"Größe":   {Llx: 100, Lly: 622, Urx: 180, Ury: 642},
+	}
+
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	// The content that the text layer is added to leaves its transformation in effect.
+	require.NoError(t, page.SetContentStreams([]string{"2 0 0 2 0 0 cm"}, core.NewRawEncoder()))
+	opt := model.OCRTextLayerOptions{Font: openSans, ImageWidth: 1224, ImageHeight: 1584}
+	require.NoError(t, page.AddOCRTextLayer(words, opt))
+
+	writer := model.NewPdfWriter()
+	require.NoError(t, writer.AddPage(page))
+	var buf bytes.Buffer
+	require.NoError(t, writer.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err = reader.GetPage(1)
+	require.NoError(t, err)
+
+	e, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+	text := pageText.Text()
+	for word, want := range expected {
+		offset := strings.Index(text, word)
+		require.True(t, offset >= 0, "word %q not in %q", word, text)
+		spanMarks, err := pageText.Marks().RangeOffset(offset, offset+len(word))
+		require.NoError(t, err)
+		for _, tm := range spanMarks.Elements() {
+			assert.Equal(t, extractor.TextInvisibleRenderMode, tm.Visibility, "mark %q", tm.Text)
+		}
+		// The words span their bounding boxes horizontally. The extracted text extends from the
+		// baseline, which is above the bottom of the bounding box by the font's descent, to the
+		// font size above it.
+		bbox, ok := spanMarks.BBox()
+		require.True(t, ok)
+		assert.InDelta(t, want.Llx, bbox.Llx, 0.01, "word %q", word)
+		assert.InDelta(t, want.Urx, bbox.Urx, 0.01, "word %q", word)
+		assert.True(t, bbox.Lly > want.Lly && bbox.Lly < want.Ury && bbox.Height() <= want.Height(),
+			"word %q bbox=%+v want=%+v", word, bbox, want)
+	}
+
+	// The default font, Helvetica, can't encode CJK text.
+	page = model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+	cjk := []model.OCRWord{{Text: "日本", BBox: model.PdfRectangle{Llx: 100, Lly: 100, Urx: 140, Ury: 120}}}
+	assert.Error(t, page.AddOCRTextLayer(cjk, model.OCRTextLayerOptions{}))
+}
+
+// TestAddOCRTextLayerDisplay tests that the image that the words were recognized in is taken to
+// fill the page's crop box, rotated by the page's Rotate entry.
+func TestAddOCRTextLayerDisplay(t *testing.T) {
+	// The words are in the pixel coordinates of an image scanned at 144 DPI.
+	words := []model.OCRWord{
+		{Text: "Scanned", BBox: model.PdfRectangle{Llx: 200, Lly: 200, Urx: 400, Ury: 240}},
+	}
+	rotate := func(r int64) *int64 { return &r }
+	testcases := []struct {
+		name      string
+		cropBox   *model.PdfRectangle
+		rotate    *int64
+		imageSize [2]float64
+		// The page coordinates of the word's bounding box.
+		expected model.PdfRectangle
+	}{
+		{
+			name:      "crop box",
+			cropBox:   &model.PdfRectangle{Llx: 100, Lly: 100, Urx: 400, Ury: 500},
+			imageSize: [2]float64{600, 800},
+			expected:  model.PdfRectangle{Llx: 200, Lly: 380, Urx: 300, Ury: 400},
+		},
+		{
+			// The top left corner of the image is the bottom left corner of the page and the word
+			// runs up the page.
+			name:      "Rotate 90",
+			rotate:    rotate(90),
+			imageSize: [2]float64{1584, 1224},
+			expected:  model.PdfRectangle{Llx: 100, Lly: 100, Urx: 120, Ury: 200},
+		},
+		{
+			name:      "Rotate 180",
+			rotate:    rotate(180),
+			imageSize: [2]float64{1224, 1584},
+			expected:  model.PdfRectangle{Llx: 412, Lly: 100, Urx: 512, Ury: 120},
+		},
+		{
+			name:      "Rotate -90 and crop box",
+			cropBox:   &model.PdfRectangle{Llx: 100, Lly: 100, Urx: 400, Ury: 500},
+			rotate:    rotate(-90),
+			imageSize: [2]float64{800, 600},
+			expected:  model.PdfRectangle{Llx: 280, Lly: 300, Urx: 300, Ury: 400},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			page := model.NewPdfPage()
+			page.MediaBox = &model.PdfRectangle{Urx: 612, Ury: 792}
+			page.CropBox = tc.cropBox
+			page.Rotate = tc.rotate
+			opt := model.OCRTextLayerOptions{ImageWidth: tc.imageSize[0], ImageHeight: tc.imageSize[1]}
+			require.NoError(t, page.AddOCRTextLayer(words, opt))
+
+			// The crop box and rotation are ignored when extracting the text so that the marks
+			// are in page coordinates.
+			page.CropBox = nil
+			page.Rotate = nil
+			e, err := extractor.New(page)
+			require.NoError(t, err)
+			pageText, _, _, err := e.ExtractPageText()
+			require.NoError(t, err)
+			text := pageText.Text()
+			offset := strings.Index(text, "Scanned")
+			require.True(t, offset >= 0, "word not in %q", text)
+			spanMarks, err := pageText.Marks().RangeOffset(offset, offset+len("Scanned"))
+			require.NoError(t, err)
+			bbox, ok := spanMarks.BBox()
+			require.True(t, ok)
+
+			// The word spans its bounding box along its baseline. The baseline, which is one of
+			// the edges of the extracted text across the word, is within the bounding box.
+			want := tc.expected
+			inRange := func(v, lo, hi float64) bool { return v > lo && v < hi }
+			if want.Width() > want.Height() {
+				assert.InDelta(t, want.Llx, bbox.Llx, 0.01)
+				assert.InDelta(t, want.Urx, bbox.Urx, 0.01)
+				assert.True(t, inRange(bbox.Lly, want.Lly, want.Ury) != inRange(bbox.Ury, want.Lly, want.Ury),
+					"bbox=%+v want=%+v", bbox, want)
+			} else {
+				assert.InDelta(t, want.Lly, bbox.Lly, 0.01)
+				assert.InDelta(t, want.Ury, bbox.Ury, 0.01)
+				assert.True(t, inRange(bbox.Llx, want.Llx, want.Urx) != inRange(bbox.Urx, want.Llx, want.Urx),
+					"bbox=%+v want=%+v", bbox, want)
+			}
+		})
+	}
+}
@@ -110,6 +110,24 @@ func (i *Image) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfO
 			common.Log.Warning("Error decode the image stream %s")
 			continue
 		}
+		if jpxEnc, ok := streamEncoder.(*core.JPXEncoder); ok {
+			if jpxEnc.SMaskInData != 0 {
+				// Re-encoding would drop the opacity channel of the JPX data.
+				continue
+			}
+			// The DCT encoder only takes whole bytes per sample and the number of components
+			// of the color space.
+			if jpxEnc.BitsPerComponent != 8 && jpxEnc.BitsPerComponent != 16 {
+				common.Log.Debug("Skipping JPX image with %d bits per component", jpxEnc.BitsPerComponent)
+				continue
+			}
+			if jpxEnc.ColorComponents != img.ColorComponents {
+				common.Log.Debug("Skipping JPX image with %d components in color space %s",
+					jpxEnc.ColorComponents, img.ColorSpace)
+				continue
+			}
+			img.BitsPerComponent = jpxEnc.BitsPerComponent
+		}
 		dctenc := core.NewDCTEncoder()
 		dctenc.ColorComponents = img.ColorComponents
 		dctenc.Quality = i.ImageQuality
@@ -118,8 +136,8 @@ func (i *Image) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfO
 		dctenc.Height = img.Height
 		streamData, err := dctenc.EncodeBytes(data)
 		if err != nil {
-			common.Log.Debug("ERROR: %v", err)
-			return nil, err
+			common.Log.Debug("ERROR: could not encode image: %v. Skipping it.", err)
+			continue
 		}
 
 		var filter core.StreamEncoder
@@ -134,9 +152,8 @@ func (i *Image) Optimize(objects []core.PdfObject) (optimizedObjects []core.PdfO
 
 			encoded, err := multienc.EncodeBytes(data)
 			if err != nil {
-				return nil, err
-			}
-			if len(encoded) < len(streamData) {
+				common.Log.Debug("ERROR: could not encode image: %v. Using DCT only.", err)
+			} else if len(encoded) < len(streamData) {
 				common.Log.Debug("Multi enc improves: %d to %d (orig %d)",
 					len(streamData), len(encoded), len(stream.Stream))
 				streamData = encoded
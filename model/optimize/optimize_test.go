@@ -7,6 +7,7 @@ package optimize_test
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"testing"
@@ -210,3 +211,69 @@ endobj
 		t.Fatalf("len(optObjects) != 6 (%d)", len(optObjects))
 	}
 }
+
+// makeGrayImage returns a `width` x `height` DeviceGray image XObject stream with contents `data`.
+func makeGrayImage(t *testing.T, data []byte, width, height int64) *core.PdfObjectStream {
+	stream, err := core.MakeStream(data, nil)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	stream.Set("Type", core.MakeName("XObject"))
+	stream.Set("Subtype", core.MakeName("Image"))
+	stream.Set("Width", core.MakeInteger(width))
+	stream.Set("Height", core.MakeInteger(height))
+	stream.Set("ColorSpace", core.MakeName("DeviceGray"))
+	return stream
+}
+
+// TestOptimizeImageJPXBitDepth tests that JPX images with samples packed at less than 8 bits
+// per component are left as they are by the image optimizer, which goes on with the other
+// images.
+func TestOptimizeImageJPXBitDepth(t *testing.T) {
+	// A 32x32 4-bit grayscale JPEG 2000 codestream of a noisy pattern.
+	encoded, err := hex.DecodeString("ff4fff510029000000000020000000200000000000000000000000200000002000000000000000000001030101" +
+		"ff52000c00000001000302020001ff5c000d4028303038303038303038ff90000a0000000001d70001ff93c3a2" +
+		"800ecc615677c1d160e8b07c238023116fdf4f00d93bb7df249ff77900555fc3e15e1f0b50f90b8014b9326c2c" +
+		"2d11f2e8d374df7b358858f9c1f3dc227a6805ae11ee3e09559701a2a2c9bc422c03484ac04406313f12d3af62" +
+		"3761ba6d23cd7c4edcced58155f3b04b275ba7be4937c7c97a8f92f51f3d6800e7118fc63d357ec6f3a5de8625" +
+		"900788cfc49cc5ef7c14366190d61f4b5438b3399bccdf16b00e7179887845c847231c30b973525467db9fbcb2" +
+		"c53ca2a538341b60f3c115485fa8aa769a1b78ca01cf0b406a646059470715304abd137a1a7e5abc6ed5d28098" +
+		"aece222ebf4f574472b9a228db2740c4931f65a2b03354310cc0b50010fadf951cbd60895cb867e0a8237bbf80" +
+		"14eef262d47d9387f0d64b5775fb6c5a061aa895598252420edd17f95b52f74ebb77bb79fd8c318af8867f17f2" +
+		"e343f799d99a9832f42ef7cfcd89f3ac3615a9750bb71d1151f950442309c935016104da645f87ad44fb09492c" +
+		"177b588be815f028f9f8d408340473e38a1847d04272ab030dcab60062759f75b75f41f7de6040d97df93d3085" +
+		"8b031cece1abb793de6d6860984c2abfebccd75a583259e6b6d20f7054e871315ed6986ab36ff98af70e3b6028" +
+		"29517fff7fffd9")
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	jpxImage := makeGrayImage(t, encoded, 32, 32)
+	jpxImage.Set("Filter", core.MakeName(core.StreamEncodingFilterNameJPX))
+
+	// A 64x64 8-bit grayscale gradient, which is made smaller by DCT encoding.
+	data := make([]byte, 64*64)
+	for i := range data {
+		data[i] = byte(i%64*2 + i/64*2)
+	}
+	rawImage := makeGrayImage(t, data, 64, 64)
+	rawImage.Set("BitsPerComponent", core.MakeInteger(8))
+
+	objects := []core.PdfObject{jpxImage, rawImage}
+	// At the lowest quality, misreading the 4-bit samples as 8-bit ones would give a DCT image
+	// smaller than the JPX data, which would replace it.
+	opt := optimize.Image{ImageQuality: 1}
+	optObjects, err := opt.Optimize(objects)
+	if err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(optObjects) != 2 {
+		t.Fatalf("len(optObjects) != 2 (%d)", len(optObjects))
+	}
+	if optObjects[0] != jpxImage || !bytes.Equal(jpxImage.Stream, encoded) {
+		t.Fatalf("4-bit JPX image was re-encoded: %s", debugObjects(optObjects[:1]))
+	}
+	stream, ok := core.GetStream(optObjects[1])
+	if !ok || stream == rawImage || len(stream.Stream) >= len(data) {
+		t.Fatalf("8-bit image was not optimized: %s", debugObjects(optObjects[1:]))
+	}
+}
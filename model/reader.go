@@ -886,6 +886,22 @@ func (r *PdfReader) GetPageLabels() (core.PdfObject, error) {
 	return obj, nil
 }
 
+// GetStructTreeRoot returns the StructTreeRoot entry in the PDF catalog, the root of the logical
+// structure tree of a tagged PDF, or nil if the document is not tagged.
+// See section 14.7.2 "Structure Hierarchy" (p. 579 PDF32000_2008).
+// NOTE: The references in the structure tree are not resolved as the tree refers to the pages and
+// the other objects of the document.
+func (r *PdfReader) GetStructTreeRoot() (core.PdfObject, error) {
+	obj := core.ResolveReference(r.catalog.Get("StructTreeRoot"))
+	if obj == nil {
+		return nil, nil
+	}
+	if _, ok := core.GetDict(obj); !ok {
+		return nil, fmt.Errorf("invalid StructTreeRoot (%T)", obj)
+	}
+	return obj, nil
+}
+
 // Inspect inspects the object types, subtypes and content in the PDF file returning a map of
 // object type to number of instances of each.
 func (r *PdfReader) Inspect() (map[string]int, error) {
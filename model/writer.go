@@ -469,6 +469,23 @@ func (w *PdfWriter) SetPageLabels(pageLabels core.PdfObject) error {
 	return w.addObjects(pageLabels)
 }
 
+// SetStructTreeRoot sets the StructTreeRoot entry in the PDF catalog and marks the document as
+// tagged. The structure elements refer to the pages they are on so the pages should be added with
+// AddPage.
+// See section 14.7.2 "Structure Hierarchy" (p. 579 PDF32000_2008).
+func (w *PdfWriter) SetStructTreeRoot(structTreeRoot core.PdfObject) error {
+	if structTreeRoot == nil {
+		return nil
+	}
+
+	common.Log.Trace("Setting catalog StructTreeRoot...")
+	w.catalog.Set("StructTreeRoot", structTreeRoot)
+	markInfo := core.MakeDict()
+	markInfo.Set("Marked", core.MakeBool(true))
+	w.catalog.Set("MarkInfo", markInfo)
+	return w.addObjects(structTreeRoot)
+}
+
 // SetOptimizer sets the optimizer to optimize PDF before writing.
 func (w *PdfWriter) SetOptimizer(optimizer Optimizer) {
 	w.optimizer = optimizer
@@ -412,6 +412,16 @@ func NewXObjectImageFromStream(stream *core.PdfObjectStream) (*XObjectImage, err
 			return nil, err
 		}
 		img.ColorSpace = cs
+	} else if jpxEnc, ok := encoder.(*core.JPXEncoder); ok && jpxEnc.ColorComponents > 0 {
+		// JPX images can omit the colorspace, it is specified by the JPX data.
+		switch jpxEnc.ColorComponents {
+		case 3:
+			img.ColorSpace = NewPdfColorspaceDeviceRGB()
+		case 4:
+			img.ColorSpace = NewPdfColorspaceDeviceCMYK()
+		default:
+			img.ColorSpace = NewPdfColorspaceDeviceGray()
+		}
 	} else {
 		// If not specified, assume gray..
 		common.Log.Debug("XObject Image colorspace not specified - assuming 1 color component")
@@ -425,6 +435,10 @@ func NewXObjectImageFromStream(stream *core.PdfObjectStream) (*XObjectImage, err
 		}
 		iVal := int64(*iObj)
 		img.BitsPerComponent = &iVal
+	} else if jpxEnc, ok := encoder.(*core.JPXEncoder); ok && jpxEnc.BitsPerComponent > 0 {
+		// JPX images can omit the bits per component, it is specified by the JPX data.
+		iVal := int64(jpxEnc.BitsPerComponent)
+		img.BitsPerComponent = &iVal
 	}
 
 	img.Intent = dict.Get("Intent")
@@ -529,11 +543,27 @@ func (ximg *XObjectImage) ToImage() (*Image, error) {
 
 	image.ColorComponents = ximg.ColorSpace.GetNumComponents()
 
-	decoded, err := core.DecodeStream(ximg.primitive)
-	if err != nil {
-		return nil, err
+	if jpxEnc, ok := ximg.Filter.(*core.JPXEncoder); ok {
+		// The JPX data specifies the bits per component of the decoded samples and
+		// can contain the opacity channel.
+		data, alpha, err := jpxEnc.DecodeImage(ximg.primitive.Stream)
+		if err != nil {
+			return nil, err
+		}
+		image.Data = data
+		image.BitsPerComponent = int64(jpxEnc.BitsPerComponent)
+		if jpxEnc.SMaskInData != 0 && alpha != nil &&
+			(jpxEnc.BitsPerComponent == 8 || jpxEnc.BitsPerComponent == 16) {
+			image.alphaData = alpha
+			image.hasAlpha = true
+		}
+	} else {
+		decoded, err := core.DecodeStream(ximg.primitive)
+		if err != nil {
+			return nil, err
+		}
+		image.Data = decoded
 	}
-	image.Data = decoded
 
 	if ximg.Decode != nil {
 		darr, ok := ximg.Decode.(*core.PdfObjectArray)
@@ -0,0 +1,155 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// Annotation flags which prevent annotations from being displayed
+// (section 12.5.3 PDF32000_2008).
+const (
+	annotationFlagHidden = 1 << 1
+	annotationFlagNoView = 1 << 5
+)
+
+// renderAnnotations renders the normal appearance streams of the visible
+// annotations of the specified page, on top of the page content. If the
+// filter function is not nil, only the annotations for which it returns true
+// are rendered.
+func (r renderer) renderAnnotations(ctx context.Context, page *model.PdfPage,
+	filter func(*model.PdfAnnotation) bool) error {
+	annotations, err := page.GetAnnotations()
+	if err != nil {
+		return err
+	}
+
+	for _, annotation := range annotations {
+		if filter != nil && !filter(annotation) {
+			continue
+		}
+		if err := r.renderAnnotation(ctx, annotation, page.Resources); err != nil {
+			common.Log.Debug("ERROR: could not render annotation: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// renderAnnotation renders the normal appearance stream of the specified
+// annotation. Hidden annotations and annotations without a normal appearance
+// are skipped.
+func (r renderer) renderAnnotation(ctx context.Context, annotation *model.PdfAnnotation,
+	resources *model.PdfPageResources) error {
+	if flags, ok := core.GetIntVal(annotation.F); ok {
+		if flags&(annotationFlagHidden|annotationFlagNoView) != 0 {
+			return nil
+		}
+	}
+
+	stream := annotationAppearance(annotation)
+	if stream == nil {
+		return nil
+	}
+	xform, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		return err
+	}
+
+	// Get annotation rectangle.
+	rectArr, ok := core.GetArray(annotation.Rect)
+	if !ok {
+		return errType
+	}
+	rect, err := model.NewPdfRectangle(*rectArr)
+	if err != nil {
+		return err
+	}
+
+	// Get the bounding box and the matrix of the appearance stream.
+	bboxArr, ok := core.GetArray(xform.BBox)
+	if !ok {
+		return errType
+	}
+	bbox, err := model.NewPdfRectangle(*bboxArr)
+	if err != nil {
+		return err
+	}
+
+	mf := []float64{1, 0, 0, 1, 0, 0}
+	if xform.Matrix != nil {
+		array, ok := core.GetArray(xform.Matrix)
+		if !ok {
+			return errType
+		}
+		if mf, err = core.GetNumbersAsFloat(array.Elements()); err != nil {
+			return err
+		}
+		if len(mf) != 6 {
+			return errRange
+		}
+	}
+
+	// The bounding box of the appearance stream is transformed using the form
+	// matrix and the resulting box is mapped onto the annotation rectangle
+	// (section 12.5.5 PDF32000_2008).
+	llx, lly, urx, ury := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, pt := range [][2]float64{
+		{bbox.Llx, bbox.Lly}, {bbox.Urx, bbox.Lly},
+		{bbox.Urx, bbox.Ury}, {bbox.Llx, bbox.Ury},
+	} {
+		x := mf[0]*pt[0] + mf[2]*pt[1] + mf[4]
+		y := mf[1]*pt[0] + mf[3]*pt[1] + mf[5]
+		llx, lly = math.Min(llx, x), math.Min(lly, y)
+		urx, ury = math.Max(urx, x), math.Max(ury, y)
+	}
+	if urx-llx == 0 || ury-lly == 0 {
+		return nil
+	}
+
+	sx := rect.Width() / (urx - llx)
+	sy := rect.Height() / (ury - lly)
+	rx, ry := math.Min(rect.Llx, rect.Urx), math.Min(rect.Lly, rect.Ury)
+	m := transform.NewMatrix(sx, 0, 0, sy, rx-llx*sx, ry-lly*sy)
+
+	ctx.Push()
+	defer ctx.Pop()
+	ctx.SetMatrix(ctx.Matrix().Mult(m))
+	return r.renderForm(ctx, xform, resources)
+}
+
+// annotationAppearance returns the normal appearance stream of the specified
+// annotation. If the normal appearance is a subdictionary of streams, the
+// stream corresponding to the appearance state of the annotation is
+// returned. Returns nil if the annotation does not have a normal appearance.
+func annotationAppearance(annotation *model.PdfAnnotation) *core.PdfObjectStream {
+	apDict, ok := core.GetDict(annotation.AP)
+	if !ok {
+		return nil
+	}
+
+	normal := core.ResolveReference(apDict.Get("N"))
+	if stream, ok := core.GetStream(normal); ok {
+		return stream
+	}
+
+	states, ok := core.GetDict(normal)
+	if !ok {
+		return nil
+	}
+	state, ok := core.GetName(annotation.AS)
+	if !ok {
+		return nil
+	}
+	stream, _ := core.GetStream(states.Get(*state))
+	return stream
+}
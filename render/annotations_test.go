@@ -0,0 +1,89 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// newTestAppearance returns a 10 x 10 appearance stream filled with RGB color `rgb`.
+func newTestAppearance(t *testing.T, rgb string) *core.PdfObjectStream {
+	return newTestStream(t, `<< /Type /XObject /Subtype /Form /BBox [0 0 10 10] >>`,
+		rgb+" rg 0 0 10 10 re f")
+}
+
+// newTestAP returns an appearance dictionary whose normal appearance is `normal`.
+func newTestAP(normal core.PdfObject) *core.PdfObjectDictionary {
+	ap := core.MakeDict()
+	ap.Set("N", normal)
+	return ap
+}
+
+// TestRenderAnnotations tests that the normal appearances of the visible annotations are drawn
+// on their rectangles and that hidden annotations are skipped.
+func TestRenderAnnotations(t *testing.T) {
+	page := newTestPage(t, 100, 20, "")
+
+	// A red square whose appearance is scaled to its rectangle.
+	square := model.NewPdfAnnotationSquare()
+	square.Rect = core.MakeArrayFromFloats([]float64{0, 0, 20, 20})
+	square.AP = newTestAP(newTestAppearance(t, "1 0 0"))
+	page.AddAnnotation(square.PdfAnnotation)
+
+	// A hidden blue square.
+	hidden := model.NewPdfAnnotationSquare()
+	hidden.Rect = core.MakeArrayFromFloats([]float64{40, 0, 60, 20})
+	hidden.F = core.MakeInteger(annotationFlagHidden)
+	hidden.AP = newTestAP(newTestAppearance(t, "0 0 1"))
+	page.AddAnnotation(hidden.PdfAnnotation)
+
+	// A widget drawn with the appearance of its On state.
+	widget := model.NewPdfAnnotationWidget()
+	widget.Rect = core.MakeArrayFromFloats([]float64{70, 0, 90, 20})
+	widget.AS = core.MakeName("On")
+	states := core.MakeDict()
+	states.Set("On", newTestAppearance(t, "0 1 0"))
+	states.Set("Off", newTestAppearance(t, "0 0 1"))
+	widget.AP = newTestAP(states)
+	page.AddAnnotation(widget.PdfAnnotation)
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	green := color.RGBA{G: 255, A: 255}
+
+	// The annotations are only drawn if RenderAnnotations is set.
+	device := NewImageDevice()
+	img, err := device.Render(page)
+	require.NoError(t, err)
+	for _, x := range []int{10, 50, 80} {
+		requireColor(t, img, x, 10, white, 0)
+	}
+
+	device.RenderAnnotations = true
+	img, err = device.Render(page)
+	require.NoError(t, err)
+	requireColor(t, img, 2, 2, red, 0)
+	requireColor(t, img, 18, 18, red, 0)
+	requireColor(t, img, 30, 10, white, 0)
+	requireColor(t, img, 50, 10, white, 0)
+	requireColor(t, img, 80, 10, green, 0)
+
+	// Filtered out annotations are skipped.
+	device.AnnotationFilter = func(annotation *model.PdfAnnotation) bool {
+		_, ok := annotation.GetContext().(*model.PdfAnnotationWidget)
+		return ok
+	}
+	img, err = device.Render(page)
+	require.NoError(t, err)
+	requireColor(t, img, 10, 10, white, 0)
+	requireColor(t, img, 80, 10, green, 0)
+}
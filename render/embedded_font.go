@@ -0,0 +1,183 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"errors"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/outline"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// embeddedFont provides the glyph outlines of the Type 1 and CFF font
+// programs embedded in PDF files (section 9.9 PDF32000_2008).
+type embeddedFont struct {
+	type1 *outline.Type1Font
+	cff   *outline.CFFFont
+
+	// The character codes of composite fonts are CIDs.
+	composite bool
+
+	// Character code to glyph name mapping of simple fonts. The built-in
+	// encoding of the font program is used for codes which are not mapped.
+	differences map[textencoding.CharCode]textencoding.GlyphName
+	encoder     textencoding.SimpleEncoder
+
+	glyphs map[textencoding.CharCode]*outline.Glyph
+}
+
+// newEmbeddedFont returns a new embedded font based on the font program
+// referenced by the descriptor of the specified font.
+func newEmbeddedFont(font *model.PdfFont, fontDict *core.PdfObjectDictionary) (*embeddedFont, error) {
+	descriptor := font.FontDescriptor()
+	if descriptor == nil {
+		return nil, errors.New("could not get font descriptor")
+	}
+
+	ef := &embeddedFont{
+		composite: font.IsCID(),
+		glyphs:    map[textencoding.CharCode]*outline.Glyph{},
+	}
+
+	if stream, ok := core.GetStream(descriptor.FontFile); ok {
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		if ef.type1, err = outline.ParseType1(data); err != nil {
+			return nil, err
+		}
+	} else if stream, ok := core.GetStream(descriptor.FontFile3); ok {
+		data, err := core.DecodeStream(stream)
+		if err != nil {
+			return nil, err
+		}
+
+		subtype, _ := core.GetNameVal(stream.Get("Subtype"))
+		switch subtype {
+		case "Type1C", "CIDFontType0C":
+			ef.cff, err = outline.ParseCFF(data)
+		case "OpenType":
+			ef.cff, err = outline.ParseOpenType(data)
+		default:
+			err = errors.New("unsupported FontFile3 subtype")
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("missing font file stream")
+	}
+
+	if !ef.composite {
+		if err := ef.loadEncoding(fontDict); err != nil {
+			return nil, err
+		}
+	}
+	return ef, nil
+}
+
+// loadEncoding loads the encoding of simple fonts from the specified font
+// dictionary.
+func (f *embeddedFont) loadEncoding(fontDict *core.PdfObjectDictionary) error {
+	switch encoding := core.ResolveReference(fontDict.Get("Encoding")).(type) {
+	case *core.PdfObjectName:
+		enc, err := textencoding.NewSimpleTextEncoder(string(*encoding), nil)
+		if err != nil {
+			return err
+		}
+		f.encoder = enc
+	case *core.PdfObjectDictionary:
+		if diffs, ok := core.GetArray(encoding.Get("Differences")); ok {
+			differences, err := textencoding.FromFontDifferences(diffs)
+			if err != nil {
+				return err
+			}
+			f.differences = differences
+		}
+		if baseName, ok := core.GetNameVal(encoding.Get("BaseEncoding")); ok {
+			enc, err := textencoding.NewSimpleTextEncoder(baseName, nil)
+			if err != nil {
+				return err
+			}
+			f.encoder = enc
+		}
+	}
+	return nil
+}
+
+// GlyphOutline returns the outline of the glyph of the specified character
+// code.
+func (f *embeddedFont) GlyphOutline(code textencoding.CharCode) (*outline.Glyph, bool) {
+	if glyph, ok := f.glyphs[code]; ok {
+		return glyph, glyph != nil
+	}
+
+	glyph, err := f.loadGlyph(code)
+	if err != nil {
+		glyph = nil
+	}
+	f.glyphs[code] = glyph
+	return glyph, glyph != nil
+}
+
+// loadGlyph loads the glyph of the specified character code from the font
+// program.
+func (f *embeddedFont) loadGlyph(code textencoding.CharCode) (*outline.Glyph, error) {
+	if f.composite {
+		if f.cff == nil {
+			return nil, errors.New("composite Type 1 fonts are not supported")
+		}
+		return f.cff.GlyphByCID(int(code))
+	}
+
+	for _, name := range f.glyphNames(code) {
+		switch {
+		case f.type1 != nil && f.type1.HasGlyph(name):
+			return f.type1.GlyphByName(name)
+		case f.cff != nil && f.cff.HasGlyph(name):
+			return f.cff.GlyphByName(name)
+		}
+	}
+	if f.cff == nil {
+		return nil, errors.New("glyph not found")
+	}
+
+	// Glyphs of fonts which have no glyph names are selected by code.
+	return f.cff.GlyphByCID(int(code))
+}
+
+// glyphNames returns the candidate names of the glyph corresponding to the
+// specified character code of a simple font, in order of precedence.
+func (f *embeddedFont) glyphNames(code textencoding.CharCode) []string {
+	var names []string
+	if glyph, ok := f.differences[code]; ok {
+		names = append(names, string(glyph))
+	}
+	if f.encoder != nil {
+		if r, ok := f.encoder.CharcodeToRune(code); ok {
+			if glyph, ok := textencoding.RuneToGlyph(r); ok {
+				names = append(names, string(glyph))
+			}
+		}
+	}
+	if code > 255 {
+		return names
+	}
+
+	var builtin map[byte]string
+	if f.type1 != nil {
+		builtin = f.type1.Encoding
+	} else {
+		builtin = f.cff.Encoding
+	}
+	if name, ok := builtin[byte(code)]; ok {
+		names = append(names, name)
+	}
+	return names
+}
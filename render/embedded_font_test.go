@@ -0,0 +1,91 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// embeddedFontTestPage returns a page showing "AA" with a Type 1 font whose descriptor has
+// `fontFile` set to a stream with font program `data`. The glyphs of the test font programs for
+// code 65 are squares from 100 to 400 glyph space units.
+func embeddedFontTestPage(t *testing.T, fontFile string, data []byte) *model.PdfPage {
+	page := newTestPage(t, 100, 60, "1 0 0 rg BT /F1 100 Tf 0 0 Td (AA) Tj ET")
+
+	stream := newTestStream(t, "<< >>", string(data))
+	if fontFile == "FontFile3" {
+		stream.Set("Subtype", core.MakeName("Type1C"))
+	}
+	descriptor := parseTestDict(t, `<<
+		/Type /FontDescriptor /FontName /Test /Flags 32 /FontBBox [0 0 500 500]
+		/ItalicAngle 0 /Ascent 500 /Descent 0 /CapHeight 500 /StemV 80
+	>>`)
+	descriptor.Set(core.PdfObjectName(fontFile), stream)
+	font := parseTestDict(t, `<<
+		/Type /Font /Subtype /Type1 /BaseFont /Test /FirstChar 65 /LastChar 65 /Widths [500]
+	>>`)
+	font.Set("FontDescriptor", descriptor)
+	require.NoError(t, page.Resources.SetFontByName("F1", font))
+	return page
+}
+
+// squareFilled returns true if the pixels of `img` inside the square from (`x0`, `y0`) to
+// (`x1`, `y1`) in page space, excluding its edges, are red.
+func squareFilled(img image.Image, x0, y0, x1, y1 int) bool {
+	height := img.Bounds().Dy()
+	for y := y0 + 1; y < y1-1; y++ {
+		for x := x0 + 1; x < x1-1; x++ {
+			if rgbaAt(img, x, height-1-y) != (color.RGBA{R: 255, A: 255}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TestEmbeddedFonts tests that text shown with embedded Type 1 and CFF font programs is drawn
+// with the outlines of their glyphs.
+func TestEmbeddedFonts(t *testing.T) {
+	type1, err := ioutil.ReadFile("testdata/square.pfa")
+	require.NoError(t, err)
+	cff, err := ioutil.ReadFile("testdata/square.cff")
+	require.NoError(t, err)
+
+	testcases := []struct {
+		fontFile string
+		data     []byte
+	}{
+		{"FontFile", type1},
+		{"FontFile3", cff},
+	}
+	for _, tc := range testcases {
+		page := embeddedFontTestPage(t, tc.fontFile, tc.data)
+		img, err := NewImageDevice().Render(page)
+		require.NoError(t, err)
+
+		// The glyphs are advanced by the widths of the font dictionary.
+		require.True(t, squareFilled(img, 10, 10, 40, 40), tc.fontFile)
+		require.True(t, squareFilled(img, 60, 10, 90, 40), tc.fontFile)
+		requireColor(t, img, 50, 35, color.RGBA{R: 255, G: 255, B: 255, A: 255}, 0)
+	}
+}
+
+// TestEmbeddedFontFallback tests that text shown with fonts whose embedded font programs can't
+// be loaded is drawn with substitute fonts, without failing.
+func TestEmbeddedFontFallback(t *testing.T) {
+	page := embeddedFontTestPage(t, "FontFile", []byte("not a font program"))
+	img, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+	require.False(t, squareFilled(img, 10, 10, 40, 40))
+}
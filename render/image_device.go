@@ -9,20 +9,82 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
 	"image/jpeg"
 	"image/png"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/gnaoh1379/unipdf/model"
 	"github.com/gnaoh1379/unipdf/render/internal/context/imagerender"
+
+	"github.com/gnaoh1379/unipdf/internal/transform"
 )
 
 // ImageDevice is used to render PDF pages to image targets.
 type ImageDevice struct {
 	renderer
+
+	// RenderAnnotations specifies whether the normal appearance streams of
+	// the visible page annotations are rendered on top of the page content.
+	// Annotations having the Hidden or NoView flags set are not rendered.
+	RenderAnnotations bool
+
+	// AnnotationFilter is used to select the annotations which are rendered
+	// when RenderAnnotations is true. The annotations for which the function
+	// returns false are skipped. The subtype of an annotation can be
+	// determined by checking the type of its context (e.g. GetContext()
+	// returning a *model.PdfAnnotationWidget). If nil, all visible
+	// annotations are rendered.
+	AnnotationFilter func(annotation *model.PdfAnnotation) bool
+
+	// DPI specifies the resolution of the output images. A page having a
+	// width of 1 inch (72 points) is rendered to an image having a width
+	// of DPI pixels. If zero, a resolution of 72 DPI is used, which maps
+	// each point of the page to one pixel.
+	DPI float64
+
+	// OutputWidth and OutputHeight specify the size of the output images,
+	// in pixels, and take precedence over the DPI setting. If only one of
+	// them is set, the other one is computed so that the aspect ratio of
+	// the page is preserved. If both are set, the page is scaled to the
+	// specified size, regardless of its aspect ratio.
+	OutputWidth  int
+	OutputHeight int
+
+	// PageBox specifies the page boundary which defines the rendered area
+	// of the pages. Defaults to the crop box.
+	PageBox PageBox
+
+	// ApplyRotation specifies whether the pages are rotated according to
+	// their Rotate entry, as they would be displayed by a viewer.
+	ApplyRotation bool
+
+	// Background specifies the color the output images are filled with
+	// before rendering the page content. A transparent color produces
+	// images having a transparent background. If nil, a white background
+	// is used.
+	Background color.Color
+
+	// DisableAntialiasing specifies whether anti-aliasing is turned off
+	// when rendering paths and text.
+	DisableAntialiasing bool
+
+	// SimulateOverprint specifies whether pages are rendered by combining
+	// their separations, as returned by RenderSeparations, instead of being
+	// rendered in RGB. This simulates the appearance of overprinted objects
+	// and of spot colors when printed. The Background setting is ignored.
+	SimulateOverprint bool
+
+	// Region specifies the region of the rendered page which is output,
+	// in pixels, relative to the top left corner of the page image which
+	// would be obtained using the current settings. It can be used to
+	// render a page in tiles, at high zoom levels. If empty, the entire
+	// page is output.
+	Region image.Rectangle
 }
 
 // NewImageDevice returns a new image device.
@@ -32,34 +94,131 @@ func NewImageDevice() *ImageDevice {
 
 // Render converts the specified PDF page into an image and returns the result.
 func (d *ImageDevice) Render(page *model.PdfPage) (image.Image, error) {
-	// Get page dimensions.
-	mbox, err := page.GetMediaBox()
+	if d.SimulateOverprint {
+		seps, err := d.RenderSeparations(page)
+		if err != nil {
+			return nil, err
+		}
+		return compositeSeparations(seps), nil
+	}
+	return d.render(page, nil)
+}
+
+// RenderSeparations renders the specified PDF page once for each ink and
+// returns the resulting separations. The separations of the process inks
+// are always returned, followed by the separations of the spot colors used
+// by the page, in the order in which they are encountered. The colors of
+// the page are converted to the tints of the inks they contain, while the
+// colors of Separation and DeviceN colorspaces are kept on the plates of
+// their colorants. The overprint parameters of the page are honored. Other
+// transparency effects are applied to each separation independently.
+func (d *ImageDevice) RenderSeparations(page *model.PdfPage) ([]*Separation, error) {
+	spots := &spotInks{colors: map[string]color.RGBA{}}
+
+	var seps []*Separation
+	renderInk := func(ink string) error {
+		img, err := d.render(page, &inkPlate{ink: ink, spots: spots})
+		if err != nil {
+			return err
+		}
+		gray := image.NewGray(img.Bounds())
+		draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+
+		c, ok := processColors[ink]
+		if !ok {
+			c = spots.colors[ink]
+		}
+		seps = append(seps, &Separation{Ink: ink, Image: gray, Color: c})
+		return nil
+	}
+
+	// The spot inks are collected while rendering the process inks.
+	for _, ink := range processInks {
+		if err := renderInk(ink); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < len(spots.names); i++ {
+		if err := renderInk(spots.names[i]); err != nil {
+			return nil, err
+		}
+	}
+	return seps, nil
+}
+
+// render renders the specified PDF page. If a plate is specified, the page
+// is rendered as the separation of the ink of the plate.
+func (d *ImageDevice) render(page *model.PdfPage, plate *inkPlate) (image.Image, error) {
+	// Get the rendered area of the page.
+	box, err := pageBox(page, d.PageBox)
 	if err != nil {
 		return nil, err
 	}
+	rotation := 0
+	if d.ApplyRotation {
+		rotation = pageRotation(page)
+	}
+	width, height := box.Width(), box.Height()
+	if rotation == 90 || rotation == 270 {
+		width, height = height, width
+	}
+
+	// Compute the scale and the size of the page image.
+	sx, sy := d.scale(width, height)
+	bounds := image.Rect(0, 0, int(math.Round(width*sx)), int(math.Round(height*sy)))
+	if bounds.Empty() {
+		return nil, errors.New("invalid page dimensions")
+	}
+	region := bounds
+	if !d.Region.Empty() {
+		if region = d.Region.Intersect(bounds); region.Empty() {
+			return nil, errInvalidRegion
+		}
+	}
 
 	// Render page.
-	width, height := mbox.Llx+mbox.Width(), mbox.Lly+mbox.Height()
+	m := transform.TranslationMatrix(-float64(region.Min.X), -float64(region.Min.Y))
+	m = m.Mult(pageMatrix(box, rotation, sx, sy))
 
-	ctx := imagerender.NewContext(int(width), int(height))
-	if err := d.renderPage(ctx, page); err != nil {
-		return nil, err
+	background := d.Background
+	if background == nil || plate != nil {
+		background = color.White
 	}
 
-	// Apply crop box, if one exists.
-	img := ctx.Image()
-	if box := page.CropBox; box != nil {
-		// Calculate crop bounds and crop start position.
-		cropBounds := image.Rect(0, 0, int(box.Width()), int(box.Height()))
-		cropStart := image.Pt(int(box.Llx), int(height-box.Ury))
+	r := d.renderer
+	r.plate = plate
 
-		// Crop image.
-		cropImg := image.NewRGBA(cropBounds)
-		draw.Draw(cropImg, cropBounds, img, cropStart, draw.Src)
-		img = cropImg
+	ctx := imagerender.NewContext(region.Dx(), region.Dy())
+	ctx.SetAntialias(!d.DisableAntialiasing)
+	if err := r.renderPage(ctx, page, m, background); err != nil {
+		return nil, err
 	}
+	if d.RenderAnnotations {
+		if err := r.renderAnnotations(ctx, page, d.AnnotationFilter); err != nil {
+			return nil, err
+		}
+	}
+
+	return ctx.Image(), nil
+}
 
-	return img, nil
+// scale returns the horizontal and vertical factors used to scale a page
+// having the specified size, in points.
+func (d *ImageDevice) scale(width, height float64) (float64, float64) {
+	switch {
+	case d.OutputWidth > 0 && d.OutputHeight > 0:
+		return float64(d.OutputWidth) / width, float64(d.OutputHeight) / height
+	case d.OutputWidth > 0:
+		s := float64(d.OutputWidth) / width
+		return s, s
+	case d.OutputHeight > 0:
+		s := float64(d.OutputHeight) / height
+		return s, s
+	case d.DPI > 0:
+		s := d.DPI / 72
+		return s, s
+	}
+	return 1, 1
 }
 
 // RenderToPath converts the specified PDF page into an image and saves the
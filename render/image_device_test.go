@@ -0,0 +1,113 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// newRotatedTestPage returns a 100 x 50 page whose bottom left 50 x 25 quarter is red, with Rotate
+// entry `rotate`.
+func newRotatedTestPage(t *testing.T, rotate int64) *model.PdfPage {
+	page := newTestPage(t, 100, 50, "1 0 0 rg 0 0 50 25 re f")
+	page.Rotate = &rotate
+	return page
+}
+
+// TestImageDeviceSize tests the size of the images output for the DPI, OutputWidth,
+// OutputHeight, ApplyRotation and Region settings.
+func TestImageDeviceSize(t *testing.T) {
+	testcases := []struct {
+		name   string
+		rotate int64
+		device ImageDevice
+		width  int
+		height int
+	}{
+		{name: "default", width: 100, height: 50},
+		{name: "DPI", device: ImageDevice{DPI: 144}, width: 200, height: 100},
+		{name: "OutputWidth", device: ImageDevice{OutputWidth: 50}, width: 50, height: 25},
+		{name: "OutputHeight", device: ImageDevice{OutputHeight: 100}, width: 200, height: 100},
+		{name: "OutputWidth and OutputHeight", device: ImageDevice{OutputWidth: 30, OutputHeight: 40},
+			width: 30, height: 40},
+		{name: "OutputWidth over DPI", device: ImageDevice{DPI: 144, OutputWidth: 50}, width: 50,
+			height: 25},
+		{name: "Rotate ignored", rotate: 90, width: 100, height: 50},
+		{name: "Rotate 90", rotate: 90, device: ImageDevice{ApplyRotation: true}, width: 50,
+			height: 100},
+		{name: "Rotate 180", rotate: 180, device: ImageDevice{ApplyRotation: true}, width: 100,
+			height: 50},
+		{name: "Rotate -90", rotate: -90, device: ImageDevice{ApplyRotation: true}, width: 50,
+			height: 100},
+		{name: "Region", device: ImageDevice{Region: image.Rect(10, 10, 60, 40)}, width: 50,
+			height: 30},
+		{name: "Region clipped", device: ImageDevice{Region: image.Rect(80, 40, 200, 200)},
+			width: 20, height: 10},
+		{name: "Region with DPI", device: ImageDevice{DPI: 144, Region: image.Rect(150, 0, 250, 50)},
+			width: 50, height: 50},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := tc.device.Render(newRotatedTestPage(t, tc.rotate))
+			require.NoError(t, err)
+			require.Equal(t, image.Rect(0, 0, tc.width, tc.height), img.Bounds())
+		})
+	}
+
+	// Regions outside of the page are rejected.
+	device := ImageDevice{Region: image.Rect(100, 50, 120, 60)}
+	_, err := device.Render(newRotatedTestPage(t, 0))
+	require.Equal(t, errInvalidRegion, err)
+}
+
+// TestImageDevicePlacement tests that the page content is positioned in the output images
+// according to the DPI, ApplyRotation and Region settings.
+func TestImageDevicePlacement(t *testing.T) {
+	red, white := color.RGBA{R: 255, A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	// The red quarter is at the bottom left of the page image.
+	img, err := NewImageDevice().Render(newRotatedTestPage(t, 0))
+	require.NoError(t, err)
+	requireColor(t, img, 10, 40, red, 0)
+	requireColor(t, img, 10, 10, white, 0)
+	requireColor(t, img, 60, 40, white, 0)
+
+	// At 144 DPI, each point is 2 pixels.
+	img, err = (&ImageDevice{DPI: 144}).Render(newRotatedTestPage(t, 0))
+	require.NoError(t, err)
+	requireColor(t, img, 95, 55, red, 0)
+	requireColor(t, img, 105, 55, white, 0)
+	requireColor(t, img, 95, 45, white, 0)
+
+	// Rotating the page clockwise by 90 degrees moves the bottom left corner to the top left.
+	img, err = (&ImageDevice{ApplyRotation: true}).Render(newRotatedTestPage(t, 90))
+	require.NoError(t, err)
+	requireColor(t, img, 10, 10, red, 0)
+	requireColor(t, img, 10, 45, red, 0)
+	requireColor(t, img, 40, 10, white, 0)
+	requireColor(t, img, 10, 60, white, 0)
+
+	// Rotating by 270 degrees moves it to the bottom right.
+	img, err = (&ImageDevice{ApplyRotation: true}).Render(newRotatedTestPage(t, 270))
+	require.NoError(t, err)
+	requireColor(t, img, 40, 90, red, 0)
+	requireColor(t, img, 10, 90, white, 0)
+	requireColor(t, img, 40, 40, white, 0)
+
+	// The region is relative to the top left corner of the page image.
+	img, err = (&ImageDevice{Region: image.Rect(40, 20, 60, 40)}).Render(newRotatedTestPage(t, 0))
+	require.NoError(t, err)
+	requireColor(t, img, 0, 0, white, 0)
+	requireColor(t, img, 5, 10, red, 0)
+	requireColor(t, img, 15, 10, white, 0)
+}
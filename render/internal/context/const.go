@@ -5,7 +5,11 @@
 
 package context
 
-import "image/color"
+import (
+	"image/color"
+
+	"github.com/gnaoh1379/unipdf/internal/transform"
+)
 
 // FillRule represents the fill style used by a context instance.
 type FillRule int
@@ -45,3 +49,98 @@ type Gradient interface {
 	Pattern
 	AddColorStop(offset float64, color color.Color)
 }
+
+// GradientStop represents a color stop of a gradient.
+type GradientStop struct {
+	Offset float64
+	Color  color.Color
+}
+
+// GradientDefinition describes an axial or radial gradient. The gradient is
+// defined in its own coordinate space, which is mapped to the device space
+// by Matrix. Axial gradients vary between the points (X0, Y0) and (X1, Y1).
+// Radial gradients vary between the circle centered at (X0, Y0) of radius
+// R0 and the circle centered at (X1, Y1) of radius R1. The colors of the
+// first and last stops are extended beyond the ends of the gradient.
+type GradientDefinition struct {
+	Radial bool
+	X0, Y0 float64
+	R0     float64
+	X1, Y1 float64
+	R1     float64
+	Stops  []GradientStop
+	Matrix transform.Matrix
+}
+
+// GradientPattern is implemented by patterns which can be painted as axial
+// or radial gradients. Vector contexts use the gradient definition instead
+// of rasterizing the pattern.
+type GradientPattern interface {
+	Pattern
+
+	// GradientDefinition returns the definition of the gradient painted by
+	// the pattern. Returns false if the pattern cannot be represented as a
+	// gradient.
+	GradientDefinition() (*GradientDefinition, bool)
+}
+
+// BlendMode represents the blend mode used to composite painted objects
+// with their backdrop.
+type BlendMode int
+
+// Blend modes.
+const (
+	BlendModeNormal BlendMode = iota
+	BlendModeMultiply
+	BlendModeScreen
+	BlendModeOverlay
+	BlendModeDarken
+	BlendModeLighten
+	BlendModeColorDodge
+	BlendModeColorBurn
+	BlendModeHardLight
+	BlendModeSoftLight
+	BlendModeDifference
+	BlendModeExclusion
+	BlendModeHue
+	BlendModeSaturation
+	BlendModeColor
+	BlendModeLuminosity
+)
+
+// TextRenderingMode determines whether showing text causes glyph outlines
+// to be stroked, filled, used as a clipping boundary, or some combination
+// of the three.
+type TextRenderingMode int
+
+// Text rendering modes (section 9.3.6 PDF32000_2008).
+const (
+	TextRenderingModeFill TextRenderingMode = iota
+	TextRenderingModeStroke
+	TextRenderingModeFillStroke
+	TextRenderingModeInvisible
+	TextRenderingModeFillClip
+	TextRenderingModeStrokeClip
+	TextRenderingModeFillStrokeClip
+	TextRenderingModeClip
+)
+
+// IsFill returns true if the glyphs shown using the text rendering mode
+// are filled.
+func (m TextRenderingMode) IsFill() bool {
+	return m == TextRenderingModeFill || m == TextRenderingModeFillStroke ||
+		m == TextRenderingModeFillClip || m == TextRenderingModeFillStrokeClip
+}
+
+// IsStroke returns true if the glyphs shown using the text rendering mode
+// are stroked.
+func (m TextRenderingMode) IsStroke() bool {
+	return m == TextRenderingModeStroke || m == TextRenderingModeFillStroke ||
+		m == TextRenderingModeStrokeClip || m == TextRenderingModeFillStrokeClip
+}
+
+// IsClip returns true if the glyphs shown using the text rendering mode
+// are added to the clipping path.
+func (m TextRenderingMode) IsClip() bool {
+	return m >= TextRenderingModeFillClip && m <= TextRenderingModeClip
+}
@@ -181,6 +181,45 @@ type Context interface {
 	// image. Use ax=0.5, ay=0.5 to center the image at the specified point.
 	DrawImageAnchored(image image.Image, x, y int, ax, ay float64)
 
+	//
+	// Transparency operations
+	//
+
+	// SetFillAlpha sets the constant alpha used for fill operations, including
+	// text and images. The value should be in range 0-1.
+	SetFillAlpha(alpha float64)
+
+	// SetStrokeAlpha sets the constant alpha used for stroke operations.
+	// The value should be in range 0-1.
+	SetStrokeAlpha(alpha float64)
+
+	// SetBlendMode sets the blend mode used to composite painted objects
+	// with the backdrop.
+	SetBlendMode(mode BlendMode)
+
+	// SetSoftMask sets the soft mask applied to painting operations.
+	// The mask must have the size of the rendering area. A nil mask
+	// disables soft masking.
+	SetSoftMask(mask *image.Alpha)
+
+	// BeginGroup starts a transparency group. Subsequent operations are
+	// painted onto a separate layer, until the group is ended. The fill alpha,
+	// blend mode and soft mask are reset for the operations inside the group.
+	// The layer of non-isolated groups is initialized with the backdrop.
+	// Objects painted inside knockout groups are composited with the initial
+	// backdrop of the group, instead of the objects painted before them.
+	BeginGroup(isolated, knockout bool)
+
+	// EndGroup ends the current transparency group and composites its layer
+	// with the backdrop, using the fill alpha, blend mode and soft mask which
+	// were active when the group was started.
+	EndGroup()
+
+	// EndGroupAsMask ends the current transparency group and returns a soft
+	// mask computed from its layer, using either the luminosity or the alpha
+	// of the painted pixels. The layer is not composited with the backdrop.
+	EndGroupAsMask(luminosity bool) *image.Alpha
+
 	//
 	// Misc operations
 	//
@@ -191,3 +230,13 @@ type Context interface {
 	// Height returns the height of the rendering area.
 	Width() int
 }
+
+// VectorContext is implemented by contexts which render to vector targets.
+// Vector contexts do not rasterize the glyphs drawn using DrawString, which
+// can therefore be drawn at any size.
+type VectorContext interface {
+	Context
+
+	// IsVector returns true if the context renders to a vector target.
+	IsVector() bool
+}
@@ -0,0 +1,218 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package imagerender
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// compositor composites painted pixels with the backdrop, taking into
+// account the constant alpha, the blend mode, the soft mask and the
+// knockout backdrop of the current graphics state.
+type compositor struct {
+	alpha    float64
+	blend    context.BlendMode
+	softMask *image.Alpha
+	knockout *image.RGBA
+}
+
+// compose composites the specified color onto the pixel of `dst` located at
+// x, y. The coverage of the pixel is specified in range 0-0xffff.
+func (c *compositor) compose(dst *image.RGBA, x, y int, col color.Color, coverage uint32) {
+	const m = 1<<16 - 1
+	cr, cg, cb, ca := col.RGBA()
+	if ca == 0 || coverage == 0 {
+		return
+	}
+
+	// Compute the opacity of the source, excluding its shape.
+	opacity := c.alpha
+	if c.softMask != nil {
+		opacity *= float64(c.softMask.AlphaAt(x, y).A) / 255
+		if opacity == 0 {
+			return
+		}
+	}
+	shape := float64(coverage) / m
+
+	// Source color, unpremultiplied.
+	as := float64(ca) / m
+	cs := [3]float64{float64(cr) / float64(ca), float64(cg) / float64(ca), float64(cb) / float64(ca)}
+	as *= opacity
+
+	// Objects inside knockout groups are composited with the initial backdrop
+	// of the group and the result replaces the current backdrop, proportionally
+	// to the shape of the object.
+	i := dst.PixOffset(x, y)
+	backdrop := dst.Pix[i : i+4]
+	if c.knockout != nil {
+		backdrop = c.knockout.Pix[c.knockout.PixOffset(x, y):][:4]
+	} else {
+		as *= shape
+	}
+
+	ab := float64(backdrop[3]) / 255
+	var cbd [3]float64
+	if ab > 0 {
+		for j := range cbd {
+			cbd[j] = float64(backdrop[j]) / 255 / ab
+		}
+	}
+
+	mix := blendColors(c.blend, cbd, cs)
+	ar := as + ab - as*ab
+
+	var res [4]float64
+	for j := 0; j < 3; j++ {
+		res[j] = (1-as)*ab*cbd[j] + as*((1-ab)*cs[j]+ab*mix[j])
+	}
+	res[3] = ar
+
+	if c.knockout != nil {
+		for j := range res {
+			cur := float64(dst.Pix[i+j]) / 255
+			res[j] = cur + (res[j]-cur)*shape
+		}
+	}
+	for j, v := range res {
+		dst.Pix[i+j] = uint8(math.Round(math.Min(math.Max(v, 0), 1) * 255))
+	}
+}
+
+// blendColors returns the result of the blend function corresponding to
+// the specified blend mode, for the backdrop color `cb` and the source
+// color `cs`.
+func blendColors(mode context.BlendMode, cb, cs [3]float64) [3]float64 {
+	switch mode {
+	case context.BlendModeNormal:
+		return cs
+	case context.BlendModeHue:
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case context.BlendModeSaturation:
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case context.BlendModeColor:
+		return setLum(cs, lum(cb))
+	case context.BlendModeLuminosity:
+		return setLum(cb, lum(cs))
+	}
+
+	var res [3]float64
+	for i := range res {
+		res[i] = blendComponent(mode, cb[i], cs[i])
+	}
+	return res
+}
+
+// blendComponent applies the separable blend function of the specified
+// blend mode to a single color component.
+func blendComponent(mode context.BlendMode, cb, cs float64) float64 {
+	switch mode {
+	case context.BlendModeMultiply:
+		return cb * cs
+	case context.BlendModeScreen:
+		return cb + cs - cb*cs
+	case context.BlendModeOverlay:
+		return blendComponent(context.BlendModeHardLight, cs, cb)
+	case context.BlendModeDarken:
+		return math.Min(cb, cs)
+	case context.BlendModeLighten:
+		return math.Max(cb, cs)
+	case context.BlendModeColorDodge:
+		if cb == 0 {
+			return 0
+		}
+		if cs >= 1 {
+			return 1
+		}
+		return math.Min(1, cb/(1-cs))
+	case context.BlendModeColorBurn:
+		if cb >= 1 {
+			return 1
+		}
+		if cs <= 0 {
+			return 0
+		}
+		return 1 - math.Min(1, (1-cb)/cs)
+	case context.BlendModeHardLight:
+		if cs <= 0.5 {
+			return cb * 2 * cs
+		}
+		cs = 2*cs - 1
+		return cb + cs - cb*cs
+	case context.BlendModeSoftLight:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		d := math.Sqrt(cb)
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case context.BlendModeDifference:
+		return math.Abs(cb - cs)
+	case context.BlendModeExclusion:
+		return cb + cs - 2*cb*cs
+	}
+	return cs
+}
+
+// Helper functions of the non-separable blend modes (section 11.3.5.3 PDF32000_2008).
+
+func lum(c [3]float64) float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+func clipColor(c [3]float64) [3]float64 {
+	l := lum(c)
+	n := math.Min(c[0], math.Min(c[1], c[2]))
+	x := math.Max(c[0], math.Max(c[1], c[2]))
+	for i := range c {
+		if n < 0 {
+			c[i] = l + (c[i]-l)*l/(l-n)
+		}
+		if x > 1 {
+			c[i] = l + (c[i]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
+
+func setLum(c [3]float64, l float64) [3]float64 {
+	d := l - lum(c)
+	for i := range c {
+		c[i] += d
+	}
+	return clipColor(c)
+}
+
+func sat(c [3]float64) float64 {
+	return math.Max(c[0], math.Max(c[1], c[2])) - math.Min(c[0], math.Min(c[1], c[2]))
+}
+
+func setSat(c [3]float64, s float64) [3]float64 {
+	// Sort the component indices by value.
+	min, mid, max := 0, 1, 2
+	if c[min] > c[mid] {
+		min, mid = mid, min
+	}
+	if c[mid] > c[max] {
+		mid, max = max, mid
+	}
+	if c[min] > c[mid] {
+		min, mid = mid, min
+	}
+
+	var res [3]float64
+	if c[max] > c[min] {
+		res[mid] = (c[mid] - c[min]) * s / (c[max] - c[min])
+		res[max] = s
+	}
+	return res
+}
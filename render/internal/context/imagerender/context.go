@@ -49,6 +49,24 @@ type Context struct {
 	matrix        transform.Matrix
 	textState     *context.TextState
 	stack         []*Context
+	fillAlpha     float64
+	strokeAlpha   float64
+	blendMode     context.BlendMode
+	softMask      *image.Alpha
+	knockout      *image.RGBA
+	groups        []*group
+	aliased       bool
+}
+
+// group represents a transparency group which is being painted.
+type group struct {
+	backdrop    *image.RGBA
+	isolated    bool
+	fillAlpha   float64
+	strokeAlpha float64
+	blendMode   context.BlendMode
+	softMask    *image.Alpha
+	knockout    *image.RGBA
 }
 
 // NewContext creates a new image.RGBA with the specified width and height
@@ -81,6 +99,8 @@ func NewContextForRGBA(im *image.RGBA) *Context {
 		fillRule:      context.FillRuleWinding,
 		matrix:        transform.IdentityMatrix(),
 		textState:     context.NewTextState(),
+		fillAlpha:     1,
+		strokeAlpha:   1,
 	}
 }
 
@@ -382,7 +402,7 @@ func (dc *Context) stroke(painter raster.Painter) {
 	r.UseNonZeroWinding = true
 	r.Clear()
 	r.AddStroke(path, fix(dc.lineWidth), dc.capper(), dc.joiner())
-	r.Rasterize(painter)
+	r.Rasterize(dc.spanPainter(painter))
 }
 
 func (dc *Context) fill(painter raster.Painter) {
@@ -396,7 +416,44 @@ func (dc *Context) fill(painter raster.Painter) {
 	r.UseNonZeroWinding = dc.fillRule == context.FillRuleWinding
 	r.Clear()
 	r.AddPath(path)
-	r.Rasterize(painter)
+	r.Rasterize(dc.spanPainter(painter))
+}
+
+// SetAntialias enables or disables anti-aliasing. When disabled, the pixels
+// covered by paths and glyphs are either fully painted or left untouched.
+// Anti-aliasing is enabled by default.
+func (dc *Context) SetAntialias(enabled bool) {
+	dc.aliased = !enabled
+}
+
+// spanPainter returns the painter used to rasterize paths. If anti-aliasing
+// is disabled, the specified painter is wrapped so that partially covered
+// pixels are either fully painted or skipped.
+func (dc *Context) spanPainter(painter raster.Painter) raster.Painter {
+	if !dc.aliased {
+		return painter
+	}
+	return aliasedPainter{painter}
+}
+
+// aliasedPainter is a painter which rounds the coverage of the spans passed
+// to the wrapped painter.
+type aliasedPainter struct {
+	painter raster.Painter
+}
+
+// Paint rounds the coverage of the specified spans and paints the spans
+// which are at least half covered.
+func (p aliasedPainter) Paint(ss []raster.Span, done bool) {
+	spans := ss[:0]
+	for _, s := range ss {
+		if s.Alpha < 0x8000 {
+			continue
+		}
+		s.Alpha = 0xffff
+		spans = append(spans, s)
+	}
+	p.painter.Paint(spans, done)
 }
 
 // StrokePreserve strokes the current path with the current color, line width,
@@ -404,7 +461,8 @@ func (dc *Context) fill(painter raster.Painter) {
 // operation.
 func (dc *Context) StrokePreserve() {
 	var painter raster.Painter
-	if dc.mask == nil {
+	comp := dc.compositor(dc.strokeAlpha)
+	if dc.mask == nil && comp == nil {
 		if pattern, ok := dc.strokePattern.(*solidPattern); ok {
 			// with a nil mask and a solid color pattern, we can be more efficient
 			// TODO: refactor so we don't have to do this type assertion stuff?
@@ -414,7 +472,7 @@ func (dc *Context) StrokePreserve() {
 		}
 	}
 	if painter == nil {
-		painter = newPatternPainter(dc.im, dc.mask, dc.strokePattern)
+		painter = newPatternPainter(dc.im, dc.mask, dc.strokePattern, comp)
 	}
 	dc.stroke(painter)
 }
@@ -431,7 +489,8 @@ func (dc *Context) Stroke() {
 // are implicity closed. The path is preserved after this operation.
 func (dc *Context) FillPreserve() {
 	var painter raster.Painter
-	if dc.mask == nil {
+	comp := dc.compositor(dc.fillAlpha)
+	if dc.mask == nil && comp == nil {
 		if pattern, ok := dc.fillPattern.(*solidPattern); ok {
 			// with a nil mask and a solid color pattern, we can be more efficient
 			// TODO: refactor so we don't have to do this type assertion stuff?
@@ -441,7 +500,7 @@ func (dc *Context) FillPreserve() {
 		}
 	}
 	if painter == nil {
-		painter = newPatternPainter(dc.im, dc.mask, dc.fillPattern)
+		painter = newPatternPainter(dc.im, dc.mask, dc.fillPattern, comp)
 	}
 	dc.fill(painter)
 }
@@ -650,14 +709,25 @@ func (dc *Context) DrawImageAnchored(im image.Image, x, y int, ax, ay float64) {
 	m := dc.matrix.Clone()
 	m.Translate(float64(x), float64(y))
 	s2d := f64.Aff3{m[0], m[3], m[6], m[1], m[4], m[7]}
+
+	// Draw the image on a separate layer if it has to be composited using
+	// the transparency parameters of the context.
+	dst := dc.im
+	comp := dc.compositor(dc.fillAlpha)
+	if comp != nil {
+		dst = image.NewRGBA(dc.im.Bounds())
+	}
 	if dc.mask == nil {
-		transformer.Transform(dc.im, s2d, im, im.Bounds(), draw.Over, nil)
+		transformer.Transform(dst, s2d, im, im.Bounds(), draw.Over, nil)
 	} else {
-		transformer.Transform(dc.im, s2d, im, im.Bounds(), draw.Over, &draw.Options{
+		transformer.Transform(dst, s2d, im, im.Bounds(), draw.Over, &draw.Options{
 			DstMask:  dc.mask,
 			DstMaskP: image.ZP,
 		})
 	}
+	if comp != nil {
+		dc.compositeLayer(dst, comp)
+	}
 }
 
 //
@@ -690,7 +760,11 @@ func (dc *Context) drawString(im *image.RGBA, s string, x, y float64) {
 			continue
 		}
 		sr := dr.Sub(dr.Min)
-		transformer := draw.BiLinear
+		var transformer draw.Transformer = draw.BiLinear
+		if dc.aliased {
+			transformer = draw.NearestNeighbor
+			mask, maskp = aliasedMask(mask, maskp, sr.Size())
+		}
 		m := dc.matrix.Clone()
 		m.Translate(float64(dr.Min.X), float64(dr.Min.Y))
 		s2d := f64.Aff3{m[0], m[3], m[6], m[1], m[4], m[7]}
@@ -703,6 +777,21 @@ func (dc *Context) drawString(im *image.RGBA, s string, x, y float64) {
 	}
 }
 
+// aliasedMask returns a copy of the specified glyph mask whose alpha values
+// are rounded to either transparent or opaque.
+func aliasedMask(mask image.Image, maskp image.Point, size image.Point) (image.Image, image.Point) {
+	aliased := image.NewAlpha(image.Rectangle{Max: size})
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			_, _, _, a := mask.At(maskp.X+x, maskp.Y+y).RGBA()
+			if a >= 0x8000 {
+				aliased.Pix[y*aliased.Stride+x] = 0xff
+			}
+		}
+	}
+	return aliased, image.ZP
+}
+
 // DrawString draws the specified text at the specified point.
 func (dc *Context) DrawString(s string, x, y float64) {
 	dc.DrawStringAnchored(s, x, y, 0, 0)
@@ -715,13 +804,25 @@ func (dc *Context) DrawStringAnchored(s string, x, y, ax, ay float64) {
 	w, h := dc.MeasureString(s)
 	x -= ax * w
 	y += ay * h
-	if dc.mask == nil {
+	comp := dc.compositor(dc.fillAlpha)
+	if dc.mask == nil && comp == nil {
 		dc.drawString(dc.im, s, x, y)
-	} else {
-		im := image.NewRGBA(image.Rect(0, 0, dc.width, dc.height))
-		dc.drawString(im, s, x, y)
+		return
+	}
+
+	im := image.NewRGBA(image.Rect(0, 0, dc.width, dc.height))
+	dc.drawString(im, s, x, y)
+	if comp == nil {
 		draw.DrawMask(dc.im, dc.im.Bounds(), im, image.ZP, dc.mask, image.ZP, draw.Over)
+		return
+	}
+
+	if dc.mask != nil {
+		layer := image.NewRGBA(im.Bounds())
+		draw.DrawMask(layer, layer.Bounds(), im, image.ZP, dc.mask, image.ZP, draw.Src)
+		im = layer
 	}
+	dc.compositeLayer(im, comp)
 }
 
 // MeasureString returns the rendered width and height of the specified text
@@ -734,6 +835,184 @@ func (dc *Context) MeasureString(s string) (w, h float64) {
 	return float64(a >> 6), dc.textState.Tf.Size
 }
 
+//
+// Transparency operations
+//
+
+// SetFillAlpha sets the constant alpha used for fill operations, including
+// text and images. The value should be in range 0-1.
+func (dc *Context) SetFillAlpha(alpha float64) {
+	dc.fillAlpha = alpha
+}
+
+// SetStrokeAlpha sets the constant alpha used for stroke operations.
+// The value should be in range 0-1.
+func (dc *Context) SetStrokeAlpha(alpha float64) {
+	dc.strokeAlpha = alpha
+}
+
+// SetBlendMode sets the blend mode used to composite painted objects with
+// the backdrop.
+func (dc *Context) SetBlendMode(mode context.BlendMode) {
+	dc.blendMode = mode
+}
+
+// SetSoftMask sets the soft mask applied to painting operations. The mask
+// must have the size of the context. A nil mask disables soft masking.
+func (dc *Context) SetSoftMask(mask *image.Alpha) {
+	if mask != nil && mask.Bounds().Size() != dc.im.Bounds().Size() {
+		mask = nil
+	}
+	dc.softMask = mask
+}
+
+// BeginGroup starts a transparency group. Subsequent operations are painted
+// onto a separate layer, until the group is ended. The fill alpha, blend mode
+// and soft mask are reset for the operations inside the group.
+func (dc *Context) BeginGroup(isolated, knockout bool) {
+	g := &group{
+		backdrop:    dc.im,
+		isolated:    isolated,
+		fillAlpha:   dc.fillAlpha,
+		strokeAlpha: dc.strokeAlpha,
+		blendMode:   dc.blendMode,
+		softMask:    dc.softMask,
+		knockout:    dc.knockout,
+	}
+	dc.groups = append(dc.groups, g)
+
+	dc.im = image.NewRGBA(g.backdrop.Bounds())
+	if !isolated {
+		copy(dc.im.Pix, g.backdrop.Pix)
+	}
+
+	dc.knockout = nil
+	if knockout {
+		dc.knockout = image.NewRGBA(dc.im.Bounds())
+		copy(dc.knockout.Pix, dc.im.Pix)
+	}
+	dc.fillAlpha = 1
+	dc.strokeAlpha = 1
+	dc.blendMode = context.BlendModeNormal
+	dc.softMask = nil
+}
+
+// EndGroup ends the current transparency group and composites its layer with
+// the backdrop, using the fill alpha, blend mode and soft mask which were
+// active when the group was started. Non-isolated groups, which already
+// contain the backdrop, are composited by interpolating between the backdrop
+// and the layer of the group.
+func (dc *Context) EndGroup() {
+	layer, g := dc.endGroup()
+	if g == nil {
+		return
+	}
+
+	comp := &compositor{
+		alpha:    dc.fillAlpha,
+		blend:    dc.blendMode,
+		softMask: dc.softMask,
+		knockout: dc.knockout,
+	}
+	if g.isolated {
+		dc.compositeLayer(layer, comp)
+		return
+	}
+
+	for y := 0; y < dc.height; y++ {
+		for x := 0; x < dc.width; x++ {
+			k := comp.alpha
+			if comp.softMask != nil {
+				k *= float64(comp.softMask.AlphaAt(x, y).A) / 255
+			}
+			if k == 0 {
+				continue
+			}
+
+			i := dc.im.PixOffset(x, y)
+			for j := i; j < i+4; j++ {
+				b, s := float64(dc.im.Pix[j]), float64(layer.Pix[j])
+				dc.im.Pix[j] = uint8(math.Round(b + (s-b)*k))
+			}
+		}
+	}
+}
+
+// EndGroupAsMask ends the current transparency group and returns a soft mask
+// computed from its layer, using either the luminosity or the alpha of the
+// painted pixels. The layer is not composited with the backdrop.
+func (dc *Context) EndGroupAsMask(luminosity bool) *image.Alpha {
+	layer, g := dc.endGroup()
+	if g == nil {
+		return nil
+	}
+
+	mask := image.NewAlpha(layer.Bounds())
+	for i := range mask.Pix {
+		px := layer.Pix[4*i : 4*i+4]
+		if !luminosity {
+			mask.Pix[i] = px[3]
+			continue
+		}
+
+		// The color values are premultiplied, which corresponds to the
+		// layer being composited onto a black backdrop.
+		l := 0.3*float64(px[0]) + 0.59*float64(px[1]) + 0.11*float64(px[2])
+		mask.Pix[i] = uint8(math.Round(math.Min(l, 255)))
+	}
+	return mask
+}
+
+// endGroup removes the current transparency group and restores the state of
+// the context from before the group was started. Returns the layer of the
+// removed group.
+func (dc *Context) endGroup() (*image.RGBA, *group) {
+	if len(dc.groups) == 0 {
+		return nil, nil
+	}
+	g := dc.groups[len(dc.groups)-1]
+	dc.groups = dc.groups[:len(dc.groups)-1]
+
+	layer := dc.im
+	dc.im = g.backdrop
+	dc.fillAlpha = g.fillAlpha
+	dc.strokeAlpha = g.strokeAlpha
+	dc.blendMode = g.blendMode
+	dc.softMask = g.softMask
+	dc.knockout = g.knockout
+	return layer, g
+}
+
+// compositor returns the compositor used for painting operations with the
+// specified constant alpha. Returns nil if the painted pixels can be simply
+// drawn over the backdrop.
+func (dc *Context) compositor(alpha float64) *compositor {
+	if alpha >= 1 && dc.blendMode == context.BlendModeNormal && dc.softMask == nil && dc.knockout == nil {
+		return nil
+	}
+	return &compositor{
+		alpha:    alpha,
+		blend:    dc.blendMode,
+		softMask: dc.softMask,
+		knockout: dc.knockout,
+	}
+}
+
+// compositeLayer composites the pixels of the specified layer, which must
+// have the size of the context, onto the image of the context.
+func (dc *Context) compositeLayer(layer *image.RGBA, comp *compositor) {
+	for y := 0; y < dc.height; y++ {
+		for x := 0; x < dc.width; x++ {
+			i := layer.PixOffset(x, y)
+			if layer.Pix[i+3] == 0 {
+				continue
+			}
+			c := color.RGBA{layer.Pix[i], layer.Pix[i+1], layer.Pix[i+2], layer.Pix[i+3]}
+			comp.compose(dc.im, x, y, c, 1<<16-1)
+		}
+	}
+}
+
 //
 // Transformation matrix operations
 //
@@ -71,6 +71,7 @@ type patternPainter struct {
 	im   *image.RGBA
 	mask *image.Alpha
 	p    context.Pattern
+	comp *compositor
 }
 
 // Paint satisfies the Painter interface.
@@ -107,6 +108,10 @@ func (r *patternPainter) Paint(ss []raster.Span, done bool) {
 				}
 			}
 			c := r.p.ColorAt(x, y)
+			if r.comp != nil {
+				r.comp.compose(r.im, x, y, c, ma)
+				continue
+			}
 			cr, cg, cb, ca := c.RGBA()
 			dr := uint32(r.im.Pix[i+0])
 			dg := uint32(r.im.Pix[i+1])
@@ -121,6 +126,6 @@ func (r *patternPainter) Paint(ss []raster.Span, done bool) {
 	}
 }
 
-func newPatternPainter(im *image.RGBA, mask *image.Alpha, p context.Pattern) *patternPainter {
-	return &patternPainter{im, mask, p}
+func newPatternPainter(im *image.RGBA, mask *image.Alpha, p context.Pattern, comp *compositor) *patternPainter {
+	return &patternPainter{im, mask, p, comp}
 }
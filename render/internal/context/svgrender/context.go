@@ -0,0 +1,897 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package svgrender
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// Context represents an SVG rendering context. Painting operations are
+// converted to SVG elements, whose coordinates are expressed in device
+// space. Glyphs drawn using DrawString are converted to text elements using
+// web fonts created from the TrueType font programs of the text fonts.
+type Context struct {
+	width     int
+	height    int
+	state     state
+	stack     []state
+	textState *context.TextState
+
+	// Current path, in device space.
+	path       strings.Builder
+	start      transform.Point
+	current    transform.Point
+	hasCurrent bool
+	bounds     rect
+
+	// The content of transparency groups is written to separate layers.
+	layers []*layer
+	defs   bytes.Buffer
+	nextID int
+
+	fonts    map[*byte]*webFont
+	fontList []*webFont
+	masks    map[*image.Alpha]string
+}
+
+// state represents the graphics state of the context, which is saved and
+// restored by the Push and Pop operations.
+type state struct {
+	matrix      transform.Matrix
+	fill        paint
+	stroke      paint
+	fillAlpha   float64
+	strokeAlpha float64
+	blendMode   context.BlendMode
+	softMask    string
+	lineWidth   float64
+	lineCap     context.LineCap
+	lineJoin    context.LineJoin
+	dashes      []float64
+	dashOffset  float64
+	fillRule    context.FillRule
+	clip        string
+}
+
+// paint represents a fill or stroke color or pattern.
+type paint struct {
+	color   color.NRGBA
+	pattern context.Pattern
+}
+
+// layer holds the elements of the page or of a transparency group, along
+// with the transparency parameters used to composite the group.
+type layer struct {
+	buf         bytes.Buffer
+	isolated    bool
+	fillAlpha   float64
+	strokeAlpha float64
+	blendMode   context.BlendMode
+	softMask    string
+}
+
+// NewContext returns a new SVG rendering context having the specified
+// width and height.
+func NewContext(width, height int) *Context {
+	return &Context{
+		width:  width,
+		height: height,
+		state: state{
+			matrix:      transform.IdentityMatrix(),
+			fill:        paint{color: color.NRGBA{A: 255}},
+			stroke:      paint{color: color.NRGBA{A: 255}},
+			fillAlpha:   1,
+			strokeAlpha: 1,
+			lineWidth:   1,
+			fillRule:    context.FillRuleWinding,
+		},
+		textState: context.NewTextState(),
+		bounds:    emptyRect(),
+		layers:    []*layer{{}},
+		fonts:     map[*byte]*webFont{},
+		masks:     map[*image.Alpha]string{},
+	}
+}
+
+// IsVector returns true, as the context renders to a vector target.
+func (dc *Context) IsVector() bool {
+	return true
+}
+
+// Width returns the width of the rendering area.
+func (dc *Context) Width() int {
+	return dc.width
+}
+
+// Height returns the height of the rendering area.
+func (dc *Context) Height() int {
+	return dc.height
+}
+
+// Write writes the SVG document containing the elements drawn by the
+// context. The specified view box selects the area of the rendering area
+// which is displayed.
+func (dc *Context) Write(w io.Writer, x, y, width, height float64) error {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" `+
+		`version="1.1" width="%s" height="%s" viewBox="%s %s %s %s">`+"\n",
+		num(width), num(height), num(x), num(y), num(width), num(height))
+
+	fontStyles := dc.fontStyles()
+	if fontStyles != "" || dc.defs.Len() > 0 {
+		buf.WriteString("<defs>\n")
+		if fontStyles != "" {
+			fmt.Fprintf(&buf, "<style type=\"text/css\"><![CDATA[\n%s]]></style>\n", fontStyles)
+		}
+		buf.Write(dc.defs.Bytes())
+		buf.WriteString("</defs>\n")
+	}
+
+	// Groups which have not been ended are written as part of the page.
+	for _, l := range dc.layers {
+		buf.Write(l.buf.Bytes())
+	}
+	buf.WriteString("</svg>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+//
+// Graphics state operations
+//
+
+// Push saves the current state of the context for later retrieval.
+func (dc *Context) Push() {
+	s := dc.state
+	s.dashes = append([]float64(nil), dc.state.dashes...)
+	dc.stack = append(dc.stack, s)
+}
+
+// Pop restores the last saved context state from the stack.
+func (dc *Context) Pop() {
+	if len(dc.stack) == 0 {
+		return
+	}
+	dc.state = dc.stack[len(dc.stack)-1]
+	dc.stack = dc.stack[:len(dc.stack)-1]
+}
+
+//
+// Matrix operations
+//
+
+// Matrix returns the current transformation matrix.
+func (dc *Context) Matrix() transform.Matrix {
+	return dc.state.matrix
+}
+
+// SetMatrix modifies the transformation matrix.
+func (dc *Context) SetMatrix(m transform.Matrix) {
+	dc.state.matrix = m
+}
+
+// Translate updates the current matrix with a translation.
+func (dc *Context) Translate(x, y float64) {
+	dc.state.matrix.Translate(x, y)
+}
+
+// Scale updates the current matrix with a scaling factor.
+// Scaling occurs about the origin.
+func (dc *Context) Scale(x, y float64) {
+	dc.state.matrix.Scale(x, y)
+}
+
+// Rotate updates the current matrix with a anticlockwise rotation.
+// Rotation occurs about the origin. Angle is specified in radians.
+func (dc *Context) Rotate(angle float64) {
+	dc.state.matrix.Rotate(angle)
+}
+
+//
+// Path operations
+//
+
+// MoveTo starts a new subpath within the current path starting at the
+// specified point.
+func (dc *Context) MoveTo(x, y float64) {
+	p := dc.transform(x, y)
+	fmt.Fprintf(&dc.path, "M%s %s", num(p.X), num(p.Y))
+	dc.start = p
+	dc.current = p
+	dc.hasCurrent = true
+}
+
+// LineTo adds a line segment to the current path starting at the current
+// point. If there is no current point, it is equivalent to MoveTo(x, y).
+func (dc *Context) LineTo(x, y float64) {
+	if !dc.hasCurrent {
+		dc.MoveTo(x, y)
+		return
+	}
+	p := dc.transform(x, y)
+	fmt.Fprintf(&dc.path, "L%s %s", num(p.X), num(p.Y))
+	dc.current = p
+}
+
+// QuadraticTo adds a quadratic bezier curve to the current path starting at
+// the current point. If there is no current point, it first performs
+// MoveTo(x1, y1).
+func (dc *Context) QuadraticTo(x1, y1, x2, y2 float64) {
+	if !dc.hasCurrent {
+		dc.MoveTo(x1, y1)
+	}
+	p1, p2 := dc.transform(x1, y1), dc.transform(x2, y2)
+	fmt.Fprintf(&dc.path, "Q%s %s %s %s", num(p1.X), num(p1.Y), num(p2.X), num(p2.Y))
+	dc.current = p2
+}
+
+// CubicTo adds a cubic bezier curve to the current path starting at the
+// current point. If there is no current point, it first performs
+// MoveTo(x1, y1).
+func (dc *Context) CubicTo(x1, y1, x2, y2, x3, y3 float64) {
+	if !dc.hasCurrent {
+		dc.MoveTo(x1, y1)
+	}
+	p1, p2, p3 := dc.transform(x1, y1), dc.transform(x2, y2), dc.transform(x3, y3)
+	fmt.Fprintf(&dc.path, "C%s %s %s %s %s %s",
+		num(p1.X), num(p1.Y), num(p2.X), num(p2.Y), num(p3.X), num(p3.Y))
+	dc.current = p3
+}
+
+// ClosePath adds a line segment from the current point to the beginning of
+// the current subpath.
+func (dc *Context) ClosePath() {
+	if dc.hasCurrent {
+		dc.path.WriteString("Z")
+		dc.current = dc.start
+	}
+}
+
+// ClearPath clears the current path. There is no current point after this
+// operation.
+func (dc *Context) ClearPath() {
+	dc.path.Reset()
+	dc.hasCurrent = false
+	dc.bounds = emptyRect()
+}
+
+// NewSubPath starts a new subpath within the current path. There is no
+// current point after this operation.
+func (dc *Context) NewSubPath() {
+	dc.hasCurrent = false
+}
+
+// transform converts the specified point to device space and extends the
+// bounds of the current path.
+func (dc *Context) transform(x, y float64) transform.Point {
+	x, y = dc.state.matrix.Transform(x, y)
+	dc.bounds.add(x, y)
+	return transform.NewPoint(x, y)
+}
+
+//
+// Clipping operations
+//
+
+// ClipPreserve updates the clipping region by intersecting the current
+// clipping region with the current path as it would be filled by Fill().
+// The path is preserved after this operation.
+func (dc *Context) ClipPreserve() {
+	id := dc.newID("c")
+	fmt.Fprintf(&dc.defs, `<clipPath id="%s" clipPathUnits="userSpaceOnUse"`, id)
+	if dc.state.clip != "" {
+		fmt.Fprintf(&dc.defs, ` clip-path="url(#%s)"`, dc.state.clip)
+	}
+	fmt.Fprintf(&dc.defs, `><path d="%s"`, dc.pathData())
+	if dc.state.fillRule == context.FillRuleEvenOdd {
+		dc.defs.WriteString(` clip-rule="evenodd"`)
+	}
+	dc.defs.WriteString("/></clipPath>\n")
+	dc.state.clip = id
+}
+
+// Clip updates the clipping region by intersecting the current clipping
+// region with the current path as it would be filled by Fill(). The path is
+// cleared after this operation.
+func (dc *Context) Clip() {
+	dc.ClipPreserve()
+	dc.ClearPath()
+}
+
+// ResetClip clears the clipping region.
+func (dc *Context) ResetClip() {
+	dc.state.clip = ""
+}
+
+//
+// Line style operations
+//
+
+// LineWidth returns the current line width.
+func (dc *Context) LineWidth() float64 {
+	return dc.state.lineWidth
+}
+
+// SetLineWidth sets the line width.
+func (dc *Context) SetLineWidth(lineWidth float64) {
+	dc.state.lineWidth = lineWidth
+}
+
+// SetLineCap sets the line cap style.
+func (dc *Context) SetLineCap(lineCap context.LineCap) {
+	dc.state.lineCap = lineCap
+}
+
+// SetLineJoin sets the line join style.
+func (dc *Context) SetLineJoin(lineJoin context.LineJoin) {
+	dc.state.lineJoin = lineJoin
+}
+
+// SetDash sets the line dash pattern.
+func (dc *Context) SetDash(dashes ...float64) {
+	dc.state.dashes = dashes
+}
+
+// SetDashOffset sets the initial offset into the dash pattern to use when
+// stroking dashed paths.
+func (dc *Context) SetDashOffset(offset float64) {
+	dc.state.dashOffset = offset
+}
+
+//
+// Fill and stroke operations
+//
+
+// FillPreserve fills the current path with the current fill style. Open
+// subpaths are implicitly closed. The path is preserved after this
+// operation.
+func (dc *Context) FillPreserve() {
+	if dc.path.Len() == 0 {
+		return
+	}
+
+	var attrs strings.Builder
+	fmt.Fprintf(&attrs, `d="%s"`, dc.pathData())
+	dc.writePaint(&attrs, "fill", dc.state.fill, dc.state.fillAlpha, dc.bounds)
+	if dc.state.fillRule == context.FillRuleEvenOdd {
+		attrs.WriteString(` fill-rule="evenodd"`)
+	}
+	dc.writeElement("<path "+attrs.String()+"/>", false)
+}
+
+// Fill fills the current path with the current fill style. Open subpaths
+// are implicitly closed. The path is cleared after this operation.
+func (dc *Context) Fill() {
+	dc.FillPreserve()
+	dc.ClearPath()
+}
+
+// StrokePreserve strokes the current path with the current stroke style,
+// line width, line cap, line join and dash settings. The path is preserved
+// after this operation.
+func (dc *Context) StrokePreserve() {
+	if dc.path.Len() == 0 {
+		return
+	}
+
+	s := dc.state
+	bounds := dc.bounds
+	bounds.expand(s.lineWidth / 2)
+
+	var attrs strings.Builder
+	fmt.Fprintf(&attrs, `d="%s" fill="none"`, dc.pathData())
+	dc.writePaint(&attrs, "stroke", s.stroke, s.strokeAlpha, bounds)
+	fmt.Fprintf(&attrs, ` stroke-width="%s"`, num(s.lineWidth))
+	switch s.lineCap {
+	case context.LineCapRound:
+		attrs.WriteString(` stroke-linecap="round"`)
+	case context.LineCapSquare:
+		attrs.WriteString(` stroke-linecap="square"`)
+	}
+	switch s.lineJoin {
+	case context.LineJoinRound:
+		attrs.WriteString(` stroke-linejoin="round"`)
+	case context.LineJoinBevel:
+		attrs.WriteString(` stroke-linejoin="bevel"`)
+	}
+	if len(s.dashes) > 0 {
+		dashes := make([]string, len(s.dashes))
+		for i, d := range s.dashes {
+			dashes[i] = num(d)
+		}
+		fmt.Fprintf(&attrs, ` stroke-dasharray="%s"`, strings.Join(dashes, " "))
+		if s.dashOffset != 0 {
+			fmt.Fprintf(&attrs, ` stroke-dashoffset="%s"`, num(s.dashOffset))
+		}
+	}
+	dc.writeElement("<path "+attrs.String()+"/>", false)
+}
+
+// Stroke strokes the current path with the current stroke style, line
+// width, line cap, line join and dash settings. The path is cleared after
+// this operation.
+func (dc *Context) Stroke() {
+	dc.StrokePreserve()
+	dc.ClearPath()
+}
+
+// SetRGBA sets both the fill and stroke colors.
+// r, g, b, a values should be in range 0-1.
+func (dc *Context) SetRGBA(r, g, b, a float64) {
+	dc.SetFillRGBA(r, g, b, a)
+	dc.SetStrokeRGBA(r, g, b, a)
+}
+
+// SetFillRGBA sets the fill color.
+// r, g, b, a values should be in range 0-1.
+func (dc *Context) SetFillRGBA(r, g, b, a float64) {
+	dc.state.fill = paint{color: rgba(r, g, b, a)}
+}
+
+// SetFillStyle sets the current fill pattern.
+func (dc *Context) SetFillStyle(pattern context.Pattern) {
+	dc.state.fill = paint{pattern: pattern}
+}
+
+// SetFillRule sets the fill rule.
+func (dc *Context) SetFillRule(fillRule context.FillRule) {
+	dc.state.fillRule = fillRule
+}
+
+// SetStrokeRGBA sets the stroke color.
+// r, g, b, a values should be in range 0-1.
+func (dc *Context) SetStrokeRGBA(r, g, b, a float64) {
+	dc.state.stroke = paint{color: rgba(r, g, b, a)}
+}
+
+// SetStrokeStyle sets the current stroke pattern.
+func (dc *Context) SetStrokeStyle(pattern context.Pattern) {
+	dc.state.stroke = paint{pattern: pattern}
+}
+
+//
+// Text operations
+//
+
+// TextState returns the current text state.
+func (dc *Context) TextState() *context.TextState {
+	return dc.textState
+}
+
+// DrawString draws the specified text at the specified point, using the
+// current text font. The text is converted to a text element which uses a
+// web font created from the font program of the text font.
+func (dc *Context) DrawString(s string, x, y float64) {
+	tf := dc.textState.Tf
+	if tf == nil || s == "" {
+		return
+	}
+	wf := dc.webFont(tf.TrueTypeData())
+	if wf == nil {
+		return
+	}
+	for _, r := range s {
+		wf.runes[r] = struct{}{}
+	}
+
+	// The size of the text is not known, so raster patterns are painted
+	// over the entire rendering area.
+	bounds := rect{maxX: float64(dc.width), maxY: float64(dc.height)}
+
+	var attrs strings.Builder
+	fmt.Fprintf(&attrs, `transform="%s" x="%s" y="%s" font-family="%s" font-size="%s"`,
+		matrixAttr(dc.state.matrix), num(x), num(y), wf.family, num(tf.Size))
+	dc.writePaint(&attrs, "fill", dc.state.fill, dc.state.fillAlpha, bounds)
+	dc.writeElement(fmt.Sprintf(`<text %s xml:space="preserve">%s</text>`, attrs.String(), escape(s)), true)
+}
+
+// MeasureString returns the width and height of the specified string, using
+// the face of the current text font.
+func (dc *Context) MeasureString(s string) (w, h float64) {
+	tf := dc.textState.Tf
+	if tf == nil || tf.Face == nil {
+		return 0, 0
+	}
+	d := &font.Drawer{Face: tf.Face}
+	a := d.MeasureString(s)
+	return float64(a >> 6), tf.Size
+}
+
+//
+// Draw operations
+//
+
+// DrawRectangle draws the specified rectangle.
+func (dc *Context) DrawRectangle(x, y, w, h float64) {
+	dc.NewSubPath()
+	dc.MoveTo(x, y)
+	dc.LineTo(x+w, y)
+	dc.LineTo(x+w, y+h)
+	dc.LineTo(x, y+h)
+	dc.ClosePath()
+}
+
+// DrawImage draws the specified image at the specified point.
+func (dc *Context) DrawImage(im image.Image, x, y int) {
+	dc.DrawImageAnchored(im, x, y, 0, 0)
+}
+
+// DrawImageAnchored draws the specified image at the specified anchor point.
+// The anchor point is x - w * ax, y - h * ay, where w, h is the size of the
+// image. The image is embedded as a PNG data URI.
+func (dc *Context) DrawImageAnchored(im image.Image, x, y int, ax, ay float64) {
+	s := im.Bounds().Size()
+	if s.X == 0 || s.Y == 0 {
+		return
+	}
+	x -= int(ax * float64(s.X))
+	y -= int(ay * float64(s.Y))
+	m := dc.state.matrix.Clone()
+	m.Translate(float64(x), float64(y))
+
+	uri, err := pngDataURI(im)
+	if err != nil {
+		return
+	}
+
+	var attrs strings.Builder
+	fmt.Fprintf(&attrs, `transform="%s" width="%d" height="%d" preserveAspectRatio="none" xlink:href="%s"`,
+		matrixAttr(m), s.X, s.Y, uri)
+	if dc.state.fillAlpha < 1 {
+		fmt.Fprintf(&attrs, ` opacity="%s"`, num(dc.state.fillAlpha))
+	}
+	dc.writeElement("<image "+attrs.String()+"/>", true)
+}
+
+//
+// Transparency operations
+//
+
+// SetFillAlpha sets the constant alpha used for fill operations, including
+// text and images. The value should be in range 0-1.
+func (dc *Context) SetFillAlpha(alpha float64) {
+	dc.state.fillAlpha = alpha
+}
+
+// SetStrokeAlpha sets the constant alpha used for stroke operations.
+// The value should be in range 0-1.
+func (dc *Context) SetStrokeAlpha(alpha float64) {
+	dc.state.strokeAlpha = alpha
+}
+
+// SetBlendMode sets the blend mode used to composite painted objects
+// with the backdrop.
+func (dc *Context) SetBlendMode(mode context.BlendMode) {
+	dc.state.blendMode = mode
+}
+
+// SetSoftMask sets the soft mask applied to painting operations. The masks
+// returned by EndGroupAsMask are converted to mask elements containing the
+// elements of their group. Other masks must have the size of the rendering
+// area and are embedded as images. A nil mask disables soft masking.
+func (dc *Context) SetSoftMask(mask *image.Alpha) {
+	if mask == nil {
+		dc.state.softMask = ""
+		return
+	}
+	if id, ok := dc.masks[mask]; ok {
+		dc.state.softMask = id
+		return
+	}
+	if mask.Bounds().Size() != image.Pt(dc.width, dc.height) {
+		dc.state.softMask = ""
+		return
+	}
+
+	// The alpha values are converted to the luminosity of a gray image.
+	gray := image.NewGray(mask.Bounds())
+	copy(gray.Pix, mask.Pix)
+	uri, err := pngDataURI(gray)
+	if err != nil {
+		dc.state.softMask = ""
+		return
+	}
+
+	id := dc.newID("m")
+	fmt.Fprintf(&dc.defs, `<mask id="%s" maskUnits="userSpaceOnUse" x="0" y="0" width="%d" height="%d">`+
+		`<image width="%d" height="%d" xlink:href="%s"/></mask>`+"\n",
+		id, dc.width, dc.height, dc.width, dc.height, uri)
+	dc.masks[mask] = id
+	dc.state.softMask = id
+}
+
+// BeginGroup starts a transparency group. Subsequent elements are written
+// to a separate layer, until the group is ended. The fill alpha, blend mode
+// and soft mask are reset for the operations inside the group. Knockout
+// groups are not supported and are rendered as regular groups.
+func (dc *Context) BeginGroup(isolated, knockout bool) {
+	dc.layers = append(dc.layers, &layer{
+		isolated:    isolated,
+		fillAlpha:   dc.state.fillAlpha,
+		strokeAlpha: dc.state.strokeAlpha,
+		blendMode:   dc.state.blendMode,
+		softMask:    dc.state.softMask,
+	})
+	dc.state.fillAlpha = 1
+	dc.state.strokeAlpha = 1
+	dc.state.blendMode = context.BlendModeNormal
+	dc.state.softMask = ""
+}
+
+// EndGroup ends the current transparency group and composites its elements
+// with the backdrop, using the fill alpha, blend mode and soft mask which
+// were active when the group was started.
+func (dc *Context) EndGroup() {
+	l := dc.endGroup()
+	if l == nil {
+		return
+	}
+
+	var attrs strings.Builder
+	if dc.state.fillAlpha < 1 {
+		fmt.Fprintf(&attrs, ` opacity="%s"`, num(dc.state.fillAlpha))
+	}
+	if dc.state.softMask != "" {
+		fmt.Fprintf(&attrs, ` mask="url(#%s)"`, dc.state.softMask)
+	}
+
+	var style []string
+	if mode := blendModeNames[dc.state.blendMode]; mode != "" {
+		style = append(style, "mix-blend-mode:"+mode)
+	}
+	if l.isolated {
+		style = append(style, "isolation:isolate")
+	}
+	if len(style) > 0 {
+		fmt.Fprintf(&attrs, ` style="%s"`, strings.Join(style, ";"))
+	}
+
+	buf := &dc.layers[len(dc.layers)-1].buf
+	fmt.Fprintf(buf, "<g%s>\n", attrs.String())
+	buf.Write(l.buf.Bytes())
+	buf.WriteString("</g>\n")
+}
+
+// EndGroupAsMask ends the current transparency group and returns a soft
+// mask computed from its elements, using either their luminosity or their
+// alpha. The elements are written to a mask element instead of being
+// composited with the backdrop. The returned mask only identifies the mask
+// element and must be passed to SetSoftMask in order to be used.
+func (dc *Context) EndGroupAsMask(luminosity bool) *image.Alpha {
+	l := dc.endGroup()
+	if l == nil {
+		return nil
+	}
+
+	id := dc.newID("m")
+	fmt.Fprintf(&dc.defs, `<mask id="%s" maskUnits="userSpaceOnUse" x="0" y="0" width="%d" height="%d"`,
+		id, dc.width, dc.height)
+	if !luminosity {
+		dc.defs.WriteString(` style="mask-type:alpha"`)
+	}
+	dc.defs.WriteString(">\n")
+	dc.defs.Write(l.buf.Bytes())
+	dc.defs.WriteString("</mask>\n")
+
+	mask := image.NewAlpha(image.Rect(0, 0, 1, 1))
+	dc.masks[mask] = id
+	return mask
+}
+
+// endGroup removes the layer of the current transparency group and restores
+// the transparency parameters which were active when the group was started.
+func (dc *Context) endGroup() *layer {
+	if len(dc.layers) < 2 {
+		return nil
+	}
+	l := dc.layers[len(dc.layers)-1]
+	dc.layers = dc.layers[:len(dc.layers)-1]
+
+	dc.state.fillAlpha = l.fillAlpha
+	dc.state.strokeAlpha = l.strokeAlpha
+	dc.state.blendMode = l.blendMode
+	dc.state.softMask = l.softMask
+	return l
+}
+
+//
+// Element output
+//
+
+// writeElement writes the specified element to the current layer, applying
+// the clipping path, the soft mask and the blend mode of the context. The
+// clipping paths and masks are expressed in device space, so elements which
+// have a transform attribute are wrapped in a group.
+func (dc *Context) writeElement(elem string, transformed bool) {
+	var attrs strings.Builder
+	if dc.state.clip != "" {
+		fmt.Fprintf(&attrs, ` clip-path="url(#%s)"`, dc.state.clip)
+	}
+	if dc.state.softMask != "" {
+		fmt.Fprintf(&attrs, ` mask="url(#%s)"`, dc.state.softMask)
+	}
+	if mode := blendModeNames[dc.state.blendMode]; mode != "" {
+		fmt.Fprintf(&attrs, ` style="mix-blend-mode:%s"`, mode)
+	}
+
+	buf := &dc.layers[len(dc.layers)-1].buf
+	switch {
+	case attrs.Len() == 0:
+		buf.WriteString(elem)
+	case transformed:
+		fmt.Fprintf(buf, "<g%s>%s</g>", attrs.String(), elem)
+	default:
+		// Insert the attributes before the end of the empty element tag.
+		buf.WriteString(strings.TrimSuffix(elem, "/>"))
+		buf.WriteString(attrs.String())
+		buf.WriteString("/>")
+	}
+	buf.WriteString("\n")
+}
+
+// writePaint writes the attributes which specify the paint of a fill or
+// stroke operation. Patterns which cannot be represented as gradients are
+// rasterized over the specified bounds.
+func (dc *Context) writePaint(attrs *strings.Builder, prop string, p paint, alpha float64, bounds rect) {
+	if p.pattern == nil {
+		fmt.Fprintf(attrs, ` %s="%s"`, prop, hexColor(p.color))
+		if a := alpha * float64(p.color.A) / 255; a < 1 {
+			fmt.Fprintf(attrs, ` %s-opacity="%s"`, prop, num(a))
+		}
+		return
+	}
+
+	id := dc.patternID(p.pattern, bounds)
+	if id == "" {
+		fmt.Fprintf(attrs, ` %s="none"`, prop)
+		return
+	}
+	fmt.Fprintf(attrs, ` %s="url(#%s)"`, prop, id)
+	if alpha < 1 {
+		fmt.Fprintf(attrs, ` %s-opacity="%s"`, prop, num(alpha))
+	}
+}
+
+// patternID writes the definition of the specified pattern and returns its
+// identifier. Returns an empty string if the pattern does not cover any
+// part of the rendering area.
+func (dc *Context) patternID(pattern context.Pattern, bounds rect) string {
+	if gp, ok := pattern.(context.GradientPattern); ok {
+		if g, ok := gp.GradientDefinition(); ok {
+			return dc.writeGradient(g)
+		}
+	}
+
+	// Rasterize the pattern over the painted area.
+	r := bounds.pixels().Intersect(image.Rect(0, 0, dc.width, dc.height))
+	if r.Empty() {
+		return ""
+	}
+	img := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.Set(x, y, pattern.ColorAt(x, y))
+		}
+	}
+	uri, err := pngDataURI(img)
+	if err != nil {
+		return ""
+	}
+
+	id := dc.newID("p")
+	fmt.Fprintf(&dc.defs, `<pattern id="%s" patternUnits="userSpaceOnUse" x="%d" y="%d" width="%d" height="%d">`+
+		`<image x="%d" y="%d" width="%d" height="%d" xlink:href="%s"/></pattern>`+"\n",
+		id, r.Min.X, r.Min.Y, r.Dx(), r.Dy(), r.Min.X, r.Min.Y, r.Dx(), r.Dy(), uri)
+	return id
+}
+
+// writeGradient writes the definition of the specified gradient and returns
+// its identifier.
+func (dc *Context) writeGradient(g *context.GradientDefinition) string {
+	id := dc.newID("g")
+	if g.Radial {
+		fmt.Fprintf(&dc.defs, `<radialGradient id="%s" gradientUnits="userSpaceOnUse" `+
+			`cx="%s" cy="%s" r="%s" fx="%s" fy="%s" fr="%s" gradientTransform="%s">`,
+			id, num(g.X1), num(g.Y1), num(g.R1), num(g.X0), num(g.Y0), num(g.R0), matrixAttr(g.Matrix))
+	} else {
+		fmt.Fprintf(&dc.defs, `<linearGradient id="%s" gradientUnits="userSpaceOnUse" `+
+			`x1="%s" y1="%s" x2="%s" y2="%s" gradientTransform="%s">`,
+			id, num(g.X0), num(g.Y0), num(g.X1), num(g.Y1), matrixAttr(g.Matrix))
+	}
+	for _, stop := range g.Stops {
+		c := color.NRGBAModel.Convert(stop.Color).(color.NRGBA)
+		fmt.Fprintf(&dc.defs, `<stop offset="%s" stop-color="%s"`, num(stop.Offset), hexColor(c))
+		if c.A < 255 {
+			fmt.Fprintf(&dc.defs, ` stop-opacity="%s"`, num(float64(c.A)/255))
+		}
+		dc.defs.WriteString("/>")
+	}
+	if g.Radial {
+		dc.defs.WriteString("</radialGradient>\n")
+	} else {
+		dc.defs.WriteString("</linearGradient>\n")
+	}
+	return id
+}
+
+// pathData returns the data of the current path. Paths consisting of a
+// single point are given a zero length segment.
+func (dc *Context) pathData() string {
+	return dc.path.String()
+}
+
+// newID returns a new element identifier having the specified prefix.
+func (dc *Context) newID(prefix string) string {
+	dc.nextID++
+	return fmt.Sprintf("%s%d", prefix, dc.nextID)
+}
+
+// blendModeNames maps context blend modes to CSS blend modes.
+var blendModeNames = map[context.BlendMode]string{
+	context.BlendModeMultiply:   "multiply",
+	context.BlendModeScreen:     "screen",
+	context.BlendModeOverlay:    "overlay",
+	context.BlendModeDarken:     "darken",
+	context.BlendModeLighten:    "lighten",
+	context.BlendModeColorDodge: "color-dodge",
+	context.BlendModeColorBurn:  "color-burn",
+	context.BlendModeHardLight:  "hard-light",
+	context.BlendModeSoftLight:  "soft-light",
+	context.BlendModeDifference: "difference",
+	context.BlendModeExclusion:  "exclusion",
+	context.BlendModeHue:        "hue",
+	context.BlendModeSaturation: "saturation",
+	context.BlendModeColor:      "color",
+	context.BlendModeLuminosity: "luminosity",
+}
+
+// rect represents the bounds of a path, in device space.
+type rect struct {
+	minX, minY float64
+	maxX, maxY float64
+}
+
+func emptyRect() rect {
+	return rect{
+		minX: math.Inf(1), minY: math.Inf(1),
+		maxX: math.Inf(-1), maxY: math.Inf(-1),
+	}
+}
+
+func (r *rect) add(x, y float64) {
+	r.minX, r.maxX = math.Min(r.minX, x), math.Max(r.maxX, x)
+	r.minY, r.maxY = math.Min(r.minY, y), math.Max(r.maxY, y)
+}
+
+func (r *rect) expand(d float64) {
+	r.minX, r.minY = r.minX-d, r.minY-d
+	r.maxX, r.maxY = r.maxX+d, r.maxY+d
+}
+
+// pixels returns the smallest pixel rectangle containing the bounds.
+func (r rect) pixels() image.Rectangle {
+	if r.minX > r.maxX || r.minY > r.maxY {
+		return image.Rectangle{}
+	}
+	clamp := func(v float64) int {
+		return int(math.Max(math.Min(v, math.MaxInt32), math.MinInt32))
+	}
+	return image.Rect(clamp(math.Floor(r.minX)), clamp(math.Floor(r.minY)),
+		clamp(math.Ceil(r.maxX)), clamp(math.Ceil(r.maxY)))
+}
@@ -0,0 +1,151 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package svgrender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unitype"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+)
+
+// webFont represents a TrueType font program which is embedded in the SVG
+// document as a web font. Only the glyphs of the runes drawn using the font
+// are kept in the embedded font program.
+type webFont struct {
+	family string
+	data   []byte
+	runes  map[rune]struct{}
+}
+
+// webFont returns the web font created from the specified TrueType font
+// program. Returns nil if no font data is specified.
+func (dc *Context) webFont(data []byte) *webFont {
+	if len(data) == 0 {
+		return nil
+	}
+	if wf, ok := dc.fonts[&data[0]]; ok {
+		return wf
+	}
+	wf := &webFont{
+		family: fmt.Sprintf("f%d", len(dc.fontList)+1),
+		data:   data,
+		runes:  map[rune]struct{}{},
+	}
+	dc.fonts[&data[0]] = wf
+	dc.fontList = append(dc.fontList, wf)
+	return wf
+}
+
+// fontStyles returns the CSS font face rules of the web fonts used by the
+// context.
+func (dc *Context) fontStyles() string {
+	var b strings.Builder
+	for _, wf := range dc.fontList {
+		fmt.Fprintf(&b, "@font-face { font-family: %s; src: url(data:font/ttf;base64,%s); }\n",
+			wf.family, base64.StdEncoding.EncodeToString(wf.subset()))
+	}
+	return b.String()
+}
+
+// subset returns the font program containing only the glyphs of the used
+// runes. The full font program is returned if it cannot be subset.
+func (wf *webFont) subset() []byte {
+	fnt, err := unitype.Parse(bytes.NewReader(wf.data))
+	if err != nil {
+		common.Log.Debug("ERROR: unable to parse web font: %v", err)
+		return wf.data
+	}
+	runes := make([]rune, 0, len(wf.runes))
+	for r := range wf.runes {
+		runes = append(runes, r)
+	}
+	sub, err := fnt.SubsetKeepRunes(runes)
+	if err != nil {
+		common.Log.Debug("ERROR: unable to subset web font: %v", err)
+		return wf.data
+	}
+	var buf bytes.Buffer
+	if err := sub.Write(&buf); err != nil {
+		common.Log.Debug("ERROR: unable to write web font: %v", err)
+		return wf.data
+	}
+	return buf.Bytes()
+}
+
+// pngDataURI returns a data URI containing the PNG encoding of the
+// specified image.
+func pngDataURI(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// num formats the specified number using at most 4 decimals.
+func num(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "0"
+	}
+	s := strconv.FormatFloat(v, 'f', 4, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// matrixAttr formats the specified matrix as an SVG transform.
+func matrixAttr(m transform.Matrix) string {
+	return fmt.Sprintf("matrix(%s %s %s %s %s %s)",
+		num(m[0]), num(m[1]), num(m[3]), num(m[4]), num(m[6]), num(m[7]))
+}
+
+// rgba returns the color having the specified components, which should be
+// in range 0-1.
+func rgba(r, g, b, a float64) color.NRGBA {
+	c := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	return color.NRGBA{R: c(r), G: c(g), B: c(b), A: c(a)}
+}
+
+// hexColor formats the RGB components of the specified color.
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// escape escapes the specified text for use as XML character data. Control
+// characters, which are not allowed in XML documents, are removed.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r < 0x20 && r != '\t' && r != '\n' && r != '\r', r == 0xfffe, r == 0xffff:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
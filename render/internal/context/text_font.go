@@ -10,10 +10,12 @@ import (
 
 	"github.com/golang/freetype/truetype"
 	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
 
 	"github.com/gnaoh1379/unipdf/core"
 	"github.com/gnaoh1379/unipdf/model"
 
+	"github.com/gnaoh1379/unipdf/internal/outline"
 	"github.com/gnaoh1379/unipdf/internal/textencoding"
 )
 
@@ -25,7 +27,32 @@ type TextFont struct {
 	Size float64
 
 	ttf      *truetype.Font
+	data     []byte
+	faceSize float64
 	origFont *model.PdfFont
+	glyphs   GlyphRenderer
+	outlines GlyphOutliner
+}
+
+// GlyphRenderer renders the glyphs of fonts which describe glyphs using
+// content streams, such as Type 3 fonts.
+type GlyphRenderer interface {
+	// RenderGlyph renders the glyph of the specified character code. The
+	// matrix of the context maps the text space of the glyph, scaled by the
+	// font size, to the device space.
+	RenderGlyph(ctx Context, code textencoding.CharCode) error
+
+	// GlyphAdvance returns the horizontal displacement of the glyph of the
+	// specified character code, in unscaled text space units.
+	GlyphAdvance(code textencoding.CharCode) (float64, bool)
+}
+
+// GlyphOutliner provides the glyph outlines of embedded font programs, such
+// as Type 1 and CFF fonts.
+type GlyphOutliner interface {
+	// GlyphOutline returns the outline of the glyph of the specified
+	// character code, expressed in unscaled text space units.
+	GlyphOutline(code textencoding.CharCode) (*outline.Glyph, bool)
 }
 
 // NewTextFont returns a new text font instance based on the specified PDF font
@@ -51,16 +78,37 @@ func NewTextFont(font *model.PdfFont, size float64) (*TextFont, error) {
 		return nil, err
 	}
 
-	if size <= 1 {
-		size = 10
+	faceSize := faceSize(size)
+	return &TextFont{
+		Font:     font,
+		Face:     truetype.NewFace(ttfFont, &truetype.Options{Size: faceSize}),
+		Size:     size,
+		ttf:      ttfFont,
+		data:     fontData,
+		faceSize: faceSize,
+	}, nil
+}
+
+// NewGlyphTextFont returns a new text font instance based on the specified
+// PDF font and font size, which renders glyphs using the specified glyph
+// renderer.
+func NewGlyphTextFont(font *model.PdfFont, size float64, glyphs GlyphRenderer) *TextFont {
+	return &TextFont{
+		Font:   font,
+		Size:   size,
+		glyphs: glyphs,
 	}
+}
 
+// NewOutlineTextFont returns a new text font instance based on the specified
+// PDF font and font size, which fills the glyph outlines provided by the
+// specified outliner.
+func NewOutlineTextFont(font *model.PdfFont, size float64, outlines GlyphOutliner) *TextFont {
 	return &TextFont{
-		Font: font,
-		Face: truetype.NewFace(ttfFont, &truetype.Options{Size: size}),
-		Size: size,
-		ttf:  ttfFont,
-	}, nil
+		Font:     font,
+		Size:     size,
+		outlines: outlines,
+	}
 }
 
 // NewTextFontFromPath returns a new text font instance based on the specified
@@ -77,19 +125,43 @@ func NewTextFontFromPath(filePath string, size float64) (*TextFont, error) {
 // WithSize returns a new text font instance based on the current text font,
 // with the specified font size.
 func (tf *TextFont) WithSize(size float64, originalFont *model.PdfFont) *TextFont {
-	if size <= 1 {
-		size = 10
+	if tf.glyphs != nil || tf.outlines != nil {
+		return &TextFont{
+			Font:     tf.Font,
+			Size:     size,
+			origFont: originalFont,
+			glyphs:   tf.glyphs,
+			outlines: tf.outlines,
+		}
 	}
-
+	faceSize := faceSize(size)
 	return &TextFont{
 		Font:     tf.Font,
-		Face:     truetype.NewFace(tf.ttf, &truetype.Options{Size: size}),
+		Face:     truetype.NewFace(tf.ttf, &truetype.Options{Size: faceSize}),
 		Size:     size,
 		ttf:      tf.ttf,
+		data:     tf.data,
+		faceSize: faceSize,
 		origFont: originalFont,
 	}
 }
 
+// TrueTypeData returns the TrueType font program of the font, if the glyphs
+// of the font are drawn using a font face.
+func (tf *TextFont) TrueTypeData() []byte {
+	return tf.data
+}
+
+// faceSize returns the size of the font face created for the specified font
+// size. Faces are not created for very small sizes, as their glyphs cannot
+// be rasterized accurately. The glyph outlines are used instead.
+func faceSize(size float64) float64 {
+	if size <= 1 {
+		return 10
+	}
+	return size
+}
+
 // BytesToCharcodes converts the specified byte data to character codes, using
 // the encapsulated PDF font instance.
 func (tf *TextFont) BytesToCharcodes(data []byte) []textencoding.CharCode {
@@ -137,3 +209,119 @@ func (tf *TextFont) GetRuneMetrics(r rune) (float64, float64, bool) {
 	metrics, ok := tf.origFont.GetRuneMetrics(r)
 	return metrics.Wx, metrics.Wy, ok && metrics.Wx != 0
 }
+
+// drawGlyph adds the outline of the glyph corresponding to the specified
+// character code to the current path of the context, using the glyph
+// outliner of the font if available, or the TrueType outline of the rune
+// otherwise. Returns false if the outline of the glyph is not available.
+func (tf *TextFont) drawGlyph(ctx Context, code textencoding.CharCode, r rune) bool {
+	if tf.outlines == nil {
+		return tf.drawOutline(ctx, r)
+	}
+
+	glyph, ok := tf.outlines.GlyphOutline(code)
+	if !ok {
+		return false
+	}
+	for _, seg := range glyph.Path {
+		p := seg.Points
+		switch seg.Type {
+		case outline.SegmentMoveTo:
+			ctx.MoveTo(p[0].X, p[0].Y)
+		case outline.SegmentLineTo:
+			ctx.LineTo(p[0].X, p[0].Y)
+		case outline.SegmentCubicTo:
+			ctx.CubicTo(p[0].X, p[0].Y, p[1].X, p[1].Y, p[2].X, p[2].Y)
+		case outline.SegmentClose:
+			ctx.ClosePath()
+		}
+	}
+	return true
+}
+
+// drawOutline adds the outline of the glyph corresponding to the specified
+// rune to the current path of the context. The outline is expressed in
+// glyph space units, in which the font size is 1. Returns false if the
+// outline of the glyph is not available.
+func (tf *TextFont) drawOutline(ctx Context, r rune) bool {
+	if tf.ttf == nil {
+		return false
+	}
+
+	upem := tf.ttf.FUnitsPerEm()
+	if upem <= 0 {
+		return false
+	}
+
+	var buf truetype.GlyphBuf
+	if err := buf.Load(tf.ttf, fixed.Int26_6(upem), tf.ttf.Index(r), font.HintingNone); err != nil {
+		return false
+	}
+
+	scale := 1 / float64(upem)
+	toPoint := func(p truetype.Point) (float64, float64) {
+		return float64(p.X) * scale, float64(p.Y) * scale
+	}
+
+	// TrueType contours consist of quadratic curves. Two consecutive off
+	// curve points imply an on curve point located between them.
+	start := 0
+	for _, end := range buf.Ends {
+		points := buf.Points[start:end]
+		start = end
+		if len(points) == 0 {
+			continue
+		}
+
+		// Find an on curve point to start the contour from.
+		first := -1
+		for i, p := range points {
+			if p.Flags&0x01 != 0 {
+				first = i
+				break
+			}
+		}
+
+		var x0, y0 float64
+		if first >= 0 {
+			x0, y0 = toPoint(points[first])
+		} else {
+			// All points are off curve. Start from the midpoint of the
+			// last and first points.
+			x1, y1 := toPoint(points[0])
+			x2, y2 := toPoint(points[len(points)-1])
+			x0, y0 = (x1+x2)/2, (y1+y2)/2
+			first = len(points) - 1
+		}
+		ctx.MoveTo(x0, y0)
+
+		var cx, cy float64
+		hasControl := false
+		for i := 1; i <= len(points); i++ {
+			p := points[(first+i)%len(points)]
+			x, y := toPoint(p)
+			if p.Flags&0x01 != 0 {
+				if hasControl {
+					ctx.QuadraticTo(cx, cy, x, y)
+				} else {
+					ctx.LineTo(x, y)
+				}
+				hasControl = false
+				continue
+			}
+
+			if hasControl {
+				mx, my := (cx+x)/2, (cy+y)/2
+				ctx.QuadraticTo(cx, cy, mx, my)
+			}
+			cx, cy = x, y
+			hasControl = true
+		}
+		if hasControl {
+			ctx.QuadraticTo(cx, cy, x0, y0)
+		}
+		ctx.ClosePath()
+	}
+
+	return true
+}
@@ -6,6 +6,10 @@
 package context
 
 import (
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
 	"github.com/gnaoh1379/unipdf/internal/transform"
 )
 
@@ -22,6 +26,21 @@ type TextState struct {
 	Ts  float64          // Text rise.
 	Tm  transform.Matrix // Text matrix.
 	Tlm transform.Matrix // Text line matrix.
+
+	Tr TextRenderingMode // Text rendering mode.
+
+	// Glyphs added to the clipping path of the current text object.
+	clip []textClipGlyph
+}
+
+// textClipGlyph represents a glyph shown using one of the clipping text
+// rendering modes, along with the matrix which maps the glyph outline to
+// the device space.
+type textClipGlyph struct {
+	font   *TextFont
+	code   textencoding.CharCode
+	r      rune
+	matrix transform.Matrix
 }
 
 // NewTextState returns a new TextState instance.
@@ -38,7 +57,7 @@ func NewTextState() *TextState {
 // See section 9.4.2 "Text Positioning Operators" and
 // Table 108 (pp. 257-258 PDF32000_2008).
 func (ts *TextState) ProcTm(a, b, c, d, e, f float64) {
-	ts.Tm = transform.NewMatrix(a, b, c, d, e, -f)
+	ts.Tm = transform.NewMatrix(a, b, c, d, e, f)
 	ts.Tlm = ts.Tm.Clone()
 }
 
@@ -48,7 +67,7 @@ func (ts *TextState) ProcTm(a, b, c, d, e, f float64) {
 // See section 9.4.2 "Text Positioning Operators" and
 // Table 108 (pp. 257-258 PDF32000_2008).
 func (ts *TextState) ProcTd(tx, ty float64) {
-	ts.Tlm.Concat(transform.TranslationMatrix(tx, -ty))
+	ts.Tlm.Concat(transform.TranslationMatrix(tx, ty))
 	ts.Tm = ts.Tlm.Clone()
 }
 
@@ -79,43 +98,132 @@ func (ts *TextState) ProcTj(data []byte, ctx Context) {
 	tfs := ts.Tf.Size
 	th := ts.Th / 100.0
 	stateMatrix := transform.NewMatrix(tfs*th, 0, 0, tfs, 0, ts.Ts)
+	ctm := ctx.Matrix()
 
-	runes := ts.Tf.CharcodesToUnicode(ts.Tf.BytesToCharcodes(data))
-	for _, r := range runes {
-		if r == '\x00' {
-			continue
-		}
+	// Word spacing is applied to the single byte character code 32 only.
+	charcodes := ts.Tf.BytesToCharcodes(data)
+	singleByte := len(charcodes) == len(data)
 
+	for _, code := range charcodes {
 		// Calculate text rendering matrix.
-		tm := ts.Tm.Clone()
-		ts.Tm.Concat(stateMatrix)
+		trm := ts.Tm.Mult(stateMatrix)
 
-		// Draw rune.
-		x, y := ts.Tm.Transform(0, 0)
-		ctx.Scale(1, -1)
-		ctx.DrawString(string(r), x, y)
-		ctx.Scale(1, -1)
+		// Draw glyph.
+		ctx.SetMatrix(ctm.Mult(trm))
+		w := ts.showGlyph(ctx, code)
+		ctx.SetMatrix(ctm)
 
 		// Calculate word spacing.
 		tw := 0.0
-		if r == ' ' {
+		if singleByte && code == 32 {
 			tw = ts.Tw
 		}
 
+		// Calculate displacement offset.
+		tx := (w*tfs + ts.Tc + tw) * th
+
+		// Generate new text matrix.
+		ts.Tm = ts.Tm.Mult(transform.TranslationMatrix(tx, 0))
+	}
+}
+
+// showGlyph draws the glyph of the specified character code, according to
+// the current text rendering mode. The matrix of the context must map the
+// glyph space, scaled by the font size, to the device space. Returns the
+// horizontal displacement of the glyph, in unscaled text space units.
+func (ts *TextState) showGlyph(ctx Context, code textencoding.CharCode) float64 {
+	tf := ts.Tf
+	mode := ts.Tr
+
+	// Glyphs described by content streams are painted by executing their
+	// content streams. These glyphs cannot be stroked or used for clipping.
+	if tf.glyphs != nil {
+		if mode != TextRenderingModeInvisible && mode != TextRenderingModeClip {
+			if err := tf.glyphs.RenderGlyph(ctx, code); err != nil {
+				common.Log.Debug("ERROR: could not render glyph %d: %v", code, err)
+			}
+		}
+		w, _ := tf.glyphs.GlyphAdvance(code)
+		return w
+	}
+
+	var r rune
+	var w float64
+	if tf.outlines != nil {
+		// Glyphs of embedded font programs are selected by character code,
+		// regardless of their Unicode mapping.
+		if wX, _, ok := tf.GetCharMetrics(code); ok {
+			w = wX * 0.001
+		} else if glyph, ok := tf.outlines.GlyphOutline(code); ok {
+			w = glyph.Advance
+		}
+	} else {
+		if runes := tf.CharcodesToUnicode([]textencoding.CharCode{code}); len(runes) > 0 {
+			r = runes[0]
+		}
+
 		// Calculate rune spacing.
-		var w float64
-		if wX, _, ok := ts.Tf.GetRuneMetrics(r); ok {
-			w = wX * 0.001 * tfs
-		} else {
-			w, _ = ctx.MeasureString(string(r))
+		if wX, _, ok := tf.GetRuneMetrics(r); ok {
+			w = wX * 0.001
+		} else if r != 0 && tf.faceSize > 0 {
+			mw, _ := ctx.MeasureString(string(r))
+			w = mw / tf.faceSize
+		}
+		if r == 0 {
+			return w
+		}
+	}
+
+	switch {
+	case mode == TextRenderingModeInvisible:
+	case mode == TextRenderingModeFill && tf.outlines == nil:
+		ts.drawString(ctx, r)
+	default:
+		if tf.drawGlyph(ctx, code, r) {
+			if mode.IsFill() {
+				ctx.FillPreserve()
+			}
+			if mode.IsStroke() {
+				ctx.StrokePreserve()
+			}
+			ctx.ClearPath()
+		} else if mode.IsFill() && tf.outlines == nil {
+			ts.drawString(ctx, r)
 		}
 
-		// Calculate displacement offset.
-		tx := (w + ts.Tc + tw) * th
+		if mode.IsClip() {
+			ts.clip = append(ts.clip, textClipGlyph{font: tf, code: code, r: r, matrix: ctx.Matrix()})
+		}
+	}
 
-		// Generate new text matrix.
-		ts.Tm = transform.TranslationMatrix(tx, 0).Mult(tm)
+	return w
+}
+
+// drawString fills the glyph of the specified rune. Glyphs are rasterized
+// using the face of the current font and then transformed using the text
+// rendering matrix. If the size of the glyph in device space differs from
+// the face size, the glyph outline is filled instead. Vector contexts draw
+// the glyphs at any size.
+func (ts *TextState) drawString(ctx Context, r rune) {
+	size := ts.Tf.Size
+	if size <= 0 {
+		return
+	}
+
+	m := ctx.Matrix()
+	if _, ok := ctx.(VectorContext); !ok {
+		emSize := math.Sqrt(math.Abs(m[0]*m[4] - m[1]*m[3]))
+		if math.Abs(emSize-size) > 0.5 || ts.Tf.faceSize != size {
+			if ts.Tf.drawOutline(ctx, r) {
+				ctx.Fill()
+				return
+			}
+		}
 	}
+
+	ctx.SetMatrix(m.Mult(transform.ScaleMatrix(1/size, -1/size)))
+	ctx.DrawString(string(r), 0, 0)
+	ctx.SetMatrix(m)
 }
 
 // ProcQ processes a `'` operation, which advances the text state to a new line
@@ -148,13 +256,44 @@ func (ts *TextState) ProcTf(font *TextFont) {
 	ts.Tf = font
 }
 
-// Translate translates the current text matrix with `tx`,`ty`.
+// ProcTr processes a `Tr` operation which sets the text rendering mode.
+//
+// See section 9.3.6 "Text Rendering Mode" and
+// Table 106 (pp. 254-255 PDF32000_2008).
+func (ts *TextState) ProcTr(mode TextRenderingMode) {
+	ts.Tr = mode
+}
+
+// ProcET processes an `ET` operation, which ends the current text object.
+// If glyphs have been shown using one of the clipping text rendering modes,
+// their outlines are intersected with the clipping region of the context.
+//
+// See section 9.3.6 "Text Rendering Mode" (pp. 254-255 PDF32000_2008).
+func (ts *TextState) ProcET(ctx Context) {
+	if len(ts.clip) > 0 {
+		m := ctx.Matrix()
+		ctx.ClearPath()
+		for _, glyph := range ts.clip {
+			ctx.SetMatrix(glyph.matrix)
+			glyph.font.drawGlyph(ctx, glyph.code, glyph.r)
+		}
+		ctx.SetMatrix(m)
+		ctx.Clip()
+	}
+
+	ts.Reset()
+}
+
+// Translate translates the current text matrix with `tx`,`ty`, expressed in
+// text space units.
 func (ts *TextState) Translate(tx, ty float64) {
-	ts.Tm = transform.TranslationMatrix(tx, ty).Mult(ts.Tm)
+	ts.Tm = ts.Tm.Mult(transform.TranslationMatrix(tx, ty))
 }
 
-// Reset resets both the text matrix and the line matrix.
+// Reset resets both the text matrix and the line matrix, and discards the
+// clipping path of the current text object.
 func (ts *TextState) Reset() {
 	ts.Tm = transform.IdentityMatrix()
 	ts.Tlm = transform.IdentityMatrix()
+	ts.clip = nil
 }
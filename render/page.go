@@ -0,0 +1,182 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+
+	"github.com/gnaoh1379/unipdf/internal/transform"
+)
+
+// PageBox identifies the page boundary which defines the rendered area of a
+// page (section 14.11.2 Page Boundaries).
+type PageBox int
+
+// Page boundaries.
+const (
+	// CropBox is the region to which the page contents are clipped when
+	// displayed or printed. Defaults to the MediaBox if not specified.
+	CropBox PageBox = iota
+
+	// MediaBox is the boundary of the physical medium on which the page is
+	// intended to be displayed or printed.
+	MediaBox
+
+	// BleedBox is the region to which the page contents are clipped when
+	// output in a production environment. Defaults to the CropBox if not
+	// specified.
+	BleedBox
+
+	// TrimBox represents the intended dimensions of the finished page
+	// after trimming. Defaults to the CropBox if not specified.
+	TrimBox
+
+	// ArtBox represents the extent of the meaningful content of the page.
+	// Defaults to the CropBox if not specified.
+	ArtBox
+)
+
+// pageBox returns the specified boundary of the page, clipped to the media
+// box of the page.
+func pageBox(page *model.PdfPage, box PageBox) (*model.PdfRectangle, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	mbox = normalizeRect(mbox)
+	if box == MediaBox {
+		return mbox, nil
+	}
+
+	var rect *model.PdfRectangle
+	switch box {
+	case BleedBox:
+		rect = page.BleedBox
+	case TrimBox:
+		rect = page.TrimBox
+	case ArtBox:
+		rect = page.ArtBox
+	}
+	if rect == nil {
+		if rect, err = pageCropBox(page); err != nil {
+			return nil, err
+		}
+	}
+	if rect == nil {
+		return mbox, nil
+	}
+
+	// Clip the boundary to the media box.
+	rect = normalizeRect(rect)
+	clipped := &model.PdfRectangle{
+		Llx: math.Max(rect.Llx, mbox.Llx),
+		Lly: math.Max(rect.Lly, mbox.Lly),
+		Urx: math.Min(rect.Urx, mbox.Urx),
+		Ury: math.Min(rect.Ury, mbox.Ury),
+	}
+	if clipped.Llx >= clipped.Urx || clipped.Lly >= clipped.Ury {
+		return mbox, nil
+	}
+	return clipped, nil
+}
+
+// pageCropBox returns the crop box of the page, which may be inherited from
+// the ancestors of the page. Returns nil if the page has no crop box.
+func pageCropBox(page *model.PdfPage) (*model.PdfRectangle, error) {
+	if page.CropBox != nil {
+		return page.CropBox, nil
+	}
+
+	arr, ok := core.GetArray(inheritedPageAttribute(page, "CropBox"))
+	if !ok {
+		return nil, nil
+	}
+	return model.NewPdfRectangle(*arr)
+}
+
+// pageRotation returns the number of degrees by which the page is rotated
+// clockwise when displayed, which may be inherited from the ancestors of the
+// page. The returned value is one of 0, 90, 180 and 270.
+func pageRotation(page *model.PdfPage) int {
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = *page.Rotate
+	} else if val, ok := core.GetIntVal(inheritedPageAttribute(page, "Rotate")); ok {
+		rotate = int64(val)
+	}
+
+	rotate %= 360
+	if rotate < 0 {
+		rotate += 360
+	}
+	if rotate%90 != 0 {
+		return 0
+	}
+	return int(rotate)
+}
+
+// inheritedPageAttribute returns the value of the specified attribute from
+// the closest ancestor of the page which defines it.
+func inheritedPageAttribute(page *model.PdfPage, name core.PdfObjectName) core.PdfObject {
+	node := page.Parent
+	for depth := 0; node != nil && depth < 1000; depth++ {
+		dict, ok := core.GetDict(node)
+		if !ok {
+			return nil
+		}
+		if obj := core.ResolveReference(dict.Get(name)); obj != nil {
+			return obj
+		}
+		node = dict.Get("Parent")
+	}
+	return nil
+}
+
+// pageMatrix returns the matrix which maps the default user space of a page
+// to a device space whose origin is the top left corner of the rendered area
+// and whose y axis points downwards. The rendered area is the specified box,
+// rotated clockwise by the specified number of degrees and scaled by sx, sy.
+func pageMatrix(box *model.PdfRectangle, rotation int, sx, sy float64) transform.Matrix {
+	w, h := box.Width(), box.Height()
+
+	var rm transform.Matrix
+	switch rotation {
+	case 90:
+		rm = transform.NewMatrix(0, -1, 1, 0, 0, w)
+	case 180:
+		rm = transform.NewMatrix(-1, 0, 0, -1, w, h)
+	case 270:
+		rm = transform.NewMatrix(0, 1, -1, 0, h, 0)
+	default:
+		rm = transform.IdentityMatrix()
+	}
+	if rotation == 90 || rotation == 270 {
+		h = w
+	}
+
+	m := transform.NewMatrix(sx, 0, 0, -sy, 0, h*sy)
+	m = m.Mult(rm)
+	return m.Mult(transform.TranslationMatrix(-box.Llx, -box.Lly))
+}
+
+// normalizeRect returns a copy of the specified rectangle, having its lower
+// left corner before its upper right corner.
+func normalizeRect(rect *model.PdfRectangle) *model.PdfRectangle {
+	return &model.PdfRectangle{
+		Llx: math.Min(rect.Llx, rect.Urx),
+		Lly: math.Min(rect.Lly, rect.Ury),
+		Urx: math.Max(rect.Llx, rect.Urx),
+		Ury: math.Max(rect.Lly, rect.Ury),
+	}
+}
+
+// errInvalidRegion is returned when the region of the page which has to be
+// rendered does not intersect the rendered area of the page.
+var errInvalidRegion = errors.New("region outside of the rendered page area")
@@ -7,6 +7,8 @@ package render
 
 import (
 	"errors"
+	"image"
+	"image/color"
 
 	"github.com/adrg/sysfont"
 
@@ -25,48 +27,116 @@ var (
 )
 
 type renderer struct {
+	// plate is the separation being rendered. If nil, pages are rendered
+	// in composite mode, converting colors to RGB.
+	plate *inkPlate
+
+	// keepFill and keepStroke are set while rendering the glyph descriptions
+	// of Type 3 fonts, which paint with the colors of the text until they
+	// set their own colors.
+	keepFill   bool
+	keepStroke bool
 }
 
-func (r renderer) renderPage(ctx context.Context, page *model.PdfPage) error {
+// renderPage renders the content of the specified page. The matrix maps
+// the default user space of the page to the device space of the context.
+// If the background color is not nil, the rendering area is filled with it
+// before rendering the page content.
+func (r renderer) renderPage(ctx context.Context, page *model.PdfPage, m transform.Matrix,
+	background color.Color) error {
 	contents, err := page.GetAllContentStreams()
 	if err != nil {
 		return err
 	}
 
-	// Change coordinate system.
-	ctx.Translate(0, float64(ctx.Height()))
-	ctx.Scale(1, -1)
+	// Create background.
+	if background != nil {
+		cr, cg, cb, ca := background.RGBA()
+		ctx.Push()
+		if ca > 0 {
+			ctx.SetRGBA(float64(cr)/float64(ca), float64(cg)/float64(ca), float64(cb)/float64(ca),
+				float64(ca)/0xffff)
+		} else {
+			ctx.SetRGBA(0, 0, 0, 0)
+		}
+		ctx.DrawRectangle(0, 0, float64(ctx.Width()), float64(ctx.Height()))
+		ctx.Fill()
+		ctx.Pop()
+	}
 
-	// Create white background.
-	ctx.Push()
-	ctx.SetRGBA(1, 1, 1, 1)
-	ctx.DrawRectangle(0, 0, float64(ctx.Width()), float64(ctx.Height()))
-	ctx.Fill()
-	ctx.Pop()
+	// Change coordinate system.
+	ctx.SetMatrix(m)
 
 	// Set defaults.
-	ctx.SetLineWidth(1.0)
+	ctx.SetLineWidth(lineWidthScale(m))
 	ctx.SetRGBA(0, 0, 0, 1)
 
+	// The graphics state is restored after rendering the content stream, so
+	// that annotations can be rendered relative to the default page space.
+	ctx.Push()
+	defer ctx.Pop()
 	return r.renderContentStream(ctx, contents, page.Resources)
 }
 
+// lineWidthScale returns the factor used to convert line widths and dash
+// lengths from the user space defined by the specified matrix to the device
+// space.
+// TODO: Take angle into account for line widths (8.4.3.2 Line Width).
+func lineWidthScale(m transform.Matrix) float64 {
+	return (m.ScalingFactorX() + m.ScalingFactorY()) / 2.0
+}
+
 func (r renderer) renderContentStream(ctx context.Context, contents string, resources *model.PdfPageResources) error {
 	operations, err := contentstream.NewContentStreamParser(contents).Parse()
 	if err != nil {
 		return err
 	}
 
+	// Shading patterns are defined relative to the coordinate space of the
+	// page or form which contains the content stream.
+	baseMatrix := ctx.Matrix()
+
 	textState := ctx.TextState()
 	fontCache := map[string]*context.TextFont{}
+	embeddedFonts := map[*core.PdfObjectDictionary]*context.TextFont{}
 	fontFinder := sysfont.NewFinder(&sysfont.FinderOpts{
 		Extensions: []string{".ttf", ".ttc"},
 	})
 
+	// The state of the plate is restored after rendering the content stream,
+	// as content streams are rendered inside a saved graphics state.
+	var plateStack []plateState
+	if r.plate != nil {
+		state := r.plate.state
+		defer func() { r.plate.state = state }()
+	}
+
+	// The color operators of uncolored Type 3 glyphs (d1) are ignored.
+	var uncolored bool
+
 	processor := contentstream.NewContentStreamProcessor(*operations)
 	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
 		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
 			common.Log.Debug("Processing %s", op.Operand)
+			if r.keepFill || r.keepStroke || uncolored {
+				switch op.Operand {
+				case "d1":
+					uncolored = true
+				case "g", "rg", "k", "cs", "sc", "scn":
+					if uncolored {
+						return nil
+					}
+					r.keepFill = false
+				case "G", "RG", "K", "CS", "SC", "SCN":
+					if uncolored {
+						return nil
+					}
+					r.keepStroke = false
+				}
+			}
+			if r.plate != nil {
+				r.processPlateOperation(ctx, op, gs, &plateStack)
+			}
 			switch op.Operand {
 			//
 			// Graphics stage operators
@@ -92,10 +162,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				m := transform.NewMatrix(fv[0], fv[1], fv[2], fv[3], fv[4], fv[5])
 				common.Log.Debug("Graphics state matrix: %+v", m)
 				ctx.SetMatrix(ctx.Matrix().Mult(m))
-
-				// TODO: Take angle into account for line widths (8.4.3.2 Line Width).
-				s := (gs.CTM.ScalingFactorX() + gs.CTM.ScalingFactorY()) / 2.0
-				ctx.SetLineWidth(s * ctx.LineWidth())
+				ctx.SetLineWidth(lineWidthScale(m) * ctx.LineWidth())
 			// Set line width.
 			case "w":
 				if len(op.Params) != 1 {
@@ -107,9 +174,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					return err
 				}
 
-				// TODO: Take angle into account for line widths (8.4.3.2 Line Width).
-				s := (gs.CTM.ScalingFactorX() + gs.CTM.ScalingFactorY()) / 2.0
-				ctx.SetLineWidth(s * fw[0])
+				ctx.SetLineWidth(lineWidthScale(ctx.Matrix()) * fw[0])
 			// Set line cap style.
 			case "J":
 				if len(op.Params) != 1 {
@@ -195,6 +260,10 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				if err != nil {
 					return err
 				}
+				s := lineWidthScale(ctx.Matrix())
+				for i := range dashes {
+					dashes[i] *= s
+				}
 				ctx.SetDash(dashes...)
 
 				// TODO: Add support for dash phase in context.
@@ -236,6 +305,40 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 				common.Log.Debug("GS dict: %s", extdict.String())
 
+				if r.plate != nil {
+					r.plate.setOverprint(extdict)
+				}
+
+				// Set transparency parameters.
+				if obj := extdict.Get("CA"); obj != nil {
+					if alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(obj)); err == nil {
+						ctx.SetStrokeAlpha(alpha)
+					}
+				}
+				if obj := extdict.Get("ca"); obj != nil {
+					if alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(obj)); err == nil {
+						ctx.SetFillAlpha(alpha)
+					}
+				}
+				if obj := extdict.Get("BM"); obj != nil {
+					if mode, ok := blendModeFromPdfObject(obj); ok {
+						ctx.SetBlendMode(mode)
+					} else {
+						common.Log.Debug("Unsupported blend mode: %v", obj)
+					}
+				}
+				if obj := extdict.Get("SMask"); obj != nil {
+					if name, ok := core.GetName(obj); ok && *name == "None" {
+						ctx.SetSoftMask(nil)
+					} else if smask, ok := core.GetDict(obj); ok {
+						mask, err := r.renderSoftMask(ctx, smask, resources)
+						if err != nil {
+							common.Log.Debug("ERROR: could not render soft mask: %v", err)
+						}
+						ctx.SetSoftMask(mask)
+					}
+				}
+
 			//
 			// Path operators
 			//
@@ -318,174 +421,56 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 			// Set path stroke.
 			case "S":
-				color, err := gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
+				if err := r.setStrokeStyle(ctx, gs, resources, baseMatrix); err != nil {
+					common.Log.Debug("Error setting stroke style: %v", err)
+					ctx.ClearPath()
+					return nil
 				}
-
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
 				ctx.Stroke()
 			// Close and stroke.
 			case "s":
-				color, err := gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
-				}
-
 				ctx.ClosePath()
 				ctx.NewSubPath()
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
+				if err := r.setStrokeStyle(ctx, gs, resources, baseMatrix); err != nil {
+					common.Log.Debug("Error setting stroke style: %v", err)
+					ctx.ClearPath()
+					return nil
+				}
 				ctx.Stroke()
 			// Fill path using non-zero winding number rule.
 			case "f", "F":
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
+				if err := r.setFillStyle(ctx, gs, resources, baseMatrix); err != nil {
+					common.Log.Debug("Error setting fill style: %v", err)
+					ctx.ClearPath()
+					return nil
 				}
-
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
 				ctx.SetFillRule(context.FillRuleWinding)
 				ctx.Fill()
 			// Fill path using even-odd rule.
 			case "f*":
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor, ok := color.(*model.PdfColorDeviceRGB)
-				if !ok {
-					common.Log.Debug("Error converting color")
-					return err
+				if err := r.setFillStyle(ctx, gs, resources, baseMatrix); err != nil {
+					common.Log.Debug("Error setting fill style: %v", err)
+					ctx.ClearPath()
+					return nil
 				}
-
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
 				ctx.SetFillRule(context.FillRuleEvenOdd)
 				ctx.Fill()
 			// Fill then stroke the path using non-zero winding rule.
 			case "B":
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.SetFillRule(context.FillRuleWinding)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				r.fillStroke(ctx, gs, resources, baseMatrix, context.FillRuleWinding)
 			// Fill then stroke the path using even-odd rule.
 			case "B*":
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.SetFillRule(context.FillRuleEvenOdd)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				r.fillStroke(ctx, gs, resources, baseMatrix, context.FillRuleEvenOdd)
 			// Close, fill and stroke the path using non-zero winding rule.
 			case "b":
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
 				ctx.ClosePath()
 				ctx.NewSubPath()
-				ctx.SetFillRule(context.FillRuleWinding)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				r.fillStroke(ctx, gs, resources, baseMatrix, context.FillRuleWinding)
 			// Close, fill and stroke the path using even-odd rule.
 			case "b*":
-				// Close current subpath.
 				ctx.ClosePath()
-
-				// Fill path.
-				color, err := gs.ColorspaceNonStroking.ColorToRGB(gs.ColorNonStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor := color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
 				ctx.NewSubPath()
-				ctx.SetFillRule(context.FillRuleEvenOdd)
-				ctx.FillPreserve()
-
-				// Stroke path.
-				color, err = gs.ColorspaceStroking.ColorToRGB(gs.ColorStroking)
-				if err != nil {
-					common.Log.Debug("Error converting color: %v", err)
-					return err
-				}
-
-				rgbColor = color.(*model.PdfColorDeviceRGB)
-				ctx.SetRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
-				ctx.Stroke()
+				r.fillStroke(ctx, gs, resources, baseMatrix, context.FillRuleEvenOdd)
 			// End the current path without filling or stroking.
 			case "n":
 				ctx.ClearPath()
@@ -626,6 +611,49 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 				ctx.SetStrokeRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
 
+			//
+			// Shading operators
+			//
+
+			// Paint the shape and color shading described by a shading
+			// dictionary, subject to the current clipping path.
+			case "sh":
+				if len(op.Params) != 1 {
+					return errRange
+				}
+
+				name, ok := core.GetName(op.Params[0])
+				if !ok {
+					return errType
+				}
+				if resources == nil {
+					common.Log.Debug("ERROR: missing resources for shading: %s", name.String())
+					return nil
+				}
+
+				shading, ok := resources.GetShadingByName(*name)
+				if !ok {
+					common.Log.Debug("ERROR: could not find shading: %s", name.String())
+					return nil
+				}
+
+				if r.plate != nil && r.plate.isOverprinted(shading.ColorSpace, nil, nil, r.plate.state.overprint.fill) {
+					return nil
+				}
+				pattern, err := newShadingPattern(shading, ctx.Matrix(), ctx.Width(), ctx.Height(), false, r.plate)
+				if err != nil {
+					common.Log.Debug("ERROR: could not render shading %s: %v", name.String(), err)
+					return nil
+				}
+
+				ctx.Push()
+				ctx.SetMatrix(transform.IdentityMatrix())
+				ctx.SetFillStyle(pattern)
+				ctx.SetFillRule(context.FillRuleWinding)
+				ctx.DrawRectangle(0, 0, float64(ctx.Width()), float64(ctx.Height()))
+				ctx.Fill()
+				ctx.Pop()
+
 			//
 			// Image operators
 			//
@@ -656,13 +684,24 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 						return err
 					}
 
-					goImg, err := img.ToGoImage()
-					if err != nil {
+					var goImg image.Image
+					if r.plate != nil {
+						if goImg, ok = r.plateImage(img, ximg.ColorSpace, ximg.Decode); !ok {
+							return nil
+						}
+					} else if goImg, err = img.ToGoImage(); err != nil {
 						return err
 					}
+
+					// Apply the soft mask or the mask of the image.
+					alpha, err := imageAlpha(ximg, img)
+					if err != nil {
+						common.Log.Debug("ERROR: could not load image mask: %v", err)
+					} else if alpha != nil {
+						goImg = applyImageAlpha(goImg, alpha)
+					}
 					bounds := goImg.Bounds()
 
-					// TODO: Handle soft masks.
 					ctx.Push()
 					ctx.Scale(1.0/float64(bounds.Dx()), -1.0/float64(bounds.Dy()))
 					ctx.DrawImageAnchored(goImg, 0, 0, 0, 1)
@@ -676,64 +715,9 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 						return err
 					}
 
-					formContent, err := xform.GetContentStream()
-					if err != nil {
+					if err := r.renderForm(ctx, xform, resources); err != nil {
 						return err
 					}
-
-					formResources := xform.Resources
-					if formResources == nil {
-						formResources = resources
-					}
-
-					ctx.Push()
-					if xform.Matrix != nil {
-						array, ok := core.GetArray(xform.Matrix)
-						if !ok {
-							return errType
-						}
-
-						mf, err := core.GetNumbersAsFloat(array.Elements())
-						if err != nil {
-							return err
-						}
-						if len(mf) != 6 {
-							return errRange
-						}
-
-						m := transform.NewMatrix(mf[0], mf[1], mf[2], mf[3], mf[4], mf[5])
-						ctx.SetMatrix(ctx.Matrix().Mult(m))
-					}
-
-					if xform.BBox != nil {
-						array, ok := core.GetArray(xform.BBox)
-						if !ok {
-							return errType
-						}
-
-						bf, err := core.GetNumbersAsFloat(array.Elements())
-						if err != nil {
-							return err
-						}
-						if len(bf) != 4 {
-							common.Log.Debug("Len = %d", len(bf))
-							return errRange
-						}
-
-						// Set clipping region.
-						ctx.DrawRectangle(bf[0], bf[1], bf[2]-bf[0], bf[3]-bf[1])
-						ctx.SetRGBA(1, 0, 0, 1)
-						ctx.Clip()
-					} else {
-						common.Log.Debug("ERROR: Required BBox missing on XObject Form")
-					}
-
-					// Process the content stream in the Form object.
-					err = r.renderContentStream(ctx, string(formContent), formResources)
-					if err != nil {
-						return err
-					}
-					ctx.Pop()
 				}
 			// Display inline image.
 			case "BI":
@@ -751,8 +735,16 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					return err
 				}
 
-				goImg, err := img.ToGoImage()
-				if err != nil {
+				var goImg image.Image
+				if r.plate != nil {
+					cs, err := iimg.GetColorSpace(resources)
+					if err != nil {
+						return err
+					}
+					if goImg, ok = r.plateImage(img, cs, iimg.Decode); !ok {
+						return nil
+					}
+				} else if goImg, err = img.ToGoImage(); err != nil {
 					return err
 				}
 				bounds := goImg.Bounds()
@@ -771,7 +763,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				textState.Reset()
 			// End text.
 			case "ET":
-				textState.Reset()
+				textState.ProcET(ctx)
 			// Set text leading.
 			case "TL":
 				if len(op.Params) != 1 {
@@ -820,6 +812,23 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 				}
 
 				textState.Th = th
+			// Set text rendering mode.
+			case "Tr":
+				if len(op.Params) != 1 {
+					return errRange
+				}
+
+				tr, ok := core.GetIntVal(op.Params[0])
+				if !ok {
+					return errType
+				}
+
+				mode := context.TextRenderingMode(tr)
+				if mode < context.TextRenderingModeFill || mode > context.TextRenderingModeClip {
+					return errRange
+				}
+
+				textState.ProcTr(mode)
 			// Set text rise.
 			case "Ts":
 				if len(op.Params) != 1 {
@@ -943,7 +952,7 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					case *core.PdfObjectFloat, *core.PdfObjectInteger:
 						val, err := core.GetNumberAsFloat(t)
 						if err == nil {
-							textState.Translate(-val*0.001*textState.Tf.Size, 0)
+							textState.Translate(-val*0.001*textState.Tf.Size*textState.Th/100, 0)
 						}
 					}
 				}
@@ -984,6 +993,24 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					return errType
 				}
 
+				// Type 3 glyphs are described by content streams, which are
+				// processed when the glyphs are shown.
+				if subtype, _ := core.GetNameVal(fontDict.Get("Subtype")); subtype == "Type3" {
+					pdfFont, _ := model.NewPdfFontFromPdfObject(fontDict)
+					if pdfFont == nil {
+						common.Log.Debug("ERROR: could not load Type 3 font from object")
+						return errType
+					}
+
+					type3, err := newType3Font(r, fontDict, resources)
+					if err != nil {
+						return err
+					}
+
+					textState.ProcTf(context.NewGlyphTextFont(pdfFont, fontSize, type3))
+					break
+				}
+
 				pdfFont, err := model.NewPdfFontFromPdfObject(fontDict)
 				if err != nil {
 					common.Log.Debug("ERROR: could not load font from object")
@@ -995,13 +1022,27 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 					baseFont = fontName.String()
 				}
 
-				textFont, ok := fontCache[baseFont]
+				textFont, ok := embeddedFonts[fontDict]
+				if !ok {
+					textFont, ok = fontCache[baseFont]
+				}
 				if !ok {
 					textFont, err = context.NewTextFont(pdfFont, fontSize)
 					if err != nil {
 						common.Log.Debug("ERROR: %v", err)
 					}
 				}
+				if textFont == nil {
+					// Embedded Type 1 and CFF font programs are rendered using
+					// their glyph outlines. System fonts are used as a last resort.
+					embedded, err := newEmbeddedFont(pdfFont, fontDict)
+					if err == nil {
+						textFont = context.NewOutlineTextFont(pdfFont, fontSize, embedded)
+						embeddedFonts[fontDict] = textFont
+					} else {
+						common.Log.Debug("could not load embedded font program: %v", err)
+					}
+				}
 
 				if textFont == nil {
 					// Treat cases such as: OPEIOA+ArialMT
@@ -1069,3 +1110,212 @@ func (r renderer) renderContentStream(ctx context.Context, contents string, reso
 
 	return nil
 }
+
+// setFillStyle sets the fill style of the context using the non-stroking
+// color of the graphics state.
+func (r renderer) setFillStyle(ctx context.Context, gs contentstream.GraphicsState,
+	resources *model.PdfPageResources, baseMatrix transform.Matrix) error {
+	if r.keepFill {
+		return nil
+	}
+	if _, ok := gs.ColorspaceNonStroking.(*model.PdfColorspaceSpecialPattern); ok {
+		pattern, err := r.patternStyle(ctx, gs.ColorNonStroking, resources, baseMatrix)
+		if err != nil {
+			return err
+		}
+
+		ctx.SetFillStyle(pattern)
+		return nil
+	}
+	if r.plate != nil {
+		return r.setPlateColor(ctx, gs, false)
+	}
+
+	rgbColor, err := toRGB(gs.ColorspaceNonStroking, gs.ColorNonStroking)
+	if err != nil {
+		return err
+	}
+
+	ctx.SetFillRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
+	return nil
+}
+
+// setStrokeStyle sets the stroke style of the context using the stroking
+// color of the graphics state.
+func (r renderer) setStrokeStyle(ctx context.Context, gs contentstream.GraphicsState,
+	resources *model.PdfPageResources, baseMatrix transform.Matrix) error {
+	if r.keepStroke {
+		return nil
+	}
+	if _, ok := gs.ColorspaceStroking.(*model.PdfColorspaceSpecialPattern); ok {
+		pattern, err := r.patternStyle(ctx, gs.ColorStroking, resources, baseMatrix)
+		if err != nil {
+			return err
+		}
+
+		ctx.SetStrokeStyle(pattern)
+		return nil
+	}
+	if r.plate != nil {
+		return r.setPlateColor(ctx, gs, true)
+	}
+
+	rgbColor, err := toRGB(gs.ColorspaceStroking, gs.ColorStroking)
+	if err != nil {
+		return err
+	}
+
+	ctx.SetStrokeRGBA(rgbColor.R(), rgbColor.G(), rgbColor.B(), 1)
+	return nil
+}
+
+// fillStroke fills the current path using the specified fill rule and then
+// strokes it. If the fill style cannot be set, the path is only stroked.
+func (r renderer) fillStroke(ctx context.Context, gs contentstream.GraphicsState,
+	resources *model.PdfPageResources, baseMatrix transform.Matrix, fillRule context.FillRule) {
+	if err := r.setFillStyle(ctx, gs, resources, baseMatrix); err != nil {
+		common.Log.Debug("Error setting fill style: %v", err)
+	} else {
+		ctx.SetFillRule(fillRule)
+		ctx.FillPreserve()
+	}
+
+	if err := r.setStrokeStyle(ctx, gs, resources, baseMatrix); err != nil {
+		common.Log.Debug("Error setting stroke style: %v", err)
+		ctx.ClearPath()
+		return
+	}
+	ctx.Stroke()
+}
+
+// patternStyle returns the context pattern corresponding to the specified
+// pattern color. Only shading patterns are currently supported. The pattern
+// matrix is applied relative to `baseMatrix`, which maps the default
+// coordinate space of the pattern's parent content stream to device space.
+func (r renderer) patternStyle(ctx context.Context, color model.PdfColor,
+	resources *model.PdfPageResources, baseMatrix transform.Matrix) (context.Pattern, error) {
+	patternColor, ok := color.(*model.PdfColorPattern)
+	if !ok {
+		return nil, errType
+	}
+	if resources == nil {
+		return nil, errors.New("missing pattern resources")
+	}
+
+	pattern, ok := resources.GetPatternByName(patternColor.PatternName)
+	if !ok {
+		return nil, errors.New("pattern not found")
+	}
+	if !pattern.IsShading() {
+		return nil, errors.New("unsupported pattern type")
+	}
+	shadingPattern := pattern.GetAsShadingPattern()
+
+	m := baseMatrix
+	if shadingPattern.Matrix != nil {
+		mf, err := core.GetNumbersAsFloat(shadingPattern.Matrix.Elements())
+		if err != nil {
+			return nil, err
+		}
+		if len(mf) != 6 {
+			return nil, errRange
+		}
+
+		m = m.Mult(transform.NewMatrix(mf[0], mf[1], mf[2], mf[3], mf[4], mf[5]))
+	}
+
+	return newShadingPattern(shadingPattern.Shading, m, ctx.Width(), ctx.Height(), true, r.plate)
+}
+
+// renderForm renders the content stream of the specified form XObject in the
+// coordinate space defined by the form. If the form does not have its own
+// resources, the specified resources are used instead.
+func (r renderer) renderForm(ctx context.Context, xform *model.XObjectForm, resources *model.PdfPageResources) error {
+	formContent, err := xform.GetContentStream()
+	if err != nil {
+		return err
+	}
+
+	formResources := xform.Resources
+	if formResources == nil {
+		formResources = resources
+	}
+
+	ctx.Push()
+	defer ctx.Pop()
+	if err := r.setFormSpace(ctx, xform); err != nil {
+		return err
+	}
+
+	// Transparency groups are painted on a separate layer which is
+	// composited with the backdrop afterwards.
+	isolated, knockout, isGroup := transparencyGroup(xform)
+	if isGroup {
+		ctx.BeginGroup(isolated, knockout)
+		defer ctx.EndGroup()
+	}
+
+	// Process the content stream in the Form object.
+	return r.renderContentStream(ctx, string(formContent), formResources)
+}
+
+// setFormSpace applies the matrix of the specified form XObject to the
+// current transformation matrix and clips the context to the bounding box
+// of the form.
+func (r renderer) setFormSpace(ctx context.Context, xform *model.XObjectForm) error {
+	if xform.Matrix != nil {
+		array, ok := core.GetArray(xform.Matrix)
+		if !ok {
+			return errType
+		}
+
+		mf, err := core.GetNumbersAsFloat(array.Elements())
+		if err != nil {
+			return err
+		}
+		if len(mf) != 6 {
+			return errRange
+		}
+
+		m := transform.NewMatrix(mf[0], mf[1], mf[2], mf[3], mf[4], mf[5])
+		ctx.SetMatrix(ctx.Matrix().Mult(m))
+	}
+
+	if xform.BBox == nil {
+		common.Log.Debug("ERROR: Required BBox missing on XObject Form")
+		return nil
+	}
+
+	array, ok := core.GetArray(xform.BBox)
+	if !ok {
+		return errType
+	}
+
+	bf, err := core.GetNumbersAsFloat(array.Elements())
+	if err != nil {
+		return err
+	}
+	if len(bf) != 4 {
+		common.Log.Debug("Len = %d", len(bf))
+		return errRange
+	}
+
+	// Set clipping region.
+	ctx.DrawRectangle(bf[0], bf[1], bf[2]-bf[0], bf[3]-bf[1])
+	ctx.Clip()
+	return nil
+}
+
+// toRGB converts the specified color to the DeviceRGB colorspace.
+func toRGB(cs model.PdfColorspace, color model.PdfColor) (*model.PdfColorDeviceRGB, error) {
+	rgb, err := cs.ColorToRGB(color)
+	if err != nil {
+		return nil, err
+	}
+
+	rgbColor, ok := rgb.(*model.PdfColorDeviceRGB)
+	if !ok {
+		return nil, errType
+	}
+	return rgbColor, nil
+}
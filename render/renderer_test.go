@@ -0,0 +1,57 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// newTestPage returns a page of size `width` x `height` drawn by content stream `contents`. The
+// resources of the page can be set on the returned page.
+func newTestPage(t *testing.T, width, height float64, contents string) *model.PdfPage {
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: width, Ury: height}
+	require.NoError(t, page.SetContentStreams([]string{contents}, core.NewRawEncoder()))
+	return page
+}
+
+// parseTestDict returns the PDF dictionary in `text`.
+func parseTestDict(t *testing.T, text string) *core.PdfObjectDictionary {
+	dict, err := core.NewParserFromString(text).ParseDict()
+	require.NoError(t, err)
+	return dict
+}
+
+// newTestStream returns a stream with the entries of dictionary `dict` and the contents
+// `contents`.
+func newTestStream(t *testing.T, dict, contents string) *core.PdfObjectStream {
+	stream, err := core.MakeStream([]byte(contents), nil)
+	require.NoError(t, err)
+	stream.Merge(parseTestDict(t, dict))
+	return stream
+}
+
+// rgbaAt returns the color of the pixel of `img` at (`x`, `y`).
+func rgbaAt(img image.Image, x, y int) color.RGBA {
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+}
+
+// requireColor checks that the pixel of `img` at (`x`, `y`) has color `expected`, within `delta`
+// for each component.
+func requireColor(t *testing.T, img image.Image, x, y int, expected color.RGBA, delta float64) {
+	c := rgbaAt(img, x, y)
+	require.InDelta(t, expected.R, c.R, delta, "R at (%d, %d): %v", x, y, c)
+	require.InDelta(t, expected.G, c.G, delta, "G at (%d, %d): %v", x, y, c)
+	require.InDelta(t, expected.B, c.B, delta, "B at (%d, %d): %v", x, y, c)
+	require.InDelta(t, expected.A, c.A, delta, "A at (%d, %d): %v", x, y, c)
+}
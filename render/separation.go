@@ -0,0 +1,549 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/contentstream"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// Names of the process inks.
+const (
+	InkCyan    = "Cyan"
+	InkMagenta = "Magenta"
+	InkYellow  = "Yellow"
+	InkBlack   = "Black"
+)
+
+// processInks contains the process inks, in the order in which their
+// separations are rendered.
+var processInks = []string{InkCyan, InkMagenta, InkYellow, InkBlack}
+
+// Separation represents the plate of a single ink, obtained by rendering a
+// page in separation mode.
+type Separation struct {
+	// Ink is the name of the ink of the separation. It is either one of
+	// the process inks (InkCyan, InkMagenta, InkYellow and InkBlack) or
+	// the colorant name of a spot color.
+	Ink string
+
+	// Image contains the amount of ink applied to each pixel of the page.
+	// Pixels having no ink are white and pixels fully covered by the ink
+	// are black.
+	Image *image.Gray
+
+	// Color is the approximate appearance of the ink at full tint.
+	Color color.RGBA
+}
+
+// processColors contains the approximate appearance of the process inks.
+var processColors = map[string]color.RGBA{
+	InkCyan:    {R: 0, G: 255, B: 255, A: 255},
+	InkMagenta: {R: 255, G: 0, B: 255, A: 255},
+	InkYellow:  {R: 255, G: 255, B: 0, A: 255},
+	InkBlack:   {R: 0, G: 0, B: 0, A: 255},
+}
+
+// spotInks holds the spot colorants found while rendering separations, in
+// the order in which they were encountered.
+type spotInks struct {
+	names  []string
+	colors map[string]color.RGBA
+}
+
+// add records the specified spot colorant, whose appearance at full tint is
+// computed using the specified function, if not already recorded.
+func (s *spotInks) add(name string, appearance func() (color.RGBA, error)) {
+	if _, ok := s.colors[name]; ok {
+		return
+	}
+	c, err := appearance()
+	if err != nil {
+		common.Log.Debug("ERROR: could not compute appearance of ink %s: %v", name, err)
+		c = color.RGBA{A: 255}
+	}
+	s.names = append(s.names, name)
+	s.colors[name] = c
+}
+
+// overprintState holds the overprint parameters of the graphics state
+// (section 8.6.7 Overprint Control).
+type overprintState struct {
+	stroke bool
+	fill   bool
+	mode   int
+}
+
+// plateState holds the parts of the graphics state which are only tracked
+// when rendering separations. The color components are the operands of the
+// color operators, which cannot be recovered from the graphics state colors
+// for Separation and DeviceN colorspaces.
+type plateState struct {
+	overprint   overprintState
+	strokeComps []float64
+	fillComps   []float64
+}
+
+// inkPlate represents the separation of a single ink being rendered. When
+// the renderer has a plate, colors are converted to shades of gray
+// representing the amount of ink of the plate they contain, and objects
+// which are overprinted are not painted on the plates of the inks they do
+// not contain.
+type inkPlate struct {
+	ink   string
+	spots *spotInks
+	state plateState
+}
+
+// plateUsage specifies how a colorspace marks the plate being rendered.
+type plateUsage int
+
+const (
+	// plateIncluded indicates that the ink of the plate is a component of
+	// the colorspace.
+	plateIncluded plateUsage = iota
+
+	// plateExcluded indicates that the ink of the plate is not a component
+	// of the colorspace. Painting knocks out the plate, unless overprint
+	// is enabled.
+	plateExcluded
+
+	// plateUnmarked indicates that painting does not mark any plate, as
+	// for the None colorant.
+	plateUnmarked
+)
+
+// isProcessInk returns true if the specified name is one of the process inks.
+func isProcessInk(name string) bool {
+	_, ok := processColors[name]
+	return ok
+}
+
+// usage returns how painting in the specified colorspace marks the plate.
+// The spot colorants of the colorspace are recorded.
+func (p *inkPlate) usage(cs model.PdfColorspace) plateUsage {
+	switch cs := cs.(type) {
+	case *model.PdfColorspaceSpecialSeparation:
+		if cs.ColorantName == nil {
+			return plateExcluded
+		}
+		name := cs.ColorantName.String()
+		switch {
+		case name == "None":
+			return plateUnmarked
+		case name == "All" || name == p.ink:
+			return plateIncluded
+		case !isProcessInk(name):
+			p.spots.add(name, func() (color.RGBA, error) {
+				return alternateAppearance(cs.AlternateSpace, cs, []float64{1})
+			})
+		}
+		return plateExcluded
+	case *model.PdfColorspaceDeviceN:
+		names := colorantNames(cs)
+		usage := plateUnmarked
+		for i, name := range names {
+			switch {
+			case name == "None":
+				continue
+			case name == p.ink:
+				return plateIncluded
+			case !isProcessInk(name):
+				vals := make([]float64, len(names))
+				vals[i] = 1
+				p.spots.add(name, func() (color.RGBA, error) {
+					return alternateAppearance(cs.AlternateSpace, cs, vals)
+				})
+			}
+			usage = plateExcluded
+		}
+		return usage
+	case *model.PdfColorspaceSpecialIndexed:
+		return p.usage(cs.Base)
+	case *model.PdfColorspaceICCBased:
+		if cs.Alternate != nil {
+			return p.usage(cs.Alternate)
+		}
+	}
+
+	// Other colorspaces are converted to process colors.
+	if isProcessInk(p.ink) {
+		return plateIncluded
+	}
+	return plateExcluded
+}
+
+// tint returns the amount of ink of the plate contained in the specified
+// color, in the range 0-1. The components of the color are used for
+// Separation and DeviceN colorspaces, as the colors of these colorspaces
+// are expressed in their alternate colorspace.
+func (p *inkPlate) tint(cs model.PdfColorspace, c model.PdfColor, comps []float64) (float64, error) {
+	switch cs := cs.(type) {
+	case *model.PdfColorspaceSpecialSeparation:
+		if len(comps) != 1 {
+			return 0, errRange
+		}
+		if name := cs.ColorantName; name != nil && (*name == "All" || name.String() == p.ink) {
+			return clampUnit(comps[0]), nil
+		}
+		return 0, nil
+	case *model.PdfColorspaceDeviceN:
+		names := colorantNames(cs)
+		if len(comps) != len(names) {
+			return 0, errRange
+		}
+		for i, name := range names {
+			if name == p.ink {
+				return clampUnit(comps[i]), nil
+			}
+		}
+		return 0, nil
+	}
+	if !isProcessInk(p.ink) {
+		return 0, nil
+	}
+
+	if c == nil {
+		if comps == nil {
+			return 0, errors.New("missing color")
+		}
+		var err error
+		if c, err = cs.ColorFromFloats(comps); err != nil {
+			return 0, err
+		}
+	}
+
+	// Colors in other colorspaces are converted to CMYK.
+	var cmyk [4]float64
+	if cc, ok := c.(*model.PdfColorDeviceCMYK); ok {
+		cmyk = [4]float64{cc.C(), cc.M(), cc.Y(), cc.K()}
+	} else {
+		rgb, err := toRGB(cs, c)
+		if err != nil {
+			return 0, err
+		}
+		cmyk = rgbToCMYK(rgb.R(), rgb.G(), rgb.B())
+	}
+	for i, ink := range processInks {
+		if ink == p.ink {
+			return clampUnit(cmyk[i]), nil
+		}
+	}
+	return 0, nil
+}
+
+// toRGB converts the specified color to the shade of gray representing the
+// amount of ink of the plate it contains.
+func (p *inkPlate) toRGB(cs model.PdfColorspace, c model.PdfColor, comps []float64) (*model.PdfColorDeviceRGB, error) {
+	t, err := p.tint(cs, c, comps)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewPdfColorDeviceRGB(1-t, 1-t, 1-t), nil
+}
+
+// isOverprinted returns true if painting the specified color with the
+// specified overprint setting leaves the plate unchanged.
+func (p *inkPlate) isOverprinted(cs model.PdfColorspace, c model.PdfColor, comps []float64,
+	overprint bool) bool {
+	switch p.usage(cs) {
+	case plateUnmarked:
+		return true
+	case plateExcluded:
+		return overprint
+	}
+
+	// In nonzero overprint mode, the components of DeviceCMYK colors which
+	// are zero leave the corresponding plates unchanged.
+	if _, ok := cs.(*model.PdfColorspaceDeviceCMYK); ok && overprint && p.state.overprint.mode == 1 {
+		if t, err := p.tint(cs, c, comps); err == nil && t == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setColorComponents records the operands of the color operators, which
+// are needed to compute the tints of Separation and DeviceN colors.
+func (p *inkPlate) setColorComponents(op *contentstream.ContentStreamOperation,
+	gs contentstream.GraphicsState) {
+	var comps []float64
+	switch op.Operand {
+	case "cs", "CS":
+		// Setting the colorspace sets the initial color of the colorspace,
+		// whose components are all 1 for Separation and DeviceN.
+		cs := gs.ColorspaceNonStroking
+		if op.Operand == "CS" {
+			cs = gs.ColorspaceStroking
+		}
+		comps = make([]float64, cs.GetNumComponents())
+		switch cs.(type) {
+		case *model.PdfColorspaceSpecialSeparation, *model.PdfColorspaceDeviceN:
+			for i := range comps {
+				comps[i] = 1
+			}
+		}
+	default:
+		for _, param := range op.Params {
+			if val, err := core.GetNumberAsFloat(param); err == nil {
+				comps = append(comps, val)
+			}
+		}
+	}
+
+	switch op.Operand {
+	case "g", "rg", "k", "cs", "sc", "scn":
+		p.state.fillComps = comps
+	default:
+		p.state.strokeComps = comps
+	}
+}
+
+// setOverprint updates the overprint parameters using the entries of the
+// specified graphics state parameter dictionary.
+func (p *inkPlate) setOverprint(extdict *core.PdfObjectDictionary) {
+	if val, ok := core.GetBoolVal(extdict.Get("OP")); ok {
+		p.state.overprint.stroke = val
+		// The nonstroking overprint parameter defaults to the value of OP.
+		if extdict.Get("op") == nil {
+			p.state.overprint.fill = val
+		}
+	}
+	if val, ok := core.GetBoolVal(extdict.Get("op")); ok {
+		p.state.overprint.fill = val
+	}
+	if val, ok := core.GetIntVal(extdict.Get("OPM")); ok {
+		p.state.overprint.mode = val
+	}
+}
+
+// image converts the specified image to a grayscale image representing the
+// amount of ink of the plate contained in each of its pixels. The decode
+// array maps the samples of the image to the ranges of the components of
+// the colorspace.
+func (p *inkPlate) image(img *model.Image, cs model.PdfColorspace, decode []float64) (*image.Gray, error) {
+	comps := img.ColorComponents
+	if cs == nil || comps != cs.GetNumComponents() || img.BitsPerComponent <= 0 || img.BitsPerComponent > 16 {
+		return nil, errors.New("unsupported image")
+	}
+	if len(decode) != 2*comps {
+		decode = cs.DecodeArray()
+		if len(decode) != 2*comps {
+			return nil, errRange
+		}
+	}
+
+	width, height := int(img.Width), int(img.Height)
+	samples := img.GetSamples()
+	if len(samples) < width*height*comps {
+		return nil, errRange
+	}
+
+	// Cache the shades of the sample tuples, as images usually contain a
+	// small number of distinct colors.
+	maxVal := float64(uint32(1)<<uint(img.BitsPerComponent) - 1)
+	cache := map[uint64]uint8{}
+	cacheable := int(img.BitsPerComponent)*comps <= 64
+	vals := make([]float64, comps)
+
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	for i := 0; i < width*height; i++ {
+		sample := samples[i*comps : (i+1)*comps]
+		var key uint64
+		if cacheable {
+			for _, s := range sample {
+				key = key<<uint(img.BitsPerComponent) | uint64(s)
+			}
+			if shade, ok := cache[key]; ok {
+				gray.Pix[i/width*gray.Stride+i%width] = shade
+				continue
+			}
+		}
+
+		for j, s := range sample {
+			vals[j] = decode[2*j] + float64(s)*(decode[2*j+1]-decode[2*j])/maxVal
+		}
+		t, err := p.tint(cs, nil, vals)
+		if err != nil {
+			return nil, err
+		}
+		shade := uint8(math.Round((1 - t) * 255))
+		if cacheable {
+			cache[key] = shade
+		}
+		gray.Pix[i/width*gray.Stride+i%width] = shade
+	}
+	return gray, nil
+}
+
+// colorantNames returns the colorant names of the specified DeviceN
+// colorspace.
+func colorantNames(cs *model.PdfColorspaceDeviceN) []string {
+	if cs.ColorantNames == nil {
+		return nil
+	}
+	names := make([]string, cs.ColorantNames.Len())
+	for i, obj := range cs.ColorantNames.Elements() {
+		if name, ok := core.GetName(obj); ok {
+			names[i] = name.String()
+		}
+	}
+	return names
+}
+
+// alternateAppearance returns the RGB appearance of the specified color
+// components of a Separation or DeviceN colorspace, computed using the
+// alternate colorspace.
+func alternateAppearance(alt, cs model.PdfColorspace, vals []float64) (color.RGBA, error) {
+	if alt == nil {
+		return color.RGBA{}, errType
+	}
+	c, err := cs.ColorFromFloats(vals)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	rgb, err := toRGB(alt, c)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	return color.RGBA{R: toUint8(rgb.R()), G: toUint8(rgb.G()), B: toUint8(rgb.B()), A: 255}, nil
+}
+
+// rgbToCMYK converts the specified RGB color to CMYK, using full black
+// generation and undercolor removal.
+func rgbToCMYK(r, g, b float64) [4]float64 {
+	k := 1 - math.Max(r, math.Max(g, b))
+	if k >= 1 {
+		return [4]float64{0, 0, 0, 1}
+	}
+	return [4]float64{
+		(1 - r - k) / (1 - k),
+		(1 - g - k) / (1 - k),
+		(1 - b - k) / (1 - k),
+		k,
+	}
+}
+
+func clampUnit(val float64) float64 {
+	return math.Min(math.Max(val, 0), 1)
+}
+
+// compositeSeparations combines the specified separations into an RGB
+// image, simulating the appearance of the printed inks. The inks are
+// treated as perfectly transparent filters.
+func compositeSeparations(seps []*Separation) *image.RGBA {
+	if len(seps) == 0 {
+		return nil
+	}
+	bounds := seps[0].Image.Bounds()
+	img := image.NewRGBA(bounds)
+	for i := 0; i < len(img.Pix); i += 4 {
+		px := i / 4
+		r, g, b := 1.0, 1.0, 1.0
+		for _, sep := range seps {
+			t := 1 - float64(sep.Image.Pix[px/bounds.Dx()*sep.Image.Stride+px%bounds.Dx()])/255
+			if t == 0 {
+				continue
+			}
+			r *= 1 - t*(1-float64(sep.Color.R)/255)
+			g *= 1 - t*(1-float64(sep.Color.G)/255)
+			b *= 1 - t*(1-float64(sep.Color.B)/255)
+		}
+		img.Pix[i] = toUint8(r)
+		img.Pix[i+1] = toUint8(g)
+		img.Pix[i+2] = toUint8(b)
+		img.Pix[i+3] = 255
+	}
+	return img
+}
+
+// processPlateOperation updates the state of the plate being rendered using
+// the specified operation. The plate states saved by the q operator are
+// pushed to `stack`.
+func (r renderer) processPlateOperation(ctx context.Context, op *contentstream.ContentStreamOperation,
+	gs contentstream.GraphicsState, stack *[]plateState) {
+	switch op.Operand {
+	case "q":
+		*stack = append(*stack, r.plate.state)
+	case "Q":
+		if n := len(*stack); n > 0 {
+			r.plate.state = (*stack)[n-1]
+			*stack = (*stack)[:n-1]
+		}
+	case "g", "G", "rg", "RG", "k", "K", "cs", "CS", "sc", "SC", "scn", "SCN":
+		r.plate.setColorComponents(op, gs)
+	case "Tj", "TJ", "'", "\"":
+		// The colors of text are set by the color operators, so they have to
+		// be converted before showing text.
+		if err := r.setPlateColor(ctx, gs, false); err != nil {
+			common.Log.Debug("Error setting text fill color: %v", err)
+		}
+		if err := r.setPlateColor(ctx, gs, true); err != nil {
+			common.Log.Debug("Error setting text stroke color: %v", err)
+		}
+	}
+}
+
+// setPlateColor sets the fill or stroke color of the context to the shade
+// of gray representing the amount of ink of the plate contained in the
+// color of the graphics state. Overprinted colors are made transparent, so
+// that painting leaves the plate unchanged.
+func (r renderer) setPlateColor(ctx context.Context, gs contentstream.GraphicsState, stroke bool) error {
+	cs, c := gs.ColorspaceNonStroking, gs.ColorNonStroking
+	comps, overprint := r.plate.state.fillComps, r.plate.state.overprint.fill
+	if stroke {
+		cs, c = gs.ColorspaceStroking, gs.ColorStroking
+		comps, overprint = r.plate.state.strokeComps, r.plate.state.overprint.stroke
+	}
+
+	rgb, err := r.plate.toRGB(cs, c, comps)
+	if err != nil {
+		return err
+	}
+	alpha := 1.0
+	if r.plate.isOverprinted(cs, c, comps, overprint) {
+		alpha = 0
+	}
+
+	if stroke {
+		ctx.SetStrokeRGBA(rgb.R(), rgb.G(), rgb.B(), alpha)
+	} else {
+		ctx.SetFillRGBA(rgb.R(), rgb.G(), rgb.B(), alpha)
+	}
+	return nil
+}
+
+// plateImage converts the specified image to the shades of the plate being
+// rendered. Returns false if the image is overprinted or if it cannot be
+// converted, in which case the image must not be painted.
+func (r renderer) plateImage(img *model.Image, cs model.PdfColorspace, decodeObj core.PdfObject) (image.Image, bool) {
+	if cs == nil {
+		// Image masks are painted like DeviceGray images.
+		cs = model.NewPdfColorspaceDeviceGray()
+	}
+	if r.plate.isOverprinted(cs, nil, nil, r.plate.state.overprint.fill) {
+		return nil, false
+	}
+
+	var decode []float64
+	if arr, ok := core.GetArray(decodeObj); ok {
+		decode, _ = core.GetNumbersAsFloat(arr.Elements())
+	}
+	gray, err := r.plate.image(img, cs, decode)
+	if err != nil {
+		common.Log.Debug("ERROR: could not convert image to ink %s: %v", r.plate.ink, err)
+		return nil, false
+	}
+	return gray, true
+}
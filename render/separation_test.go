@@ -0,0 +1,98 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// newSeparationTestPage returns a 60 x 20 page made of three 20 x 20 squares, painted with the
+// process colors and the spot color Orange, with and without overprint.
+func newSeparationTestPage(t *testing.T) *model.PdfPage {
+	page := newTestPage(t, 60, 20, `
+		1 0 0 0 k 0 0 20 20 re f
+		0 1 0 0 k 20 0 20 20 re f
+		1 0 0 0 k 40 0 20 20 re f
+		q /GS0 gs /CS0 cs 1 scn 0 0 20 20 re f Q
+		/CS0 cs 1 scn 20 0 20 20 re f
+		q /GS1 gs 0 1 0 0 k 40 0 20 20 re f Q`)
+
+	obj := parseTestDict(t, `<< /CS [/Separation /Orange /DeviceCMYK
+		<< /FunctionType 2 /Domain [0 1] /C0 [0 0 0 0] /C1 [0 0.5 1 0] /N 1 >>] >>`).Get("CS")
+	cs, err := model.NewPdfColorspaceFromPdfObject(obj)
+	require.NoError(t, err)
+	require.NoError(t, page.Resources.SetColorspaceByName("CS0", cs))
+	require.NoError(t, page.Resources.AddExtGState("GS0", parseTestDict(t, `<< /OP true >>`)))
+	require.NoError(t, page.Resources.AddExtGState("GS1", parseTestDict(t, `<< /OP true /OPM 1 >>`)))
+	return page
+}
+
+// TestRenderSeparations tests that a separation is rendered for each process ink and for each
+// spot ink, and that overprinted objects leave the plates of the inks they do not contain
+// unchanged.
+func TestRenderSeparations(t *testing.T) {
+	seps, err := NewImageDevice().RenderSeparations(newSeparationTestPage(t))
+	require.NoError(t, err)
+
+	var inks []string
+	for _, sep := range seps {
+		inks = append(inks, sep.Ink)
+		require.Equal(t, 60, sep.Image.Bounds().Dx())
+		require.Equal(t, 20, sep.Image.Bounds().Dy())
+	}
+	require.Equal(t, []string{InkCyan, InkMagenta, InkYellow, InkBlack, "Orange"}, inks)
+	require.Equal(t, processColors[InkCyan], seps[0].Color)
+
+	// The spot color appears as its alternate color at full tint.
+	c := seps[4].Color
+	require.InDelta(t, 255, c.R, 2)
+	require.InDelta(t, 128, c.G, 2)
+	require.InDelta(t, 0, c.B, 2)
+
+	// The gray levels of the plates in each square: 0 is full ink and 255 is no ink.
+	testcases := []struct {
+		name string
+		x    int
+		inks [5]uint8
+	}{
+		// Orange overprints cyan.
+		{"overprint", 10, [5]uint8{0, 255, 255, 255, 0}},
+		// Orange knocks out magenta, as the overprint setting is restored by Q.
+		{"knockout", 30, [5]uint8{255, 255, 255, 255, 0}},
+		// In nonzero overprint mode, the zero cyan component of magenta leaves cyan unchanged.
+		{"overprint mode", 50, [5]uint8{0, 0, 255, 255, 255}},
+	}
+	for _, tc := range testcases {
+		for i, sep := range seps {
+			require.Equal(t, tc.inks[i], sep.Image.GrayAt(tc.x, 10).Y, "%s: %s plate", tc.name, sep.Ink)
+		}
+	}
+}
+
+// TestSimulateOverprint tests that SimulateOverprint composites the separations, so that
+// overprinted colors are combined instead of being replaced.
+func TestSimulateOverprint(t *testing.T) {
+	img, err := NewImageDevice().Render(newSeparationTestPage(t))
+	require.NoError(t, err)
+	orange := color.RGBA{R: 255, G: 128, A: 255}
+	requireColor(t, img, 10, 10, orange, 3)
+	requireColor(t, img, 50, 10, color.RGBA{R: 255, B: 255, A: 255}, 3)
+
+	device := NewImageDevice()
+	device.SimulateOverprint = true
+	img, err = device.Render(newSeparationTestPage(t))
+	require.NoError(t, err)
+
+	// Cyan under orange, orange alone and magenta over cyan.
+	requireColor(t, img, 10, 10, color.RGBA{G: 128, A: 255}, 3)
+	requireColor(t, img, 30, 10, orange, 3)
+	requireColor(t, img, 50, 10, color.RGBA{B: 255, A: 255}, 3)
+}
@@ -0,0 +1,1029 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/common"
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/jbig2/reader"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// shadingLUTSize is the number of precomputed colors used for shadings
+// which depend on a single parametric variable.
+const shadingLUTSize = 512
+
+// shadingGradientStops is the number of color stops of the gradients
+// representing axial and radial shadings.
+const shadingGradientStops = 32
+
+// shadingPattern is a context pattern which paints a PDF shading.
+// The colors of function-based, axial and radial shadings are computed
+// for each device pixel, while mesh shadings (types 4-7) are rasterized
+// when the pattern is created.
+type shadingPattern struct {
+	inv        transform.Matrix // Maps device space to shading space.
+	bbox       *model.PdfRectangle
+	background color.Color
+	sample     func(x, y float64) (color.Color, bool)
+	raster     *image.RGBA
+	gradient   *context.GradientDefinition
+}
+
+// newShadingPattern returns a pattern which paints the specified shading.
+// The matrix `m` maps shading space to device space and `width`, `height`
+// are the dimensions of the device. If `background` is true, the points
+// not covered by the shading are painted using the Background entry of the
+// shading, if present. If `plate` is not nil, the colors of the shading are
+// converted to the shades of the plate.
+func newShadingPattern(shading *model.PdfShading, m transform.Matrix, width, height int,
+	background bool, plate *inkPlate) (*shadingPattern, error) {
+	if shading == nil || shading.ColorSpace == nil {
+		return nil, errType
+	}
+
+	inv, ok := invertMatrix(m)
+	if !ok {
+		return nil, errors.New("shading matrix is not invertible")
+	}
+
+	p := &shadingPattern{
+		inv:  inv,
+		bbox: shading.BBox,
+	}
+	if background && shading.Background != nil {
+		sc := &shadingColor{cs: shading.ColorSpace, plate: plate}
+		vals, err := core.GetNumbersAsFloat(shading.Background.Elements())
+		if err != nil {
+			return nil, err
+		}
+		bg, err := sc.toRGBA(vals)
+		if err != nil {
+			return nil, err
+		}
+		p.background = bg
+	}
+
+	var err error
+	switch sh := shading.GetContext().(type) {
+	case *model.PdfShadingType1:
+		p.sample, err = newFunctionShadingSampler(sh, plate)
+	case *model.PdfShadingType2:
+		p.sample, err = newAxialShadingSampler(sh, plate)
+		if err == nil && shading.BBox == nil {
+			p.gradient, err = newShadingGradient(sh.PdfShading, sh.Coords, sh.Function, sh.Domain, sh.Extend, m, plate)
+		}
+	case *model.PdfShadingType3:
+		p.sample, err = newRadialShadingSampler(sh, plate)
+		if err == nil && shading.BBox == nil {
+			p.gradient, err = newShadingGradient(sh.PdfShading, sh.Coords, sh.Function, sh.Domain, sh.Extend, m, plate)
+		}
+	case *model.PdfShadingType4:
+		p.raster, err = rasterizeFreeFormMesh(sh, m, width, height, plate)
+	case *model.PdfShadingType5:
+		p.raster, err = rasterizeLatticeMesh(sh, m, width, height, plate)
+	case *model.PdfShadingType6:
+		p.raster, err = rasterizePatchMesh(sh.PdfShading, sh.BitsPerCoordinate, sh.BitsPerComponent,
+			sh.BitsPerFlag, sh.Decode, sh.Function, false, m, width, height, plate)
+	case *model.PdfShadingType7:
+		p.raster, err = rasterizePatchMesh(sh.PdfShading, sh.BitsPerCoordinate, sh.BitsPerComponent,
+			sh.BitsPerFlag, sh.Decode, sh.Function, true, m, width, height, plate)
+	default:
+		return nil, errType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ColorAt returns the color of the shading at the specified device pixel.
+func (p *shadingPattern) ColorAt(x, y int) color.Color {
+	sx, sy := p.inv.Transform(float64(x)+0.5, float64(y)+0.5)
+	if b := p.bbox; b != nil {
+		if sx < b.Llx || sx > b.Urx || sy < b.Lly || sy > b.Ury {
+			return color.Transparent
+		}
+	}
+
+	var c color.Color
+	var ok bool
+	if p.raster != nil {
+		if (image.Point{X: x, Y: y}).In(p.raster.Rect) {
+			rgba := p.raster.RGBAAt(x, y)
+			c, ok = rgba, rgba.A != 0
+		}
+	} else {
+		c, ok = p.sample(sx, sy)
+	}
+	if !ok {
+		if p.background != nil {
+			return p.background
+		}
+		return color.Transparent
+	}
+	return c
+}
+
+// GradientDefinition returns the definition of the gradient painted by the
+// pattern. Only axial and radial shadings which are extended on both ends
+// and are not clipped by a bounding box can be represented as gradients.
+func (p *shadingPattern) GradientDefinition() (*context.GradientDefinition, bool) {
+	return p.gradient, p.gradient != nil
+}
+
+// shadingColor converts the color components of a shading into RGBA colors.
+// If functions are specified, the components are used as the input of the
+// functions, and their output represents the color in the colorspace of the
+// shading. If a plate is specified, the colors are converted to the shades
+// of the plate.
+type shadingColor struct {
+	cs    model.PdfColorspace
+	fns   []model.PdfFunction
+	plate *inkPlate
+}
+
+func (sc *shadingColor) toRGBA(vals []float64) (color.RGBA, error) {
+	if len(sc.fns) > 0 {
+		var out []float64
+		for _, fn := range sc.fns {
+			res, err := fn.Evaluate(vals)
+			if err != nil {
+				return color.RGBA{}, err
+			}
+			out = append(out, res...)
+		}
+		vals = out
+	}
+
+	// Clamp components to the ranges of the colorspace.
+	if decode := sc.cs.DecodeArray(); len(decode) == 2*len(vals) {
+		clamped := make([]float64, len(vals))
+		for i, val := range vals {
+			clamped[i] = math.Min(math.Max(val, decode[2*i]), decode[2*i+1])
+		}
+		vals = clamped
+	}
+
+	var rgbColor *model.PdfColorDeviceRGB
+	if sc.plate != nil {
+		c, err := sc.plate.toRGB(sc.cs, nil, vals)
+		if err != nil {
+			return color.RGBA{}, err
+		}
+		rgbColor = c
+	} else {
+		pdfColor, err := sc.cs.ColorFromFloats(vals)
+		if err != nil {
+			return color.RGBA{}, err
+		}
+		if rgbColor, err = toRGB(sc.cs, pdfColor); err != nil {
+			return color.RGBA{}, err
+		}
+	}
+
+	return color.RGBA{
+		R: toUint8(rgbColor.R()),
+		G: toUint8(rgbColor.G()),
+		B: toUint8(rgbColor.B()),
+		A: 255,
+	}, nil
+}
+
+// shadingLUT holds precomputed colors for shadings which depend on a
+// single parametric variable t, varying between t0 and t1.
+type shadingLUT struct {
+	t0, t1 float64
+	colors []color.RGBA
+}
+
+func newShadingLUT(sc *shadingColor, t0, t1 float64) (*shadingLUT, error) {
+	lut := &shadingLUT{
+		t0:     t0,
+		t1:     t1,
+		colors: make([]color.RGBA, shadingLUTSize),
+	}
+	for i := range lut.colors {
+		t := t0 + (t1-t0)*float64(i)/float64(shadingLUTSize-1)
+		c, err := sc.toRGBA([]float64{t})
+		if err != nil {
+			return nil, err
+		}
+		lut.colors[i] = c
+	}
+
+	return lut, nil
+}
+
+// at returns the color corresponding to the value t, clamped to [t0, t1].
+func (lut *shadingLUT) at(t float64) color.RGBA {
+	if lut.t1 == lut.t0 {
+		return lut.colors[0]
+	}
+	s := (t - lut.t0) / (lut.t1 - lut.t0)
+	i := int(math.Round(s * float64(shadingLUTSize-1)))
+	if i < 0 {
+		i = 0
+	} else if i >= shadingLUTSize {
+		i = shadingLUTSize - 1
+	}
+	return lut.colors[i]
+}
+
+// newFunctionShadingSampler returns a sampler for function-based shadings.
+func newFunctionShadingSampler(sh *model.PdfShadingType1, plate *inkPlate) (func(x, y float64) (color.Color, bool), error) {
+	if len(sh.Function) == 0 {
+		return nil, errRange
+	}
+	domain, err := getFloats(sh.Domain, []float64{0, 1, 0, 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(domain) != 4 {
+		return nil, errRange
+	}
+
+	// The matrix of the shading maps the domain to shading space.
+	inv := transform.IdentityMatrix()
+	if sh.Matrix != nil {
+		mf, err := getFloats(sh.Matrix, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(mf) != 6 {
+			return nil, errRange
+		}
+
+		var ok bool
+		inv, ok = invertMatrix(transform.NewMatrix(mf[0], mf[1], mf[2], mf[3], mf[4], mf[5]))
+		if !ok {
+			return nil, errors.New("shading matrix is not invertible")
+		}
+	}
+
+	sc := &shadingColor{cs: sh.ColorSpace, fns: sh.Function, plate: plate}
+	return func(x, y float64) (color.Color, bool) {
+		u, v := inv.Transform(x, y)
+		if u < domain[0] || u > domain[1] || v < domain[2] || v > domain[3] {
+			return nil, false
+		}
+
+		c, err := sc.toRGBA([]float64{u, v})
+		if err != nil {
+			return nil, false
+		}
+		return c, true
+	}, nil
+}
+
+// newAxialShadingSampler returns a sampler for axial shadings.
+func newAxialShadingSampler(sh *model.PdfShadingType2, plate *inkPlate) (func(x, y float64) (color.Color, bool), error) {
+	coords, err := getFloats(sh.Coords, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(coords) != 4 {
+		return nil, errRange
+	}
+	lut, extStart, extEnd, err := newParametricShadingLUT(sh.PdfShading, sh.Function, sh.Domain, sh.Extend, plate)
+	if err != nil {
+		return nil, err
+	}
+
+	x0, y0, x1, y1 := coords[0], coords[1], coords[2], coords[3]
+	dx, dy := x1-x0, y1-y0
+	denom := dx*dx + dy*dy
+	return func(x, y float64) (color.Color, bool) {
+		var s float64
+		if denom != 0 {
+			s = ((x-x0)*dx + (y-y0)*dy) / denom
+		}
+		if s < 0 {
+			if !extStart {
+				return nil, false
+			}
+			s = 0
+		} else if s > 1 {
+			if !extEnd {
+				return nil, false
+			}
+			s = 1
+		}
+		return lut.at(lut.t0 + s*(lut.t1-lut.t0)), true
+	}, nil
+}
+
+// newRadialShadingSampler returns a sampler for radial shadings.
+func newRadialShadingSampler(sh *model.PdfShadingType3, plate *inkPlate) (func(x, y float64) (color.Color, bool), error) {
+	coords, err := getFloats(sh.Coords, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(coords) != 6 {
+		return nil, errRange
+	}
+	lut, extStart, extEnd, err := newParametricShadingLUT(sh.PdfShading, sh.Function, sh.Domain, sh.Extend, plate)
+	if err != nil {
+		return nil, err
+	}
+
+	x0, y0, r0 := coords[0], coords[1], coords[2]
+	cdx, cdy, cdr := coords[3]-x0, coords[4]-y0, coords[5]-r0
+	a := cdx*cdx + cdy*cdy - cdr*cdr
+
+	// valid checks if the circle corresponding to s contains the point and
+	// returns the clamped value of s.
+	valid := func(s float64) (float64, bool) {
+		if r0+s*cdr < 0 {
+			return 0, false
+		}
+		if s < 0 {
+			return 0, extStart
+		}
+		if s > 1 {
+			return 1, extEnd
+		}
+		return s, true
+	}
+
+	return func(x, y float64) (color.Color, bool) {
+		// Find the largest s such that the point lies on the circle with
+		// center (x0 + s*cdx, y0 + s*cdy) and radius r0 + s*cdr.
+		pdx, pdy := x-x0, y-y0
+		b := pdx*cdx + pdy*cdy + r0*cdr
+		c := pdx*pdx + pdy*pdy - r0*r0
+
+		var roots []float64
+		if a == 0 {
+			if b == 0 {
+				return nil, false
+			}
+			roots = []float64{c / (2 * b)}
+		} else {
+			discr := b*b - a*c
+			if discr < 0 {
+				return nil, false
+			}
+			sqrtDiscr := math.Sqrt(discr)
+			s0, s1 := (b+sqrtDiscr)/a, (b-sqrtDiscr)/a
+			if s0 < s1 {
+				s0, s1 = s1, s0
+			}
+			roots = []float64{s0, s1}
+		}
+
+		for _, s := range roots {
+			if s, ok := valid(s); ok {
+				return lut.at(lut.t0 + s*(lut.t1-lut.t0)), true
+			}
+		}
+		return nil, false
+	}, nil
+}
+
+// newShadingGradient returns the gradient definition of the specified axial
+// or radial shading, whose space is mapped to device space by `m`. Returns
+// nil if the shading cannot be represented as a gradient.
+func newShadingGradient(shading *model.PdfShading, coordsArr *core.PdfObjectArray, fns []model.PdfFunction,
+	domainArr, extendArr *core.PdfObjectArray, m transform.Matrix, plate *inkPlate) (*context.GradientDefinition, error) {
+	coords, err := getFloats(coordsArr, nil)
+	if err != nil {
+		return nil, err
+	}
+	lut, extStart, extEnd, err := newParametricShadingLUT(shading, fns, domainArr, extendArr, plate)
+	if err != nil {
+		return nil, err
+	}
+	if !extStart || !extEnd {
+		return nil, nil
+	}
+
+	gradient := &context.GradientDefinition{Matrix: m}
+	switch len(coords) {
+	case 4:
+		gradient.X0, gradient.Y0 = coords[0], coords[1]
+		gradient.X1, gradient.Y1 = coords[2], coords[3]
+	case 6:
+		// Radial gradients can only be represented if the starting circle
+		// is inside the ending circle.
+		x0, y0, r0, x1, y1, r1 := coords[0], coords[1], coords[2], coords[3], coords[4], coords[5]
+		if math.Hypot(x1-x0, y1-y0)+r0 > r1 {
+			return nil, nil
+		}
+		gradient.Radial = true
+		gradient.X0, gradient.Y0, gradient.R0 = x0, y0, r0
+		gradient.X1, gradient.Y1, gradient.R1 = x1, y1, r1
+	default:
+		return nil, errRange
+	}
+
+	for i := 0; i < shadingGradientStops; i++ {
+		s := float64(i) / (shadingGradientStops - 1)
+		gradient.Stops = append(gradient.Stops, context.GradientStop{
+			Offset: s,
+			Color:  lut.at(lut.t0 + s*(lut.t1-lut.t0)),
+		})
+	}
+	return gradient, nil
+}
+
+// newParametricShadingLUT returns the color lookup table and the extend
+// flags of axial and radial shadings.
+func newParametricShadingLUT(shading *model.PdfShading, fns []model.PdfFunction,
+	domainArr, extendArr *core.PdfObjectArray, plate *inkPlate) (*shadingLUT, bool, bool, error) {
+	if len(fns) == 0 {
+		return nil, false, false, errRange
+	}
+	domain, err := getFloats(domainArr, []float64{0, 1})
+	if err != nil {
+		return nil, false, false, err
+	}
+	if len(domain) != 2 {
+		return nil, false, false, errRange
+	}
+
+	var extStart, extEnd bool
+	if extendArr != nil && extendArr.Len() == 2 {
+		extStart, _ = core.GetBoolVal(extendArr.Get(0))
+		extEnd, _ = core.GetBoolVal(extendArr.Get(1))
+	}
+
+	sc := &shadingColor{cs: shading.ColorSpace, fns: fns, plate: plate}
+	lut, err := newShadingLUT(sc, domain[0], domain[1])
+	if err != nil {
+		return nil, false, false, err
+	}
+	return lut, extStart, extEnd, nil
+}
+
+// meshVertex represents a vertex of a mesh shading in device space. The
+// values of the vertex are either the parametric variable t of the shading,
+// if the shading has functions, or the RGB components of its color.
+type meshVertex struct {
+	x, y float64
+	vals []float64
+}
+
+// meshRasterizer paints the triangles of mesh shadings onto an image.
+type meshRasterizer struct {
+	im  *image.RGBA
+	m   transform.Matrix
+	sc  *shadingColor
+	lut *shadingLUT
+}
+
+func newMeshRasterizer(shading *model.PdfShading, fns []model.PdfFunction, decode []float64,
+	m transform.Matrix, width, height int, plate *inkPlate) (*meshRasterizer, error) {
+	rz := &meshRasterizer{
+		im: image.NewRGBA(image.Rect(0, 0, width, height)),
+		m:  m,
+		sc: &shadingColor{cs: shading.ColorSpace, plate: plate},
+	}
+	if len(fns) > 0 {
+		if len(decode) < 6 {
+			return nil, errRange
+		}
+
+		lut, err := newShadingLUT(&shadingColor{cs: shading.ColorSpace, fns: fns, plate: plate}, decode[4], decode[5])
+		if err != nil {
+			return nil, err
+		}
+		rz.lut = lut
+	}
+
+	return rz, nil
+}
+
+// vertex returns the device space representation of the mesh vertex at
+// the specified shading space coordinates, with the specified color.
+func (rz *meshRasterizer) vertex(x, y float64, comps []float64) (meshVertex, error) {
+	vals, err := rz.values(comps)
+	if err != nil {
+		return meshVertex{}, err
+	}
+
+	x, y = rz.m.Transform(x, y)
+	return meshVertex{x: x, y: y, vals: vals}, nil
+}
+
+// values returns the vertex values corresponding to the specified color
+// components.
+func (rz *meshRasterizer) values(comps []float64) ([]float64, error) {
+	if rz.lut != nil {
+		return comps, nil
+	}
+
+	c, err := rz.sc.toRGBA(comps)
+	if err != nil {
+		return nil, err
+	}
+	return []float64{float64(c.R), float64(c.G), float64(c.B)}, nil
+}
+
+// color returns the color corresponding to the specified vertex values.
+func (rz *meshRasterizer) color(vals []float64) color.RGBA {
+	if rz.lut != nil {
+		return rz.lut.at(vals[0])
+	}
+	return color.RGBA{
+		R: uint8(math.Round(vals[0])),
+		G: uint8(math.Round(vals[1])),
+		B: uint8(math.Round(vals[2])),
+		A: 255,
+	}
+}
+
+// fillTriangle paints a Gouraud-shaded triangle, interpolating the values of
+// its vertices for each of the covered pixels.
+func (rz *meshRasterizer) fillTriangle(a, b, c meshVertex) {
+	area := (b.x-a.x)*(c.y-a.y) - (c.x-a.x)*(b.y-a.y)
+	if area == 0 {
+		return
+	}
+
+	bounds := rz.im.Bounds()
+	minX := maxInt(int(math.Floor(math.Min(a.x, math.Min(b.x, c.x)))), bounds.Min.X)
+	maxX := minInt(int(math.Ceil(math.Max(a.x, math.Max(b.x, c.x)))), bounds.Max.X-1)
+	minY := maxInt(int(math.Floor(math.Min(a.y, math.Min(b.y, c.y)))), bounds.Min.Y)
+	maxY := minInt(int(math.Ceil(math.Max(a.y, math.Max(b.y, c.y)))), bounds.Max.Y-1)
+
+	const eps = -1e-9
+	vals := make([]float64, len(a.vals))
+	for py := minY; py <= maxY; py++ {
+		fy := float64(py) + 0.5
+		for px := minX; px <= maxX; px++ {
+			fx := float64(px) + 0.5
+
+			// Barycentric coordinates of the pixel center.
+			wa := ((b.x-fx)*(c.y-fy) - (c.x-fx)*(b.y-fy)) / area
+			wb := ((c.x-fx)*(a.y-fy) - (a.x-fx)*(c.y-fy)) / area
+			wc := 1 - wa - wb
+			if wa < eps || wb < eps || wc < eps {
+				continue
+			}
+
+			for i := range vals {
+				vals[i] = wa*a.vals[i] + wb*b.vals[i] + wc*c.vals[i]
+			}
+			rz.im.SetRGBA(px, py, rz.color(vals))
+		}
+	}
+}
+
+// meshReader reads the vertex data of mesh shadings.
+type meshReader struct {
+	r           *reader.Reader
+	bitsCoord   byte
+	bitsComp    byte
+	bitsFlag    byte
+	decode      []float64
+	numComps    int
+	maxCoordVal float64
+	maxCompVal  float64
+}
+
+func newMeshReader(shading *model.PdfShading, bitsCoord, bitsComp, bitsFlag *core.PdfObjectInteger,
+	decodeArr *core.PdfObjectArray, fns []model.PdfFunction) (*meshReader, error) {
+	stream, ok := core.GetStream(shading.GetContainingPdfObject())
+	if !ok {
+		return nil, errType
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	decode, err := getFloats(decodeArr, nil)
+	if err != nil {
+		return nil, err
+	}
+	numComps := shading.ColorSpace.GetNumComponents()
+	if len(fns) > 0 {
+		numComps = 1
+	}
+	if len(decode) < 4+2*numComps {
+		return nil, errRange
+	}
+
+	mr := &meshReader{
+		r:        reader.New(data),
+		decode:   decode,
+		numComps: numComps,
+	}
+	if bitsCoord != nil {
+		mr.bitsCoord = byte(*bitsCoord)
+	}
+	if bitsComp != nil {
+		mr.bitsComp = byte(*bitsComp)
+	}
+	if bitsFlag != nil {
+		mr.bitsFlag = byte(*bitsFlag)
+	}
+	if mr.bitsCoord == 0 || mr.bitsCoord > 32 || mr.bitsComp == 0 || mr.bitsComp > 16 || mr.bitsFlag > 8 {
+		return nil, errRange
+	}
+	mr.maxCoordVal = float64(uint64(1)<<mr.bitsCoord - 1)
+	mr.maxCompVal = float64(uint64(1)<<mr.bitsComp - 1)
+
+	return mr, nil
+}
+
+func (mr *meshReader) readFlag() (int, error) {
+	flag, err := mr.r.ReadBits(mr.bitsFlag)
+	if err != nil {
+		return 0, err
+	}
+	return int(flag), nil
+}
+
+func (mr *meshReader) readPoint() (float64, float64, error) {
+	bx, err := mr.r.ReadBits(mr.bitsCoord)
+	if err != nil {
+		return 0, 0, err
+	}
+	by, err := mr.r.ReadBits(mr.bitsCoord)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	x := mr.decode[0] + float64(bx)*(mr.decode[1]-mr.decode[0])/mr.maxCoordVal
+	y := mr.decode[2] + float64(by)*(mr.decode[3]-mr.decode[2])/mr.maxCoordVal
+	return x, y, nil
+}
+
+func (mr *meshReader) readColor() ([]float64, error) {
+	comps := make([]float64, mr.numComps)
+	for i := range comps {
+		b, err := mr.r.ReadBits(mr.bitsComp)
+		if err != nil {
+			return nil, err
+		}
+		dmin, dmax := mr.decode[4+2*i], mr.decode[5+2*i]
+		comps[i] = dmin + float64(b)*(dmax-dmin)/mr.maxCompVal
+	}
+	return comps, nil
+}
+
+// readVertex reads the coordinates and the color of the next vertex and
+// converts it to device space.
+func (mr *meshReader) readVertex(rz *meshRasterizer) (meshVertex, error) {
+	x, y, err := mr.readPoint()
+	if err != nil {
+		return meshVertex{}, err
+	}
+	comps, err := mr.readColor()
+	if err != nil {
+		return meshVertex{}, err
+	}
+	return rz.vertex(x, y, comps)
+}
+
+// rasterizeFreeFormMesh rasterizes free-form Gouraud-shaded triangle meshes.
+func rasterizeFreeFormMesh(sh *model.PdfShadingType4, m transform.Matrix, width, height int,
+	plate *inkPlate) (*image.RGBA, error) {
+	mr, err := newMeshReader(sh.PdfShading, sh.BitsPerCoordinate, sh.BitsPerComponent, sh.BitsPerFlag,
+		sh.Decode, sh.Function)
+	if err != nil {
+		return nil, err
+	}
+	rz, err := newMeshRasterizer(sh.PdfShading, sh.Function, mr.decode, m, width, height, plate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each vertex starts on a byte boundary.
+	next := func() (int, meshVertex, error) {
+		mr.r.Align()
+		flag, err := mr.readFlag()
+		if err != nil {
+			return 0, meshVertex{}, err
+		}
+		v, err := mr.readVertex(rz)
+		return flag, v, err
+	}
+
+	var va, vb, vc meshVertex
+	var hasTriangle bool
+	for {
+		flag, vd, err := next()
+		if err != nil {
+			return rz.im, nil
+		}
+
+		switch flag {
+		case 0:
+			_, ve, err := next()
+			if err != nil {
+				return rz.im, nil
+			}
+			_, vf, err := next()
+			if err != nil {
+				return rz.im, nil
+			}
+			va, vb, vc = vd, ve, vf
+			hasTriangle = true
+		case 1:
+			if !hasTriangle {
+				continue
+			}
+			va, vb, vc = vb, vc, vd
+		case 2:
+			if !hasTriangle {
+				continue
+			}
+			vb, vc = vc, vd
+		default:
+			common.Log.Debug("ERROR: invalid mesh vertex flag: %d", flag)
+			return rz.im, nil
+		}
+		rz.fillTriangle(va, vb, vc)
+	}
+}
+
+// rasterizeLatticeMesh rasterizes lattice-form Gouraud-shaded triangle meshes.
+func rasterizeLatticeMesh(sh *model.PdfShadingType5, m transform.Matrix, width, height int,
+	plate *inkPlate) (*image.RGBA, error) {
+	if sh.VerticesPerRow == nil || *sh.VerticesPerRow < 2 {
+		return nil, errRange
+	}
+	perRow := int(*sh.VerticesPerRow)
+
+	mr, err := newMeshReader(sh.PdfShading, sh.BitsPerCoordinate, sh.BitsPerComponent, nil,
+		sh.Decode, sh.Function)
+	if err != nil {
+		return nil, err
+	}
+	rz, err := newMeshRasterizer(sh.PdfShading, sh.Function, mr.decode, m, width, height, plate)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev []meshVertex
+	for {
+		row := make([]meshVertex, 0, perRow)
+		for len(row) < perRow {
+			v, err := mr.readVertex(rz)
+			if err != nil {
+				return rz.im, nil
+			}
+			row = append(row, v)
+		}
+
+		if prev != nil {
+			for i := 0; i < perRow-1; i++ {
+				rz.fillTriangle(prev[i], prev[i+1], row[i])
+				rz.fillTriangle(prev[i+1], row[i], row[i+1])
+			}
+		}
+		prev = row
+	}
+}
+
+// patchBoundary contains the positions of the boundary control points of
+// Coons and tensor-product patches, in the order they are specified in the
+// shading data. Positions are specified as [u][v] indices.
+var patchBoundary = [12][2]int{
+	{0, 0}, {0, 1}, {0, 2}, {0, 3}, {1, 3}, {2, 3},
+	{3, 3}, {3, 2}, {3, 1}, {3, 0}, {2, 0}, {1, 0},
+}
+
+// patchInternal contains the positions of the internal control points of
+// tensor-product patches, in the order they are specified in the shading data.
+var patchInternal = [4][2]int{{1, 1}, {1, 2}, {2, 2}, {2, 1}}
+
+// meshPatch represents a tensor-product patch in device space. The corner
+// values are stored in the order (0,0), (0,3), (3,3), (3,0).
+type meshPatch struct {
+	p    [4][4]transform.Point
+	vals [4][]float64
+}
+
+// rasterizePatchMesh rasterizes Coons (type 6) and tensor-product (type 7)
+// patch meshes.
+func rasterizePatchMesh(shading *model.PdfShading, bitsCoord, bitsComp, bitsFlag *core.PdfObjectInteger,
+	decodeArr *core.PdfObjectArray, fns []model.PdfFunction, tensor bool, m transform.Matrix,
+	width, height int, plate *inkPlate) (*image.RGBA, error) {
+	mr, err := newMeshReader(shading, bitsCoord, bitsComp, bitsFlag, decodeArr, fns)
+	if err != nil {
+		return nil, err
+	}
+	rz, err := newMeshRasterizer(shading, fns, mr.decode, m, width, height, plate)
+	if err != nil {
+		return nil, err
+	}
+
+	readPoint := func(patch *meshPatch, pos [2]int) error {
+		x, y, err := mr.readPoint()
+		if err != nil {
+			return err
+		}
+		x, y = m.Transform(x, y)
+		patch.p[pos[0]][pos[1]] = transform.NewPoint(x, y)
+		return nil
+	}
+	readColor := func(patch *meshPatch, i int) error {
+		comps, err := mr.readColor()
+		if err != nil {
+			return err
+		}
+		patch.vals[i], err = rz.values(comps)
+		return err
+	}
+
+	var prev *meshPatch
+	for {
+		flag, err := mr.readFlag()
+		if err != nil {
+			break
+		}
+
+		patch := &meshPatch{}
+		start, colorStart := 0, 0
+		if flag != 0 {
+			if prev == nil || flag > 3 {
+				common.Log.Debug("ERROR: invalid patch mesh flag: %d", flag)
+				break
+			}
+
+			// The first edge of the patch is shared with the previous patch.
+			first := 3 * flag
+			for i := 0; i < 4; i++ {
+				src := patchBoundary[(first+i)%12]
+				dst := patchBoundary[i]
+				patch.p[dst[0]][dst[1]] = prev.p[src[0]][src[1]]
+			}
+			patch.vals[0] = prev.vals[flag]
+			patch.vals[1] = prev.vals[(flag+1)%4]
+			start, colorStart = 4, 2
+		}
+
+		for _, pos := range patchBoundary[start:] {
+			if err = readPoint(patch, pos); err != nil {
+				break
+			}
+		}
+		if err == nil && tensor {
+			for _, pos := range patchInternal {
+				if err = readPoint(patch, pos); err != nil {
+					break
+				}
+			}
+		}
+		for i := colorStart; err == nil && i < 4; i++ {
+			err = readColor(patch, i)
+		}
+		if err != nil {
+			break
+		}
+		if !tensor {
+			patch.computeCoonsInternal()
+		}
+
+		rz.fillPatch(patch)
+		prev = patch
+	}
+
+	return rz.im, nil
+}
+
+// computeCoonsInternal computes the internal control points of a Coons
+// patch, so that it can be painted as a tensor-product patch.
+func (patch *meshPatch) computeCoonsInternal() {
+	p := &patch.p
+	internal := func(c, e1, e2, o1, o2, f1, f2, d transform.Point) transform.Point {
+		return transform.NewPoint(
+			(-4*c.X+6*(e1.X+e2.X)-2*(o1.X+o2.X)+3*(f1.X+f2.X)-d.X)/9,
+			(-4*c.Y+6*(e1.Y+e2.Y)-2*(o1.Y+o2.Y)+3*(f1.Y+f2.Y)-d.Y)/9,
+		)
+	}
+
+	p[1][1] = internal(p[0][0], p[0][1], p[1][0], p[0][3], p[3][0], p[3][1], p[1][3], p[3][3])
+	p[1][2] = internal(p[0][3], p[0][2], p[1][3], p[0][0], p[3][3], p[3][2], p[1][0], p[3][0])
+	p[2][1] = internal(p[3][0], p[3][1], p[2][0], p[3][3], p[0][0], p[0][1], p[2][3], p[0][3])
+	p[2][2] = internal(p[3][3], p[3][2], p[2][3], p[3][0], p[0][3], p[0][2], p[2][0], p[0][0])
+}
+
+// point returns the position of the patch at the parametric coordinates u, v.
+func (patch *meshPatch) point(u, v float64) transform.Point {
+	bu, bv := bernstein(u), bernstein(v)
+
+	var x, y float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			w := bu[i] * bv[j]
+			x += w * patch.p[i][j].X
+			y += w * patch.p[i][j].Y
+		}
+	}
+	return transform.NewPoint(x, y)
+}
+
+// values returns the bilinear interpolation of the corner values of the
+// patch at the parametric coordinates u, v.
+func (patch *meshPatch) values(u, v float64) []float64 {
+	c00, c03, c33, c30 := patch.vals[0], patch.vals[1], patch.vals[2], patch.vals[3]
+
+	vals := make([]float64, len(c00))
+	for i := range vals {
+		vals[i] = (1-u)*(1-v)*c00[i] + (1-u)*v*c03[i] + u*v*c33[i] + u*(1-v)*c30[i]
+	}
+	return vals
+}
+
+// fillPatch paints a patch by subdividing it into a grid of Gouraud-shaded
+// triangles. The grid density depends on the size of the patch in device space.
+func (rz *meshRasterizer) fillPatch(patch *meshPatch) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < 4; i++ {
+		for _, p := range patch.p[i] {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+
+	const maxSteps = 64
+	steps := int(math.Ceil(math.Max(maxX-minX, maxY-minY) / 3))
+	if steps < 2 {
+		steps = 2
+	} else if steps > maxSteps {
+		steps = maxSteps
+	}
+
+	grid := make([][]meshVertex, steps+1)
+	for i := range grid {
+		u := float64(i) / float64(steps)
+		grid[i] = make([]meshVertex, steps+1)
+		for j := range grid[i] {
+			v := float64(j) / float64(steps)
+			p := patch.point(u, v)
+			grid[i][j] = meshVertex{x: p.X, y: p.Y, vals: patch.values(u, v)}
+		}
+	}
+
+	for i := 0; i < steps; i++ {
+		for j := 0; j < steps; j++ {
+			rz.fillTriangle(grid[i][j], grid[i+1][j], grid[i+1][j+1])
+			rz.fillTriangle(grid[i][j], grid[i+1][j+1], grid[i][j+1])
+		}
+	}
+}
+
+// bernstein returns the cubic Bernstein polynomials evaluated at t.
+func bernstein(t float64) [4]float64 {
+	mt := 1 - t
+	return [4]float64{mt * mt * mt, 3 * t * mt * mt, 3 * t * t * mt, t * t * t}
+}
+
+// invertMatrix returns the inverse of the specified transformation matrix.
+// Returns false if the matrix is not invertible.
+func invertMatrix(m transform.Matrix) (transform.Matrix, bool) {
+	det := m[0]*m[4] - m[1]*m[3]
+	if math.Abs(det) < 1e-12 {
+		return transform.Matrix{}, false
+	}
+
+	return transform.NewMatrix(
+		m[4]/det, -m[1]/det,
+		-m[3]/det, m[0]/det,
+		(m[3]*m[7]-m[4]*m[6])/det, (m[1]*m[6]-m[0]*m[7])/det,
+	), true
+}
+
+// getFloats returns the numeric values of the specified array or the
+// default values if the array is nil.
+func getFloats(arr *core.PdfObjectArray, defaults []float64) ([]float64, error) {
+	if arr == nil {
+		return defaults, nil
+	}
+	return core.GetNumbersAsFloat(arr.Elements())
+}
+
+func toUint8(val float64) uint8 {
+	return uint8(math.Round(math.Min(math.Max(val, 0), 1) * 255))
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
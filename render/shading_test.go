@@ -0,0 +1,92 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+)
+
+// axialTestShading is an axial shading from red at x=0 to blue at x=100.
+const axialTestShading = `<<
+	/ShadingType 2 /ColorSpace /DeviceRGB /Coords [0 0 100 0]
+	/Function << /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [0 0 1] /N 1 >>
+	/Extend [true true]
+>>`
+
+// TestAxialShading tests that the sh operator paints the clipping region with an axial shading
+// whose colors at the endpoints are the colors of its function at 0 and 1.
+func TestAxialShading(t *testing.T) {
+	page := newTestPage(t, 100, 20, "/Sh0 sh")
+	require.NoError(t, page.Resources.SetShadingByName("Sh0", parseTestDict(t, axialTestShading)))
+
+	img, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+	require.Equal(t, 100, img.Bounds().Dx())
+
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	requireColor(t, img, 0, 10, red, 8)
+	requireColor(t, img, 99, 10, blue, 8)
+	requireColor(t, img, 50, 10, color.RGBA{R: 128, B: 128, A: 255}, 8)
+
+	// The colors only vary along the axis.
+	require.Equal(t, rgbaAt(img, 25, 0), rgbaAt(img, 25, 19))
+}
+
+// TestShadingPatternFill tests that paths filled with a shading pattern are painted with the
+// shading, which is positioned by the matrix of the pattern rather than by the path.
+func TestShadingPatternFill(t *testing.T) {
+	page := newTestPage(t, 100, 20, "/Pattern cs /P0 scn 50 0 50 20 re f")
+	pattern := parseTestDict(t, `<< /PatternType 2 /Matrix [1 0 0 1 0 0] >>`)
+	pattern.Set("Shading", parseTestDict(t, axialTestShading))
+	require.NoError(t, page.Resources.SetPatternByName("P0", core.MakeIndirectObject(pattern)))
+
+	img, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+
+	// The left half is not painted and the right half is painted with the second half of the
+	// shading.
+	requireColor(t, img, 25, 10, color.RGBA{R: 255, G: 255, B: 255, A: 255}, 0)
+	requireColor(t, img, 51, 10, color.RGBA{R: 128, B: 128, A: 255}, 8)
+	requireColor(t, img, 99, 10, color.RGBA{B: 255, A: 255}, 8)
+}
+
+// TestInvertMatrix tests that the inverses of rotated, skewed and translated matrices undo them.
+func TestInvertMatrix(t *testing.T) {
+	cos, sin := math.Cos(math.Pi/6), math.Sin(math.Pi/6)
+	matrices := []transform.Matrix{
+		transform.NewMatrix(2, 0, 0, 3, 10, 20),
+		transform.NewMatrix(0, 1, -1, 0, 50, -30),
+		transform.NewMatrix(2*cos, 2*sin, -3*sin, 3*cos, 100, 200),
+		transform.NewMatrix(1, 0.5, 0.25, 1, -7, 13),
+	}
+
+	for _, m := range matrices {
+		inv, ok := invertMatrix(m)
+		require.True(t, ok, "m=%s", m)
+		for _, product := range []transform.Matrix{m.Mult(inv), inv.Mult(m)} {
+			identity := transform.IdentityMatrix()
+			for i := range identity {
+				require.InDelta(t, identity[i], product[i], 1e-9, "m=%s inv=%s", m, inv)
+			}
+		}
+
+		// A point transformed by the matrix is mapped back by the inverse.
+		x, y := m.Transform(3, 4)
+		x, y = inv.Transform(x, y)
+		require.InDelta(t, 3, x, 1e-9, "m=%s", m)
+		require.InDelta(t, 4, y, 1e-9, "m=%s", m)
+	}
+
+	_, ok := invertMatrix(transform.NewMatrix(1, 2, 2, 4, 0, 0))
+	require.False(t, ok)
+}
@@ -0,0 +1,91 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"bytes"
+	"image/color"
+	"io"
+	"math"
+	"os"
+
+	"github.com/gnaoh1379/unipdf/model"
+	"github.com/gnaoh1379/unipdf/render/internal/context/svgrender"
+)
+
+// SVGDevice is used to render PDF pages to SVG documents. The pages are
+// processed using the same operations as the image device, but painting
+// operations produce vector elements instead of pixels: paths and clipping
+// paths are preserved, images are embedded as PNG data URIs, axial and
+// radial shadings are converted to gradients when possible and text drawn
+// with TrueType fonts is output as text elements using subset web fonts.
+// Text drawn with Type 1, CFF and Type 3 fonts, as well as text using
+// rendering modes other than fill, is converted to paths.
+type SVGDevice struct {
+	renderer
+
+	// RenderAnnotations specifies whether the normal appearance streams of
+	// the visible page annotations are rendered on top of the page content.
+	// Annotations having the Hidden or NoView flags set are not rendered.
+	RenderAnnotations bool
+
+	// AnnotationFilter is used to select the annotations which are rendered
+	// when RenderAnnotations is true. The annotations for which the function
+	// returns false are skipped. If nil, all visible annotations are
+	// rendered.
+	AnnotationFilter func(annotation *model.PdfAnnotation) bool
+}
+
+// NewSVGDevice returns a new SVG device.
+func NewSVGDevice() *SVGDevice {
+	return &SVGDevice{}
+}
+
+// Render converts the specified PDF page into an SVG document and returns
+// the result.
+func (d *SVGDevice) Render(page *model.PdfPage) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.RenderTo(page, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderTo converts the specified PDF page into an SVG document and writes
+// the result to w.
+func (d *SVGDevice) RenderTo(page *model.PdfPage, w io.Writer) error {
+	// Get the rendered area of the page.
+	box, err := pageBox(page, CropBox)
+	if err != nil {
+		return err
+	}
+	width, height := box.Width(), box.Height()
+
+	// Render page.
+	ctx := svgrender.NewContext(int(math.Ceil(width)), int(math.Ceil(height)))
+	if err := d.renderPage(ctx, page, pageMatrix(box, 0, 1, 1), color.White); err != nil {
+		return err
+	}
+	if d.RenderAnnotations {
+		if err := d.renderAnnotations(ctx, page, d.AnnotationFilter); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Write(w, 0, 0, width, height)
+}
+
+// RenderToPath converts the specified PDF page into an SVG document and
+// saves the result at the specified location.
+func (d *SVGDevice) RenderToPath(page *model.PdfPage, outputPath string) error {
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return d.RenderTo(page, file)
+}
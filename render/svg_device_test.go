@@ -0,0 +1,106 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// svgElements parses the specified SVG document and returns its elements, grouped by name.
+func svgElements(t *testing.T, data []byte) map[string][]xml.StartElement {
+	elems := map[string][]xml.StartElement{}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if start, ok := tok.(xml.StartElement); ok {
+			elems[start.Name.Local] = append(elems[start.Name.Local], start)
+		}
+	}
+	return elems
+}
+
+// svgAttr returns the value of the attribute of elem with the specified local name.
+func svgAttr(elem xml.StartElement, name string) string {
+	for _, attr := range elem.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// TestSVGDevice tests that paths, shadings and images are written as SVG elements.
+func TestSVGDevice(t *testing.T) {
+	page := newTestPage(t, 200, 100, `
+		1 0 0 rg 10 10 30 30 re f
+		0 0 1 RG 2 w 50 10 30 30 re S
+		q 100 0 40 40 re W n /Sh0 sh Q
+		q 20 0 0 20 150 10 cm /Im0 Do Q`)
+	require.NoError(t, page.Resources.SetShadingByName("Sh0", parseTestDict(t, axialTestShading)))
+
+	goImg := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(goImg, goImg.Bounds(), image.NewUniform(color.RGBA{G: 255, A: 255}), image.Point{},
+		draw.Src)
+	img, err := model.ImageHandling.NewImageFromGoImage(goImg)
+	require.NoError(t, err)
+	ximg, err := model.NewXObjectImageFromImage(img, model.NewPdfColorspaceDeviceRGB(),
+		core.NewFlateEncoder())
+	require.NoError(t, err)
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im0", ximg))
+
+	data, err := NewSVGDevice().Render(page)
+	require.NoError(t, err)
+	elems := svgElements(t, data)
+
+	// The document has the size of the page.
+	require.Len(t, elems["svg"], 1)
+	root := elems["svg"][0]
+	require.Equal(t, "200", svgAttr(root, "width"))
+	require.Equal(t, "100", svgAttr(root, "height"))
+	require.Equal(t, "0 0 200 100", svgAttr(root, "viewBox"))
+
+	// The filled and the stroked rectangles are paths.
+	var filled, stroked bool
+	for _, path := range elems["path"] {
+		switch {
+		case svgAttr(path, "fill") == "#ff0000":
+			filled = true
+		case svgAttr(path, "fill") == "none" && svgAttr(path, "stroke") == "#0000ff":
+			stroked = true
+			require.Equal(t, "2", svgAttr(path, "stroke-width"))
+		}
+	}
+	require.True(t, filled, "no red filled path")
+	require.True(t, stroked, "no blue stroked path")
+
+	// The axial shading is a linear gradient from red to blue, clipped by the clipping path.
+	require.Len(t, elems["linearGradient"], 1)
+	stops := elems["stop"]
+	require.True(t, len(stops) >= 2)
+	require.Equal(t, "#ff0000", svgAttr(stops[0], "stop-color"))
+	require.Equal(t, "#0000ff", svgAttr(stops[len(stops)-1], "stop-color"))
+	require.NotEmpty(t, elems["clipPath"])
+
+	// The image is embedded as a PNG data URI.
+	require.Len(t, elems["image"], 1)
+	require.True(t, strings.HasPrefix(svgAttr(elems["image"][0], "href"), "data:image/png;base64,"))
+}
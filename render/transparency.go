@@ -0,0 +1,361 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// blendModes maps the names of the PDF blend modes to context blend modes.
+var blendModes = map[core.PdfObjectName]context.BlendMode{
+	"Normal":     context.BlendModeNormal,
+	"Compatible": context.BlendModeNormal,
+	"Multiply":   context.BlendModeMultiply,
+	"Screen":     context.BlendModeScreen,
+	"Overlay":    context.BlendModeOverlay,
+	"Darken":     context.BlendModeDarken,
+	"Lighten":    context.BlendModeLighten,
+	"ColorDodge": context.BlendModeColorDodge,
+	"ColorBurn":  context.BlendModeColorBurn,
+	"HardLight":  context.BlendModeHardLight,
+	"SoftLight":  context.BlendModeSoftLight,
+	"Difference": context.BlendModeDifference,
+	"Exclusion":  context.BlendModeExclusion,
+	"Hue":        context.BlendModeHue,
+	"Saturation": context.BlendModeSaturation,
+	"Color":      context.BlendModeColor,
+	"Luminosity": context.BlendModeLuminosity,
+}
+
+// blendModeFromPdfObject returns the blend mode specified by the BM entry
+// of an ExtGState dictionary. The entry can be either a name or an array of
+// names, in which case the first supported blend mode is used.
+func blendModeFromPdfObject(obj core.PdfObject) (context.BlendMode, bool) {
+	if name, ok := core.GetName(obj); ok {
+		mode, ok := blendModes[*name]
+		return mode, ok
+	}
+
+	if array, ok := core.GetArray(obj); ok {
+		for _, elem := range array.Elements() {
+			if mode, ok := blendModeFromPdfObject(elem); ok {
+				return mode, true
+			}
+		}
+	}
+	return context.BlendModeNormal, false
+}
+
+// transparencyGroup returns the isolated and knockout flags of the
+// transparency group defined by the specified form XObject. The last return
+// value is false if the form does not define a transparency group.
+func transparencyGroup(xform *model.XObjectForm) (isolated, knockout, ok bool) {
+	dict, ok := core.GetDict(xform.Group)
+	if !ok {
+		return false, false, false
+	}
+	if subtype, ok := core.GetName(dict.Get("S")); !ok || *subtype != "Transparency" {
+		return false, false, false
+	}
+
+	isolated, _ = core.GetBoolVal(dict.Get("I"))
+	knockout, _ = core.GetBoolVal(dict.Get("K"))
+	return isolated, knockout, true
+}
+
+// renderSoftMask renders the transparency group of the specified soft mask
+// dictionary and returns the resulting mask. The mask is computed in the
+// coordinate space defined by the current transformation matrix.
+func (r renderer) renderSoftMask(ctx context.Context, smask *core.PdfObjectDictionary,
+	resources *model.PdfPageResources) (*image.Alpha, error) {
+	// Soft masks are computed from the composite colors of their group,
+	// including when rendering separations.
+	r.plate = nil
+
+	subtype, ok := core.GetName(smask.Get("S"))
+	if !ok {
+		return nil, errType
+	}
+	luminosity := *subtype == "Luminosity"
+
+	stream, ok := core.GetStream(smask.Get("G"))
+	if !ok {
+		return nil, errType
+	}
+	xform, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	content, err := xform.GetContentStream()
+	if err != nil {
+		return nil, err
+	}
+	formResources := xform.Resources
+	if formResources == nil {
+		formResources = resources
+	}
+
+	// The backdrop of luminosity masks is specified in the colorspace of the
+	// transparency group. It defaults to black.
+	var backdrop *model.PdfColorDeviceRGB
+	if luminosity {
+		backdrop, err = softMaskBackdrop(smask, xform)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx.Push()
+	ctx.ResetClip()
+	ctx.BeginGroup(true, false)
+	if backdrop != nil {
+		ctx.Push()
+		ctx.SetMatrix(transform.IdentityMatrix())
+		ctx.SetFillRGBA(backdrop.R(), backdrop.G(), backdrop.B(), 1)
+		ctx.DrawRectangle(0, 0, float64(ctx.Width()), float64(ctx.Height()))
+		ctx.Fill()
+		ctx.Pop()
+	}
+
+	err = r.setFormSpace(ctx, xform)
+	if err == nil {
+		err = r.renderContentStream(ctx, string(content), formResources)
+	}
+	mask := ctx.EndGroupAsMask(luminosity)
+	ctx.Pop()
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the transfer function of the mask.
+	trObj := core.ResolveReference(smask.Get("TR"))
+	if name, ok := trObj.(*core.PdfObjectName); trObj == nil || ok && *name == "Identity" {
+		return mask, nil
+	}
+	fn, err := model.NewPdfFunctionFromPdfObject(trObj)
+	if err != nil {
+		return nil, err
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		out, err := fn.Evaluate([]float64{float64(i) / 255})
+		if err != nil {
+			return nil, err
+		}
+		if len(out) == 0 {
+			return nil, errRange
+		}
+		lut[i] = uint8(math.Round(math.Min(math.Max(out[0], 0), 1) * 255))
+	}
+	for i, a := range mask.Pix {
+		mask.Pix[i] = lut[a]
+	}
+
+	return mask, nil
+}
+
+// softMaskBackdrop returns the backdrop color of the luminosity soft mask
+// defined by the specified dictionary.
+func softMaskBackdrop(smask *core.PdfObjectDictionary, xform *model.XObjectForm) (*model.PdfColorDeviceRGB, error) {
+	array, ok := core.GetArray(smask.Get("BC"))
+	if !ok {
+		return model.NewPdfColorDeviceRGB(0, 0, 0), nil
+	}
+	vals, err := core.GetNumbersAsFloat(array.Elements())
+	if err != nil {
+		return nil, err
+	}
+
+	var cs model.PdfColorspace
+	if group, ok := core.GetDict(xform.Group); ok && group.Get("CS") != nil {
+		if cs, err = model.NewPdfColorspaceFromPdfObject(group.Get("CS")); err != nil {
+			return nil, err
+		}
+	} else {
+		switch len(vals) {
+		case 1:
+			cs = model.NewPdfColorspaceDeviceGray()
+		case 4:
+			cs = model.NewPdfColorspaceDeviceCMYK()
+		default:
+			cs = model.NewPdfColorspaceDeviceRGB()
+		}
+	}
+
+	color, err := cs.ColorFromFloats(vals)
+	if err != nil {
+		return nil, err
+	}
+	return toRGB(cs, color)
+}
+
+// imageAlpha returns the alpha values of the specified image, as defined by
+// its soft mask or its mask. The returned alpha mask has the size of the
+// image. Returns nil if the image is not masked.
+func imageAlpha(ximg *model.XObjectImage, img *model.Image) (*image.Alpha, error) {
+	width, height := int(img.Width), int(img.Height)
+
+	if ximg.SMask != nil {
+		stream, ok := core.GetStream(ximg.SMask)
+		if !ok {
+			return nil, errType
+		}
+		return maskAlpha(stream, width, height, false)
+	}
+
+	if ximg.Mask == nil {
+		return nil, nil
+	}
+
+	// Stencil masking.
+	if stream, ok := core.GetStream(ximg.Mask); ok {
+		return maskAlpha(stream, width, height, true)
+	}
+
+	// Color key masking. The mask specifies a range of sample values for each
+	// color component. Pixels with all components in range are masked out.
+	array, ok := core.GetArray(ximg.Mask)
+	if !ok {
+		return nil, errType
+	}
+	ranges, err := core.GetNumbersAsFloat(array.Elements())
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) != 2*img.ColorComponents {
+		return nil, errRange
+	}
+
+	sampler := newImageSampler(img.Data, width, int(img.BitsPerComponent), img.ColorComponents)
+	alpha := image.NewAlpha(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			masked := true
+			for c := 0; c < img.ColorComponents && masked; c++ {
+				val := float64(sampler.sample(x, y, c))
+				masked = val >= ranges[2*c] && val <= ranges[2*c+1]
+			}
+			if !masked {
+				alpha.SetAlpha(x, y, color.Alpha{A: 255})
+			}
+		}
+	}
+
+	return alpha, nil
+}
+
+// maskAlpha returns the alpha values defined by the specified soft mask or
+// stencil mask image, scaled to the specified dimensions. For stencil masks,
+// samples with the value 1 are masked out, unless the Decode array of the
+// mask specifies otherwise.
+func maskAlpha(stream *core.PdfObjectStream, width, height int, stencil bool) (*image.Alpha, error) {
+	ximg, err := model.NewXObjectImageFromStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	if ximg.BitsPerComponent == nil {
+		bpc := int64(1)
+		ximg.BitsPerComponent = &bpc
+	}
+	ximg.ColorSpace = model.NewPdfColorspaceDeviceGray()
+
+	img, err := ximg.ToImage()
+	if err != nil {
+		return nil, err
+	}
+	maskWidth, maskHeight := int(img.Width), int(img.Height)
+	if maskWidth <= 0 || maskHeight <= 0 {
+		return nil, errRange
+	}
+
+	decode := []float64{0, 1}
+	if array, ok := core.GetArray(ximg.Decode); ok {
+		vals, err := core.GetNumbersAsFloat(array.Elements())
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) == 2 {
+			decode = vals
+		}
+	}
+
+	sampler := newImageSampler(img.Data, maskWidth, int(img.BitsPerComponent), 1)
+	maxVal := float64(uint32(1)<<uint(img.BitsPerComponent) - 1)
+
+	alpha := image.NewAlpha(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		my := y * maskHeight / height
+		for x := 0; x < width; x++ {
+			mx := x * maskWidth / width
+
+			val := decode[0] + float64(sampler.sample(mx, my, 0))/maxVal*(decode[1]-decode[0])
+			if stencil {
+				val = 1 - val
+			}
+			alpha.SetAlpha(x, y, color.Alpha{A: uint8(math.Round(math.Min(math.Max(val, 0), 1) * 255))})
+		}
+	}
+
+	return alpha, nil
+}
+
+// applyImageAlpha returns a copy of the specified image, having its alpha
+// values multiplied by the values of the specified alpha mask.
+func applyImageAlpha(img image.Image, alpha *image.Alpha) image.Image {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			a := alpha.AlphaAt(x-bounds.Min.X, y-bounds.Min.Y).A
+			c.A = uint8(uint32(c.A) * uint32(a) / 255)
+			out.SetNRGBA(x, y, c)
+		}
+	}
+	return out
+}
+
+// imageSampler reads the samples of decoded image data, in which each row of
+// the image starts at a byte boundary.
+type imageSampler struct {
+	data     []byte
+	bpc      int
+	comps    int
+	rowBytes int
+}
+
+func newImageSampler(data []byte, width, bpc, comps int) *imageSampler {
+	return &imageSampler{
+		data:     data,
+		bpc:      bpc,
+		comps:    comps,
+		rowBytes: (width*comps*bpc + 7) / 8,
+	}
+}
+
+// sample returns the value of the component `c` of the pixel located at x, y.
+func (s *imageSampler) sample(x, y, c int) uint32 {
+	pos := y*s.rowBytes*8 + (x*s.comps+c)*s.bpc
+
+	var val uint32
+	for i := 0; i < s.bpc; i++ {
+		idx := (pos + i) / 8
+		if idx >= len(s.data) {
+			return 0
+		}
+		bit := (s.data[idx] >> uint(7-(pos+i)%8)) & 1
+		val = val<<1 | uint32(bit)
+	}
+	return val
+}
@@ -0,0 +1,107 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// TestConstantAlpha tests that fills are composited with the constant alpha of the ca entry of
+// the graphics state.
+func TestConstantAlpha(t *testing.T) {
+	page := newTestPage(t, 20, 20, "/GS0 gs 1 0 0 rg 0 0 20 20 re f")
+	require.NoError(t, page.Resources.AddExtGState("GS0", parseTestDict(t, `<< /ca 0.5 >>`)))
+
+	img, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+	requireColor(t, img, 10, 10, color.RGBA{R: 255, G: 128, B: 128, A: 255}, 2)
+}
+
+// TestBlendModes tests that fills are blended with their backdrop with the blend mode of the BM
+// entry of the graphics state.
+func TestBlendModes(t *testing.T) {
+	testcases := []struct {
+		mode     string
+		expected color.RGBA
+	}{
+		{"Normal", color.RGBA{R: 255, G: 255, A: 255}},
+		{"Multiply", color.RGBA{G: 255, A: 255}},
+		{"Screen", color.RGBA{R: 255, G: 255, B: 255, A: 255}},
+		{"Darken", color.RGBA{G: 255, A: 255}},
+		{"Difference", color.RGBA{R: 255, B: 255, A: 255}},
+	}
+
+	for _, tc := range testcases {
+		// Yellow is painted on cyan.
+		page := newTestPage(t, 20, 20, "0 1 1 rg 0 0 20 20 re f /GS0 gs 1 1 0 rg 0 0 20 20 re f")
+		gs := parseTestDict(t, `<< /BM /`+tc.mode+` >>`)
+		require.NoError(t, page.Resources.AddExtGState("GS0", gs))
+
+		img, err := NewImageDevice().Render(page)
+		require.NoError(t, err)
+		requireColor(t, img, 10, 10, tc.expected, 2)
+	}
+}
+
+// TestSoftMask tests that fills are masked by the luminosity of the group of the soft mask of
+// the graphics state.
+func TestSoftMask(t *testing.T) {
+	page := newTestPage(t, 20, 20, "/GS0 gs 1 0 0 rg 0 0 20 20 re f")
+
+	// The left half of the mask is white and its right half is 50% gray.
+	group := newTestStream(t, `<<
+		/Type /XObject /Subtype /Form /BBox [0 0 20 20]
+		/Group << /S /Transparency /CS /DeviceRGB >>
+	>>`, "1 g 0 0 10 20 re f 0.5 g 10 0 10 20 re f")
+	gs := parseTestDict(t, `<< /SMask << /S /Luminosity >> >>`)
+	smask, ok := core.GetDict(gs.Get("SMask"))
+	require.True(t, ok)
+	smask.Set("G", group)
+	require.NoError(t, page.Resources.AddExtGState("GS0", gs))
+
+	img, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+	requireColor(t, img, 5, 10, color.RGBA{R: 255, A: 255}, 2)
+	requireColor(t, img, 15, 10, color.RGBA{R: 255, G: 128, B: 128, A: 255}, 2)
+}
+
+// TestImageSoftMask tests that images are composited with the alpha of their SMask images.
+func TestImageSoftMask(t *testing.T) {
+	page := newTestPage(t, 20, 20, "q 20 0 0 20 0 0 cm /Im0 Do Q")
+
+	// A red image whose left half is opaque and whose right half is transparent.
+	goImg := image.NewRGBA(image.Rect(0, 0, 20, 1))
+	draw.Draw(goImg, goImg.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{},
+		draw.Src)
+	img, err := model.ImageHandling.NewImageFromGoImage(goImg)
+	require.NoError(t, err)
+	ximg, err := model.NewXObjectImageFromImage(img, model.NewPdfColorspaceDeviceRGB(),
+		core.NewFlateEncoder())
+	require.NoError(t, err)
+	maskImg := model.Image{Width: 20, Height: 1, BitsPerComponent: 8, ColorComponents: 1,
+		Data: append(bytes.Repeat([]byte{0xff}, 10), make([]byte, 10)...)}
+	smask, err := model.NewXObjectImageFromImage(&maskImg, model.NewPdfColorspaceDeviceGray(),
+		core.NewFlateEncoder())
+	require.NoError(t, err)
+	ximg.SMask = smask.ToPdfObject()
+	require.NoError(t, page.Resources.SetXObjectImageByName("Im0", ximg))
+
+	device := NewImageDevice()
+	device.Background = color.Transparent
+	out, err := device.Render(page)
+	require.NoError(t, err)
+	requireColor(t, out, 5, 10, color.RGBA{R: 255, A: 255}, 2)
+	require.Zero(t, rgbaAt(out, 15, 10).A)
+}
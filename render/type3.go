@@ -0,0 +1,174 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"errors"
+
+	"github.com/gnaoh1379/unipdf/core"
+	"github.com/gnaoh1379/unipdf/internal/textencoding"
+	"github.com/gnaoh1379/unipdf/internal/transform"
+	"github.com/gnaoh1379/unipdf/model"
+	"github.com/gnaoh1379/unipdf/render/internal/context"
+)
+
+// type3Font renders the glyphs of Type 3 fonts, which are described by
+// content streams (section 9.6.5 PDF32000_2008).
+type type3Font struct {
+	r         renderer
+	matrix    transform.Matrix
+	charProcs *core.PdfObjectDictionary
+	resources *model.PdfPageResources
+
+	// Character code to glyph name mapping.
+	differences map[textencoding.CharCode]textencoding.GlyphName
+	encoder     textencoding.SimpleEncoder
+
+	// Glyph widths, expressed in glyph space units.
+	firstChar textencoding.CharCode
+	widths    []float64
+}
+
+// newType3Font returns a new Type 3 font based on the specified font
+// dictionary. The specified resources are used by the glyph content streams
+// if the font does not have its own resources.
+func newType3Font(r renderer, fontDict *core.PdfObjectDictionary,
+	resources *model.PdfPageResources) (*type3Font, error) {
+	charProcs, ok := core.GetDict(fontDict.Get("CharProcs"))
+	if !ok {
+		return nil, errors.New("missing Type 3 font CharProcs")
+	}
+
+	font := &type3Font{
+		r:         r,
+		matrix:    transform.NewMatrix(0.001, 0, 0, 0.001, 0, 0),
+		charProcs: charProcs,
+		resources: resources,
+	}
+
+	// Get font matrix.
+	if array, ok := core.GetArray(fontDict.Get("FontMatrix")); ok {
+		mf, err := core.GetNumbersAsFloat(array.Elements())
+		if err != nil {
+			return nil, err
+		}
+		if len(mf) != 6 {
+			return nil, errRange
+		}
+		font.matrix = transform.NewMatrix(mf[0], mf[1], mf[2], mf[3], mf[4], mf[5])
+	}
+
+	// Get font encoding.
+	switch encoding := core.ResolveReference(fontDict.Get("Encoding")).(type) {
+	case *core.PdfObjectName:
+		enc, err := textencoding.NewSimpleTextEncoder(string(*encoding), nil)
+		if err != nil {
+			return nil, err
+		}
+		font.encoder = enc
+	case *core.PdfObjectDictionary:
+		if diffs, ok := core.GetArray(encoding.Get("Differences")); ok {
+			differences, err := textencoding.FromFontDifferences(diffs)
+			if err != nil {
+				return nil, err
+			}
+			font.differences = differences
+		}
+		if baseName, ok := core.GetNameVal(encoding.Get("BaseEncoding")); ok {
+			enc, err := textencoding.NewSimpleTextEncoder(baseName, nil)
+			if err != nil {
+				return nil, err
+			}
+			font.encoder = enc
+		}
+	}
+
+	// Get glyph widths.
+	if firstChar, ok := core.GetIntVal(fontDict.Get("FirstChar")); ok {
+		font.firstChar = textencoding.CharCode(firstChar)
+	}
+	if array, ok := core.GetArray(fontDict.Get("Widths")); ok {
+		widths, err := core.GetNumbersAsFloat(array.Elements())
+		if err != nil {
+			return nil, err
+		}
+		font.widths = widths
+	}
+
+	// Get font resources.
+	if resDict, ok := core.GetDict(fontDict.Get("Resources")); ok {
+		fontResources, err := model.NewPdfPageResourcesFromDict(resDict)
+		if err != nil {
+			return nil, err
+		}
+		font.resources = fontResources
+	}
+
+	return font, nil
+}
+
+// RenderGlyph renders the glyph of the specified character code, by
+// processing the content stream which describes it.
+func (f *type3Font) RenderGlyph(ctx context.Context, code textencoding.CharCode) error {
+	glyph, ok := f.glyphName(code)
+	if !ok {
+		return nil
+	}
+	stream, ok := core.GetStream(f.charProcs.Get(core.PdfObjectName(glyph)))
+	if !ok {
+		return nil
+	}
+	content, err := core.DecodeStream(stream)
+	if err != nil {
+		return err
+	}
+
+	// The glyph description can change the text state, which has to be
+	// restored after the glyph is rendered.
+	textState := ctx.TextState()
+	prevState := *textState
+	defer func() {
+		*textState = prevState
+	}()
+
+	// The glyph is painted with the colors of the text, which are the colors
+	// of the context, unless it sets its own colors.
+	r := f.r
+	r.keepFill, r.keepStroke = true, true
+
+	ctx.Push()
+	defer ctx.Pop()
+	ctx.SetMatrix(ctx.Matrix().Mult(f.matrix))
+	return r.renderContentStream(ctx, string(content), f.resources)
+}
+
+// GlyphAdvance returns the horizontal displacement of the glyph of the
+// specified character code, in text space units.
+func (f *type3Font) GlyphAdvance(code textencoding.CharCode) (float64, bool) {
+	if code < f.firstChar || int(code-f.firstChar) >= len(f.widths) {
+		return 0, false
+	}
+
+	w := f.widths[code-f.firstChar]
+	return w * f.matrix[0], true
+}
+
+// glyphName returns the name of the glyph corresponding to the specified
+// character code.
+func (f *type3Font) glyphName(code textencoding.CharCode) (textencoding.GlyphName, bool) {
+	if glyph, ok := f.differences[code]; ok {
+		return glyph, true
+	}
+	if f.encoder == nil {
+		return "", false
+	}
+
+	r, ok := f.encoder.CharcodeToRune(code)
+	if !ok {
+		return "", false
+	}
+	return textencoding.RuneToGlyph(r)
+}
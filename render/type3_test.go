@@ -0,0 +1,106 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gnaoh1379/unipdf/model"
+)
+
+// countPixels returns the number of pixels of `img` with color `c`, and the number of pixels
+// that are not white.
+func countPixels(img image.Image, c color.RGBA) (matching, painted int) {
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			switch rgbaAt(img, x, y) {
+			case c:
+				matching++
+				painted++
+			case white:
+			default:
+				painted++
+			}
+		}
+	}
+	return matching, painted
+}
+
+// TestTextRenderingModes tests that text drawn with the invisible rendering mode leaves no
+// pixels and that text drawn with a clipping rendering mode clips the paths drawn after it.
+func TestTextRenderingModes(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	render := func(contents string) image.Image {
+		page := newTestPage(t, 100, 40, contents)
+		helvetica := model.NewStandard14FontMustCompile(model.HelveticaName)
+		require.NoError(t, page.Resources.SetFontByName("F1", helvetica.ToPdfObject()))
+
+		device := NewImageDevice()
+		device.DisableAntialiasing = true
+		img, err := device.Render(page)
+		require.NoError(t, err)
+		return img
+	}
+
+	// Filled text.
+	filled, painted := countPixels(render("1 0 0 rg BT /F1 30 Tf 0 Tr 5 10 Td (HI) Tj ET"), red)
+	require.NotZero(t, filled)
+	require.Equal(t, filled, painted)
+
+	// Invisible text.
+	_, painted = countPixels(render("1 0 0 rg BT /F1 30 Tf 3 Tr 5 10 Td (HI) Tj ET"), red)
+	require.Zero(t, painted)
+
+	// Invisible text added to the clipping path: only the glyphs of the rectangle are painted.
+	clipped, painted := countPixels(render(
+		"q BT /F1 30 Tf 7 Tr 5 10 Td (HI) Tj ET 1 0 0 rg 0 0 100 40 re f Q"), red)
+	require.Equal(t, clipped, painted)
+	require.InDelta(t, filled, clipped, float64(filled)/10)
+}
+
+// TestType3Font tests that the glyphs of Type 3 fonts are drawn by their content streams, scaled
+// by the font matrix and the font size and advanced by their widths, and that they are painted
+// with the color of the text unless they are colored glyphs that set their own colors.
+func TestType3Font(t *testing.T) {
+	page := newTestPage(t, 100, 40, "1 0 0 rg BT /F1 20 Tf 10 10 Td (abac) Tj ET")
+
+	// The glyph of "a" is an uncolored square, whose color operators are ignored, the glyph of
+	// "b" is blank and the glyph of "c" is a blue square.
+	charProcs := parseTestDict(t, "<< >>")
+	charProcs.Set("a", newTestStream(t, "<< >>",
+		"1000 0 0 0 1000 1000 d1 0 1 0 rg 0 0 1000 1000 re f"))
+	charProcs.Set("b", newTestStream(t, "<< >>", "500 0 d0"))
+	charProcs.Set("c", newTestStream(t, "<< >>", "1000 0 d0 0 0 1 rg 0 0 1000 1000 re f"))
+	font := parseTestDict(t, `<<
+		/Type /Font /Subtype /Type3 /FontBBox [0 0 1000 1000] /FontMatrix [0.001 0 0 0.001 0 0]
+		/Encoding << /Type /Encoding /Differences [97 /a /b /c] >>
+		/FirstChar 97 /LastChar 99 /Widths [1000 500 1000]
+	>>`)
+	font.Set("CharProcs", charProcs)
+	require.NoError(t, page.Resources.SetFontByName("F1", font))
+
+	img, err := NewImageDevice().Render(page)
+	require.NoError(t, err)
+
+	// The glyphs are drawn from x=10 to x=80 and y=10 to y=30 in page space, i.e. rows 10 to 30
+	// of the image.
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	requireColor(t, img, 20, 20, red, 0)
+	requireColor(t, img, 35, 20, white, 0)
+	requireColor(t, img, 50, 20, red, 0)
+	requireColor(t, img, 70, 20, blue, 0)
+	requireColor(t, img, 85, 20, white, 0)
+	requireColor(t, img, 20, 5, white, 0)
+	requireColor(t, img, 20, 35, white, 0)
+}